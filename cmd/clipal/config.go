@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/web"
+)
+
+// configImporter previews (dryRun) or applies an import bundle.
+type configImporter func(bundle []byte, strategy string, dryRun bool) (web.ConfigImportResponse, error)
+
+func printConfigUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage:")
+	fmt.Fprintln(w, "  clipal config import <file> [--strategy merge|replace|add_only] [--dry-run] [--yes] [--config-dir DIR]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Import a bundle written by the Web UI export (or GET /api/config/export).")
+	fmt.Fprintln(w, "The changes are shown first and applied only after confirmation.")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Strategies:")
+	fmt.Fprintln(w, "  merge     Add new providers and overwrite same-named ones (default)")
+	fmt.Fprintln(w, "  replace   Make each imported client section match the bundle, removing other providers")
+	fmt.Fprintln(w, "  add_only  Only add providers and credentials that do not exist yet")
+}
+
+func runConfig(args []string) {
+	if len(args) == 0 || isHelpToken(args[0]) {
+		printConfigUsage(os.Stdout)
+		return
+	}
+	if args[0] != "import" {
+		fmt.Fprintf(os.Stderr, "clipal config: unknown action %q\n\n", args[0])
+		printConfigUsage(os.Stderr)
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("config import", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		printConfigUsage(os.Stderr)
+	}
+	configDir := fs.String("config-dir", "", "Configuration directory (default: ~/.clipal)")
+	strategy := fs.String("strategy", web.ConfigImportMerge, "How to combine the bundle with the current config: merge, replace or add_only")
+	dryRun := fs.Bool("dry-run", false, "Show the changes without applying them")
+	yes := fs.Bool("yes", false, "Apply without asking for confirmation")
+	offline := fs.Bool("offline", false, "Write the config files directly even if clipal is running")
+	timeout := fs.Duration("timeout", 10*time.Second, "Timeout for requests to the running instance")
+
+	// Allow flags after the file name.
+	var files []string
+	rest := args[1:]
+	for {
+		if err := fs.Parse(rest); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return
+			}
+			os.Exit(2)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		files = append(files, fs.Arg(0))
+		rest = fs.Args()[1:]
+	}
+	if len(files) != 1 {
+		fmt.Fprintf(os.Stderr, "clipal config import: expected exactly one bundle file\n")
+		os.Exit(2)
+	}
+
+	bundle, err := os.ReadFile(files[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "clipal config import failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfgDir := *configDir
+	if cfgDir == "" {
+		cfgDir = config.GetConfigDir()
+	}
+	cfg, err := config.Load(cfgDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "clipal config import failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	var importer configImporter
+	if !*offline {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		h := checkHealth(ctx, healthCandidateURLs(strings.TrimSpace(cfg.Global.ListenAddr), cfg.Global.Port))
+		cancel()
+		if h.OK {
+			importer = runningConfigImporter(strings.TrimSuffix(h.URL, "/health"), *timeout)
+		}
+	}
+	if importer == nil {
+		importer = offlineConfigImporter(cfgDir)
+	}
+
+	if _, err := importConfigBundle(os.Stdout, bufio.NewReader(os.Stdin), importer, bundle, strings.TrimSpace(*strategy), *dryRun, *yes); err != nil {
+		fmt.Fprintf(os.Stderr, "clipal config import failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// importConfigBundle previews the import, asks for confirmation unless yes is
+// set, and applies it. It reports whether anything was written.
+func importConfigBundle(w io.Writer, in *bufio.Reader, importer configImporter, bundle []byte, strategy string, dryRun bool, yes bool) (bool, error) {
+	preview, err := importer(bundle, strategy, true)
+	if err != nil {
+		return false, err
+	}
+	writeConfigImportChanges(w, preview)
+	if len(preview.Changes) == 0 || dryRun {
+		return false, nil
+	}
+	if !yes {
+		fmt.Fprintf(w, "Apply %d changes? [y/N] ", len(preview.Changes))
+		answer, _ := in.ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			fmt.Fprintln(w, "Import canceled.")
+			return false, nil
+		}
+	}
+	resp, err := importer(bundle, strategy, false)
+	if err != nil {
+		return false, err
+	}
+	fmt.Fprintln(w, resp.Message)
+	return resp.Applied, nil
+}
+
+func writeConfigImportChanges(w io.Writer, resp web.ConfigImportResponse) {
+	signs := map[string]string{"add": "+", "update": "~", "remove": "-"}
+	for _, c := range resp.Changes {
+		line := fmt.Sprintf("%s %s", orValue(signs[c.Action], "~"), c.Section)
+		if c.Item != "" {
+			line += " " + c.Item
+		}
+		if len(c.Fields) > 0 {
+			line += " (" + strings.Join(c.Fields, ", ") + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "Strategy %s: %s\n", resp.Strategy, resp.Message)
+}
+
+func offlineConfigImporter(configDir string) configImporter {
+	api := web.NewAPI(configDir, version, nil)
+	api.SetRevisionSource(config.RevisionSourceCLI)
+	return func(raw []byte, strategy string, dryRun bool) (web.ConfigImportResponse, error) {
+		var bundle web.ConfigImportBundle
+		if err := json.Unmarshal(raw, &bundle); err != nil {
+			return web.ConfigImportResponse{}, fmt.Errorf("invalid import bundle: %w", err)
+		}
+		return api.ImportConfig(bundle, strategy, dryRun)
+	}
+}
+
+// runningConfigImporter sends the bundle to the running instance so its
+// runtime picks up the change. Each request gets its own timeout because the
+// confirmation prompt sits between preview and apply.
+func runningConfigImporter(baseURL string, timeout time.Duration) configImporter {
+	return func(bundle []byte, strategy string, dryRun bool) (web.ConfigImportResponse, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		values := url.Values{"strategy": {strategy}}
+		if dryRun {
+			values.Set("dry_run", "true")
+		}
+		var resp web.ConfigImportResponse
+		err := doAPIRequest(ctx, http.MethodPost, baseURL+"/api/config/import?"+values.Encode(), bytes.NewReader(bundle), &resp)
+		return resp, err
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lansespirit/Clipal/internal/web"
+)
+
+func TestImportConfigBundlePreviewsAndConfirms(t *testing.T) {
+	var applies int
+	importer := func(bundle []byte, strategy string, dryRun bool) (web.ConfigImportResponse, error) {
+		resp := web.ConfigImportResponse{
+			Strategy: strategy,
+			DryRun:   dryRun,
+			Changes: []web.ConfigImportChange{
+				{Section: "openai", Item: "p1", Action: "update", Fields: []string{"base_url"}},
+				{Section: "openai", Item: "p2", Action: "add"},
+			},
+			Message: "2 changes would be applied",
+		}
+		if !dryRun {
+			applies++
+			resp.Applied = true
+			resp.Message = "2 changes applied"
+		}
+		return resp, nil
+	}
+
+	for _, tt := range []struct {
+		name    string
+		input   string
+		dryRun  bool
+		yes     bool
+		applied bool
+	}{
+		{name: "Declined", input: "n\n"},
+		{name: "Confirmed", input: "y\n", applied: true},
+		{name: "Yes", yes: true, applied: true},
+		{name: "DryRun", dryRun: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			applies = 0
+			var out bytes.Buffer
+			applied, err := importConfigBundle(&out, bufio.NewReader(strings.NewReader(tt.input)), importer, []byte("{}"), web.ConfigImportMerge, tt.dryRun, tt.yes)
+			if err != nil {
+				t.Fatalf("importConfigBundle: %v", err)
+			}
+			if applied != tt.applied || (applies == 1) != tt.applied {
+				t.Fatalf("applied = %v (calls %d), want %v", applied, applies, tt.applied)
+			}
+			got := out.String()
+			if !strings.Contains(got, "~ openai p1 (base_url)\n+ openai p2\n") {
+				t.Fatalf("output = %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/logger"
+	"github.com/lansespirit/Clipal/internal/telemetry"
+)
+
+func runLogs(args []string) {
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	configDir := fs.String("config-dir", "", "Configuration directory (default: ~/.clipal)")
+	var follow bool
+	fs.BoolVar(&follow, "f", false, "Keep printing new entries as they are written")
+	fs.BoolVar(&follow, "follow", false, "Same as -f")
+	lines := fs.Int("n", 50, "Number of matching entries to print before following (0 for all)")
+	level := fs.String("level", "", "Minimum level: debug, info, warn or error")
+	provider := fs.String("provider", "", "Only entries that mention this provider")
+	client := fs.String("client", "", "Only entries for this client type (claude, openai, gemini)")
+	grep := fs.String("grep", "", "Only entries whose message contains this text (case-insensitive)")
+	since := fs.String("since", "", "Start of the range: RFC 3339 time, date, or look-back such as 1h or 2d")
+	until := fs.String("until", "", "End of the range (default: now)")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		os.Exit(2)
+	}
+	if extra := fs.Args(); len(extra) > 0 {
+		fmt.Fprintf(os.Stderr, "clipal logs: unexpected argument %q\n", extra[0])
+		os.Exit(2)
+	}
+	if *lines < 0 {
+		fmt.Fprintf(os.Stderr, "clipal logs: -n must not be negative\n")
+		os.Exit(2)
+	}
+
+	now := time.Now()
+	query := logger.Query{
+		Level:    strings.TrimSpace(*level),
+		Client:   strings.TrimSpace(*client),
+		Provider: strings.TrimSpace(*provider),
+		Text:     strings.TrimSpace(*grep),
+	}
+	var err error
+	if query.Since, err = telemetry.ParseLedgerTime(*since, now); err == nil {
+		query.Until, err = telemetry.ParseLedgerTime(*until, now)
+	}
+	if err == nil {
+		err = query.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "clipal logs: %v\n", err)
+		os.Exit(2)
+	}
+
+	cfgDir := *configDir
+	if cfgDir == "" {
+		cfgDir = config.GetConfigDir()
+	}
+	cfg, err := config.Load(cfgDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "clipal logs failed: %v\n", err)
+		os.Exit(1)
+	}
+	dir := logger.Dir(cfgDir, cfg.Global.LogDir)
+
+	if err := printLogs(os.Stdout, dir, query, *lines); err != nil {
+		fmt.Fprintf(os.Stderr, "clipal logs failed: %v\n", err)
+		os.Exit(1)
+	}
+	if !follow {
+		return
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := followLogs(ctx, os.Stdout, dir, query); err != nil {
+		fmt.Fprintf(os.Stderr, "clipal logs failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printLogs writes the last lines entries matching query, redacted, oldest
+// first.
+func printLogs(w io.Writer, dir string, query logger.Query, lines int) error {
+	entries, err := logger.Search(dir, query, lines)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if _, err := fmt.Fprintln(w, logger.Redact(e.String())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func followLogs(ctx context.Context, w io.Writer, dir string, query logger.Query) error {
+	return logger.Follow(ctx, dir, query, func(e logger.Entry) {
+		fmt.Fprintln(w, logger.Redact(e.String()))
+	})
+}
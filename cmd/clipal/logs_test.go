@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lansespirit/Clipal/internal/logger"
+)
+
+func TestPrintLogsFiltersAndRedacts(t *testing.T) {
+	dir := t.TempDir()
+	content := strings.Join([]string{
+		"[INFO ] 2026-03-01 10:00:00.000 [claude] forwarding to: p1",
+		"[WARN ] 2026-03-01 10:00:01.000 [claude] p1 rejected api_key=sk-abcdefghijklmnop",
+		"[ERROR] 2026-03-01 10:00:02.000 [openai] p2 all providers failed",
+	}, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "clipal-2026-03-01.log"), []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := printLogs(&out, dir, logger.Query{Level: "warn", Provider: "p1"}, 10); err != nil {
+		t.Fatalf("printLogs: %v", err)
+	}
+	got := out.String()
+	if strings.Count(got, "\n") != 1 || !strings.HasPrefix(got, "[WARN ] 2026-03-01 10:00:01.000 [claude] p1 rejected") {
+		t.Fatalf("output = %q", got)
+	}
+	if strings.Contains(got, "abcdefghijklmnop") {
+		t.Fatalf("secret not redacted: %q", got)
+	}
+}
@@ -32,6 +32,10 @@ const (
 	rootCommandHelp        rootCommand = "help"
 	rootCommandUpdate      rootCommand = "update"
 	rootCommandStatus      rootCommand = "status"
+	rootCommandUsage       rootCommand = "usage"
+	rootCommandLogs        rootCommand = "logs"
+	rootCommandConfig      rootCommand = "config"
+	rootCommandProvider    rootCommand = "provider"
 	rootCommandService     rootCommand = "service"
 	rootCommandApplyUpdate rootCommand = "__apply-update"
 )
@@ -70,6 +74,18 @@ func main() {
 	case rootCommandStatus:
 		runStatus(args)
 		return
+	case rootCommandUsage:
+		runUsage(args)
+		return
+	case rootCommandLogs:
+		runLogs(args)
+		return
+	case rootCommandConfig:
+		runConfig(args)
+		return
+	case rootCommandProvider:
+		runProvider(args)
+		return
 	case rootCommandService:
 		runService(args)
 		return
@@ -97,6 +113,14 @@ func resolveRootCommand(args []string) (rootCommand, []string, error) {
 		return rootCommandUpdate, args[1:], nil
 	case "status":
 		return rootCommandStatus, args[1:], nil
+	case "usage":
+		return rootCommandUsage, args[1:], nil
+	case "logs":
+		return rootCommandLogs, args[1:], nil
+	case "config":
+		return rootCommandConfig, args[1:], nil
+	case "provider":
+		return rootCommandProvider, args[1:], nil
 	case "service":
 		return rootCommandService, args[1:], nil
 	case "__apply-update":
@@ -118,6 +142,10 @@ func printRootUsage(w io.Writer) {
 	fmt.Fprintln(w, "")
 	fmt.Fprintln(w, "Commands:")
 	fmt.Fprintln(w, "  status            Show runtime and service status without starting the server")
+	fmt.Fprintln(w, "  usage             Report token usage and cost, or reset the billing period")
+	fmt.Fprintln(w, "  logs              Print or follow the log files, filtered by level, client or provider")
+	fmt.Fprintln(w, "  config import     Preview and apply a config bundle exported from the Web UI")
+	fmt.Fprintln(w, "  provider          Deactivate, reactivate or reset a provider in the running instance")
 	fmt.Fprintln(w, "  service           Install and manage the background service")
 	fmt.Fprintln(w, "  update            Check for updates or replace the current binary in place")
 	fmt.Fprintln(w, "  restart           Shortcut for 'clipal service restart'")
@@ -140,6 +168,10 @@ func printRootUsage(w io.Writer) {
 	fmt.Fprintln(w, "Examples:")
 	fmt.Fprintln(w, "  clipal")
 	fmt.Fprintln(w, "  clipal status")
+	fmt.Fprintln(w, "  clipal usage --since 2026-01-01 --format csv")
+	fmt.Fprintln(w, "  clipal logs -f --level warn --provider my-provider")
+	fmt.Fprintln(w, "  clipal config import clipal-config.json --strategy add_only")
+	fmt.Fprintln(w, "  clipal provider deactivate claude my-provider --for 2h --reason \"planned maintenance\"")
 	fmt.Fprintln(w, "  clipal restart")
 	fmt.Fprintln(w, "  clipal service install")
 	fmt.Fprintln(w, "  clipal update")
@@ -162,6 +162,30 @@ func TestResolveRootCommand(t *testing.T) {
 			wantCmd:  rootCommandService,
 			wantArgs: []string{"restart"},
 		},
+		{
+			name:     "UsageCommandPassesThrough",
+			args:     []string{"usage", "--format", "csv"},
+			wantCmd:  rootCommandUsage,
+			wantArgs: []string{"--format", "csv"},
+		},
+		{
+			name:     "LogsCommandPassesThrough",
+			args:     []string{"logs", "-f", "--level", "warn"},
+			wantCmd:  rootCommandLogs,
+			wantArgs: []string{"-f", "--level", "warn"},
+		},
+		{
+			name:     "ConfigCommandPassesThrough",
+			args:     []string{"config", "import", "bundle.json", "--dry-run"},
+			wantCmd:  rootCommandConfig,
+			wantArgs: []string{"import", "bundle.json", "--dry-run"},
+		},
+		{
+			name:     "ProviderCommandPassesThrough",
+			args:     []string{"provider", "reactivate", "openai", "p1", "--key", "2"},
+			wantCmd:  rootCommandProvider,
+			wantArgs: []string{"reactivate", "openai", "p1", "--key", "2"},
+		},
 		{
 			name:    "HelpTokenShowsRootHelp",
 			args:    []string{"help"},
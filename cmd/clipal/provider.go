@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/web"
+)
+
+// providerActions maps the CLI action names to the management API actions.
+var providerActions = map[string]string{
+	"deactivate":    "deactivate",
+	"reactivate":    "reactivate",
+	"reset-circuit": "reset_circuit",
+	"open-circuit":  "open_circuit",
+	"clear-busy":    "clear_busy",
+}
+
+func printProviderUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage:")
+	fmt.Fprintln(w, "  clipal provider <action> <client> <name> [--key N] [--for DURATION] [--reason TEXT] [--config-dir DIR]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Change the runtime state of a provider in the running instance.")
+	fmt.Fprintln(w, "The change lasts until it expires or clipal restarts; the config is not modified.")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Actions:")
+	fmt.Fprintln(w, "  deactivate     Take the provider, or one key with --key, out of rotation for --for")
+	fmt.Fprintln(w, "  reactivate     End a deactivation now; without --key also reactivates every key")
+	fmt.Fprintln(w, "  reset-circuit  Close the circuit breaker and forget recent failures")
+	fmt.Fprintln(w, "  open-circuit   Open the circuit breaker for --for")
+	fmt.Fprintln(w, "  clear-busy     Drop the busy backoff")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Examples:")
+	fmt.Fprintln(w, "  clipal provider deactivate claude my-provider --for 2h --reason \"planned maintenance\"")
+	fmt.Fprintln(w, "  clipal provider reactivate openai my-provider --key 2")
+}
+
+func runProvider(args []string) {
+	if len(args) == 0 || isHelpToken(args[0]) {
+		printProviderUsage(os.Stdout)
+		return
+	}
+	action, ok := providerActions[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "clipal provider: unknown action %q\n\n", args[0])
+		printProviderUsage(os.Stderr)
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("provider "+args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		printProviderUsage(os.Stderr)
+	}
+	configDir := fs.String("config-dir", "", "Configuration directory (default: ~/.clipal)")
+	key := fs.Int("key", 0, "1-based key index, for deactivate and reactivate")
+	duration := fs.Duration("for", 0, "How long deactivate or open-circuit lasts, such as 30m")
+	reason := fs.String("reason", "", "Reason shown in the provider status")
+	timeout := fs.Duration("timeout", 5*time.Second, "Timeout for requests to the running instance")
+
+	// Allow flags after the client and provider names.
+	var names []string
+	rest := args[1:]
+	for {
+		if err := fs.Parse(rest); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return
+			}
+			os.Exit(2)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		names = append(names, fs.Arg(0))
+		rest = fs.Args()[1:]
+	}
+	if len(names) != 2 {
+		fmt.Fprintf(os.Stderr, "clipal provider %s: expected a client type and a provider name\n", args[0])
+		os.Exit(2)
+	}
+	clientType, ok := config.CanonicalClientType(names[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "clipal provider %s: unknown client type %q\n", args[0], names[0])
+		os.Exit(2)
+	}
+
+	cfgDir := *configDir
+	if cfgDir == "" {
+		cfgDir = config.GetConfigDir()
+	}
+	cfg, err := config.Load(cfgDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "clipal provider %s failed: %v\n", args[0], err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	h := checkHealth(ctx, healthCandidateURLs(strings.TrimSpace(cfg.Global.ListenAddr), cfg.Global.Port))
+	if !h.OK {
+		fmt.Fprintf(os.Stderr, "clipal provider %s failed: clipal is not running; runtime state only exists in the running instance\n", args[0])
+		os.Exit(1)
+	}
+
+	req := web.ProviderRuntimeControlRequest{Action: action, Key: *key, Reason: strings.TrimSpace(*reason)}
+	if *duration > 0 {
+		req.Duration = duration.String()
+	}
+	resp, err := controlProvider(ctx, strings.TrimSuffix(h.URL, "/health"), clientType, names[1], req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "clipal provider %s failed: %v\n", args[0], err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stdout, "%s: %s\n", resp.Label, resp.Detail)
+}
+
+// controlProvider sends a runtime action to the running instance.
+func controlProvider(ctx context.Context, baseURL string, clientType string, name string, req web.ProviderRuntimeControlRequest) (web.ProviderRuntimeControlResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return web.ProviderRuntimeControlResponse{}, err
+	}
+	target := fmt.Sprintf("%s/api/providers/%s/%s/runtime", baseURL, url.PathEscape(clientType), url.PathEscape(name))
+	var resp web.ProviderRuntimeControlResponse
+	err = doAPIRequest(ctx, http.MethodPost, target, bytes.NewReader(body), &resp)
+	return resp, err
+}
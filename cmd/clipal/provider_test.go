@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lansespirit/Clipal/internal/web"
+)
+
+func TestControlProviderPostsRuntimeAction(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req web.ProviderRuntimeControlRequest
+		if r.Method != http.MethodPost || r.URL.EscapedPath() != "/api/providers/openai/my%20provider/runtime" ||
+			r.Header.Get("X-Clipal-UI") != "1" || r.Header.Get("Content-Type") != "application/json" ||
+			json.NewDecoder(r.Body).Decode(&req) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"bad request"}`))
+			return
+		}
+		if req.Action != "reactivate" || req.Key != 2 {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"provider is not active in the running proxy"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(web.ProviderRuntimeControlResponse{Provider: "my provider", Action: req.Action, Key: req.Key, State: "available", Label: "my provider", Detail: "Available. Key 2 reactivated manually: " + req.Reason + "."})
+	}))
+	defer srv.Close()
+
+	resp, err := controlProvider(context.Background(), srv.URL, "openai", "my provider", web.ProviderRuntimeControlRequest{Action: "reactivate", Key: 2, Reason: "rotated"})
+	if err != nil || resp.Detail != "Available. Key 2 reactivated manually: rotated." {
+		t.Fatalf("resp = %#v err = %v", resp, err)
+	}
+	_, err = controlProvider(context.Background(), srv.URL, "openai", "my provider", web.ProviderRuntimeControlRequest{Action: "clear_busy"})
+	if err == nil || !strings.Contains(err.Error(), "not active in the running proxy (HTTP 404)") {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/telemetry"
+)
+
+const defaultUsageGroupBy = "client_type,provider,model,day"
+
+type usageTable struct {
+	GroupBy string                  `json:"group_by"`
+	Groups  []telemetry.LedgerGroup `json:"groups"`
+}
+
+type usageReport struct {
+	Source string                `json:"source"`
+	Since  string                `json:"since,omitempty"`
+	Until  string                `json:"until,omitempty"`
+	Totals telemetry.LedgerGroup `json:"totals"`
+	Tables []usageTable          `json:"tables"`
+}
+
+// usageSource aggregates ledger entries by one group-by field.
+type usageSource func(groupBy string) ([]telemetry.LedgerGroup, error)
+
+func runUsage(args []string) {
+	fs := flag.NewFlagSet("usage", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	configDir := fs.String("config-dir", "", "Configuration directory (default: ~/.clipal)")
+	since := fs.String("since", "30d", "Start of the range: RFC 3339 time, date, or look-back such as 24h or 7d")
+	until := fs.String("until", "", "End of the range (default: now)")
+	by := fs.String("by", defaultUsageGroupBy, "Comma-separated tables to print: client_type, provider, model, capability, key, project, session, day, hour")
+	format := fs.String("format", "table", "Output format: table, csv or json")
+	offline := fs.Bool("offline", false, "Read the usage files directly even if clipal is running")
+	reset := fs.Bool("reset", false, "Archive the cumulative usage counters and start a new billing period; later reports start there")
+	timeout := fs.Duration("timeout", 5*time.Second, "Timeout for requests to the running instance")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		os.Exit(2)
+	}
+	if extra := fs.Args(); len(extra) > 0 {
+		fmt.Fprintf(os.Stderr, "clipal usage: unexpected argument %q\n", extra[0])
+		os.Exit(2)
+	}
+	*format = strings.ToLower(strings.TrimSpace(*format))
+	if *format != "table" && *format != "csv" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "clipal usage: unsupported format %q (table, csv or json)\n", *format)
+		os.Exit(2)
+	}
+
+	cfgDir := *configDir
+	if cfgDir == "" {
+		cfgDir = config.GetConfigDir()
+	}
+	cfg, err := config.Load(cfgDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "clipal usage failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	baseURL := ""
+	if !*offline {
+		if h := checkHealth(ctx, healthCandidateURLs(strings.TrimSpace(cfg.Global.ListenAddr), cfg.Global.Port)); h.OK {
+			baseURL = strings.TrimSuffix(h.URL, "/health")
+		}
+	}
+
+	if *reset {
+		var archive string
+		if baseURL != "" {
+			archive, err = resetRunningUsage(ctx, baseURL)
+		} else {
+			archive, err = resetOfflineUsage(cfgDir)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "clipal usage reset failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stdout, "Usage counters reset. Previous period archived to %s\n", archive)
+		return
+	}
+
+	report, err := loadUsageReport(ctx, cfgDir, baseURL, *since, *until, splitUsageGroupBy(*by), time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "clipal usage failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := writeUsageReport(os.Stdout, report, *format); err != nil {
+		fmt.Fprintf(os.Stderr, "clipal usage failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// loadUsageReport builds the report from the running instance when baseURL is
+// set, and from the ledger file otherwise. The range never starts before the
+// current usage period, so a report after --reset starts from zero.
+func loadUsageReport(ctx context.Context, configDir string, baseURL string, since string, until string, groupBy []string, now time.Time) (usageReport, error) {
+	periodStart, err := telemetry.ReadPeriodStart(configDir)
+	if err != nil {
+		return usageReport{}, fmt.Errorf("failed to read usage period: %w", err)
+	}
+	since, err = usagePeriodSince(since, periodStart, now)
+	if err != nil {
+		return usageReport{}, err
+	}
+
+	var source usageSource
+	sourceName := "offline"
+	if baseURL != "" {
+		source = runningUsageSource(ctx, baseURL, since, until)
+		sourceName = "running"
+	} else if source, err = offlineUsageSource(configDir, since, until, now); err != nil {
+		return usageReport{}, err
+	}
+	report, err := buildUsageReport(source, groupBy)
+	if err != nil {
+		return usageReport{}, err
+	}
+	report.Source = sourceName
+	report.Since = strings.TrimSpace(since)
+	report.Until = strings.TrimSpace(until)
+	return report, nil
+}
+
+// usagePeriodSince moves since forward to periodStart when it would start the
+// range before the last reset.
+func usagePeriodSince(since string, periodStart time.Time, now time.Time) (string, error) {
+	if periodStart.IsZero() {
+		return since, nil
+	}
+	start, err := telemetry.ParseLedgerTime(since, now)
+	if err != nil {
+		return "", fmt.Errorf("invalid --since: %v", err)
+	}
+	if start.IsZero() || start.Before(periodStart) {
+		return periodStart.Format(time.RFC3339Nano), nil
+	}
+	return since, nil
+}
+
+func splitUsageGroupBy(raw string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "client" {
+			field = "client_type"
+		}
+		if field != "" && !seen[field] {
+			seen[field] = true
+			out = append(out, field)
+		}
+	}
+	return out
+}
+
+func offlineUsageSource(configDir string, since string, until string, now time.Time) (usageSource, error) {
+	query := telemetry.LedgerQuery{}
+	var err error
+	if query.Since, err = telemetry.ParseLedgerTime(since, now); err != nil {
+		return nil, fmt.Errorf("invalid --since: %v", err)
+	}
+	if query.Until, err = telemetry.ParseLedgerTime(until, now); err != nil {
+		return nil, fmt.Errorf("invalid --until: %v", err)
+	}
+	ledger, err := telemetry.OpenLedger(configDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load usage ledger: %w", err)
+	}
+	return func(groupBy string) ([]telemetry.LedgerGroup, error) {
+		return ledger.Aggregate(query, groupBy)
+	}, nil
+}
+
+func runningUsageSource(ctx context.Context, baseURL string, since string, until string) usageSource {
+	since = strings.TrimSpace(since)
+	until = strings.TrimSpace(until)
+	return func(groupBy string) ([]telemetry.LedgerGroup, error) {
+		values := url.Values{"group_by": {groupBy}}
+		if since != "" {
+			values.Set("since", since)
+		}
+		if until != "" {
+			values.Set("until", until)
+		}
+		var resp struct {
+			Groups []telemetry.LedgerGroup `json:"groups"`
+		}
+		if err := doUsageAPIRequest(ctx, http.MethodGet, baseURL+"/api/usage/requests?"+values.Encode(), &resp); err != nil {
+			return nil, err
+		}
+		return resp.Groups, nil
+	}
+}
+
+func resetRunningUsage(ctx context.Context, baseURL string) (string, error) {
+	var resp struct {
+		Archive string `json:"archive"`
+	}
+	if err := doUsageAPIRequest(ctx, http.MethodPost, baseURL+"/api/usage/reset", &resp); err != nil {
+		return "", err
+	}
+	return resp.Archive, nil
+}
+
+func resetOfflineUsage(configDir string) (string, error) {
+	store, err := telemetry.NewStore(configDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to load usage telemetry: %w", err)
+	}
+	archive, err := store.Reset(time.Now())
+	if closeErr := store.Close(); err == nil {
+		err = closeErr
+	}
+	return archive, err
+}
+
+func doUsageAPIRequest(ctx context.Context, method string, target string, out any) error {
+	return doAPIRequest(ctx, method, target, nil, out)
+}
+
+// doAPIRequest calls the management API of the running instance and decodes
+// the JSON response into out.
+func doAPIRequest(ctx context.Context, method string, target string, body io.Reader, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	if method != http.MethodGet {
+		// The management API rejects state-changing calls without this header.
+		req.Header.Set("X-Clipal-UI", "1")
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s (HTTP %d)", apiErr.Error, resp.StatusCode)
+		}
+		return fmt.Errorf("unexpected HTTP status %d", resp.StatusCode)
+	}
+	return json.Unmarshal(data, out)
+}
+
+func buildUsageReport(source usageSource, groupBy []string) (usageReport, error) {
+	if len(groupBy) == 0 {
+		return usageReport{}, fmt.Errorf("--by must name at least one field")
+	}
+	report := usageReport{Totals: telemetry.LedgerGroup{Key: "total"}}
+	for i, field := range groupBy {
+		groups, err := source(field)
+		if err != nil {
+			return usageReport{}, err
+		}
+		if field == "day" || field == "hour" {
+			sort.SliceStable(groups, func(a, b int) bool { return groups[a].Key < groups[b].Key })
+		}
+		if i == 0 {
+			// Every table covers the same entries, so any one yields the totals.
+			for _, group := range groups {
+				report.Totals.Requests += group.Requests
+				report.Totals.Failures += group.Failures
+				report.Totals.InputTokens += group.InputTokens
+				report.Totals.OutputTokens += group.OutputTokens
+				report.Totals.TotalTokens += group.TotalTokens
+				report.Totals.ReasoningTokens += group.ReasoningTokens
+				report.Totals.CostMicros += group.CostMicros
+				report.Totals.HasCost = report.Totals.HasCost || group.HasCost
+			}
+		}
+		report.Tables = append(report.Tables, usageTable{GroupBy: field, Groups: groups})
+	}
+	return report, nil
+}
+
+func writeUsageReport(w io.Writer, report usageReport, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case "csv":
+		return writeUsageCSV(w, report)
+	default:
+		return writeUsageTables(w, report)
+	}
+}
+
+func writeUsageCSV(w io.Writer, report usageReport) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"group_by", "key", "requests", "failures", "input_tokens", "output_tokens", "reasoning_tokens", "total_tokens", "cost_usd"})
+	row := func(groupBy string, group telemetry.LedgerGroup) {
+		cost := ""
+		if group.HasCost {
+			cost = strconv.FormatFloat(float64(group.CostMicros)/1e6, 'f', 6, 64)
+		}
+		_ = cw.Write([]string{
+			groupBy,
+			group.Key,
+			strconv.FormatInt(group.Requests, 10),
+			strconv.FormatInt(group.Failures, 10),
+			strconv.FormatInt(group.InputTokens, 10),
+			strconv.FormatInt(group.OutputTokens, 10),
+			strconv.FormatInt(group.ReasoningTokens, 10),
+			strconv.FormatInt(group.TotalTokens, 10),
+			cost,
+		})
+	}
+	for _, table := range report.Tables {
+		for _, group := range table.Groups {
+			row(table.GroupBy, group)
+		}
+	}
+	row("total", report.Totals)
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeUsageTables(w io.Writer, report usageReport) error {
+	rangeLabel := "all retained requests"
+	if report.Since != "" || report.Until != "" {
+		rangeLabel = fmt.Sprintf("since %s until %s", orDash(report.Since), orValue(report.Until, "now"))
+	}
+	fmt.Fprintf(w, "Usage %s (%s data)\n", rangeLabel, report.Source)
+
+	for _, table := range report.Tables {
+		fmt.Fprintf(w, "\nBy %s:\n", strings.ReplaceAll(table.GroupBy, "_", " "))
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "  KEY\tREQUESTS\tFAILED\tINPUT\tOUTPUT\tTOTAL TOKENS\tCOST (USD)")
+		for _, group := range table.Groups {
+			writeUsageTableRow(tw, orValue(group.Key, "(none)"), group)
+		}
+		writeUsageTableRow(tw, "TOTAL", report.Totals)
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeUsageTableRow(w io.Writer, label string, group telemetry.LedgerGroup) {
+	cost := "-"
+	if group.HasCost {
+		cost = fmt.Sprintf("%.4f", float64(group.CostMicros)/1e6)
+	}
+	fmt.Fprintf(w, "  %s\t%d\t%d\t%d\t%d\t%d\t%s\n", label, group.Requests, group.Failures, group.InputTokens, group.OutputTokens, group.TotalTokens, cost)
+}
+
+func orValue(s string, fallback string) string {
+	if strings.TrimSpace(s) == "" {
+		return fallback
+	}
+	return s
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/telemetry"
+)
+
+func TestUsageReport_OfflineCSVAndTable(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+	lines := []string{
+		fmt.Sprintf(`{"time":%q,"client_type":"openai","provider":"p1","requested_model":"gpt-5","status":200,"input_tokens":10,"output_tokens":5,"total_tokens":15,"cost_micros":1500000,"has_cost":true}`, now.Add(-40*24*time.Hour).Format(time.RFC3339)),
+		fmt.Sprintf(`{"time":%q,"client_type":"openai","provider":"p1","requested_model":"gpt-5","status":200,"input_tokens":10,"output_tokens":5,"total_tokens":15,"cost_micros":250000,"has_cost":true}`, now.Add(-26*time.Hour).Format(time.RFC3339)),
+		fmt.Sprintf(`{"time":%q,"client_type":"claude","provider":"c1","requested_model":"sonnet","status":502}`, now.Add(-time.Hour).Format(time.RFC3339)),
+	}
+	if err := os.WriteFile(filepath.Join(dir, "usage-ledger.jsonl"), []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	source, err := offlineUsageSource(dir, "30d", "", now)
+	if err != nil {
+		t.Fatalf("offlineUsageSource: %v", err)
+	}
+	report, err := buildUsageReport(source, splitUsageGroupBy("provider, day,provider"))
+	if err != nil {
+		t.Fatalf("buildUsageReport: %v", err)
+	}
+	if len(report.Tables) != 2 || report.Tables[1].GroupBy != "day" || report.Tables[1].Groups[0].Key != "2026-05-09" {
+		t.Fatalf("tables = %#v", report.Tables)
+	}
+	if report.Totals.Requests != 2 || report.Totals.Failures != 1 || report.Totals.CostMicros != 250000 {
+		t.Fatalf("totals = %#v", report.Totals)
+	}
+
+	var csvOut bytes.Buffer
+	if err := writeUsageReport(&csvOut, report, "csv"); err != nil {
+		t.Fatalf("writeUsageReport csv: %v", err)
+	}
+	wantCSV := strings.Join([]string{
+		"group_by,key,requests,failures,input_tokens,output_tokens,reasoning_tokens,total_tokens,cost_usd",
+		"provider,p1,1,0,10,5,0,15,0.250000",
+		"provider,c1,1,1,0,0,0,0,",
+		"day,2026-05-09,1,0,10,5,0,15,0.250000",
+		"day,2026-05-10,1,1,0,0,0,0,",
+		"total,total,2,1,10,5,0,15,0.250000",
+	}, "\n") + "\n"
+	if csvOut.String() != wantCSV {
+		t.Fatalf("csv =\n%s\nwant\n%s", csvOut.String(), wantCSV)
+	}
+
+	var tableOut bytes.Buffer
+	if err := writeUsageReport(&tableOut, report, "table"); err != nil {
+		t.Fatalf("writeUsageReport table: %v", err)
+	}
+	for _, want := range []string{"By provider:", "By day:", "0.2500", "TOTAL"} {
+		if !strings.Contains(tableOut.String(), want) {
+			t.Fatalf("table output missing %q:\n%s", want, tableOut.String())
+		}
+	}
+
+	if _, err := buildUsageReport(source, []string{"bogus"}); err == nil {
+		t.Fatalf("expected unsupported group_by error")
+	}
+}
+
+func TestUsageRunningInstanceQueriesAndReset(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/usage/requests":
+			if r.URL.Query().Get("group_by") != "model" || r.URL.Query().Get("since") != "7d" {
+				http.Error(w, `{"error":"bad query"}`, http.StatusBadRequest)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"group_by": "model",
+				"groups":   []telemetry.LedgerGroup{{Key: "gpt-5", Requests: 3, CostMicros: 900, HasCost: true}},
+			})
+		case "/api/usage/reset":
+			if r.Method != http.MethodPost || r.Header.Get("X-Clipal-UI") != "1" {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`{"error":"forbidden: missing X-Clipal-UI header"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"archive":"/tmp/usage-archive/usage-1.json"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	report, err := buildUsageReport(runningUsageSource(t.Context(), srv.URL, " 7d ", ""), []string{"model"})
+	if err != nil {
+		t.Fatalf("buildUsageReport: %v", err)
+	}
+	if report.Totals.Requests != 3 || report.Tables[0].Groups[0].Key != "gpt-5" {
+		t.Fatalf("report = %#v", report)
+	}
+
+	if _, err := buildUsageReport(runningUsageSource(t.Context(), srv.URL, "1h", ""), []string{"model"}); err == nil || !strings.Contains(err.Error(), "HTTP 400") {
+		t.Fatalf("expected API error, got %v", err)
+	}
+
+	archive, err := resetRunningUsage(t.Context(), srv.URL)
+	if err != nil || archive != "/tmp/usage-archive/usage-1.json" {
+		t.Fatalf("resetRunningUsage = %q, %v", archive, err)
+	}
+}
+
+func TestResetOfflineUsageArchivesStore(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "usage.json"), []byte(`{"version":4,"clients":{"openai":{"providers":{"p1":{"request_count":2}}}}}`), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	archive, err := resetOfflineUsage(dir)
+	if err != nil {
+		t.Fatalf("resetOfflineUsage: %v", err)
+	}
+	data, err := os.ReadFile(archive)
+	if err != nil || !strings.Contains(string(data), `"request_count": 2`) {
+		t.Fatalf("archive %q = %s, %v", archive, data, err)
+	}
+	store, err := telemetry.NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	if _, ok := store.ProviderSnapshot("openai", "p1"); ok {
+		t.Fatalf("expected usage.json to be cleared")
+	}
+}
+
+func TestUsageReport_StartsFromZeroAfterReset(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	line := fmt.Sprintf(`{"time":%q,"client_type":"openai","provider":"p1","requested_model":"gpt-5","status":200,"total_tokens":15,"cost_micros":250000,"has_cost":true}`, time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))
+	if err := os.WriteFile(filepath.Join(dir, "usage-ledger.jsonl"), []byte(line+"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "usage.json"), []byte(`{"version":4,"clients":{"openai":{"providers":{"p1":{"request_count":1}}}}}`), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	report, err := loadUsageReport(t.Context(), dir, "", "30d", "", []string{"provider"}, time.Now())
+	if err != nil || report.Totals.Requests != 1 || report.Totals.CostMicros != 250000 {
+		t.Fatalf("report before reset = %#v, %v", report.Totals, err)
+	}
+
+	if _, err := resetOfflineUsage(dir); err != nil {
+		t.Fatalf("resetOfflineUsage: %v", err)
+	}
+	report, err = loadUsageReport(t.Context(), dir, "", "30d", "", []string{"provider"}, time.Now())
+	if err != nil {
+		t.Fatalf("loadUsageReport: %v", err)
+	}
+	if report.Totals.Requests != 0 || report.Totals.CostMicros != 0 || len(report.Tables[0].Groups) != 0 {
+		t.Fatalf("report after reset = %#v", report)
+	}
+	if report.Since == "30d" {
+		t.Fatalf("expected the range to start at the reset, got since=%q", report.Since)
+	}
+}
@@ -5,8 +5,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
-	"strings"
 	"sync"
 
 	"github.com/lansespirit/Clipal/internal/config"
@@ -108,17 +106,14 @@ func (a *Application) Shutdown(_ context.Context) error {
 }
 
 func configureFileLogging(cfgDir string, cfg *config.Config) error {
-	logDir := strings.TrimSpace(cfg.Global.LogDir)
-	if logDir == "" {
-		logDir = filepath.Join(cfgDir, "logs")
-	}
+	logDir := logger.Dir(cfgDir, cfg.Global.LogDir)
 
 	retention := cfg.Global.LogRetentionDays
 	if retention < 0 {
 		retention = 7
 	}
 
-	w, err := newRotatingFileWriterFunc(logDir, "clipal", retention)
+	w, err := newRotatingFileWriterFunc(logDir, logger.FilePrefix, retention)
 	if err != nil {
 		return err
 	}
@@ -3,6 +3,7 @@ package config
 import (
 	"bytes"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -66,9 +67,104 @@ type BusyBackpressureConfig struct {
 	MaxInlineWait      string   `yaml:"max_inline_wait"`
 }
 
+// FirstTokenTimeoutConfig bounds how long a streaming attempt may send only
+// keepalives before the first content event. Durations are measured from the
+// upstream response headers; "0" or an empty value disables the deadline.
+type FirstTokenTimeoutConfig struct {
+	Default string `yaml:"default,omitempty"`
+	// Capabilities overrides Default per request capability, e.g. claude_messages: 90s.
+	Capabilities map[string]string `yaml:"capabilities,omitempty"`
+}
+
+// firstTokenTimeoutCapabilities lists the generation capabilities whose SSE
+// streams can be inspected for the first content event.
+var firstTokenTimeoutCapabilities = map[string]struct{}{
+	"claude_compatible":              {},
+	"claude_messages":                {},
+	"openai_compatible":              {},
+	"openai_chat_completions":        {},
+	"openai_completions":             {},
+	"openai_responses":               {},
+	"gemini_compatible":              {},
+	"gemini_generate_content":        {},
+	"gemini_stream_generate_content": {},
+}
+
+// StreamRecoveryConfig enables resuming an interrupted Claude Messages or OpenAI
+// chat stream on another provider, using the output already relayed to the
+// client as an assistant prefill.
+type StreamRecoveryConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxAttempts limits continuation requests per client request. 0 means 1.
+	MaxAttempts int `yaml:"max_attempts,omitempty"`
+}
+
+// UsageLedgerConfig controls the per-request usage ledger kept next to usage.json.
+type UsageLedgerConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// RetentionDays drops entries older than this many days on compaction. 0 keeps them forever.
+	RetentionDays int `yaml:"retention_days"`
+	// MaxEntries caps the number of retained entries. 0 means unlimited.
+	MaxEntries int `yaml:"max_entries"`
+}
+
+// DefaultTracingEndpoint is the OTLP/HTTP traces URL of a local collector.
+const DefaultTracingEndpoint = "http://127.0.0.1:4318/v1/traces"
+
+// TracingConfig controls OpenTelemetry trace export over OTLP/HTTP.
+type TracingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Endpoint is the collector's OTLP/HTTP URL. Empty means
+	// DefaultTracingEndpoint; a URL without a path gets /v1/traces appended.
+	Endpoint string `yaml:"endpoint,omitempty"`
+	// Headers are sent with every export request, e.g. for collector auth.
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// ServiceName sets the service.name resource attribute. Empty means "clipal".
+	ServiceName string `yaml:"service_name,omitempty"`
+}
+
+// TracesEndpoint returns the URL that spans are posted to.
+func (t TracingConfig) TracesEndpoint() string {
+	endpoint := strings.TrimSpace(t.Endpoint)
+	if endpoint == "" {
+		return DefaultTracingEndpoint
+	}
+	if u, err := url.Parse(endpoint); err == nil && strings.Trim(u.Path, "/") == "" {
+		u.Path = "/v1/traces"
+		return u.String()
+	}
+	return endpoint
+}
+
+// CountTokensMode selects how count_tokens requests are answered.
+type CountTokensMode string
+
+const (
+	// CountTokensModeFallback forwards to the upstream and counts locally when
+	// the upstream is unavailable, lacks the endpoint or fails with a server
+	// error. It is the default.
+	CountTokensModeFallback CountTokensMode = "fallback"
+	// CountTokensModeLocal always counts locally without an upstream request.
+	CountTokensModeLocal CountTokensMode = "local"
+	// CountTokensModeUpstream only forwards to the upstream.
+	CountTokensModeUpstream CountTokensMode = "upstream"
+)
+
 type RoutingConfig struct {
-	StickySessions   StickySessionsConfig   `yaml:"sticky_sessions"`
-	BusyBackpressure BusyBackpressureConfig `yaml:"busy_backpressure"`
+	StickySessions    StickySessionsConfig    `yaml:"sticky_sessions"`
+	BusyBackpressure  BusyBackpressureConfig  `yaml:"busy_backpressure"`
+	FirstTokenTimeout FirstTokenTimeoutConfig `yaml:"first_token_timeout,omitempty"`
+	StreamRecovery    StreamRecoveryConfig    `yaml:"stream_recovery,omitempty"`
+	// CountTokens is empty or one of the CountTokensMode values. Empty means
+	// CountTokensModeFallback.
+	CountTokens CountTokensMode `yaml:"count_tokens,omitempty"`
+	// FailureRules apply to every provider after that provider's own rules.
+	FailureRules []FailureRule `yaml:"failure_rules,omitempty"`
+	// FallbackModels apply to every provider that has no chain of its own for the
+	// requested model.
+	FallbackModels FallbackModels `yaml:"fallback_models,omitempty"`
+	// ModelList controls how model list requests are answered, per client type.
+	ModelList ModelLists `yaml:"model_list,omitempty"`
 }
 
 // OpenTimeoutDuration parses the configured circuit breaker timeout.
@@ -102,6 +198,8 @@ type GlobalConfig struct {
 	Notifications         NotificationsConfig     `yaml:"notifications"`
 	CircuitBreaker        CircuitBreakerConfig    `yaml:"circuit_breaker"`
 	Routing               RoutingConfig           `yaml:"routing"`
+	UsageLedger           UsageLedgerConfig       `yaml:"usage_ledger"`
+	Tracing               TracingConfig           `yaml:"tracing,omitempty"`
 	// Deprecated: retained only so older config.yaml files still load under
 	// strict KnownFields decoding. Runtime no longer reads this field.
 	IgnoreCountTokensFailover bool `yaml:"ignore_count_tokens_failover"`
@@ -194,6 +292,8 @@ type providerYAML struct {
 	Model                string             `yaml:"model,omitempty"`
 	ReasoningEffort      string             `yaml:"reasoning_effort,omitempty"`
 	ThinkingBudgetTokens int                `yaml:"thinking_budget_tokens,omitempty"`
+	FailureRules         []FailureRule      `yaml:"failure_rules,omitempty"`
+	FallbackModels       FallbackModels     `yaml:"fallback_models,omitempty"`
 }
 
 // Provider represents an API provider configuration
@@ -211,6 +311,9 @@ type Provider struct {
 	Priority      int                `yaml:"priority"`
 	Enabled       *bool              `yaml:"enabled,omitempty"`
 	Overrides     *ProviderOverrides `yaml:"-"`
+	FailureRules  []FailureRule      `yaml:"-"`
+	// FallbackModels is checked before routing.fallback_models.
+	FallbackModels FallbackModels `yaml:"-"`
 }
 
 func (p *Provider) UnmarshalYAML(value *yaml.Node) error {
@@ -242,19 +345,21 @@ func (p *Provider) UnmarshalYAML(value *yaml.Node) error {
 		}
 	}
 	*p = Provider{
-		Name:          raw.Name,
-		BaseURL:       raw.BaseURL,
-		APIKey:        raw.APIKey,
-		APIKeys:       append([]string(nil), raw.APIKeys...),
-		AuthType:      raw.AuthType,
-		OAuthProvider: raw.OAuthProvider,
-		OAuthRef:      raw.OAuthRef,
-		OAuthIdentity: raw.OAuthIdentity,
-		ProxyMode:     raw.ProxyMode,
-		ProxyURL:      raw.ProxyURL,
-		Priority:      raw.Priority,
-		Enabled:       raw.Enabled,
-		Overrides:     NormalizeProviderOverrides(overrides),
+		Name:           raw.Name,
+		BaseURL:        raw.BaseURL,
+		APIKey:         raw.APIKey,
+		APIKeys:        append([]string(nil), raw.APIKeys...),
+		AuthType:       raw.AuthType,
+		OAuthProvider:  raw.OAuthProvider,
+		OAuthRef:       raw.OAuthRef,
+		OAuthIdentity:  raw.OAuthIdentity,
+		ProxyMode:      raw.ProxyMode,
+		ProxyURL:       raw.ProxyURL,
+		Priority:       raw.Priority,
+		Enabled:        raw.Enabled,
+		Overrides:      NormalizeProviderOverrides(overrides),
+		FailureRules:   append([]FailureRule(nil), raw.FailureRules...),
+		FallbackModels: cloneFallbackModels(raw.FallbackModels),
 	}
 	NormalizeProviderAuthSettings(p)
 	NormalizeProviderProxySettings(p)
@@ -281,19 +386,21 @@ func (p Provider) MarshalYAML() (any, error) {
 		proxyURL = ""
 	}
 	return providerYAML{
-		Name:          p.Name,
-		BaseURL:       p.BaseURL,
-		APIKey:        p.APIKey,
-		APIKeys:       append([]string(nil), p.APIKeys...),
-		AuthType:      authType,
-		OAuthProvider: oauthProvider,
-		OAuthRef:      oauthRef,
-		OAuthIdentity: oauthIdentity,
-		ProxyMode:     proxyMode,
-		ProxyURL:      proxyURL,
-		Priority:      p.Priority,
-		Enabled:       p.Enabled,
-		Overrides:     NormalizeProviderOverrides(p.Overrides),
+		Name:           p.Name,
+		BaseURL:        p.BaseURL,
+		APIKey:         p.APIKey,
+		APIKeys:        append([]string(nil), p.APIKeys...),
+		AuthType:       authType,
+		OAuthProvider:  oauthProvider,
+		OAuthRef:       oauthRef,
+		OAuthIdentity:  oauthIdentity,
+		ProxyMode:      proxyMode,
+		ProxyURL:       proxyURL,
+		Priority:       p.Priority,
+		Enabled:        p.Enabled,
+		Overrides:      NormalizeProviderOverrides(p.Overrides),
+		FailureRules:   append([]FailureRule(nil), p.FailureRules...),
+		FallbackModels: cloneFallbackModels(p.FallbackModels),
 	}, nil
 }
 
@@ -704,6 +811,7 @@ type Config struct {
 	Claude    ClientConfig
 	OpenAI    ClientConfig
 	Gemini    ClientConfig
+	Pricing   PricingConfig
 	configDir string
 }
 
@@ -778,6 +886,11 @@ func DefaultGlobalConfig() GlobalConfig {
 				MaxInlineWait:      "8s",
 			},
 		},
+		UsageLedger: UsageLedgerConfig{
+			Enabled:       true,
+			RetentionDays: 30,
+			MaxEntries:    50000,
+		},
 	}
 }
 
@@ -824,6 +937,12 @@ func Load(configDir string) (*Config, error) {
 		return nil, fmt.Errorf("failed to load gemini config: %w", err)
 	}
 
+	pricing, err := LoadPricing(configDir)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Pricing = pricing
+
 	applyClientDefaults(&cfg.Claude)
 	applyClientDefaults(&cfg.OpenAI)
 	applyClientDefaults(&cfg.Gemini)
@@ -858,8 +977,8 @@ func ClientConfigFilename(clientType string) (string, error) {
 }
 
 func WatchedConfigFilenames() []string {
-	names := []string{"config.yaml"}
-	seen := map[string]struct{}{"config.yaml": {}}
+	names := []string{"config.yaml", PricingFilename}
+	seen := map[string]struct{}{"config.yaml": {}, PricingFilename: {}}
 	for _, spec := range clientConfigFileSpecs {
 		if spec.currentName != "" {
 			if _, ok := seen[spec.currentName]; !ok {
@@ -1084,9 +1203,24 @@ func (c *Config) Validate() error {
 	if c.Global.LogRetentionDays < 0 {
 		return fmt.Errorf("invalid log_retention_days: %d", c.Global.LogRetentionDays)
 	}
+	if c.Global.UsageLedger.RetentionDays < 0 {
+		return fmt.Errorf("invalid usage_ledger.retention_days: %d", c.Global.UsageLedger.RetentionDays)
+	}
+	if c.Global.UsageLedger.MaxEntries < 0 {
+		return fmt.Errorf("invalid usage_ledger.max_entries: %d", c.Global.UsageLedger.MaxEntries)
+	}
+	if c.Global.Tracing.Enabled {
+		u, err := url.Parse(c.Global.Tracing.TracesEndpoint())
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid tracing.endpoint: %s", c.Global.Tracing.Endpoint)
+		}
+	}
 	if err := validateGlobalProxySettings("global upstream proxy", c.Global.NormalizedUpstreamProxyMode(), c.Global.NormalizedUpstreamProxyURL()); err != nil {
 		return err
 	}
+	if err := ValidatePricing(c.Pricing); err != nil {
+		return err
+	}
 
 	// Circuit breaker:
 	// - failure_threshold == 0 disables the circuit breaker entirely.
@@ -1248,6 +1382,15 @@ func validateProviders(clientName string, providers []Provider) error {
 		if p.Overrides != nil && p.Overrides.Claude != nil && p.Overrides.Claude.Effort != nil && p.ClaudeEffort() == "" {
 			return fmt.Errorf("%s provider %s: claude effort must be one of low, medium, high, max, xhigh", clientName, p.Name)
 		}
+		if err := ValidateFailureRules("failure_rules", p.FailureRules); err != nil {
+			return fmt.Errorf("%s provider %s: %w", clientName, p.Name, err)
+		}
+		if len(p.FallbackModels) > 0 && clientName == "gemini" {
+			return fmt.Errorf("%s provider %s: fallback_models are not supported for client", clientName, p.Name)
+		}
+		if err := ValidateFallbackModels("fallback_models", p.FallbackModels); err != nil {
+			return fmt.Errorf("%s provider %s: %w", clientName, p.Name, err)
+		}
 	}
 	return nil
 }
@@ -1309,6 +1452,42 @@ func validateRoutingConfig(rc RoutingConfig) error {
 		}
 	}
 
+	if err := validateOptionalNonNegativeDuration("routing.first_token_timeout.default", rc.FirstTokenTimeout.Default); err != nil {
+		return err
+	}
+	for capability, value := range rc.FirstTokenTimeout.Capabilities {
+		key := strings.ToLower(strings.TrimSpace(capability))
+		if _, ok := firstTokenTimeoutCapabilities[key]; !ok {
+			return fmt.Errorf("invalid routing.first_token_timeout.capabilities: unsupported capability %q", capability)
+		}
+		field := "routing.first_token_timeout.capabilities." + key
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("invalid %s: empty", field)
+		}
+		if err := validateOptionalNonNegativeDuration(field, value); err != nil {
+			return err
+		}
+	}
+
+	if rc.StreamRecovery.MaxAttempts < 0 {
+		return fmt.Errorf("invalid routing.stream_recovery.max_attempts: %d", rc.StreamRecovery.MaxAttempts)
+	}
+	switch rc.CountTokens {
+	case "", CountTokensModeFallback, CountTokensModeLocal, CountTokensModeUpstream:
+	default:
+		return fmt.Errorf("invalid routing.count_tokens: %s", rc.CountTokens)
+	}
+
+	if err := ValidateFailureRules("routing.failure_rules", rc.FailureRules); err != nil {
+		return err
+	}
+	if err := ValidateFallbackModels("routing.fallback_models", rc.FallbackModels); err != nil {
+		return err
+	}
+	if err := ValidateModelLists("routing.model_list", rc.ModelList); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -1326,3 +1505,14 @@ func validateOptionalPositiveDuration(field string, value string) error {
 	}
 	return validatePositiveDuration(field, value)
 }
+
+func validateOptionalNonNegativeDuration(field string, value string) error {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil || d < 0 {
+		return fmt.Errorf("invalid %s: %s", field, value)
+	}
+	return nil
+}
@@ -232,6 +232,33 @@ func TestValidate_CircuitBreakerFailureThresholdNegative_IsRejected(t *testing.T
 	}
 }
 
+func TestValidate_TracingEndpoint(t *testing.T) {
+	t.Parallel()
+
+	cfg := &Config{
+		Global: DefaultGlobalConfig(),
+		Claude: ClientConfig{Mode: ClientModeAuto},
+		OpenAI: ClientConfig{Mode: ClientModeAuto},
+		Gemini: ClientConfig{Mode: ClientModeAuto},
+	}
+
+	cfg.Global.Tracing.Endpoint = "not a url"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("disabled tracing should not validate the endpoint: %v", err)
+	}
+	cfg.Global.Tracing.Enabled = true
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected validation error for invalid tracing.endpoint")
+	}
+	cfg.Global.Tracing.Endpoint = "http://otel-collector:4318"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if got := cfg.Global.Tracing.TracesEndpoint(); got != "http://otel-collector:4318/v1/traces" {
+		t.Fatalf("TracesEndpoint() = %q", got)
+	}
+}
+
 func TestValidate_ManualMode_RequiresEnabledPinnedProvider(t *testing.T) {
 	t.Parallel()
 
@@ -866,6 +893,41 @@ func TestValidate_RoutingConfigRejectsInvalidValues(t *testing.T) {
 			},
 			wantErr: "routing.busy_backpressure.max_inline_wait",
 		},
+		{
+			name: "negative first token timeout",
+			mutate: func(cfg *Config) {
+				cfg.Global.Routing.FirstTokenTimeout.Default = "-1s"
+			},
+			wantErr: "routing.first_token_timeout.default",
+		},
+		{
+			name: "unknown first token timeout capability",
+			mutate: func(cfg *Config) {
+				cfg.Global.Routing.FirstTokenTimeout.Capabilities = map[string]string{"openai_embeddings": "30s"}
+			},
+			wantErr: "routing.first_token_timeout.capabilities",
+		},
+		{
+			name: "bad first token timeout override",
+			mutate: func(cfg *Config) {
+				cfg.Global.Routing.FirstTokenTimeout.Capabilities = map[string]string{"claude_messages": "soon"}
+			},
+			wantErr: "routing.first_token_timeout.capabilities.claude_messages",
+		},
+		{
+			name: "negative stream recovery attempts",
+			mutate: func(cfg *Config) {
+				cfg.Global.Routing.StreamRecovery.MaxAttempts = -1
+			},
+			wantErr: "routing.stream_recovery.max_attempts",
+		},
+		{
+			name: "unknown count tokens mode",
+			mutate: func(cfg *Config) {
+				cfg.Global.Routing.CountTokens = "remote"
+			},
+			wantErr: "routing.count_tokens",
+		},
 	}
 
 	for _, tt := range tests {
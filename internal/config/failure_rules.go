@@ -0,0 +1,182 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FailureRuleAction selects how the proxy handles an upstream response matched by a FailureRule.
+type FailureRuleAction string
+
+const (
+	FailureRuleActionReturnToClient     FailureRuleAction = "return_to_client"
+	FailureRuleActionRetryNext          FailureRuleAction = "retry_next"
+	FailureRuleActionBusyRetry          FailureRuleAction = "busy_retry"
+	FailureRuleActionDeactivateProvider FailureRuleAction = "deactivate_provider"
+	FailureRuleActionDeactivateKey      FailureRuleAction = "deactivate_key"
+)
+
+// FailureRuleReason is the reason recorded when a rule does not set one explicitly
+// and the action has no more specific default.
+const FailureRuleReason = "rule"
+
+// FailureRule classifies an upstream response before the built-in failure
+// classification runs. Every configured matcher must match; rules are evaluated
+// in order and the first match wins.
+type FailureRule struct {
+	Name string `yaml:"name,omitempty"`
+	// Status lists the HTTP status codes this rule applies to. When empty the rule
+	// applies to every non-2xx status; 2xx responses are only inspected when listed.
+	Status []int `yaml:"status,omitempty"`
+	// Headers maps a response header name to a regular expression its value must match.
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// BodyRegex is matched against the start of the response body.
+	BodyRegex string `yaml:"body_regex,omitempty"`
+	// JSONPath selects a value from the JSON (or SSE data) body, e.g. "error.code"
+	// or "error.details[0].reason". Without JSONValue it only has to be present.
+	JSONPath  string            `yaml:"json_path,omitempty"`
+	JSONValue string            `yaml:"json_value,omitempty"`
+	Action    FailureRuleAction `yaml:"action"`
+	Reason    string            `yaml:"reason,omitempty"`
+	// Cooldown overrides how long the provider or key stays unavailable. It is not
+	// allowed for return_to_client and busy_retry.
+	Cooldown string `yaml:"cooldown,omitempty"`
+}
+
+// NormalizedAction returns the trimmed, lower-cased rule action.
+func (r FailureRule) NormalizedAction() FailureRuleAction {
+	return FailureRuleAction(strings.ToLower(strings.TrimSpace(string(r.Action))))
+}
+
+// NormalizedReason returns the configured reason, or the default for the rule action.
+func (r FailureRule) NormalizedReason() string {
+	if reason := strings.ToLower(strings.TrimSpace(r.Reason)); reason != "" {
+		return reason
+	}
+	switch r.NormalizedAction() {
+	case FailureRuleActionReturnToClient:
+		return ""
+	case FailureRuleActionRetryNext:
+		return "server"
+	case FailureRuleActionBusyRetry:
+		return "busy"
+	default:
+		return FailureRuleReason
+	}
+}
+
+// CooldownDuration parses the configured cooldown; an empty value returns 0.
+func (r FailureRule) CooldownDuration() (time.Duration, error) {
+	raw := strings.TrimSpace(r.Cooldown)
+	if raw == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid cooldown: %s", r.Cooldown)
+	}
+	return d, nil
+}
+
+// Label returns the rule name, falling back to its 1-based position.
+func (r FailureRule) Label(index int) string {
+	if name := strings.TrimSpace(r.Name); name != "" {
+		return name
+	}
+	return fmt.Sprintf("#%d", index+1)
+}
+
+// ValidateFailureRules checks an ordered rule list; scope prefixes error messages.
+func ValidateFailureRules(scope string, rules []FailureRule) error {
+	for i, rule := range rules {
+		field := fmt.Sprintf("%s[%d]", scope, i)
+		if err := validateFailureRule(rule); err != nil {
+			return fmt.Errorf("invalid %s: %w", field, err)
+		}
+	}
+	return nil
+}
+
+func validateFailureRule(rule FailureRule) error {
+	if len(rule.Status) == 0 && len(rule.Headers) == 0 && strings.TrimSpace(rule.BodyRegex) == "" && strings.TrimSpace(rule.JSONPath) == "" {
+		return fmt.Errorf("at least one of status, headers, body_regex or json_path is required")
+	}
+	for _, status := range rule.Status {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("status must be between 100 and 599: %d", status)
+		}
+	}
+	for name, pattern := range rule.Headers {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("header name cannot be empty")
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("headers.%s: %v", http.CanonicalHeaderKey(strings.TrimSpace(name)), err)
+		}
+	}
+	if pattern := strings.TrimSpace(rule.BodyRegex); pattern != "" {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("body_regex: %v", err)
+		}
+	}
+	if strings.TrimSpace(rule.JSONValue) != "" && strings.TrimSpace(rule.JSONPath) == "" {
+		return fmt.Errorf("json_value requires json_path")
+	}
+	if path := strings.TrimSpace(rule.JSONPath); path != "" {
+		if _, err := ParseFailureRuleJSONPath(path); err != nil {
+			return fmt.Errorf("json_path: %v", err)
+		}
+	}
+	if pattern := strings.TrimSpace(rule.JSONValue); pattern != "" {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("json_value: %v", err)
+		}
+	}
+
+	action := rule.NormalizedAction()
+	switch action {
+	case FailureRuleActionReturnToClient, FailureRuleActionBusyRetry:
+		if strings.TrimSpace(rule.Cooldown) != "" {
+			return fmt.Errorf("cooldown is not supported for action %s", action)
+		}
+	case FailureRuleActionRetryNext, FailureRuleActionDeactivateProvider, FailureRuleActionDeactivateKey:
+		if _, err := rule.CooldownDuration(); err != nil {
+			return err
+		}
+	case "":
+		return fmt.Errorf("action is required")
+	default:
+		return fmt.Errorf("unsupported action %q", rule.Action)
+	}
+
+	switch reason := rule.NormalizedReason(); reason {
+	case "", FailureRuleReason, "auth", "billing", "quota", "rate_limit", "overloaded", "busy", "server":
+		if action == FailureRuleActionReturnToClient && reason != "" {
+			return fmt.Errorf("reason is not supported for action %s", action)
+		}
+	default:
+		return fmt.Errorf("unsupported reason %q", rule.Reason)
+	}
+	return nil
+}
+
+// ParseFailureRuleJSONPath splits a dotted JSON path into object keys and array
+// indices. Both "a.b[0].c" and "a.b.0.c" are accepted.
+func ParseFailureRuleJSONPath(path string) ([]string, error) {
+	path = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(path), "$."))
+	if path == "" {
+		return nil, fmt.Errorf("path cannot be empty")
+	}
+	path = strings.ReplaceAll(path, "[", ".")
+	path = strings.ReplaceAll(path, "]", "")
+	segments := strings.Split(path, ".")
+	for _, segment := range segments {
+		if strings.TrimSpace(segment) == "" {
+			return nil, fmt.Errorf("empty segment in %q", path)
+		}
+	}
+	return segments, nil
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad_FailureRulesGlobalAndPerProvider(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(strings.TrimSpace(`
+routing:
+  failure_rules:
+    - name: region-block
+      status: [403]
+      body_regex: "(?i)unsupported region"
+      action: deactivate_provider
+      cooldown: 6h
+`)+"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	writeClientConfigFile(t, dir, "openai.yaml", `
+providers:
+  - name: p1
+    base_url: https://example.com
+    api_key: sk-1
+    priority: 1
+    failure_rules:
+      - json_path: error.code
+        json_value: ^insufficient_balance$
+        action: deactivate_key
+        reason: billing
+`)
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	global := cfg.Global.Routing.FailureRules
+	if len(global) != 1 || global[0].Label(0) != "region-block" || global[0].NormalizedAction() != FailureRuleActionDeactivateProvider {
+		t.Fatalf("global rules = %+v", global)
+	}
+	if d, err := global[0].CooldownDuration(); err != nil || d != 6*time.Hour {
+		t.Fatalf("CooldownDuration = %s, %v", d, err)
+	}
+	if got := global[0].NormalizedReason(); got != FailureRuleReason {
+		t.Fatalf("default reason = %q, want %q", got, FailureRuleReason)
+	}
+
+	providerRules := cfg.OpenAI.Providers[0].FailureRules
+	if len(providerRules) != 1 || providerRules[0].Label(0) != "#1" || providerRules[0].NormalizedReason() != "billing" {
+		t.Fatalf("provider rules = %+v", providerRules)
+	}
+}
+
+func TestValidate_FailureRulesRejectInvalidValues(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		rule    FailureRule
+		wantErr string
+	}{
+		{name: "no matcher", rule: FailureRule{Action: FailureRuleActionRetryNext}, wantErr: "at least one of"},
+		{name: "missing action", rule: FailureRule{Status: []int{400}}, wantErr: "action is required"},
+		{name: "unknown action", rule: FailureRule{Status: []int{400}, Action: "explode"}, wantErr: "unsupported action"},
+		{name: "bad status", rule: FailureRule{Status: []int{42}, Action: FailureRuleActionRetryNext}, wantErr: "status must be between"},
+		{name: "bad body regex", rule: FailureRule{BodyRegex: "(", Action: FailureRuleActionRetryNext}, wantErr: "body_regex"},
+		{name: "json value without path", rule: FailureRule{Status: []int{400}, JSONValue: "x", Action: FailureRuleActionRetryNext}, wantErr: "json_value requires json_path"},
+		{name: "bad json path", rule: FailureRule{JSONPath: "error..code", Action: FailureRuleActionRetryNext}, wantErr: "json_path"},
+		{name: "bad cooldown", rule: FailureRule{Status: []int{400}, Action: FailureRuleActionDeactivateKey, Cooldown: "soon"}, wantErr: "invalid cooldown"},
+		{name: "cooldown on busy retry", rule: FailureRule{Status: []int{429}, Action: FailureRuleActionBusyRetry, Cooldown: "1s"}, wantErr: "cooldown is not supported"},
+		{name: "reason on return", rule: FailureRule{Status: []int{429}, Action: FailureRuleActionReturnToClient, Reason: "auth"}, wantErr: "reason is not supported"},
+		{name: "unknown reason", rule: FailureRule{Status: []int{400}, Action: FailureRuleActionRetryNext, Reason: "bored"}, wantErr: "unsupported reason"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &Config{
+				Global: DefaultGlobalConfig(),
+				Claude: ClientConfig{Mode: ClientModeAuto},
+				OpenAI: ClientConfig{Mode: ClientModeAuto},
+				Gemini: ClientConfig{Mode: ClientModeAuto},
+			}
+			cfg.Global.Routing.FailureRules = []FailureRule{tt.rule}
+
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), "routing.failure_rules[0]") || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want substring %q", err, tt.wantErr)
+			}
+		})
+	}
+}
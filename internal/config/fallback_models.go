@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FallbackModels maps a requested model to the models tried, in order, on the
+// same provider when the requested model is unavailable there.
+type FallbackModels map[string][]string
+
+// Chain returns the fallback models configured for model, or nil.
+func (m FallbackModels) Chain(model string) []string {
+	model = strings.TrimSpace(model)
+	if model == "" || len(m) == 0 {
+		return nil
+	}
+	if chain, ok := m[model]; ok {
+		return chain
+	}
+	for key, chain := range m {
+		if strings.TrimSpace(key) == model {
+			return chain
+		}
+	}
+	return nil
+}
+
+func cloneFallbackModels(models FallbackModels) FallbackModels {
+	if len(models) == 0 {
+		return nil
+	}
+	out := make(FallbackModels, len(models))
+	for model, chain := range models {
+		out[model] = append([]string(nil), chain...)
+	}
+	return out
+}
+
+// ValidateFallbackModels checks a fallback chain map; scope prefixes error messages.
+func ValidateFallbackModels(scope string, models FallbackModels) error {
+	keys := make([]string, 0, len(models))
+	for key := range models {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		model := strings.TrimSpace(key)
+		if model == "" {
+			return fmt.Errorf("invalid %s: model name cannot be empty", scope)
+		}
+		chain := models[key]
+		if len(chain) == 0 {
+			return fmt.Errorf("invalid %s.%s: at least one fallback model is required", scope, model)
+		}
+		seen := map[string]struct{}{model: {}}
+		for _, fallback := range chain {
+			fallback = strings.TrimSpace(fallback)
+			if fallback == "" {
+				return fmt.Errorf("invalid %s.%s: fallback model cannot be empty", scope, model)
+			}
+			if _, ok := seen[fallback]; ok {
+				return fmt.Errorf("invalid %s.%s: duplicate model %q", scope, model, fallback)
+			}
+			seen[fallback] = struct{}{}
+		}
+	}
+	return nil
+}
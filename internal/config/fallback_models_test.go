@@ -0,0 +1,84 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad_FallbackModelsGlobalAndPerProvider(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(strings.TrimSpace(`
+routing:
+  fallback_models:
+    gpt-5: [gpt-5-mini]
+`)+"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	writeClientConfigFile(t, dir, "claude.yaml", `
+providers:
+  - name: p1
+    base_url: https://example.com
+    api_key: sk-1
+    priority: 1
+    fallback_models:
+      claude-opus-4-1: [claude-sonnet-4-5, claude-haiku-4-5]
+`)
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	if got := cfg.Global.Routing.FallbackModels.Chain("gpt-5"); !reflect.DeepEqual(got, []string{"gpt-5-mini"}) {
+		t.Fatalf("global chain = %v", got)
+	}
+	provider := cfg.Claude.Providers[0]
+	if got := provider.FallbackModels.Chain("claude-opus-4-1"); !reflect.DeepEqual(got, []string{"claude-sonnet-4-5", "claude-haiku-4-5"}) {
+		t.Fatalf("provider chain = %v", got)
+	}
+	if got := provider.FallbackModels.Chain("claude-haiku-4-5"); got != nil {
+		t.Fatalf("unconfigured chain = %v, want nil", got)
+	}
+}
+
+func TestValidate_FallbackModelsRejectInvalidValues(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		models  FallbackModels
+		wantErr string
+	}{
+		{name: "empty key", models: FallbackModels{" ": {"b"}}, wantErr: "model name cannot be empty"},
+		{name: "empty chain", models: FallbackModels{"a": nil}, wantErr: "at least one fallback model"},
+		{name: "empty entry", models: FallbackModels{"a": {""}}, wantErr: "fallback model cannot be empty"},
+		{name: "self reference", models: FallbackModels{"a": {"b", "a"}}, wantErr: `duplicate model "a"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &Config{
+				Global: DefaultGlobalConfig(),
+				Claude: ClientConfig{Mode: ClientModeAuto},
+				OpenAI: ClientConfig{Mode: ClientModeAuto},
+				Gemini: ClientConfig{Mode: ClientModeAuto},
+			}
+			cfg.Global.Routing.FallbackModels = tt.models
+
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), "routing.fallback_models") || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want substring %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ModelListSource selects how model list requests are answered.
+type ModelListSource string
+
+const (
+	// ModelListSourceMerged answers with the deduplicated union of every
+	// enabled provider's model list.
+	ModelListSourceMerged ModelListSource = "merged"
+	// ModelListSourceCurrent forwards to the current provider, like any other
+	// request. It is the default.
+	ModelListSourceCurrent ModelListSource = "current"
+	// ModelListSourceStatic answers with the configured models only.
+	ModelListSourceStatic ModelListSource = "static"
+)
+
+// ModelListConfig controls the model list Clipal answers for one client type.
+type ModelListConfig struct {
+	// Source is empty or one of the ModelListSource values. Empty means
+	// ModelListSourceCurrent.
+	Source ModelListSource `yaml:"source,omitempty"`
+	// Models is the list answered by the static source.
+	Models []string `yaml:"models,omitempty"`
+	// Aliases are added to merged and static lists, such as model names that a
+	// model override or fallback chain maps onto a real model.
+	Aliases []string `yaml:"aliases,omitempty"`
+}
+
+// ModelLists maps a client type (claude, openai, gemini) to its model list settings.
+type ModelLists map[string]ModelListConfig
+
+// For returns the settings of clientType; unconfigured client types get the
+// zero value, which forwards to the current provider.
+func (m ModelLists) For(clientType string) ModelListConfig {
+	return m[clientType]
+}
+
+// NormalizedSource returns Source, defaulting to ModelListSourceCurrent.
+func (c ModelListConfig) NormalizedSource() ModelListSource {
+	if c.Source == "" {
+		return ModelListSourceCurrent
+	}
+	return c.Source
+}
+
+// ValidateModelLists checks model list settings; scope prefixes error messages.
+func ValidateModelLists(scope string, lists ModelLists) error {
+	clients := make([]string, 0, len(lists))
+	for client := range lists {
+		clients = append(clients, client)
+	}
+	sort.Strings(clients)
+	for _, client := range clients {
+		switch client {
+		case "claude", "openai", "gemini":
+		default:
+			return fmt.Errorf("invalid %s: unknown client type %q", scope, client)
+		}
+		field := scope + "." + client
+		list := lists[client]
+		switch list.Source {
+		case "", ModelListSourceMerged, ModelListSourceCurrent, ModelListSourceStatic:
+		default:
+			return fmt.Errorf("invalid %s.source: %s", field, list.Source)
+		}
+		if list.Source == ModelListSourceStatic && len(list.Models) == 0 {
+			return fmt.Errorf("invalid %s.models: at least one model is required for the static source", field)
+		}
+		for _, group := range []struct {
+			name   string
+			models []string
+		}{{"models", list.Models}, {"aliases", list.Aliases}} {
+			for _, model := range group.models {
+				if strings.TrimSpace(model) == "" {
+					return fmt.Errorf("invalid %s.%s: model name cannot be empty", field, group.name)
+				}
+			}
+		}
+	}
+	return nil
+}
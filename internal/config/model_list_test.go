@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad_ModelListPerClientType(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(strings.TrimSpace(`
+routing:
+  model_list:
+    openai:
+      source: merged
+      aliases: [fast]
+    gemini:
+      source: static
+      models: [gemini-2.5-pro]
+`)+"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	lists := cfg.Global.Routing.ModelList
+	if got := lists.For("openai"); got.NormalizedSource() != ModelListSourceMerged || !reflect.DeepEqual(got.Aliases, []string{"fast"}) {
+		t.Fatalf("openai = %#v", got)
+	}
+	if got := lists.For("gemini"); got.NormalizedSource() != ModelListSourceStatic || !reflect.DeepEqual(got.Models, []string{"gemini-2.5-pro"}) {
+		t.Fatalf("gemini = %#v", got)
+	}
+	if got := lists.For("claude").NormalizedSource(); got != ModelListSourceCurrent {
+		t.Fatalf("claude source = %q", got)
+	}
+}
+
+func TestValidate_ModelListRejectInvalidValues(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		lists   ModelLists
+		wantErr string
+	}{
+		{name: "unknown client", lists: ModelLists{"codex": {}}, wantErr: `unknown client type "codex"`},
+		{name: "unknown source", lists: ModelLists{"openai": {Source: "random"}}, wantErr: "openai.source"},
+		{name: "static without models", lists: ModelLists{"claude": {Source: ModelListSourceStatic}}, wantErr: "at least one model"},
+		{name: "empty alias", lists: ModelLists{"gemini": {Aliases: []string{" "}}}, wantErr: "gemini.aliases: model name cannot be empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &Config{
+				Global: DefaultGlobalConfig(),
+				Claude: ClientConfig{Mode: ClientModeAuto},
+				OpenAI: ClientConfig{Mode: ClientModeAuto},
+				Gemini: ClientConfig{Mode: ClientModeAuto},
+			}
+			cfg.Global.Routing.ModelList = tt.lists
+
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), "routing.model_list") || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want substring %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PricingFilename is the optional pricing catalog in the config directory.
+const PricingFilename = "pricing.yaml"
+
+// PricingConfig overrides and extends the built-in model price tables.
+type PricingConfig struct {
+	// Models are matched in order against the effective model; the first match
+	// wins over the built-in tables.
+	Models []ModelPricing `yaml:"models,omitempty"`
+	// ProviderMultipliers scale inferred costs per provider name, e.g. 1.05
+	// for a reseller's markup.
+	ProviderMultipliers map[string]float64 `yaml:"provider_multipliers,omitempty"`
+	// ExchangeRates convert model prices to USD: the USD value of one unit of
+	// each currency.
+	ExchangeRates map[string]float64 `yaml:"exchange_rates,omitempty"`
+}
+
+// ModelPricing holds the per-million-token prices of one model or model family.
+type ModelPricing struct {
+	// Model is an exact model name or a prefix ending in "*".
+	Model string `yaml:"model"`
+	// Currency of the prices. Empty means USD.
+	Currency   string `yaml:"currency,omitempty"`
+	PriceRates `yaml:",inline"`
+	Tiers      []PriceTier `yaml:"tiers,omitempty"`
+}
+
+// PriceRates are prices per million tokens. Unset cache_read and cache_write
+// prices fall back to input; an unset reasoning price falls back to output.
+type PriceRates struct {
+	Input      *float64 `yaml:"input,omitempty"`
+	Output     *float64 `yaml:"output,omitempty"`
+	CacheRead  *float64 `yaml:"cache_read,omitempty"`
+	CacheWrite *float64 `yaml:"cache_write,omitempty"`
+	Reasoning  *float64 `yaml:"reasoning,omitempty"`
+}
+
+// PriceTier replaces the prices it sets once the prompt exceeds
+// AboveInputTokens tokens.
+type PriceTier struct {
+	AboveInputTokens int64 `yaml:"above_input_tokens"`
+	PriceRates       `yaml:",inline"`
+}
+
+// Matches reports whether the pattern selects model. Both are compared
+// case-insensitively.
+func (m ModelPricing) Matches(model string) bool {
+	pattern := strings.ToLower(strings.TrimSpace(m.Model))
+	model = strings.ToLower(strings.TrimSpace(model))
+	if pattern == "" || model == "" {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(model, prefix)
+	}
+	return model == pattern
+}
+
+// Match returns the first catalog entry for model.
+func (p PricingConfig) Match(model string) (ModelPricing, bool) {
+	for _, entry := range p.Models {
+		if entry.Matches(model) {
+			return entry, true
+		}
+	}
+	return ModelPricing{}, false
+}
+
+// USDRate returns the USD value of one unit of currency.
+func (p PricingConfig) USDRate(currency string) (float64, bool) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" || currency == "USD" {
+		return 1, true
+	}
+	for code, rate := range p.ExchangeRates {
+		if strings.EqualFold(strings.TrimSpace(code), currency) {
+			return rate, true
+		}
+	}
+	return 0, false
+}
+
+// ProviderMultiplier returns the cost multiplier for a provider, 1 when unset.
+func (p PricingConfig) ProviderMultiplier(provider string) float64 {
+	if m, ok := p.ProviderMultipliers[strings.TrimSpace(provider)]; ok {
+		return m
+	}
+	return 1
+}
+
+// IsEmpty reports whether the catalog adds nothing to the built-in tables.
+func (p PricingConfig) IsEmpty() bool {
+	return len(p.Models) == 0 && len(p.ProviderMultipliers) == 0 && len(p.ExchangeRates) == 0
+}
+
+// LoadPricing reads the pricing catalog from configDir. A missing file yields
+// an empty catalog.
+func LoadPricing(configDir string) (PricingConfig, error) {
+	var pricing PricingConfig
+	if err := loadYAML(filepath.Join(configDir, PricingFilename), &pricing); err != nil && !os.IsNotExist(err) && !errors.Is(err, io.EOF) {
+		return PricingConfig{}, fmt.Errorf("failed to load pricing catalog: %w", err)
+	}
+	return pricing, nil
+}
+
+// ValidatePricing checks prices, tiers, multipliers and currencies.
+func ValidatePricing(p PricingConfig) error {
+	codes := make([]string, 0, len(p.ExchangeRates))
+	for code := range p.ExchangeRates {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if strings.TrimSpace(code) == "" {
+			return fmt.Errorf("invalid pricing.exchange_rates: currency cannot be empty")
+		}
+		if rate := p.ExchangeRates[code]; !validPrice(rate) || rate == 0 {
+			return fmt.Errorf("invalid pricing.exchange_rates.%s: must be greater than 0", code)
+		}
+	}
+
+	providers := make([]string, 0, len(p.ProviderMultipliers))
+	for provider := range p.ProviderMultipliers {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	for _, provider := range providers {
+		if strings.TrimSpace(provider) == "" {
+			return fmt.Errorf("invalid pricing.provider_multipliers: provider name cannot be empty")
+		}
+		if m := p.ProviderMultipliers[provider]; !validPrice(m) {
+			return fmt.Errorf("invalid pricing.provider_multipliers.%s: must be >= 0", provider)
+		}
+	}
+
+	seen := make(map[string]struct{}, len(p.Models))
+	for i, entry := range p.Models {
+		scope := fmt.Sprintf("pricing.models[%d]", i)
+		pattern := strings.ToLower(strings.TrimSpace(entry.Model))
+		if pattern == "" || pattern == "*" {
+			return fmt.Errorf("invalid %s.model: must name a model or a prefix ending in *", scope)
+		}
+		if strings.Contains(strings.TrimSuffix(pattern, "*"), "*") {
+			return fmt.Errorf("invalid %s.model: * is only allowed at the end", scope)
+		}
+		if _, ok := seen[pattern]; ok {
+			return fmt.Errorf("invalid %s.model: duplicate model %q", scope, entry.Model)
+		}
+		seen[pattern] = struct{}{}
+		if _, ok := p.USDRate(entry.Currency); !ok {
+			return fmt.Errorf("invalid %s.currency: no exchange_rates entry for %s", scope, entry.Currency)
+		}
+		if entry.Input == nil || entry.Output == nil {
+			return fmt.Errorf("invalid %s: input and output prices are required", scope)
+		}
+		if err := validatePriceRates(scope, entry.PriceRates); err != nil {
+			return err
+		}
+		var previous int64
+		for j, tier := range entry.Tiers {
+			tierScope := fmt.Sprintf("%s.tiers[%d]", scope, j)
+			if tier.AboveInputTokens <= previous {
+				return fmt.Errorf("invalid %s.above_input_tokens: must be greater than %d", tierScope, previous)
+			}
+			previous = tier.AboveInputTokens
+			if err := validatePriceRates(tierScope, tier.PriceRates); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validatePriceRates(scope string, rates PriceRates) error {
+	for _, field := range []struct {
+		name  string
+		value *float64
+	}{
+		{"input", rates.Input},
+		{"output", rates.Output},
+		{"cache_read", rates.CacheRead},
+		{"cache_write", rates.CacheWrite},
+		{"reasoning", rates.Reasoning},
+	} {
+		if field.value != nil && !validPrice(*field.value) {
+			return fmt.Errorf("invalid %s.%s: must be >= 0", scope, field.name)
+		}
+	}
+	return nil
+}
+
+func validPrice(v float64) bool {
+	return v >= 0 && !math.IsInf(v, 0) && !math.IsNaN(v)
+}
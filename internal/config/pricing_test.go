@@ -0,0 +1,135 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPricing_MissingFileAndCatalog(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	pricing, err := LoadPricing(dir)
+	if err != nil {
+		t.Fatalf("LoadPricing missing: %v", err)
+	}
+	if !pricing.IsEmpty() {
+		t.Fatalf("expected empty catalog, got %#v", pricing)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, PricingFilename), []byte(strings.TrimSpace(`
+models:
+  - model: my-model
+    currency: cny
+    input: 2
+    output: 8
+    tiers:
+      - above_input_tokens: 32000
+        input: 4
+  - model: my-*
+    input: 1
+    output: 2
+provider_multipliers:
+  reseller: 1.2
+exchange_rates:
+  CNY: 0.14
+`)+"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	pricing, err = LoadPricing(dir)
+	if err != nil {
+		t.Fatalf("LoadPricing: %v", err)
+	}
+	if err := ValidatePricing(pricing); err != nil {
+		t.Fatalf("ValidatePricing: %v", err)
+	}
+
+	entry, ok := pricing.Match("MY-MODEL")
+	if !ok || entry.Model != "my-model" || *entry.Input != 2 || len(entry.Tiers) != 1 || *entry.Tiers[0].Input != 4 || entry.Tiers[0].Output != nil {
+		t.Fatalf("exact match = %#v ok=%v", entry, ok)
+	}
+	if entry, ok := pricing.Match("my-other"); !ok || entry.Model != "my-*" {
+		t.Fatalf("prefix match = %#v ok=%v", entry, ok)
+	}
+	if _, ok := pricing.Match("gpt-5"); ok {
+		t.Fatalf("unexpected match for gpt-5")
+	}
+	if rate, ok := pricing.USDRate("cny"); !ok || rate != 0.14 {
+		t.Fatalf("USDRate(cny) = %v %v", rate, ok)
+	}
+	if got := pricing.ProviderMultiplier("reseller"); got != 1.2 {
+		t.Fatalf("ProviderMultiplier(reseller) = %v", got)
+	}
+	if got := pricing.ProviderMultiplier("direct"); got != 1 {
+		t.Fatalf("ProviderMultiplier(direct) = %v", got)
+	}
+}
+
+func TestValidatePricing(t *testing.T) {
+	t.Parallel()
+
+	price := func(v float64) *float64 { return &v }
+	valid := ModelPricing{Model: "m", PriceRates: PriceRates{Input: price(1), Output: price(2)}}
+	tests := []struct {
+		name    string
+		pricing PricingConfig
+		want    string
+	}{
+		{
+			name:    "missing output",
+			pricing: PricingConfig{Models: []ModelPricing{{Model: "m", PriceRates: PriceRates{Input: price(1)}}}},
+			want:    "input and output prices are required",
+		},
+		{
+			name:    "negative cache price",
+			pricing: PricingConfig{Models: []ModelPricing{{Model: "m", PriceRates: PriceRates{Input: price(1), Output: price(2), CacheRead: price(-1)}}}},
+			want:    "pricing.models[0].cache_read",
+		},
+		{
+			name:    "duplicate model",
+			pricing: PricingConfig{Models: []ModelPricing{valid, {Model: "M", PriceRates: valid.PriceRates}}},
+			want:    "duplicate model",
+		},
+		{
+			name:    "wildcard in the middle",
+			pricing: PricingConfig{Models: []ModelPricing{{Model: "gpt-*-mini", PriceRates: valid.PriceRates}}},
+			want:    "only allowed at the end",
+		},
+		{
+			name:    "unknown currency",
+			pricing: PricingConfig{Models: []ModelPricing{{Model: "m", Currency: "EUR", PriceRates: valid.PriceRates}}},
+			want:    "no exchange_rates entry for EUR",
+		},
+		{
+			name: "tiers not ascending",
+			pricing: PricingConfig{Models: []ModelPricing{{Model: "m", PriceRates: valid.PriceRates, Tiers: []PriceTier{
+				{AboveInputTokens: 200000},
+				{AboveInputTokens: 128000},
+			}}}},
+			want: "pricing.models[0].tiers[1].above_input_tokens",
+		},
+		{
+			name:    "zero exchange rate",
+			pricing: PricingConfig{ExchangeRates: map[string]float64{"CNY": 0}},
+			want:    "pricing.exchange_rates.CNY",
+		},
+		{
+			name:    "negative multiplier",
+			pricing: PricingConfig{ProviderMultipliers: map[string]float64{"p1": -1}},
+			want:    "pricing.provider_multipliers.p1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePricing(tt.pricing)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("ValidatePricing() = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+	if err := ValidatePricing(PricingConfig{Models: []ModelPricing{valid}}); err != nil {
+		t.Fatalf("ValidatePricing(valid) = %v", err)
+	}
+}
@@ -0,0 +1,307 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/lansespirit/Clipal/internal/fsutil"
+)
+
+// RevisionsDirname holds the config revision history inside the config directory.
+const RevisionsDirname = "revisions"
+
+// MaxRevisions bounds the history; the oldest revisions are pruned first.
+const MaxRevisions = 50
+
+// RevisionSource records what wrote a revision.
+type RevisionSource string
+
+const (
+	RevisionSourceWeb  RevisionSource = "web"
+	RevisionSourceCLI  RevisionSource = "cli"
+	RevisionSourceFile RevisionSource = "file"
+)
+
+// ErrRevisionNotFound is returned for an unknown revision ID.
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// Revision is a snapshot of the global and client config files.
+type Revision struct {
+	ID     string         `json:"id"`
+	Time   time.Time      `json:"time"`
+	Source RevisionSource `json:"source"`
+	// Summary lists what changed since the previous revision, e.g.
+	// "openai: updated provider p1".
+	Summary      []string `json:"summary,omitempty"`
+	RestoredFrom string   `json:"restored_from,omitempty"`
+	// Files maps a file name to its content. A file missing from the map did
+	// not exist when the snapshot was taken.
+	Files map[string]string `json:"files"`
+}
+
+// revisionsMu serializes history writes from the web API and the config watcher.
+var revisionsMu sync.Mutex
+
+// RevisionFilenames lists the files captured by a revision.
+func RevisionFilenames() []string {
+	names := []string{"config.yaml"}
+	for _, spec := range clientConfigFileSpecs {
+		if spec.currentName != "" && !slices.Contains(names, spec.currentName) {
+			names = append(names, spec.currentName)
+		}
+	}
+	return names
+}
+
+// ReadRevisionFiles reads the current content of the revision files.
+func ReadRevisionFiles(configDir string) (map[string]string, error) {
+	files := make(map[string]string)
+	for _, name := range RevisionFilenames() {
+		data, err := os.ReadFile(filepath.Join(configDir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		files[name] = string(data)
+	}
+	return files, nil
+}
+
+// RecordRevision snapshots the config files unless they match the latest
+// revision. It returns nil when nothing changed.
+func RecordRevision(configDir string, source RevisionSource, restoredFrom string) (*Revision, error) {
+	revisionsMu.Lock()
+	defer revisionsMu.Unlock()
+
+	files, err := ReadRevisionFiles(configDir)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := revisionIDs(configDir)
+	if err != nil {
+		return nil, err
+	}
+	var previous map[string]string
+	if len(ids) > 0 {
+		latest, err := loadRevision(configDir, ids[len(ids)-1])
+		if err != nil {
+			return nil, err
+		}
+		if reflect.DeepEqual(latest.Files, files) {
+			return nil, nil
+		}
+		previous = latest.Files
+	}
+
+	now := time.Now()
+	rev := &Revision{
+		ID:           now.UTC().Format("20060102T150405.000000000Z"),
+		Time:         now,
+		Source:       source,
+		RestoredFrom: restoredFrom,
+		Files:        files,
+	}
+	if previous != nil {
+		rev.Summary = SummarizeRevisionChanges(previous, files)
+	}
+	data, err := json.MarshalIndent(rev, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := fsutil.AtomicWriteFile(filepath.Join(configDir, RevisionsDirname, rev.ID+".json"), data, 0o600); err != nil {
+		return nil, err
+	}
+	for _, id := range append(ids, rev.ID)[:max(0, len(ids)+1-MaxRevisions)] {
+		_ = os.Remove(filepath.Join(configDir, RevisionsDirname, id+".json"))
+	}
+	return rev, nil
+}
+
+// ListRevisions returns the retained revisions, newest first.
+func ListRevisions(configDir string) ([]Revision, error) {
+	revisionsMu.Lock()
+	defer revisionsMu.Unlock()
+
+	ids, err := revisionIDs(configDir)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Revision, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		rev, err := loadRevision(configDir, ids[i])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *rev)
+	}
+	return out, nil
+}
+
+// LoadRevision returns one revision and the one recorded before it, if any.
+func LoadRevision(configDir, id string) (rev *Revision, previous *Revision, err error) {
+	revisionsMu.Lock()
+	defer revisionsMu.Unlock()
+
+	ids, err := revisionIDs(configDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	i := sort.SearchStrings(ids, id)
+	if i == len(ids) || ids[i] != id {
+		return nil, nil, ErrRevisionNotFound
+	}
+	if rev, err = loadRevision(configDir, id); err != nil {
+		return nil, nil, err
+	}
+	if i > 0 {
+		if previous, err = loadRevision(configDir, ids[i-1]); err != nil {
+			return nil, nil, err
+		}
+	}
+	return rev, previous, nil
+}
+
+// SummarizeRevisionChanges describes the differences between two sets of
+// revision files: changed global settings and added, updated, or removed
+// providers per client.
+func SummarizeRevisionChanges(before, after map[string]string) []string {
+	var out []string
+	for _, name := range RevisionFilenames() {
+		if before[name] == after[name] {
+			continue
+		}
+		if name == "config.yaml" {
+			out = append(out, summarizeGlobalChange(before[name], after[name]))
+			continue
+		}
+		out = append(out, summarizeClientChange(strings.TrimSuffix(name, ".yaml"), before[name], after[name])...)
+	}
+	return out
+}
+
+func summarizeGlobalChange(before, after string) string {
+	var a, b map[string]any
+	if yaml.Unmarshal([]byte(before), &a) != nil || yaml.Unmarshal([]byte(after), &b) != nil {
+		return "config.yaml changed"
+	}
+	var keys []string
+	for key := range mergeKeys(a, b) {
+		if !reflect.DeepEqual(a[key], b[key]) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return "global: formatting changed"
+	}
+	sort.Strings(keys)
+	return "global: " + strings.Join(keys, ", ") + " changed"
+}
+
+func summarizeClientChange(client, before, after string) []string {
+	type clientFile struct {
+		Mode           string           `yaml:"mode"`
+		PinnedProvider string           `yaml:"pinned_provider"`
+		Providers      []map[string]any `yaml:"providers"`
+	}
+	var a, b clientFile
+	if yaml.Unmarshal([]byte(before), &a) != nil || yaml.Unmarshal([]byte(after), &b) != nil {
+		return []string{client + ".yaml changed"}
+	}
+	index := func(providers []map[string]any) (map[string]map[string]any, []string) {
+		byName := make(map[string]map[string]any, len(providers))
+		var names []string
+		for _, p := range providers {
+			name := fmt.Sprint(p["name"])
+			byName[name] = p
+			names = append(names, name)
+		}
+		return byName, names
+	}
+	oldByName, oldNames := index(a.Providers)
+	newByName, newNames := index(b.Providers)
+
+	var out []string
+	if a.Mode != b.Mode || a.PinnedProvider != b.PinnedProvider {
+		out = append(out, client+": mode changed")
+	}
+	for _, name := range newNames {
+		old, ok := oldByName[name]
+		switch {
+		case !ok:
+			out = append(out, client+": added provider "+name)
+		case !reflect.DeepEqual(old, newByName[name]):
+			out = append(out, client+": updated provider "+name)
+		}
+	}
+	for _, name := range oldNames {
+		if _, ok := newByName[name]; !ok {
+			out = append(out, client+": removed provider "+name)
+		}
+	}
+	if len(out) == 0 {
+		out = append(out, client+": formatting changed")
+	}
+	return out
+}
+
+func mergeKeys(a, b map[string]any) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for key := range a {
+		keys[key] = struct{}{}
+	}
+	for key := range b {
+		keys[key] = struct{}{}
+	}
+	return keys
+}
+
+// revisionIDs returns the stored revision IDs, oldest first. IDs are UTC
+// timestamps, so they sort chronologically.
+func revisionIDs(configDir string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(configDir, RevisionsDirname))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var ids []string
+	for _, entry := range entries {
+		if name := entry.Name(); !entry.IsDir() && strings.HasSuffix(name, ".json") && !strings.HasPrefix(name, ".") {
+			ids = append(ids, strings.TrimSuffix(name, ".json"))
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func loadRevision(configDir, id string) (*Revision, error) {
+	data, err := os.ReadFile(filepath.Join(configDir, RevisionsDirname, id+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrRevisionNotFound
+		}
+		return nil, err
+	}
+	var rev Revision
+	if err := json.Unmarshal(data, &rev); err != nil {
+		return nil, fmt.Errorf("failed to parse revision %s: %w", id, err)
+	}
+	if rev.Files == nil {
+		rev.Files = map[string]string{}
+	}
+	return &rev, nil
+}
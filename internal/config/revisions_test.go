@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRecordRevisionSkipsUnchangedFilesAndSummarizes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	write("config.yaml", "port: 3333\n")
+	write("openai.yaml", "mode: auto\nproviders:\n  - name: p1\n    base_url: https://a\n  - name: p2\n    base_url: https://b\n")
+
+	first, err := RecordRevision(dir, RevisionSourceFile, "")
+	if err != nil || first == nil || len(first.Summary) != 0 {
+		t.Fatalf("first revision = %#v, %v", first, err)
+	}
+	if again, err := RecordRevision(dir, RevisionSourceWeb, ""); err != nil || again != nil {
+		t.Fatalf("unchanged files recorded a revision: %#v, %v", again, err)
+	}
+
+	write("config.yaml", "port: 4444\n")
+	write("openai.yaml", "mode: auto\nproviders:\n  - name: p1\n    base_url: https://changed\n  - name: p3\n    base_url: https://c\n")
+	second, err := RecordRevision(dir, RevisionSourceWeb, "")
+	if err != nil || second == nil {
+		t.Fatalf("second revision = %#v, %v", second, err)
+	}
+	want := []string{"global: port changed", "openai: updated provider p1", "openai: added provider p3", "openai: removed provider p2"}
+	if !slices.Equal(second.Summary, want) {
+		t.Fatalf("summary = %q, want %q", second.Summary, want)
+	}
+
+	rev, previous, err := LoadRevision(dir, second.ID)
+	if err != nil || previous == nil || previous.ID != first.ID || rev.Source != RevisionSourceWeb {
+		t.Fatalf("LoadRevision = %#v, %#v, %v", rev, previous, err)
+	}
+	if _, _, err := LoadRevision(dir, "../config"); err != ErrRevisionNotFound {
+		t.Fatalf("LoadRevision(unknown) err = %v", err)
+	}
+}
+
+func TestRecordRevisionPrunesOldest(t *testing.T) {
+	dir := t.TempDir()
+	var ids []string
+	for i := range MaxRevisions + 2 {
+		if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(fmt.Sprintf("port: %d\n", 3000+i)), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		rev, err := RecordRevision(dir, RevisionSourceFile, "")
+		if err != nil || rev == nil {
+			t.Fatalf("RecordRevision #%d = %#v, %v", i, rev, err)
+		}
+		ids = append(ids, rev.ID)
+	}
+	revs, err := ListRevisions(dir)
+	if err != nil {
+		t.Fatalf("ListRevisions: %v", err)
+	}
+	if len(revs) != MaxRevisions || revs[0].ID != ids[len(ids)-1] || revs[len(revs)-1].ID != ids[2] {
+		t.Fatalf("kept %d revisions, newest %s oldest %s", len(revs), revs[0].ID, revs[len(revs)-1].ID)
+	}
+}
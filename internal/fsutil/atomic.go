@@ -0,0 +1,51 @@
+// Package fsutil holds file helpers shared by Clipal's on-disk stores.
+package fsutil
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// AtomicWriteFile writes data to path through a synced temporary file in the
+// same directory and renames it into place, so readers see either the old or
+// the new content. Missing parent directories are created with mode 0700.
+//
+//nolint:gosec // paths come from Clipal-managed config, telemetry and oauth store locations.
+func AtomicWriteFile(path string, data []byte, perm fs.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, ".clipal-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	success := false
+	defer func() {
+		_ = f.Close()
+		if !success {
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	if err := f.Chmod(perm); err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	success = true
+	return nil
+}
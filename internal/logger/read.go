@@ -0,0 +1,345 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FilePrefix names the files the process-wide logger rotates into.
+const FilePrefix = "clipal"
+
+const lineTimeLayout = "2006-01-02 15:04:05.000"
+
+// followPollInterval is how often Follow checks the current log file for new
+// lines.
+var followPollInterval = 500 * time.Millisecond
+
+// Dir resolves the log directory: the configured log_dir, or logs/ under the
+// config directory.
+func Dir(configDir, logDir string) string {
+	if logDir = strings.TrimSpace(logDir); logDir != "" {
+		return logDir
+	}
+	return filepath.Join(configDir, "logs")
+}
+
+// FileInfo describes one rotated log file.
+type FileInfo struct {
+	Name    string    `json:"name"`
+	Day     string    `json:"day"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modified"`
+}
+
+// ListFiles returns the rotated log files in dir, newest day first. A missing
+// directory has no files.
+func ListFiles(dir string) ([]FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var files []FileInfo
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		day, ok := logFileDay(e.Name())
+		if !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, FileInfo{Name: e.Name(), Day: day, Size: info.Size(), ModTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Day > files[j].Day })
+	return files, nil
+}
+
+func logFileDay(name string) (string, bool) {
+	day, ok := strings.CutPrefix(name, FilePrefix+"-")
+	if !ok {
+		return "", false
+	}
+	if day, ok = strings.CutSuffix(day, ".log"); !ok {
+		return "", false
+	}
+	if _, err := time.Parse("2006-01-02", day); err != nil {
+		return "", false
+	}
+	return day, true
+}
+
+// Entry is one parsed log record. Continuation lines of a multi-line message
+// are folded into the record they follow.
+type Entry struct {
+	Time  time.Time `json:"time"`
+	Level string    `json:"level"`
+	// Client is the "[client]" tag proxy messages start with, if any.
+	Client  string `json:"client,omitempty"`
+	Message string `json:"message"`
+}
+
+// String formats the entry the way the logger wrote it.
+func (e Entry) String() string {
+	return fmt.Sprintf("[%-5s] %s %s", e.Level, e.Time.Format(lineTimeLayout), e.Message)
+}
+
+// parseLine parses a "[LEVEL] 2006-01-02 15:04:05.000 message" line.
+func parseLine(line string) (Entry, bool) {
+	rest, ok := strings.CutPrefix(line, "[")
+	if !ok {
+		return Entry{}, false
+	}
+	level, rest, ok := strings.Cut(rest, "] ")
+	if !ok {
+		return Entry{}, false
+	}
+	level = strings.TrimSpace(level)
+	if levelRank(level) < 0 || len(rest) < len(lineTimeLayout) {
+		return Entry{}, false
+	}
+	at, err := time.ParseInLocation(lineTimeLayout, rest[:len(lineTimeLayout)], time.Local)
+	if err != nil {
+		return Entry{}, false
+	}
+	message := strings.TrimPrefix(rest[len(lineTimeLayout):], " ")
+	e := Entry{Time: at, Level: level, Message: message}
+	if tag, _, ok := strings.Cut(message, "] "); ok && strings.HasPrefix(tag, "[") && !strings.ContainsAny(tag[1:], " [") {
+		e.Client = tag[1:]
+	}
+	return e, true
+}
+
+func levelRank(level string) int {
+	switch strings.ToUpper(strings.TrimSpace(level)) {
+	case "DEBUG":
+		return int(LevelDebug)
+	case "INFO":
+		return int(LevelInfo)
+	case "WARN", "WARNING":
+		return int(LevelWarn)
+	case "ERROR":
+		return int(LevelError)
+	default:
+		return -1
+	}
+}
+
+// Query selects log entries. Empty fields match everything.
+type Query struct {
+	// Level is the minimum level: debug, info, warn, or error.
+	Level  string
+	Client string
+	// Provider matches entries that mention the provider name as a word.
+	Provider string
+	// Text is a case-insensitive substring of the message.
+	Text  string
+	Since time.Time
+	Until time.Time
+}
+
+// Validate reports an unknown level.
+func (q Query) Validate() error {
+	if strings.TrimSpace(q.Level) != "" && levelRank(q.Level) < 0 {
+		return fmt.Errorf("unknown log level: %s", q.Level)
+	}
+	return nil
+}
+
+// Match reports whether e passes the query.
+func (q Query) Match(e Entry) bool {
+	if strings.TrimSpace(q.Level) != "" && levelRank(e.Level) < levelRank(q.Level) {
+		return false
+	}
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !e.Time.Before(q.Until) {
+		return false
+	}
+	if q.Client != "" && !strings.EqualFold(q.Client, e.Client) {
+		return false
+	}
+	if q.Provider != "" && !containsWord(e.Message, q.Provider) {
+		return false
+	}
+	if q.Text != "" && !strings.Contains(strings.ToLower(e.Message), strings.ToLower(q.Text)) {
+		return false
+	}
+	return true
+}
+
+// containsWord reports whether word occurs in s without a name character on
+// either side, so provider "p1" does not match "p10".
+func containsWord(s, word string) bool {
+	for offset := 0; ; {
+		i := strings.Index(s[offset:], word)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(word)
+		before, _ := utf8.DecodeLastRuneInString(s[:start])
+		after, _ := utf8.DecodeRuneInString(s[end:])
+		if (start == 0 || !isNameRune(before)) && (end == len(s) || !isNameRune(after)) {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.'
+}
+
+// Search returns the last limit entries matching q across the log files in
+// dir, oldest first. Files outside the query's time range are skipped.
+func Search(dir string, q Query, limit int) ([]Entry, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	files, err := ListFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []Entry
+	keep := func(e Entry) {
+		if !q.Match(e) {
+			return
+		}
+		matches = append(matches, e)
+		if limit > 0 && len(matches) > 2*limit {
+			matches = append(matches[:0], matches[len(matches)-limit:]...)
+		}
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		day := files[i].Day
+		if !q.Since.IsZero() && day < q.Since.In(time.Local).Format("2006-01-02") {
+			continue
+		}
+		if !q.Until.IsZero() && day > q.Until.In(time.Local).Format("2006-01-02") {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, files[i].Name))
+		if err != nil {
+			continue
+		}
+		err = scanEntries(f, keep)
+		_ = f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if limit > 0 && len(matches) > limit {
+		matches = matches[len(matches)-limit:]
+	}
+	return matches, nil
+}
+
+// scanEntries parses r and calls fn for every complete entry.
+func scanEntries(r io.Reader, fn func(Entry)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	var pending *Entry
+	for scanner.Scan() {
+		line := scanner.Text()
+		if e, ok := parseLine(line); ok {
+			if pending != nil {
+				fn(*pending)
+			}
+			pending = &e
+			continue
+		}
+		if pending != nil {
+			pending.Message += "\n" + line
+		}
+	}
+	if pending != nil {
+		fn(*pending)
+	}
+	return scanner.Err()
+}
+
+// Follow calls fn for each entry matching q that is appended to the newest log
+// file in dir after Follow starts, switching files when the log rotates. It
+// returns when ctx is done.
+func Follow(ctx context.Context, dir string, q Query, fn func(Entry)) error {
+	if err := q.Validate(); err != nil {
+		return err
+	}
+	name, offset := currentLogFile(dir)
+	ticker := time.NewTicker(followPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if latest, size := currentLogFile(dir); latest != name {
+			name, offset = latest, 0
+		} else if size < offset {
+			offset = 0
+		}
+		if name == "" {
+			continue
+		}
+		next, err := readAppended(filepath.Join(dir, name), offset, func(e Entry) {
+			if q.Match(e) {
+				fn(e)
+			}
+		})
+		if err != nil {
+			continue
+		}
+		offset = next
+	}
+}
+
+func currentLogFile(dir string) (string, int64) {
+	files, err := ListFiles(dir)
+	if err != nil || len(files) == 0 {
+		return "", 0
+	}
+	return files[0].Name, files[0].Size
+}
+
+// readAppended parses the complete lines written to path since offset and
+// returns the offset after the last one. A trailing partial line is left for
+// the next read.
+func readAppended(path string, offset int64, fn func(Entry)) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return offset, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return offset, err
+	}
+	end := bytes.LastIndexByte(data, '\n')
+	if end < 0 {
+		return offset, nil
+	}
+	if err := scanEntries(bytes.NewReader(data[:end+1]), fn); err != nil {
+		return offset, err
+	}
+	return offset + int64(end+1), nil
+}
@@ -0,0 +1,105 @@
+package logger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchFiltersAcrossRotatedFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeLog := func(day, content string) {
+		if err := os.WriteFile(filepath.Join(dir, "clipal-"+day+".log"), []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	writeLog("2026-03-01", "[INFO ] 2026-03-01 10:00:00.000 [claude] forwarding to: p1\n"+
+		"[ERROR] 2026-03-01 11:00:00.000 [claude] all providers failed: p1 returned 502\n"+
+		"  upstream body\n")
+	writeLog("2026-03-02", "[WARN ] 2026-03-02 09:00:00.000 [openai] p10 returned 429; trying next provider\n"+
+		"[WARN ] 2026-03-02 09:30:00.000 [claude] provider p1 exhausted available keys\n")
+	if err := os.WriteFile(filepath.Join(dir, "other.log"), []byte("[ERROR] 2026-03-02 09:00:00.000 p1\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	entries, err := Search(dir, Query{Level: "warn", Provider: "p1"}, 0)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Level != "ERROR" || entries[0].Client != "claude" || entries[1].Level != "WARN" {
+		t.Fatalf("entries = %#v", entries)
+	}
+	if !strings.HasSuffix(entries[0].Message, "502\n  upstream body") {
+		t.Fatalf("continuation line not folded: %q", entries[0].Message)
+	}
+
+	since := time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local)
+	entries, err = Search(dir, Query{Since: since, Client: "openai"}, 1)
+	if err != nil || len(entries) != 1 || !strings.Contains(entries[0].Message, "p10") {
+		t.Fatalf("since search = %#v, %v", entries, err)
+	}
+
+	if _, err := Search(dir, Query{Level: "loud"}, 0); err == nil {
+		t.Fatalf("expected unknown level error")
+	}
+}
+
+func TestFollowReadsAppendedEntries(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clipal-2026-03-01.log")
+	if err := os.WriteFile(path, []byte("[INFO ] 2026-03-01 10:00:00.000 old\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	oldInterval := followPollInterval
+	followPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { followPollInterval = oldInterval })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	got := make(chan Entry, 4)
+	done := make(chan error, 1)
+	go func() {
+		done <- Follow(ctx, dir, Query{Level: "warn"}, func(e Entry) { got <- e })
+	}()
+
+	time.Sleep(30 * time.Millisecond)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	_, _ = f.WriteString("[INFO ] 2026-03-01 10:00:01.000 skipped\n[WARN ] 2026-03-01 10:00:02.000 new")
+	_ = f.Sync()
+	time.Sleep(30 * time.Millisecond)
+	_, _ = f.WriteString("\n")
+	_ = f.Close()
+
+	select {
+	case e := <-got:
+		if e.Message != "new" || e.Level != "WARN" {
+			t.Fatalf("followed entry = %#v", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no entry followed")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Follow: %v", err)
+	}
+}
+
+func TestRedactMasksSecrets(t *testing.T) {
+	t.Parallel()
+
+	msg := Redact(`auth=Bearer abc.def key=sk-ant-abcdefghijklmnop {"refresh_token":"rt-123"} url=/v1?key=AIzaSyD0123456789abcdefghijklmnopqrstu`)
+	for _, secret := range []string{"abc.def", "abcdefghijklmnop", "rt-123", "AIzaSy"} {
+		if strings.Contains(msg, secret) {
+			t.Fatalf("secret %q survived: %s", secret, msg)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package logger
+
+import (
+	"regexp"
+	"strings"
+)
+
+type redactor struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+var sensitiveRedactors = []redactor{
+	{regexp.MustCompile(`(?i)\b(bearer)\s+([^\s]+)`), "$1 [redacted]"},
+	{regexp.MustCompile(`\bsk-[a-zA-Z0-9_-]{10,}\b`), "[redacted]"},
+	{regexp.MustCompile(`\bsk-ant-[a-zA-Z0-9_-]{10,}\b`), "[redacted]"},
+	{regexp.MustCompile(`\bsk-or-[a-zA-Z0-9_-]{10,}\b`), "[redacted]"},
+	{regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{30,}\b`), "[redacted]"},
+	{regexp.MustCompile(`(?i)\b(api[_-]?key|x-api-key|x-goog-api-key)\s*[:=]\s*([^\s]+)`), "$1=[redacted]"},
+	{regexp.MustCompile(`(?i)"((?:access|refresh|id)_token|api_key|client_secret)"\s*:\s*"[^"]*"`), `"$1":"[redacted]"`},
+	{regexp.MustCompile(`(?i)([?&](?:api[_-]?key|key|token|access[_-]?token)=)([^&\s]+)`), "$1[redacted]"},
+}
+
+// Redact masks API keys, bearer tokens, and OAuth secrets in a log message
+// before it leaves the process.
+func Redact(message string) string {
+	if strings.TrimSpace(message) == "" {
+		return message
+	}
+	for _, r := range sensitiveRedactors {
+		message = r.pattern.ReplaceAllString(message, r.replacement)
+	}
+	return message
+}
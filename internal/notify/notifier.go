@@ -4,13 +4,13 @@ import (
 	_ "embed"
 	"fmt"
 	"os"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gen2brain/beeep"
 	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/logger"
 )
 
 const (
@@ -227,30 +227,8 @@ func normalizeMessage(message string) string {
 	return message
 }
 
-type redactor struct {
-	pattern     *regexp.Regexp
-	replacement string
-}
-
-var sensitiveRedactors = []redactor{
-	{regexp.MustCompile(`(?i)\b(bearer)\s+([^\s]+)`), "$1 [redacted]"},
-	{regexp.MustCompile(`\bsk-[a-zA-Z0-9_-]{10,}\b`), "[redacted]"},
-	{regexp.MustCompile(`\bsk-ant-[a-zA-Z0-9_-]{10,}\b`), "[redacted]"},
-	{regexp.MustCompile(`\bsk-or-[a-zA-Z0-9_-]{10,}\b`), "[redacted]"},
-	{regexp.MustCompile(`(?i)\b(api[_-]?key)\s*[:=]\s*([^\s]+)`), "$1=[redacted]"},
-	{regexp.MustCompile(`(?i)([?&](?:api[_-]?key|token|access[_-]?token)=)([^&\s]+)`), "$1[redacted]"},
-}
-
 func redactSensitive(message string) string {
-	message = strings.TrimSpace(message)
-	if message == "" {
-		return ""
-	}
-	redacted := message
-	for _, r := range sensitiveRedactors {
-		redacted = r.pattern.ReplaceAllString(redacted, r.replacement)
-	}
-	return redacted
+	return logger.Redact(strings.TrimSpace(message))
 }
 
 func (n *Notifier) enqueue(title string, message string, key string) {
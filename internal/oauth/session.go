@@ -39,6 +39,7 @@ type Service struct {
 	terminalRetention    time.Duration
 	refreshSkew          time.Duration
 	geminiUsageTTL       time.Duration
+	refreshObserver      func(provider config.OAuthProvider, ref string, err error)
 
 	mu               sync.Mutex
 	sessions         map[string]*LoginSession
@@ -166,6 +167,14 @@ func WithRefreshSkew(skew time.Duration) Option {
 	}
 }
 
+// WithRefreshObserver registers fn to be told about every credential refresh
+// the service performs, successful or not.
+func WithRefreshObserver(fn func(provider config.OAuthProvider, ref string, err error)) Option {
+	return func(s *Service) {
+		s.refreshObserver = fn
+	}
+}
+
 func WithGeminiUsageTTL(ttl time.Duration) Option {
 	return func(s *Service) {
 		if ttl >= 0 {
@@ -411,6 +420,9 @@ func (s *Service) refresh(ctx context.Context, provider config.OAuthProvider, re
 	call.err = err
 	close(call.done)
 	s.mu.Unlock()
+	if s.refreshObserver != nil {
+		s.refreshObserver(cred.Provider, cred.Ref, err)
+	}
 
 	if refreshed == nil {
 		return nil, err
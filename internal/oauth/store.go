@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/fsutil"
 )
 
 type Store struct {
@@ -65,7 +66,7 @@ func (s *Store) Save(cred *Credential) error {
 	}
 	data = append(data, '\n')
 	targetPath := s.preferredPath(provider, toSave.Email, toSave.Ref)
-	if err := atomicWriteFile(targetPath, data, 0o600); err != nil {
+	if err := fsutil.AtomicWriteFile(targetPath, data, 0o600); err != nil {
 		return err
 	}
 	if existingPath != "" && existingPath != targetPath {
@@ -453,46 +454,5 @@ func (s fileSnapshot) restore() error {
 		}
 		return nil
 	}
-	return atomicWriteFile(s.path, s.data, s.perm)
-}
-
-func atomicWriteFile(path string, data []byte, perm fs.FileMode) error {
-	dir := filepath.Dir(path)
-	// #nosec G703 -- dir is derived from Clipal-managed oauth provider directories.
-	if err := os.MkdirAll(dir, 0o700); err != nil {
-		return err
-	}
-
-	f, err := os.CreateTemp(dir, ".clipal-oauth-*")
-	if err != nil {
-		return err
-	}
-	tmp := f.Name()
-	success := false
-	defer func() {
-		_ = f.Close()
-		if !success {
-			// #nosec G703 -- tmp is created by os.CreateTemp under Clipal-managed oauth storage.
-			_ = os.Remove(tmp)
-		}
-	}()
-
-	if err := f.Chmod(perm); err != nil {
-		return err
-	}
-	if _, err := f.Write(data); err != nil {
-		return err
-	}
-	if err := f.Sync(); err != nil {
-		return err
-	}
-	if err := f.Close(); err != nil {
-		return err
-	}
-	// #nosec G703 -- tmp and path are created under Clipal-managed oauth provider directories.
-	if err := os.Rename(tmp, path); err != nil {
-		return err
-	}
-	success = true
-	return nil
+	return fsutil.AtomicWriteFile(s.path, s.data, s.perm)
 }
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/fsutil"
 )
 
 func TestStoreSaveUses0600Permissions(t *testing.T) {
@@ -371,5 +372,5 @@ func writeCredentialFile(path string, cred *Credential) error {
 		return err
 	}
 	data = append(data, '\n')
-	return atomicWriteFile(path, data, 0o600)
+	return fsutil.AtomicWriteFile(path, data, 0o600)
 }
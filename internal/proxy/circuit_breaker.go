@@ -75,6 +75,13 @@ type circuitBreaker struct {
 	openedAt time.Time
 	// halfOpenInFlight tracks probe requests currently in flight in half-open state.
 	halfOpenInFlight int
+	// manualOpen marks a circuit opened by an operator, with their reason. Both
+	// are cleared by the next state change.
+	manualOpen   bool
+	manualReason string
+
+	// onTransition, when set, is called with cb.mu held whenever the state changes.
+	onTransition func(from, to circuitState)
 }
 
 type circuitAllowResult struct {
@@ -151,7 +158,7 @@ func (cb *circuitBreaker) allow(now time.Time) circuitAllowResult {
 		}
 		elapsed := now.Sub(cb.openedAt)
 		if elapsed >= cb.cfg.openTimeout {
-			cb.state = circuitHalfOpen
+			cb.setStateLocked(circuitHalfOpen)
 			cb.consecutiveSuccesses = 0
 			cb.halfOpenInFlight = 0
 			// fallthrough to half-open handling
@@ -194,7 +201,7 @@ func (cb *circuitBreaker) recordSuccess(now time.Time, usedProbe bool) {
 	case circuitHalfOpen:
 		cb.consecutiveSuccesses++
 		if cb.consecutiveSuccesses >= cb.cfg.successThreshold {
-			cb.state = circuitClosed
+			cb.setStateLocked(circuitClosed)
 			cb.consecutiveSuccesses = 0
 			cb.openedAt = time.Time{}
 			cb.halfOpenInFlight = 0
@@ -247,36 +254,95 @@ func (cb *circuitBreaker) releaseProbeNeutral(usedProbe bool) {
 }
 
 func (cb *circuitBreaker) transitionToOpenLocked(now time.Time) {
-	cb.state = circuitOpen
+	cb.setStateLocked(circuitOpen)
 	cb.openedAt = now
 	cb.consecutiveFailures = 0
 	cb.consecutiveSuccesses = 0
 	cb.halfOpenInFlight = 0
 }
 
-func (cb *circuitBreaker) snapshot(now time.Time) (state circuitState, openWait time.Duration) {
+// forceOpen opens the circuit for d regardless of recent results. It reports
+// false when the breaker is disabled.
+func (cb *circuitBreaker) forceOpen(now time.Time, d time.Duration, reason string) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if !cb.cfg.enabled {
+		return false
+	}
+	cb.transitionToOpenLocked(now)
+	// Backdate openedAt so the usual open timeout ends after d.
+	cb.openedAt = now.Add(d - cb.cfg.openTimeout)
+	cb.manualOpen = true
+	cb.manualReason = reason
+	return true
+}
+
+// reset closes the circuit and forgets recent failures. It reports false when
+// the breaker is disabled.
+func (cb *circuitBreaker) reset() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if !cb.cfg.enabled {
+		return false
+	}
+	cb.setStateLocked(circuitClosed)
+	cb.consecutiveFailures = 0
+	cb.consecutiveSuccesses = 0
+	cb.openedAt = time.Time{}
+	cb.halfOpenInFlight = 0
+	cb.manualOpen = false
+	cb.manualReason = ""
+	return true
+}
+
+func (cb *circuitBreaker) setStateLocked(state circuitState) {
+	from := cb.state
+	cb.state = state
+	if from != state {
+		cb.manualOpen = false
+		cb.manualReason = ""
+	}
+	if from != state && cb.onTransition != nil {
+		cb.onTransition(from, state)
+	}
+}
+
+// circuitSnapshot is the state of a breaker as the status API reports it.
+type circuitSnapshot struct {
+	state    circuitState
+	openWait time.Duration
+	// manual and reason describe a circuit an operator opened.
+	manual bool
+	reason string
+}
+
+func (cb *circuitBreaker) snapshot(now time.Time) circuitSnapshot {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
 	if !cb.cfg.enabled {
-		return circuitClosed, 0
+		return circuitSnapshot{state: circuitClosed}
 	}
 
 	switch cb.state {
 	case circuitOpen:
+		snap := circuitSnapshot{state: circuitOpen, openWait: cb.cfg.openTimeout, manual: cb.manualOpen, reason: cb.manualReason}
 		if cb.openedAt.IsZero() {
-			return circuitOpen, cb.cfg.openTimeout
+			return snap
 		}
 		elapsed := now.Sub(cb.openedAt)
 		if elapsed >= cb.cfg.openTimeout {
 			// Mirror allow(): after the open timeout elapses, the breaker is ready
 			// to probe in half-open state.
-			return circuitHalfOpen, 0
+			return circuitSnapshot{state: circuitHalfOpen}
 		}
-		return circuitOpen, cb.cfg.openTimeout - elapsed
+		snap.openWait = cb.cfg.openTimeout - elapsed
+		return snap
 	case circuitHalfOpen:
-		return circuitHalfOpen, 0
+		return circuitSnapshot{state: circuitHalfOpen}
 	default:
-		return circuitClosed, 0
+		return circuitSnapshot{state: circuitClosed}
 	}
 }
@@ -0,0 +1,350 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/logger"
+	"github.com/lansespirit/Clipal/internal/tokencount"
+)
+
+// localCountTokensProvider names count_tokens answers Clipal computed itself
+// in request records.
+const localCountTokensProvider = "local"
+
+const (
+	// claudeMessageOverheadTokens approximates the role markers around each
+	// Claude message.
+	claudeMessageOverheadTokens = 3
+	// claudeToolsOverheadTokens approximates the tool-use system prompt Claude
+	// adds when a request declares tools.
+	claudeToolsOverheadTokens = 346
+	// openAIMessageOverheadTokens approximates the role and separator tokens
+	// the chat format adds around each message.
+	openAIMessageOverheadTokens = 4
+)
+
+func (cp *ClientProxy) countTokensMode() config.CountTokensMode {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+	if cp.routing.countTokens == "" {
+		return config.CountTokensModeFallback
+	}
+	return cp.routing.countTokens
+}
+
+// answerCountTokensLocally writes a locally estimated count_tokens response.
+// It reads the request body itself when payload is nil, and returns false
+// without writing anything when the body cannot be counted.
+func (cp *ClientProxy) answerCountTokensLocally(w http.ResponseWriter, req *http.Request, payload *requestPayload, reason string) bool {
+	if payload == nil {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return false
+		}
+		payload = newRequestPayload(body)
+	}
+	requestCtx, _ := requestContextFromRequest(req)
+	tokens, ok := localCountTokens(requestCtx, payload.jsonRoot())
+	if !ok {
+		return false
+	}
+	var response any
+	if requestCtx.Capability == CapabilityGeminiCountTokens {
+		response = map[string]int{"totalTokens": tokens}
+	} else {
+		response = map[string]int{"input_tokens": tokens}
+	}
+	body, err := json.Marshal(response)
+	if err != nil {
+		return false
+	}
+
+	logger.Debug("[%s] answering count_tokens locally (%s): %d tokens", cp.clientType, reason, tokens)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	n, _ := w.Write(body)
+	cp.logRequestResult(req, localCountTokensProvider, http.StatusOK, streamResult{
+		kind:     streamFinal,
+		delivery: deliveryCommittedComplete,
+		protocol: protocolNotApplicable,
+		proto:    streamProtocolNone,
+		bytes:    n,
+	}, false)
+	return true
+}
+
+// localCountTokens estimates the prompt tokens of a Claude count_tokens or
+// Gemini countTokens body. ok is false when the body is not a request of
+// that shape.
+func localCountTokens(requestCtx RequestContext, root map[string]any) (int, bool) {
+	if root == nil {
+		return 0, false
+	}
+	switch requestCtx.Capability {
+	case CapabilityClaudeCountTokens:
+		if _, ok := root["messages"].([]any); !ok {
+			return 0, false
+		}
+		return countClaudeTokens(root), true
+	case CapabilityGeminiCountTokens:
+		if nested, ok := root["generateContentRequest"].(map[string]any); ok {
+			root = nested
+		}
+		if _, ok := root["contents"].([]any); !ok {
+			return 0, false
+		}
+		return countGeminiTokens(root), true
+	default:
+		return 0, false
+	}
+}
+
+// estimatePromptTokens estimates the prompt tokens of a request body of the
+// request's protocol family, for usage an upstream never reported.
+func estimatePromptTokens(requestCtx RequestContext, root map[string]any) int64 {
+	if root == nil {
+		return 0
+	}
+	switch requestCtx.Family {
+	case ProtocolFamilyClaude:
+		return int64(countClaudeTokens(root))
+	case ProtocolFamilyGemini:
+		if nested, ok := root["generateContentRequest"].(map[string]any); ok {
+			root = nested
+		}
+		return int64(countGeminiTokens(root))
+	default:
+		return int64(countOpenAITokens(root))
+	}
+}
+
+// countOpenAITokens counts a chat, completions or responses request: its
+// messages or input items, legacy prompt, instructions and tools.
+func countOpenAITokens(root map[string]any) int {
+	total := countOpenAIContent(root["instructions"])
+	messages, _ := root["messages"].([]any)
+	for _, raw := range messages {
+		total += countOpenAIItem(raw)
+	}
+	switch input := root["input"].(type) {
+	case string:
+		total += tokencount.Text(input)
+	case []any:
+		for _, raw := range input {
+			total += countOpenAIItem(raw)
+		}
+	}
+	switch prompt := root["prompt"].(type) {
+	case string:
+		total += tokencount.Text(prompt)
+	case []any:
+		for _, item := range prompt {
+			total += tokencount.Text(stringValue(item))
+		}
+	}
+	tools, _ := root["tools"].([]any)
+	for _, tool := range tools {
+		total += countJSONTokens(tool)
+	}
+	return total
+}
+
+// countOpenAIItem counts a chat message or a responses input item.
+func countOpenAIItem(raw any) int {
+	item, _ := raw.(map[string]any)
+	if item == nil {
+		return 0
+	}
+	switch stringValue(item["type"]) {
+	case "function_call":
+		return tokencount.Text(stringValue(item["name"])) + tokencount.Text(stringValue(item["arguments"]))
+	case "function_call_output":
+		return countOpenAIContent(item["output"])
+	}
+	total := openAIMessageOverheadTokens + countOpenAIContent(item["content"])
+	toolCalls, _ := item["tool_calls"].([]any)
+	for _, rawCall := range toolCalls {
+		call, _ := rawCall.(map[string]any)
+		function, _ := call["function"].(map[string]any)
+		total += tokencount.Text(stringValue(function["name"])) + tokencount.Text(stringValue(function["arguments"]))
+	}
+	return total
+}
+
+// countOpenAIContent counts message content, which is either a string or a
+// list of parts.
+func countOpenAIContent(content any) int {
+	switch content := content.(type) {
+	case string:
+		return tokencount.Text(content)
+	case []any:
+		total := 0
+		for _, raw := range content {
+			part, _ := raw.(map[string]any)
+			total += tokencount.Text(stringValue(part["text"]))
+		}
+		return total
+	default:
+		return 0
+	}
+}
+
+func countClaudeTokens(root map[string]any) int {
+	total := countClaudeContent(root["system"])
+	messages, _ := root["messages"].([]any)
+	for _, raw := range messages {
+		message, _ := raw.(map[string]any)
+		if message == nil {
+			continue
+		}
+		total += claudeMessageOverheadTokens + countClaudeContent(message["content"])
+	}
+	tools, _ := root["tools"].([]any)
+	for _, raw := range tools {
+		tool, _ := raw.(map[string]any)
+		total += tokencount.Text(stringValue(tool["name"])) +
+			tokencount.Text(stringValue(tool["description"])) +
+			countJSONTokens(tool["input_schema"])
+	}
+	if len(tools) > 0 {
+		total += claudeToolsOverheadTokens
+	}
+	return total
+}
+
+// countClaudeContent counts a Claude system prompt, message content, or tool
+// result content, each of which is either a string or a list of blocks.
+func countClaudeContent(content any) int {
+	switch content := content.(type) {
+	case string:
+		return tokencount.Text(content)
+	case []any:
+		total := 0
+		for _, raw := range content {
+			block, _ := raw.(map[string]any)
+			if block == nil {
+				continue
+			}
+			switch stringValue(block["type"]) {
+			case "image":
+				source, _ := block["source"].(map[string]any)
+				total += tokencount.ClaudeImage(imageSourceSize(source))
+			case "tool_use", "server_tool_use":
+				total += tokencount.Text(stringValue(block["name"])) + countJSONTokens(block["input"])
+			case "tool_result":
+				total += countClaudeContent(block["content"])
+			case "thinking":
+				total += tokencount.Text(stringValue(block["thinking"]))
+			case "document":
+				source, _ := block["source"].(map[string]any)
+				switch stringValue(source["type"]) {
+				case "text":
+					total += tokencount.Text(stringValue(source["data"]))
+				case "content":
+					total += countClaudeContent(source["content"])
+				}
+			default:
+				total += tokencount.Text(stringValue(block["text"]))
+			}
+		}
+		return total
+	default:
+		return 0
+	}
+}
+
+// imageSourceSize returns the dimensions of a base64 Claude image source, or
+// zeros when they cannot be determined.
+func imageSourceSize(source map[string]any) (int, int) {
+	if stringValue(source["type"]) != "base64" {
+		return 0, 0
+	}
+	return base64ImageSize(stringValue(source["data"]))
+}
+
+func countGeminiTokens(root map[string]any) int {
+	total := countGeminiContent(root["systemInstruction"])
+	contents, _ := root["contents"].([]any)
+	for _, content := range contents {
+		total += countGeminiContent(content)
+	}
+	tools, _ := root["tools"].([]any)
+	for _, raw := range tools {
+		tool, _ := raw.(map[string]any)
+		declarations, _ := tool["functionDeclarations"].([]any)
+		for _, declaration := range declarations {
+			total += countJSONTokens(declaration)
+		}
+	}
+	return total
+}
+
+func countGeminiContent(raw any) int {
+	content, _ := raw.(map[string]any)
+	parts, _ := content["parts"].([]any)
+	total := 0
+	for _, rawPart := range parts {
+		part, _ := rawPart.(map[string]any)
+		if part == nil {
+			continue
+		}
+		switch {
+		case part["inlineData"] != nil:
+			data, _ := part["inlineData"].(map[string]any)
+			if strings.HasPrefix(stringValue(data["mimeType"]), "image/") {
+				total += tokencount.GeminiImage(base64ImageSize(stringValue(data["data"])))
+			}
+		case part["fileData"] != nil:
+			data, _ := part["fileData"].(map[string]any)
+			if strings.HasPrefix(stringValue(data["mimeType"]), "image/") {
+				total += tokencount.GeminiImageTileTokens
+			}
+		case part["functionCall"] != nil:
+			call, _ := part["functionCall"].(map[string]any)
+			total += tokencount.Text(stringValue(call["name"])) + countJSONTokens(call["args"])
+		case part["functionResponse"] != nil:
+			response, _ := part["functionResponse"].(map[string]any)
+			total += tokencount.Text(stringValue(response["name"])) + countJSONTokens(response["response"])
+		default:
+			total += tokencount.Text(stringValue(part["text"]))
+		}
+	}
+	return total
+}
+
+func base64ImageSize(data string) (int, int) {
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return 0, 0
+	}
+	width, height, _ := tokencount.ImageSize(decoded)
+	return width, height
+}
+
+func countJSONTokens(value any) int {
+	if value == nil {
+		return 0
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return 0
+	}
+	return tokencount.Text(string(data))
+}
+
+// countTokensFallbackStatus reports whether an upstream count_tokens status
+// means the provider cannot count the request, so fallback mode may answer
+// locally: the endpoint is missing or the upstream failed. Authentication,
+// quota and validation errors are passed through to the client.
+func countTokensFallbackStatus(status int) bool {
+	switch status {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	}
+	return status >= http.StatusInternalServerError
+}
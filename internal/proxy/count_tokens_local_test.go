@@ -0,0 +1,158 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/tokencount"
+)
+
+func TestLocalCountTokens(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 200, 200))); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	imageData := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	claude := RequestContext{Family: ProtocolFamilyClaude, Capability: CapabilityClaudeCountTokens}
+	root := map[string]any{
+		"system": "Be brief.",
+		"messages": []any{
+			map[string]any{"role": "user", "content": []any{
+				map[string]any{"type": "text", "text": "Describe"},
+				map[string]any{"type": "image", "source": map[string]any{"type": "base64", "media_type": "image/png", "data": imageData}},
+			}},
+		},
+	}
+	got, ok := localCountTokens(claude, root)
+	want := tokencount.Text("Be brief.") + claudeMessageOverheadTokens + tokencount.Text("Describe") + tokencount.ClaudeImage(200, 200)
+	if !ok || got != want {
+		t.Fatalf("claude tokens = %d, %v; want %d", got, ok, want)
+	}
+
+	root["tools"] = []any{map[string]any{"name": "lookup", "input_schema": map[string]any{"type": "object"}}}
+	if withTools, _ := localCountTokens(claude, root); withTools <= got+claudeToolsOverheadTokens {
+		t.Fatalf("tools were not counted: %d", withTools)
+	}
+
+	gemini := RequestContext{Family: ProtocolFamilyGemini, Capability: CapabilityGeminiCountTokens}
+	nested := map[string]any{"generateContentRequest": map[string]any{
+		"contents": []any{map[string]any{"role": "user", "parts": []any{
+			map[string]any{"text": "Hello"},
+			map[string]any{"inlineData": map[string]any{"mimeType": "image/png", "data": imageData}},
+		}}},
+	}}
+	if got, ok := localCountTokens(gemini, nested); !ok || got != tokencount.Text("Hello")+tokencount.GeminiImageTileTokens {
+		t.Fatalf("gemini tokens = %d, %v", got, ok)
+	}
+
+	if _, ok := localCountTokens(claude, map[string]any{"x": 1}); ok {
+		t.Fatalf("counted a body without messages")
+	}
+}
+
+func TestEstimatePromptTokens(t *testing.T) {
+	t.Parallel()
+
+	claudeRoot := map[string]any{
+		"system": "You are a coding agent. Follow the repository conventions.",
+		"tools":  []any{map[string]any{"name": "bash", "description": "Run a command", "input_schema": map[string]any{"type": "object"}}},
+		"messages": []any{
+			map[string]any{"role": "assistant", "content": []any{map[string]any{"type": "tool_use", "name": "bash", "input": map[string]any{"command": "ls"}}}},
+			map[string]any{"role": "user", "content": []any{map[string]any{"type": "tool_result", "content": "go.mod\ninternal"}}},
+		},
+	}
+	counted, _ := localCountTokens(RequestContext{Family: ProtocolFamilyClaude, Capability: CapabilityClaudeCountTokens}, claudeRoot)
+	if got := estimatePromptTokens(RequestContext{Family: ProtocolFamilyClaude, Capability: CapabilityClaudeMessages}, claudeRoot); got != int64(counted) || got <= claudeToolsOverheadTokens {
+		t.Fatalf("claude estimate = %d, want the count_tokens estimate %d", got, counted)
+	}
+
+	openAI := RequestContext{Family: ProtocolFamilyOpenAI, Capability: CapabilityOpenAIChatCompletions}
+	chat := map[string]any{"messages": []any{
+		map[string]any{"role": "system", "content": "be brief"},
+		map[string]any{"role": "user", "content": []any{map[string]any{"type": "text", "text": "say hello"}}},
+	}}
+	if got, want := estimatePromptTokens(openAI, chat), int64(tokencount.Text("be brief")+tokencount.Text("say hello")+2*openAIMessageOverheadTokens); got != want {
+		t.Fatalf("chat estimate = %d, want %d", got, want)
+	}
+	responses := map[string]any{"instructions": "be brief", "input": []any{
+		map[string]any{"type": "message", "role": "user", "content": []any{map[string]any{"type": "input_text", "text": "say hello"}}},
+		map[string]any{"type": "function_call_output", "output": "done"},
+	}}
+	if got, want := estimatePromptTokens(openAI, responses), int64(tokencount.Text("be brief")+openAIMessageOverheadTokens+tokencount.Text("say hello")+tokencount.Text("done")); got != want {
+		t.Fatalf("responses estimate = %d, want %d", got, want)
+	}
+}
+
+func TestClaudeCountTokens_FallsBackToLocalEstimate(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name      string
+		mode      config.CountTokensMode
+		manual    bool
+		upstream  int
+		wantCalls int
+		wantLocal bool
+	}{
+		{name: "upstream answers", upstream: http.StatusOK, wantCalls: 1},
+		{name: "upstream lacks endpoint", upstream: http.StatusNotFound, wantCalls: 1, wantLocal: true},
+		{name: "upstream server error", upstream: http.StatusBadGateway, wantCalls: 1, wantLocal: true},
+		{name: "bad key passes through", upstream: http.StatusUnauthorized, wantCalls: 1},
+		{name: "rate limit passes through", upstream: http.StatusTooManyRequests, wantCalls: 1},
+		{name: "pinned provider lacks endpoint", manual: true, upstream: http.StatusNotImplemented, wantCalls: 1, wantLocal: true},
+		{name: "pinned provider answers", manual: true, upstream: http.StatusOK, wantCalls: 1},
+		{name: "local mode skips upstream", mode: config.CountTokensModeLocal, upstream: http.StatusOK, wantLocal: true},
+		{name: "upstream mode passes errors through", mode: config.CountTokensModeUpstream, upstream: http.StatusNotFound, wantCalls: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			clientMode, pinned := config.ClientModeAuto, ""
+			if tt.manual {
+				clientMode, pinned = config.ClientModeManual, "p1"
+			}
+			cp := newClientProxy(ClientClaude, clientMode, pinned, []config.Provider{
+				{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1},
+			}, time.Hour, 0, testResponseHeaderTimeout, circuitBreakerConfig{}, nil)
+			cp.applyRoutingRuntimeSettings(routingRuntimeSettingsFromConfig(config.RoutingConfig{CountTokens: tt.mode}))
+			calls := 0
+			cp.httpClient.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				calls++
+				h := make(http.Header)
+				h.Set("Content-Type", "application/json")
+				return newResponse(tt.upstream, h, `{"input_tokens":99}`), nil
+			})
+
+			body := `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hello there"}]}`
+			req := httptest.NewRequest(http.MethodPost, "http://proxy/clipal/v1/messages/count_tokens", strings.NewReader(body))
+			req = withRequestContext(req, requestContextForClientPath(ClientClaude, "/v1/messages/count_tokens", true))
+			rr := httptest.NewRecorder()
+			cp.forwardCountTokensSingleShot(rr, req, "/v1/messages/count_tokens")
+
+			if calls != tt.wantCalls {
+				t.Fatalf("upstream calls = %d, want %d", calls, tt.wantCalls)
+			}
+			local := strings.TrimSpace(rr.Body.String()) != `{"input_tokens":99}`
+			if local != tt.wantLocal {
+				t.Fatalf("status=%d body=%s, want local=%v", rr.Code, rr.Body.String(), tt.wantLocal)
+			}
+			if local && (rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"input_tokens":`)) {
+				t.Fatalf("local answer status=%d body=%s", rr.Code, rr.Body.String())
+			}
+			if cp.isDeactivated(0) {
+				t.Fatalf("count_tokens changed provider health")
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// proxyErrorDetail is the Clipal-specific context attached to errors that the
+// proxy generates locally (as opposed to upstream errors, which are relayed).
+type proxyErrorDetail struct {
+	Reason             string   `json:"reason,omitempty"`
+	RetryAfterSeconds  int      `json:"retry_after_seconds,omitempty"`
+	AvailableAt        string   `json:"available_at,omitempty"`
+	AttemptedProviders []string `json:"attempted_providers,omitempty"`
+}
+
+func (d proxyErrorDetail) empty() bool {
+	return d.Reason == "" && d.RetryAfterSeconds == 0 && d.AvailableAt == "" && len(d.AttemptedProviders) == 0
+}
+
+// withRetryAfter records when the proxy expects a provider to be available again.
+func (d proxyErrorDetail) withRetryAfter(wait time.Duration) proxyErrorDetail {
+	if wait <= 0 {
+		return d
+	}
+	d.RetryAfterSeconds = retryAfterSeconds(wait)
+	d.AvailableAt = time.Now().Add(wait).UTC().Format(time.RFC3339)
+	return d
+}
+
+func retryAfterSeconds(wait time.Duration) int {
+	secs := int(wait/time.Second) + 1
+	if secs < 1 {
+		secs = 1
+	}
+	return secs
+}
+
+func writeProxyError(w http.ResponseWriter, req *http.Request, msg string, status int) {
+	writeProxyErrorDetail(w, req, nil, msg, status, proxyErrorDetail{})
+}
+
+// writeProxyErrorDetail writes a locally generated error in the native error
+// shape of the request's protocol family. Streaming requests receive the same
+// error as a single SSE error event so stream parsers can surface it.
+func writeProxyErrorDetail(w http.ResponseWriter, req *http.Request, payload *requestPayload, msg string, status int, detail proxyErrorDetail) {
+	// Only attach Retry-After for retryable errors we generate locally.
+	// Upstream responses are streamed through as-is.
+	if status == http.StatusTooManyRequests || shouldRetry(status) {
+		wait := time.Second
+		if detail.RetryAfterSeconds > 0 {
+			wait = time.Duration(detail.RetryAfterSeconds-1) * time.Second
+		}
+		setRetryAfterHeader(w, wait)
+	}
+
+	family := errorFamilyForRequest(req)
+	body := proxyErrorBody(family, msg, status, detail)
+	data, err := json.Marshal(body)
+	if err != nil {
+		http.Error(w, msg, status)
+		return
+	}
+
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("X-Content-Type-Options", "nosniff")
+	if wantsStreamingError(req, payload) {
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		w.WriteHeader(status)
+		if family == ProtocolFamilyGemini {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", data)
+		} else {
+			_, _ = fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+		}
+		return
+	}
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+	_, _ = w.Write([]byte("\n"))
+}
+
+// errorFamilyForRequest picks the error shape for a request. Requests that
+// were rejected before routing fall back to client hints, then to the OpenAI
+// shape, which is the most widely understood.
+func errorFamilyForRequest(req *http.Request) ProtocolFamily {
+	if req == nil {
+		return ProtocolFamilyOpenAI
+	}
+	if requestCtx, ok := requestContextFromRequest(req); ok && requestCtx.Family != "" {
+		return requestCtx.Family
+	}
+	switch {
+	case req.Header.Get("anthropic-version") != "":
+		return ProtocolFamilyClaude
+	case req.Header.Get("x-goog-api-key") != "" || req.URL.Query().Get("key") != "":
+		return ProtocolFamilyGemini
+	default:
+		return ProtocolFamilyOpenAI
+	}
+}
+
+func wantsStreamingError(req *http.Request, payload *requestPayload) bool {
+	if req == nil {
+		return false
+	}
+	if requestCtx, ok := requestContextFromRequest(req); ok && requestCtx.Capability == CapabilityGeminiStreamGenerate {
+		return strings.EqualFold(req.URL.Query().Get("alt"), "sse")
+	}
+	if root := payload.jsonRoot(); root != nil {
+		stream, _ := root["stream"].(bool)
+		return stream
+	}
+	return strings.Contains(strings.ToLower(req.Header.Get("Accept")), "text/event-stream")
+}
+
+func proxyErrorBody(family ProtocolFamily, msg string, status int, detail proxyErrorDetail) map[string]any {
+	var clipal any
+	if !detail.empty() {
+		clipal = detail
+	}
+	switch family {
+	case ProtocolFamilyClaude:
+		errObj := map[string]any{"type": claudeErrorType(status), "message": msg}
+		if clipal != nil {
+			errObj["clipal"] = clipal
+		}
+		return map[string]any{"type": "error", "error": errObj}
+	case ProtocolFamilyGemini:
+		return map[string]any{"error": map[string]any{
+			"code":    status,
+			"message": msg,
+			"status":  googleRPCStatus(status),
+			"details": googleRPCDetails(detail),
+		}}
+	default:
+		errObj := map[string]any{"message": msg, "type": openAIErrorType(status), "param": nil, "code": nil}
+		if detail.Reason != "" {
+			errObj["code"] = detail.Reason
+		}
+		if clipal != nil {
+			errObj["clipal"] = clipal
+		}
+		return map[string]any{"error": errObj}
+	}
+}
+
+func claudeErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable, 529:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
+
+func openAIErrorType(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return "authentication_error"
+	case status == http.StatusForbidden:
+		return "permission_error"
+	case status == http.StatusNotFound:
+		return "not_found_error"
+	case status == http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case status >= 500:
+		return "server_error"
+	default:
+		return "invalid_request_error"
+	}
+}
+
+func googleRPCStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	default:
+		return "INTERNAL"
+	}
+}
+
+// googleRPCDetails maps the Clipal detail onto the standard google.rpc
+// ErrorInfo and RetryInfo messages.
+func googleRPCDetails(detail proxyErrorDetail) []any {
+	details := []any{}
+	if detail.empty() {
+		return details
+	}
+	metadata := map[string]string{}
+	if detail.AvailableAt != "" {
+		metadata["available_at"] = detail.AvailableAt
+	}
+	if len(detail.AttemptedProviders) > 0 {
+		metadata["attempted_providers"] = strings.Join(detail.AttemptedProviders, ",")
+	}
+	reason := detail.Reason
+	if reason == "" {
+		reason = "proxy_error"
+	}
+	details = append(details, map[string]any{
+		"@type":    "type.googleapis.com/google.rpc.ErrorInfo",
+		"reason":   strings.ToUpper(reason),
+		"domain":   "clipal",
+		"metadata": metadata,
+	})
+	if detail.RetryAfterSeconds > 0 {
+		details = append(details, map[string]any{
+			"@type":      "type.googleapis.com/google.rpc.RetryInfo",
+			"retryDelay": strconv.Itoa(detail.RetryAfterSeconds) + "s",
+		})
+	}
+	return details
+}
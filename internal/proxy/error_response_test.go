@@ -0,0 +1,150 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+)
+
+func TestHandleAllUnavailable_WritesClaudeErrorWithAvailability(t *testing.T) {
+	t.Parallel()
+
+	cp := newClientProxy(ClientClaude, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1},
+	}, time.Hour, 0, testResponseHeaderTimeout, circuitBreakerConfig{})
+	cp.deactivated[0] = providerDeactivation{until: time.Now().Add(30 * time.Second), reason: "server"}
+
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/claudecode/v1/messages", nil)
+	req = withRequestContext(req, requestContextForClientPath(ClientClaude, "/v1/messages", false))
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, req, "/v1/messages")
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status: got %d want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/json" {
+		t.Fatalf("content-type: got %q", got)
+	}
+	var body struct {
+		Type  string `json:"type"`
+		Error struct {
+			Type    string           `json:"type"`
+			Message string           `json:"message"`
+			Clipal  proxyErrorDetail `json:"clipal"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v body=%s", err, rr.Body.String())
+	}
+	if body.Type != "error" || body.Error.Type != "overloaded_error" || body.Error.Clipal.Reason != "all_providers_unavailable" {
+		t.Fatalf("unexpected body: %s", rr.Body.String())
+	}
+	if body.Error.Clipal.RetryAfterSeconds != 30 && body.Error.Clipal.RetryAfterSeconds != 31 {
+		t.Fatalf("retry_after_seconds: got %d", body.Error.Clipal.RetryAfterSeconds)
+	}
+	if _, err := time.Parse(time.RFC3339, body.Error.Clipal.AvailableAt); err != nil {
+		t.Fatalf("available_at: %v", err)
+	}
+	if got := rr.Header().Get("Retry-After"); got == "" {
+		t.Fatalf("expected Retry-After header")
+	}
+}
+
+func TestForwardWithFailover_AllFailedStreamsOpenAIErrorEvent(t *testing.T) {
+	t.Parallel()
+
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return newResponse(http.StatusBadGateway, nil, "bad gateway"), nil
+	})
+	cp := newClientProxy(ClientOpenAI, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1},
+		{Name: "p2", BaseURL: "http://p2", APIKey: "k2", Priority: 2},
+	}, 0, 0, testResponseHeaderTimeout, circuitBreakerConfig{})
+	cp.httpClient.Transport = rt
+
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/codex/v1/chat/completions", bytes.NewReader([]byte(`{"stream":true}`)))
+	req = withRequestContext(req, requestContextForClientPath(ClientOpenAI, "/v1/chat/completions", false))
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, req, "/v1/chat/completions")
+
+	if got := rr.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("content-type: got %q body=%s", got, rr.Body.String())
+	}
+	got := rr.Body.String()
+	if !strings.HasPrefix(got, "event: error\ndata: ") || !strings.HasSuffix(got, "\n\n") {
+		t.Fatalf("expected a single SSE error event, got %q", got)
+	}
+	var body struct {
+		Error struct {
+			Type   string           `json:"type"`
+			Code   string           `json:"code"`
+			Clipal proxyErrorDetail `json:"clipal"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(got, "event: error\ndata: "))), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Error.Type != "server_error" || body.Error.Code != "all_providers_failed" {
+		t.Fatalf("unexpected error: %+v", body.Error)
+	}
+	if providers := strings.Join(body.Error.Clipal.AttemptedProviders, ","); providers != "p1,p2" {
+		t.Fatalf("attempted_providers: got %q want %q", providers, "p1,p2")
+	}
+}
+
+func TestProxyErrorBody_GeminiUsesGoogleRPCStatus(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/gemini/v1beta/models/m:generateContent", nil)
+	req = withRequestContext(req, requestContextForClientPath(ClientGemini, "/v1beta/models/m:generateContent", false))
+	writeProxyErrorDetail(rr, req, nil, "All providers are rate limited; retry later", http.StatusTooManyRequests, proxyErrorDetail{
+		Reason:             "all_providers_rate_limited",
+		AttemptedProviders: []string{"p1"},
+	}.withRetryAfter(4*time.Second))
+
+	var body struct {
+		Error struct {
+			Code    int              `json:"code"`
+			Status  string           `json:"status"`
+			Details []map[string]any `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Error.Code != http.StatusTooManyRequests || body.Error.Status != "RESOURCE_EXHAUSTED" || len(body.Error.Details) != 2 {
+		t.Fatalf("unexpected body: %s", rr.Body.String())
+	}
+	if body.Error.Details[0]["reason"] != "ALL_PROVIDERS_RATE_LIMITED" || body.Error.Details[1]["retryDelay"] != "5s" {
+		t.Fatalf("unexpected details: %v", body.Error.Details)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "5" {
+		t.Fatalf("Retry-After: got %q want %q", got, "5")
+	}
+}
+
+func TestUnknownEndpoint_UsesClientHintForErrorShape(t *testing.T) {
+	t.Parallel()
+
+	router := newUnifiedIngressTestRouter()
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/unknown/v1/messages", nil)
+	req.Header.Set("anthropic-version", "2023-06-01")
+	router.handleRequest(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("status: got %d want %d", rr.Code, http.StatusNotFound)
+	}
+	got := rr.Body.String()
+	if !strings.HasPrefix(got, `{"error":{"message":`) || !strings.Contains(got, `"type":"not_found_error"`) || !strings.HasSuffix(got, `"type":"error"}`+"\n") {
+		t.Fatalf("expected Claude not_found_error envelope, got %q", got)
+	}
+}
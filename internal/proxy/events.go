@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+)
+
+// EventType names a runtime event published to management API subscribers.
+type EventType string
+
+const (
+	EventRequestStarted      EventType = "request_started"
+	EventRequestCompleted    EventType = "request_completed"
+	EventAttemptFailed       EventType = "attempt_failed"
+	EventProviderSwitched    EventType = "provider_switched"
+	EventProviderDeactivated EventType = "provider_deactivated"
+	EventProviderReactivated EventType = "provider_reactivated"
+	EventCircuitChanged      EventType = "circuit_changed"
+	EventOAuthRefreshed      EventType = "oauth_refreshed"
+	EventConfigReloaded      EventType = "config_reloaded"
+)
+
+// EventTypes lists every event type in a stable order.
+var EventTypes = []EventType{
+	EventRequestStarted,
+	EventRequestCompleted,
+	EventAttemptFailed,
+	EventProviderSwitched,
+	EventProviderDeactivated,
+	EventProviderReactivated,
+	EventCircuitChanged,
+	EventOAuthRefreshed,
+	EventConfigReloaded,
+}
+
+// Event is one runtime occurrence. Fields that do not apply to an event type
+// are left empty.
+type Event struct {
+	ID         uint64    `json:"id"`
+	Type       EventType `json:"type"`
+	At         time.Time `json:"at"`
+	ClientType string    `json:"client_type,omitempty"`
+	// RequestID ties the started, attempt, and completed events of one client
+	// request together.
+	RequestID  string `json:"request_id,omitempty"`
+	Capability string `json:"capability,omitempty"`
+	Provider   string `json:"provider,omitempty"`
+	// Key is the 1-based key index for key-level deactivations.
+	Key        int    `json:"key,omitempty"`
+	Model      string `json:"model,omitempty"`
+	Status     int    `json:"status,omitempty"`
+	Result     string `json:"result,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	From       string `json:"from,omitempty"`
+	To         string `json:"to,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+	// Until is when a deactivated provider or key becomes eligible again.
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// EventFilter selects events for a subscriber. Empty fields match everything.
+type EventFilter struct {
+	Types      []EventType
+	ClientType string
+	// Provider also matches either side of a provider switch.
+	Provider string
+}
+
+// Match reports whether e passes the filter.
+func (f EventFilter) Match(e Event) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, e.Type) {
+		return false
+	}
+	if f.ClientType != "" && f.ClientType != e.ClientType {
+		return false
+	}
+	if f.Provider != "" && f.Provider != e.Provider && f.Provider != e.From && f.Provider != e.To {
+		return false
+	}
+	return true
+}
+
+const (
+	// eventHistorySize is how many recent events are kept for subscribers
+	// that connect late or reconnect.
+	eventHistorySize = 512
+	// eventSubscriberBuffer bounds how far a slow subscriber may fall behind
+	// before events are dropped for it.
+	eventSubscriberBuffer = 256
+)
+
+// eventBus fans runtime events out to subscribers and keeps a ring buffer of
+// recent events. Publishing never blocks on a subscriber.
+type eventBus struct {
+	mu      sync.Mutex
+	nextID  uint64
+	history []Event
+	head    int
+	subs    map[*eventSubscriber]struct{}
+}
+
+type eventSubscriber struct {
+	filter EventFilter
+	ch     chan Event
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		history: make([]Event, 0, eventHistorySize),
+		subs:    make(map[*eventSubscriber]struct{}),
+	}
+}
+
+func (b *eventBus) publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	e.ID = b.nextID
+	if len(b.history) < eventHistorySize {
+		b.history = append(b.history, e)
+	} else {
+		b.history[b.head] = e
+		b.head = (b.head + 1) % eventHistorySize
+	}
+	for sub := range b.subs {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+		}
+	}
+}
+
+// subscribe returns the buffered events after afterID that match filter, and
+// a channel of later ones. cancel must be called to release the channel.
+func (b *eventBus) subscribe(filter EventFilter, afterID uint64) (recent []Event, events <-chan Event, cancel func()) {
+	if b == nil {
+		return nil, nil, func() {}
+	}
+	sub := &eventSubscriber{filter: filter, ch: make(chan Event, eventSubscriberBuffer)}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range b.history {
+		e := b.history[(b.head+i)%len(b.history)]
+		if e.ID > afterID && filter.Match(e) {
+			recent = append(recent, e)
+		}
+	}
+	b.subs[sub] = struct{}{}
+	var once sync.Once
+	cancel = func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subs, sub)
+		})
+	}
+	return recent, sub.ch, cancel
+}
+
+// SubscribeEvents streams runtime events matching filter. recent holds the
+// buffered events with an ID above afterID, oldest first; pass 0 to receive
+// the whole buffer. cancel must be called when the subscriber goes away.
+func (r *Router) SubscribeEvents(filter EventFilter, afterID uint64) (recent []Event, events <-chan Event, cancel func()) {
+	return r.events.subscribe(filter, afterID)
+}
+
+func (cp *ClientProxy) publishEvent(e Event) {
+	if cp == nil || cp.events == nil {
+		return
+	}
+	e.ClientType = string(cp.clientType)
+	cp.events.publish(e)
+}
+
+// noteAttemptFailure ends the request's latest upstream attempt with the
+// classification that made the proxy move on, and announces it.
+func (cp *ClientProxy) noteAttemptFailure(req *http.Request, provider string, status int, reason string) {
+	trace := requestTraceFromRequest(req)
+	trace.noteAttemptFailure(status, reason)
+	cp.publishEvent(Event{
+		Type:      EventAttemptFailed,
+		RequestID: trace.requestID(),
+		Provider:  provider,
+		Status:    status,
+		Reason:    reason,
+	})
+}
+
+// oauthRefreshObserver publishes credential refreshes made by the OAuth
+// service.
+func (b *eventBus) oauthRefreshObserver(provider config.OAuthProvider, ref string, err error) {
+	e := Event{Type: EventOAuthRefreshed, Detail: string(provider) + " credential " + ref, Result: "refreshed"}
+	if err != nil {
+		e.Result = "failed"
+		e.Reason = err.Error()
+	}
+	b.publish(e)
+}
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+)
+
+func TestEventBusReplaysRecentEventsAndFilters(t *testing.T) {
+	t.Parallel()
+
+	bus := newEventBus()
+	for i := 0; i < eventHistorySize+2; i++ {
+		bus.publish(Event{Type: EventConfigReloaded})
+	}
+	bus.publish(Event{Type: EventProviderSwitched, ClientType: "claude", From: "p1", To: "p2"})
+
+	recent, _, cancel := bus.subscribe(EventFilter{}, 0)
+	cancel()
+	if len(recent) != eventHistorySize || recent[0].ID != 4 || recent[len(recent)-1].ID != eventHistorySize+3 {
+		t.Fatalf("ring buffer kept %d events from %d to %d", len(recent), recent[0].ID, recent[len(recent)-1].ID)
+	}
+
+	recent, live, cancel := bus.subscribe(EventFilter{ClientType: "claude", Provider: "p2"}, 0)
+	defer cancel()
+	if len(recent) != 1 || recent[0].Type != EventProviderSwitched {
+		t.Fatalf("filtered replay = %#v", recent)
+	}
+	bus.publish(Event{Type: EventProviderDeactivated, ClientType: "openai", Provider: "p2"})
+	bus.publish(Event{Type: EventProviderDeactivated, ClientType: "claude", Provider: "p2"})
+	select {
+	case e := <-live:
+		if e.ClientType != "claude" || e.Type != EventProviderDeactivated {
+			t.Fatalf("live event = %#v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no live event")
+	}
+}
+
+func TestForwardWithFailover_PublishesRequestEvents(t *testing.T) {
+	t.Parallel()
+
+	cp := newClientProxy(ClientOpenAI, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1},
+		{Name: "p2", BaseURL: "http://p2", APIKey: "k2", Priority: 2},
+	}, time.Hour, 0, testResponseHeaderTimeout, circuitBreakerConfig{enabled: true, failureThreshold: 1, successThreshold: 1, openTimeout: time.Minute, halfOpenMaxInFlight: 1})
+	cp.events = newEventBus()
+	cp.httpClient.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host == "p1" {
+			return newResponse(http.StatusBadGateway, nil, "bad gateway"), nil
+		}
+		h := make(http.Header)
+		h.Set("Content-Type", "application/json")
+		return newResponse(http.StatusOK, h, `{"id":"resp_1"}`), nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/clipal/v1/responses", bytes.NewReader([]byte(`{"model":"gpt-5","input":"hello"}`)))
+	req = withRequestTrace(withRequestContext(req, requestContextForClientPath(ClientOpenAI, "/v1/responses", true)))
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, req, "/v1/responses")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", rr.Code, rr.Body.String())
+	}
+
+	events, _, cancel := cp.events.subscribe(EventFilter{}, 0)
+	cancel()
+	byType := make(map[EventType]Event)
+	for _, e := range events {
+		if e.ClientType != string(ClientOpenAI) {
+			t.Fatalf("event without client type: %#v", e)
+		}
+		byType[e.Type] = e
+	}
+	requestID := requestTraceFromRequest(req).requestID()
+	if e := byType[EventAttemptFailed]; e.Provider != "p1" || e.Status != http.StatusBadGateway || e.RequestID != requestID {
+		t.Fatalf("attempt_failed = %#v", e)
+	}
+	if e := byType[EventCircuitChanged]; e.Provider != "p1" || e.From != "closed" || e.To != "open" {
+		t.Fatalf("circuit_changed = %#v", e)
+	}
+	if e := byType[EventProviderSwitched]; e.From != "p1" || e.To != "p2" {
+		t.Fatalf("provider_switched = %#v", e)
+	}
+	if e := byType[EventRequestCompleted]; e.Provider != "p2" || e.Status != http.StatusOK || e.Model != "gpt-5" || e.RequestID != requestID {
+		t.Fatalf("request_completed = %#v", e)
+	}
+}
@@ -57,6 +57,16 @@ func classifyUpstreamFailure(status int, hdr http.Header, body []byte, truncated
 	}
 }
 
+// inspectsUpstreamFailure reports whether the built-in classification reads the
+// response body for this status code.
+func inspectsUpstreamFailure(status int) bool {
+	return status == http.StatusUnauthorized ||
+		status == http.StatusForbidden ||
+		status == http.StatusPaymentRequired ||
+		status == http.StatusTooManyRequests ||
+		shouldRetry(status)
+}
+
 func classify429(body []byte) (action failureAction, reason string) {
 	var v any
 	if err := json.Unmarshal(body, &v); err != nil {
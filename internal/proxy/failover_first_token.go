@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/telemetry"
+)
+
+var errFirstTokenTimeout = errors.New("upstream first token timeout")
+
+// maxFirstTokenPrelude caps how much keepalive/metadata output is held back
+// while waiting for the first content event. Past this the stream is committed.
+const maxFirstTokenPrelude = protocolScanWindow
+
+func isFirstTokenTimeout(ctx context.Context, err error) bool {
+	if err == nil {
+		return errors.Is(context.Cause(ctx), errFirstTokenTimeout)
+	}
+	if errors.Is(err, errFirstTokenTimeout) {
+		return true
+	}
+	if errors.Is(err, context.Canceled) && errors.Is(context.Cause(ctx), errFirstTokenTimeout) {
+		return true
+	}
+	return false
+}
+
+// firstTokenTimeoutFor returns the first-token deadline for a request, or 0 when
+// the attempt should be committed on its first byte as usual. The deadline only
+// matters when there is another provider to fail over to.
+func (cp *ClientProxy) firstTokenTimeoutFor(req *http.Request) time.Duration {
+	if cp.mode == config.ClientModeManual || len(cp.providers) < 2 {
+		return 0
+	}
+	requestCtx, ok := requestContextFromRequest(req)
+	if !ok {
+		return 0
+	}
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+	if d, ok := cp.routing.firstTokenTimeouts[requestCtx.Capability]; ok {
+		return d
+	}
+	return cp.routing.firstTokenTimeout
+}
+
+// awaitFirstToken keeps reading the uncommitted stream until the usage extractor
+// sees a content event, the prelude grows past maxFirstTokenPrelude, or the
+// deadline expires. It returns the held bytes, the updated byte count, and the
+// read error (errFirstTokenTimeout when the deadline won).
+func (cp *ClientProxy) awaitFirstToken(resp *http.Response, attemptCtx context.Context, cancelAttempt context.CancelCauseFunc, remaining time.Duration, idleTimer *time.Timer, tracker *protocolTracker, extractor *telemetry.UsageExtractor, held []byte, total int) ([]byte, int, error) {
+	if remaining <= 0 {
+		cancelAttempt(errFirstTokenTimeout)
+		return held, total, errFirstTokenTimeout
+	}
+	deadlineTimer := time.AfterFunc(remaining, func() { cancelAttempt(errFirstTokenTimeout) })
+	buf := make([]byte, 32*1024)
+	var readErr error
+	for !extractor.SawContent() && len(held) < maxFirstTokenPrelude {
+		nr, er := resp.Body.Read(buf)
+		if nr > 0 {
+			if idleTimer != nil {
+				idleTimer.Reset(cp.upstreamIdle)
+			}
+			total += nr
+			tracker.append(buf[:nr])
+			extractor.Append(buf[:nr])
+			held = append(held, buf[:nr]...)
+		}
+		if er != nil {
+			readErr = er
+			break
+		}
+	}
+	if !deadlineTimer.Stop() && (readErr == nil || isFirstTokenTimeout(attemptCtx, readErr)) {
+		// The deadline fired (or is firing) before content could be committed.
+		readErr = errFirstTokenTimeout
+	}
+	return held, total, readErr
+}
@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+)
+
+const claudeStreamPrelude = "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":3}}}\n\nevent: ping\ndata: {\"type\":\"ping\"}\n\n"
+
+const claudeStreamContent = "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"hi\"}}\n\nevent: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+
+func newFirstTokenTestProxy(rt http.RoundTripper, timeout string) *ClientProxy {
+	cp := newClientProxy(ClientClaude, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1},
+		{Name: "p2", BaseURL: "http://p2", APIKey: "k2", Priority: 2},
+	}, time.Hour, time.Second, testResponseHeaderTimeout, circuitBreakerConfig{})
+	cp.httpClient.Transport = rt
+	cp.applyRoutingRuntimeSettings(routingRuntimeSettingsFromConfig(config.RoutingConfig{
+		FirstTokenTimeout: config.FirstTokenTimeoutConfig{
+			Capabilities: map[string]string{"claude_messages": timeout},
+		},
+	}))
+	return cp
+}
+
+func newClaudeMessagesRequest() *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/claudecode/v1/messages", bytes.NewReader([]byte(`{"stream":true}`)))
+	return withRequestContext(req, requestContextForClientPath(ClientClaude, "/v1/messages", false))
+}
+
+func TestForwardWithFailover_FirstTokenTimeoutRetriesNextProviderUncommitted(t *testing.T) {
+	t.Parallel()
+
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		h := make(http.Header)
+		h.Set("Content-Type", "text/event-stream")
+		switch r.URL.Host {
+		case "p1":
+			// Headers and keepalives arrive promptly, but no content ever follows.
+			return &http.Response{StatusCode: http.StatusOK, Header: h, Body: &ctxCancelBody{ctx: r.Context(), first: []byte(claudeStreamPrelude)}}, nil
+		case "p2":
+			return newResponse(http.StatusOK, h, claudeStreamPrelude+claudeStreamContent), nil
+		default:
+			return nil, errors.New("unexpected host")
+		}
+	})
+
+	cp := newFirstTokenTestProxy(rt, "30ms")
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, newClaudeMessagesRequest(), "/v1/messages")
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: got %d want %d", rr.Code, http.StatusOK)
+	}
+	if got, want := rr.Body.String(), claudeStreamPrelude+claudeStreamContent; got != want {
+		t.Fatalf("body: got %q want %q", got, want)
+	}
+	if got := cp.getCurrentIndex(); got != 1 {
+		t.Fatalf("currentIndex: got %d want %d", got, 1)
+	}
+	if cp.lastSwitch.Reason != "first_token_timeout" {
+		t.Fatalf("switch reason: got %q want %q", cp.lastSwitch.Reason, "first_token_timeout")
+	}
+}
+
+func TestForwardWithFailover_FirstTokenTimeoutCommitsOnceContentArrives(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		h := make(http.Header)
+		h.Set("Content-Type", "text/event-stream")
+		return newResponse(http.StatusOK, h, claudeStreamPrelude+claudeStreamContent), nil
+	})
+
+	cp := newFirstTokenTestProxy(rt, "1s")
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, newClaudeMessagesRequest(), "/v1/messages")
+
+	if got, want := rr.Body.String(), claudeStreamPrelude+claudeStreamContent; got != want {
+		t.Fatalf("body: got %q want %q", got, want)
+	}
+	if calls != 1 {
+		t.Fatalf("upstream calls: got %d want 1", calls)
+	}
+	if got := cp.getCurrentIndex(); got != 0 {
+		t.Fatalf("currentIndex: got %d want %d", got, 0)
+	}
+}
+
+func TestForwardWithFailover_FirstTokenTimeoutIgnoresNonStreamingResponses(t *testing.T) {
+	t.Parallel()
+
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		h := make(http.Header)
+		h.Set("Content-Type", "application/json")
+		return &http.Response{StatusCode: http.StatusOK, Header: h, Body: &ctxCancelBody{ctx: r.Context(), first: []byte(`{"type":"message"`)}}, nil
+	})
+
+	cp := newFirstTokenTestProxy(rt, "10ms")
+	cp.upstreamIdle = 50 * time.Millisecond
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, newClaudeMessagesRequest(), "/v1/messages")
+
+	// JSON responses are committed on the first byte; only the idle timeout can end them.
+	if got := rr.Body.String(); !strings.HasPrefix(got, `{"type":"message"`) {
+		t.Fatalf("body: got %q", got)
+	}
+	if cp.lastSwitch.Reason == "first_token_timeout" {
+		t.Fatalf("non-streaming response should not trigger the first-token timeout")
+	}
+}
+
+func TestFirstTokenTimeoutFor_CapabilityOverrideAndManualMode(t *testing.T) {
+	t.Parallel()
+
+	cp := newClientProxy(ClientOpenAI, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1},
+		{Name: "p2", BaseURL: "http://p2", APIKey: "k2", Priority: 2},
+	}, time.Hour, 0, testResponseHeaderTimeout, circuitBreakerConfig{})
+	cp.applyRoutingRuntimeSettings(routingRuntimeSettingsFromConfig(config.RoutingConfig{
+		FirstTokenTimeout: config.FirstTokenTimeoutConfig{
+			Default:      "45s",
+			Capabilities: map[string]string{"openai_responses": "2m", "openai_chat_completions": "0"},
+		},
+	}))
+
+	request := func(path string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "http://proxy/codex"+path, nil)
+		return withRequestContext(req, requestContextForClientPath(ClientOpenAI, path, false))
+	}
+	if got := cp.firstTokenTimeoutFor(request("/v1/responses")); got != 2*time.Minute {
+		t.Fatalf("responses override: got %s want 2m", got)
+	}
+	if got := cp.firstTokenTimeoutFor(request("/v1/chat/completions")); got != 0 {
+		t.Fatalf("disabled override: got %s want 0", got)
+	}
+	if got := cp.firstTokenTimeoutFor(request("/v1/completions")); got != 45*time.Second {
+		t.Fatalf("default: got %s want 45s", got)
+	}
+
+	cp.mode = config.ClientModeManual
+	if got := cp.firstTokenTimeoutFor(request("/v1/responses")); got != 0 {
+		t.Fatalf("manual mode: got %s want 0", got)
+	}
+}
@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -97,6 +98,7 @@ func keyFailureDuration(reason string, cooldown time.Duration, reactivateAfter t
 
 // forwardWithFailover forwards the request with automatic failover.
 func (cp *ClientProxy) forwardWithFailover(w http.ResponseWriter, req *http.Request, path string) {
+	req = withRequestTrace(req)
 	if cp.mode == config.ClientModeManual {
 		cp.forwardManual(w, req, path)
 		return
@@ -116,7 +118,7 @@ func (cp *ClientProxy) forwardWithFailover(w http.ResponseWriter, req *http.Requ
 
 	// This availability check does not depend on the request body, so do it
 	// before buffering potentially large prompts.
-	active, startIndex := cp.getActiveCountAndStartIndexForScope(scope, requestCtx.Capability)
+	active, startIndex := cp.getActiveCountAndStartIndexForScope(scope, requestCtx.Capability, false)
 	if active == 0 {
 		if wait, reason, ok := cp.timeUntilNextAvailable(); ok && wait > 0 {
 			result, status, detail := unavailableRequestStatus(reason)
@@ -124,11 +126,11 @@ func (cp *ClientProxy) forwardWithFailover(w http.ResponseWriter, req *http.Requ
 		} else {
 			cp.recordTerminalRequest(time.Now(), req, "", http.StatusServiceUnavailable, "all_providers_unavailable", "All providers are unavailable.")
 		}
-		if handled := cp.handleAllUnavailable(w); handled {
+		if handled := cp.handleAllUnavailable(w, req, nil, proxyErrorDetail{}); handled {
 			return
 		}
 		logger.Error("[%s] all providers unavailable", cp.clientType)
-		writeProxyError(w, "All providers are unavailable", http.StatusServiceUnavailable)
+		writeProxyErrorDetail(w, req, nil, "All providers are unavailable", http.StatusServiceUnavailable, proxyErrorDetail{Reason: "all_providers_unavailable"})
 		return
 	}
 
@@ -139,25 +141,21 @@ func (cp *ClientProxy) forwardWithFailover(w http.ResponseWriter, req *http.Requ
 		var maxErr *http.MaxBytesError
 		if errors.As(err, &maxErr) {
 			cp.recordTerminalRequest(time.Now(), req, "", http.StatusRequestEntityTooLarge, "request_rejected", "Request body too large.")
-			writeProxyError(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			writeProxyError(w, req, "Request body too large", http.StatusRequestEntityTooLarge)
 			return
 		}
 		cp.recordTerminalRequest(time.Now(), req, "", http.StatusBadRequest, "request_rejected", "Failed to read request body.")
-		writeProxyError(w, "Failed to read request body", http.StatusBadRequest)
+		writeProxyError(w, req, "Failed to read request body", http.StatusBadRequest)
 		return
 	}
 	defer func() { _ = req.Body.Close() }()
-	payload := newRequestPayload(bodyBytes)
+	payload := newClientRequestPayload(req, requestCtx, bodyBytes)
 	requestKey := payload.requestStickyKey(requestCtx)
-	if preferredIndex, preferredKeyIndex, ok := cp.resolveStickyProvider(scope, requestKey, time.Now()); ok {
-		if providerSupportsCapability(cp.providers[preferredIndex], requestCtx.Capability) &&
-			!cp.isDeactivated(preferredIndex) &&
-			cp.activeKeyCount(preferredIndex) > 0 {
-			startIndex = preferredIndex
-			if preferredKeyIndex >= 0 {
-				cp.setCurrentKeyIndexForScope(preferredIndex, preferredKeyIndex, scope)
-			}
-		}
+	stickyIndex, stickyKeyIndex, _, stickyApplied := cp.stickyStart(scope, requestCtx.Capability, requestKey, time.Now(), false)
+	if stickyApplied {
+		startIndex = stickyIndex
+	} else {
+		stickyIndex = -1
 	}
 	preferredIndex := startIndex
 
@@ -166,6 +164,7 @@ func (cp *ClientProxy) forwardWithFailover(w http.ResponseWriter, req *http.Requ
 	lastSwitchStatus := 0
 	lastFailedProvider := ""
 	attemptSummaries := make([]string, 0, active)
+	attemptedProviders := make([]string, 0, active)
 	hadUpstreamAttempt := false
 
 	for offset := 0; offset < len(cp.providers) && attempted < active; offset++ {
@@ -174,24 +173,28 @@ func (cp *ClientProxy) forwardWithFailover(w http.ResponseWriter, req *http.Requ
 		}
 
 		index := (startIndex + offset) % len(cp.providers)
-		if !providerSupportsCapability(cp.providers[index], requestCtx.Capability) ||
-			cp.isDeactivated(index) ||
-			cp.activeKeyCount(index) == 0 {
+		now := time.Now()
+		if cp.candidateSkip(index, requestCtx.Capability, now) != "" {
 			continue
 		}
-		now := time.Now()
-		allow := cp.allowCircuit(now, index)
+		allow := cp.allowCircuit(now, index, false)
 		if !allow.allowed {
 			continue
 		}
 		provider := cp.providers[index]
-		keyActive, keyStart := cp.getActiveKeyCountAndStartIndexForScope(index, scope)
+		modelChain := cp.modelFallbackChainFor(req, index, requestCtx, payload)
+		keyFrom := -1
+		if index == stickyIndex {
+			keyFrom = stickyKeyIndex
+		}
+		keyActive, keyStart := cp.getActiveKeyCountAndStartIndexForScope(index, scope, keyFrom, false)
 		if keyActive == 0 {
 			cp.releaseCircuitPermit(index, allow.usedProbe)
 			continue
 		}
 
 		attempted++
+		attemptedProviders = append(attemptedProviders, provider.Name)
 		logger.Debug("[%s] forwarding to: %s (attempt %d/%d, keys=%d)", cp.clientType, provider.Name, attempted, active, len(cp.providerKeys[index]))
 
 		providerFailed := false
@@ -200,11 +203,10 @@ func (cp *ClientProxy) forwardWithFailover(w http.ResponseWriter, req *http.Requ
 		keyExhausted := false
 		keyExhaustedReason := ""
 		keyExhaustedStatus := 0
-		if wait, ok := cp.providerBusyWait(index, time.Now()); ok {
-			if index != preferredIndex || wait > cp.routing.maxInlineWait {
-				cp.releaseCircuitPermit(index, allow.usedProbe)
-				continue
-			}
+		if wait, skip := cp.candidateBusyWait(index, preferredIndex, time.Now()); skip {
+			cp.releaseCircuitPermit(index, allow.usedProbe)
+			continue
+		} else if wait > 0 {
 			if !waitInline(req.Context(), wait) {
 				cp.releaseCircuitPermit(index, allow.usedProbe)
 				return
@@ -258,6 +260,7 @@ func (cp *ClientProxy) forwardWithFailover(w http.ResponseWriter, req *http.Requ
 					busyProbeHeld = false
 				}
 				cp.recordCircuitFailure(time.Now(), index, allow.usedProbe, "network")
+				cp.noteAttemptFailure(req, provider.Name, 0, "network")
 				cancelAttempt(nil)
 				nextIndex, nextName := nextProviderName(cp, index)
 				summary := describeAttemptFailure(provider.Name, "network", 0, true)
@@ -279,32 +282,36 @@ func (cp *ClientProxy) forwardWithFailover(w http.ResponseWriter, req *http.Requ
 			}
 			hadUpstreamAttempt = true
 
-			var (
-				action   failureAction
-				reason   string
-				msg      string
-				cooldown time.Duration
-			)
-			inspect := resp.StatusCode == http.StatusUnauthorized ||
-				resp.StatusCode == http.StatusForbidden ||
-				resp.StatusCode == http.StatusPaymentRequired ||
-				resp.StatusCode == http.StatusTooManyRequests ||
-				shouldRetry(resp.StatusCode)
-			if inspect {
-				body, truncated := readResponseBodyBytes(resp, 32*1024)
-				action, reason, msg, cooldown = classifyUpstreamFailure(resp.StatusCode, resp.Header, body, truncated)
-				if resp.StatusCode == http.StatusTooManyRequests && provider.UsesOAuth() && isOAuthCooldownReason(reason) {
-					cooldown = cp.oauthCooldownForFailure(req.Context(), provider, index, path, resp.Header, body, cooldown)
+			if modelChain.hasNext() && isModelFallbackStatus(resp.StatusCode) {
+				if body := cp.bufferResponseForRules(resp, cancelAttempt); isModelUnavailable(resp.StatusCode, body) {
+					_ = resp.Body.Close()
+					cancelAttempt(nil)
+					cp.noteAttemptFailure(req, provider.Name, resp.StatusCode, "model_unavailable")
+					from, to := modelChain.current(), modelChain.advance()
+					provider = withModelOverride(cp.providers[index], to)
+					logger.Warn("[%s] %s returned %s for model %s; retrying with fallback model %s", cp.clientType, provider.Name, formatHTTPStatus(resp.StatusCode), from, to)
+					cp.announceModelFallback(provider.Name, from, to, resp.StatusCode)
+					// Retry the same key; the `for` post statement restores keyOffset.
+					keyOffset--
+					keyTried--
+					continue
 				}
-			} else {
-				action = failureReturnToClient
 			}
 
+			failure := cp.classifyAttemptResponse(req.Context(), resp, cancelAttempt, provider, index, path)
+			action, reason, msg, cooldown := failure.action, failure.reason, failure.msg, failure.cooldown
+			keyScoped, ruleName, ruleMatched := failure.keyScoped, failure.ruleName, failure.ruleMatched
+
 			if action != failureReturnToClient {
 				_ = resp.Body.Close()
 				cancelAttempt(nil)
+				attemptTrace := requestTraceFromRequest(req)
+				cp.noteAttemptFailure(req, provider.Name, resp.StatusCode, reason)
 				lastFailedProvider = provider.Name
 				summary := describeAttemptFailure(provider.Name, reason, resp.StatusCode, false)
+				if ruleName != "" {
+					summary = fmt.Sprintf("%s (failure rule %s)", summary, ruleName)
+				}
 				attemptSummaries = append(attemptSummaries, summary)
 				if action == failureBusyRetry {
 					if busyProbeHeld {
@@ -314,6 +321,7 @@ func (cp *ClientProxy) forwardWithFailover(w http.ResponseWriter, req *http.Requ
 					cp.releaseCircuitPermit(index, allow.usedProbe)
 					step, wait := cp.nextBusyBackoff(index)
 					cp.markProviderBusy(index, reason, step, time.Now(), wait)
+					attemptTrace.noteAttemptCooldown(wait)
 					if index == preferredIndex && !busyRetried && wait > 0 && wait <= cp.routing.maxInlineWait {
 						if !waitInline(req.Context(), wait) {
 							return
@@ -341,10 +349,14 @@ func (cp *ClientProxy) forwardWithFailover(w http.ResponseWriter, req *http.Requ
 					providerFailed = true
 					break
 				}
-				if isKeyScopedFailure(reason) {
+				if keyScoped {
 					d := keyFailureDuration(reason, cooldown, cp.reactivateAfter)
+					if ruleMatched {
+						d = cooldown
+					}
 					if d > 0 {
 						cp.deactivateKeyFor(index, keyIndex, reason, resp.StatusCode, msg, d)
+						attemptTrace.noteAttemptCooldown(d)
 					}
 					nextKeyActive := cp.activeKeyCount(index)
 					if nextKeyActive > 0 {
@@ -373,7 +385,12 @@ func (cp *ClientProxy) forwardWithFailover(w http.ResponseWriter, req *http.Requ
 				nextIndex, nextName := nextProviderName(cp, index)
 				switch action {
 				case failureDeactivateAndRetryNext:
-					cp.deactivateFor(index, reason, resp.StatusCode, msg, cp.reactivateAfter)
+					d := cp.reactivateAfter
+					if ruleMatched {
+						d = cooldown
+					}
+					cp.deactivateFor(index, reason, resp.StatusCode, msg, d)
+					attemptTrace.noteAttemptCooldown(d)
 					if nextName != "" {
 						logger.Error("[%s] %s; marking provider unavailable and trying next=%s", cp.clientType, summary, nextName)
 					} else {
@@ -383,6 +400,7 @@ func (cp *ClientProxy) forwardWithFailover(w http.ResponseWriter, req *http.Requ
 					summaryWithCooldown := summary
 					if cooldown > 0 {
 						cp.deactivateFor(index, reason, resp.StatusCode, msg, cooldown)
+						attemptTrace.noteAttemptCooldown(cooldown)
 						summaryWithCooldown = fmt.Sprintf("%s; cooling down for %s", summary, cooldown)
 					}
 					if nextName != "" {
@@ -411,16 +429,25 @@ func (cp *ClientProxy) forwardWithFailover(w http.ResponseWriter, req *http.Requ
 				cp.clearProviderBusy(index)
 				now := time.Now()
 				cp.learnStickySuccessWithPayload(scope, requestCtx, requestKey, payload, success.responseBody, index, keyIndex, now)
-				success.usage = applyUsageCostSnapshot(req, requestCtx, provider, payload, success.usage)
+				success.usage = applyUsageCostSnapshot(cp.pricing, req, requestCtx, provider, payload, estimatedStreamUsage(payload, success))
 				cp.recordCompletedUsage(req, provider.Name, resp.StatusCode, success.usage, now)
 			}
 
+			if fallback := modelChain.fallbackModel(); fallback != "" {
+				// Response headers are copied from upstream on commit.
+				resp.Header.Set(headerRequestedModel, modelChain.requested)
+				resp.Header.Set(headerFallbackModel, fallback)
+			}
+
 			var result streamResult
 			if shouldSynthesizeCodexOAuthNonStreamingResponse(req, provider, path, payload) && resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
 				result = cp.synthesizeCodexOAuthNonStreamingResponseToClient(w, resp, req, attemptCtx, cancelAttempt, index, allow, onCommit, onSuccess)
 				cancelAttempt(nil)
 			} else {
-				result = cp.streamResponseToClient(w, resp, req, attemptCtx, cancelAttempt, index, allow, onCommit, onSuccess)
+				onInterrupted := func(rec *streamRecovery) (streamResult, bool) {
+					return cp.resumeInterruptedStream(req, path, payload, scope, index, rec)
+				}
+				result = cp.streamResponseToClient(w, resp, req, attemptCtx, cancelAttempt, index, allow, onCommit, onSuccess, onInterrupted)
 			}
 			if result.kind == streamFinal {
 				if result.delivery != deliveryCommittedComplete && busyProbeHeld {
@@ -430,7 +457,15 @@ func (cp *ClientProxy) forwardWithFailover(w http.ResponseWriter, req *http.Requ
 				return
 			}
 
-			if isUpstreamIdleTimeout(attemptCtx, attemptCtx.Err()) {
+			if result.cause == "first_token_timeout" {
+				summary := describeAttemptFailure(provider.Name, "first_token_timeout", 0, true)
+				attemptSummaries = append(attemptSummaries, summary)
+				logger.Warn("[%s] %s; trying next provider", cp.clientType, summary)
+				lastSwitchReason = "first_token_timeout"
+				lastSwitchStatus = 0
+				lastFailedProvider = provider.Name
+				cp.recordCircuitFailure(time.Now(), index, allow.usedProbe, "first_token_timeout")
+			} else if isUpstreamIdleTimeout(attemptCtx, attemptCtx.Err()) {
 				summary := describeAttemptFailure(provider.Name, "idle_timeout", 0, true)
 				attemptSummaries = append(attemptSummaries, summary)
 				logger.Warn("[%s] %s; trying next provider", cp.clientType, summary)
@@ -447,6 +482,7 @@ func (cp *ClientProxy) forwardWithFailover(w http.ResponseWriter, req *http.Requ
 				lastFailedProvider = provider.Name
 				cp.recordCircuitFailure(time.Now(), index, allow.usedProbe, "network")
 			}
+			cp.noteAttemptFailure(req, provider.Name, 0, lastSwitchReason)
 			if busyProbeHeld {
 				cp.releaseProviderBusyProbe(index)
 				busyProbeHeld = false
@@ -483,7 +519,7 @@ func (cp *ClientProxy) forwardWithFailover(w http.ResponseWriter, req *http.Requ
 			result, status, detail := unavailableRequestStatus(reason)
 			cp.recordTerminalRequest(time.Now(), req, "", status, result, detail)
 		}
-		if handled := cp.handleAllUnavailable(w); handled {
+		if handled := cp.handleAllUnavailable(w, req, payload, proxyErrorDetail{AttemptedProviders: attemptedProviders}); handled {
 			return
 		}
 	}
@@ -501,7 +537,10 @@ func (cp *ClientProxy) forwardWithFailover(w http.ResponseWriter, req *http.Requ
 	} else {
 		logger.Error("[%s] all providers failed", cp.clientType)
 	}
-	writeProxyError(w, "All providers failed", http.StatusServiceUnavailable)
+	writeProxyErrorDetail(w, req, payload, "All providers failed", http.StatusServiceUnavailable, proxyErrorDetail{
+		Reason:             terminalResult,
+		AttemptedProviders: attemptedProviders,
+	})
 }
 
 func waitInline(ctx context.Context, wait time.Duration) bool {
@@ -520,32 +559,73 @@ func waitInline(ctx context.Context, wait time.Duration) bool {
 
 // forwardCountTokensSingleShot forwards advisory count-token requests as a
 // single-shot passthrough. It never retries and never mutates provider health state.
+// Depending on routing.count_tokens, the request is instead answered with a local
+// estimate, either always or when the upstream cannot answer it.
 func (cp *ClientProxy) forwardCountTokensSingleShot(w http.ResponseWriter, req *http.Request, path string) {
-	if cp.mode == config.ClientModeManual {
+	req = withRequestTrace(req)
+	mode := cp.countTokensMode()
+	if mode == config.CountTokensModeLocal {
+		bodyBytes, err := io.ReadAll(req.Body)
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				writeProxyError(w, req, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			writeProxyError(w, req, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if cp.answerCountTokensLocally(w, req, newRequestPayload(bodyBytes), "local mode") {
+			return
+		}
+		// Let the upstream reject a body that cannot be counted.
+		req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	}
+	fallback := mode == config.CountTokensModeFallback
+	manual := cp.mode == config.ClientModeManual
+	if manual && !fallback {
 		cp.forwardManual(w, req, path)
 		return
 	}
 
-	cp.reactivateExpired()
+	if !manual {
+		cp.reactivateExpired()
+	}
 
 	if err := req.Context().Err(); err != nil {
 		return
 	}
 
-	index, provider, keyIndex, ok := cp.countTokensSingleShotTarget()
+	var (
+		index, keyIndex int
+		provider        config.Provider
+		ok              bool
+	)
+	if manual {
+		index, provider, keyIndex, ok = cp.pinnedCountTokensTarget(req, path)
+		if !ok {
+			// Let forwardManual report why the pinned provider cannot take it.
+			cp.forwardManual(w, req, path)
+			return
+		}
+	} else {
+		index, provider, keyIndex, ok = cp.countTokensSingleShotTarget()
+	}
 	if !ok {
+		if fallback && cp.answerCountTokensLocally(w, req, nil, "no provider available") {
+			return
+		}
 		if wait, reason, ok := cp.timeUntilNextAvailable(); ok && wait > 0 {
 			result, status, detail, userMessage := advisoryUnavailableRequestStatus(reason)
 			cp.recordTerminalRequest(time.Now(), req, "", status, result, detail)
-			setRetryAfterHeader(w, wait)
 			logger.Warn("[%s] advisory request unavailable during count_tokens. %s", cp.clientType, detail)
-			writeProxyError(w, userMessage, status)
+			writeProxyErrorDetail(w, req, nil, userMessage, status, proxyErrorDetail{Reason: result}.withRetryAfter(wait))
 			return
 		} else {
 			result, status, detail, userMessage := advisoryUnavailableRequestStatus("")
 			cp.recordTerminalRequest(time.Now(), req, "", status, result, detail)
 			logger.Warn("[%s] advisory request unavailable during count_tokens. %s", cp.clientType, detail)
-			writeProxyError(w, userMessage, status)
+			writeProxyError(w, req, userMessage, status)
 			return
 		}
 	}
@@ -555,10 +635,10 @@ func (cp *ClientProxy) forwardCountTokensSingleShot(w http.ResponseWriter, req *
 		logger.Error("[%s] failed to read request body: %v", cp.clientType, err)
 		var maxErr *http.MaxBytesError
 		if errors.As(err, &maxErr) {
-			writeProxyError(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			writeProxyError(w, req, "Request body too large", http.StatusRequestEntityTooLarge)
 			return
 		}
-		writeProxyError(w, "Failed to read request body", http.StatusBadRequest)
+		writeProxyError(w, req, "Failed to read request body", http.StatusBadRequest)
 		return
 	}
 	defer func() { _ = req.Body.Close() }()
@@ -576,12 +656,22 @@ func (cp *ClientProxy) forwardCountTokensSingleShot(w http.ResponseWriter, req *
 			return
 		}
 		logger.Warn("[%s] %s during count_tokens", cp.clientType, describeAttemptFailure(provider.Name, "network", 0, true))
+		if fallback && cp.answerCountTokensLocally(w, req, payload, "upstream request failed") {
+			return
+		}
 		cp.recordTerminalRequest(time.Now(), req, provider.Name, http.StatusBadGateway, "failed_before_response", describeAttemptFailure(provider.Name, "network", 0, true)+".")
-		writeProxyError(w, "Upstream request failed", http.StatusBadGateway)
+		writeProxyErrorDetail(w, req, payload, "Upstream request failed", http.StatusBadGateway, proxyErrorDetail{AttemptedProviders: []string{provider.Name}})
 		return
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	if fallback && countTokensFallbackStatus(resp.StatusCode) {
+		logger.Debug("[%s] %s returned status %d for count_tokens", cp.clientType, provider.Name, resp.StatusCode)
+		if cp.answerCountTokensLocally(w, req, payload, "upstream error") {
+			return
+		}
+	}
+
 	copyHeaders(w.Header(), resp.Header)
 	w.WriteHeader(resp.StatusCode)
 	n, copyErr := io.Copy(responseBodyWriter(w, req, resp), resp.Body)
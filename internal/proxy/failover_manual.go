@@ -11,6 +11,7 @@ import (
 )
 
 func (cp *ClientProxy) forwardManual(w http.ResponseWriter, req *http.Request, path string) {
+	req = withRequestTrace(req)
 	if err := req.Context().Err(); err != nil {
 		return
 	}
@@ -30,7 +31,7 @@ func (cp *ClientProxy) forwardManual(w http.ResponseWriter, req *http.Request, p
 	if index < 0 || index >= len(cp.providers) {
 		logger.Warn("[%s] manual mode enabled but pinned provider not found", cp.clientType)
 		cp.recordTerminalRequest(time.Now(), req, "", http.StatusServiceUnavailable, "all_providers_unavailable", "Pinned provider not configured.")
-		writeProxyError(w, "Pinned provider not configured", http.StatusServiceUnavailable)
+		writeProxyError(w, req, "Pinned provider not configured", http.StatusServiceUnavailable)
 		return
 	}
 
@@ -45,13 +46,13 @@ func (cp *ClientProxy) forwardManual(w http.ResponseWriter, req *http.Request, p
 			message = "Pinned provider only supports " + supportedCapabilitySummary(provider) + "."
 		}
 		cp.recordTerminalRequest(time.Now(), req, provider.Name, http.StatusServiceUnavailable, "request_rejected", message)
-		writeProxyError(w, message, http.StatusServiceUnavailable)
+		writeProxyError(w, req, message, http.StatusServiceUnavailable)
 		return
 	}
 	scope := routingScopeForRequest(req)
 	if index < 0 || index >= len(cp.providerKeys) || len(cp.providerKeys[index]) == 0 {
 		cp.recordTerminalRequest(time.Now(), req, provider.Name, http.StatusServiceUnavailable, "all_providers_unavailable", "Pinned provider has no configured API keys.")
-		writeProxyError(w, "Pinned provider has no configured API keys", http.StatusServiceUnavailable)
+		writeProxyError(w, req, "Pinned provider has no configured API keys", http.StatusServiceUnavailable)
 		return
 	}
 	keyIndex := cp.preferredKeyIndexForScope(index, scope)
@@ -62,15 +63,15 @@ func (cp *ClientProxy) forwardManual(w http.ResponseWriter, req *http.Request, p
 		var maxErr *http.MaxBytesError
 		if errors.As(err, &maxErr) {
 			cp.recordTerminalRequest(time.Now(), req, "", http.StatusRequestEntityTooLarge, "request_rejected", "Request body too large.")
-			writeProxyError(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			writeProxyError(w, req, "Request body too large", http.StatusRequestEntityTooLarge)
 			return
 		}
 		cp.recordTerminalRequest(time.Now(), req, "", http.StatusBadRequest, "request_rejected", "Failed to read request body.")
-		writeProxyError(w, "Failed to read request body", http.StatusBadRequest)
+		writeProxyError(w, req, "Failed to read request body", http.StatusBadRequest)
 		return
 	}
 	defer func() { _ = req.Body.Close() }()
-	payload := newRequestPayload(bodyBytes)
+	payload := newClientRequestPayload(req, requestCtx, bodyBytes)
 
 	attemptCtx, cancelAttempt := context.WithCancelCause(req.Context())
 	reqWithAttemptCtx := req.WithContext(attemptCtx)
@@ -80,14 +81,14 @@ func (cp *ClientProxy) forwardManual(w http.ResponseWriter, req *http.Request, p
 		if !prepared {
 			logger.Error("[%s] failed to create request for %s: %v", cp.clientType, provider.Name, err)
 			cp.recordTerminalRequest(time.Now(), req, provider.Name, http.StatusBadGateway, "request_rejected", "Failed to create upstream request.")
-			writeProxyError(w, "Failed to create upstream request", http.StatusBadGateway)
+			writeProxyErrorDetail(w, req, payload, "Failed to create upstream request", http.StatusBadGateway, proxyErrorDetail{AttemptedProviders: []string{provider.Name}})
 			return
 		}
 		if req.Context().Err() != nil {
 			return
 		}
 		cp.recordTerminalRequest(time.Now(), req, provider.Name, http.StatusBadGateway, "failed_before_response", describeAttemptFailure(provider.Name, "network", 0, true)+".")
-		writeProxyError(w, "Upstream request failed", http.StatusBadGateway)
+		writeProxyErrorDetail(w, req, payload, "Upstream request failed", http.StatusBadGateway, proxyErrorDetail{AttemptedProviders: []string{provider.Name}})
 		return
 	}
 
@@ -96,7 +97,7 @@ func (cp *ClientProxy) forwardManual(w http.ResponseWriter, req *http.Request, p
 		cp.setCurrentKeyIndexForScope(index, keyIndex, scope)
 	}
 	onSuccess := func(success streamSuccess) {
-		success.usage = applyUsageCostSnapshot(req, requestCtx, provider, payload, success.usage)
+		success.usage = applyUsageCostSnapshot(cp.pricing, req, requestCtx, provider, payload, estimatedStreamUsage(payload, success))
 		cp.recordCompletedUsage(req, provider.Name, resp.StatusCode, success.usage, time.Now())
 	}
 	allow := circuitAllowResult{}
@@ -105,7 +106,7 @@ func (cp *ClientProxy) forwardManual(w http.ResponseWriter, req *http.Request, p
 		result = cp.synthesizeCodexOAuthNonStreamingResponseToClient(w, resp, req, attemptCtx, cancelAttempt, index, allow, onCommit, onSuccess)
 		cancelAttempt(nil)
 	} else {
-		result = cp.streamResponseToClient(w, resp, req, attemptCtx, cancelAttempt, index, allow, onCommit, onSuccess)
+		result = cp.streamResponseToClient(w, resp, req, attemptCtx, cancelAttempt, index, allow, onCommit, onSuccess, nil)
 	}
 	if result.kind == streamFinal {
 		cp.logRequestResult(req, provider.Name, resp.StatusCode, result, true)
@@ -118,5 +119,5 @@ func (cp *ClientProxy) forwardManual(w http.ResponseWriter, req *http.Request, p
 		reason = "idle_timeout"
 	}
 	cp.recordTerminalRequest(time.Now(), req, provider.Name, http.StatusBadGateway, "failed_before_response", describeAttemptFailure(provider.Name, reason, 0, true)+".")
-	writeProxyError(w, "Upstream response read failed", http.StatusBadGateway)
+	writeProxyErrorDetail(w, req, payload, "Upstream response read failed", http.StatusBadGateway, proxyErrorDetail{AttemptedProviders: []string{provider.Name}})
 }
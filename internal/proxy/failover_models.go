@@ -0,0 +1,156 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lansespirit/Clipal/internal/config"
+)
+
+// modelFallbackReason is the switch reason recorded when a provider is retried
+// with the next model of a fallback chain.
+const modelFallbackReason = "model_fallback"
+
+// Response headers set when a request was served by a fallback model.
+const (
+	headerRequestedModel = "X-Clipal-Requested-Model"
+	headerFallbackModel  = "X-Clipal-Fallback-Model"
+)
+
+// modelFallbackChain tracks the fallback models still available for one
+// provider attempt.
+type modelFallbackChain struct {
+	requested string
+	models    []string
+	used      int
+}
+
+// modelFallbackChainFor returns the fallback chain for the model this provider
+// would be asked for, or nil when none is configured or the request model cannot
+// be rewritten.
+func (cp *ClientProxy) modelFallbackChainFor(req *http.Request, index int, requestCtx RequestContext, payload *requestPayload) *modelFallbackChain {
+	if !supportsModelFallback(requestCtx) || !isJSONRequest(req) {
+		return nil
+	}
+	provider := cp.providers[index]
+	requested := provider.ModelOverride()
+	if requested == "" {
+		requested = strings.TrimSpace(stringValue(payload.jsonRoot()["model"]))
+	}
+	if requested == "" {
+		return nil
+	}
+	chain := provider.FallbackModels.Chain(requested)
+	if len(chain) == 0 {
+		cp.mu.RLock()
+		chain = cp.routing.fallbackModels.Chain(requested)
+		cp.mu.RUnlock()
+	}
+	if len(chain) == 0 {
+		return nil
+	}
+	return &modelFallbackChain{requested: requested, models: chain}
+}
+
+// supportsModelFallback reports whether the provider override machinery rewrites
+// the model for this capability.
+func supportsModelFallback(requestCtx RequestContext) bool {
+	switch requestCtx.Family {
+	case ProtocolFamilyClaude:
+		return requestCtx.Capability == CapabilityClaudeMessages || requestCtx.Capability == CapabilityClaudeCountTokens
+	case ProtocolFamilyOpenAI:
+		return isOpenAIGenerationCapability(requestCtx.Capability)
+	default:
+		return false
+	}
+}
+
+func (c *modelFallbackChain) hasNext() bool {
+	return c != nil && c.used < len(c.models)
+}
+
+// current returns the model of the latest attempt.
+func (c *modelFallbackChain) current() string {
+	if c.used == 0 {
+		return c.requested
+	}
+	return strings.TrimSpace(c.models[c.used-1])
+}
+
+// advance moves to the next fallback model and returns it.
+func (c *modelFallbackChain) advance() string {
+	c.used++
+	return c.current()
+}
+
+// fallbackModel returns the fallback model in use, or "" when the requested model
+// is still being tried.
+func (c *modelFallbackChain) fallbackModel() string {
+	if c == nil || c.used == 0 {
+		return ""
+	}
+	return c.current()
+}
+
+// withModelOverride returns a copy of provider whose model override is model.
+func withModelOverride(provider config.Provider, model string) config.Provider {
+	var overrides config.ProviderOverrides
+	if provider.Overrides != nil {
+		overrides = *provider.Overrides
+	}
+	overrides.Model = &model
+	provider.Overrides = &overrides
+	return provider
+}
+
+func isModelFallbackStatus(status int) bool {
+	switch status {
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusGone, http.StatusUnprocessableEntity,
+		http.StatusServiceUnavailable, 529:
+		return true
+	default:
+		return false
+	}
+}
+
+// isModelUnavailable reports whether an upstream error says the requested model,
+// rather than the provider, cannot serve the request: it is unknown, retired, or
+// overloaded.
+func isModelUnavailable(status int, body []byte) bool {
+	if !isModelFallbackStatus(status) {
+		return false
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return false
+	}
+	code, typ, msg := extractErrorFields(v)
+	code = strings.ToLower(code)
+	typ = strings.ToLower(typ)
+	msg = strings.ToLower(msg)
+
+	if inSet(code, "model_not_found", "model_not_available", "model_deprecated", "model_retired") {
+		return true
+	}
+	if status == 529 || typ == "overloaded_error" {
+		return true
+	}
+	if !strings.Contains(msg, "model") {
+		return false
+	}
+	if typ == "not_found_error" {
+		return true
+	}
+	for _, marker := range []string{"not found", "does not exist", "not supported", "no longer available", "deprecated", "retired", "decommissioned", "overloaded", "unavailable"} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cp *ClientProxy) announceModelFallback(provider string, from string, to string, status int) {
+	cp.announceProviderSwitch(fmt.Sprintf("%s (%s)", provider, from), fmt.Sprintf("%s (%s)", provider, to), modelFallbackReason, status)
+}
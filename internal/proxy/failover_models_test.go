@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+)
+
+func requestModel(body []byte) string {
+	var req struct {
+		Model string `json:"model"`
+	}
+	_ = json.Unmarshal(body, &req)
+	return req.Model
+}
+
+func TestForwardWithFailover_ModelFallbackRetriesSameProvider(t *testing.T) {
+	t.Parallel()
+
+	var models []string
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host != "p1" {
+			return nil, errors.New("unexpected host")
+		}
+		body, _ := io.ReadAll(r.Body)
+		model := requestModel(body)
+		models = append(models, model)
+		switch model {
+		case "claude-opus-4-1":
+			return newResponse(http.StatusNotFound, nil, `{"type":"error","error":{"type":"not_found_error","message":"model: claude-opus-4-1"}}`), nil
+		case "claude-sonnet-4-5":
+			return newResponse(529, nil, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`), nil
+		default:
+			return newResponse(http.StatusOK, nil, `{"ok":true}`), nil
+		}
+	})
+
+	cp := newClientProxy(ClientClaude, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1, FallbackModels: config.FallbackModels{
+			"claude-opus-4-1": {"claude-sonnet-4-5", "claude-haiku-4-5"},
+		}},
+		{Name: "p2", BaseURL: "http://p2", APIKey: "k2", Priority: 2},
+	}, time.Hour, 0, testResponseHeaderTimeout, circuitBreakerConfig{})
+	cp.httpClient.Transport = rt
+
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/claudecode/v1/messages", bytes.NewReader([]byte(`{"model":"claude-opus-4-1","max_tokens":8}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req = withRequestContext(req, requestContextForClientPath(ClientClaude, "/v1/messages", false))
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, req, "/v1/messages")
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: got %d body=%q", rr.Code, rr.Body.String())
+	}
+	want := []string{"claude-opus-4-1", "claude-sonnet-4-5", "claude-haiku-4-5"}
+	if len(models) != len(want) {
+		t.Fatalf("models: got %v want %v", models, want)
+	}
+	for i := range want {
+		if models[i] != want[i] {
+			t.Fatalf("models: got %v want %v", models, want)
+		}
+	}
+	if got := rr.Header().Get(headerRequestedModel); got != "claude-opus-4-1" {
+		t.Fatalf("%s: got %q", headerRequestedModel, got)
+	}
+	if got := rr.Header().Get(headerFallbackModel); got != "claude-haiku-4-5" {
+		t.Fatalf("%s: got %q", headerFallbackModel, got)
+	}
+	if got := cp.lastSwitch; got.Reason != modelFallbackReason || got.From != "p1 (claude-sonnet-4-5)" || got.To != "p1 (claude-haiku-4-5)" {
+		t.Fatalf("last switch: %+v", got)
+	}
+	if cp.isDeactivated(0) {
+		t.Fatalf("model fallback should not deactivate provider 0")
+	}
+}
+
+func TestForwardWithFailover_GlobalModelFallbackAppliesToOverriddenModel(t *testing.T) {
+	t.Parallel()
+
+	var models []string
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(r.Body)
+		model := requestModel(body)
+		models = append(models, model)
+		if model == "gpt-5" {
+			return newResponse(http.StatusNotFound, nil, `{"error":{"code":"model_not_found","message":"The model gpt-5 does not exist"}}`), nil
+		}
+		return newResponse(http.StatusOK, nil, `{"ok":true}`), nil
+	})
+
+	model := "gpt-5"
+	cp := newClientProxy(ClientOpenAI, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1, Overrides: &config.ProviderOverrides{Model: &model}},
+	}, time.Hour, 0, testResponseHeaderTimeout, circuitBreakerConfig{})
+	cp.httpClient.Transport = rt
+	cp.applyRoutingRuntimeSettings(routingRuntimeSettingsFromConfig(config.RoutingConfig{
+		FallbackModels: config.FallbackModels{"gpt-5": {"gpt-5-mini"}},
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/codex/v1/chat/completions", bytes.NewReader([]byte(`{"model":"gpt-4o","messages":[]}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req = withRequestContext(req, requestContextForClientPath(ClientOpenAI, "/v1/chat/completions", false))
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, req, "/v1/chat/completions")
+
+	if rr.Code != http.StatusOK || len(models) != 2 || models[1] != "gpt-5-mini" {
+		t.Fatalf("status=%d models=%v", rr.Code, models)
+	}
+	if got := rr.Header().Get(headerFallbackModel); got != "gpt-5-mini" {
+		t.Fatalf("%s: got %q", headerFallbackModel, got)
+	}
+}
+
+func TestIsModelUnavailable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   bool
+	}{
+		{name: "openai model_not_found", status: http.StatusNotFound, body: `{"error":{"code":"model_not_found","message":"nope"}}`, want: true},
+		{name: "claude not_found_error", status: http.StatusNotFound, body: `{"type":"error","error":{"type":"not_found_error","message":"model: claude-x"}}`, want: true},
+		{name: "retired model", status: http.StatusBadRequest, body: `{"error":{"message":"The model text-davinci-003 has been deprecated"}}`, want: true},
+		{name: "overloaded", status: 529, body: `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`, want: true},
+		{name: "unrelated bad request", status: http.StatusBadRequest, body: `{"error":{"message":"messages: field required"}}`, want: false},
+		{name: "plain 404", status: http.StatusNotFound, body: `not found`, want: false},
+		{name: "server error", status: http.StatusInternalServerError, body: `{"error":{"code":"model_not_found"}}`, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := isModelUnavailable(tt.status, []byte(tt.body)); got != tt.want {
+				t.Fatalf("got %v want %v", got, tt.want)
+			}
+		})
+	}
+}
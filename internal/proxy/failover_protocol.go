@@ -165,15 +165,19 @@ func (pt *protocolTracker) abortedStatus() protocolStatus {
 
 func (cp *ClientProxy) logRequestResult(req *http.Request, providerName string, statusCode int, result streamResult, manual bool) {
 	now := time.Now()
+	if result.recoveredVia != "" {
+		providerName = result.recoveredVia
+	}
 	cp.recordLastRequest(now, req, providerName, statusCode, result)
 	presentation := DescribeRequestOutcome(RequestOutcomeEvent{
-		At:       now,
-		Provider: providerName,
-		Status:   statusCode,
-		Delivery: string(result.delivery),
-		Protocol: string(result.protocol),
-		Cause:    result.cause,
-		Bytes:    result.bytes,
+		At:            now,
+		Provider:      providerName,
+		Status:        statusCode,
+		Delivery:      string(result.delivery),
+		Protocol:      string(result.protocol),
+		Cause:         result.cause,
+		Bytes:         result.bytes,
+		RecoveredFrom: result.recoveredFrom,
 	})
 
 	suffix := ""
@@ -0,0 +1,731 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/logger"
+	"github.com/lansespirit/Clipal/internal/telemetry"
+	"github.com/lansespirit/Clipal/internal/tokencount"
+)
+
+// streamRecoveryReason is the switch reason recorded when an interrupted stream
+// is resumed on another provider.
+const streamRecoveryReason = "stream_recovery"
+
+// streamRecovery tracks the assistant output already relayed on a committed
+// Claude Messages or OpenAI chat stream so an interrupted stream can be resumed
+// on another provider with that output as an assistant prefill.
+type streamRecovery struct {
+	kind    streamProtocolKind
+	w       io.Writer
+	tracker *protocolTracker
+
+	// provider is the provider whose stream was interrupted most recently.
+	provider string
+	attempts int
+
+	relayed     sseEventReader
+	text        strings.Builder
+	unsupported bool
+	finished    bool
+
+	// usage is what the interrupted stream reported before it broke off, and
+	// segment is the length of text when that stream started relaying.
+	usage   telemetry.UsageSnapshot
+	segment int
+	// trimmedSpace is the trailing whitespace left out of the last Claude
+	// prefill; the client has already received it.
+	trimmedSpace string
+
+	// Claude content block bookkeeping, in client-visible indices.
+	openBlock int
+	nextBlock int
+
+	// chunkID is the OpenAI chat completion id the client has seen.
+	chunkID string
+}
+
+// newStreamRecovery returns a recovery tracker for committed streams that can be
+// resumed, or nil when the request is not eligible.
+func (cp *ClientProxy) newStreamRecovery(req *http.Request, resp *http.Response, contentType string, w io.Writer, tracker *protocolTracker, provider string) *streamRecovery {
+	if cp.streamRecoveryAttempts() == 0 || resp == nil || resp.StatusCode/100 != 2 || !isEventStreamContentType(contentType) {
+		return nil
+	}
+	requestCtx, ok := requestContextFromRequest(req)
+	if !ok {
+		return nil
+	}
+	var kind streamProtocolKind
+	switch requestCtx.Capability {
+	case CapabilityClaudeMessages:
+		kind = streamProtocolClaude
+	case CapabilityOpenAIChatCompletions:
+		kind = streamProtocolOpenAI
+	default:
+		return nil
+	}
+	return &streamRecovery{kind: kind, w: w, tracker: tracker, provider: provider, openBlock: -1}
+}
+
+func (cp *ClientProxy) streamRecoveryAttempts() int {
+	if cp.mode == config.ClientModeManual || len(cp.providers) < 2 {
+		return 0
+	}
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+	return cp.routing.streamRecoveryAttempts
+}
+
+// observe records bytes that have been written to the client.
+func (rec *streamRecovery) observe(chunk []byte) {
+	if rec == nil || len(chunk) == 0 {
+		return
+	}
+	rec.relayed.feed(chunk, rec.observeEvent)
+}
+
+// recoverable reports whether the relayed output can be continued elsewhere:
+// only plain text was produced, the upstream did not finish or report an error,
+// the client is not left inside a partial event, and the attempt budget is not
+// exhausted.
+func (rec *streamRecovery) recoverable(maxAttempts int) bool {
+	return rec != nil && !rec.unsupported && !rec.finished && rec.relayed.atBoundary() && rec.attempts < maxAttempts
+}
+
+// resumeInterruptedStream continues an interrupted stream on the next eligible
+// provider. It returns false when nothing was written after the interruption, so
+// the caller reports the original failure.
+func (cp *ClientProxy) resumeInterruptedStream(req *http.Request, path string, payload *requestPayload, scope routingScope, failedIndex int, rec *streamRecovery) (streamResult, bool) {
+	maxAttempts := cp.streamRecoveryAttempts()
+	requestCtx, _ := requestContextFromRequest(req)
+	from := rec.provider
+	cp.recordInterruptedUsage(req, cp.providers[failedIndex], payload, rec)
+	tried := map[int]bool{failedIndex: true}
+	written := 0
+	last := streamResult{}
+	for rec.recoverable(maxAttempts) && req.Context().Err() == nil {
+		index, allow, ok := cp.nextRecoveryProvider(failedIndex, tried, requestCtx.Capability)
+		if !ok {
+			break
+		}
+		tried[index] = true
+		keyIndex, ok := cp.recoveryKeyIndex(index, scope)
+		if !ok {
+			cp.releaseCircuitPermit(index, allow.usedProbe)
+			continue
+		}
+		body, err := rec.continuationBody(payload.Body())
+		if err != nil {
+			cp.releaseCircuitPermit(index, allow.usedProbe)
+			logger.Warn("[%s] cannot resume stream from %s: %v", cp.clientType, rec.provider, err)
+			break
+		}
+		provider := cp.providers[index]
+		rec.attempts++
+		logger.Warn("[%s] stream from %s was interrupted; resuming via %s", cp.clientType, rec.provider, provider.Name)
+		cp.announceProviderSwitch(rec.provider, provider.Name, streamRecoveryReason, 0)
+
+		result, n := cp.spliceContinuation(req, path, newRequestPayload(body), index, keyIndex, allow, rec)
+		written += n
+		if result.delivery == deliveryCommittedComplete {
+			cp.setCurrentIndexForScope(index, scope)
+			cp.setCurrentKeyIndexForScope(index, keyIndex, scope)
+			result.bytes = written
+			result.recoveredVia = provider.Name
+			result.recoveredFrom = from
+			return result, true
+		}
+		last = result
+		if result.delivery == deliveryClientCanceled {
+			break
+		}
+		rec.provider = provider.Name
+	}
+	if written == 0 && last.delivery != deliveryClientCanceled {
+		return streamResult{}, false
+	}
+	last.bytes = written
+	return last, true
+}
+
+func (cp *ClientProxy) nextRecoveryProvider(failedIndex int, tried map[int]bool, capability RequestCapability) (int, circuitAllowResult, bool) {
+	for offset := 1; offset < len(cp.providers); offset++ {
+		index := (failedIndex + offset) % len(cp.providers)
+		now := time.Now()
+		if tried[index] || cp.candidateSkip(index, capability, now) != "" {
+			continue
+		}
+		if allow := cp.allowCircuit(now, index, false); allow.allowed {
+			return index, allow, true
+		}
+	}
+	return -1, circuitAllowResult{}, false
+}
+
+func (cp *ClientProxy) recoveryKeyIndex(index int, scope routingScope) (int, bool) {
+	keyActive, keyStart := cp.getActiveKeyCountAndStartIndexForScope(index, scope, -1, false)
+	if keyActive == 0 {
+		return -1, false
+	}
+	for offset := 0; offset < len(cp.providerKeys[index]); offset++ {
+		keyIndex := (keyStart + offset) % len(cp.providerKeys[index])
+		if !cp.isKeyDeactivated(index, keyIndex) {
+			return keyIndex, true
+		}
+	}
+	return -1, false
+}
+
+// spliceContinuation sends one continuation request and relays its output to
+// the client. It returns the outcome and the number of bytes written.
+func (cp *ClientProxy) spliceContinuation(req *http.Request, path string, payload *requestPayload, index int, keyIndex int, allow circuitAllowResult, rec *streamRecovery) (streamResult, int) {
+	provider := cp.providers[index]
+	attemptCtx, cancelAttempt := context.WithCancelCause(req.Context())
+	defer cancelAttempt(nil)
+	var usageExtractor *telemetry.UsageExtractor
+	circuitRecorded := false
+	failed := func(reason string, err error, written int) (streamResult, int) {
+		if written > 0 {
+			rec.usage, _ = usageExtractor.Partial()
+			cp.recordInterruptedUsage(req, provider, payload, rec)
+		}
+		if req.Context().Err() != nil {
+			if !circuitRecorded {
+				cp.releaseCircuitPermit(index, allow.usedProbe)
+			}
+			return streamResult{
+				kind:     streamFinal,
+				delivery: deliveryClientCanceled,
+				protocol: rec.tracker.abortedStatus(),
+				proto:    rec.tracker.kind,
+				cause:    "client_canceled",
+				err:      req.Context().Err(),
+			}, written
+		}
+		if !circuitRecorded {
+			cp.recordCircuitFailure(time.Now(), index, allow.usedProbe, reason)
+		}
+		logger.Warn("[%s] stream continuation via %s failed: %s", cp.clientType, provider.Name, reason)
+		return streamResult{
+			kind:     streamFinal,
+			delivery: deliveryCommittedPartial,
+			protocol: rec.tracker.abortedStatus(),
+			proto:    rec.tracker.kind,
+			cause:    reason,
+			err:      err,
+		}, written
+	}
+
+	resp, _, err := cp.doProviderRequestWithPayload(req.WithContext(attemptCtx), provider, index, cp.providerKeys[index][keyIndex], path, payload)
+	if err != nil {
+		return failed("network", err, 0)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	contentType := resp.Header.Get("Content-Type")
+	if resp.StatusCode/100 != 2 {
+		reason := cp.rejectContinuation(req, resp, cancelAttempt, path, index, keyIndex, allow)
+		circuitRecorded = true
+		return failed(reason, fmt.Errorf("continuation returned %s", formatHTTPStatus(resp.StatusCode)), 0)
+	}
+	if !isEventStreamContentType(contentType) {
+		return failed("protocol_incomplete", fmt.Errorf("continuation returned %s", contentType), 0)
+	}
+
+	var idleTimer *time.Timer
+	if cp.upstreamIdle > 0 {
+		idleTimer = time.AfterFunc(cp.upstreamIdle, func() { cancelAttempt(errUpstreamIdleTimeout) })
+		defer stopTimer(idleTimer)
+	}
+	usageExtractor = usageExtractorFromRequestWithContentType(req, contentType)
+	if usageExtractor != nil {
+		defer usageExtractor.Cleanup()
+	}
+	splice := rec.newSplice()
+	buf := make([]byte, 32*1024)
+	written := 0
+	for {
+		nr, er := resp.Body.Read(buf)
+		if nr > 0 {
+			if idleTimer != nil {
+				idleTimer.Reset(cp.upstreamIdle)
+			}
+			if usageExtractor != nil {
+				usageExtractor.Append(buf[:nr])
+			}
+			if out := splice.transform(buf[:nr]); len(out) > 0 {
+				rec.tracker.append(out)
+				rec.observe(out)
+				if _, ew := rec.w.Write(out); ew != nil {
+					cp.releaseCircuitPermit(index, allow.usedProbe)
+					return streamResult{
+						kind:     streamFinal,
+						delivery: deliveryClientCanceled,
+						protocol: rec.tracker.abortedStatus(),
+						proto:    rec.tracker.kind,
+						cause:    "client_canceled",
+						err:      ew,
+					}, written
+				}
+				written += len(out)
+			}
+		}
+		if er != nil {
+			if errors.Is(er, io.EOF) {
+				break
+			}
+			reason := "network"
+			if isUpstreamIdleTimeout(attemptCtx, er) {
+				reason = "idle_timeout"
+			}
+			return failed(reason, er, written)
+		}
+	}
+	protocol := rec.tracker.finalStatus()
+	if protocol == protocolIncomplete {
+		return failed("protocol_incomplete", nil, written)
+	}
+
+	now := time.Now()
+	cp.recordCircuitSuccess(now, index, allow.usedProbe)
+	var usage telemetry.UsageSnapshot
+	if usageExtractor != nil {
+		if snapshot, ok := usageExtractor.Finalize(); ok {
+			usage = snapshot
+		}
+	}
+	requestCtx, _ := requestContextFromRequest(req)
+	usage = applyUsageCostSnapshot(cp.pricing, req, requestCtx, provider, payload, usage)
+	cp.recordRecoveredUsage(req, provider.Name, resp.StatusCode, usage, now)
+	return streamResult{
+		kind:     streamFinal,
+		delivery: deliveryCommittedComplete,
+		protocol: protocol,
+		proto:    rec.tracker.kind,
+	}, written
+}
+
+// rejectContinuation classifies a non-success continuation response the way
+// forwardWithFailover classifies a first attempt, applying the same key and
+// provider deactivations and circuit accounting. It returns the failure reason.
+func (cp *ClientProxy) rejectContinuation(req *http.Request, resp *http.Response, cancelAttempt context.CancelCauseFunc, path string, index int, keyIndex int, allow circuitAllowResult) string {
+	provider := cp.providers[index]
+	failure := cp.classifyAttemptResponse(req.Context(), resp, cancelAttempt, provider, index, path)
+	reason := failure.reason
+	if failure.action == failureReturnToClient {
+		reason = "rejected"
+	}
+	cp.noteAttemptFailure(req, provider.Name, resp.StatusCode, reason)
+	switch {
+	case failure.action == failureReturnToClient:
+		cp.releaseCircuitPermit(index, allow.usedProbe)
+		return reason
+	case failure.action == failureBusyRetry:
+		cp.releaseCircuitPermit(index, allow.usedProbe)
+		step, wait := cp.nextBusyBackoff(index)
+		cp.markProviderBusy(index, reason, step, time.Now(), wait)
+		return reason
+	case failure.keyScoped:
+		d := keyFailureDuration(reason, failure.cooldown, cp.reactivateAfter)
+		if failure.ruleMatched {
+			d = failure.cooldown
+		}
+		if d > 0 {
+			cp.deactivateKeyFor(index, keyIndex, reason, resp.StatusCode, failure.msg, d)
+			if cp.activeKeyCount(index) == 0 {
+				cp.deactivateFor(index, reason, resp.StatusCode, failure.msg, d)
+			}
+		}
+	case failure.action == failureDeactivateAndRetryNext:
+		d := cp.reactivateAfter
+		if failure.ruleMatched {
+			d = failure.cooldown
+		}
+		cp.deactivateFor(index, reason, resp.StatusCode, failure.msg, d)
+	case failure.cooldown > 0:
+		cp.deactivateFor(index, reason, resp.StatusCode, failure.msg, failure.cooldown)
+	}
+	cp.recordCircuitFailureFromClassification(time.Now(), index, allow.usedProbe, reason)
+	return reason
+}
+
+// recordInterruptedUsage records the usage of a stream that broke off after
+// relaying output. Counts the stream never reported are estimated from the
+// request and from the text it relayed.
+func (cp *ClientProxy) recordInterruptedUsage(req *http.Request, provider config.Provider, payload *requestPayload, rec *streamRecovery) {
+	usage := rec.usage
+	rec.usage = telemetry.UsageSnapshot{}
+	output := int64(tokencount.Text(rec.text.String()[rec.segment:]))
+	rec.segment = rec.text.Len()
+	requestCtx, _ := requestContextFromRequest(req)
+
+	inputKey, outputKey := "prompt_tokens", "completion_tokens"
+	if rec.kind == streamProtocolClaude {
+		inputKey, outputKey = "input_tokens", "output_tokens"
+	}
+	if usage.InputTokens == 0 {
+		usage.InputTokens = estimatePromptTokens(requestCtx, payload.jsonRoot())
+		usage.Usage = withUsageValue(usage.Usage, inputKey, usage.InputTokens)
+		usage.Estimated = true
+	}
+	if usage.OutputTokens < output {
+		usage.OutputTokens = output
+		usage.Usage = withUsageValue(usage.Usage, outputKey, output)
+		usage.Estimated = true
+	}
+	usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+	if usage.TotalTokens == 0 {
+		return
+	}
+	usage = applyUsageCostSnapshot(cp.pricing, req, requestCtx, provider, payload, usage)
+	cp.recordInterruptedStreamUsage(req, provider.Name, usage, time.Now())
+}
+
+func withUsageValue(raw map[string]any, key string, value int64) map[string]any {
+	out := make(map[string]any, len(raw)+1)
+	for k, v := range raw {
+		out[k] = v
+	}
+	out[key] = float64(value)
+	return out
+}
+
+func (rec *streamRecovery) observeEvent(ev sseEvent) {
+	if strings.TrimSpace(ev.data) == "[DONE]" {
+		rec.finished = true
+		return
+	}
+	payload, ok := decodeRecoveryJSON(ev.data)
+	if !ok {
+		return
+	}
+	if _, ok := payload["error"]; ok {
+		rec.unsupported = true
+		return
+	}
+	switch rec.kind {
+	case streamProtocolClaude:
+		rec.observeClaudeEvent(ev.name, payload)
+	case streamProtocolOpenAI:
+		rec.observeOpenAIChunk(payload)
+	}
+}
+
+func (rec *streamRecovery) observeClaudeEvent(name string, payload map[string]any) {
+	if name == "" {
+		name = stringValue(payload["type"])
+	}
+	switch name {
+	case "content_block_start":
+		block, _ := payload["content_block"].(map[string]any)
+		if stringValue(block["type"]) != "text" {
+			rec.unsupported = true
+			return
+		}
+		index := recoveryJSONIndex(payload["index"])
+		rec.text.WriteString(stringValue(block["text"]))
+		rec.openBlock = index
+		rec.nextBlock = index + 1
+	case "content_block_delta":
+		delta, _ := payload["delta"].(map[string]any)
+		if stringValue(delta["type"]) != "text_delta" {
+			rec.unsupported = true
+			return
+		}
+		rec.text.WriteString(stringValue(delta["text"]))
+	case "content_block_stop":
+		rec.openBlock = -1
+	case "message_delta", "message_stop":
+		rec.finished = true
+	}
+}
+
+func (rec *streamRecovery) observeOpenAIChunk(payload map[string]any) {
+	if id := stringValue(payload["id"]); id != "" && rec.chunkID == "" {
+		rec.chunkID = id
+	}
+	for _, raw := range anySlice(payload["choices"]) {
+		choice, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		if recoveryJSONIndex(choice["index"]) != 0 {
+			rec.unsupported = true
+			continue
+		}
+		if stringValue(choice["finish_reason"]) != "" {
+			rec.finished = true
+		}
+		delta, _ := choice["delta"].(map[string]any)
+		if len(anySlice(delta["tool_calls"])) > 0 || delta["function_call"] != nil {
+			rec.unsupported = true
+			continue
+		}
+		rec.text.WriteString(stringValue(delta["content"]))
+	}
+}
+
+// continuationBody appends the relayed output to the request messages as an
+// assistant prefill, extending a client-supplied prefill when there is one.
+func (rec *streamRecovery) continuationBody(body []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var root map[string]any
+	if err := dec.Decode(&root); err != nil {
+		return nil, fmt.Errorf("decode request body: %w", err)
+	}
+	messages, ok := root["messages"].([]any)
+	if !ok {
+		return nil, fmt.Errorf("request body has no messages")
+	}
+	prefill := rec.text.String()
+	rec.trimmedSpace = ""
+	if rec.kind == streamProtocolClaude {
+		// Claude rejects assistant prefills that end in whitespace. The client
+		// already has it, so the splice drops it if the continuation repeats it.
+		trimmed := strings.TrimRightFunc(prefill, unicode.IsSpace)
+		prefill, rec.trimmedSpace = trimmed, prefill[len(trimmed):]
+	}
+	if prefill != "" {
+		var last map[string]any
+		if len(messages) > 0 {
+			last, _ = messages[len(messages)-1].(map[string]any)
+		}
+		switch content := last["content"].(type) {
+		case string:
+			if stringValue(last["role"]) == "assistant" {
+				last["content"] = content + prefill
+				break
+			}
+			messages = append(messages, map[string]any{"role": "assistant", "content": prefill})
+		case []any:
+			if stringValue(last["role"]) == "assistant" {
+				last["content"] = append(content, map[string]any{"type": "text", "text": prefill})
+				break
+			}
+			messages = append(messages, map[string]any{"role": "assistant", "content": prefill})
+		default:
+			messages = append(messages, map[string]any{"role": "assistant", "content": prefill})
+		}
+	}
+	root["messages"] = messages
+	return json.Marshal(root)
+}
+
+// streamSplice rewrites a continuation stream so it reads as the rest of the
+// stream the client is already consuming.
+type streamSplice struct {
+	rec    *streamRecovery
+	reader sseEventReader
+	// openBlock is the Claude text block left open by the interruption, or -1.
+	// The continuation's first text block is merged into it.
+	openBlock int
+	offset    int
+	merged    bool
+	// space is the part of the trimmed prefill whitespace the continuation
+	// has not yet repeated.
+	space string
+}
+
+func (rec *streamRecovery) newSplice() *streamSplice {
+	s := &streamSplice{rec: rec, openBlock: rec.openBlock, offset: rec.nextBlock, space: rec.trimmedSpace}
+	if rec.openBlock >= 0 {
+		s.merged = true
+		s.offset = rec.openBlock
+	}
+	return s
+}
+
+// transform returns the client-facing bytes for a continuation chunk.
+func (s *streamSplice) transform(chunk []byte) []byte {
+	var out bytes.Buffer
+	s.reader.feed(chunk, func(ev sseEvent) {
+		switch s.rec.kind {
+		case streamProtocolClaude:
+			s.writeClaudeEvent(&out, ev)
+		default:
+			s.writeOpenAIChunk(&out, ev)
+		}
+	})
+	return out.Bytes()
+}
+
+func (s *streamSplice) writeClaudeEvent(out *bytes.Buffer, ev sseEvent) {
+	payload, ok := decodeRecoveryJSON(ev.data)
+	if !ok {
+		writeSSEEvent(out, ev.name, ev.data)
+		return
+	}
+	name := ev.name
+	if name == "" {
+		name = stringValue(payload["type"])
+	}
+	switch name {
+	case "message_start":
+		// The client already received message_start from the interrupted stream.
+		return
+	case "content_block_start":
+		index := recoveryJSONIndex(payload["index"])
+		if s.merged && index == 0 {
+			block, _ := payload["content_block"].(map[string]any)
+			if stringValue(block["type"]) == "text" {
+				if text := s.dropRepeatedSpace(stringValue(block["text"])); text != "" {
+					s.writeClaudeJSON(out, "content_block_delta", map[string]any{
+						"type":  "content_block_delta",
+						"index": s.openBlock,
+						"delta": map[string]any{"type": "text_delta", "text": text},
+					})
+				}
+				return
+			}
+			// Close the interrupted text block before a different block type starts.
+			s.writeClaudeJSON(out, "content_block_stop", map[string]any{"type": "content_block_stop", "index": s.openBlock})
+			s.merged = false
+			s.offset = s.openBlock + 1
+		}
+		if block, _ := payload["content_block"].(map[string]any); stringValue(block["type"]) != "text" {
+			s.space = ""
+		}
+		payload["index"] = s.offset + index
+	case "content_block_delta":
+		if delta, _ := payload["delta"].(map[string]any); s.space != "" && stringValue(delta["type"]) == "text_delta" {
+			text := s.dropRepeatedSpace(stringValue(delta["text"]))
+			if text == "" {
+				return
+			}
+			delta["text"] = text
+		}
+		payload["index"] = s.offset + recoveryJSONIndex(payload["index"])
+	case "content_block_stop":
+		payload["index"] = s.offset + recoveryJSONIndex(payload["index"])
+	}
+	s.writeClaudeJSON(out, name, payload)
+}
+
+// dropRepeatedSpace strips the start of continuation text that repeats the
+// whitespace trimmed from the prefill. The first other character ends it.
+func (s *streamSplice) dropRepeatedSpace(text string) string {
+	for s.space != "" && text != "" {
+		r, n := utf8.DecodeRuneInString(text)
+		want, size := utf8.DecodeRuneInString(s.space)
+		if r != want {
+			s.space = ""
+			break
+		}
+		text, s.space = text[n:], s.space[size:]
+	}
+	return text
+}
+
+func (s *streamSplice) writeClaudeJSON(out *bytes.Buffer, name string, payload map[string]any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	writeSSEEvent(out, name, string(data))
+}
+
+func (s *streamSplice) writeOpenAIChunk(out *bytes.Buffer, ev sseEvent) {
+	payload, ok := decodeRecoveryJSON(ev.data)
+	if !ok {
+		writeSSEEvent(out, ev.name, ev.data)
+		return
+	}
+	if s.rec.chunkID != "" {
+		payload["id"] = s.rec.chunkID
+	}
+	for _, raw := range anySlice(payload["choices"]) {
+		if choice, ok := raw.(map[string]any); ok {
+			if delta, ok := choice["delta"].(map[string]any); ok {
+				delete(delta, "role")
+			}
+		}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	writeSSEEvent(out, ev.name, string(data))
+}
+
+func writeSSEEvent(out *bytes.Buffer, name string, data string) {
+	if name != "" {
+		out.WriteString("event: " + name + "\n")
+	}
+	for _, line := range strings.Split(data, "\n") {
+		out.WriteString("data: " + line + "\n")
+	}
+	out.WriteString("\n")
+}
+
+// sseEventReader splits a byte stream into complete SSE events. Comment lines
+// and unknown fields are dropped.
+type sseEventReader struct {
+	buf  []byte
+	name string
+	data []string
+}
+
+// atBoundary reports whether the stream so far ended on a complete event.
+func (r *sseEventReader) atBoundary() bool {
+	return len(r.buf) == 0 && len(r.data) == 0 && r.name == ""
+}
+
+func (r *sseEventReader) feed(chunk []byte, fn func(sseEvent)) {
+	r.buf = append(r.buf, chunk...)
+	for {
+		idx := bytes.IndexByte(r.buf, '\n')
+		if idx < 0 {
+			return
+		}
+		line := strings.TrimSuffix(string(r.buf[:idx]), "\r")
+		r.buf = r.buf[idx+1:]
+		switch {
+		case line == "":
+			if len(r.data) > 0 {
+				fn(sseEvent{name: r.name, data: strings.Join(r.data, "\n")})
+			}
+			r.name, r.data = "", nil
+		case strings.HasPrefix(line, "event:"):
+			r.name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			r.data = append(r.data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+}
+
+func decodeRecoveryJSON(data string) (map[string]any, bool) {
+	data = strings.TrimSpace(data)
+	if data == "" || data[0] != '{' {
+		return nil, false
+	}
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	var payload map[string]any
+	if err := dec.Decode(&payload); err != nil {
+		return nil, false
+	}
+	return payload, true
+}
+
+func recoveryJSONIndex(v any) int {
+	switch n := v.(type) {
+	case json.Number:
+		i, _ := n.Int64()
+		return int(i)
+	case float64:
+		return int(n)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,348 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/telemetry"
+)
+
+func interruptedBody(data string) io.ReadCloser {
+	return io.NopCloser(io.MultiReader(strings.NewReader(data), iotest.ErrReader(errors.New("connection reset"))))
+}
+
+func newStreamRecoveryTestProxy(clientType ClientType, rt http.RoundTripper) *ClientProxy {
+	cp := newClientProxy(clientType, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1},
+		{Name: "p2", BaseURL: "http://p2", APIKey: "k2", Priority: 2},
+	}, time.Hour, time.Second, testResponseHeaderTimeout, circuitBreakerConfig{})
+	cp.httpClient.Transport = rt
+	cp.applyRoutingRuntimeSettings(routingRuntimeSettingsFromConfig(config.RoutingConfig{
+		StreamRecovery: config.StreamRecoveryConfig{Enabled: true},
+	}))
+	return cp
+}
+
+func TestForwardWithFailover_StreamRecoveryContinuesClaudeTextBlock(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu           sync.Mutex
+		continuation string
+	)
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		h := make(http.Header)
+		h.Set("Content-Type", "text/event-stream")
+		switch r.URL.Host {
+		case "p1":
+			return &http.Response{StatusCode: http.StatusOK, Header: h, Body: interruptedBody(
+				"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\"}}\n\n" +
+					"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+					"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello \"}}\n\n",
+			)}, nil
+		case "p2":
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			continuation = string(body)
+			mu.Unlock()
+			return newResponse(http.StatusOK, h,
+				"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_2\"}}\n\n"+
+					"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n"+
+					"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"world\"}}\n\n"+
+					"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n"+
+					"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"}}\n\n"+
+					"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"), nil
+		default:
+			return nil, errors.New("unexpected host")
+		}
+	})
+
+	cp := newStreamRecoveryTestProxy(ClientClaude, rt)
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/claudecode/v1/messages", bytes.NewReader([]byte(`{"stream":true,"messages":[{"role":"user","content":"hi"}]}`)))
+	req = withRequestContext(req, requestContextForClientPath(ClientClaude, "/v1/messages", false))
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, req, "/v1/messages")
+
+	body := rr.Body.String()
+	if strings.Count(body, "event: message_start") != 1 || strings.Contains(body, "msg_2") {
+		t.Fatalf("expected a single message_start from the interrupted stream, got %q", body)
+	}
+	if !strings.Contains(body, `{"delta":{"text":"world","type":"text_delta"},"index":0,"type":"content_block_delta"}`) {
+		t.Fatalf("expected continuation text merged into block 0, got %q", body)
+	}
+	if strings.Count(body, "event: content_block_start") != 1 || !strings.HasSuffix(body, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n") {
+		t.Fatalf("unexpected spliced stream: %q", body)
+	}
+
+	mu.Lock()
+	sent := continuation
+	mu.Unlock()
+	if !strings.Contains(sent, `{"content":"Hello","role":"assistant"}`) {
+		t.Fatalf("expected assistant prefill in continuation request, got %s", sent)
+	}
+
+	last := cp.lastRequest
+	if last.Provider != "p2" || last.RecoveredFrom != "p1" || last.Delivery != string(deliveryCommittedComplete) || last.Protocol != string(protocolCompleted) {
+		t.Fatalf("last request: %+v", last)
+	}
+	if got := cp.getCurrentIndex(); got != 1 {
+		t.Fatalf("currentIndex: got %d want 1", got)
+	}
+}
+
+func TestForwardWithFailover_StreamRecoveryContinuesOpenAIChat(t *testing.T) {
+	t.Parallel()
+
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		h := make(http.Header)
+		h.Set("Content-Type", "text/event-stream")
+		switch r.URL.Host {
+		case "p1":
+			return &http.Response{StatusCode: http.StatusOK, Header: h, Body: interruptedBody(
+				"data: {\"id\":\"chatcmpl-1\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hel\"}}]}\n\n",
+			)}, nil
+		case "p2":
+			return newResponse(http.StatusOK, h,
+				"data: {\"id\":\"chatcmpl-2\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"lo\"}}]}\n\n"+
+					"data: {\"id\":\"chatcmpl-2\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n"+
+					"data: [DONE]\n\n"), nil
+		default:
+			return nil, errors.New("unexpected host")
+		}
+	})
+
+	cp := newStreamRecoveryTestProxy(ClientOpenAI, rt)
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/codex/v1/chat/completions", bytes.NewReader([]byte(`{"stream":true,"messages":[{"role":"user","content":"hi"}]}`)))
+	req = withRequestContext(req, requestContextForClientPath(ClientOpenAI, "/v1/chat/completions", false))
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, req, "/v1/chat/completions")
+
+	body := rr.Body.String()
+	if strings.Contains(body, "chatcmpl-2") {
+		t.Fatalf("expected continuation chunks to reuse the original id, got %q", body)
+	}
+	if strings.Count(body, `"role"`) != 1 || !strings.Contains(body, `"content":"lo"`) || !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Fatalf("unexpected spliced stream: %q", body)
+	}
+	if last := cp.lastRequest; last.Provider != "p2" || last.RecoveredFrom != "p1" {
+		t.Fatalf("last request: %+v", last)
+	}
+}
+
+func TestForwardWithFailover_StreamRecoverySkipsToolUse(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		h := make(http.Header)
+		h.Set("Content-Type", "text/event-stream")
+		return &http.Response{StatusCode: http.StatusOK, Header: h, Body: interruptedBody(
+			"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{}}\n\n" +
+				"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"tool_use\",\"name\":\"ls\"}}\n\n",
+		)}, nil
+	})
+
+	cp := newStreamRecoveryTestProxy(ClientClaude, rt)
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, newClaudeMessagesRequest(), "/v1/messages")
+
+	if calls != 1 {
+		t.Fatalf("upstream calls: got %d want 1", calls)
+	}
+	if last := cp.lastRequest; last.Delivery != string(deliveryCommittedPartial) || last.RecoveredFrom != "" {
+		t.Fatalf("last request: %+v", last)
+	}
+}
+
+func TestStreamRecoveryContinuationBody_ExtendsAssistantPrefill(t *testing.T) {
+	t.Parallel()
+
+	rec := &streamRecovery{kind: streamProtocolClaude}
+	rec.text.WriteString("The answer is \n")
+
+	got, err := rec.continuationBody([]byte(`{"max_tokens":1024,"messages":[{"role":"user","content":"q"},{"role":"assistant","content":"Sure. "}]}`))
+	if err != nil {
+		t.Fatalf("continuationBody: %v", err)
+	}
+	want := `{"max_tokens":1024,"messages":[{"content":"q","role":"user"},{"content":"Sure. The answer is","role":"assistant"}]}`
+	if string(got) != want {
+		t.Fatalf("body:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestForwardWithFailover_StreamRecoveryRecordsInterruptedUsage(t *testing.T) {
+	t.Parallel()
+
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		h := make(http.Header)
+		h.Set("Content-Type", "text/event-stream")
+		switch r.URL.Host {
+		case "p1":
+			return &http.Response{StatusCode: http.StatusOK, Header: h, Body: interruptedBody(
+				"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"usage\":{\"input_tokens\":40,\"output_tokens\":1}}}\n\n" +
+					"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+					"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"The quick brown fox \"}}\n\n",
+			)}, nil
+		case "p2":
+			return newResponse(http.StatusOK, h,
+				"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_2\",\"usage\":{\"input_tokens\":50,\"output_tokens\":1}}}\n\n"+
+					"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n"+
+					"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\" jumps\"}}\n\n"+
+					"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n"+
+					"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":3}}\n\n"+
+					"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"), nil
+		default:
+			return nil, errors.New("unexpected host")
+		}
+	})
+
+	store, err := telemetry.NewStore("")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	cp := newClientProxy(ClientClaude, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1},
+		{Name: "p2", BaseURL: "http://p2", APIKey: "k2", Priority: 2},
+	}, time.Hour, time.Second, testResponseHeaderTimeout, circuitBreakerConfig{}, store)
+	cp.httpClient.Transport = rt
+	cp.applyRoutingRuntimeSettings(routingRuntimeSettingsFromConfig(config.RoutingConfig{
+		StreamRecovery: config.StreamRecoveryConfig{Enabled: true},
+	}))
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/claudecode/v1/messages", bytes.NewReader([]byte(`{"stream":true,"messages":[{"role":"user","content":"hi"}]}`)))
+	req = withRequestContext(req, requestContextForClientPath(ClientClaude, "/v1/messages", false))
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, req, "/v1/messages")
+
+	// The continuation repeated the whitespace trimmed from the prefill.
+	if body := rr.Body.String(); !strings.Contains(body, `"text":"jumps"`) || strings.Contains(body, `" jumps"`) {
+		t.Fatalf("unexpected spliced stream: %q", body)
+	}
+	first, ok := store.ProviderSnapshot(string(ClientClaude), "p1")
+	if !ok || first.RequestCount != 1 || first.SuccessCount != 0 || first.InputTokens != 40 || first.OutputTokens < 5 {
+		t.Fatalf("interrupted provider usage = %#v", first)
+	}
+	second, ok := store.ProviderSnapshot(string(ClientClaude), "p2")
+	if !ok || second.RecoveredCount != 1 || second.InputTokens != 50 || second.OutputTokens != 3 {
+		t.Fatalf("recovering provider usage = %#v", second)
+	}
+}
+
+func TestForwardWithFailover_StreamRecoveryEstimatesClaudeInputFromWholePrompt(t *testing.T) {
+	t.Parallel()
+
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		h := make(http.Header)
+		h.Set("Content-Type", "text/event-stream")
+		if r.URL.Host != "p1" {
+			return newResponse(http.StatusServiceUnavailable, nil, "down"), nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: h, Body: interruptedBody(
+			"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\"}}\n\n" +
+				"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello\"}}\n\n",
+		)}, nil
+	})
+	store, err := telemetry.NewStore("")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	cp := newClientProxy(ClientClaude, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1},
+		{Name: "p2", BaseURL: "http://p2", APIKey: "k2", Priority: 2},
+	}, time.Hour, time.Second, testResponseHeaderTimeout, circuitBreakerConfig{}, store)
+	cp.httpClient.Transport = rt
+	cp.applyRoutingRuntimeSettings(routingRuntimeSettingsFromConfig(config.RoutingConfig{
+		StreamRecovery: config.StreamRecoveryConfig{Enabled: true},
+	}))
+	body := `{"stream":true,"system":[{"type":"text","text":"You are a coding agent working in a large Go repository. Read the surrounding code before editing."}],` +
+		`"tools":[{"name":"bash","description":"Run a shell command","input_schema":{"type":"object","properties":{"command":{"type":"string"}}}}],` +
+		`"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/claudecode/v1/messages", strings.NewReader(body))
+	requestCtx := requestContextForClientPath(ClientClaude, "/v1/messages", false)
+	req = withRequestContext(req, requestCtx)
+	cp.forwardWithFailover(httptest.NewRecorder(), req, "/v1/messages")
+
+	// The system prompt and tools count, not just the message text.
+	want := estimatePromptTokens(requestCtx, newRequestPayload([]byte(body)).jsonRoot())
+	first, ok := store.ProviderSnapshot(string(ClientClaude), "p1")
+	if !ok || first.InputTokens != want || want <= claudeToolsOverheadTokens {
+		t.Fatalf("interrupted provider usage = %#v, want %d input tokens", first, want)
+	}
+}
+
+func TestForwardWithFailover_StreamRecoveryClassifiesRejectedContinuation(t *testing.T) {
+	t.Parallel()
+
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.URL.Host {
+		case "p1":
+			h := make(http.Header)
+			h.Set("Content-Type", "text/event-stream")
+			return &http.Response{StatusCode: http.StatusOK, Header: h, Body: interruptedBody(
+				"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\"}}\n\n" +
+					"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+					"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello\"}}\n\n",
+			)}, nil
+		case "p2":
+			h := make(http.Header)
+			h.Set("Content-Type", "application/json")
+			return newResponse(http.StatusUnauthorized, h, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`), nil
+		default:
+			return nil, errors.New("unexpected host")
+		}
+	})
+
+	cp := newStreamRecoveryTestProxy(ClientClaude, rt)
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/claudecode/v1/messages", bytes.NewReader([]byte(`{"stream":true,"messages":[{"role":"user","content":"hi"}]}`)))
+	req = withRequestContext(req, requestContextForClientPath(ClientClaude, "/v1/messages", false))
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, req, "/v1/messages")
+
+	if !cp.isKeyDeactivated(1, 0) || !cp.isDeactivated(1) {
+		t.Fatalf("expected the rejected key and its provider to be deactivated")
+	}
+	if last := cp.lastRequest; last.Delivery != string(deliveryCommittedPartial) || last.RecoveredFrom != "" {
+		t.Fatalf("last request: %+v", last)
+	}
+}
+
+func TestStreamSplice_DropsRepeatedPrefillWhitespace(t *testing.T) {
+	t.Parallel()
+
+	delta := func(text string) string {
+		return "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"" + text + "\"}}\n\n"
+	}
+	for _, tt := range []struct {
+		name   string
+		deltas []string
+		want   string
+	}{
+		{name: "repeated", deltas: []string{" ", "\\nnext"}, want: "next"},
+		{name: "not repeated", deltas: []string{"next"}, want: "next"},
+		{name: "different whitespace", deltas: []string{"\\tnext"}, want: "\\tnext"},
+	} {
+		rec := &streamRecovery{kind: streamProtocolClaude, openBlock: 0, nextBlock: 1}
+		rec.text.WriteString("Hello \n")
+		if _, err := rec.continuationBody([]byte(`{"messages":[{"role":"user","content":"q"}]}`)); err != nil {
+			t.Fatalf("continuationBody: %v", err)
+		}
+		splice := rec.newSplice()
+		var out []byte
+		for _, d := range tt.deltas {
+			out = append(out, splice.transform([]byte(delta(d)))...)
+		}
+		if got := string(out); strings.Count(got, "content_block_delta\n") != 1 || !strings.Contains(got, `"text":"`+tt.want+`"`) {
+			t.Fatalf("%s: spliced = %q", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,493 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/logger"
+)
+
+const failureRuleBodyLimit = 32 * 1024
+
+type failureRuleSource string
+
+const (
+	failureRuleSourceProvider failureRuleSource = "provider"
+	failureRuleSourceGlobal   failureRuleSource = "global"
+)
+
+type compiledFailureRule struct {
+	name      string
+	source    failureRuleSource
+	index     int
+	statuses  map[int]struct{}
+	headers   map[string]*regexp.Regexp
+	body      *regexp.Regexp
+	jsonPath  []string
+	jsonValue *regexp.Regexp
+	action    config.FailureRuleAction
+	reason    string
+	cooldown  time.Duration
+}
+
+type failureRuleSet []compiledFailureRule
+
+// failureRuleMatch is the outcome of a matched rule, expressed in terms of the
+// built-in failover actions plus whether the failure is scoped to a single key.
+type failureRuleMatch struct {
+	rule      compiledFailureRule
+	action    failureAction
+	reason    string
+	cooldown  time.Duration
+	keyScoped bool
+}
+
+func compileFailureRules(source failureRuleSource, rules []config.FailureRule) (failureRuleSet, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	out := make(failureRuleSet, 0, len(rules))
+	for i, rule := range rules {
+		compiled, err := compileFailureRule(source, i, rule)
+		if err != nil {
+			return nil, fmt.Errorf("failure rule %s: %w", rule.Label(i), err)
+		}
+		out = append(out, compiled)
+	}
+	return out, nil
+}
+
+func compileFailureRule(source failureRuleSource, index int, rule config.FailureRule) (compiledFailureRule, error) {
+	compiled := compiledFailureRule{
+		name:   rule.Label(index),
+		source: source,
+		index:  index,
+		action: rule.NormalizedAction(),
+		reason: rule.NormalizedReason(),
+	}
+	if len(rule.Status) > 0 {
+		compiled.statuses = make(map[int]struct{}, len(rule.Status))
+		for _, status := range rule.Status {
+			compiled.statuses[status] = struct{}{}
+		}
+	}
+	if len(rule.Headers) > 0 {
+		compiled.headers = make(map[string]*regexp.Regexp, len(rule.Headers))
+		for name, pattern := range rule.Headers {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return compiledFailureRule{}, err
+			}
+			compiled.headers[http.CanonicalHeaderKey(strings.TrimSpace(name))] = re
+		}
+	}
+	if pattern := strings.TrimSpace(rule.BodyRegex); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return compiledFailureRule{}, err
+		}
+		compiled.body = re
+	}
+	if path := strings.TrimSpace(rule.JSONPath); path != "" {
+		segments, err := config.ParseFailureRuleJSONPath(path)
+		if err != nil {
+			return compiledFailureRule{}, err
+		}
+		compiled.jsonPath = segments
+	}
+	if pattern := strings.TrimSpace(rule.JSONValue); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return compiledFailureRule{}, err
+		}
+		compiled.jsonValue = re
+	}
+	cooldown, err := rule.CooldownDuration()
+	if err != nil {
+		return compiledFailureRule{}, err
+	}
+	compiled.cooldown = cooldown
+	return compiled, nil
+}
+
+// appliesToStatus reports whether the rule can match a response with this status
+// code. Rules without a status list only apply to non-2xx responses.
+func (r compiledFailureRule) appliesToStatus(status int) bool {
+	if len(r.statuses) == 0 {
+		return status < http.StatusOK || status >= http.StatusMultipleChoices
+	}
+	_, ok := r.statuses[status]
+	return ok
+}
+
+func (r compiledFailureRule) matches(status int, hdr http.Header, body []byte) bool {
+	if !r.appliesToStatus(status) {
+		return false
+	}
+	for name, re := range r.headers {
+		values := hdr.Values(name)
+		if len(values) == 0 {
+			return false
+		}
+		if !re.MatchString(strings.Join(values, ", ")) {
+			return false
+		}
+	}
+	if r.body != nil && !r.body.Match(body) {
+		return false
+	}
+	if len(r.jsonPath) > 0 {
+		value, ok := failureRuleJSONValue(body, r.jsonPath)
+		if !ok {
+			return false
+		}
+		if r.jsonValue != nil && !r.jsonValue.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// inspects reports whether any rule applies to the status code, meaning the
+// response body has to be read before it can be classified.
+func (s failureRuleSet) inspects(status int) bool {
+	for _, rule := range s {
+		if rule.appliesToStatus(status) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s failureRuleSet) match(status int, hdr http.Header, body []byte, reactivateAfter time.Duration) (failureRuleMatch, bool) {
+	for _, rule := range s {
+		if !rule.matches(status, hdr, body) {
+			continue
+		}
+		out := failureRuleMatch{rule: rule, reason: rule.reason, cooldown: rule.cooldown}
+		switch rule.action {
+		case config.FailureRuleActionReturnToClient:
+			out.action = failureReturnToClient
+		case config.FailureRuleActionBusyRetry:
+			out.action = failureBusyRetry
+		case config.FailureRuleActionRetryNext:
+			out.action = failureRetryNext
+			if out.cooldown == 0 {
+				out.cooldown = retryAfterDuration(hdr)
+			}
+		case config.FailureRuleActionDeactivateKey:
+			out.action = failureDeactivateAndRetryNext
+			out.keyScoped = true
+			if out.cooldown == 0 {
+				out.cooldown = reactivateAfter
+			}
+		default:
+			out.action = failureDeactivateAndRetryNext
+			if out.cooldown == 0 {
+				out.cooldown = reactivateAfter
+			}
+		}
+		return out, true
+	}
+	return failureRuleMatch{}, false
+}
+
+// failureRulesFor returns the rules evaluated for a provider: its own rules first,
+// then the global routing rules.
+func (cp *ClientProxy) failureRulesFor(index int) failureRuleSet {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+	var providerRules failureRuleSet
+	if index >= 0 && index < len(cp.providerFailureRules) {
+		providerRules = cp.providerFailureRules[index]
+	}
+	if len(providerRules) == 0 {
+		return cp.routing.failureRules
+	}
+	if len(cp.routing.failureRules) == 0 {
+		return providerRules
+	}
+	out := make(failureRuleSet, 0, len(providerRules)+len(cp.routing.failureRules))
+	out = append(out, providerRules...)
+	return append(out, cp.routing.failureRules...)
+}
+
+// attemptFailure is the classification of one non-success upstream response.
+type attemptFailure struct {
+	action      failureAction
+	reason      string
+	msg         string
+	cooldown    time.Duration
+	keyScoped   bool
+	ruleName    string
+	ruleMatched bool
+}
+
+// classifyAttemptResponse applies the provider's failure rules and then the
+// built-in classification to an upstream response. Statuses neither inspects
+// are returned to the client.
+func (cp *ClientProxy) classifyAttemptResponse(ctx context.Context, resp *http.Response, cancelAttempt context.CancelCauseFunc, provider config.Provider, index int, path string) attemptFailure {
+	var out attemptFailure
+	rules := cp.failureRulesFor(index)
+	var ruleBody []byte
+	if rules.inspects(resp.StatusCode) {
+		ruleBody = cp.bufferResponseForRules(resp, cancelAttempt)
+		if match, ok := rules.match(resp.StatusCode, resp.Header, ruleBody, cp.reactivateAfter); ok {
+			out.ruleMatched = true
+			out.ruleName = match.rule.name
+			out.action, out.reason, out.cooldown, out.keyScoped = match.action, match.reason, match.cooldown, match.keyScoped
+			out.msg = truncateString(sanitizeLogString(string(ruleBody)), 2048)
+			logger.Debug("[%s] provider=%s status=%d matched %s failure rule %s", cp.clientType, provider.Name, resp.StatusCode, match.rule.source, out.ruleName)
+			return out
+		}
+	}
+	if !inspectsUpstreamFailure(resp.StatusCode) {
+		out.action = failureReturnToClient
+		return out
+	}
+	body, truncated := ruleBody, len(ruleBody) >= failureRuleBodyLimit
+	if body == nil {
+		body, truncated = readResponseBodyBytes(resp, 32*1024)
+	}
+	out.action, out.reason, out.msg, out.cooldown = classifyUpstreamFailure(resp.StatusCode, resp.Header, body, truncated)
+	out.keyScoped = isKeyScopedFailure(out.reason)
+	if resp.StatusCode == http.StatusTooManyRequests && provider.UsesOAuth() && isOAuthCooldownReason(out.reason) {
+		out.cooldown = cp.oauthCooldownForFailure(ctx, provider, index, path, resp.Header, body, out.cooldown)
+	}
+	return out
+}
+
+func compileProviderFailureRules(providers []config.Provider) []failureRuleSet {
+	out := make([]failureRuleSet, len(providers))
+	for i := range providers {
+		rules, err := compileFailureRules(failureRuleSourceProvider, providers[i].FailureRules)
+		if err != nil {
+			logger.Warn("ignoring failure rules for provider %s: %v", providers[i].Name, err)
+			continue
+		}
+		out[i] = rules
+	}
+	return out
+}
+
+func failureRuleJSONValue(body []byte, path []string) (string, bool) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return "", false
+	}
+	var root any
+	if err := json.Unmarshal(body, &root); err == nil {
+		return lookupFailureRuleJSONPath(root, path)
+	}
+	// Streaming responses: try each SSE data payload in order.
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 4096), len(body)+1)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "" || payload == "[DONE]" {
+			continue
+		}
+		var event any
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			continue
+		}
+		if value, ok := lookupFailureRuleJSONPath(event, path); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func lookupFailureRuleJSONPath(root any, path []string) (string, bool) {
+	current := root
+	for _, segment := range path {
+		switch typed := current.(type) {
+		case map[string]any:
+			next, ok := typed[segment]
+			if !ok {
+				return "", false
+			}
+			current = next
+		case []any:
+			idx, err := strconv.Atoi(segment)
+			if err != nil || idx < 0 || idx >= len(typed) {
+				return "", false
+			}
+			current = typed[idx]
+		default:
+			return "", false
+		}
+	}
+	switch typed := current.(type) {
+	case nil:
+		return "", false
+	case string:
+		return typed, true
+	case bool:
+		return strconv.FormatBool(typed), true
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64), true
+	default:
+		encoded, err := json.Marshal(typed)
+		if err != nil {
+			return "", false
+		}
+		return string(encoded), true
+	}
+}
+
+// replayBody re-emits bytes that were read for classification before continuing
+// with the original upstream body.
+type replayBody struct {
+	prefix *bytes.Reader
+	err    error
+	body   io.ReadCloser
+}
+
+func (b *replayBody) Read(p []byte) (int, error) {
+	if b.prefix.Len() > 0 {
+		return b.prefix.Read(p)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.body.Read(p)
+}
+
+func (b *replayBody) Close() error {
+	return b.body.Close()
+}
+
+// bufferResponseForRules reads the start of an upstream body so failure rules can
+// inspect it, then restores resp.Body so the response can still be relayed.
+// Successful responses may be long-lived streams, so only their first chunk is
+// read, guarded by the upstream idle timeout.
+func (cp *ClientProxy) bufferResponseForRules(resp *http.Response, cancelAttempt context.CancelCauseFunc) []byte {
+	if resp == nil || resp.Body == nil {
+		return nil
+	}
+	var (
+		raw []byte
+		err error
+	)
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		var idleTimer *time.Timer
+		if cp.upstreamIdle > 0 && cancelAttempt != nil {
+			idleTimer = time.AfterFunc(cp.upstreamIdle, func() { cancelAttempt(errUpstreamIdleTimeout) })
+		}
+		buf := make([]byte, failureRuleBodyLimit)
+		n, readErr := resp.Body.Read(buf)
+		stopTimer(idleTimer)
+		raw, err = buf[:n], readErr
+	} else {
+		raw, err = io.ReadAll(io.LimitReader(resp.Body, failureRuleBodyLimit))
+	}
+	resp.Body = &replayBody{prefix: bytes.NewReader(raw), err: err, body: resp.Body}
+	return decodeFailureRuleBody(resp.Header, raw)
+}
+
+func decodeFailureRuleBody(hdr http.Header, raw []byte) []byte {
+	enc := strings.ToLower(strings.TrimSpace(hdr.Get("Content-Encoding")))
+	isGzip := strings.Contains(enc, "gzip") || (len(raw) >= 2 && raw[0] == 0x1f && raw[1] == 0x8b)
+	if !isGzip {
+		return raw
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return raw
+	}
+	defer func() { _ = gz.Close() }()
+	// A truncated gzip stream still yields its decodable prefix.
+	decoded, _ := io.ReadAll(io.LimitReader(gz, failureRuleBodyLimit))
+	return decoded
+}
+
+// FailureRuleSample is an upstream response used to preview failure classification.
+type FailureRuleSample struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// FailureRuleEvaluation describes how the proxy would handle a sample response.
+// Source is "provider" or "global" for a matched rule and "builtin" otherwise.
+type FailureRuleEvaluation struct {
+	Matched   bool
+	Source    string
+	RuleIndex int
+	RuleName  string
+	Action    config.FailureRuleAction
+	Reason    string
+	Cooldown  time.Duration
+}
+
+// EvaluateFailureRules classifies a sample response the same way the failover
+// loop does: provider rules first, then global rules, then the built-in rules.
+func EvaluateFailureRules(providerRules []config.FailureRule, globalRules []config.FailureRule, sample FailureRuleSample, reactivateAfter time.Duration) (FailureRuleEvaluation, error) {
+	compiledProvider, err := compileFailureRules(failureRuleSourceProvider, providerRules)
+	if err != nil {
+		return FailureRuleEvaluation{}, err
+	}
+	compiledGlobal, err := compileFailureRules(failureRuleSourceGlobal, globalRules)
+	if err != nil {
+		return FailureRuleEvaluation{}, err
+	}
+	rules := append(append(failureRuleSet{}, compiledProvider...), compiledGlobal...)
+	return rules.evaluate(sample, reactivateAfter), nil
+}
+
+// evaluate classifies a response with these rules, falling back to the
+// built-in classification.
+func (s failureRuleSet) evaluate(sample FailureRuleSample, reactivateAfter time.Duration) FailureRuleEvaluation {
+	hdr := sample.Header
+	if hdr == nil {
+		hdr = http.Header{}
+	}
+	if match, ok := s.match(sample.Status, hdr, sample.Body, reactivateAfter); ok {
+		return FailureRuleEvaluation{
+			Matched:   true,
+			Source:    string(match.rule.source),
+			RuleIndex: match.rule.index,
+			RuleName:  match.rule.name,
+			Action:    match.rule.action,
+			Reason:    match.reason,
+			Cooldown:  match.cooldown,
+		}
+	}
+
+	out := FailureRuleEvaluation{Source: "builtin", RuleIndex: -1, Action: config.FailureRuleActionReturnToClient}
+	if !inspectsUpstreamFailure(sample.Status) {
+		return out
+	}
+	action, reason, _, cooldown := classifyUpstreamFailure(sample.Status, hdr, sample.Body, false)
+	out.Reason = reason
+	out.Cooldown = cooldown
+	switch action {
+	case failureRetryNext:
+		out.Action = config.FailureRuleActionRetryNext
+	case failureBusyRetry:
+		out.Action = config.FailureRuleActionBusyRetry
+	case failureDeactivateAndRetryNext:
+		out.Action = config.FailureRuleActionDeactivateProvider
+		if isKeyScopedFailure(reason) {
+			out.Action = config.FailureRuleActionDeactivateKey
+		}
+		out.Cooldown = keyFailureDuration(reason, cooldown, reactivateAfter)
+	}
+	return out
+}
@@ -0,0 +1,241 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+)
+
+func TestForwardWithFailover_FailureRuleDeactivatesProviderOn400(t *testing.T) {
+	t.Parallel()
+
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.URL.Host {
+		case "p1":
+			return newResponse(http.StatusBadRequest, nil, `{"error":{"message":"insufficient balance"}}`), nil
+		case "p2":
+			return newResponse(http.StatusOK, nil, "ok"), nil
+		default:
+			return nil, errors.New("unexpected host")
+		}
+	})
+
+	cp := newClientProxy(ClientOpenAI, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1, FailureRules: []config.FailureRule{{
+			Name:      "balance",
+			Status:    []int{http.StatusBadRequest},
+			BodyRegex: "(?i)insufficient balance",
+			Action:    config.FailureRuleActionDeactivateProvider,
+			Reason:    "billing",
+			Cooldown:  "10m",
+		}}},
+		{Name: "p2", BaseURL: "http://p2", APIKey: "k2", Priority: 2},
+	}, time.Hour, 0, testResponseHeaderTimeout, circuitBreakerConfig{})
+	cp.httpClient.Transport = rt
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/codex/v1/test", bytes.NewReader([]byte(`{"x":1}`)))
+	cp.forwardWithFailover(rr, req, "/v1/test")
+
+	if rr.Code != http.StatusOK || rr.Body.String() != "ok" {
+		t.Fatalf("response: got %d %q", rr.Code, rr.Body.String())
+	}
+	if !cp.isDeactivated(0) {
+		t.Fatalf("expected provider 0 to be deactivated")
+	}
+	if got := cp.deactivated[0].reason; got != "billing" {
+		t.Fatalf("reason: got %q want %q", got, "billing")
+	}
+	if wait := time.Until(cp.deactivated[0].until); wait > 10*time.Minute || wait < 9*time.Minute {
+		t.Fatalf("cooldown: got %s want about 10m", wait)
+	}
+}
+
+func TestForwardWithFailover_GlobalFailureRuleRetriesOnSSEErrorEvent(t *testing.T) {
+	t.Parallel()
+
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.URL.Host {
+		case "p1":
+			h := make(http.Header)
+			h.Set("Content-Type", "text/event-stream")
+			return newResponse(http.StatusOK, h, "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\"}}\n\n"), nil
+		case "p2":
+			return newResponse(http.StatusOK, nil, "ok"), nil
+		default:
+			return nil, errors.New("unexpected host")
+		}
+	})
+
+	cp := newClientProxy(ClientClaude, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1},
+		{Name: "p2", BaseURL: "http://p2", APIKey: "k2", Priority: 2},
+	}, time.Hour, 0, testResponseHeaderTimeout, circuitBreakerConfig{})
+	cp.httpClient.Transport = rt
+	cp.applyRoutingRuntimeSettings(routingRuntimeSettingsFromConfig(config.RoutingConfig{
+		FailureRules: []config.FailureRule{{
+			Status:    []int{http.StatusOK},
+			JSONPath:  "error.type",
+			JSONValue: "^overloaded_error$",
+			Action:    config.FailureRuleActionRetryNext,
+			Reason:    "overloaded",
+		}},
+	}))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/claudecode/v1/messages", bytes.NewReader([]byte(`{"x":1}`)))
+	cp.forwardWithFailover(rr, req, "/v1/messages")
+
+	if rr.Code != http.StatusOK || rr.Body.String() != "ok" {
+		t.Fatalf("response: got %d %q", rr.Code, rr.Body.String())
+	}
+	if cp.isDeactivated(0) {
+		t.Fatalf("retry_next without cooldown should not deactivate provider 0")
+	}
+	if got := cp.getCurrentIndex(); got != 1 {
+		t.Fatalf("currentIndex: got %d want %d", got, 1)
+	}
+}
+
+func TestForwardWithFailover_FailureRuleReturnToClientRelaysBody(t *testing.T) {
+	t.Parallel()
+
+	const upstreamBody = `{"error":{"type":"rate_limit_error","message":"region blocked"}}`
+	calls := 0
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return newResponse(http.StatusTooManyRequests, nil, upstreamBody), nil
+	})
+
+	cp := newClientProxy(ClientOpenAI, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1, FailureRules: []config.FailureRule{{
+			BodyRegex: "region blocked",
+			Action:    config.FailureRuleActionReturnToClient,
+		}}},
+		{Name: "p2", BaseURL: "http://p2", APIKey: "k2", Priority: 2},
+	}, time.Hour, 0, testResponseHeaderTimeout, circuitBreakerConfig{})
+	cp.httpClient.Transport = rt
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/codex/v1/test", bytes.NewReader([]byte(`{"x":1}`)))
+	cp.forwardWithFailover(rr, req, "/v1/test")
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("status: got %d want %d", rr.Code, http.StatusTooManyRequests)
+	}
+	if got := rr.Body.String(); got != upstreamBody {
+		t.Fatalf("body: got %q want %q", got, upstreamBody)
+	}
+	if calls != 1 {
+		t.Fatalf("upstream calls: got %d want 1", calls)
+	}
+	if cp.isDeactivated(0) {
+		t.Fatalf("return_to_client should not deactivate provider 0")
+	}
+}
+
+func TestForwardWithFailover_FailureRuleDeactivatesOnlyMatchedKey(t *testing.T) {
+	t.Parallel()
+
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Header.Get("Authorization") == "Bearer k1" {
+			h := make(http.Header)
+			h.Set("X-Key-State", "suspended")
+			return newResponse(http.StatusForbidden, h, `{"error":"forbidden"}`), nil
+		}
+		return newResponse(http.StatusOK, nil, "ok"), nil
+	})
+
+	cp := newClientProxy(ClientOpenAI, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKeys: []string{"k1", "k2"}, Priority: 1, FailureRules: []config.FailureRule{{
+			Headers:  map[string]string{"x-key-state": "^suspended$"},
+			Action:   config.FailureRuleActionDeactivateKey,
+			Cooldown: "5m",
+		}}},
+	}, time.Hour, 0, testResponseHeaderTimeout, circuitBreakerConfig{})
+	cp.httpClient.Transport = rt
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/codex/v1/test", bytes.NewReader([]byte(`{"x":1}`)))
+	cp.forwardWithFailover(rr, req, "/v1/test")
+
+	if rr.Code != http.StatusOK || rr.Body.String() != "ok" {
+		t.Fatalf("response: got %d %q", rr.Code, rr.Body.String())
+	}
+	if !cp.isKeyDeactivated(0, 0) {
+		t.Fatalf("expected key 0 to be deactivated")
+	}
+	if cp.isKeyDeactivated(0, 1) || cp.isDeactivated(0) {
+		t.Fatalf("expected key 1 and provider 0 to stay available")
+	}
+	if got := cp.keyDeactivated[0][0].reason; got != config.FailureRuleReason {
+		t.Fatalf("key reason: got %q want %q", got, config.FailureRuleReason)
+	}
+}
+
+func TestEvaluateFailureRules_ProviderRulesWinThenBuiltinFallback(t *testing.T) {
+	t.Parallel()
+
+	providerRules := []config.FailureRule{{Name: "provider-403", Status: []int{http.StatusForbidden}, Action: config.FailureRuleActionRetryNext, Cooldown: "30s"}}
+	globalRules := []config.FailureRule{{Name: "global-403", Status: []int{http.StatusForbidden}, Action: config.FailureRuleActionReturnToClient}}
+
+	got, err := EvaluateFailureRules(providerRules, globalRules, FailureRuleSample{Status: http.StatusForbidden}, time.Hour)
+	if err != nil {
+		t.Fatalf("EvaluateFailureRules: %v", err)
+	}
+	if !got.Matched || got.Source != "provider" || got.RuleName != "provider-403" || got.Action != config.FailureRuleActionRetryNext || got.Cooldown != 30*time.Second {
+		t.Fatalf("provider evaluation: %+v", got)
+	}
+
+	got, err = EvaluateFailureRules(nil, globalRules, FailureRuleSample{Status: http.StatusUnauthorized}, time.Hour)
+	if err != nil {
+		t.Fatalf("EvaluateFailureRules: %v", err)
+	}
+	if got.Matched || got.Source != "builtin" || got.Action != config.FailureRuleActionDeactivateKey || got.Reason != "auth" || got.Cooldown != time.Hour {
+		t.Fatalf("builtin evaluation: %+v", got)
+	}
+
+	got, err = EvaluateFailureRules(nil, nil, FailureRuleSample{Status: http.StatusBadRequest}, time.Hour)
+	if err != nil {
+		t.Fatalf("EvaluateFailureRules: %v", err)
+	}
+	if got.Matched || got.Action != config.FailureRuleActionReturnToClient {
+		t.Fatalf("passthrough evaluation: %+v", got)
+	}
+}
+
+func TestFailureRuleJSONValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		body string
+		path string
+		want string
+		ok   bool
+	}{
+		{name: "nested string", body: `{"error":{"code":"insufficient_balance"}}`, path: "error.code", want: "insufficient_balance", ok: true},
+		{name: "array index", body: `{"error":{"details":[{"reason":"REGION"}]}}`, path: "error.details[0].reason", want: "REGION", ok: true},
+		{name: "number", body: `{"code":4031}`, path: "code", want: "4031", ok: true},
+		{name: "missing", body: `{"error":{}}`, path: "error.code", ok: false},
+		{name: "sse data", body: "event: ping\ndata: {}\n\nevent: error\ndata: {\"type\":\"error\"}\n\n", path: "type", want: "error", ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			segments, err := config.ParseFailureRuleJSONPath(tt.path)
+			if err != nil {
+				t.Fatalf("ParseFailureRuleJSONPath: %v", err)
+			}
+			got, ok := failureRuleJSONValue([]byte(tt.body), segments)
+			if ok != tt.ok || got != tt.want {
+				t.Fatalf("got (%q, %v) want (%q, %v)", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
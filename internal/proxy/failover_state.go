@@ -41,15 +41,6 @@ func setRetryAfterHeader(w http.ResponseWriter, wait time.Duration) {
 	w.Header().Set("Retry-After", strconv.Itoa(secs))
 }
 
-func writeProxyError(w http.ResponseWriter, msg string, status int) {
-	// Only attach Retry-After for retryable errors we generate locally.
-	// Upstream responses are streamed through as-is.
-	if status == http.StatusTooManyRequests || shouldRetry(status) {
-		setRetryAfterHeader(w, time.Second)
-	}
-	http.Error(w, msg, status)
-}
-
 func (cp *ClientProxy) reactivateExpired() {
 	now := time.Now()
 
@@ -71,8 +62,10 @@ func (cp *ClientProxy) reactivateExpired() {
 		}
 		detail := reactivationDetail(d.reason)
 		cp.deactivated[i] = providerDeactivation{}
+		cp.clearProviderControlLocked(i)
 		if i < len(cp.providers) {
 			logger.Info("[%s] provider %s %s", cp.clientType, cp.providers[i].Name, detail)
+			cp.publishEvent(Event{At: now, Type: EventProviderReactivated, Provider: cp.providers[i].Name, Reason: d.reason, Detail: detail})
 		} else {
 			logger.Info("[%s] provider #%d %s", cp.clientType, i, detail)
 		}
@@ -86,8 +79,10 @@ func (cp *ClientProxy) reactivateExpired() {
 				continue
 			}
 			cp.keyDeactivated[i][j] = providerDeactivation{}
+			cp.clearProviderControlLocked(i)
 			if i < len(cp.providers) {
 				logger.Info("[%s] provider %s key %d/%d reactivated", cp.clientType, cp.providers[i].Name, j+1, len(cp.providerKeys[i]))
+				cp.publishEvent(Event{At: now, Type: EventProviderReactivated, Provider: cp.providers[i].Name, Key: j + 1, Reason: d.reason})
 			}
 		}
 	}
@@ -107,22 +102,30 @@ func (cp *ClientProxy) deactivateFor(index int, reason string, status int, msg s
 	if d <= 0 {
 		return
 	}
+	now := time.Now()
+	cp.deactivate(index, providerDeactivation{at: now, until: now.Add(d), reason: reason, status: status, message: msg}, false)
+}
+
+// deactivate takes the provider out of rotation until deactivation.until. An
+// active deactivation is kept unless replace is set, as it is for operators;
+// without replace this is an automatic state change and drops the note of the
+// last manual control.
+func (cp *ClientProxy) deactivate(index int, deactivation providerDeactivation, replace bool) {
 	cp.mu.Lock()
 	defer cp.mu.Unlock()
 	if index < 0 || index >= len(cp.deactivated) {
 		return
 	}
-	if !cp.deactivated[index].until.IsZero() && time.Now().Before(cp.deactivated[index].until) {
-		return
-	}
-	now := time.Now()
-	cp.deactivated[index] = providerDeactivation{
-		at:      now,
-		until:   now.Add(d),
-		reason:  reason,
-		status:  status,
-		message: msg,
+	now := deactivation.at
+	if !replace {
+		if !cp.deactivated[index].until.IsZero() && now.Before(cp.deactivated[index].until) {
+			return
+		}
+		cp.clearProviderControlLocked(index)
 	}
+	cp.deactivated[index] = deactivation
+	until := deactivation.until
+	cp.publishEvent(Event{At: now, Type: EventProviderDeactivated, Provider: cp.providers[index].Name, Status: deactivation.status, Reason: deactivation.reason, Detail: deactivation.message, Until: &until})
 
 	// If a routing cursor was deactivated, move it forward within its scope.
 	if cp.mode != config.ClientModeManual {
@@ -151,6 +154,15 @@ func (cp *ClientProxy) markProviderBusy(index int, reason string, backoffStep in
 		busy.Reason = reason
 	}
 	cp.providerBusy[index] = busy
+	cp.clearProviderControlLocked(index)
+}
+
+// clearProviderControlLocked forgets the last manual control of the provider
+// once an automatic state change makes its note outdated.
+func (cp *ClientProxy) clearProviderControlLocked(index int) {
+	if index >= 0 && index < len(cp.providerControls) {
+		cp.providerControls[index] = providerControl{}
+	}
 }
 
 func (cp *ClientProxy) providerBusySnapshot(index int) providerBusyState {
@@ -358,9 +370,18 @@ func (cp *ClientProxy) preferredKeyIndexForScope(providerIndex int, scope routin
 	return cur[providerIndex]
 }
 
-func (cp *ClientProxy) getActiveKeyCountAndStartIndexForScope(providerIndex int, scope routingScope) (active int, startIndex int) {
-	cp.mu.Lock()
-	defer cp.mu.Unlock()
+// getActiveKeyCountAndStartIndexForScope counts the usable keys of the
+// provider and returns the key to start with: the first active key from from,
+// or from the scope's key cursor when from is negative. The cursor is moved to
+// that key unless peek is set, as it is for the routing explain endpoint.
+func (cp *ClientProxy) getActiveKeyCountAndStartIndexForScope(providerIndex int, scope routingScope, from int, peek bool) (active int, startIndex int) {
+	if peek {
+		cp.mu.RLock()
+		defer cp.mu.RUnlock()
+	} else {
+		cp.mu.Lock()
+		defer cp.mu.Unlock()
+	}
 	now := time.Now()
 	if providerIndex < 0 || providerIndex >= len(cp.providerKeys) || len(cp.providerKeys[providerIndex]) == 0 {
 		return 0, 0
@@ -370,14 +391,17 @@ func (cp *ClientProxy) getActiveKeyCountAndStartIndexForScope(providerIndex int,
 		return 0, 0
 	}
 	cur := cp.keyIndicesForScopeLocked(scope)
-	if cur == nil || providerIndex >= len(cur) {
-		return active, 0
+	if from < 0 && providerIndex < len(cur) {
+		from = cur[providerIndex]
 	}
-	if cur[providerIndex] < 0 || cur[providerIndex] >= len(cp.providerKeys[providerIndex]) {
-		cur[providerIndex] = 0
+	if from < 0 || from >= len(cp.providerKeys[providerIndex]) {
+		from = 0
 	}
-	cur[providerIndex] = cp.nextActiveKeyIndexLocked(providerIndex, cur[providerIndex], now)
-	return active, cur[providerIndex]
+	startIndex = cp.nextActiveKeyIndexLocked(providerIndex, from, now)
+	if !peek && providerIndex < len(cur) {
+		cur[providerIndex] = startIndex
+	}
+	return active, startIndex
 }
 
 func (cp *ClientProxy) activeKeyCount(providerIndex int) int {
@@ -396,6 +420,12 @@ func (cp *ClientProxy) deactivateKeyFor(providerIndex int, keyIndex int, reason
 	if d <= 0 {
 		return
 	}
+	now := time.Now()
+	cp.deactivateKey(providerIndex, keyIndex, providerDeactivation{at: now, until: now.Add(d), reason: reason, status: status, message: msg}, false)
+}
+
+// deactivateKey is deactivate for one key of the provider.
+func (cp *ClientProxy) deactivateKey(providerIndex int, keyIndex int, deactivation providerDeactivation, replace bool) {
 	cp.mu.Lock()
 	defer cp.mu.Unlock()
 	if providerIndex < 0 || providerIndex >= len(cp.keyDeactivated) {
@@ -404,17 +434,16 @@ func (cp *ClientProxy) deactivateKeyFor(providerIndex int, keyIndex int, reason
 	if keyIndex < 0 || keyIndex >= len(cp.keyDeactivated[providerIndex]) {
 		return
 	}
-	now := time.Now()
-	if !cp.keyDeactivated[providerIndex][keyIndex].until.IsZero() && now.Before(cp.keyDeactivated[providerIndex][keyIndex].until) {
-		return
-	}
-	cp.keyDeactivated[providerIndex][keyIndex] = providerDeactivation{
-		at:      now,
-		until:   now.Add(d),
-		reason:  reason,
-		status:  status,
-		message: msg,
+	now := deactivation.at
+	if !replace {
+		if !cp.keyDeactivated[providerIndex][keyIndex].until.IsZero() && now.Before(cp.keyDeactivated[providerIndex][keyIndex].until) {
+			return
+		}
+		cp.clearProviderControlLocked(providerIndex)
 	}
+	cp.keyDeactivated[providerIndex][keyIndex] = deactivation
+	until := deactivation.until
+	cp.publishEvent(Event{At: now, Type: EventProviderDeactivated, Provider: cp.providers[providerIndex].Name, Key: keyIndex + 1, Status: deactivation.status, Reason: deactivation.reason, Detail: deactivation.message, Until: &until})
 	for _, cur := range [][]int{cp.currentKeyIndex, cp.countTokensKeyIndex, cp.responsesKeyIndex, cp.geminiStreamKeyIndex} {
 		if providerIndex < len(cur) && cur[providerIndex] == keyIndex {
 			cur[providerIndex] = cp.nextActiveKeyIndexLocked(providerIndex, keyIndex, now)
@@ -525,9 +554,17 @@ func (cp *ClientProxy) providerAvailableForCapabilityLocked(providerIndex int, n
 	return cp.providerAvailableForRoutingLocked(providerIndex, now)
 }
 
-func (cp *ClientProxy) getActiveCountAndStartIndexForScope(scope routingScope, capability RequestCapability) (active int, startIndex int) {
-	cp.mu.Lock()
-	defer cp.mu.Unlock()
+// getActiveCountAndStartIndexForScope counts the providers that can take a
+// request of capability and returns the provider to start with, moving the
+// scope's routing cursor to it unless peek is set.
+func (cp *ClientProxy) getActiveCountAndStartIndexForScope(scope routingScope, capability RequestCapability, peek bool) (active int, startIndex int) {
+	if peek {
+		cp.mu.RLock()
+		defer cp.mu.RUnlock()
+	} else {
+		cp.mu.Lock()
+		defer cp.mu.Unlock()
+	}
 	now := time.Now()
 
 	// Count active providers.
@@ -540,26 +577,62 @@ func (cp *ClientProxy) getActiveCountAndStartIndexForScope(scope routingScope, c
 		return active, 0
 	}
 
-	startIndex = cp.ensureActiveScopeIndexLocked(scope, now, capability)
+	startIndex = cp.activeScopeIndexLocked(scope, now, capability, peek)
 	return active, startIndex
 }
 
+type candidateSkipReason string
+
+const (
+	candidateSkipCapability  candidateSkipReason = "capability"
+	candidateSkipDeactivated candidateSkipReason = "deactivated"
+	candidateSkipNoKeys      candidateSkipReason = "no_keys"
+)
+
+// candidateSkip returns why forwardWithFailover skips the provider before
+// asking its circuit breaker, or "" when the provider can be tried.
+func (cp *ClientProxy) candidateSkip(index int, capability RequestCapability, now time.Time) candidateSkipReason {
+	if !providerSupportsCapability(cp.providers[index], capability) {
+		return candidateSkipCapability
+	}
+
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+	if d := cp.deactivated[index]; !d.until.IsZero() && now.Before(d.until) {
+		return candidateSkipDeactivated
+	}
+	if cp.availableKeyCountLocked(index, now) == 0 {
+		return candidateSkipNoKeys
+	}
+	return ""
+}
+
+// candidateBusyWait returns the busy backoff of the provider and whether it is
+// skipped for it. Only the preferred provider waits, and only for up to
+// routing.busy_backpressure's inline wait.
+func (cp *ClientProxy) candidateBusyWait(index int, preferredIndex int, now time.Time) (wait time.Duration, skip bool) {
+	wait, ok := cp.providerBusyWait(index, now)
+	if !ok {
+		return 0, false
+	}
+	return wait, index != preferredIndex || wait > cp.routing.maxInlineWait
+}
+
 // handleAllUnavailable writes a Retry-After response if all providers are temporarily unavailable.
 // Returns true if a response was written, false otherwise.
-func (cp *ClientProxy) handleAllUnavailable(w http.ResponseWriter) bool {
+func (cp *ClientProxy) handleAllUnavailable(w http.ResponseWriter, req *http.Request, payload *requestPayload, detail proxyErrorDetail) bool {
 	wait, reason, ok := cp.timeUntilNextAvailable()
 	if !ok || wait <= 0 {
 		return false
 	}
-	secs := int(wait/time.Second) + 1
-	if secs < 1 {
-		secs = 1
-	}
-	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	detail = detail.withRetryAfter(wait)
+	w.Header().Set("Retry-After", strconv.Itoa(detail.RetryAfterSeconds))
 	if reason == "rate_limit" || reason == "overloaded" {
-		http.Error(w, "All providers are rate limited; retry later", http.StatusTooManyRequests)
+		detail.Reason = "all_providers_rate_limited"
+		writeProxyErrorDetail(w, req, payload, "All providers are rate limited; retry later", http.StatusTooManyRequests, detail)
 	} else {
-		http.Error(w, "All providers are temporarily unavailable; retry later", http.StatusServiceUnavailable)
+		detail.Reason = "all_providers_unavailable"
+		writeProxyErrorDetail(w, req, payload, "All providers are temporarily unavailable; retry later", http.StatusServiceUnavailable, detail)
 	}
 	return true
 }
@@ -605,6 +678,28 @@ func (cp *ClientProxy) countTokensSingleShotTarget() (int, config.Provider, int,
 	return 0, config.Provider{}, 0, false
 }
 
+// pinnedCountTokensTarget returns the pinned provider and key forwardManual
+// would use for req. ok is false when forwardManual would reject the request
+// before contacting the provider.
+func (cp *ClientProxy) pinnedCountTokensTarget(req *http.Request, path string) (int, config.Provider, int, bool) {
+	index := cp.pinnedIndex
+	if index < 0 {
+		index = providerIndexByName(cp.providers, cp.pinnedProvider)
+	}
+	if index < 0 || index >= len(cp.providers) || index >= len(cp.providerKeys) || len(cp.providerKeys[index]) == 0 {
+		return 0, config.Provider{}, 0, false
+	}
+	requestCtx, ok := requestContextFromRequest(req)
+	if !ok {
+		requestCtx = requestContextForClientPath(cp.clientType, path, false)
+	}
+	provider := cp.providers[index]
+	if !providerSupportsCapability(provider, requestCtx.Capability) {
+		return 0, config.Provider{}, 0, false
+	}
+	return index, provider, cp.preferredKeyIndexForScope(index, routingScopeForRequest(req)), true
+}
+
 func (cp *ClientProxy) setCurrentIndexForScope(index int, scope routingScope) {
 	cp.mu.Lock()
 	defer cp.mu.Unlock()
@@ -637,7 +732,10 @@ func (cp *ClientProxy) scopeIndexLocked(scope routingScope) int {
 	}
 }
 
-func (cp *ClientProxy) ensureActiveScopeIndexLocked(scope routingScope, now time.Time, capability RequestCapability) int {
+// activeScopeIndexLocked returns the first provider from the scope's routing
+// cursor that can take a request of capability, and moves the cursor there
+// unless peek is set.
+func (cp *ClientProxy) activeScopeIndexLocked(scope routingScope, now time.Time, capability RequestCapability, peek bool) int {
 	idx := cp.scopeIndexLocked(scope)
 	if idx < 0 || idx >= len(cp.providers) {
 		idx = 0
@@ -648,11 +746,12 @@ func (cp *ClientProxy) ensureActiveScopeIndexLocked(scope routingScope, now time
 		if !cp.providerAvailableForCapabilityLocked(candidate, now, capability) {
 			continue
 		}
-		cp.setScopeIndexLocked(candidate, scope)
-		return candidate
+		idx = candidate
+		break
+	}
+	if !peek {
+		cp.setScopeIndexLocked(idx, scope)
 	}
-
-	cp.setScopeIndexLocked(idx, scope)
 	return idx
 }
 
@@ -678,7 +777,9 @@ func (cp *ClientProxy) nextActiveIndexLocked(from int) int {
 	return from % n
 }
 
-func (cp *ClientProxy) allowCircuit(now time.Time, providerIndex int) circuitAllowResult {
+// allowCircuit asks the provider's circuit breaker for a permit. With peek set
+// it only reports the answer, without taking a half-open probe slot.
+func (cp *ClientProxy) allowCircuit(now time.Time, providerIndex int, peek bool) circuitAllowResult {
 	if providerIndex < 0 || providerIndex >= len(cp.breakers) {
 		return circuitAllowResult{allowed: true}
 	}
@@ -686,6 +787,9 @@ func (cp *ClientProxy) allowCircuit(now time.Time, providerIndex int) circuitAll
 	if cb == nil {
 		return circuitAllowResult{allowed: true}
 	}
+	if peek {
+		return cb.peekAllow(now)
+	}
 	return cb.allow(now)
 }
 
@@ -702,7 +806,7 @@ func (cp *ClientProxy) releaseCircuitPermit(providerIndex int, usedProbe bool) {
 
 func shouldRecordCircuitFailure(reason string) bool {
 	switch reason {
-	case "network", "server", "idle_timeout":
+	case "network", "server", "idle_timeout", "first_token_timeout":
 		return true
 	default:
 		return false
@@ -740,6 +844,11 @@ func (cp *ClientProxy) recordCircuitFailure(now time.Time, providerIndex int, us
 	}
 	if shouldRecordCircuitFailure(reason) {
 		cb.recordFailure(now, usedProbe)
+		if cb.snapshot(now).state == circuitOpen {
+			cp.mu.Lock()
+			cp.clearProviderControlLocked(providerIndex)
+			cp.mu.Unlock()
+		}
 	} else {
 		cb.releaseProbeNeutral(usedProbe)
 	}
@@ -751,6 +860,8 @@ func (cp *ClientProxy) recordCircuitFailureFromClassification(now time.Time, pro
 }
 
 func (cp *ClientProxy) recordProviderSwitch(from string, to string, reason string, status int) {
+	cp.metrics.observeSwitch(cp.clientType, reason)
+	cp.publishEvent(Event{Type: EventProviderSwitched, From: from, To: to, Reason: reason, Status: status})
 	cp.mu.Lock()
 	defer cp.mu.Unlock()
 	cp.lastSwitch = ProviderSwitchEvent{
@@ -764,9 +875,7 @@ func (cp *ClientProxy) recordProviderSwitch(from string, to string, reason strin
 
 func (cp *ClientProxy) recordLastRequest(now time.Time, req *http.Request, provider string, status int, result streamResult) {
 	requestCtx, _ := requestContextFromRequest(req)
-	cp.mu.Lock()
-	defer cp.mu.Unlock()
-	cp.lastRequest = RequestOutcomeEvent{
+	event := RequestOutcomeEvent{
 		At:         now,
 		Provider:   provider,
 		Status:     status,
@@ -775,10 +884,18 @@ func (cp *ClientProxy) recordLastRequest(now time.Time, req *http.Request, provi
 		Capability: string(requestCtx.Capability),
 		Cause:      result.cause,
 		Bytes:      result.bytes,
+
+		RecoveredFrom: result.recoveredFrom,
 	}
+	requestTraceFromRequest(req).noteBytes(result.bytes)
+	cp.recordRequestEntry(now, req, provider, status, DescribeRequestOutcome(event).Result)
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.lastRequest = event
 }
 
 func (cp *ClientProxy) recordTerminalRequest(now time.Time, req *http.Request, provider string, status int, result string, detail string) {
+	cp.recordRequestEntry(now, req, provider, status, result)
 	requestCtx, _ := requestContextFromRequest(req)
 	cp.mu.Lock()
 	defer cp.mu.Unlock()
@@ -28,14 +28,21 @@ type streamResult struct {
 	cause    string
 	bytes    int
 	err      error
+	// recoveredVia and recoveredFrom are set when the stream was resumed on
+	// another provider after an interruption.
+	recoveredVia  string
+	recoveredFrom string
 }
 
 // streamResponseToClient handles the final stage of an upstream attempt: waiting for the first byte,
 // committing headers, and streaming the body. It handles idle timeouts, circuit breaker recording,
 // and cleanup. It returns a terminal stream result so callers can distinguish a clean completion
 // from client disconnects and upstream aborts after the response has already been committed.
-func (cp *ClientProxy) streamResponseToClient(w http.ResponseWriter, resp *http.Response, originalReq *http.Request, attemptCtx context.Context, cancelAttempt context.CancelCauseFunc, index int, allow circuitAllowResult, onCommit func(), onSuccess func(streamSuccess)) streamResult {
+// When onInterrupted is set and stream recovery applies, a committed stream that breaks off is
+// handed to it so the rest of the response can be continued on another provider.
+func (cp *ClientProxy) streamResponseToClient(w http.ResponseWriter, resp *http.Response, originalReq *http.Request, attemptCtx context.Context, cancelAttempt context.CancelCauseFunc, index int, allow circuitAllowResult, onCommit func(), onSuccess func(streamSuccess), onInterrupted func(*streamRecovery) (streamResult, bool)) streamResult {
 	// Stream response to the client, with idle-timeout protection.
+	startedAt := time.Now()
 	var idleTimer *time.Timer
 	if cp.upstreamIdle > 0 {
 		idleTimer = time.AfterFunc(cp.upstreamIdle, func() { cancelAttempt(errUpstreamIdleTimeout) })
@@ -80,11 +87,23 @@ func (cp *ClientProxy) streamResponseToClient(w http.ResponseWriter, resp *http.
 		_, _ = capture.Write(buf[:min(firstN, protocolScanWindow-capture.Len())])
 	}
 
-	if firstN == 0 && firstErr != nil {
+	// With a first-token deadline, hold back keepalives and metadata events until the
+	// first content event so a stalled stream can still fail over uncommitted.
+	prelude := buf[:firstN]
+	heldForFirstToken := false
+	if firstN > 0 && firstErr == nil && usageExtractor != nil && !usageExtractor.SawContent() && upstreamResp.StatusCode/100 == 2 && isEventStreamContentType(derivedContentType) {
+		if deadline := cp.firstTokenTimeoutFor(originalReq); deadline > 0 {
+			heldForFirstToken = true
+			prelude, total, firstErr = cp.awaitFirstToken(upstreamResp, attemptCtx, cancelAttempt, deadline-time.Since(startedAt), idleTimer, tracker, usageExtractor, append([]byte(nil), prelude...), total)
+		}
+	}
+
+	if (firstN == 0 && firstErr != nil) || (heldForFirstToken && firstErr != nil && !errors.Is(firstErr, io.EOF)) {
 		_ = upstreamResp.Body.Close()
 		stopTimer(idleTimer)
 		if errors.Is(firstErr, io.EOF) {
 			// Legitimately empty body; pass through as-is.
+			requestTraceFromRequest(originalReq).noteFirstByte(time.Now())
 			if onCommit != nil {
 				onCommit()
 			}
@@ -134,6 +153,7 @@ func (cp *ClientProxy) streamResponseToClient(w http.ResponseWriter, resp *http.
 	}
 
 	// Committed to this provider.
+	requestTraceFromRequest(originalReq).noteFirstByte(time.Now())
 	if onCommit != nil {
 		onCommit()
 	}
@@ -141,8 +161,18 @@ func (cp *ClientProxy) streamResponseToClient(w http.ResponseWriter, resp *http.
 	w.WriteHeader(upstreamResp.StatusCode)
 
 	fw := responseBodyWriter(w, originalReq, upstreamResp)
-	if firstN > 0 {
-		if _, err := fw.Write(buf[:firstN]); err != nil {
+	if requestTraceFromRequest(originalReq).streamUsageInjected() && isEventStreamContentType(derivedContentType) {
+		usageFilter := newStreamUsageFilter(fw)
+		defer usageFilter.flush()
+		fw = usageFilter
+	}
+	var recovery *streamRecovery
+	if onInterrupted != nil {
+		recovery = cp.newStreamRecovery(originalReq, upstreamResp, derivedContentType, fw, tracker, cp.providers[index].Name)
+	}
+	recovery.observe(prelude)
+	if len(prelude) > 0 {
+		if _, err := fw.Write(prelude); err != nil {
 			_ = upstreamResp.Body.Close()
 			stopTimer(idleTimer)
 			cp.releaseCircuitPermit(index, allow.usedProbe)
@@ -192,6 +222,7 @@ func (cp *ClientProxy) streamResponseToClient(w http.ResponseWriter, resp *http.
 					err:      ew,
 				}
 			}
+			recovery.observe(buf[:nr])
 		}
 		if er != nil {
 			if errors.Is(er, io.EOF) {
@@ -240,6 +271,13 @@ func (cp *ClientProxy) streamResponseToClient(w http.ResponseWriter, resp *http.
 		cp.recordCircuitFailure(time.Now(), index, allow.usedProbe, "network")
 	}
 	cancelAttempt(nil)
+	if recovery != nil && (copyErr != nil || protocol == protocolIncomplete) {
+		recovery.usage, _ = usageExtractor.Partial()
+		if result, ok := onInterrupted(recovery); ok {
+			result.bytes += total
+			return result
+		}
+	}
 	if copyErr == nil {
 		return streamResult{
 			kind:     streamFinal,
@@ -279,6 +317,8 @@ func buildStreamSuccess(responseBody []byte, extractor *telemetry.UsageExtractor
 	}
 	if usage, ok := extractor.Finalize(); ok {
 		out.usage = usage
+	} else {
+		out.outputEstimate, out.estimable = extractor.EstimatedOutputTokens()
 	}
 	return out
 }
@@ -339,6 +379,9 @@ func streamCause(protocol protocolStatus, err error, attemptCtx context.Context,
 	if protocol == protocolIncomplete {
 		return "protocol_incomplete"
 	}
+	if isFirstTokenTimeout(attemptCtx, err) {
+		return "first_token_timeout"
+	}
 	if isUpstreamIdleTimeout(attemptCtx, err) {
 		return "idle_timeout"
 	}
@@ -0,0 +1,320 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/telemetry"
+)
+
+// latencyBucketsSeconds are the upper bounds shared by the TTFB and total
+// duration histograms. Long generations can take minutes.
+var latencyBucketsSeconds = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// metricsRegistry holds the counters and histograms exported on /metrics.
+// It lives on the Router so values survive config reloads; gauges are read
+// from the runtime snapshot at scrape time instead.
+type metricsRegistry struct {
+	mu       sync.Mutex
+	requests *metricVec
+	attempts *metricVec
+	switches *metricVec
+	tokens   *metricVec
+	cost     *metricVec
+	ttfb     *histogramVec
+	duration *histogramVec
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		requests: newMetricVec("clipal_requests_total", "counter", "Completed client requests.", "client", "provider", "capability", "status", "result"),
+		attempts: newMetricVec("clipal_upstream_attempts_total", "counter", "Upstream attempts sent to providers.", "client", "provider"),
+		switches: newMetricVec("clipal_provider_switches_total", "counter", "Failovers from one provider to another.", "client", "reason"),
+		tokens:   newMetricVec("clipal_tokens_total", "counter", "Tokens reported by upstream usage.", "client", "provider", "type"),
+		cost:     newMetricVec("clipal_cost_usd_total", "counter", "Spend in USD for requests with known pricing.", "client", "provider"),
+		ttfb:     newHistogramVec("clipal_request_ttfb_seconds", "Time from receiving a request to the first upstream response byte.", latencyBucketsSeconds, "client", "provider"),
+		duration: newHistogramVec("clipal_request_duration_seconds", "Total time to complete a client request.", latencyBucketsSeconds, "client", "provider"),
+	}
+}
+
+func (m *metricsRegistry) observeRequest(entry telemetry.LedgerEntry) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := entry.Result
+	if result == "" {
+		result = "completed"
+	}
+	m.requests.add(1, entry.ClientType, entry.Provider, entry.Capability, strconv.Itoa(entry.Status), result)
+	if entry.TTFBMillis > 0 {
+		m.ttfb.observe(float64(entry.TTFBMillis)/1000, entry.ClientType, entry.Provider)
+	}
+	m.duration.observe(float64(entry.DurationMillis)/1000, entry.ClientType, entry.Provider)
+	for _, token := range []struct {
+		kind  string
+		count int64
+	}{
+		{"input", entry.InputTokens},
+		{"output", entry.OutputTokens},
+		{"reasoning", entry.ReasoningTokens},
+		{"thoughts", entry.ThoughtsTokens},
+	} {
+		if token.count > 0 {
+			m.tokens.add(float64(token.count), entry.ClientType, entry.Provider, token.kind)
+		}
+	}
+	if entry.HasCost && entry.CostMicros > 0 {
+		m.cost.add(float64(entry.CostMicros)/1_000_000, entry.ClientType, entry.Provider)
+	}
+}
+
+func (m *metricsRegistry) observeAttempt(clientType ClientType, provider string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.attempts.add(1, string(clientType), provider)
+}
+
+func (m *metricsRegistry) observeSwitch(clientType ClientType, reason string) {
+	if m == nil {
+		return
+	}
+	if reason == "" {
+		reason = "unknown"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.switches.add(1, string(clientType), reason)
+}
+
+func (m *metricsRegistry) writeTo(w io.Writer) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests.writeTo(w)
+	m.attempts.writeTo(w)
+	m.switches.writeTo(w)
+	m.tokens.writeTo(w)
+	m.cost.writeTo(w)
+	m.ttfb.writeTo(w)
+	m.duration.writeTo(w)
+}
+
+// WriteMetrics writes all metrics in the Prometheus text exposition format.
+func (r *Router) WriteMetrics(w io.Writer) {
+	r.metrics.writeTo(w)
+
+	now := time.Now()
+	snapshot := r.RuntimeSnapshot()
+	circuit := newMetricVec("clipal_provider_circuit_state", "gauge", "Circuit breaker state; 1 for the current state.", "client", "provider", "state")
+	deactivated := newMetricVec("clipal_provider_deactivated", "gauge", "Whether the provider is temporarily deactivated.", "client", "provider", "reason")
+	busy := newMetricVec("clipal_provider_busy", "gauge", "Whether the provider is backing off after rate limiting.", "client", "provider")
+	keys := newMetricVec("clipal_provider_keys", "gauge", "Configured API keys.", "client", "provider")
+	availableKeys := newMetricVec("clipal_provider_available_keys", "gauge", "API keys not currently deactivated.", "client", "provider")
+	sticky := newMetricVec("clipal_sticky_bindings", "gauge", "Active sticky session bindings.", "client")
+	for clientType, client := range snapshot.Clients {
+		ct := string(clientType)
+		sticky.add(float64(client.StickyBindingCount), ct)
+		for _, p := range client.Providers {
+			for _, state := range []circuitState{circuitClosed, circuitOpen, circuitHalfOpen} {
+				circuit.add(boolMetric(p.CircuitState == string(state)), ct, p.Name, string(state))
+			}
+			deactivated.add(boolMetric(p.DeactivatedReason != ""), ct, p.Name, p.DeactivatedReason)
+			busy.add(boolMetric(p.BusyUntil.After(now)), ct, p.Name)
+			keys.add(float64(p.KeyCount), ct, p.Name)
+			availableKeys.add(float64(p.AvailableKeyCount), ct, p.Name)
+		}
+	}
+	circuit.writeTo(w)
+	deactivated.writeTo(w)
+	busy.writeTo(w)
+	keys.writeTo(w)
+	availableKeys.writeTo(w)
+	sticky.writeTo(w)
+	r.oauthExpiryMetrics().writeTo(w)
+}
+
+func (r *Router) oauthExpiryMetrics() *metricVec {
+	expiry := newMetricVec("clipal_oauth_token_expiry_timestamp_seconds", "gauge", "Unix time when the provider's OAuth access token expires.", "client", "provider", "oauth_provider")
+	if r.oauth == nil {
+		return expiry
+	}
+	r.mu.RLock()
+	proxies := make(map[ClientType]*ClientProxy, len(r.proxies))
+	for clientType, cp := range r.proxies {
+		proxies[clientType] = cp
+	}
+	r.mu.RUnlock()
+	providers := make(map[ClientType][]config.Provider, len(proxies))
+	for clientType, cp := range proxies {
+		if cp == nil {
+			continue
+		}
+		cp.mu.RLock()
+		providers[clientType] = append([]config.Provider(nil), cp.providers...)
+		cp.mu.RUnlock()
+	}
+	for clientType, list := range providers {
+		for _, provider := range list {
+			if !provider.UsesOAuth() {
+				continue
+			}
+			cred, err := r.oauth.Load(provider.NormalizedOAuthProvider(), provider.NormalizedOAuthRef())
+			if err != nil || cred == nil || cred.ExpiresAt.IsZero() {
+				continue
+			}
+			expiry.add(float64(cred.ExpiresAt.Unix()), string(clientType), provider.Name, string(provider.NormalizedOAuthProvider()))
+		}
+	}
+	return expiry
+}
+
+func boolMetric(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// metricVec is a counter or gauge family keyed by label values.
+type metricVec struct {
+	name   string
+	typ    string
+	help   string
+	labels []string
+	values map[string]float64
+}
+
+func newMetricVec(name, typ, help string, labels ...string) *metricVec {
+	return &metricVec{name: name, typ: typ, help: help, labels: labels, values: map[string]float64{}}
+}
+
+func (v *metricVec) add(delta float64, labelValues ...string) {
+	v.values[strings.Join(labelValues, "\xff")] += delta
+}
+
+func (v *metricVec) writeTo(w io.Writer) {
+	writeMetricHeader(w, v.name, v.typ, v.help)
+	for _, key := range sortedKeys(v.values) {
+		_, _ = fmt.Fprintf(w, "%s%s %s\n", v.name, formatLabels(v.labels, strings.Split(key, "\xff"), "", ""), formatMetricValue(v.values[key]))
+	}
+}
+
+type histogramSeries struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	bounds  []float64
+	samples map[string]*histogramSeries
+}
+
+func newHistogramVec(name, help string, bounds []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, bounds: bounds, samples: map[string]*histogramSeries{}}
+}
+
+func (h *histogramVec) observe(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	series := h.samples[key]
+	if series == nil {
+		series = &histogramSeries{buckets: make([]uint64, len(h.bounds))}
+		h.samples[key] = series
+	}
+	for i, bound := range h.bounds {
+		if value <= bound {
+			series.buckets[i]++
+		}
+	}
+	series.count++
+	series.sum += value
+}
+
+func (h *histogramVec) writeTo(w io.Writer) {
+	writeMetricHeader(w, h.name, "histogram", h.help)
+	for _, key := range sortedKeys(h.samples) {
+		series := h.samples[key]
+		values := strings.Split(key, "\xff")
+		for i, bound := range h.bounds {
+			_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, values, "le", formatMetricValue(bound)), series.buckets[i])
+		}
+		_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, values, "le", "+Inf"), series.count)
+		_, _ = fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, values, "", ""), formatMetricValue(series.sum))
+		_, _ = fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, values, "", ""), series.count)
+	}
+}
+
+func writeMetricHeader(w io.Writer, name, typ, help string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func formatLabels(names []string, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(value))
+		b.WriteByte('"')
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extraName)
+		b.WriteString(`="`)
+		b.WriteString(extraValue)
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
+func formatMetricValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
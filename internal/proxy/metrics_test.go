@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/telemetry"
+)
+
+func TestRouterWriteMetrics_ExportsRequestCountersAndRuntimeGauges(t *testing.T) {
+	t.Parallel()
+
+	router := newUnifiedIngressTestRouter()
+	router.proxies[ClientOpenAI].httpClient.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		h := make(http.Header)
+		h.Set("Content-Type", "application/json")
+		return newResponse(http.StatusOK, h, `{"id":"resp_1","usage":{"input_tokens":7,"output_tokens":3,"total_tokens":10}}`), nil
+	})
+	router.proxies[ClientClaude].deactivated[0] = providerDeactivation{until: time.Now().Add(time.Minute), reason: "billing"}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/clipal/v1/responses", bytes.NewReader([]byte(`{"model":"gpt-5","input":"hi"}`)))
+	router.handleRequest(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	router.proxies[ClientOpenAI].recordProviderSwitch("codex", "backup", "rate_limit", http.StatusTooManyRequests)
+
+	var out bytes.Buffer
+	router.WriteMetrics(&out)
+	got := out.String()
+	for _, want := range []string{
+		"# TYPE clipal_requests_total counter\n",
+		`clipal_requests_total{client="openai",provider="codex",capability="openai_responses",status="200",result="completed"} 1`,
+		`clipal_upstream_attempts_total{client="openai",provider="codex"} 1`,
+		`clipal_provider_switches_total{client="openai",reason="rate_limit"} 1`,
+		`clipal_tokens_total{client="openai",provider="codex",type="input"} 7`,
+		`clipal_request_duration_seconds_count{client="openai",provider="codex"} 1`,
+		`clipal_request_duration_seconds_bucket{client="openai",provider="codex",le="+Inf"} 1`,
+		`clipal_provider_circuit_state{client="openai",provider="codex",state="closed"} 1`,
+		`clipal_provider_circuit_state{client="openai",provider="codex",state="open"} 0`,
+		`clipal_provider_deactivated{client="claude",provider="claude",reason="billing"} 1`,
+		`clipal_provider_keys{client="gemini",provider="gemini"} 1`,
+		`clipal_sticky_bindings{client="openai"} 0`,
+		"# TYPE clipal_oauth_token_expiry_timestamp_seconds gauge\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("metrics missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestMetricsRegistry_HistogramBucketsAndLabelEscaping(t *testing.T) {
+	t.Parallel()
+
+	m := newMetricsRegistry()
+	m.observeRequest(telemetry.LedgerEntry{ClientType: "claude", Provider: `p"1\` + "\n", Status: 200, TTFBMillis: 300, DurationMillis: 2000, CostMicros: 1_500_000, HasCost: true})
+	m.observeRequest(telemetry.LedgerEntry{ClientType: "claude", Provider: "p2", Status: 502, Result: "incomplete_response", DurationMillis: 50})
+
+	var out bytes.Buffer
+	m.writeTo(&out)
+	got := out.String()
+	for _, want := range []string{
+		`clipal_requests_total{client="claude",provider="p\"1\\\n",capability="",status="200",result="completed"} 1`,
+		`clipal_request_ttfb_seconds_bucket{client="claude",provider="p\"1\\\n",le="0.25"} 0`,
+		`clipal_request_ttfb_seconds_bucket{client="claude",provider="p\"1\\\n",le="0.5"} 1`,
+		`clipal_request_ttfb_seconds_sum{client="claude",provider="p\"1\\\n"} 0.3`,
+		`clipal_request_duration_seconds_bucket{client="claude",provider="p2",le="0.1"} 1`,
+		`clipal_cost_usd_total{client="claude",provider="p\"1\\\n"} 1.5`,
+		`result="incomplete_response"} 1`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("metrics missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, `clipal_request_ttfb_seconds_count{client="claude",provider="p2"}`) {
+		t.Fatalf("requests without a first byte should not be observed in the TTFB histogram:\n%s", got)
+	}
+}
@@ -0,0 +1,301 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/logger"
+)
+
+// localModelListProvider names model lists Clipal answered itself in request
+// records, and owns static models and aliases in OpenAI lists.
+const localModelListProvider = "clipal"
+
+const (
+	// modelListCacheTTL is how long a merged model list is served before the
+	// providers are asked again.
+	modelListCacheTTL = 10 * time.Minute
+	// modelListFailureTTL is how long a fetch that found no models is
+	// remembered, so requests are forwarded without asking every provider again.
+	modelListFailureTTL = time.Minute
+	// modelListFetchTimeout bounds how long a merged list waits for slow providers.
+	modelListFetchTimeout = 15 * time.Second
+)
+
+// listedModel is one entry of a model list Clipal answers, with the provider
+// that reported it.
+type listedModel struct {
+	DiscoveredModel
+	ownedBy string
+}
+
+// modelListCache holds the merged model list of one client proxy. Concurrent
+// requests share one fetch: they wait on fetching, not on mu, while the
+// providers are asked.
+type modelListCache struct {
+	mu        sync.Mutex
+	fetchedAt time.Time
+	models    []listedModel
+	// fetching is closed when the fetch in flight finishes; nil when none is.
+	fetching chan struct{}
+}
+
+// freshLocked reports whether the last fetch can still be served: a list for
+// modelListCacheTTL, an empty result for modelListFailureTTL.
+func (c *modelListCache) freshLocked(now time.Time) bool {
+	if c.fetchedAt.IsZero() {
+		return false
+	}
+	ttl := modelListCacheTTL
+	if len(c.models) == 0 {
+		ttl = modelListFailureTTL
+	}
+	return now.Sub(c.fetchedAt) < ttl
+}
+
+// isModelListRequest reports whether req lists models, as opposed to reading
+// one model or any other request of the models capabilities.
+func isModelListRequest(req *http.Request, requestCtx RequestContext) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+	switch requestCtx.Capability {
+	case CapabilityOpenAIModels, CapabilityClaudeModels:
+		return matchesExactPath(requestCtx.UpstreamPath, "/v1/models")
+	case CapabilityGeminiModels:
+		return matchesExactPath(requestCtx.UpstreamPath, "/v1beta/models")
+	default:
+		return false
+	}
+}
+
+func (cp *ClientProxy) modelListSettings() config.ModelListConfig {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+	return cp.routing.modelList.For(string(cp.clientType))
+}
+
+// answerModelList answers a model list request from routing.model_list. It
+// returns false without writing anything when the request should be forwarded
+// to the current provider instead: for the current source, or when no provider
+// returned a merged list recently.
+func (cp *ClientProxy) answerModelList(w http.ResponseWriter, req *http.Request) bool {
+	settings := cp.modelListSettings()
+	var (
+		models    []listedModel
+		fetchedAt time.Time
+	)
+	switch settings.NormalizedSource() {
+	case config.ModelListSourceCurrent:
+		return false
+	case config.ModelListSourceStatic:
+		models = appendListedModelIDs(nil, settings.Models)
+		fetchedAt = time.Now()
+	default:
+		models, fetchedAt = cp.mergedModelList(req.Context())
+		if len(models) == 0 {
+			return false
+		}
+	}
+	models = appendListedModelIDs(models, settings.Aliases)
+
+	requestCtx, _ := requestContextFromRequest(req)
+	body, err := json.Marshal(modelListResponse(requestCtx.Family, models, fetchedAt))
+	if err != nil {
+		return false
+	}
+
+	logger.Debug("[%s] answering model list (%s): %d models", cp.clientType, settings.NormalizedSource(), len(models))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	n, _ := w.Write(body)
+	cp.logRequestResult(req, localModelListProvider, http.StatusOK, streamResult{
+		kind:     streamFinal,
+		delivery: deliveryCommittedComplete,
+		protocol: protocolNotApplicable,
+		proto:    streamProtocolNone,
+		bytes:    n,
+	}, false)
+	return true
+}
+
+// mergedModelList returns the union of every provider's model list, asking
+// the providers when the cached list is missing or stale. Providers that fail
+// or cannot list models are left out. An empty result is cached for
+// modelListFailureTTL and returned as nil.
+func (cp *ClientProxy) mergedModelList(ctx context.Context) ([]listedModel, time.Time) {
+	c := &cp.modelList
+	c.mu.Lock()
+	if !c.freshLocked(time.Now()) && c.fetching == nil {
+		c.fetching = make(chan struct{})
+		// Other requests may wait on this fetch, so it outlives the client
+		// that started it.
+		go cp.fetchMergedModelList(c.fetching)
+	}
+	fetching := c.fetching
+	c.mu.Unlock()
+
+	if fetching != nil {
+		select {
+		case <-fetching:
+		case <-ctx.Done():
+			return nil, time.Time{}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.models) == 0 {
+		return nil, time.Time{}
+	}
+	return c.models, c.fetchedAt
+}
+
+// fetchMergedModelList asks every provider for its model list, stores the
+// merged result and closes done.
+func (cp *ClientProxy) fetchMergedModelList(done chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), modelListFetchTimeout)
+	defer cancel()
+	lists := make([][]DiscoveredModel, len(cp.providers))
+	var wg sync.WaitGroup
+	for i := range cp.providers {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			models, err := cp.discoverModels(ctx, index)
+			if err != nil {
+				if !errors.Is(err, ErrModelDiscoveryUnsupported) {
+					logger.Debug("[%s] model list from %s failed: %v", cp.clientType, cp.providers[index].Name, sanitizeLogString(err.Error()))
+				}
+				return
+			}
+			lists[index] = models
+		}(i)
+	}
+	wg.Wait()
+
+	merged := mergeModelLists(cp.providers, lists)
+	cp.modelList.mu.Lock()
+	cp.modelList.models = merged
+	cp.modelList.fetchedAt = time.Now()
+	cp.modelList.fetching = nil
+	cp.modelList.mu.Unlock()
+	close(done)
+}
+
+// mergeModelLists deduplicates the providers' lists by model ID. The first
+// provider, in priority order, owns a model; later providers only fill in
+// metadata it did not report.
+func mergeModelLists(providers []config.Provider, lists [][]DiscoveredModel) []listedModel {
+	var out []listedModel
+	byID := make(map[string]int)
+	for i, models := range lists {
+		for _, model := range models {
+			idx, ok := byID[model.ID]
+			if !ok {
+				byID[model.ID] = len(out)
+				out = append(out, listedModel{DiscoveredModel: model, ownedBy: providers[i].Name})
+				continue
+			}
+			existing := &out[idx]
+			if existing.DisplayName == "" {
+				existing.DisplayName = model.DisplayName
+			}
+			if existing.ContextWindow == 0 {
+				existing.ContextWindow = model.ContextWindow
+			}
+			if existing.MaxOutputTokens == 0 {
+				existing.MaxOutputTokens = model.MaxOutputTokens
+			}
+			if len(existing.Capabilities) == 0 {
+				existing.Capabilities = model.Capabilities
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// appendListedModelIDs appends the configured model IDs that models does not
+// list yet. The result never aliases the cached list.
+func appendListedModelIDs(models []listedModel, ids []string) []listedModel {
+	out := append([]listedModel(nil), models...)
+	seen := make(map[string]struct{}, len(out)+len(ids))
+	for _, model := range out {
+		seen[model.ID] = struct{}{}
+	}
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if _, ok := seen[id]; ok || id == "" {
+			continue
+		}
+		seen[id] = struct{}{}
+		out = append(out, listedModel{DiscoveredModel: DiscoveredModel{ID: id}, ownedBy: localModelListProvider})
+	}
+	return out
+}
+
+// modelListResponse shapes models like the upstream list of family: OpenAI
+// "data" objects, an Anthropic page with has_more=false, or Gemini "models"
+// with "models/" names.
+func modelListResponse(family ProtocolFamily, models []listedModel, fetchedAt time.Time) any {
+	switch family {
+	case ProtocolFamilyClaude:
+		data := make([]map[string]any, 0, len(models))
+		for _, model := range models {
+			displayName := model.DisplayName
+			if displayName == "" {
+				displayName = model.ID
+			}
+			data = append(data, map[string]any{
+				"type":         "model",
+				"id":           model.ID,
+				"display_name": displayName,
+				"created_at":   fetchedAt.UTC().Format(time.RFC3339),
+			})
+		}
+		resp := map[string]any{"data": data, "has_more": false, "first_id": nil, "last_id": nil}
+		if len(models) > 0 {
+			resp["first_id"] = models[0].ID
+			resp["last_id"] = models[len(models)-1].ID
+		}
+		return resp
+	case ProtocolFamilyGemini:
+		out := make([]map[string]any, 0, len(models))
+		for _, model := range models {
+			entry := map[string]any{"name": "models/" + model.ID}
+			if model.DisplayName != "" {
+				entry["displayName"] = model.DisplayName
+			}
+			if model.ContextWindow > 0 {
+				entry["inputTokenLimit"] = model.ContextWindow
+			}
+			if model.MaxOutputTokens > 0 {
+				entry["outputTokenLimit"] = model.MaxOutputTokens
+			}
+			if len(model.Capabilities) > 0 {
+				entry["supportedGenerationMethods"] = model.Capabilities
+			}
+			out = append(out, entry)
+		}
+		return map[string]any{"models": out}
+	default:
+		data := make([]map[string]any, 0, len(models))
+		for _, model := range models {
+			data = append(data, map[string]any{
+				"id":       model.ID,
+				"object":   "model",
+				"created":  fetchedAt.Unix(),
+				"owned_by": model.ownedBy,
+			})
+		}
+		return map[string]any{"object": "list", "data": data}
+	}
+}
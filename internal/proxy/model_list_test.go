@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lansespirit/Clipal/internal/config"
+)
+
+func serveModelList(t *testing.T, router *Router, path string, header http.Header) map[string]any {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://proxy"+path, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	router.handleRequest(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("%s status = %d body=%s", path, rr.Code, rr.Body.String())
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s body %q: %v", path, rr.Body.String(), err)
+	}
+	return body
+}
+
+func modelListIDs(items any, key string) []string {
+	var ids []string
+	list, _ := items.([]any)
+	for _, item := range list {
+		m, _ := item.(map[string]any)
+		ids = append(ids, stringValue(m[key]))
+	}
+	return ids
+}
+
+func TestModelList_MergesProvidersWithAliasesAndCaches(t *testing.T) {
+	var calls int32
+	newUpstream := func(body string, status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			if r.URL.Path != "/v1/models" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		}))
+	}
+	p1 := newUpstream(`{"data":[{"id":"gpt-5"},{"id":"gpt-4.1"}]}`, http.StatusOK)
+	defer p1.Close()
+	p2 := newUpstream(`{"data":[{"id":"gpt-5"},{"id":"deepseek-chat"}]}`, http.StatusOK)
+	defer p2.Close()
+	broken := newUpstream(`{"error":"down"}`, http.StatusInternalServerError)
+	defer broken.Close()
+
+	cfg := &config.Config{Global: config.DefaultGlobalConfig()}
+	cfg.Global.Routing.ModelList = config.ModelLists{"openai": {Source: config.ModelListSourceMerged, Aliases: []string{"fast", "gpt-5"}}}
+	cfg.OpenAI = config.ClientConfig{Mode: config.ClientModeAuto, Providers: []config.Provider{
+		{Name: "p1", BaseURL: p1.URL, APIKey: "k1", Priority: 1, ProxyMode: config.ProviderProxyModeDirect},
+		{Name: "broken", BaseURL: broken.URL, APIKey: "k2", Priority: 2, ProxyMode: config.ProviderProxyModeDirect},
+		{Name: "p2", BaseURL: p2.URL, APIKey: "k3", Priority: 3, ProxyMode: config.ProviderProxyModeDirect},
+	}}
+	router := NewRouter(cfg)
+
+	body := serveModelList(t, router, "/openai/v1/models", nil)
+	if got, want := modelListIDs(body["data"], "id"), []string{"deepseek-chat", "gpt-4.1", "gpt-5", "fast"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ids = %v, want %v", got, want)
+	}
+	if got, want := modelListIDs(body["data"], "owned_by"), []string{"p2", "p1", "p1", "clipal"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("owned_by = %v, want %v", got, want)
+	}
+	fetched := atomic.LoadInt32(&calls)
+	if fetched != 3 {
+		t.Fatalf("upstream calls = %d, want 3", fetched)
+	}
+
+	// The unified ingress serves the same cached list.
+	body = serveModelList(t, router, "/clipal/v1/models", nil)
+	if got := modelListIDs(body["data"], "id"); len(got) != 4 {
+		t.Fatalf("cached ids = %v", got)
+	}
+	if got := atomic.LoadInt32(&calls); got != fetched {
+		t.Fatalf("upstream calls after cache hit = %d, want %d", got, fetched)
+	}
+
+	// List fetches are Clipal's own traffic, not client attempts.
+	var metrics bytes.Buffer
+	router.WriteMetrics(&metrics)
+	if strings.Contains(metrics.String(), "clipal_upstream_attempts_total{") {
+		t.Fatalf("model list fetches were counted as upstream attempts:\n%s", metrics.String())
+	}
+}
+
+func TestModelList_ClaudeAndGeminiShapesStaticAndFallback(t *testing.T) {
+	claude := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":[{"id":"claude-sonnet-4-5","display_name":"Claude Sonnet 4.5"}],"has_more":false}`))
+	}))
+	defer claude.Close()
+	var openaiCalls int32
+	openai := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&openaiCalls, 1)
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`not a list`))
+	}))
+	defer openai.Close()
+
+	cfg := &config.Config{Global: config.DefaultGlobalConfig()}
+	cfg.Global.Routing.ModelList = config.ModelLists{
+		"claude": {Source: config.ModelListSourceMerged},
+		"openai": {Source: config.ModelListSourceMerged},
+		"gemini": {Source: config.ModelListSourceStatic, Models: []string{"gemini-2.5-pro", "gemini-2.5-flash"}},
+	}
+	cfg.Claude = config.ClientConfig{Mode: config.ClientModeAuto, Providers: []config.Provider{
+		{Name: "c1", BaseURL: claude.URL, APIKey: "k", Priority: 1, ProxyMode: config.ProviderProxyModeDirect},
+	}}
+	cfg.OpenAI = config.ClientConfig{Mode: config.ClientModeAuto, Providers: []config.Provider{
+		{Name: "o1", BaseURL: openai.URL, APIKey: "k", Priority: 1, ProxyMode: config.ProviderProxyModeDirect},
+	}}
+	cfg.Gemini = config.ClientConfig{Mode: config.ClientModeAuto, Providers: []config.Provider{
+		{Name: "g1", BaseURL: "http://127.0.0.1:1", APIKey: "k", Priority: 1, ProxyMode: config.ProviderProxyModeDirect},
+	}}
+	router := NewRouter(cfg)
+
+	body := serveModelList(t, router, "/clipal/v1/models", http.Header{"Anthropic-Version": {"2023-06-01"}})
+	if got := modelListIDs(body["data"], "display_name"); !reflect.DeepEqual(got, []string{"Claude Sonnet 4.5"}) {
+		t.Fatalf("claude display names = %v", got)
+	}
+	if body["has_more"] != false || body["last_id"] != "claude-sonnet-4-5" {
+		t.Fatalf("claude page = %#v", body)
+	}
+
+	body = serveModelList(t, router, "/gemini/v1beta/models", nil)
+	if got := modelListIDs(body["models"], "name"); !reflect.DeepEqual(got, []string{"models/gemini-2.5-pro", "models/gemini-2.5-flash"}) {
+		t.Fatalf("gemini names = %v", got)
+	}
+
+	// No provider returned a list, so the request goes to the current provider.
+	rr := httptest.NewRecorder()
+	router.handleRequest(rr, httptest.NewRequest(http.MethodGet, "http://proxy/openai/v1/models", nil))
+	if rr.Code != http.StatusNotFound || rr.Body.String() != "not a list" {
+		t.Fatalf("fallback status=%d body=%q", rr.Code, rr.Body.String())
+	}
+	if got := atomic.LoadInt32(&openaiCalls); got != 2 {
+		t.Fatalf("openai calls = %d, want a list attempt and a forward", got)
+	}
+
+	// The failed attempt is cached, so the next request is only forwarded.
+	router.handleRequest(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://proxy/openai/v1/models", nil))
+	if got := atomic.LoadInt32(&openaiCalls); got != 3 {
+		t.Fatalf("openai calls = %d, want the failed list attempt to be cached", got)
+	}
+}
@@ -68,7 +68,7 @@ func (cp *ClientProxy) createClaudeOAuthRequestWithPayloadForProvider(original *
 
 func copyHeaderAllowingApplicationAuth(dst http.Header, src http.Header) {
 	for key, values := range src {
-		if isHopByHopHeader(key) {
+		if isHopByHopHeader(key) || isClipalRequestHeader(key) {
 			continue
 		}
 		for _, value := range values {
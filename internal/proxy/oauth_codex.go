@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/logger"
 	oauthpkg "github.com/lansespirit/Clipal/internal/oauth"
 )
 
@@ -591,6 +592,42 @@ func (cp *ClientProxy) doProviderRequestWithPayload(original *http.Request, prov
 	if err != nil {
 		return nil, false, err
 	}
+	requestCtx, _ := requestContextFromRequest(original)
+	requestTraceFromRequest(original).noteAttempt(requestCtx, provider, apiKey, payload)
+	cp.metrics.observeAttempt(cp.clientType, provider.Name)
+	resp, sent, err := cp.sendProviderRequest(original, proxyReq, provider, providerIndex, apiKey, path, payload)
+	if err != nil {
+		return resp, sent, err
+	}
+	clientPayload, changed := payload.withoutStreamUsage()
+	if !changed || !streamUsageRejected(resp) {
+		return resp, sent, err
+	}
+	logger.Debug("[%s] %s rejected stream_options; resending the request as the client sent it", cp.clientType, provider.Name)
+	_ = resp.Body.Close()
+	proxyReq, err = cp.createProxyRequestWithPayloadForProvider(original, provider, providerIndex, apiKey, path, clientPayload)
+	if err != nil {
+		return nil, false, err
+	}
+	return cp.sendProviderRequest(original, proxyReq, provider, providerIndex, apiKey, path, clientPayload)
+}
+
+// doUnobservedProviderRequest is doProviderRequestWithPayload for requests
+// Clipal makes on its own, such as model list fetches and provider probes.
+// No client sent them, so they stay out of the attempt metrics and request
+// traces.
+func (cp *ClientProxy) doUnobservedProviderRequest(original *http.Request, provider config.Provider, providerIndex int, apiKey string, path string, payload *requestPayload) (*http.Response, bool, error) {
+	proxyReq, err := cp.createProxyRequestWithPayloadForProvider(original, provider, providerIndex, apiKey, path, payload)
+	if err != nil {
+		return nil, false, err
+	}
+	return cp.sendProviderRequest(original, proxyReq, provider, providerIndex, apiKey, path, payload)
+}
+
+// sendProviderRequest sends a prepared upstream request, refreshing the OAuth
+// credential and retrying once when the provider answers 401.
+func (cp *ClientProxy) sendProviderRequest(original *http.Request, proxyReq *http.Request, provider config.Provider, providerIndex int, apiKey string, path string, payload *requestPayload) (*http.Response, bool, error) {
+	cp.propagateTraceContext(original, proxyReq, provider)
 	resp, err := cp.doPreparedProviderRequest(proxyReq, providerIndex)
 	if err != nil || !provider.UsesOAuth() || resp == nil || resp.StatusCode != http.StatusUnauthorized {
 		if err != nil || resp == nil {
@@ -612,6 +649,7 @@ func (cp *ClientProxy) doProviderRequestWithPayload(original *http.Request, prov
 	if err != nil {
 		return nil, false, err
 	}
+	cp.propagateTraceContext(original, proxyReq, provider)
 	resp, err = cp.doPreparedProviderRequest(proxyReq, providerIndex)
 	if err != nil || resp == nil {
 		return resp, true, err
@@ -620,6 +658,26 @@ func (cp *ClientProxy) doProviderRequestWithPayload(original *http.Request, prov
 	return resp, true, err
 }
 
+// propagateTraceContext points the upstream traceparent at the current attempt
+// span when tracing is enabled. OAuth requests only carry it when the provider
+// forwards the client's trace headers.
+func (cp *ClientProxy) propagateTraceContext(original *http.Request, proxyReq *http.Request, provider config.Provider) {
+	if cp == nil || cp.tracer == nil || proxyReq == nil {
+		return
+	}
+	if provider.UsesOAuth() && proxyReq.Header.Get("traceparent") == "" {
+		return
+	}
+	sc, ok := requestTraceFromRequest(original).attemptSpanContext()
+	if !ok {
+		return
+	}
+	proxyReq.Header.Set("traceparent", sc.Traceparent())
+	if sc.TraceState != "" {
+		proxyReq.Header.Set("tracestate", sc.TraceState)
+	}
+}
+
 func (cp *ClientProxy) oauthHTTPClientForProvider(provider config.Provider, providerIndex int) *http.Client {
 	if cp == nil || providerIndex < 0 {
 		return nil
@@ -92,6 +92,7 @@ func (cp *ClientProxy) synthesizeCodexOAuthNonStreamingResponseToClient(w http.R
 		}
 	}
 
+	requestTraceFromRequest(originalReq).noteFirstByte(time.Now())
 	if onCommit != nil {
 		onCommit()
 	}
@@ -1399,7 +1399,7 @@ func TestForwardManual_CodexOAuthRejectsUnsupportedCapability(t *testing.T) {
 	if got := rr.Result().StatusCode; got != http.StatusServiceUnavailable {
 		t.Fatalf("status = %d body=%s", got, rr.Body.String())
 	}
-	if got := rr.Body.String(); got != "Pinned provider only supports OpenAI Responses requests.\n" {
+	if got := rr.Body.String(); !strings.Contains(got, `"message":"Pinned provider only supports OpenAI Responses requests."`) {
 		t.Fatalf("body = %q", got)
 	}
 	if got := atomic.LoadInt32(&calls); got != 0 {
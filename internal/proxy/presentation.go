@@ -62,6 +62,14 @@ func DescribeRequestOutcome(event RequestOutcomeEvent) RequestOutcomePresentatio
 			Label:  providerOutcomeLabel("Interrupted after partial output", provider),
 			Detail: interruptedResponseDetail(provider, event.Cause),
 		}
+	case event.Delivery == string(deliveryCommittedComplete) &&
+		(event.Protocol == string(protocolCompleted) || event.Protocol == string(protocolNotApplicable)) &&
+		strings.TrimSpace(event.RecoveredFrom) != "":
+		return RequestOutcomePresentation{
+			Result: "recovered",
+			Label:  providerOutcomeLabel("Recovered mid-stream", provider),
+			Detail: recoveredResponseDetail(strings.TrimSpace(event.RecoveredFrom), provider),
+		}
 	case event.Delivery == string(deliveryCommittedComplete) &&
 		(event.Protocol == string(protocolCompleted) || event.Protocol == string(protocolNotApplicable)):
 		return RequestOutcomePresentation{
@@ -158,7 +166,7 @@ func DescribeProviderAvailability(name string, enabled bool, snap ProviderRuntim
 		return ProviderAvailabilityPresentation{
 			State:  state,
 			Label:  providerStateLabel(name, providerStateShortLabel(state)),
-			Detail: providerUnavailableDetail(snap.DeactivatedReason, d),
+			Detail: providerUnavailableDetail(snap.DeactivatedReason, snap.DeactivatedMessage, d),
 		}
 	}
 
@@ -176,10 +184,14 @@ func DescribeProviderAvailability(name string, enabled bool, snap ProviderRuntim
 
 	switch strings.TrimSpace(snap.CircuitState) {
 	case "open":
+		detail := providerCircuitDetail("open", snap.CircuitOpenIn.String())
+		if snap.CircuitManual {
+			detail = manualDetail("Circuit breaker was opened manually", snap.CircuitReason, snap.CircuitOpenIn.Truncate(time.Second).String())
+		}
 		return ProviderAvailabilityPresentation{
 			State:  "cooling_down",
 			Label:  providerStateLabel(name, "cooling down"),
-			Detail: providerCircuitDetail("open", snap.CircuitOpenIn.String()),
+			Detail: detail,
 		}
 	case "half_open":
 		return ProviderAvailabilityPresentation{
@@ -188,14 +200,54 @@ func DescribeProviderAvailability(name string, enabled bool, snap ProviderRuntim
 			Detail: "Clipal is sending limited traffic to verify the provider has recovered.",
 		}
 	default:
+		detail := "Available."
+		if note := restoredControlDetail(snap.LastControl); note != "" {
+			detail += " " + note
+		}
 		return ProviderAvailabilityPresentation{
 			State:  "available",
 			Label:  name,
-			Detail: "Available.",
+			Detail: detail,
 		}
 	}
 }
 
+// restoredControlDetail describes a manual action that made the provider
+// available again, such as "Reactivated manually: outage over."
+func restoredControlDetail(c *RuntimeControlEvent) string {
+	if c == nil {
+		return ""
+	}
+	var what string
+	switch c.Action {
+	case RuntimeActionReactivate:
+		what = "Reactivated manually"
+		if c.Key > 0 {
+			what = fmt.Sprintf("Key %d reactivated manually", c.Key)
+		}
+	case RuntimeActionResetCircuit:
+		what = "Circuit breaker reset manually"
+	case RuntimeActionClearBusy:
+		what = "Busy backoff cleared manually"
+	default:
+		return ""
+	}
+	return manualDetail(what, c.Reason, "")
+}
+
+// manualDetail joins what was done manually with the operator's reason and
+// the remaining duration.
+func manualDetail(what string, reason string, duration string) string {
+	detail := what + "."
+	if reason = strings.TrimSpace(reason); reason != "" {
+		detail = fmt.Sprintf("%s: %s.", what, strings.TrimSuffix(reason, "."))
+	}
+	if duration != "" && duration != "0s" {
+		detail = fmt.Sprintf("%s Retry in %s.", detail, duration)
+	}
+	return detail
+}
+
 func providerOutcomeLabel(prefix string, provider string) string {
 	if provider == "" {
 		return prefix
@@ -213,6 +265,13 @@ func completedResponseDetail(provider string, status int) string {
 	return fmt.Sprintf("%s returned a complete response.", provider)
 }
 
+func recoveredResponseDetail(from string, provider string) string {
+	if provider == "" {
+		return fmt.Sprintf("The stream from %s was interrupted and resumed on another provider.", from)
+	}
+	return fmt.Sprintf("The stream from %s was interrupted and %s completed it from the relayed output.", from, provider)
+}
+
 func incompleteResponseDetail(provider string, status int) string {
 	if provider == "" {
 		return "The response stream ended before completion."
@@ -242,7 +301,7 @@ func interruptedResponseDetail(provider string, cause string) string {
 
 func isHardUnavailableReason(reason string) bool {
 	switch strings.TrimSpace(reason) {
-	case "auth", "billing", "quota":
+	case "auth", "billing", "quota", manualDeactivationReason:
 		return true
 	default:
 		return false
@@ -269,10 +328,13 @@ func providerStateShortLabel(state string) string {
 	}
 }
 
-func providerUnavailableDetail(reason string, duration string) string {
+func providerUnavailableDetail(reason string, message string, duration string) string {
 	reason = strings.TrimSpace(reason)
 	var detail string
 	switch reason {
+	case manualDeactivationReason:
+		// The message of a manual deactivation is the operator's reason.
+		return manualDetail("Deactivated manually", message, duration)
 	case "auth":
 		detail = "Authentication failed."
 	case "billing":
@@ -287,10 +349,14 @@ func providerUnavailableDetail(reason string, duration string) string {
 		detail = "Upstream server error."
 	case "idle_timeout":
 		detail = "Timed out waiting for upstream response."
+	case "first_token_timeout":
+		detail = "Timed out waiting for the first streamed content."
 	case "protocol_incomplete":
 		detail = "The previous response ended before completion."
 	case "network":
 		detail = "Network request failed."
+	case "rule":
+		detail = "Matched a configured failure rule."
 	default:
 		detail = "Temporarily unavailable."
 	}
@@ -324,12 +390,16 @@ func reactivationDetail(reason string) string {
 		return "available again after the overload cooldown expired"
 	case "server":
 		return "available again after the server-error cooldown expired"
-	case "idle_timeout":
+	case "idle_timeout", "first_token_timeout":
 		return "available again after the timeout cooldown expired"
 	case "protocol_incomplete":
 		return "available again after the incomplete-response cooldown expired"
 	case "network":
 		return "available again after the network cooldown expired"
+	case "rule":
+		return "available again after the failure-rule cooldown expired"
+	case manualDeactivationReason:
+		return "available again after the manual deactivation expired"
 	default:
 		return "available again"
 	}
@@ -344,6 +414,8 @@ func switchReasonDetail(from string, reason string, status int) string {
 	switch strings.TrimSpace(reason) {
 	case "idle_timeout":
 		return fmt.Sprintf("Switched after %s timed out before any response body.", subject)
+	case "first_token_timeout":
+		return fmt.Sprintf("Switched after %s streamed no content before the first-token timeout.", subject)
 	case "network":
 		return fmt.Sprintf("Switched after %s failed before any response body.", subject)
 	case "auth":
@@ -358,6 +430,12 @@ func switchReasonDetail(from string, reason string, status int) string {
 		return fmt.Sprintf("Switched after %s returned %s and reported overload.", subject, formatHTTPStatus(status))
 	case "server":
 		return fmt.Sprintf("Switched after %s returned %s.", subject, formatHTTPStatus(status))
+	case "rule":
+		return fmt.Sprintf("Switched after %s returned %s matching a failure rule.", subject, formatHTTPStatus(status))
+	case "stream_recovery":
+		return fmt.Sprintf("Resuming the stream %s interrupted mid-response.", subject)
+	case "model_fallback":
+		return fmt.Sprintf("Retrying on a fallback model after %s returned %s.", subject, formatHTTPStatus(status))
 	default:
 		if status > 0 {
 			return fmt.Sprintf("Switched after %s returned %s.", subject, formatHTTPStatus(status))
@@ -376,6 +454,8 @@ func describeAttemptFailure(provider string, reason string, status int, beforeBo
 		switch strings.TrimSpace(reason) {
 		case "idle_timeout":
 			return fmt.Sprintf("%s timed out before any response body", provider)
+		case "first_token_timeout":
+			return fmt.Sprintf("%s streamed no content before the first-token timeout", provider)
 		default:
 			return fmt.Sprintf("%s failed before any response body", provider)
 		}
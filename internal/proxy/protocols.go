@@ -20,6 +20,7 @@ const (
 	CapabilityClaudeCompatible         RequestCapability = "claude_compatible"
 	CapabilityClaudeMessages           RequestCapability = "claude_messages"
 	CapabilityClaudeCountTokens        RequestCapability = "claude_count_tokens"
+	CapabilityClaudeModels             RequestCapability = "claude_models"
 	CapabilityOpenAICompatible         RequestCapability = "openai_compatible"
 	CapabilityOpenAIChatCompletions    RequestCapability = "openai_chat_completions"
 	CapabilityOpenAICompletions        RequestCapability = "openai_completions"
@@ -130,6 +131,11 @@ func requestContextForClientPath(clientType ClientType, path string, unified boo
 	case ClientClaude:
 		requestCtx.Family = ProtocolFamilyClaude
 		requestCtx.Capability = capabilityOrDefault(detectClaudeCapability(path), CapabilityClaudeCompatible)
+		// /v1/models is shared with OpenAI, so detectClaudeCapability leaves it
+		// to the OpenAI pool on /clipal.
+		if requestCtx.Capability == CapabilityClaudeCompatible && pathMatchesPrefix(path, "/v1/models") {
+			requestCtx.Capability = CapabilityClaudeModels
+		}
 	case ClientOpenAI:
 		requestCtx.Family = ProtocolFamilyOpenAI
 		requestCtx.Capability = capabilityOrDefault(detectOpenAICapability(path), CapabilityOpenAICompatible)
@@ -0,0 +1,306 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	oauthpkg "github.com/lansespirit/Clipal/internal/oauth"
+)
+
+// ErrModelDiscoveryUnsupported is returned for providers whose upstream has no
+// model list endpoint, such as Gemini OAuth (Code Assist).
+var ErrModelDiscoveryUnsupported = errors.New("model discovery is not supported for this provider")
+
+const (
+	// modelListBodyLimit caps one page of an upstream model list.
+	modelListBodyLimit = 8 << 20
+	// modelListMaxPages stops runaway pagination.
+	modelListMaxPages = 20
+)
+
+// DiscoveredModel is one entry of a provider's model list. ContextWindow,
+// MaxOutputTokens and Capabilities are only set when the upstream exposes them.
+type DiscoveredModel struct {
+	ID              string
+	DisplayName     string
+	ContextWindow   int
+	MaxOutputTokens int
+	Capabilities    []string
+}
+
+// DiscoverProviderModels asks the provider which models it offers: OpenAI and
+// Anthropic /v1/models, Gemini models.list, and the Claude and Codex OAuth
+// equivalents. Keys are tried in order until one succeeds. Like ProbeProvider,
+// it never touches the live runtime's provider state.
+func DiscoverProviderModels(ctx context.Context, cfg *config.Config, oauth *oauthpkg.Service, clientType ClientType, provider config.Provider) ([]DiscoveredModel, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("config is nil")
+	}
+	cp := newStandaloneClientProxy(cfg, oauth, clientType, provider)
+	defer cp.Close()
+	return cp.discoverModels(ctx, 0)
+}
+
+// DiscoverProviderModels lists a provider's models with the runtime's config
+// and OAuth service.
+func (r *Router) DiscoverProviderModels(ctx context.Context, clientType ClientType, provider config.Provider) ([]DiscoveredModel, error) {
+	r.mu.RLock()
+	cfg, oauth := r.cfg, r.oauth
+	r.mu.RUnlock()
+	return DiscoverProviderModels(ctx, cfg, oauth, clientType, provider)
+}
+
+// discoverModels lists the models of the provider at index, trying its keys
+// in order until one succeeds.
+func (cp *ClientProxy) discoverModels(ctx context.Context, index int) ([]DiscoveredModel, error) {
+	provider := cp.providers[index]
+	if provider.UsesOAuth() && provider.NormalizedOAuthProvider() == config.OAuthProviderGemini {
+		return nil, ErrModelDiscoveryUnsupported
+	}
+	var lastErr error
+	for _, apiKey := range cp.providerKeys[index] {
+		models, err := cp.listModels(ctx, index, apiKey)
+		if err == nil {
+			return models, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func (cp *ClientProxy) listModels(ctx context.Context, index int, apiKey string) ([]DiscoveredModel, error) {
+	var out []DiscoveredModel
+	seen := make(map[string]struct{})
+	cursor := ""
+	for page := 0; page < modelListMaxPages; page++ {
+		raw, err := cp.fetchModelPage(ctx, index, apiKey, cursor)
+		if err != nil {
+			return nil, err
+		}
+		models, next, err := parseModelList(raw)
+		if err != nil {
+			return nil, err
+		}
+		for _, model := range models {
+			if _, ok := seen[model.ID]; ok {
+				continue
+			}
+			seen[model.ID] = struct{}{}
+			out = append(out, model)
+		}
+		if next == "" || next == cursor {
+			break
+		}
+		cursor = next
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (cp *ClientProxy) fetchModelPage(ctx context.Context, index int, apiKey string, cursor string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, providerProbeTimeout)
+	defer cancel()
+
+	provider := cp.providers[index]
+	var (
+		resp *http.Response
+		err  error
+	)
+	if provider.UsesOAuth() {
+		resp, err = cp.doOAuthModelListRequest(ctx, index, provider, cursor)
+	} else {
+		path, query := modelListRequestPath(cp.clientType, cursor)
+		var original *http.Request
+		original, err = http.NewRequestWithContext(ctx, http.MethodGet, "http://clipal.local"+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		original.URL.RawQuery = query
+		if cp.clientType == ClientClaude {
+			original.Header.Set("anthropic-version", "2023-06-01")
+		}
+		original = withRequestContext(original, requestContextForClientPath(cp.clientType, path, false))
+		resp, _, err = cp.doUnobservedProviderRequest(original, provider, index, apiKey, path, newRequestPayload(nil))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, modelListBodyLimit))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("upstream returned %d: %s", resp.StatusCode, truncateString(sanitizeLogString(string(raw)), providerProbeErrorLimit))
+	}
+	return raw, nil
+}
+
+// modelListRequestPath returns the upstream path and query of one model list page.
+func modelListRequestPath(clientType ClientType, cursor string) (string, string) {
+	query := url.Values{}
+	switch clientType {
+	case ClientClaude:
+		query.Set("limit", "1000")
+		if cursor != "" {
+			query.Set("after_id", cursor)
+		}
+		return "/v1/models", query.Encode()
+	case ClientGemini:
+		query.Set("pageSize", "1000")
+		if cursor != "" {
+			query.Set("pageToken", cursor)
+		}
+		return "/v1beta/models", query.Encode()
+	default:
+		return "/v1/models", ""
+	}
+}
+
+// doOAuthModelListRequest lists models with an OAuth credential. The regular
+// OAuth request builders only accept generation requests, so the list request
+// is built here with the same headers.
+func (cp *ClientProxy) doOAuthModelListRequest(ctx context.Context, index int, provider config.Provider, cursor string) (*http.Response, error) {
+	if cp.oauth == nil {
+		return nil, fmt.Errorf("oauth service is not available")
+	}
+	cred, err := cp.oauth.RefreshIfNeededWithHTTPClient(ctx, provider.NormalizedOAuthProvider(), provider.NormalizedOAuthRef(), cp.oauthHTTPClientForProvider(provider, index))
+	if err != nil {
+		return nil, fmt.Errorf("load oauth credential: %w", err)
+	}
+	if cred == nil || strings.TrimSpace(cred.AccessToken) == "" {
+		return nil, fmt.Errorf("oauth credential %q has no access token", provider.NormalizedOAuthRef())
+	}
+
+	var req *http.Request
+	switch provider.NormalizedOAuthProvider() {
+	case config.OAuthProviderClaude:
+		path, query := modelListRequestPath(ClientClaude, cursor)
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, defaultClaudeOAuthBaseURL+path+"?"+query, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(cred.AccessToken))
+		applyClaudeOAuthHeaderDefaults(req, nil, nil, requestContextForClientPath(ClientClaude, path, false), "")
+	case config.OAuthProviderCodex:
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, defaultCodexOAuthBaseURL+"/models?client_version="+url.QueryEscape(codexOAuthVersion), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(cred.AccessToken))
+		applyCodexOAuthHeaders(req, cred, false, newCodexOAuthRequestContext(nil))
+	default:
+		return nil, ErrModelDiscoveryUnsupported
+	}
+	return cp.doPreparedProviderRequest(req, index)
+}
+
+// parseModelList normalizes the model list formats of OpenAI ("data" with
+// "id"), Anthropic ("data" with "display_name", paginated by "last_id"),
+// Gemini ("models" with "name", paginated by "nextPageToken") and Codex
+// ("models" with "slug"). It returns the cursor of the next page, if any.
+func parseModelList(raw []byte) ([]DiscoveredModel, string, error) {
+	var root any
+	if err := json.Unmarshal(raw, &root); err != nil {
+		return nil, "", fmt.Errorf("model list is not valid json: %w", err)
+	}
+	var (
+		items []any
+		next  string
+	)
+	switch v := root.(type) {
+	case []any:
+		items = v
+	case map[string]any:
+		if data, ok := v["data"].([]any); ok {
+			items = data
+		} else if models, ok := v["models"].([]any); ok {
+			items = models
+		} else {
+			return nil, "", fmt.Errorf("model list has no data or models array")
+		}
+		if more, _ := v["has_more"].(bool); more {
+			next = stringValue(v["last_id"])
+		} else {
+			next = stringValue(v["nextPageToken"])
+		}
+	default:
+		return nil, "", fmt.Errorf("model list is not a json object")
+	}
+
+	out := make([]DiscoveredModel, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		id := strings.TrimPrefix(firstModelString(m, "id", "slug", "name"), "models/")
+		if id == "" {
+			continue
+		}
+		out = append(out, DiscoveredModel{
+			ID:              id,
+			DisplayName:     firstModelString(m, "display_name", "displayName"),
+			ContextWindow:   firstModelInt(m, "context_window", "context_length", "inputTokenLimit", "max_input_tokens"),
+			MaxOutputTokens: firstModelInt(m, "max_output_tokens", "outputTokenLimit", "max_completion_tokens"),
+			Capabilities:    modelCapabilities(m),
+		})
+	}
+	return out, strings.TrimSpace(next), nil
+}
+
+func firstModelString(m map[string]any, keys ...string) string {
+	for _, key := range keys {
+		if v := strings.TrimSpace(stringValue(m[key])); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func firstModelInt(m map[string]any, keys ...string) int {
+	for _, key := range keys {
+		if v, ok := m[key].(float64); ok && v > 0 {
+			return int(v)
+		}
+	}
+	return 0
+}
+
+// modelCapabilities reads Gemini's supportedGenerationMethods, or a
+// "capabilities" list or flag map.
+func modelCapabilities(m map[string]any) []string {
+	var out []string
+	appendStrings := func(v any) {
+		list, _ := v.([]any)
+		for _, item := range list {
+			if s := strings.TrimSpace(stringValue(item)); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	appendStrings(m["supportedGenerationMethods"])
+	switch caps := m["capabilities"].(type) {
+	case []any:
+		appendStrings(caps)
+	case map[string]any:
+		for name, enabled := range caps {
+			if on, _ := enabled.(bool); on {
+				out = append(out, name)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
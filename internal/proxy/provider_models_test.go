@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/lansespirit/Clipal/internal/config"
+)
+
+func TestDiscoverProviderModels_NormalizesFamiliesAndFollowsPages(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			if r.Header.Get("x-api-key") == "" {
+				if r.Header.Get("Authorization") == "Bearer bad" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = w.Write([]byte(`{"object":"list","data":[{"id":"gpt-4.1","object":"model"},{"id":"gpt-5","context_length":400000}]}`))
+				return
+			}
+			if r.URL.Query().Get("after_id") == "" {
+				_, _ = w.Write([]byte(`{"data":[{"id":"claude-sonnet-4-5","display_name":"Claude Sonnet 4.5"}],"has_more":true,"last_id":"claude-sonnet-4-5"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"data":[{"id":"claude-haiku-4-5","display_name":"Claude Haiku 4.5"}],"has_more":false,"last_id":"claude-haiku-4-5"}`))
+		case "/v1beta/models":
+			if r.Header.Get("x-goog-api-key") != "g-key" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			_, _ = w.Write([]byte(`{"models":[{"name":"models/gemini-2.5-flash","displayName":"Gemini 2.5 Flash","inputTokenLimit":1048576,"outputTokenLimit":65536,"supportedGenerationMethods":["generateContent","countTokens"]}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+	cfg := &config.Config{Global: config.DefaultGlobalConfig()}
+	ctx := context.Background()
+
+	claude, err := DiscoverProviderModels(ctx, cfg, nil, ClientClaude, config.Provider{Name: "c", BaseURL: upstream.URL, APIKey: "c-key", ProxyMode: config.ProviderProxyModeDirect})
+	if err != nil {
+		t.Fatalf("claude: %v", err)
+	}
+	if len(claude) != 2 || claude[0].ID != "claude-haiku-4-5" || claude[1].DisplayName != "Claude Sonnet 4.5" {
+		t.Fatalf("claude models = %#v", claude)
+	}
+
+	// The first key fails; the next one is tried.
+	openai, err := DiscoverProviderModels(ctx, cfg, nil, ClientOpenAI, config.Provider{Name: "o", BaseURL: upstream.URL, APIKeys: []string{"bad", "good"}, ProxyMode: config.ProviderProxyModeDirect})
+	if err != nil {
+		t.Fatalf("openai: %v", err)
+	}
+	if len(openai) != 2 || openai[1].ID != "gpt-5" || openai[1].ContextWindow != 400000 {
+		t.Fatalf("openai models = %#v", openai)
+	}
+
+	gemini, err := DiscoverProviderModels(ctx, cfg, nil, ClientGemini, config.Provider{Name: "g", BaseURL: upstream.URL, APIKey: "g-key", ProxyMode: config.ProviderProxyModeDirect})
+	if err != nil {
+		t.Fatalf("gemini: %v", err)
+	}
+	want := DiscoveredModel{ID: "gemini-2.5-flash", DisplayName: "Gemini 2.5 Flash", ContextWindow: 1048576, MaxOutputTokens: 65536, Capabilities: []string{"countTokens", "generateContent"}}
+	if len(gemini) != 1 || !reflect.DeepEqual(gemini[0], want) {
+		t.Fatalf("gemini models = %#v", gemini)
+	}
+
+	if _, err := DiscoverProviderModels(ctx, cfg, nil, ClientGemini, config.Provider{Name: "g", BaseURL: upstream.URL, APIKey: "wrong", ProxyMode: config.ProviderProxyModeDirect}); err == nil {
+		t.Fatalf("expected error for rejected key")
+	}
+	oauthGemini := config.Provider{Name: "go", AuthType: config.ProviderAuthTypeOAuth, OAuthProvider: config.OAuthProviderGemini, OAuthRef: "r"}
+	if _, err := DiscoverProviderModels(ctx, cfg, nil, ClientGemini, oauthGemini); !errors.Is(err, ErrModelDiscoveryUnsupported) {
+		t.Fatalf("gemini oauth err = %v", err)
+	}
+}
+
+func TestParseModelList_CodexSlugsAndCapabilityFlags(t *testing.T) {
+	models, next, err := parseModelList([]byte(`{"models":[{"slug":"gpt-5-codex","display_name":"GPT-5 Codex","context_window":272000,"capabilities":{"vision":true,"tools":true,"audio":false}}]}`))
+	if err != nil || next != "" {
+		t.Fatalf("parseModelList: next=%q err=%v", next, err)
+	}
+	want := []DiscoveredModel{{ID: "gpt-5-codex", DisplayName: "GPT-5 Codex", ContextWindow: 272000, Capabilities: []string{"tools", "vision"}}}
+	if !reflect.DeepEqual(models, want) {
+		t.Fatalf("models = %#v", models)
+	}
+	if _, _, err := parseModelList([]byte(`{"error":"nope"}`)); err == nil {
+		t.Fatalf("expected error without a model array")
+	}
+}
@@ -0,0 +1,204 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	oauthpkg "github.com/lansespirit/Clipal/internal/oauth"
+)
+
+// providerProbeTimeout bounds each key's test request.
+const providerProbeTimeout = 45 * time.Second
+
+// providerProbeErrorLimit caps the upstream error text kept in a probe result.
+const providerProbeErrorLimit = 512
+
+// ProviderProbeResult is the outcome of a connection test for one key.
+type ProviderProbeResult struct {
+	KeyIndex       int
+	KeyFingerprint string
+	OK             bool
+	Status         int
+	Latency        time.Duration
+	// EffectiveModel is the model sent upstream after provider overrides.
+	EffectiveModel string
+	// Action and Reason classify a failure the way the failover loop would;
+	// both are empty for a successful test.
+	Action config.FailureRuleAction
+	Reason string
+	Error  string
+}
+
+// DefaultProbeModel returns the model a connection test asks for when the
+// caller does not pick one.
+func DefaultProbeModel(clientType ClientType, provider config.Provider) string {
+	switch clientType {
+	case ClientClaude:
+		return "claude-haiku-4-5"
+	case ClientOpenAI:
+		if provider.UsesOAuth() {
+			return "gpt-5"
+		}
+		return "gpt-4.1-mini"
+	case ClientGemini:
+		return "gemini-2.5-flash"
+	default:
+		return ""
+	}
+}
+
+// ProbeProvider sends a minimal generation request with each of the provider's
+// keys through the regular request-building path: overrides, proxy policy and
+// OAuth refresh all apply. The test runs on a throwaway client proxy, so it
+// never touches circuit breakers, deactivations or usage of the live runtime.
+func ProbeProvider(ctx context.Context, cfg *config.Config, oauth *oauthpkg.Service, clientType ClientType, provider config.Provider, model string) ([]ProviderProbeResult, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("config is nil")
+	}
+	model = strings.TrimSpace(model)
+	if model == "" {
+		model = DefaultProbeModel(clientType, provider)
+	}
+	path, body, err := providerProbeRequest(clientType, provider, model)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := newStandaloneClientProxy(cfg, oauth, clientType, provider)
+	defer cp.Close()
+
+	requestCtx := requestContextForClientPath(clientType, path, false)
+	effectiveModel := provider.ModelOverride()
+	if effectiveModel == "" || !supportsModelFallback(requestCtx) {
+		effectiveModel = model
+	}
+
+	results := make([]ProviderProbeResult, 0, len(cp.providerKeys[0]))
+	for keyIndex, apiKey := range cp.providerKeys[0] {
+		result := cp.probeKey(ctx, requestCtx, path, body, apiKey)
+		result.KeyIndex = keyIndex
+		result.KeyFingerprint = apiKeyFingerprint(apiKey)
+		result.EffectiveModel = effectiveModel
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// ProbeProvider tests a provider with the runtime's config and OAuth service.
+func (r *Router) ProbeProvider(ctx context.Context, clientType ClientType, provider config.Provider, model string) ([]ProviderProbeResult, error) {
+	r.mu.RLock()
+	cfg, oauth := r.cfg, r.oauth
+	r.mu.RUnlock()
+	return ProbeProvider(ctx, cfg, oauth, clientType, provider, model)
+}
+
+// newStandaloneClientProxy builds a single-provider client proxy with the
+// global settings of cfg. It shares no state with the running router.
+func newStandaloneClientProxy(cfg *config.Config, oauth *oauthpkg.Service, clientType ClientType, provider config.Provider) *ClientProxy {
+	durations, err := cfg.Global.RuntimeDurations()
+	if err != nil {
+		durations = config.DefaultRuntimeDurations()
+	}
+	cp := newClientProxyWithGlobalProxy(clientType, config.ClientModeAuto, "", []config.Provider{provider}, durations.ReactivateAfter, durations.UpstreamIdleTimeout, durations.ResponseHeaderTimeout, normalizeCircuitBreakerConfig(cfg.Global.CircuitBreaker), cfg.Global.NormalizedUpstreamProxyMode(), cfg.Global.EffectiveUpstreamProxyIdentity())
+	cp.oauth = oauth
+	cp.applyRoutingRuntimeSettings(routingRuntimeSettingsFromConfig(cfg.Global.Routing))
+	return cp
+}
+
+func (cp *ClientProxy) probeKey(ctx context.Context, requestCtx RequestContext, path string, body []byte, apiKey string) ProviderProbeResult {
+	ctx, cancel := context.WithTimeout(ctx, providerProbeTimeout)
+	defer cancel()
+
+	original, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://clipal.local"+path, bytes.NewReader(body))
+	if err != nil {
+		return ProviderProbeResult{Reason: "request", Error: err.Error()}
+	}
+	original.Header.Set("Content-Type", "application/json")
+	if requestCtx.Family == ProtocolFamilyClaude {
+		original.Header.Set("anthropic-version", "2023-06-01")
+	}
+	original = withRequestContext(original, requestCtx)
+
+	start := time.Now()
+	resp, _, err := cp.doUnobservedProviderRequest(original, cp.providers[0], 0, apiKey, path, newRequestPayload(body))
+	if err != nil {
+		return ProviderProbeResult{
+			Latency: time.Since(start),
+			Reason:  "network",
+			Error:   truncateString(sanitizeLogString(err.Error()), providerProbeErrorLimit),
+		}
+	}
+	defer func() { _ = resp.Body.Close() }()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, failureRuleBodyLimit))
+	result := ProviderProbeResult{Status: resp.StatusCode, Latency: time.Since(start)}
+	respBody := decodeFailureRuleBody(resp.Header, raw)
+
+	eval := cp.failureRulesFor(0).evaluate(FailureRuleSample{Status: resp.StatusCode, Header: resp.Header, Body: respBody}, cp.reactivateAfter)
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices && !eval.Matched {
+		result.OK = true
+		return result
+	}
+	result.Action = eval.Action
+	result.Reason = eval.Reason
+	result.Error = truncateString(sanitizeLogString(string(respBody)), providerProbeErrorLimit)
+	return result
+}
+
+// providerProbeRequest builds the path and body of the smallest generation
+// request for the client family.
+func providerProbeRequest(clientType ClientType, provider config.Provider, model string) (string, []byte, error) {
+	if model == "" {
+		return "", nil, fmt.Errorf("model is required")
+	}
+	var (
+		path string
+		root map[string]any
+	)
+	switch clientType {
+	case ClientClaude:
+		path = "/v1/messages"
+		root = map[string]any{
+			"model":      model,
+			"max_tokens": 1,
+			"messages":   []any{map[string]any{"role": "user", "content": "ping"}},
+		}
+	case ClientOpenAI:
+		// Codex OAuth only serves the Responses API.
+		if provider.UsesOAuth() {
+			path = "/v1/responses"
+			root = map[string]any{
+				"model": model,
+				"input": []any{map[string]any{"role": "user", "content": "ping"}},
+			}
+		} else {
+			path = "/v1/chat/completions"
+			root = map[string]any{
+				"model":    model,
+				"messages": []any{map[string]any{"role": "user", "content": "ping"}},
+			}
+		}
+	case ClientGemini:
+		if strings.ContainsAny(model, "/:?#") {
+			return "", nil, fmt.Errorf("invalid gemini model %q", model)
+		}
+		path = "/v1beta/models/" + model + ":generateContent"
+		root = map[string]any{
+			"contents":         []any{map[string]any{"role": "user", "parts": []any{map[string]any{"text": "ping"}}}},
+			"generationConfig": map[string]any{"maxOutputTokens": 1},
+		}
+	default:
+		return "", nil, fmt.Errorf("unsupported client type %q", clientType)
+	}
+	body, err := json.Marshal(root)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, body, nil
+}
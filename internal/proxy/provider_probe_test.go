@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lansespirit/Clipal/internal/config"
+)
+
+func TestProbeProvider_TestsEachKeyWithOverridesAndClassifiesFailures(t *testing.T) {
+	var models []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		var body map[string]any
+		raw, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(raw, &body)
+		models = append(models, stringValue(body["model"]))
+		switch r.Header.Get("Authorization") {
+		case "Bearer good":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"choices":[]}`))
+		case "Bearer quota":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"quota exhausted"}}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"message":"invalid api key"}}`))
+		}
+	}))
+	defer upstream.Close()
+
+	model := "override-model"
+	// Direct mode: going through http.ProxyFromEnvironment would cache the
+	// proxy environment before other tests set HTTP_PROXY.
+	provider := config.Provider{
+		Name:      "p1",
+		BaseURL:   upstream.URL,
+		APIKeys:   []string{"good", "bad", "quota"},
+		Priority:  1,
+		ProxyMode: config.ProviderProxyModeDirect,
+		Overrides: &config.ProviderOverrides{Model: &model},
+		FailureRules: []config.FailureRule{{
+			Status: []int{http.StatusBadRequest}, BodyRegex: "quota", Action: config.FailureRuleActionDeactivateKey,
+		}},
+	}
+	cfg := &config.Config{Global: config.DefaultGlobalConfig()}
+
+	results, err := ProbeProvider(context.Background(), cfg, nil, ClientOpenAI, provider, "requested-model")
+	if err != nil {
+		t.Fatalf("ProbeProvider: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("results = %#v", results)
+	}
+	for _, m := range models {
+		if m != model {
+			t.Fatalf("upstream models = %v, want override %s", models, model)
+		}
+	}
+
+	if r := results[0]; !r.OK || r.Status != http.StatusOK || r.EffectiveModel != model || r.KeyFingerprint != apiKeyFingerprint("good") || r.Action != "" {
+		t.Fatalf("good key result = %#v", r)
+	}
+	if r := results[1]; r.OK || r.KeyIndex != 1 || r.Status != http.StatusUnauthorized || r.Reason != "auth" || r.Action != config.FailureRuleActionDeactivateKey || r.Error == "" {
+		t.Fatalf("bad key result = %#v", r)
+	}
+	if r := results[2]; r.OK || r.Status != http.StatusBadRequest || r.Action != config.FailureRuleActionDeactivateKey {
+		t.Fatalf("quota key result = %#v", r)
+	}
+}
+
+func TestProbeProvider_GeminiUsesModelPathAndReportsNetworkErrors(t *testing.T) {
+	var path string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if r.Header.Get("x-goog-api-key") != "g-key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"candidates":[]}`))
+	}))
+	cfg := &config.Config{Global: config.DefaultGlobalConfig()}
+	provider := config.Provider{Name: "g", BaseURL: upstream.URL, APIKey: "g-key", Priority: 1, ProxyMode: config.ProviderProxyModeDirect}
+
+	results, err := ProbeProvider(context.Background(), cfg, nil, ClientGemini, provider, "")
+	if err != nil {
+		t.Fatalf("ProbeProvider: %v", err)
+	}
+	want := "/v1beta/models/" + DefaultProbeModel(ClientGemini, provider) + ":generateContent"
+	if len(results) != 1 || !results[0].OK || path != want {
+		t.Fatalf("results = %#v path = %s, want %s", results, path, want)
+	}
+
+	upstream.Close()
+	results, err = ProbeProvider(context.Background(), cfg, nil, ClientGemini, provider, "gemini-2.5-pro")
+	if err != nil {
+		t.Fatalf("ProbeProvider: %v", err)
+	}
+	if len(results) != 1 || results[0].OK || results[0].Reason != "network" || results[0].Error == "" {
+		t.Fatalf("closed upstream result = %#v", results)
+	}
+
+	if _, err := ProbeProvider(context.Background(), cfg, nil, ClientGemini, provider, "models/x:y"); err == nil {
+		t.Fatalf("expected error for invalid gemini model")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
@@ -20,6 +21,7 @@ import (
 	"github.com/lansespirit/Clipal/internal/notify"
 	oauthpkg "github.com/lansespirit/Clipal/internal/oauth"
 	"github.com/lansespirit/Clipal/internal/telemetry"
+	"github.com/lansespirit/Clipal/internal/tracing"
 	"golang.org/x/net/http/httpproxy"
 )
 
@@ -83,6 +85,8 @@ type RequestOutcomeEvent struct {
 	Bytes      int
 	Result     string
 	Detail     string
+	// RecoveredFrom names the provider whose interrupted stream was resumed.
+	RecoveredFrom string
 }
 
 type routingRuntimeSettings struct {
@@ -95,6 +99,13 @@ type routingRuntimeSettings struct {
 	busyProbeMaxInFlight   int
 	shortRetryAfterMax     time.Duration
 	maxInlineWait          time.Duration
+	firstTokenTimeout      time.Duration
+	firstTokenTimeouts     map[RequestCapability]time.Duration
+	streamRecoveryAttempts int
+	countTokens            config.CountTokensMode
+	failureRules           failureRuleSet
+	fallbackModels         config.FallbackModels
+	modelList              config.ModelLists
 }
 
 type upstreamProxyPolicyMode string
@@ -115,6 +126,10 @@ type Router struct {
 	cfg        *config.Config
 	configDir  string
 	telemetry  *telemetry.Store
+	ledger     *telemetry.Ledger
+	metrics    *metricsRegistry
+	events     *eventBus
+	tracer     *tracing.Exporter
 	oauth      *oauthpkg.Service
 	proxies    map[ClientType]*ClientProxy
 	server     *http.Server
@@ -144,6 +159,16 @@ func (r *Router) TelemetryStore() *telemetry.Store {
 	return r.telemetry
 }
 
+// UsageLedger returns the per-request usage ledger shared by all client proxies.
+func (r *Router) UsageLedger() *telemetry.Ledger {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.ledger
+}
+
 // ClientProxy handles requests for a specific client type
 type ClientProxy struct {
 	clientType            ClientType
@@ -167,6 +192,8 @@ type ClientProxy struct {
 	deactivated           []providerDeactivation
 	keyDeactivated        [][]providerDeactivation
 	providerBusy          []providerBusyState
+	providerControls      []providerControl
+	providerFailureRules  []failureRuleSet
 	reactivateAfter       time.Duration
 	upstreamIdle          time.Duration
 
@@ -178,7 +205,13 @@ type ClientProxy struct {
 	lastSwitch             ProviderSwitchEvent
 	lastRequest            RequestOutcomeEvent
 	telemetry              *telemetry.Store
+	ledger                 *telemetry.Ledger
+	metrics                *metricsRegistry
+	events                 *eventBus
+	tracer                 *tracing.Exporter
+	pricing                config.PricingConfig
 	oauth                  *oauthpkg.Service
+	modelList              modelListCache
 }
 
 // Close releases resources held by the ClientProxy.
@@ -213,11 +246,20 @@ func NewRouter(cfg *config.Config) *Router {
 	if err != nil {
 		logger.Warn("failed to load usage telemetry from %s: %v", cfg.ConfigDir(), err)
 	}
+	ledger, err := telemetry.NewLedger(cfg.ConfigDir(), usageLedgerOptions(cfg.Global.UsageLedger))
+	if err != nil {
+		logger.Warn("failed to load usage ledger from %s: %v", cfg.ConfigDir(), err)
+	}
+	events := newEventBus()
 	r := &Router{
 		cfg:        cfg,
 		configDir:  cfg.ConfigDir(),
 		telemetry:  telemetryStore,
-		oauth:      oauthpkg.NewService(cfg.ConfigDir()),
+		ledger:     ledger,
+		metrics:    newMetricsRegistry(),
+		events:     events,
+		tracer:     newTraceExporter(cfg.Global.Tracing),
+		oauth:      oauthpkg.NewService(cfg.ConfigDir(), oauthpkg.WithRefreshObserver(events.oauthRefreshObserver)),
 		proxies:    make(map[ClientType]*ClientProxy),
 		lastMod:    make(map[string]time.Time),
 		watchEvery: 5 * time.Second,
@@ -227,27 +269,51 @@ func NewRouter(cfg *config.Config) *Router {
 	claudeProviders := config.GetEnabledProviders(cfg.Claude)
 	if len(claudeProviders) > 0 {
 		r.proxies[ClientClaude] = newClientProxyWithGlobalProxy(ClientClaude, cfg.Claude.Mode, cfg.Claude.PinnedProvider, claudeProviders, durations.ReactivateAfter, durations.UpstreamIdleTimeout, durations.ResponseHeaderTimeout, cbCfg, cfg.Global.NormalizedUpstreamProxyMode(), cfg.Global.EffectiveUpstreamProxyIdentity(), telemetryStore)
-		r.proxies[ClientClaude].oauth = r.oauth
+		r.attachSharedServices(r.proxies[ClientClaude], cfg, r.tracer)
 		r.proxies[ClientClaude].applyRoutingRuntimeSettings(routingCfg)
 	}
 
 	codexProviders := config.GetEnabledProviders(cfg.OpenAI)
 	if len(codexProviders) > 0 {
 		r.proxies[ClientOpenAI] = newClientProxyWithGlobalProxy(ClientOpenAI, cfg.OpenAI.Mode, cfg.OpenAI.PinnedProvider, codexProviders, durations.ReactivateAfter, durations.UpstreamIdleTimeout, durations.ResponseHeaderTimeout, cbCfg, cfg.Global.NormalizedUpstreamProxyMode(), cfg.Global.EffectiveUpstreamProxyIdentity(), telemetryStore)
-		r.proxies[ClientOpenAI].oauth = r.oauth
+		r.attachSharedServices(r.proxies[ClientOpenAI], cfg, r.tracer)
 		r.proxies[ClientOpenAI].applyRoutingRuntimeSettings(routingCfg)
 	}
 
 	geminiProviders := config.GetEnabledProviders(cfg.Gemini)
 	if len(geminiProviders) > 0 {
 		r.proxies[ClientGemini] = newClientProxyWithGlobalProxy(ClientGemini, cfg.Gemini.Mode, cfg.Gemini.PinnedProvider, geminiProviders, durations.ReactivateAfter, durations.UpstreamIdleTimeout, durations.ResponseHeaderTimeout, cbCfg, cfg.Global.NormalizedUpstreamProxyMode(), cfg.Global.EffectiveUpstreamProxyIdentity(), telemetryStore)
-		r.proxies[ClientGemini].oauth = r.oauth
+		r.attachSharedServices(r.proxies[ClientGemini], cfg, r.tracer)
 		r.proxies[ClientGemini].applyRoutingRuntimeSettings(routingCfg)
 	}
 
 	return r
 }
 
+// attachSharedServices points cp at the router-wide services every client
+// proxy shares. tracer is passed separately because a reload builds the new
+// exporter before it replaces r.tracer.
+func (r *Router) attachSharedServices(cp *ClientProxy, cfg *config.Config, tracer *tracing.Exporter) {
+	cp.oauth = r.oauth
+	cp.ledger = r.ledger
+	cp.metrics = r.metrics
+	cp.events = r.events
+	cp.tracer = tracer
+	cp.pricing = cfg.Pricing
+}
+
+// newTraceExporter returns nil when tracing is disabled.
+func newTraceExporter(cfg config.TracingConfig) *tracing.Exporter {
+	if !cfg.Enabled {
+		return nil
+	}
+	return tracing.NewExporter(tracing.ExporterOptions{
+		Endpoint:    cfg.TracesEndpoint(),
+		Headers:     cfg.Headers,
+		ServiceName: cfg.ServiceName,
+	})
+}
+
 func newClientProxy(clientType ClientType, mode config.ClientMode, pinnedProvider string, providers []config.Provider, reactivateAfter time.Duration, upstreamIdle time.Duration, responseHeaderTimeout time.Duration, cbCfg circuitBreakerConfig, telemetryStore ...*telemetry.Store) *ClientProxy {
 	return newClientProxyWithGlobalProxy(clientType, mode, pinnedProvider, providers, reactivateAfter, upstreamIdle, responseHeaderTimeout, cbCfg, config.GlobalUpstreamProxyModeEnvironment, "", telemetryStore...)
 }
@@ -301,7 +367,7 @@ func newClientProxyWithGlobalProxy(clientType ClientType, mode config.ClientMode
 		}
 		keyDeactivated[i] = make([]providerDeactivation, len(providerKeys[i]))
 	}
-	return &ClientProxy{
+	cp := &ClientProxy{
 		clientType:     clientType,
 		mode:           mode,
 		pinnedProvider: pinnedProvider,
@@ -342,6 +408,8 @@ func newClientProxyWithGlobalProxy(clientType ClientType, mode config.ClientMode
 		deactivated:            make([]providerDeactivation, len(providers)),
 		keyDeactivated:         keyDeactivated,
 		providerBusy:           make([]providerBusyState, len(providers)),
+		providerControls:       make([]providerControl, len(providers)),
+		providerFailureRules:   compileProviderFailureRules(providers),
 		reactivateAfter:        reactivateAfter,
 		upstreamIdle:           upstreamIdle,
 		stickyBindings:         make(map[string]stickyBinding),
@@ -351,6 +419,13 @@ func newClientProxyWithGlobalProxy(clientType ClientType, mode config.ClientMode
 		breakers:               breakers,
 		httpClient:             sharedClient,
 	}
+	for i, cb := range breakers {
+		name := providers[i].Name
+		cb.onTransition = func(from, to circuitState) {
+			cp.publishEvent(Event{Type: EventCircuitChanged, Provider: name, From: string(from), To: string(to)})
+		}
+	}
+	return cp
 }
 
 func newUpstreamHTTPClient(dialer *net.Dialer, responseHeaderTimeout time.Duration, proxy func(*http.Request) (*url.URL, error)) *http.Client {
@@ -525,6 +600,28 @@ func routingRuntimeSettingsFromConfig(cfg config.RoutingConfig) routingRuntimeSe
 			out.busyRetryDelays = delays
 		}
 	}
+	if d, err := time.ParseDuration(strings.TrimSpace(cfg.FirstTokenTimeout.Default)); err == nil && d > 0 {
+		out.firstTokenTimeout = d
+	}
+	for capability, raw := range cfg.FirstTokenTimeout.Capabilities {
+		if d, err := time.ParseDuration(strings.TrimSpace(raw)); err == nil && d >= 0 {
+			if out.firstTokenTimeouts == nil {
+				out.firstTokenTimeouts = make(map[RequestCapability]time.Duration)
+			}
+			out.firstTokenTimeouts[RequestCapability(strings.ToLower(strings.TrimSpace(capability)))] = d
+		}
+	}
+	if cfg.StreamRecovery.Enabled {
+		out.streamRecoveryAttempts = max(cfg.StreamRecovery.MaxAttempts, 1)
+	}
+	out.countTokens = cfg.CountTokens
+	if rules, err := compileFailureRules(failureRuleSourceGlobal, cfg.FailureRules); err != nil {
+		logger.Warn("ignoring routing.failure_rules: %v", err)
+	} else {
+		out.failureRules = rules
+	}
+	out.fallbackModels = cfg.FallbackModels
+	out.modelList = cfg.ModelList
 
 	return out
 }
@@ -598,7 +695,15 @@ func (r *Router) Stop() error {
 			logger.Warn("failed to flush usage telemetry: %v", flushErr)
 		}
 	}
-	return errors.Join(shutdownErr, flushErr)
+	ledgerErr := r.ledger.Close()
+	if ledgerErr != nil {
+		logger.Warn("failed to compact usage ledger: %v", ledgerErr)
+	}
+	r.mu.RLock()
+	tracer := r.tracer
+	r.mu.RUnlock()
+	tracerErr := tracer.Close()
+	return errors.Join(shutdownErr, flushErr, ledgerErr, tracerErr)
 }
 
 func (r *Router) startProviderConfigWatcher() {
@@ -619,6 +724,8 @@ func (r *Router) startProviderConfigWatcher() {
 
 	// Initialize snapshot so we don't reload immediately on start.
 	r.snapshotProviderConfigModTimes()
+	// Edits made while clipal was stopped become a revision of their own.
+	r.recordConfigRevision()
 
 	go func(stopCh <-chan struct{}, doneCh chan struct{}) {
 		defer close(doneCh)
@@ -666,7 +773,7 @@ func (r *Router) stopProviderConfigWatcher() {
 
 func (r *Router) providerConfigFiles() []string {
 	// config.yaml carries global runtime knobs (log level, failover policy, body limit, etc.)
-	// and should be hot-reloaded together with provider configs.
+	// and pricing.yaml the cost catalog; both are hot-reloaded together with provider configs.
 	return config.WatchedConfigFilenames()
 }
 
@@ -733,9 +840,27 @@ func (r *Router) reloadIfProviderConfigsChanged() {
 		return
 	}
 	r.lastMod = nextMod
+	r.recordConfigRevision()
+}
+
+// recordConfigRevision adds the files on disk to the revision history after
+// an external edit. Saves made through the web API record their own revision.
+func (r *Router) recordConfigRevision() {
+	if _, err := config.RecordRevision(r.configDir, config.RevisionSourceFile, ""); err != nil {
+		logger.Warn("failed to record config revision: %v", err)
+	}
 }
 
-func (r *Router) reloadProviderConfigsLocked() error {
+func (r *Router) reloadProviderConfigsLocked() (err error) {
+	defer func() {
+		event := Event{Type: EventConfigReloaded, Result: "reloaded"}
+		if err != nil {
+			event.Result = "failed"
+			event.Reason = err.Error()
+		}
+		r.events.publish(event)
+	}()
+
 	newCfg, err := config.Load(r.configDir)
 	if err != nil {
 		return err
@@ -769,25 +894,39 @@ func (r *Router) reloadProviderConfigsLocked() error {
 	globalProxyMode := newCfg.Global.NormalizedUpstreamProxyMode()
 	globalProxyURL := newCfg.Global.EffectiveUpstreamProxyIdentity()
 
+	r.mu.RLock()
+	tracer := r.tracer
+	r.mu.RUnlock()
+	var oldTracer *tracing.Exporter
+	if !reflect.DeepEqual(oldCfg.Global.Tracing, newCfg.Global.Tracing) {
+		oldTracer = tracer
+		tracer = newTraceExporter(newCfg.Global.Tracing)
+	}
+
 	newProxies := make(map[ClientType]*ClientProxy)
 	if ps := config.GetEnabledProviders(newCfg.Claude); len(ps) > 0 {
 		newProxies[ClientClaude] = newReloadedClientProxy(ClientClaude, newCfg.Claude.Mode, newCfg.Claude.PinnedProvider, ps, durations.ReactivateAfter, durations.UpstreamIdleTimeout, durations.ResponseHeaderTimeout, cbCfg, routingRuntimeSettingsFromConfig(newCfg.Global.Routing), globalProxyMode, globalProxyURL, oldProxies[ClientClaude], r.telemetry)
-		newProxies[ClientClaude].oauth = r.oauth
+		r.attachSharedServices(newProxies[ClientClaude], newCfg, tracer)
 	}
 	if ps := config.GetEnabledProviders(newCfg.OpenAI); len(ps) > 0 {
 		newProxies[ClientOpenAI] = newReloadedClientProxy(ClientOpenAI, newCfg.OpenAI.Mode, newCfg.OpenAI.PinnedProvider, ps, durations.ReactivateAfter, durations.UpstreamIdleTimeout, durations.ResponseHeaderTimeout, cbCfg, routingRuntimeSettingsFromConfig(newCfg.Global.Routing), globalProxyMode, globalProxyURL, oldProxies[ClientOpenAI], r.telemetry)
-		newProxies[ClientOpenAI].oauth = r.oauth
+		r.attachSharedServices(newProxies[ClientOpenAI], newCfg, tracer)
 	}
 	if ps := config.GetEnabledProviders(newCfg.Gemini); len(ps) > 0 {
 		newProxies[ClientGemini] = newReloadedClientProxy(ClientGemini, newCfg.Gemini.Mode, newCfg.Gemini.PinnedProvider, ps, durations.ReactivateAfter, durations.UpstreamIdleTimeout, durations.ResponseHeaderTimeout, cbCfg, routingRuntimeSettingsFromConfig(newCfg.Global.Routing), globalProxyMode, globalProxyURL, oldProxies[ClientGemini], r.telemetry)
-		newProxies[ClientGemini].oauth = r.oauth
+		r.attachSharedServices(newProxies[ClientGemini], newCfg, tracer)
 	}
 	r.reconcileTelemetryUsage(oldCfg, newCfg)
+	if err := r.ledger.SetOptions(usageLedgerOptions(newCfg.Global.UsageLedger)); err != nil {
+		logger.Warn("failed to compact usage ledger: %v", err)
+	}
 
 	r.mu.Lock()
 	r.cfg = newCfg
 	r.proxies = newProxies
+	r.tracer = tracer
 	r.mu.Unlock()
+	_ = oldTracer.Close()
 
 	// Close old proxies to release idle connections.
 	for _, p := range oldProxies {
@@ -839,6 +978,7 @@ func (cp *ClientProxy) inheritRuntimeState(old *ClientProxy) {
 		}
 		cp.deactivated[newIdx] = old.deactivated[oldIdx]
 		cp.providerBusy[newIdx] = old.providerBusy[oldIdx]
+		cp.providerControls[newIdx] = old.providerControls[oldIdx]
 		inheritKeyState(cp, newIdx, old, oldIdx)
 		inheritBreakerState(cp.breakers[newIdx], old.breakers[oldIdx])
 	}
@@ -945,6 +1085,8 @@ func inheritBreakerState(dst *circuitBreaker, src *circuitBreaker) {
 	dst.consecutiveSuccesses = src.consecutiveSuccesses
 	dst.openedAt = src.openedAt
 	dst.halfOpenInFlight = src.halfOpenInFlight
+	dst.manualOpen = src.manualOpen
+	dst.manualReason = src.manualReason
 }
 
 func inheritStickyRuntimeState(dst *ClientProxy, src *ClientProxy, indexMap map[int]int) {
@@ -1037,52 +1179,19 @@ func (r *Router) handleHealth(w http.ResponseWriter, req *http.Request) {
 func (r *Router) handleRequest(w http.ResponseWriter, req *http.Request) {
 	path := req.URL.Path
 
-	// Determine client type from path prefix
-	var requestCtx RequestContext
-	var clientType ClientType
-	var stripPrefix string
-	var clipalPath bool
-
-	switch {
-	case pathMatchesPrefix(path, "/clipal"):
-		clipalPath = true
-		stripPrefix = "/clipal"
-	case pathMatchesPrefix(path, "/claude"):
-		clientType = ClientClaude
-		stripPrefix = "/claude"
-	case pathMatchesPrefix(path, "/openai"):
-		clientType = ClientOpenAI
-		stripPrefix = "/openai"
-	case pathMatchesPrefix(path, "/claudecode"):
-		clientType = ClientClaude
-		stripPrefix = "/claudecode"
-	case pathMatchesPrefix(path, "/codex"):
-		clientType = ClientOpenAI
-		stripPrefix = "/codex"
-	case pathMatchesPrefix(path, "/gemini"):
-		clientType = ClientGemini
-		stripPrefix = "/gemini"
-	default:
-		logger.Warn("unknown path prefix: %s", path)
-		writeProxyError(w, "Unknown endpoint. Use /clipal (preferred), canonical aliases /claude, /openai, /gemini, or legacy aliases /claudecode, /codex", http.StatusNotFound)
-		return
-	}
-
-	newPath := stripClientPrefix(path, stripPrefix)
-	if clipalPath {
-		newPath = canonicalizeClipalPath(newPath)
-		var ok bool
-		requestCtx, ok = detectClipalRequestContext(newPath)
-		if !ok {
-			logger.Warn("unknown /clipal protocol path: %s", newPath)
-			writeProxyError(w, "Unknown /clipal protocol endpoint", http.StatusNotFound)
+	route, ok := routeRequestPath(path, req.Header)
+	if !ok {
+		if route.clipal {
+			logger.Warn("unknown /clipal protocol path: %s", route.path)
+			writeProxyError(w, req, "Unknown /clipal protocol endpoint", http.StatusNotFound)
 			return
 		}
-		clientType = requestCtx.ClientType
-	} else {
-		requestCtx = requestContextForClientPath(clientType, newPath, false)
+		logger.Warn("unknown path prefix: %s", path)
+		writeProxyError(w, req, "Unknown endpoint. Use /clipal (preferred), canonical aliases /claude, /openai, /gemini, or legacy aliases /claudecode, /codex", http.StatusNotFound)
+		return
 	}
-	req = withRequestContext(req, requestCtx)
+	clientType, newPath, requestCtx := route.clientType, route.path, route.requestCtx
+	req = withRequestTrace(withRequestContext(req, requestCtx))
 
 	r.mu.RLock()
 	proxy, exists := r.proxies[clientType]
@@ -1091,7 +1200,7 @@ func (r *Router) handleRequest(w http.ResponseWriter, req *http.Request) {
 
 	if !exists || len(proxy.providers) == 0 {
 		logger.Warn("[%s] no providers configured", clientType)
-		writeProxyError(w, fmt.Sprintf("No providers configured for %s", clientType), http.StatusServiceUnavailable)
+		writeProxyError(w, req, fmt.Sprintf("No providers configured for %s", clientType), http.StatusServiceUnavailable)
 		return
 	}
 
@@ -1100,6 +1209,16 @@ func (r *Router) handleRequest(w http.ResponseWriter, req *http.Request) {
 	}
 
 	logger.Debug("[%s] request received: %s %s", clientType, req.Method, newPath)
+	proxy.publishEvent(Event{
+		Type:       EventRequestStarted,
+		RequestID:  requestTraceFromRequest(req).requestID(),
+		Capability: string(requestCtx.Capability),
+		Detail:     req.Method + " " + newPath,
+	})
+
+	if isModelListRequest(req, requestCtx) && proxy.answerModelList(w, req) {
+		return
+	}
 
 	// Count token endpoints are lightweight advisory requests, so handle them as
 	// single-shot passthroughs that never mutate provider health state.
@@ -1112,6 +1231,61 @@ func (r *Router) handleRequest(w http.ResponseWriter, req *http.Request) {
 	proxy.forwardWithFailover(w, req, newPath)
 }
 
+// requestRoute is where a client request path is routed.
+type requestRoute struct {
+	clientType ClientType
+	// path is the upstream path, with the client prefix stripped.
+	path       string
+	requestCtx RequestContext
+	clipal     bool
+}
+
+// routeRequestPath determines the client type and request context of a client
+// path. ok is false for unknown prefixes and unknown /clipal endpoints; clipal
+// and path are still set for the latter.
+func routeRequestPath(path string, header http.Header) (route requestRoute, ok bool) {
+	var stripPrefix string
+	switch {
+	case pathMatchesPrefix(path, "/clipal"):
+		route.clipal = true
+		stripPrefix = "/clipal"
+	case pathMatchesPrefix(path, "/claude"):
+		route.clientType = ClientClaude
+		stripPrefix = "/claude"
+	case pathMatchesPrefix(path, "/openai"):
+		route.clientType = ClientOpenAI
+		stripPrefix = "/openai"
+	case pathMatchesPrefix(path, "/claudecode"):
+		route.clientType = ClientClaude
+		stripPrefix = "/claudecode"
+	case pathMatchesPrefix(path, "/codex"):
+		route.clientType = ClientOpenAI
+		stripPrefix = "/codex"
+	case pathMatchesPrefix(path, "/gemini"):
+		route.clientType = ClientGemini
+		stripPrefix = "/gemini"
+	default:
+		return route, false
+	}
+
+	route.path = stripClientPrefix(path, stripPrefix)
+	if !route.clipal {
+		route.requestCtx = requestContextForClientPath(route.clientType, route.path, false)
+		return route, true
+	}
+	route.path = canonicalizeClipalPath(route.path)
+	route.requestCtx, ok = detectClipalRequestContext(route.path)
+	if !ok {
+		return route, false
+	}
+	if route.requestCtx.Capability == CapabilityOpenAIModels && header.Get("anthropic-version") != "" {
+		// Anthropic SDKs list models on the OpenAI path; the header tells them apart.
+		route.requestCtx = requestContextForClientPath(ClientClaude, route.path, true)
+	}
+	route.clientType = route.requestCtx.ClientType
+	return route, true
+}
+
 func isClaudeCountTokensPath(path string) bool {
 	return path == "/v1/messages/count_tokens" || path == "/v1/messages/count_tokens/"
 }
@@ -1151,8 +1325,8 @@ func (cp *ClientProxy) createProxyRequestWithPayloadForProvider(original *http.R
 
 	// Copy headers from original request
 	for key, values := range original.Header {
-		// Skip hop-by-hop headers
-		if isHopByHopHeader(key) {
+		// Skip hop-by-hop headers and headers meant for Clipal itself
+		if isHopByHopHeader(key) || isClipalRequestHeader(key) {
 			continue
 		}
 		for _, value := range values {
@@ -1292,6 +1466,12 @@ func isHopByHopHeader(header string) bool {
 	return hopByHopHeaders[http.CanonicalHeaderKey(header)]
 }
 
+// isClipalRequestHeader reports whether a client header is addressed to
+// Clipal and must not reach the upstream.
+func isClipalRequestHeader(header string) bool {
+	return http.CanonicalHeaderKey(header) == headerProject
+}
+
 func pathMatchesPrefix(path, prefix string) bool {
 	return path == prefix || strings.HasPrefix(path, prefix+"/")
 }
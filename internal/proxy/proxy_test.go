@@ -1319,6 +1319,8 @@ func TestGeminiCountTokens_DoesNotRetryOrAffectHealthState(t *testing.T) {
 			Port:            3333,
 			LogLevel:        config.LogLevelDebug,
 			ReactivateAfter: "1h",
+			// Pass the upstream error through instead of counting locally.
+			Routing: config.RoutingConfig{CountTokens: config.CountTokensModeUpstream},
 		},
 		Gemini: config.ClientConfig{
 			Providers: []config.Provider{
@@ -932,7 +932,8 @@ func TestHandleAllUnavailable_RetryAfterAndStatusBranches(t *testing.T) {
 			}
 
 			rr := httptest.NewRecorder()
-			if !cp.handleAllUnavailable(rr) {
+			req := httptest.NewRequest(http.MethodPost, "http://proxy/codex/v1/responses", nil)
+			if !cp.handleAllUnavailable(rr, req, nil, proxyErrorDetail{}) {
 				t.Fatalf("expected handler to write response")
 			}
 
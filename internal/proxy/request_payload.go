@@ -10,7 +10,11 @@ import (
 )
 
 type requestPayload struct {
-	body          []byte
+	body []byte
+	// clientBody is the body as the client sent it when Clipal asked the
+	// upstream for stream usage; see withStreamUsageOption.
+	clientBody    []byte
+	client        *requestPayload
 	rootParsed    bool
 	root          map[string]any
 	overrideCache map[string][]byte
@@ -36,6 +40,30 @@ func newRequestPayload(body []byte) *requestPayload {
 	return &requestPayload{body: body}
 }
 
+// newClientRequestPayload builds the payload of a client request, asking
+// OpenAI streams for usage when the client did not.
+func newClientRequestPayload(req *http.Request, requestCtx RequestContext, body []byte) *requestPayload {
+	rewritten, injected := withStreamUsageOption(req, requestCtx, body)
+	p := newRequestPayload(rewritten)
+	if injected {
+		p.clientBody = body
+	}
+	return p
+}
+
+// withoutStreamUsage returns the payload of the body the client sent, for
+// resending to an upstream that rejected the injected stream_options. ok is
+// false when Clipal did not change the body.
+func (p *requestPayload) withoutStreamUsage() (*requestPayload, bool) {
+	if p == nil || p.clientBody == nil {
+		return nil, false
+	}
+	if p.client == nil {
+		p.client = newRequestPayload(p.clientBody)
+	}
+	return p.client, true
+}
+
 func (p *requestPayload) Body() []byte {
 	if p == nil {
 		return nil
@@ -0,0 +1,381 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/telemetry"
+	"github.com/lansespirit/Clipal/internal/tracing"
+)
+
+// requestTrace collects per-request facts for the usage ledger while a client
+// request moves through failover. It is shared by every attempt of a request.
+type requestTrace struct {
+	mu             sync.Mutex
+	start          time.Time
+	firstByte      time.Time
+	attempts       int
+	keyFingerprint string
+	effectiveModel string
+	payload        *requestPayload
+	usage          telemetry.UsageSnapshot
+	bytes          int
+	recorded       bool
+
+	// project and sessionID attribute the request in the ledger; sessionID
+	// falls back to the request body when the headers carry none.
+	project   string
+	sessionID string
+
+	// streamUsage is set when Clipal added include_usage to the request, so
+	// the usage-only chunk it causes is kept from the client.
+	streamUsage bool
+
+	// spanCtx identifies the request span; parentSpanID comes from an incoming
+	// traceparent header.
+	spanCtx      tracing.SpanContext
+	parentSpanID tracing.SpanID
+	attemptSpans []attemptSpan
+}
+
+// attemptSpan records one upstream attempt for trace export.
+type attemptSpan struct {
+	id             tracing.SpanID
+	provider       string
+	keyFingerprint string
+	model          string
+	start          time.Time
+	end            time.Time
+	status         int
+	reason         string
+	cooldown       time.Duration
+}
+
+type requestTraceKey struct{}
+
+// withRequestTrace attaches a trace to req unless one is already present.
+func withRequestTrace(req *http.Request) *http.Request {
+	if req == nil || requestTraceFromRequest(req) != nil {
+		return req
+	}
+	trace := &requestTrace{
+		start:     time.Now(),
+		project:   requestProject(req),
+		sessionID: headerSessionID(req.Header),
+	}
+	if parent, ok := tracing.ParseTraceparent(req.Header.Get("traceparent")); ok {
+		trace.spanCtx = parent
+		trace.spanCtx.TraceState = req.Header.Get("tracestate")
+		trace.parentSpanID = parent.SpanID
+	} else {
+		trace.spanCtx = tracing.SpanContext{TraceID: tracing.NewTraceID(), Flags: 0x01}
+	}
+	trace.spanCtx.SpanID = tracing.NewSpanID()
+	return req.WithContext(context.WithValue(req.Context(), requestTraceKey{}, trace))
+}
+
+func requestTraceFromRequest(req *http.Request) *requestTrace {
+	if req == nil {
+		return nil
+	}
+	trace, _ := req.Context().Value(requestTraceKey{}).(*requestTrace)
+	return trace
+}
+
+// noteAttempt records an upstream attempt and what it was sent with.
+func (t *requestTrace) noteAttempt(requestCtx RequestContext, provider config.Provider, apiKey string, payload *requestPayload) {
+	if t == nil {
+		return
+	}
+	model := provider.ModelOverride()
+	if model == "" {
+		model = stickyModelName(requestCtx, payload.jsonRoot())
+	}
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.attempts++
+	t.keyFingerprint = apiKeyFingerprint(apiKey)
+	t.effectiveModel = strings.TrimSpace(model)
+	t.payload = payload
+	if n := len(t.attemptSpans); n > 0 && t.attemptSpans[n-1].end.IsZero() {
+		t.attemptSpans[n-1].end = now
+	}
+	t.attemptSpans = append(t.attemptSpans, attemptSpan{
+		id:             tracing.NewSpanID(),
+		provider:       provider.Name,
+		keyFingerprint: t.keyFingerprint,
+		model:          t.effectiveModel,
+		start:          now,
+	})
+}
+
+// attemptSpanContext returns the trace context to send upstream with the
+// latest attempt.
+func (t *requestTrace) attemptSpanContext() (tracing.SpanContext, bool) {
+	if t == nil {
+		return tracing.SpanContext{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.attemptSpans) == 0 {
+		return tracing.SpanContext{}, false
+	}
+	sc := t.spanCtx
+	sc.SpanID = t.attemptSpans[len(t.attemptSpans)-1].id
+	return sc, true
+}
+
+// requestID identifies the client request in runtime events.
+func (t *requestTrace) requestID() string {
+	if t == nil {
+		return ""
+	}
+	return t.spanCtx.SpanID.String()
+}
+
+// noteAttemptFailure ends the latest attempt with the classification that made
+// the proxy move on.
+func (t *requestTrace) noteAttemptFailure(status int, reason string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n := len(t.attemptSpans); n > 0 {
+		attempt := &t.attemptSpans[n-1]
+		attempt.status = status
+		attempt.reason = reason
+		if attempt.end.IsZero() {
+			attempt.end = time.Now()
+		}
+	}
+}
+
+// noteAttemptCooldown records the deactivation or backoff applied after the
+// latest attempt failed.
+func (t *requestTrace) noteAttemptCooldown(d time.Duration) {
+	if t == nil || d <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n := len(t.attemptSpans); n > 0 {
+		t.attemptSpans[n-1].cooldown = d
+	}
+}
+
+func (t *requestTrace) noteStreamUsageInjected() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.streamUsage = true
+}
+
+func (t *requestTrace) streamUsageInjected() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.streamUsage
+}
+
+func (t *requestTrace) noteBytes(n int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bytes = n
+}
+
+// attemptTarget returns the effective model and key fingerprint of the latest
+// attempt.
+func (t *requestTrace) attemptTarget() (model string, keyFingerprint string) {
+	if t == nil {
+		return "", ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.effectiveModel, t.keyFingerprint
+}
+
+func (t *requestTrace) noteFirstByte(now time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.firstByte.IsZero() {
+		t.firstByte = now
+	}
+}
+
+// noteUsage adds usage to the request's ledger totals. A recovered stream
+// notes the interrupted upstream's usage before the continuation's.
+func (t *requestTrace) noteUsage(usage telemetry.UsageSnapshot) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.usage.TotalTokens == 0 && !t.usage.HasCost {
+		t.usage = usage
+		return
+	}
+	prior := t.usage
+	t.usage = usage
+	t.usage.InputTokens += prior.InputTokens
+	t.usage.OutputTokens += prior.OutputTokens
+	t.usage.TotalTokens += prior.TotalTokens
+	t.usage.ReasoningTokens += prior.ReasoningTokens
+	t.usage.ThoughtsTokens += prior.ThoughtsTokens
+	t.usage.CostMicros += prior.CostMicros
+	t.usage.HasCost = t.usage.HasCost || prior.HasCost
+	t.usage.Estimated = t.usage.Estimated || prior.Estimated
+}
+
+// ledgerEntry builds the ledger entry for the request's final outcome. It
+// returns false once an entry has already been produced for this trace.
+func (t *requestTrace) ledgerEntry(now time.Time, requestCtx RequestContext) (telemetry.LedgerEntry, bool) {
+	entry := telemetry.LedgerEntry{Time: now}
+	if t == nil {
+		return entry, true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.recorded {
+		return entry, false
+	}
+	t.recorded = true
+	entry.Attempts = t.attempts
+	entry.KeyFingerprint = t.keyFingerprint
+	entry.EffectiveModel = t.effectiveModel
+	entry.DurationMillis = now.Sub(t.start).Milliseconds()
+	entry.Project = t.project
+	entry.SessionID = t.sessionID
+	if !t.firstByte.IsZero() {
+		entry.TTFBMillis = t.firstByte.Sub(t.start).Milliseconds()
+	}
+	if t.payload != nil {
+		root := t.payload.jsonRoot()
+		entry.RequestedModel = strings.TrimSpace(stickyModelName(requestCtx, root))
+		entry.SessionKey = truncateString(extractRequestStickyKeyFromRoot(requestCtx, root).Key, 128)
+		if entry.SessionID == "" {
+			entry.SessionID = bodySessionID(requestCtx, root)
+		}
+	}
+	entry.ApplyUsage(t.usage)
+	entry.CacheReadTokens, entry.CacheWriteTokens = usageCacheTokens(requestCtx.Family, t.usage.Usage)
+	return entry, true
+}
+
+// spans builds the request span and one child span per upstream attempt for
+// the request's final outcome.
+func (t *requestTrace) spans(entry telemetry.LedgerEntry, method string, requestCtx RequestContext) []tracing.Span {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.spanCtx.Sampled() {
+		// The caller's sampling decision applies to the whole trace.
+		return nil
+	}
+
+	root := tracing.Span{
+		TraceID:      t.spanCtx.TraceID,
+		SpanID:       t.spanCtx.SpanID,
+		ParentSpanID: t.parentSpanID,
+		TraceState:   t.spanCtx.TraceState,
+		Name:         strings.TrimSpace(method + " " + requestCtx.UpstreamPath),
+		Kind:         tracing.SpanKindServer,
+		Start:        t.start,
+		End:          entry.Time,
+		Attributes: []tracing.Attribute{
+			tracing.String("clipal.client_type", entry.ClientType),
+			tracing.String("clipal.capability", entry.Capability),
+			tracing.String("clipal.provider", entry.Provider),
+			tracing.String("clipal.result", entry.Result),
+			tracing.Int64("clipal.attempts", int64(entry.Attempts)),
+			tracing.Int64("http.response.status_code", int64(entry.Status)),
+			tracing.String("gen_ai.request.model", entry.RequestedModel),
+			tracing.String("gen_ai.response.model", entry.EffectiveModel),
+		},
+	}
+	if !entry.Success() {
+		root.Error = true
+		root.StatusMessage = entry.Result
+	}
+	usageAttrs := []tracing.Attribute{
+		tracing.Int64("clipal.response_bytes", int64(t.bytes)),
+		tracing.Int64("gen_ai.usage.input_tokens", entry.InputTokens),
+		tracing.Int64("gen_ai.usage.output_tokens", entry.OutputTokens),
+	}
+	root.Attributes = append(root.Attributes, usageAttrs...)
+
+	out := make([]tracing.Span, 0, len(t.attemptSpans)+1)
+	out = append(out, root)
+	for i, attempt := range t.attemptSpans {
+		span := tracing.Span{
+			TraceID:      t.spanCtx.TraceID,
+			SpanID:       attempt.id,
+			ParentSpanID: t.spanCtx.SpanID,
+			TraceState:   t.spanCtx.TraceState,
+			Name:         "attempt " + attempt.provider,
+			Kind:         tracing.SpanKindClient,
+			Start:        attempt.start,
+			End:          attempt.end,
+			Attributes: []tracing.Attribute{
+				tracing.String("clipal.provider", attempt.provider),
+				tracing.String("clipal.key_fingerprint", attempt.keyFingerprint),
+				tracing.String("gen_ai.request.model", attempt.model),
+			},
+		}
+		if span.End.IsZero() {
+			span.End = entry.Time
+		}
+		status := attempt.status
+		if attempt.reason == "" && i == len(t.attemptSpans)-1 {
+			// The last attempt produced the final outcome.
+			status = entry.Status
+			span.Attributes = append(span.Attributes, usageAttrs...)
+			if !entry.Success() {
+				span.Error = true
+				span.StatusMessage = entry.Result
+			}
+		}
+		if status > 0 {
+			span.Attributes = append(span.Attributes, tracing.Int64("http.response.status_code", int64(status)))
+		}
+		if attempt.reason != "" {
+			span.Error = true
+			span.StatusMessage = attempt.reason
+			span.Attributes = append(span.Attributes, tracing.String("clipal.failure_reason", attempt.reason))
+		}
+		if attempt.cooldown > 0 {
+			span.Attributes = append(span.Attributes, tracing.Int64("clipal.cooldown_ms", attempt.cooldown.Milliseconds()))
+		}
+		out = append(out, span)
+	}
+	return out
+}
+
+// apiKeyFingerprint identifies a key in the ledger without storing it.
+func apiKeyFingerprint(apiKey string) string {
+	apiKey = strings.TrimSpace(apiKey)
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])[:12]
+}
@@ -0,0 +1,455 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/logger"
+)
+
+// ExplainHandling is how the proxy would serve an explained request.
+type ExplainHandling string
+
+const (
+	// ExplainHandlingFailover tries the candidates in order until one answers.
+	ExplainHandlingFailover ExplainHandling = "failover"
+	// ExplainHandlingManual sends the request to the pinned provider only.
+	ExplainHandlingManual ExplainHandling = "manual"
+	// ExplainHandlingSingleShot sends count_tokens requests once, without failover.
+	ExplainHandlingSingleShot ExplainHandling = "single_shot"
+	// ExplainHandlingLocal answers the request without an upstream.
+	ExplainHandlingLocal ExplainHandling = "local"
+	// ExplainHandlingRejected answers the request with an error.
+	ExplainHandlingRejected ExplainHandling = "rejected"
+)
+
+// ErrUnknownRequestPath reports a path that no client proxy serves.
+var ErrUnknownRequestPath = errors.New("unknown request path")
+
+// oauthPreviewRefreshSkew is how close to expiry an OAuth token may be before
+// explain stops previewing the request: building it would refresh the token,
+// which is an upstream call. It covers the OAuth service's own refresh skew.
+const oauthPreviewRefreshSkew = time.Minute
+
+// redactedHeaderValue replaces credentials in previewed upstream headers.
+const redactedHeaderValue = "[redacted]"
+
+// RoutingExplanation describes how the running proxy would route a request.
+type RoutingExplanation struct {
+	ClientType ClientType
+	Method     string
+	// Path is the upstream path, with the client prefix stripped.
+	Path       string
+	Capability RequestCapability
+	Scope      string
+	Handling   ExplainHandling
+	// Notes explain handling that does not depend on a provider.
+	Notes []string
+	// Sticky is nil when the request carries no sticky key.
+	Sticky     *StickyExplanation
+	Candidates []RoutingCandidate
+}
+
+// StickyExplanation describes the sticky key of an explained request and the
+// binding it resolves to.
+type StickyExplanation struct {
+	Level   string
+	Source  string
+	Preview string
+	// Provider and Key are the bound provider and its 1-based key, empty when
+	// the key has no binding.
+	Provider string
+	Key      int
+	// Applied reports whether routing starts at the bound provider.
+	Applied bool
+	Detail  string
+}
+
+// RoutingCandidate is one provider in the order the proxy would consider it.
+type RoutingCandidate struct {
+	Provider string
+	// Attempt is the 1-based position among the providers that would be
+	// tried, or 0 when the provider is skipped.
+	Attempt int
+	// Reason says why the provider would be tried or skipped.
+	Reason string
+	// Key is the 1-based key the first attempt would use.
+	Key            int
+	KeyFingerprint string
+	AvailableKeys  int
+	Model          string
+	FallbackModels []string
+	// Request is the upstream request the first attempt would send. It is nil
+	// for skipped providers and when Error is set.
+	Request *UpstreamRequestPreview
+	// Error says why the upstream request could not be previewed.
+	Error string
+}
+
+// UpstreamRequestPreview is an upstream request with its credentials redacted.
+type UpstreamRequestPreview struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// ExplainRequest works out how the running proxy would route a request to
+// target, a client path with an optional query, without sending anything or
+// changing any routing state.
+func (r *Router) ExplainRequest(ctx context.Context, method string, target string, header http.Header, body []byte) (RoutingExplanation, error) {
+	if strings.TrimSpace(method) == "" {
+		method = http.MethodPost
+	}
+	u, err := url.Parse(strings.TrimSpace(target))
+	if err != nil || !strings.HasPrefix(u.Path, "/") || u.Host != "" {
+		return RoutingExplanation{}, fmt.Errorf("%w: %q", ErrUnknownRequestPath, target)
+	}
+	route, ok := routeRequestPath(u.Path, header)
+	if !ok {
+		return RoutingExplanation{}, fmt.Errorf("%w: %s", ErrUnknownRequestPath, u.Path)
+	}
+
+	r.mu.RLock()
+	cp := r.proxies[route.clientType]
+	maxBody := r.cfg.Global.MaxRequestBody
+	host := net.JoinHostPort(strings.TrimSpace(r.cfg.Global.ListenAddr), strconv.Itoa(r.cfg.Global.Port))
+	r.mu.RUnlock()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return RoutingExplanation{}, fmt.Errorf("%w: %v", ErrUnknownRequestPath, err)
+	}
+	req.Host = host
+	if header != nil {
+		req.Header = header.Clone()
+	}
+	if len(body) > 0 && req.Header.Get("Content-Type") == "" {
+		// Request overrides only rewrite JSON bodies; clients always send one.
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req = withRequestContext(req, route.requestCtx)
+
+	out := RoutingExplanation{
+		ClientType: route.clientType,
+		Method:     method,
+		Path:       route.path,
+		Capability: route.requestCtx.Capability,
+		Scope:      string(routingScopeForCapability(route.requestCtx.Capability)),
+	}
+	if cp == nil || len(cp.providers) == 0 {
+		out.Handling = ExplainHandlingRejected
+		out.Notes = append(out.Notes, fmt.Sprintf("No providers configured for %s.", route.clientType))
+		return out, nil
+	}
+	if maxBody > 0 && int64(len(body)) > maxBody {
+		out.Handling = ExplainHandlingRejected
+		out.Notes = append(out.Notes, fmt.Sprintf("The body exceeds max_request_body (%d bytes).", maxBody))
+		return out, nil
+	}
+	cp.explainRequest(req, route.path, body, &out)
+	return out, nil
+}
+
+// explainRequest mirrors handleRequest past the client lookup.
+func (cp *ClientProxy) explainRequest(req *http.Request, path string, body []byte, out *RoutingExplanation) {
+	requestCtx, _ := requestContextFromRequest(req)
+
+	if isModelListRequest(req, requestCtx) {
+		switch settings := cp.modelListSettings(); settings.NormalizedSource() {
+		case config.ModelListSourceStatic:
+			out.Handling = ExplainHandlingLocal
+			out.Notes = append(out.Notes, "Answered locally from the static models of routing.model_list.")
+			return
+		case config.ModelListSourceMerged:
+			out.Notes = append(out.Notes, "Answered with the merged model list of all providers; the candidates below only serve it when no provider lists models.")
+		}
+	}
+
+	if requestCtx.Capability == CapabilityClaudeCountTokens || requestCtx.Capability == CapabilityGeminiCountTokens {
+		switch cp.countTokensMode() {
+		case config.CountTokensModeLocal:
+			out.Handling = ExplainHandlingLocal
+			out.Notes = append(out.Notes, "Counted locally (routing.count_tokens is local); bodies that cannot be counted go to the upstream.")
+			return
+		case config.CountTokensModeFallback:
+			out.Notes = append(out.Notes, "Counted locally if the upstream is unreachable, lacks the endpoint or returns a server error.")
+		}
+		if cp.mode == config.ClientModeManual {
+			cp.explainManual(req, path, newRequestPayload(body), out)
+			return
+		}
+		cp.explainCountTokens(req, path, newRequestPayload(body), out)
+		return
+	}
+
+	payload := newClientRequestPayload(req, requestCtx, body)
+	if cp.mode == config.ClientModeManual {
+		cp.explainManual(req, path, payload, out)
+		return
+	}
+	cp.explainFailover(req, path, requestCtx, payload, out)
+}
+
+// explainFailover mirrors forwardWithFailover, running its selection helpers
+// in peek mode so the routing state is left as it was.
+func (cp *ClientProxy) explainFailover(req *http.Request, path string, requestCtx RequestContext, payload *requestPayload, out *RoutingExplanation) {
+	out.Handling = ExplainHandlingFailover
+	scope := routingScopeForCapability(requestCtx.Capability)
+	now := time.Now()
+
+	_, startIndex := cp.getActiveCountAndStartIndexForScope(scope, requestCtx.Capability, true)
+	cursorIndex := startIndex
+
+	stickyIndex, stickyKeyIndex := -1, -1
+	if requestKey := payload.requestStickyKey(requestCtx); strings.TrimSpace(requestKey.Key) != "" {
+		sticky := &StickyExplanation{Level: string(requestKey.Level), Source: requestKey.Source, Preview: requestKey.Preview}
+		if sticky.Preview == "" && requestKey.Level != stickyKeyLevelL3 {
+			sticky.Preview = truncateString(requestKey.Key, stickyPreviewLimit)
+		}
+		boundIndex, boundKeyIndex, found, applied := cp.stickyStart(scope, requestCtx.Capability, requestKey, now, true)
+		switch {
+		case !found:
+			sticky.Detail = "No binding for this key yet; a successful response creates one."
+		default:
+			sticky.Provider = cp.providers[boundIndex].Name
+			sticky.Key = boundKeyIndex + 1
+			if applied {
+				sticky.Applied = true
+				sticky.Detail = "Routing starts at the bound provider."
+				startIndex = boundIndex
+				stickyIndex, stickyKeyIndex = boundIndex, boundKeyIndex
+			} else {
+				sticky.Detail = fmt.Sprintf("Bound to %s, which cannot take this request; routing starts at the current provider.", sticky.Provider)
+			}
+		}
+		out.Sticky = sticky
+	}
+
+	attempt := 0
+	for offset := 0; offset < len(cp.providers); offset++ {
+		index := (startIndex + offset) % len(cp.providers)
+		provider := cp.providers[index]
+		cand := RoutingCandidate{Provider: provider.Name, AvailableKeys: cp.activeKeyCount(index)}
+		if skip := cp.candidateSkip(index, requestCtx.Capability, now); skip != "" {
+			cand.Reason = cp.candidateSkipDetail(index, requestCtx.Capability, skip, now)
+			out.Candidates = append(out.Candidates, cand)
+			continue
+		}
+		if allow := cp.allowCircuit(now, index, true); !allow.allowed {
+			cand.Reason = circuitSkipDetail(allow)
+			out.Candidates = append(out.Candidates, cand)
+			continue
+		}
+		wait, skip := cp.candidateBusyWait(index, startIndex, now)
+		if skip {
+			cand.Reason = busyDetail(cp.providerBusySnapshot(index).Reason, wait)
+			out.Candidates = append(out.Candidates, cand)
+			continue
+		}
+
+		attempt++
+		cand.Attempt = attempt
+		switch {
+		case index == stickyIndex:
+			cand.Reason = fmt.Sprintf("Bound by the %s sticky key (%s).", out.Sticky.Level, out.Sticky.Source)
+		case index == cursorIndex:
+			cand.Reason = "Current provider for this routing scope."
+		default:
+			cand.Reason = "Tried if the earlier providers fail."
+		}
+		if wait > 0 {
+			cand.Reason += fmt.Sprintf(" Waits %s for its busy backoff first.", wait.Truncate(time.Millisecond))
+		}
+
+		keyFrom := -1
+		if index == stickyIndex {
+			keyFrom = stickyKeyIndex
+		}
+		_, keyIndex := cp.getActiveKeyCountAndStartIndexForScope(index, scope, keyFrom, true)
+		cp.explainAttempt(req, path, index, keyIndex, payload, &cand)
+		out.Candidates = append(out.Candidates, cand)
+	}
+	if attempt == 0 {
+		out.Notes = append(out.Notes, "No provider can take the request now; it would be answered with an unavailable error.")
+	}
+}
+
+// explainManual mirrors forwardManual.
+func (cp *ClientProxy) explainManual(req *http.Request, path string, payload *requestPayload, out *RoutingExplanation) {
+	out.Handling = ExplainHandlingManual
+	index := cp.pinnedIndex
+	if index < 0 {
+		index = providerIndexByName(cp.providers, cp.pinnedProvider)
+	}
+	if index < 0 || index >= len(cp.providers) {
+		out.Notes = append(out.Notes, "Manual mode is on but the pinned provider is not configured.")
+		return
+	}
+	requestCtx, _ := requestContextFromRequest(req)
+	provider := cp.providers[index]
+	cand := RoutingCandidate{Provider: provider.Name, AvailableKeys: cp.activeKeyCount(index)}
+	switch {
+	case !providerSupportsCapability(provider, requestCtx.Capability):
+		cand.Reason = capabilitySkipDetail(provider, requestCtx.Capability)
+	case len(cp.providerKeys[index]) == 0:
+		cand.Reason = "The pinned provider has no configured API keys."
+	default:
+		cand.Attempt = 1
+		cand.Reason = "Pinned provider (manual mode); health checks are bypassed."
+		cp.explainAttempt(req, path, index, cp.preferredKeyIndexForScope(index, routingScopeForCapability(requestCtx.Capability)), payload, &cand)
+	}
+	out.Candidates = append(out.Candidates, cand)
+}
+
+// explainCountTokens mirrors forwardCountTokensSingleShot.
+func (cp *ClientProxy) explainCountTokens(req *http.Request, path string, payload *requestPayload, out *RoutingExplanation) {
+	out.Handling = ExplainHandlingSingleShot
+	index, provider, keyIndex, ok := cp.countTokensSingleShotTarget()
+	if !ok {
+		out.Notes = append(out.Notes, "No provider can take the request now.")
+		return
+	}
+	cand := RoutingCandidate{
+		Provider:      provider.Name,
+		Attempt:       1,
+		Reason:        "Current provider; count_tokens requests are sent once, without failover.",
+		AvailableKeys: cp.activeKeyCount(index),
+	}
+	cp.explainAttempt(req, path, index, keyIndex, payload, &cand)
+	out.Candidates = append(out.Candidates, cand)
+}
+
+// candidateSkipDetail describes why candidateSkip turned the provider away.
+func (cp *ClientProxy) candidateSkipDetail(index int, capability RequestCapability, reason candidateSkipReason, now time.Time) string {
+	switch reason {
+	case candidateSkipCapability:
+		return capabilitySkipDetail(cp.providers[index], capability)
+	case candidateSkipDeactivated:
+		cp.mu.RLock()
+		d := cp.deactivated[index]
+		cp.mu.RUnlock()
+		return providerUnavailableDetail(d.reason, d.message, d.until.Sub(now).Truncate(time.Second).String())
+	default:
+		return "Every key is deactivated."
+	}
+}
+
+// circuitSkipDetail describes why a provider's circuit breaker turned the
+// request away.
+func circuitSkipDetail(allow circuitAllowResult) string {
+	if allow.reason == circuitBlockHalfOpenBusy {
+		return providerCircuitDetail("half_open", "")
+	}
+	return providerCircuitDetail("open", allow.wait.Truncate(time.Second).String())
+}
+
+func capabilitySkipDetail(provider config.Provider, capability RequestCapability) string {
+	if provider.UsesOAuth() {
+		return "Only supports " + supportedCapabilitySummary(provider) + "."
+	}
+	return fmt.Sprintf("Does not support %s requests.", capability)
+}
+
+func busyDetail(reason string, wait time.Duration) string {
+	detail := "Busy."
+	if reason = strings.TrimSpace(reason); reason != "" {
+		detail = fmt.Sprintf("Busy (%s).", reason)
+	}
+	return fmt.Sprintf("%s Retry in %s.", strings.TrimSuffix(detail, "."), wait.Truncate(time.Second))
+}
+
+// explainAttempt fills in the key, model and upstream request of an attempt.
+func (cp *ClientProxy) explainAttempt(req *http.Request, path string, index int, keyIndex int, payload *requestPayload, cand *RoutingCandidate) {
+	provider := cp.providers[index]
+	requestCtx, _ := requestContextFromRequest(req)
+	apiKey := ""
+	if keyIndex >= 0 && keyIndex < len(cp.providerKeys[index]) {
+		apiKey = cp.providerKeys[index][keyIndex]
+	}
+	if !provider.UsesOAuth() {
+		cand.Key = keyIndex + 1
+		cand.KeyFingerprint = apiKeyFingerprint(apiKey)
+	}
+	cand.Model = provider.ModelOverride()
+	if cand.Model == "" {
+		cand.Model = strings.TrimSpace(stickyModelName(requestCtx, payload.jsonRoot()))
+	}
+	if chain := cp.modelFallbackChainFor(req, index, requestCtx, payload); chain != nil {
+		cand.FallbackModels = chain.models
+	}
+
+	preview, err := cp.previewUpstreamRequest(req, provider, index, apiKey, path, payload)
+	if err != nil {
+		cand.Error = truncateString(sanitizeLogString(err.Error()), 512)
+		return
+	}
+	cand.Request = preview
+}
+
+// previewUpstreamRequest builds the upstream request an attempt would send and
+// redacts its credentials.
+func (cp *ClientProxy) previewUpstreamRequest(original *http.Request, provider config.Provider, index int, apiKey string, path string, payload *requestPayload) (*UpstreamRequestPreview, error) {
+	if provider.UsesOAuth() {
+		if cp.oauth == nil {
+			return nil, fmt.Errorf("oauth service is unavailable")
+		}
+		cred, err := cp.oauth.Load(provider.NormalizedOAuthProvider(), provider.NormalizedOAuthRef())
+		if err != nil {
+			return nil, fmt.Errorf("load oauth credential: %w", err)
+		}
+		if strings.TrimSpace(cred.RefreshToken) != "" && cred.NeedsRefresh(time.Now(), oauthPreviewRefreshSkew) {
+			return nil, fmt.Errorf("the OAuth access token is due for a refresh, which explain does not perform")
+		}
+	}
+	proxyReq, err := cp.createProxyRequestWithPayloadForProvider(original, provider, index, apiKey, path, payload)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(proxyReq.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &UpstreamRequestPreview{
+		Method: proxyReq.Method,
+		URL:    logger.Redact(proxyReq.URL.String()),
+		Header: redactUpstreamHeaders(proxyReq.Header),
+		Body:   body,
+	}, nil
+}
+
+// redactUpstreamHeaders hides credentials, keeping the auth scheme so the
+// preview still shows how the key is sent.
+func redactUpstreamHeaders(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for key, values := range h {
+		redacted := make([]string, 0, len(values))
+		for _, v := range values {
+			switch http.CanonicalHeaderKey(key) {
+			case "Authorization", "Proxy-Authorization":
+				if scheme, _, ok := strings.Cut(v, " "); ok {
+					v = scheme + " " + redactedHeaderValue
+				} else {
+					v = redactedHeaderValue
+				}
+			case "X-Api-Key", "X-Goog-Api-Key", "Cookie", "Chatgpt-Account-Id":
+				v = redactedHeaderValue
+			default:
+				v = logger.Redact(v)
+			}
+			redacted = append(redacted, v)
+		}
+		out[key] = redacted
+	}
+	return out
+}
@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+)
+
+func TestExplainRequest_OrdersCandidatesWithoutChangingState(t *testing.T) {
+	model := "gpt-override"
+	cfg := &config.Config{Global: config.DefaultGlobalConfig()}
+	cfg.Global.CircuitBreaker = config.CircuitBreakerConfig{FailureThreshold: 2, SuccessThreshold: 1, OpenTimeout: "30s", HalfOpenMaxInFlight: 1}
+	cfg.OpenAI = config.ClientConfig{Mode: config.ClientModeAuto, Providers: []config.Provider{
+		{Name: "p1", BaseURL: "https://p1.example", APIKey: "k1", Priority: 1},
+		{Name: "p2", BaseURL: "https://p2.example/api", APIKeys: []string{"k2a", "k2b"}, Priority: 2, Overrides: &config.ProviderOverrides{Model: &model}},
+		{Name: "p3", BaseURL: "https://p3.example", APIKey: "k3", Priority: 3},
+	}}
+	router := NewRouter(cfg)
+	cp := router.proxies[ClientOpenAI]
+	if err := router.ControlProvider(ClientOpenAI, "p1", RuntimeControl{Action: RuntimeActionDeactivate, Duration: time.Hour, Reason: "maintenance"}); err != nil {
+		t.Fatalf("deactivate: %v", err)
+	}
+	if err := router.ControlProvider(ClientOpenAI, "p3", RuntimeControl{Action: RuntimeActionOpenCircuit, Duration: time.Minute}); err != nil {
+		t.Fatalf("open circuit: %v", err)
+	}
+	cp.responseLookup["resp_1"] = stickyLookupEntry{ProviderIndex: 2, KeyIndex: 0, LastSeenAt: time.Now().Add(-time.Minute), Source: "response_id"}
+	seenAt := cp.responseLookup["resp_1"].LastSeenAt
+	currentIndex := cp.currentIndex
+
+	header := http.Header{"Authorization": []string{"Bearer client-secret"}}
+	out, err := router.ExplainRequest(context.Background(), http.MethodPost, "/clipal/v1/chat/completions", header, []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatalf("ExplainRequest: %v", err)
+	}
+	if out.ClientType != ClientOpenAI || out.Path != "/v1/chat/completions" || out.Handling != ExplainHandlingFailover || out.Sticky != nil {
+		t.Fatalf("explanation = %#v", out)
+	}
+	if len(out.Candidates) != 3 {
+		t.Fatalf("candidates = %#v", out.Candidates)
+	}
+	first, circuit, deactivated := out.Candidates[0], out.Candidates[1], out.Candidates[2]
+	if first.Provider != "p2" || first.Attempt != 1 || first.Key != 1 || first.Model != model || first.Request == nil {
+		t.Fatalf("first candidate = %#v", first)
+	}
+	if first.Request.URL != "https://p2.example/api/v1/chat/completions" || first.Request.Header.Get("Authorization") != "Bearer [redacted]" {
+		t.Fatalf("first request = %#v", first.Request)
+	}
+	if !strings.Contains(string(first.Request.Body), `"model":"gpt-override"`) {
+		t.Fatalf("first request body = %s", first.Request.Body)
+	}
+	if circuit.Provider != "p3" || circuit.Attempt != 0 || !strings.HasPrefix(circuit.Reason, "Circuit breaker is open.") || circuit.Request != nil {
+		t.Fatalf("circuit candidate = %#v", circuit)
+	}
+	if deactivated.Provider != "p1" || deactivated.Attempt != 0 || !strings.HasPrefix(deactivated.Reason, "Deactivated manually: maintenance.") {
+		t.Fatalf("deactivated candidate = %#v", deactivated)
+	}
+
+	// Like a real request, a binding to a provider with an open circuit starts
+	// the order there and then skips it.
+	out, err = router.ExplainRequest(context.Background(), "", "/openai/v1/responses", nil, []byte(`{"model":"gpt-4o","previous_response_id":"resp_1","input":"hi"}`))
+	if err != nil {
+		t.Fatalf("ExplainRequest responses: %v", err)
+	}
+	if out.Sticky == nil || out.Sticky.Provider != "p3" || !out.Sticky.Applied || out.Sticky.Level != string(stickyKeyLevelL1) {
+		t.Fatalf("sticky = %#v", out.Sticky)
+	}
+	if out.Candidates[0].Provider != "p3" || out.Candidates[0].Attempt != 0 || out.Candidates[2].Provider != "p2" || out.Candidates[2].Attempt != 1 {
+		t.Fatalf("sticky candidates = %#v", out.Candidates)
+	}
+	if err := router.ControlProvider(ClientOpenAI, "p3", RuntimeControl{Action: RuntimeActionResetCircuit}); err != nil {
+		t.Fatalf("reset circuit: %v", err)
+	}
+	out, err = router.ExplainRequest(context.Background(), "", "/openai/v1/responses", nil, []byte(`{"model":"gpt-4o","previous_response_id":"resp_1","input":"hi"}`))
+	if err != nil {
+		t.Fatalf("ExplainRequest responses: %v", err)
+	}
+	if !out.Sticky.Applied || out.Candidates[0].Provider != "p3" || !strings.HasPrefix(out.Candidates[0].Reason, "Bound by the L1 sticky key") {
+		t.Fatalf("sticky explanation = %#v", out)
+	}
+
+	if cp.currentIndex != currentIndex || !cp.responseLookup["resp_1"].LastSeenAt.Equal(seenAt) {
+		t.Fatalf("explain changed routing state: currentIndex=%d lookup=%#v", cp.currentIndex, cp.responseLookup["resp_1"])
+	}
+
+	if _, err := router.ExplainRequest(context.Background(), http.MethodPost, "/nope/v1/chat/completions", nil, nil); !errors.Is(err, ErrUnknownRequestPath) {
+		t.Fatalf("unknown path err = %v", err)
+	}
+}
+
+func TestExplainRequest_MatchesLiveRoute(t *testing.T) {
+	cfg := &config.Config{Global: config.DefaultGlobalConfig()}
+	cfg.OpenAI = config.ClientConfig{Mode: config.ClientModeAuto, Providers: []config.Provider{
+		{Name: "p1", BaseURL: "https://p1.example", APIKeys: []string{"k1a", "k1b"}, Priority: 1},
+		{Name: "p2", BaseURL: "https://p2.example", APIKey: "k2", Priority: 2},
+	}}
+	router := NewRouter(cfg)
+	cp := router.proxies[ClientOpenAI]
+	var sent []string
+	cp.httpClient.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		sent = append(sent, r.URL.Host+" "+r.Header.Get("Authorization"))
+		return newResponse(http.StatusOK, http.Header{"Content-Type": []string{"application/json"}}, `{}`), nil
+	})
+	now := time.Now()
+	cp.mu.Lock()
+	cp.responseLookup["resp_1"] = stickyLookupEntry{ProviderIndex: 0, KeyIndex: 1, LastSeenAt: now, Source: "response_id"}
+	cp.responseLookup["resp_old"] = stickyLookupEntry{ProviderIndex: 1, KeyIndex: 0, LastSeenAt: now.Add(-30 * 24 * time.Hour), Source: "response_id"}
+	cp.mu.Unlock()
+
+	for _, tc := range []struct {
+		previous string
+		applied  bool
+		want     string
+	}{
+		{previous: "resp_1", applied: true, want: "p1.example Bearer k1b"},
+		// An expired binding no longer steers the live route, so explain
+		// must not report it either.
+		{previous: "resp_old", applied: false, want: "p1.example Bearer k1b"},
+	} {
+		body := []byte(`{"model":"gpt-4o","previous_response_id":"` + tc.previous + `","input":"hi"}`)
+		out, err := router.ExplainRequest(context.Background(), "", "/openai/v1/responses", nil, body)
+		if err != nil {
+			t.Fatalf("%s: ExplainRequest: %v", tc.previous, err)
+		}
+		if out.Sticky == nil || out.Sticky.Applied != tc.applied || len(out.Candidates) == 0 || out.Candidates[0].Attempt != 1 {
+			t.Fatalf("%s: explanation = %#v sticky = %#v", tc.previous, out, out.Sticky)
+		}
+		first := out.Candidates[0]
+		explained := first.Provider + ".example " + first.KeyFingerprint
+
+		sent = nil
+		req := httptest.NewRequest(http.MethodPost, "http://proxy/openai/v1/responses", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.handleRequest(rr, req)
+		if rr.Code != http.StatusOK || len(sent) != 1 {
+			t.Fatalf("%s: live status = %d sent = %v", tc.previous, rr.Code, sent)
+		}
+		host, key, _ := strings.Cut(sent[0], " Bearer ")
+		if sent[0] != tc.want || explained != host+" "+apiKeyFingerprint(key) {
+			t.Fatalf("%s: explained %q, live sent %q, want %q", tc.previous, explained, sent[0], tc.want)
+		}
+	}
+}
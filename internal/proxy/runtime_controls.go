@@ -0,0 +1,185 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/logger"
+)
+
+// RuntimeAction is a manual change to a provider's runtime state.
+type RuntimeAction string
+
+const (
+	// RuntimeActionDeactivate takes a provider, or one of its keys, out of
+	// rotation for a duration.
+	RuntimeActionDeactivate RuntimeAction = "deactivate"
+	// RuntimeActionReactivate ends a deactivation immediately. Without a key it
+	// reactivates the provider and all of its keys.
+	RuntimeActionReactivate RuntimeAction = "reactivate"
+	// RuntimeActionResetCircuit closes the circuit breaker and forgets recent failures.
+	RuntimeActionResetCircuit RuntimeAction = "reset_circuit"
+	// RuntimeActionOpenCircuit opens the circuit breaker for a duration.
+	RuntimeActionOpenCircuit RuntimeAction = "open_circuit"
+	// RuntimeActionClearBusy drops the busy backoff of the provider.
+	RuntimeActionClearBusy RuntimeAction = "clear_busy"
+)
+
+// manualDeactivationReason is the deactivation reason recorded for manual
+// deactivations; the operator's reason becomes the deactivation message.
+const manualDeactivationReason = "manual"
+
+var (
+	// ErrInvalidRuntimeControl reports a runtime control that cannot be applied
+	// as given.
+	ErrInvalidRuntimeControl = errors.New("invalid runtime control")
+	// ErrProviderNotRunning reports a provider that the running proxy does not
+	// route to, such as a disabled or unknown one.
+	ErrProviderNotRunning = errors.New("provider is not active in the running proxy")
+)
+
+// RuntimeControl describes one manual runtime action.
+type RuntimeControl struct {
+	Action RuntimeAction
+	// Key is the 1-based key index for key-level deactivate and reactivate, or
+	// 0 for the whole provider.
+	Key int
+	// Duration is how long deactivate and open_circuit last.
+	Duration time.Duration
+	// Reason is shown in the provider status and events.
+	Reason string
+}
+
+// providerControl records the last runtime control applied to a provider.
+type providerControl struct {
+	action RuntimeAction
+	key    int
+	reason string
+	at     time.Time
+}
+
+// Validate checks the fields the action needs.
+func (c RuntimeControl) Validate() error {
+	switch c.Action {
+	case RuntimeActionDeactivate, RuntimeActionOpenCircuit:
+		if c.Duration <= 0 {
+			return fmt.Errorf("%w: %s needs a positive duration", ErrInvalidRuntimeControl, c.Action)
+		}
+	case RuntimeActionReactivate, RuntimeActionResetCircuit, RuntimeActionClearBusy:
+	default:
+		return fmt.Errorf("%w: unknown action %q", ErrInvalidRuntimeControl, c.Action)
+	}
+	if c.Key < 0 {
+		return fmt.Errorf("%w: key must not be negative", ErrInvalidRuntimeControl)
+	}
+	if c.Key > 0 && c.Action != RuntimeActionDeactivate && c.Action != RuntimeActionReactivate {
+		return fmt.Errorf("%w: %s applies to the whole provider, not a key", ErrInvalidRuntimeControl, c.Action)
+	}
+	return nil
+}
+
+// ControlProvider applies a manual runtime action to a running provider.
+func (r *Router) ControlProvider(clientType ClientType, provider string, control RuntimeControl) error {
+	if err := control.Validate(); err != nil {
+		return err
+	}
+	cp := r.clientProxy(clientType)
+	if cp == nil {
+		return fmt.Errorf("%w: %s/%s", ErrProviderNotRunning, clientType, provider)
+	}
+	return cp.applyRuntimeControl(provider, control, time.Now())
+}
+
+// clientProxy returns the running proxy of clientType, or nil when the client
+// has no enabled providers.
+func (r *Router) clientProxy(clientType ClientType) *ClientProxy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.proxies[clientType]
+}
+
+func (cp *ClientProxy) applyRuntimeControl(provider string, control RuntimeControl, now time.Time) error {
+	index := providerIndexByName(cp.providers, provider)
+	if index < 0 {
+		return fmt.Errorf("%w: %s/%s", ErrProviderNotRunning, cp.clientType, provider)
+	}
+	if control.Key > len(cp.providerKeys[index]) {
+		return fmt.Errorf("%w: provider %s has %d keys", ErrInvalidRuntimeControl, provider, len(cp.providerKeys[index]))
+	}
+	reason := strings.TrimSpace(control.Reason)
+	keyIndex := control.Key - 1
+
+	switch control.Action {
+	case RuntimeActionDeactivate:
+		// Operators replace an active deactivation instead of keeping it.
+		deactivation := providerDeactivation{at: now, until: now.Add(control.Duration), reason: manualDeactivationReason, message: reason}
+		if keyIndex >= 0 {
+			cp.deactivateKey(index, keyIndex, deactivation, true)
+		} else {
+			cp.deactivate(index, deactivation, true)
+		}
+	case RuntimeActionReactivate:
+		cp.manualReactivate(index, keyIndex, reason, now)
+	case RuntimeActionResetCircuit:
+		if index >= len(cp.breakers) || cp.breakers[index] == nil || !cp.breakers[index].reset() {
+			return fmt.Errorf("%w: the circuit breaker is disabled", ErrInvalidRuntimeControl)
+		}
+	case RuntimeActionOpenCircuit:
+		if index >= len(cp.breakers) || cp.breakers[index] == nil || !cp.breakers[index].forceOpen(now, control.Duration, reason) {
+			return fmt.Errorf("%w: the circuit breaker is disabled", ErrInvalidRuntimeControl)
+		}
+	case RuntimeActionClearBusy:
+		cp.clearProviderBusyBackoff(index)
+	}
+
+	cp.mu.Lock()
+	if index < len(cp.providerControls) {
+		cp.providerControls[index] = providerControl{action: control.Action, key: control.Key, reason: reason, at: now}
+	}
+	cp.mu.Unlock()
+
+	target := provider
+	if control.Key > 0 {
+		target = fmt.Sprintf("%s key %d", provider, control.Key)
+	}
+	logger.Info("[%s] %s %s manually: %s", cp.clientType, control.Action, target, orManualReason(reason))
+	return nil
+}
+
+// manualReactivate clears the deactivation of one key, or of the provider
+// and all of its keys when keyIndex is negative.
+func (cp *ClientProxy) manualReactivate(providerIndex int, keyIndex int, reason string, now time.Time) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	name := cp.providers[providerIndex].Name
+	if keyIndex >= 0 {
+		cp.keyDeactivated[providerIndex][keyIndex] = providerDeactivation{}
+		cp.publishEvent(Event{At: now, Type: EventProviderReactivated, Provider: name, Key: keyIndex + 1, Reason: manualDeactivationReason, Detail: reason})
+		return
+	}
+	cp.deactivated[providerIndex] = providerDeactivation{}
+	for j := range cp.keyDeactivated[providerIndex] {
+		cp.keyDeactivated[providerIndex][j] = providerDeactivation{}
+	}
+	cp.publishEvent(Event{At: now, Type: EventProviderReactivated, Provider: name, Reason: manualDeactivationReason, Detail: reason})
+}
+
+// clearProviderBusyBackoff forgets the busy backoff but keeps the count of
+// probes in flight, which are released as they finish.
+func (cp *ClientProxy) clearProviderBusyBackoff(index int) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if index < 0 || index >= len(cp.providerBusy) {
+		return
+	}
+	cp.providerBusy[index] = providerBusyState{ProbeInFlight: cp.providerBusy[index].ProbeInFlight}
+}
+
+func orManualReason(reason string) string {
+	if reason == "" {
+		return "no reason given"
+	}
+	return reason
+}
@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+)
+
+func providerSnapshot(t *testing.T, router *Router, name string) ProviderRuntimeSnapshot {
+	t.Helper()
+	for _, p := range router.RuntimeSnapshot().Clients[ClientOpenAI].Providers {
+		if p.Name == name {
+			return p
+		}
+	}
+	t.Fatalf("provider %s missing from runtime snapshot", name)
+	return ProviderRuntimeSnapshot{}
+}
+
+func TestControlProvider_DeactivatesReactivatesAndDrivesCircuit(t *testing.T) {
+	cfg := &config.Config{Global: config.DefaultGlobalConfig()}
+	cfg.Global.CircuitBreaker = config.CircuitBreakerConfig{FailureThreshold: 2, SuccessThreshold: 1, OpenTimeout: "30s", HalfOpenMaxInFlight: 1}
+	cfg.OpenAI = config.ClientConfig{Mode: config.ClientModeAuto, Providers: []config.Provider{
+		{Name: "p1", BaseURL: "https://p1.example", APIKeys: []string{"k1", "k2"}, Priority: 1},
+		{Name: "p2", BaseURL: "https://p2.example", APIKey: "k3", Priority: 2},
+	}}
+	router := NewRouter(cfg)
+	cp := router.proxies[ClientOpenAI]
+
+	if err := router.ControlProvider(ClientOpenAI, "p1", RuntimeControl{Action: RuntimeActionDeactivate, Duration: time.Hour, Reason: "maintenance window"}); err != nil {
+		t.Fatalf("deactivate: %v", err)
+	}
+	view := DescribeProviderAvailability("p1", true, providerSnapshot(t, router, "p1"))
+	if view.State != "unavailable" || !strings.HasPrefix(view.Detail, "Deactivated manually: maintenance window. Retry in ") {
+		t.Fatalf("deactivated view = %#v", view)
+	}
+	if got := cp.providers[cp.currentIndex].Name; got != "p2" {
+		t.Fatalf("current provider = %s, want p2", got)
+	}
+
+	if err := router.ControlProvider(ClientOpenAI, "p1", RuntimeControl{Action: RuntimeActionDeactivate, Key: 2, Duration: time.Hour}); err != nil {
+		t.Fatalf("deactivate key: %v", err)
+	}
+	if err := router.ControlProvider(ClientOpenAI, "p1", RuntimeControl{Action: RuntimeActionReactivate, Reason: "outage over"}); err != nil {
+		t.Fatalf("reactivate: %v", err)
+	}
+	snap := providerSnapshot(t, router, "p1")
+	view = DescribeProviderAvailability("p1", true, snap)
+	if snap.AvailableKeyCount != 2 || view.State != "available" || view.Detail != "Available. Reactivated manually: outage over." {
+		t.Fatalf("reactivated snap=%#v view=%#v", snap, view)
+	}
+
+	if err := router.ControlProvider(ClientOpenAI, "p2", RuntimeControl{Action: RuntimeActionOpenCircuit, Duration: 10 * time.Minute, Reason: "provider announced downtime"}); err != nil {
+		t.Fatalf("open circuit: %v", err)
+	}
+	snap = providerSnapshot(t, router, "p2")
+	view = DescribeProviderAvailability("p2", true, snap)
+	if snap.CircuitState != "open" || snap.CircuitOpenIn <= 9*time.Minute || !strings.HasPrefix(view.Detail, "Circuit breaker was opened manually: provider announced downtime. Retry in ") {
+		t.Fatalf("open circuit snap=%#v view=%#v", snap, view)
+	}
+	if err := router.ControlProvider(ClientOpenAI, "p2", RuntimeControl{Action: RuntimeActionResetCircuit}); err != nil {
+		t.Fatalf("reset circuit: %v", err)
+	}
+	if snap = providerSnapshot(t, router, "p2"); snap.CircuitState != "closed" || snap.CircuitManual {
+		t.Fatalf("reset circuit snap=%#v", snap)
+	}
+	if view = DescribeProviderAvailability("p2", true, snap); view.Detail != "Available. Circuit breaker reset manually." {
+		t.Fatalf("reset circuit view = %#v", view)
+	}
+	// The breaker opening on its own supersedes the manual note.
+	for i := 0; i < 2; i++ {
+		cp.recordCircuitFailure(time.Now(), 1, false, "network")
+	}
+	if snap = providerSnapshot(t, router, "p2"); snap.CircuitState != "open" || snap.LastControl != nil {
+		t.Fatalf("circuit opened after reset snap=%#v", snap)
+	}
+	if err := router.ControlProvider(ClientOpenAI, "p2", RuntimeControl{Action: RuntimeActionResetCircuit}); err != nil {
+		t.Fatalf("reset circuit: %v", err)
+	}
+
+	// A manual deactivation replaces an active one; an automatic one does not.
+	for _, reason := range []string{"first", "second"} {
+		if err := router.ControlProvider(ClientOpenAI, "p1", RuntimeControl{Action: RuntimeActionDeactivate, Duration: time.Hour, Reason: reason}); err != nil {
+			t.Fatalf("deactivate: %v", err)
+		}
+	}
+	cp.deactivateFor(0, "auth", 401, "bad key", time.Minute)
+	if snap = providerSnapshot(t, router, "p1"); snap.DeactivatedMessage != "second" || snap.DeactivatedReason != manualDeactivationReason {
+		t.Fatalf("repeated deactivation snap=%#v", snap)
+	}
+	if err := router.ControlProvider(ClientOpenAI, "p1", RuntimeControl{Action: RuntimeActionReactivate}); err != nil {
+		t.Fatalf("reactivate: %v", err)
+	}
+
+	cp.markProviderBusy(1, "busy", 2, time.Now(), time.Minute)
+	if err := router.ControlProvider(ClientOpenAI, "p2", RuntimeControl{Action: RuntimeActionClearBusy}); err != nil {
+		t.Fatalf("clear busy: %v", err)
+	}
+	if busy := cp.providerBusySnapshot(1); !busy.Until.IsZero() || busy.BackoffStep != 0 {
+		t.Fatalf("busy after clear = %#v", busy)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		provider string
+		control  RuntimeControl
+		want     error
+	}{
+		{"UnknownProvider", "missing", RuntimeControl{Action: RuntimeActionReactivate}, ErrProviderNotRunning},
+		{"NoDuration", "p1", RuntimeControl{Action: RuntimeActionDeactivate}, ErrInvalidRuntimeControl},
+		{"KeyOutOfRange", "p1", RuntimeControl{Action: RuntimeActionDeactivate, Key: 3, Duration: time.Minute}, ErrInvalidRuntimeControl},
+		{"KeyForCircuit", "p1", RuntimeControl{Action: RuntimeActionResetCircuit, Key: 1}, ErrInvalidRuntimeControl},
+		{"UnknownAction", "p1", RuntimeControl{Action: "pause"}, ErrInvalidRuntimeControl},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := router.ControlProvider(ClientOpenAI, tt.provider, tt.control); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
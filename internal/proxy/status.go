@@ -18,6 +18,20 @@ type ProviderRuntimeSnapshot struct {
 
 	CircuitState  string
 	CircuitOpenIn time.Duration
+	// CircuitManual marks a circuit opened manually, for CircuitReason.
+	CircuitManual bool
+	CircuitReason string
+
+	// LastControl is the last manual runtime action, or nil.
+	LastControl *RuntimeControlEvent
+}
+
+// RuntimeControlEvent records a manual runtime action on a provider.
+type RuntimeControlEvent struct {
+	At     time.Time
+	Action RuntimeAction
+	Key    int
+	Reason string
 }
 
 type ClientRuntimeSnapshot struct {
@@ -87,12 +101,18 @@ func (cp *ClientProxy) runtimeSnapshot(now time.Time) ClientRuntimeSnapshot {
 			}
 		}
 		if i < len(cp.breakers) && cp.breakers[i] != nil {
-			st, wait := cp.breakers[i].snapshot(now)
-			ps.CircuitState = string(st)
-			ps.CircuitOpenIn = wait
+			cs := cp.breakers[i].snapshot(now)
+			ps.CircuitState = string(cs.state)
+			ps.CircuitOpenIn = cs.openWait
+			ps.CircuitManual = cs.manual
+			ps.CircuitReason = cs.reason
 		} else {
 			ps.CircuitState = string(circuitClosed)
 		}
+		if i < len(cp.providerControls) && !cp.providerControls[i].at.IsZero() {
+			c := cp.providerControls[i]
+			ps.LastControl = &RuntimeControlEvent{At: c.at, Action: c.action, Key: c.key, Reason: c.reason}
+		}
 		providers = append(providers, ps)
 	}
 
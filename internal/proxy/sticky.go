@@ -41,6 +41,9 @@ type stickyLookupEntry struct {
 	KeyIndex      int
 	LastSeenAt    time.Time
 	Source        string
+	// Preview is the start of the human message a dynamic feature was learned
+	// from, for the sticky inspector.
+	Preview string
 }
 
 func stickyScopeKey(scope routingScope, key string) string {
@@ -244,31 +247,45 @@ func stringField(root map[string]any, key string) string {
 	return str
 }
 
-func (cp *ClientProxy) resolveStickyProvider(scope routingScope, key stickyKey, now time.Time) (int, int, bool) {
+// resolveStickyProvider returns the provider and key bound to key. It prunes
+// expired bindings and refreshes the one it finds unless peek is set, as it
+// is for the routing explain endpoint.
+func (cp *ClientProxy) resolveStickyProvider(scope routingScope, key stickyKey, now time.Time, peek bool) (int, int, bool) {
 	if cp == nil || strings.TrimSpace(key.Key) == "" {
 		return 0, 0, false
 	}
 
-	cp.mu.Lock()
-	defer cp.mu.Unlock()
-	cp.pruneStickyStateLocked(now)
+	if peek {
+		cp.mu.RLock()
+		defer cp.mu.RUnlock()
+	} else {
+		cp.mu.Lock()
+		defer cp.mu.Unlock()
+		cp.pruneStickyStateLocked(now)
+	}
 
 	switch key.Level {
 	case stickyKeyLevelL1:
-		if entry, ok := cp.stickyBindings[stickyScopeKey(scope, key.Key)]; ok {
-			entry.LastSeenAt = now
-			cp.stickyBindings[stickyScopeKey(scope, key.Key)] = entry
+		if entry, ok := cp.stickyBindings[stickyScopeKey(scope, key.Key)]; ok && !stickyExpired(entry.LastSeenAt, cp.routing.explicitTTL, now) {
+			if !peek {
+				entry.LastSeenAt = now
+				cp.stickyBindings[stickyScopeKey(scope, key.Key)] = entry
+			}
 			return entry.ProviderIndex, entry.KeyIndex, true
 		}
-		if entry, ok := cp.responseLookup[key.Key]; ok {
-			entry.LastSeenAt = now
-			cp.responseLookup[key.Key] = entry
+		if entry, ok := cp.responseLookup[key.Key]; ok && !stickyExpired(entry.LastSeenAt, cp.routing.responseLookupTTL, now) {
+			if !peek {
+				entry.LastSeenAt = now
+				cp.responseLookup[key.Key] = entry
+			}
 			return entry.ProviderIndex, entry.KeyIndex, true
 		}
 	case stickyKeyLevelL2, stickyKeyLevelL3:
-		if entry, ok := cp.dynamicFeatureBindings[stickyScopeKey(scope, key.Key)]; ok {
-			entry.LastSeenAt = now
-			cp.dynamicFeatureBindings[stickyScopeKey(scope, key.Key)] = entry
+		if entry, ok := cp.dynamicFeatureBindings[stickyScopeKey(scope, key.Key)]; ok && !stickyExpired(entry.LastSeenAt, cp.dynamicFeatureTTLLocked(entry), now) {
+			if !peek {
+				entry.LastSeenAt = now
+				cp.dynamicFeatureBindings[stickyScopeKey(scope, key.Key)] = entry
+			}
 			return entry.ProviderIndex, entry.KeyIndex, true
 		}
 	}
@@ -276,6 +293,18 @@ func (cp *ClientProxy) resolveStickyProvider(scope routingScope, key stickyKey,
 	return 0, 0, false
 }
 
+// stickyStart resolves the binding of key for a request of capability.
+// applied reports whether routing starts at the bound provider; it does not
+// when that provider cannot take the request now.
+func (cp *ClientProxy) stickyStart(scope routingScope, capability RequestCapability, key stickyKey, now time.Time, peek bool) (index int, keyIndex int, found bool, applied bool) {
+	index, keyIndex, found = cp.resolveStickyProvider(scope, key, now, peek)
+	if !found {
+		return 0, 0, false, false
+	}
+	applied = cp.candidateSkip(index, capability, now) == ""
+	return index, keyIndex, true, applied
+}
+
 func (cp *ClientProxy) learnStickySuccessWithPayload(scope routingScope, requestCtx RequestContext, requestKey stickyKey, payload *requestPayload, responseBody []byte, providerIndex int, keyIndex int, now time.Time) {
 	if cp == nil || providerIndex < 0 {
 		return
@@ -301,6 +330,7 @@ func (cp *ClientProxy) learnStickySuccessWithPayload(scope routingScope, request
 			KeyIndex:      keyIndex,
 			LastSeenAt:    now,
 			Source:        requestKey.Source,
+			Preview:       requestKey.Preview,
 		}
 		cp.enforceDynamicFeatureCapacityLocked()
 	}
@@ -314,6 +344,7 @@ func (cp *ClientProxy) learnStickySuccessWithPayload(scope routingScope, request
 			KeyIndex:      keyIndex,
 			LastSeenAt:    now,
 			Source:        learned.Source,
+			Preview:       learned.Preview,
 		}
 		cp.enforceDynamicFeatureCapacityLocked()
 	}
@@ -338,27 +369,38 @@ func extractResponseLookupID(requestCtx RequestContext, responseBody []byte) str
 
 func (cp *ClientProxy) pruneStickyStateLocked(now time.Time) {
 	for key, entry := range cp.stickyBindings {
-		if cp.routing.explicitTTL > 0 && now.Sub(entry.LastSeenAt) > cp.routing.explicitTTL {
+		if stickyExpired(entry.LastSeenAt, cp.routing.explicitTTL, now) {
 			delete(cp.stickyBindings, key)
 		}
 	}
 	for key, entry := range cp.responseLookup {
-		if cp.routing.responseLookupTTL > 0 && now.Sub(entry.LastSeenAt) > cp.routing.responseLookupTTL {
+		if stickyExpired(entry.LastSeenAt, cp.routing.responseLookupTTL, now) {
 			delete(cp.responseLookup, key)
 		}
 	}
 	for key, entry := range cp.dynamicFeatureBindings {
-		ttl := cp.routing.dynamicFeatureTTL
-		if entry.Source == "prompt_cache_key" {
-			ttl = cp.routing.cacheHintTTL
-		}
-		if ttl > 0 && now.Sub(entry.LastSeenAt) > ttl {
+		if stickyExpired(entry.LastSeenAt, cp.dynamicFeatureTTLLocked(entry), now) {
 			delete(cp.dynamicFeatureBindings, key)
 		}
 	}
 	cp.enforceDynamicFeatureCapacityLocked()
 }
 
+// stickyExpired reports whether a binding last seen at lastSeen has outlived
+// ttl. A non-positive ttl never expires.
+func stickyExpired(lastSeen time.Time, ttl time.Duration, now time.Time) bool {
+	return ttl > 0 && now.Sub(lastSeen) > ttl
+}
+
+// dynamicFeatureTTLLocked is the TTL of a dynamic feature binding; bindings
+// learned from prompt_cache_key use the cache hint TTL.
+func (cp *ClientProxy) dynamicFeatureTTLLocked(entry stickyLookupEntry) time.Duration {
+	if entry.Source == "prompt_cache_key" {
+		return cp.routing.cacheHintTTL
+	}
+	return cp.routing.dynamicFeatureTTL
+}
+
 func (cp *ClientProxy) enforceDynamicFeatureCapacityLocked() {
 	if cp == nil || cp.routing.dynamicFeatureCapacity <= 0 {
 		return
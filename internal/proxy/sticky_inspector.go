@@ -0,0 +1,280 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StickyKind names the table a sticky binding lives in.
+type StickyKind string
+
+const (
+	// StickyKindBinding is an explicit session binding, such as a
+	// previous_response_id chain.
+	StickyKindBinding StickyKind = "binding"
+	// StickyKindResponseLookup maps a response ID to the provider that
+	// produced it.
+	StickyKindResponseLookup StickyKind = "response_lookup"
+	// StickyKindDynamicFeature is a prompt_cache_key or human-message feature
+	// learned from earlier requests.
+	StickyKindDynamicFeature StickyKind = "dynamic_feature"
+)
+
+var (
+	// ErrStickyBindingNotFound reports a sticky binding ID that does not exist,
+	// or that expired.
+	ErrStickyBindingNotFound = errors.New("sticky binding not found")
+	// ErrInvalidStickyScope reports an unknown routing scope filter.
+	ErrInvalidStickyScope = errors.New("invalid sticky scope")
+)
+
+// StickyBindingSnapshot describes one sticky binding for the inspector.
+type StickyBindingSnapshot struct {
+	// ID identifies the binding in purge and re-pin calls. It is derived from
+	// the binding key, which is not exposed.
+	ID    string
+	Kind  StickyKind
+	Scope string
+	Level string
+	// Source is what the key came from, such as previous_response_id,
+	// prompt_cache_key or dynamic_human_feature.
+	Source   string
+	Provider string
+	// Key is the 1-based key index of the provider.
+	Key        int
+	BoundAt    time.Time
+	LastSeenAt time.Time
+	// ExpiresAt is zero when the binding does not expire.
+	ExpiresAt time.Time
+	Preview   string
+}
+
+// StickyBindings lists the sticky bindings of a client, most recently used
+// first. A non-empty scope keeps only bindings of that routing scope.
+func (r *Router) StickyBindings(clientType ClientType, scope string) ([]StickyBindingSnapshot, error) {
+	if err := validateStickyScope(scope); err != nil {
+		return nil, err
+	}
+	cp := r.clientProxy(clientType)
+	if cp == nil {
+		return nil, nil
+	}
+	now := time.Now()
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.pruneStickyStateLocked(now)
+
+	var out []StickyBindingSnapshot
+	cp.eachStickyEntryLocked(func(e stickyEntryRef) {
+		if scope == "" || string(e.scope) == scope {
+			out = append(out, cp.stickySnapshotLocked(e))
+		}
+	})
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].LastSeenAt.Equal(out[j].LastSeenAt) {
+			return out[i].ID < out[j].ID
+		}
+		return out[i].LastSeenAt.After(out[j].LastSeenAt)
+	})
+	return out, nil
+}
+
+// PurgeStickyBindings removes the binding with id, or every binding of scope
+// (all scopes when empty) when id is empty. It returns how many were removed.
+func (r *Router) PurgeStickyBindings(clientType ClientType, scope string, id string) (int, error) {
+	if err := validateStickyScope(scope); err != nil {
+		return 0, err
+	}
+	cp := r.clientProxy(clientType)
+	if cp == nil {
+		if id != "" {
+			return 0, ErrStickyBindingNotFound
+		}
+		return 0, nil
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	removed := 0
+	cp.eachStickyEntryLocked(func(e stickyEntryRef) {
+		if (id != "" && e.id() != id) || (scope != "" && string(e.scope) != scope) {
+			return
+		}
+		switch e.kind {
+		case StickyKindBinding:
+			delete(cp.stickyBindings, e.mapKey)
+		case StickyKindResponseLookup:
+			delete(cp.responseLookup, e.mapKey)
+		case StickyKindDynamicFeature:
+			delete(cp.dynamicFeatureBindings, e.mapKey)
+		}
+		removed++
+	})
+	if id != "" && removed == 0 {
+		return 0, ErrStickyBindingNotFound
+	}
+	return removed, nil
+}
+
+// RepinStickyBinding moves a binding to provider and refreshes its TTL. key
+// is the 1-based key index; 0 keeps the current key when the provider does
+// not change and uses the first key otherwise.
+func (r *Router) RepinStickyBinding(clientType ClientType, id string, provider string, key int) (StickyBindingSnapshot, error) {
+	cp := r.clientProxy(clientType)
+	if cp == nil {
+		return StickyBindingSnapshot{}, ErrStickyBindingNotFound
+	}
+	providerIndex := providerIndexByName(cp.providers, provider)
+	if providerIndex < 0 {
+		return StickyBindingSnapshot{}, fmt.Errorf("%w: %s/%s", ErrProviderNotRunning, clientType, provider)
+	}
+	if key < 0 || key > len(cp.providerKeys[providerIndex]) {
+		return StickyBindingSnapshot{}, fmt.Errorf("%w: provider %s has %d keys", ErrInvalidRuntimeControl, provider, len(cp.providerKeys[providerIndex]))
+	}
+
+	now := time.Now()
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.pruneStickyStateLocked(now)
+
+	var (
+		found bool
+		snap  StickyBindingSnapshot
+	)
+	cp.eachStickyEntryLocked(func(e stickyEntryRef) {
+		if found || e.id() != id {
+			return
+		}
+		found = true
+		keyIndex := key - 1
+		if keyIndex < 0 {
+			keyIndex = 0
+			if e.providerIndex == providerIndex {
+				keyIndex = e.keyIndex
+			}
+		}
+		switch e.kind {
+		case StickyKindBinding:
+			b := cp.stickyBindings[e.mapKey]
+			b.ProviderIndex, b.KeyIndex, b.BoundAt, b.LastSeenAt = providerIndex, keyIndex, now, now
+			cp.stickyBindings[e.mapKey] = b
+		case StickyKindResponseLookup:
+			entry := cp.responseLookup[e.mapKey]
+			entry.ProviderIndex, entry.KeyIndex, entry.LastSeenAt = providerIndex, keyIndex, now
+			cp.responseLookup[e.mapKey] = entry
+		case StickyKindDynamicFeature:
+			entry := cp.dynamicFeatureBindings[e.mapKey]
+			entry.ProviderIndex, entry.KeyIndex, entry.LastSeenAt = providerIndex, keyIndex, now
+			cp.dynamicFeatureBindings[e.mapKey] = entry
+		}
+		e.providerIndex, e.keyIndex, e.lastSeenAt = providerIndex, keyIndex, now
+		if e.kind == StickyKindBinding {
+			e.boundAt = now
+		}
+		snap = cp.stickySnapshotLocked(e)
+	})
+	if !found {
+		return StickyBindingSnapshot{}, ErrStickyBindingNotFound
+	}
+	return snap, nil
+}
+
+func validateStickyScope(scope string) error {
+	switch routingScope(scope) {
+	case "", routingScopeDefault, routingScopeClaudeCountTokens, routingScopeOpenAIResponses, routingScopeGeminiStream:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidStickyScope, scope)
+	}
+}
+
+// stickyEntryRef is one entry of the three sticky tables in a common shape.
+type stickyEntryRef struct {
+	kind          StickyKind
+	mapKey        string
+	scope         routingScope
+	key           string
+	level         stickyKeyLevel
+	source        string
+	providerIndex int
+	keyIndex      int
+	boundAt       time.Time
+	lastSeenAt    time.Time
+	preview       string
+}
+
+func (e stickyEntryRef) id() string {
+	sum := sha256.Sum256([]byte(string(e.kind) + "\x00" + e.mapKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+// eachStickyEntryLocked calls fn for every sticky entry. fn may delete the
+// entry it is given.
+func (cp *ClientProxy) eachStickyEntryLocked(fn func(stickyEntryRef)) {
+	for mapKey, b := range cp.stickyBindings {
+		scope, key := splitStickyScopeKey(mapKey)
+		fn(stickyEntryRef{kind: StickyKindBinding, mapKey: mapKey, scope: scope, key: key, level: b.Level, source: b.Source,
+			providerIndex: b.ProviderIndex, keyIndex: b.KeyIndex, boundAt: b.BoundAt, lastSeenAt: b.LastSeenAt})
+	}
+	for mapKey, entry := range cp.responseLookup {
+		// Response lookups are keyed by response ID alone; only the Responses
+		// API produces them.
+		fn(stickyEntryRef{kind: StickyKindResponseLookup, mapKey: mapKey, scope: routingScopeOpenAIResponses, key: mapKey, level: stickyKeyLevelL1, source: entry.Source,
+			providerIndex: entry.ProviderIndex, keyIndex: entry.KeyIndex, lastSeenAt: entry.LastSeenAt})
+	}
+	for mapKey, entry := range cp.dynamicFeatureBindings {
+		scope, key := splitStickyScopeKey(mapKey)
+		level := stickyKeyLevelL3
+		if entry.Source == "prompt_cache_key" {
+			level = stickyKeyLevelL2
+		}
+		fn(stickyEntryRef{kind: StickyKindDynamicFeature, mapKey: mapKey, scope: scope, key: key, level: level, source: entry.Source,
+			providerIndex: entry.ProviderIndex, keyIndex: entry.KeyIndex, lastSeenAt: entry.LastSeenAt, preview: entry.Preview})
+	}
+}
+
+func (cp *ClientProxy) stickySnapshotLocked(e stickyEntryRef) StickyBindingSnapshot {
+	var ttl time.Duration
+	switch {
+	case e.kind == StickyKindBinding:
+		ttl = cp.routing.explicitTTL
+	case e.kind == StickyKindResponseLookup:
+		ttl = cp.routing.responseLookupTTL
+	default:
+		ttl = cp.dynamicFeatureTTLLocked(stickyLookupEntry{Source: e.source})
+	}
+	preview := e.preview
+	if preview == "" && e.level != stickyKeyLevelL3 {
+		// L1 and L2 keys are client-chosen identifiers, not hashes.
+		preview = e.key
+		if len(preview) > stickyPreviewLimit {
+			preview = preview[:stickyPreviewLimit]
+		}
+	}
+	snap := StickyBindingSnapshot{
+		ID:         e.id(),
+		Kind:       e.kind,
+		Scope:      string(e.scope),
+		Level:      string(e.level),
+		Source:     e.source,
+		Provider:   providerNameAtIndex(cp.providers, e.providerIndex),
+		Key:        e.keyIndex + 1,
+		BoundAt:    e.boundAt,
+		LastSeenAt: e.lastSeenAt,
+		Preview:    preview,
+	}
+	if ttl > 0 {
+		snap.ExpiresAt = e.lastSeenAt.Add(ttl)
+	}
+	return snap
+}
+
+func splitStickyScopeKey(mapKey string) (routingScope, string) {
+	scope, key, _ := strings.Cut(mapKey, "\x00")
+	return routingScope(scope), key
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+)
+
+func TestStickyInspector_ListsRepinsAndPurgesBindings(t *testing.T) {
+	cfg := &config.Config{Global: config.DefaultGlobalConfig()}
+	cfg.OpenAI = config.ClientConfig{Mode: config.ClientModeAuto, Providers: []config.Provider{
+		{Name: "p1", BaseURL: "https://p1.example", APIKey: "k1", Priority: 1},
+		{Name: "p2", BaseURL: "https://p2.example", APIKeys: []string{"k2", "k3"}, Priority: 2},
+	}}
+	router := NewRouter(cfg)
+	cp := router.proxies[ClientOpenAI]
+
+	now := time.Now()
+	cp.mu.Lock()
+	cp.stickyBindings[stickyScopeKey(routingScopeOpenAIResponses, "resp_previous_0123456789abcdef")] = stickyBinding{
+		ProviderIndex: 0, BoundAt: now.Add(-2 * time.Minute), LastSeenAt: now.Add(-time.Minute), Level: stickyKeyLevelL1, Source: "previous_response_id",
+	}
+	cp.responseLookup["resp_next"] = stickyLookupEntry{ProviderIndex: 0, LastSeenAt: now.Add(-3 * time.Minute), Source: "response_id"}
+	cp.dynamicFeatureBindings[stickyScopeKey(routingScopeDefault, "feature-hash")] = stickyLookupEntry{
+		ProviderIndex: 1, KeyIndex: 1, LastSeenAt: now, Source: "dynamic_human_feature", Preview: "refactor the parser",
+	}
+	cp.mu.Unlock()
+
+	all, err := router.StickyBindings(ClientOpenAI, "")
+	if err != nil || len(all) != 3 {
+		t.Fatalf("bindings = %#v err = %v", all, err)
+	}
+	if all[0].Kind != StickyKindDynamicFeature || all[0].Level != "L3" || all[0].Provider != "p2" || all[0].Key != 2 || all[0].Preview != "refactor the parser" {
+		t.Fatalf("feature = %#v", all[0])
+	}
+	session := all[1]
+	if session.Kind != StickyKindBinding || session.Scope != "openai_responses" || session.Preview != "resp_previous_0123456789" ||
+		time.Until(session.ExpiresAt) < 28*time.Minute || time.Until(session.ExpiresAt) > 29*time.Minute {
+		t.Fatalf("session = %#v", session)
+	}
+
+	scoped, err := router.StickyBindings(ClientOpenAI, "openai_responses")
+	if err != nil || len(scoped) != 2 {
+		t.Fatalf("scoped = %#v err = %v", scoped, err)
+	}
+	if _, err := router.StickyBindings(ClientOpenAI, "bogus"); !errors.Is(err, ErrInvalidStickyScope) {
+		t.Fatalf("bogus scope err = %v", err)
+	}
+
+	repinned, err := router.RepinStickyBinding(ClientOpenAI, session.ID, "p2", 2)
+	if err != nil || repinned.ID != session.ID || repinned.Provider != "p2" || repinned.Key != 2 || !repinned.LastSeenAt.After(session.LastSeenAt) {
+		t.Fatalf("repinned = %#v err = %v", repinned, err)
+	}
+	if idx, keyIdx, ok := cp.resolveStickyProvider(routingScopeOpenAIResponses, stickyKey{Level: stickyKeyLevelL1, Key: "resp_previous_0123456789abcdef"}, time.Now(), false); !ok || idx != 1 || keyIdx != 1 {
+		t.Fatalf("resolved = %d/%d/%v", idx, keyIdx, ok)
+	}
+	if _, err := router.RepinStickyBinding(ClientOpenAI, session.ID, "missing", 0); !errors.Is(err, ErrProviderNotRunning) {
+		t.Fatalf("repin missing provider err = %v", err)
+	}
+
+	if n, err := router.PurgeStickyBindings(ClientOpenAI, "", session.ID); err != nil || n != 1 {
+		t.Fatalf("purge one = %d err = %v", n, err)
+	}
+	if _, err := router.PurgeStickyBindings(ClientOpenAI, "", session.ID); !errors.Is(err, ErrStickyBindingNotFound) {
+		t.Fatalf("purge again err = %v", err)
+	}
+	if n, err := router.PurgeStickyBindings(ClientOpenAI, "", ""); err != nil || n != 2 {
+		t.Fatalf("purge all = %d err = %v", n, err)
+	}
+	if snap := router.RuntimeSnapshot().Clients[ClientOpenAI]; snap.StickyBindingCount+snap.ResponseLookupCount+snap.DynamicFeatureCacheCount != 0 {
+		t.Fatalf("counts after purge = %#v", snap)
+	}
+}
@@ -0,0 +1,226 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/lansespirit/Clipal/internal/telemetry"
+)
+
+// streamUsageOptions is spliced into OpenAI chat and completions stream
+// requests that do not ask for usage themselves.
+const streamUsageOptions = `{"include_usage":true}`
+
+// withStreamUsageOption asks an OpenAI chat or completions stream to report
+// usage when the client did not, so the request can still be metered. The
+// usage-only chunk the upstream then appends is removed by streamUsageFilter
+// before it reaches the client. The option is spliced into the client's bytes
+// rather than re-encoded, so the rest of the body is forwarded byte for byte.
+func withStreamUsageOption(req *http.Request, requestCtx RequestContext, body []byte) ([]byte, bool) {
+	switch requestCtx.Capability {
+	case CapabilityOpenAIChatCompletions, CapabilityOpenAICompletions:
+	default:
+		return body, false
+	}
+	if len(body) == 0 || !isJSONRequest(req) {
+		return body, false
+	}
+	var root map[string]any
+	if err := json.Unmarshal(body, &root); err != nil || root["stream"] != true {
+		return body, false
+	}
+	rawOptions, exists := root["stream_options"]
+	options, isObject := rawOptions.(map[string]any)
+	if options["include_usage"] == true {
+		return body, false
+	}
+
+	var rewritten []byte
+	switch {
+	case !exists:
+		start := bytes.IndexByte(body, '{')
+		rewritten = spliceJSON(body, start+1, start+1, `"stream_options":`+streamUsageOptions+",")
+	default:
+		start, end, ok := jsonMemberValueSpan(body, "stream_options")
+		if !ok {
+			return body, false
+		}
+		value := body[start:end]
+		if !isObject {
+			rewritten = spliceJSON(body, start, end, streamUsageOptions)
+			break
+		}
+		if _, has := options["include_usage"]; has {
+			usageStart, usageEnd, ok := jsonMemberValueSpan(value, "include_usage")
+			if !ok {
+				return body, false
+			}
+			rewritten = spliceJSON(body, start+usageStart, start+usageEnd, "true")
+			break
+		}
+		insert := `"include_usage":true`
+		if len(options) > 0 {
+			insert += ","
+		}
+		open := start + bytes.IndexByte(value, '{') + 1
+		rewritten = spliceJSON(body, open, open, insert)
+	}
+	requestTraceFromRequest(req).noteStreamUsageInjected()
+	return rewritten, true
+}
+
+// spliceJSON returns body with body[start:end] replaced by text.
+func spliceJSON(body []byte, start int, end int, text string) []byte {
+	out := make([]byte, 0, len(body)-(end-start)+len(text))
+	out = append(out, body[:start]...)
+	out = append(out, text...)
+	return append(out, body[end:]...)
+}
+
+// jsonMemberValueSpan returns the byte range of the value of the last key
+// member of the JSON object obj.
+func jsonMemberValueSpan(obj []byte, key string) (int, int, bool) {
+	dec := json.NewDecoder(bytes.NewReader(obj))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return 0, 0, false
+	}
+	start, end, found := 0, 0, false
+	for dec.More() {
+		name, err := dec.Token()
+		if err != nil {
+			return 0, 0, false
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return 0, 0, false
+		}
+		if name != key {
+			continue
+		}
+		end = int(dec.InputOffset())
+		start = end - len(value)
+		if start < 0 || !bytes.Equal(obj[start:end], value) {
+			return 0, 0, false
+		}
+		found = true
+	}
+	return start, end, found
+}
+
+// streamUsageRejected reports whether resp is a 400 that blames the
+// stream_options Clipal added. Some OpenAI-compatible upstreams reject the
+// field outright. resp.Body stays readable from the start either way.
+func streamUsageRejected(resp *http.Response) bool {
+	if resp == nil || resp.Body == nil || resp.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, failureRuleBodyLimit))
+	resp.Body = &replayBody{prefix: bytes.NewReader(raw), err: err, body: resp.Body}
+	return bytes.Contains(decodeFailureRuleBody(resp.Header, raw), []byte("stream_options"))
+}
+
+// streamUsageFilter relays an SSE stream while dropping the usage-only chunk
+// an upstream sends because Clipal added include_usage. Partial events are
+// held until they are complete.
+type streamUsageFilter struct {
+	w       io.Writer
+	pending []byte
+}
+
+func newStreamUsageFilter(w io.Writer) *streamUsageFilter {
+	return &streamUsageFilter{w: w}
+}
+
+func (f *streamUsageFilter) Write(p []byte) (int, error) {
+	f.pending = append(f.pending, p...)
+	var out []byte
+	for {
+		end := sseEventEnd(f.pending)
+		if end < 0 {
+			break
+		}
+		event := f.pending[:end]
+		if !isStreamUsageOnlyEvent(event) {
+			out = append(out, event...)
+		}
+		f.pending = f.pending[end:]
+	}
+	if len(out) > 0 {
+		if _, err := f.w.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// flush writes out a trailing event that was never terminated.
+func (f *streamUsageFilter) flush() {
+	if f == nil || len(f.pending) == 0 {
+		return
+	}
+	_, _ = f.w.Write(f.pending)
+	f.pending = nil
+}
+
+// sseEventEnd returns the length of the first complete event in buf,
+// including its terminating blank line, or -1.
+func sseEventEnd(buf []byte) int {
+	offset := 0
+	for {
+		idx := bytes.IndexByte(buf[offset:], '\n')
+		if idx < 0 {
+			return -1
+		}
+		lineEnd := offset + idx + 1
+		line := bytes.TrimSuffix(buf[offset:lineEnd-1], []byte{'\r'})
+		if len(line) == 0 {
+			return lineEnd
+		}
+		offset = lineEnd
+	}
+}
+
+func isStreamUsageOnlyEvent(event []byte) bool {
+	var data []string
+	for _, line := range strings.Split(string(event), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = append(data, strings.TrimPrefix(value, " "))
+		}
+	}
+	var chunk struct {
+		Choices *[]json.RawMessage `json:"choices"`
+		Usage   json.RawMessage    `json:"usage"`
+	}
+	if len(data) == 0 || json.Unmarshal([]byte(strings.Join(data, "\n")), &chunk) != nil {
+		return false
+	}
+	return chunk.Choices != nil && len(*chunk.Choices) == 0 && len(chunk.Usage) > 0 && string(chunk.Usage) != "null"
+}
+
+// estimatedStreamUsage approximates usage for a completed OpenAI stream whose
+// upstream reported none, from the prompt and the relayed output. The result
+// is flagged as estimated.
+func estimatedStreamUsage(payload *requestPayload, success streamSuccess) telemetry.UsageSnapshot {
+	if !success.estimable {
+		return success.usage
+	}
+	input := int64(countOpenAITokens(payload.jsonRoot()))
+	output := success.outputEstimate
+	return telemetry.UsageSnapshot{
+		UsageDelta: telemetry.UsageDelta{
+			InputTokens:  input,
+			OutputTokens: output,
+			TotalTokens:  input + output,
+		},
+		Usage: map[string]any{
+			"prompt_tokens":     float64(input),
+			"completion_tokens": float64(output),
+			"total_tokens":      float64(input + output),
+		},
+		Estimated: true,
+	}
+}
@@ -0,0 +1,235 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/telemetry"
+)
+
+func TestWithStreamUsageOption(t *testing.T) {
+	t.Parallel()
+
+	chat := RequestContext{Family: ProtocolFamilyOpenAI, Capability: CapabilityOpenAIChatCompletions}
+	tests := []struct {
+		name       string
+		requestCtx RequestContext
+		body       string
+		want       string
+		injected   bool
+	}{
+		{
+			name:       "splices missing option",
+			requestCtx: chat,
+			body:       ` {"model":"gpt-4o","stream":true}`,
+			want:       ` {"stream_options":{"include_usage":true},"model":"gpt-4o","stream":true}`,
+			injected:   true,
+		},
+		{
+			name:       "enables disabled option",
+			requestCtx: chat,
+			body:       `{"model":"gpt-4o","stream":true,"stream_options":{"include_usage": false ,"x":[1]}}`,
+			want:       `{"model":"gpt-4o","stream":true,"stream_options":{"include_usage": true ,"x":[1]}}`,
+			injected:   true,
+		},
+		{
+			name:       "patches only the options object",
+			requestCtx: chat,
+			body:       `{"stream":true, "seed":12345678901234567890,"stream_options": {"continuous_usage_stats":true},"z":1,"a":2}`,
+			want:       `{"stream":true, "seed":12345678901234567890,"stream_options": {"include_usage":true,"continuous_usage_stats":true},"z":1,"a":2}`,
+			injected:   true,
+		},
+		{
+			name:       "fills empty options",
+			requestCtx: chat,
+			body:       `{"stream":true,"stream_options":{ },"seed":1e3}`,
+			want:       `{"stream":true,"stream_options":{"include_usage":true },"seed":1e3}`,
+			injected:   true,
+		},
+		{
+			name:       "replaces null options",
+			requestCtx: chat,
+			body:       `{"stream":true,"stream_options":null,"seed":1e3}`,
+			want:       `{"stream":true,"stream_options":{"include_usage":true},"seed":1e3}`,
+			injected:   true,
+		},
+		{
+			name:       "keeps client option",
+			requestCtx: chat,
+			body:       `{"model":"gpt-4o","stream":true,"stream_options":{"include_usage":true}}`,
+			want:       `{"model":"gpt-4o","stream":true,"stream_options":{"include_usage":true}}`,
+		},
+		{
+			name:       "ignores non-streaming requests",
+			requestCtx: chat,
+			body:       `{"model":"gpt-4o"}`,
+			want:       `{"model":"gpt-4o"}`,
+		},
+		{
+			name:       "ignores responses api",
+			requestCtx: RequestContext{Family: ProtocolFamilyOpenAI, Capability: CapabilityOpenAIResponses},
+			body:       `{"model":"gpt-4o","stream":true}`,
+			want:       `{"model":"gpt-4o","stream":true}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withRequestTrace(httptest.NewRequest(http.MethodPost, "http://proxy/v1/chat/completions", nil))
+			req.Header.Set("Content-Type", "application/json")
+			got, injected := withStreamUsageOption(req, tt.requestCtx, []byte(tt.body))
+			if string(got) != tt.want {
+				t.Fatalf("body = %s, want %s", got, tt.want)
+			}
+			if injected != tt.injected || requestTraceFromRequest(req).streamUsageInjected() != tt.injected {
+				t.Fatalf("injected = %v, want %v", injected, tt.injected)
+			}
+		})
+	}
+}
+
+func TestStreamUsageFilterDropsUsageOnlyChunk(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	filter := newStreamUsageFilter(&out)
+	stream := "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}],\"usage\":null}\n\n" +
+		"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":1}}\r\n\r\n" +
+		"data: [DONE]\n\n"
+	// Split writes mid-event to exercise buffering.
+	for _, part := range []string{stream[:20], stream[20:90], stream[90:]} {
+		if _, err := filter.Write([]byte(part)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	filter.flush()
+
+	want := "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}],\"usage\":null}\n\ndata: [DONE]\n\n"
+	if out.String() != want {
+		t.Fatalf("filtered stream = %q", out.String())
+	}
+}
+
+func TestForwardWithFailover_ChatStreamInjectsIncludeUsage(t *testing.T) {
+	t.Parallel()
+
+	ledger, err := telemetry.NewLedger("", telemetry.LedgerOptions{Enabled: true})
+	if err != nil {
+		t.Fatalf("NewLedger: %v", err)
+	}
+	cp := newClientProxy(ClientOpenAI, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1},
+	}, time.Hour, 0, testResponseHeaderTimeout, circuitBreakerConfig{}, nil)
+	cp.ledger = ledger
+	var upstreamBody string
+	cp.httpClient.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(r.Body)
+		upstreamBody = string(body)
+		h := make(http.Header)
+		h.Set("Content-Type", "text/event-stream")
+		return newResponse(http.StatusOK, h, strings.Join([]string{
+			`data: {"id":"c1","choices":[{"delta":{"content":"hello"}}]}`,
+			"",
+			`data: {"id":"c1","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":6,"total_tokens":16}}`,
+			"",
+			"data: [DONE]",
+			"",
+		}, "\n")), nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/clipal/v1/chat/completions", strings.NewReader(`{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = withRequestContext(req, requestContextForClientPath(ClientOpenAI, "/v1/chat/completions", true))
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, req, "/v1/chat/completions")
+
+	if !strings.Contains(upstreamBody, `"include_usage":true`) {
+		t.Fatalf("upstream body = %s", upstreamBody)
+	}
+	if body := rr.Body.String(); strings.Contains(body, "usage") || !strings.Contains(body, "hello") || !strings.Contains(body, "[DONE]") {
+		t.Fatalf("client stream = %q", body)
+	}
+	entries, _ := ledger.Query(telemetry.LedgerQuery{})
+	if len(entries) != 1 || entries[0].InputTokens != 10 || entries[0].OutputTokens != 6 || entries[0].Estimated {
+		t.Fatalf("ledger = %#v", entries)
+	}
+}
+
+func TestForwardWithFailover_ChatStreamResendsWithoutRejectedStreamOptions(t *testing.T) {
+	t.Parallel()
+
+	cp := newClientProxy(ClientOpenAI, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1},
+	}, time.Hour, 0, testResponseHeaderTimeout, circuitBreakerConfig{}, nil)
+	clientBody := `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	var upstreamBodies []string
+	cp.httpClient.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(r.Body)
+		upstreamBodies = append(upstreamBodies, string(body))
+		if strings.Contains(string(body), "stream_options") {
+			return newResponse(http.StatusBadRequest, http.Header{"Content-Type": []string{"application/json"}}, `{"error":{"message":"Unrecognized request argument supplied: stream_options"}}`), nil
+		}
+		return newResponse(http.StatusOK, http.Header{"Content-Type": []string{"text/event-stream"}}, "data: {\"choices\":[{\"delta\":{\"content\":\"hello\"}}]}\n\ndata: [DONE]\n\n"), nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/clipal/v1/chat/completions", strings.NewReader(clientBody))
+	req.Header.Set("Content-Type", "application/json")
+	req = withRequestContext(req, requestContextForClientPath(ClientOpenAI, "/v1/chat/completions", true))
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, req, "/v1/chat/completions")
+
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "hello") {
+		t.Fatalf("status = %d body = %q", rr.Code, rr.Body.String())
+	}
+	if len(upstreamBodies) != 2 || upstreamBodies[1] != clientBody {
+		t.Fatalf("upstream bodies = %q", upstreamBodies)
+	}
+	if cp.isDeactivated(0) {
+		t.Fatalf("provider was deactivated for rejecting stream_options")
+	}
+}
+
+func TestForwardWithFailover_ChatStreamWithoutUsageIsEstimated(t *testing.T) {
+	t.Parallel()
+
+	ledger, err := telemetry.NewLedger("", telemetry.LedgerOptions{Enabled: true})
+	if err != nil {
+		t.Fatalf("NewLedger: %v", err)
+	}
+	cp := newClientProxy(ClientOpenAI, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1},
+	}, time.Hour, 0, testResponseHeaderTimeout, circuitBreakerConfig{}, nil)
+	cp.ledger = ledger
+	cp.httpClient.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		h := make(http.Header)
+		h.Set("Content-Type", "text/event-stream")
+		return newResponse(http.StatusOK, h, strings.Join([]string{
+			`data: {"id":"c1","choices":[{"delta":{"content":"hello world!"},"finish_reason":"stop"}]}`,
+			"",
+			"data: [DONE]",
+			"",
+		}, "\n")), nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/clipal/v1/chat/completions", strings.NewReader(`{"model":"gpt-4.1","stream":true,"messages":[{"role":"system","content":"be brief"},{"role":"user","content":[{"type":"text","text":"say hello"}]}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = withRequestContext(req, requestContextForClientPath(ClientOpenAI, "/v1/chat/completions", true))
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, req, "/v1/chat/completions")
+
+	entries, _ := ledger.Query(telemetry.LedgerQuery{})
+	if len(entries) != 1 {
+		t.Fatalf("ledger = %#v", entries)
+	}
+	// Prompt: "be brief" and "say hello" (2 tokens each) plus 4 per message;
+	// output: "hello", " world" and "!".
+	got := entries[0]
+	if !got.Estimated || got.InputTokens != 12 || got.OutputTokens != 3 || got.TotalTokens != 15 || !got.HasCost {
+		t.Fatalf("estimated entry = %#v", got)
+	}
+}
@@ -14,6 +14,10 @@ import (
 type streamSuccess struct {
 	responseBody []byte
 	usage        telemetry.UsageSnapshot
+	// estimable is set when the upstream reported no usage but the output
+	// can be estimated locally; outputEstimate is that estimate.
+	estimable      bool
+	outputEstimate int64
 }
 
 type providerTelemetryIdentity struct {
@@ -46,7 +50,79 @@ func (r *Router) DeleteProviderUsage(clientType string, provider string) error {
 	return r.telemetry.DeleteProvider(clientType, provider)
 }
 
+func usageLedgerOptions(cfg config.UsageLedgerConfig) telemetry.LedgerOptions {
+	return telemetry.LedgerOptions{
+		Enabled:       cfg.Enabled,
+		RetentionDays: cfg.RetentionDays,
+		MaxEntries:    cfg.MaxEntries,
+	}
+}
+
+// recordRequestEntry records the final outcome of a client request in the
+// usage ledger and the request metrics. Only the first outcome reported for a
+// request is kept.
+func (cp *ClientProxy) recordRequestEntry(now time.Time, req *http.Request, provider string, status int, result string) {
+	if cp == nil || (cp.ledger == nil && cp.metrics == nil && cp.tracer == nil && cp.events == nil) {
+		return
+	}
+	requestCtx, _ := requestContextFromRequest(req)
+	trace := requestTraceFromRequest(req)
+	entry, ok := trace.ledgerEntry(now, requestCtx)
+	if !ok {
+		return
+	}
+	entry.ClientType = string(requestCtx.ClientType)
+	if entry.ClientType == "" {
+		entry.ClientType = string(cp.clientType)
+	}
+	entry.Capability = string(requestCtx.Capability)
+	entry.Provider = provider
+	entry.Status = status
+	entry.Result = result
+	model := entry.EffectiveModel
+	if model == "" {
+		model = entry.RequestedModel
+	}
+	cp.publishEvent(Event{
+		Type:       EventRequestCompleted,
+		At:         now,
+		RequestID:  trace.requestID(),
+		Capability: entry.Capability,
+		Provider:   provider,
+		Model:      model,
+		Status:     status,
+		Result:     result,
+		DurationMS: entry.DurationMillis,
+	})
+	cp.metrics.observeRequest(entry)
+	if cp.tracer != nil {
+		cp.tracer.Export(trace.spans(entry, req.Method, requestCtx)...)
+	}
+	if cp.ledger == nil {
+		return
+	}
+	if err := cp.ledger.Append(entry); err != nil {
+		logger.Warn("[%s] failed to append usage ledger entry: %v", cp.clientType, err)
+	}
+}
+
 func (cp *ClientProxy) recordCompletedUsage(req *http.Request, provider string, statusCode int, usage telemetry.UsageSnapshot, when time.Time) {
+	cp.recordUsage(req, provider, statusCode, usage, when, false)
+}
+
+// recordRecoveredUsage records a request completed by a mid-stream continuation.
+func (cp *ClientProxy) recordRecoveredUsage(req *http.Request, provider string, statusCode int, usage telemetry.UsageSnapshot, when time.Time) {
+	cp.recordUsage(req, provider, statusCode, usage, when, true)
+}
+
+// recordInterruptedStreamUsage records the usage of an upstream stream that
+// broke off before completing. It counts a request but not a success.
+func (cp *ClientProxy) recordInterruptedStreamUsage(req *http.Request, provider string, usage telemetry.UsageSnapshot, when time.Time) {
+	cp.recordUsage(req, provider, 0, usage, when, false)
+}
+
+func (cp *ClientProxy) recordUsage(req *http.Request, provider string, statusCode int, usage telemetry.UsageSnapshot, when time.Time, recovered bool) {
+	requestTraceFromRequest(req).noteUsage(usage)
 	if cp == nil || cp.telemetry == nil {
 		return
 	}
@@ -59,9 +135,14 @@ func (cp *ClientProxy) recordCompletedUsage(req *http.Request, provider string,
 		clientType = strings.TrimSpace(string(cp.clientType))
 	}
 	countSuccess := ok && recordsGenerationSuccess(requestCtx.Capability, statusCode)
+	model, keyFingerprint := requestTraceFromRequest(req).attemptTarget()
 	_ = cp.telemetry.Record(clientType, provider, usage, when, telemetry.RecordOptions{
-		CountRequest: true,
-		CountSuccess: countSuccess,
+		CountRequest:   true,
+		CountSuccess:   countSuccess,
+		Recovered:      recovered,
+		Model:          model,
+		Capability:     string(requestCtx.Capability),
+		KeyFingerprint: keyFingerprint,
 	})
 }
 
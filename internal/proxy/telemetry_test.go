@@ -2,14 +2,17 @@ package proxy
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/lansespirit/Clipal/internal/config"
 	"github.com/lansespirit/Clipal/internal/telemetry"
+	"github.com/lansespirit/Clipal/internal/tracing"
 )
 
 func TestRecordCompletedUsageCountsOnlySuccessfulGenerationRequests(t *testing.T) {
@@ -203,3 +206,173 @@ func TestForwardWithFailover_OpenAIPlainTextStreamRecordsUsage(t *testing.T) {
 		t.Fatalf("reasoning_tokens = %d", got.ReasoningTokens)
 	}
 }
+
+func TestForwardWithFailover_AppendsUsageLedgerEntry(t *testing.T) {
+	t.Parallel()
+
+	ledger, err := telemetry.NewLedger("", telemetry.LedgerOptions{Enabled: true})
+	if err != nil {
+		t.Fatalf("NewLedger: %v", err)
+	}
+	store, err := telemetry.NewStore("")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	model := "gpt-5-mini"
+	cp := newClientProxy(ClientOpenAI, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1},
+		{Name: "p2", BaseURL: "http://p2", APIKey: "k2", Priority: 2, Overrides: &config.ProviderOverrides{Model: &model}},
+	}, time.Hour, 0, testResponseHeaderTimeout, circuitBreakerConfig{}, store)
+	cp.ledger = ledger
+	cp.httpClient.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host == "p1" {
+			return newResponse(http.StatusBadGateway, nil, "bad gateway"), nil
+		}
+		h := make(http.Header)
+		h.Set("Content-Type", "application/json")
+		return newResponse(http.StatusOK, h, `{"id":"resp_1","usage":{"input_tokens":12,"output_tokens":8,"total_tokens":20}}`), nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/clipal/v1/responses", bytes.NewReader([]byte(`{"model":"gpt-5","input":"hello"}`)))
+	req = withRequestContext(req, requestContextForClientPath(ClientOpenAI, "/v1/responses", true))
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, req, "/v1/responses")
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	entries, total := ledger.Query(telemetry.LedgerQuery{})
+	if total != 1 {
+		t.Fatalf("ledger entries = %d, want 1", total)
+	}
+	got := entries[0]
+	if got.Provider != "p2" || got.Attempts != 2 || got.Status != http.StatusOK || got.Result != "completed" {
+		t.Fatalf("entry = %#v", got)
+	}
+	if got.RequestedModel != "gpt-5" || got.EffectiveModel != "gpt-5-mini" {
+		t.Fatalf("models = %q -> %q", got.RequestedModel, got.EffectiveModel)
+	}
+	if got.KeyFingerprint != apiKeyFingerprint("k2") || got.KeyFingerprint == "k2" {
+		t.Fatalf("key_fingerprint = %q", got.KeyFingerprint)
+	}
+	if got.InputTokens != 12 || got.OutputTokens != 8 || got.TotalTokens != 20 {
+		t.Fatalf("tokens = %#v", got)
+	}
+
+	usage, ok := store.ProviderSnapshot(string(ClientOpenAI), "p2")
+	if !ok {
+		t.Fatalf("ProviderSnapshot missing")
+	}
+	if usage.Models["gpt-5-mini"].TotalTokens != 20 || usage.Keys[apiKeyFingerprint("k2")].RequestCount != 1 || usage.Capabilities[string(CapabilityOpenAIResponses)].SuccessCount != 1 {
+		t.Fatalf("breakdowns = models:%#v keys:%#v capabilities:%#v", usage.Models, usage.Keys, usage.Capabilities)
+	}
+}
+
+func TestForwardWithFailover_ExportsAttemptSpans(t *testing.T) {
+	t.Parallel()
+
+	spansCh := make(chan []map[string]any, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []map[string]any `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode export body: %v", err)
+		}
+		spansCh <- body.ResourceSpans[0].ScopeSpans[0].Spans
+	}))
+	defer collector.Close()
+
+	cp := newClientProxy(ClientOpenAI, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1},
+		{Name: "p2", BaseURL: "http://p2", APIKey: "k2", Priority: 2},
+	}, time.Hour, 0, testResponseHeaderTimeout, circuitBreakerConfig{})
+	cp.tracer = tracing.NewExporter(tracing.ExporterOptions{Endpoint: collector.URL})
+	defer func() { _ = cp.tracer.Close() }()
+	upstreamTraceparents := map[string]string{}
+	var mu sync.Mutex
+	cp.httpClient.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		mu.Lock()
+		upstreamTraceparents[r.URL.Host] = r.Header.Get("traceparent")
+		mu.Unlock()
+		if r.URL.Host == "p1" {
+			return newResponse(http.StatusBadGateway, nil, "bad gateway"), nil
+		}
+		h := make(http.Header)
+		h.Set("Content-Type", "application/json")
+		return newResponse(http.StatusOK, h, `{"id":"resp_1","usage":{"input_tokens":12,"output_tokens":8,"total_tokens":20}}`), nil
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/clipal/v1/responses", bytes.NewReader([]byte(`{"model":"gpt-5","input":"hello"}`)))
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	req = withRequestContext(req, requestContextForClientPath(ClientOpenAI, "/v1/responses", true))
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, req, "/v1/responses")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	cp.tracer.Flush()
+
+	var spans []map[string]any
+	select {
+	case spans = <-spansCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("collector received no spans")
+	}
+	if len(spans) != 3 {
+		t.Fatalf("spans = %d, want request span plus 2 attempts: %#v", len(spans), spans)
+	}
+	root, first, second := spans[0], spans[1], spans[2]
+	for _, span := range spans {
+		if span["traceId"] != traceID {
+			t.Fatalf("span trace id = %v, want %s", span["traceId"], traceID)
+		}
+	}
+	if root["parentSpanId"] != "00f067aa0ba902b7" {
+		t.Fatalf("root parent = %v", root["parentSpanId"])
+	}
+	if first["parentSpanId"] != root["spanId"] || second["parentSpanId"] != root["spanId"] {
+		t.Fatalf("attempt parents = %v, %v; root = %v", first["parentSpanId"], second["parentSpanId"], root["spanId"])
+	}
+	if got := spanAttribute(first, "clipal.failure_reason"); got != "server" {
+		t.Fatalf("first attempt failure_reason = %v", got)
+	}
+	if got := spanAttribute(first, "clipal.provider"); got != "p1" {
+		t.Fatalf("first attempt provider = %v", got)
+	}
+	if got := spanAttribute(second, "gen_ai.usage.output_tokens"); got != "8" {
+		t.Fatalf("second attempt output tokens = %v", got)
+	}
+	if got := spanAttribute(second, "clipal.key_fingerprint"); got != apiKeyFingerprint("k2") {
+		t.Fatalf("second attempt key fingerprint = %v", got)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got, want := upstreamTraceparents["p1"], "00-"+traceID+"-"+first["spanId"].(string)+"-01"; got != want {
+		t.Fatalf("p1 traceparent = %q, want %q", got, want)
+	}
+	if got, want := upstreamTraceparents["p2"], "00-"+traceID+"-"+second["spanId"].(string)+"-01"; got != want {
+		t.Fatalf("p2 traceparent = %q, want %q", got, want)
+	}
+}
+
+func spanAttribute(span map[string]any, key string) any {
+	attrs, _ := span["attributes"].([]any)
+	for _, raw := range attrs {
+		attr, _ := raw.(map[string]any)
+		if attr["key"] != key {
+			continue
+		}
+		value, _ := attr["value"].(map[string]any)
+		for _, v := range value {
+			return v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+)
+
+// headerProject lets a caller tag requests with the project they are billed
+// to. It is consumed by Clipal and never forwarded upstream.
+const headerProject = "X-Clipal-Project"
+
+const usageAttributionMaxLen = 128
+
+// requestProject returns the project a request is billed to: the
+// X-Clipal-Project header, or else a fingerprint of the token the caller sent
+// to Clipal so each consumer token reports separately.
+func requestProject(req *http.Request) string {
+	if req == nil {
+		return ""
+	}
+	if project := strings.TrimSpace(req.Header.Get(headerProject)); project != "" {
+		return truncateString(project, usageAttributionMaxLen)
+	}
+	if fingerprint := apiKeyFingerprint(consumerToken(req)); fingerprint != "" {
+		return "token:" + fingerprint
+	}
+	return ""
+}
+
+// consumerToken returns the credential the client presented to Clipal. It is
+// replaced by the provider key before the request goes upstream.
+func consumerToken(req *http.Request) string {
+	switch detectAuthCarrier(req) {
+	case authCarrierClaudeHeader:
+		return strings.TrimSpace(req.Header.Get("x-api-key"))
+	case authCarrierGeminiHeader:
+		return strings.TrimSpace(req.Header.Get("x-goog-api-key"))
+	case authCarrierAuthorization:
+		value := strings.TrimSpace(req.Header.Get("Authorization"))
+		if len(value) > len("Bearer ") && strings.EqualFold(value[:len("Bearer ")], "Bearer ") {
+			value = strings.TrimSpace(value[len("Bearer "):])
+		}
+		return value
+	case authCarrierQueryKey:
+		return strings.TrimSpace(req.URL.Query().Get("key"))
+	case authCarrierQueryAPIKey:
+		return strings.TrimSpace(req.URL.Query().Get("api_key"))
+	default:
+		return ""
+	}
+}
+
+// headerSessionID returns the conversation session a client announces in its
+// headers: Claude Code's session header, or Codex's session and thread IDs.
+func headerSessionID(headers http.Header) string {
+	return truncateString(firstNonEmptyHeader(headers,
+		"X-Claude-Code-Session-Id",
+		"Session-Id",
+		"Session_id",
+		"Thread-Id",
+		"Thread_id",
+	), usageAttributionMaxLen)
+}
+
+// bodySessionID returns the session carried in a Claude request's metadata,
+// the same field the OAuth compatibility layer reads.
+func bodySessionID(requestCtx RequestContext, root map[string]any) string {
+	if requestCtx.Family != ProtocolFamilyClaude || root == nil {
+		return ""
+	}
+	metadata, _ := root["metadata"].(map[string]any)
+	sessionID := sessionIDFromClaudeOAuthMetadataUserID(stringValue(metadata["user_id"]))
+	if sessionID == "" {
+		sessionID = strings.TrimSpace(stringValue(metadata["session_id"]))
+	}
+	return truncateString(sessionID, usageAttributionMaxLen)
+}
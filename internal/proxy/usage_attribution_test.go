@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/telemetry"
+)
+
+func TestRequestProject(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		header http.Header
+		target string
+		want   string
+	}{
+		{name: "project header wins", header: http.Header{"X-Clipal-Project": {" billing "}, "Authorization": {"Bearer sk-a"}}, want: "billing"},
+		{name: "bearer token", header: http.Header{"Authorization": {"Bearer sk-a"}}, want: "token:" + apiKeyFingerprint("sk-a")},
+		{name: "claude header", header: http.Header{"X-Api-Key": {"sk-b"}}, want: "token:" + apiKeyFingerprint("sk-b")},
+		{name: "gemini query key", target: "http://proxy/v1beta/models?key=sk-c", want: "token:" + apiKeyFingerprint("sk-c")},
+		{name: "anonymous", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := tt.target
+			if target == "" {
+				target = "http://proxy/v1/messages"
+			}
+			req := httptest.NewRequest(http.MethodPost, target, nil)
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+			if got := requestProject(req); got != tt.want {
+				t.Fatalf("requestProject = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestSessionID(t *testing.T) {
+	t.Parallel()
+
+	if got := headerSessionID(http.Header{"Session_id": {"codex-session"}, "Thread_id": {"codex-thread"}}); got != "codex-session" {
+		t.Fatalf("codex session = %q", got)
+	}
+	if got := headerSessionID(http.Header{"Thread-Id": {"codex-thread"}}); got != "codex-thread" {
+		t.Fatalf("codex thread = %q", got)
+	}
+
+	claude := RequestContext{Family: ProtocolFamilyClaude}
+	root := map[string]any{"metadata": map[string]any{"user_id": "user_abc_account__session_9f1c"}}
+	if got := bodySessionID(claude, root); got != "9f1c" {
+		t.Fatalf("legacy user_id session = %q", got)
+	}
+	root = map[string]any{"metadata": map[string]any{"user_id": `{"device_id":"d","session_id":"s-42"}`}}
+	if got := bodySessionID(claude, root); got != "s-42" {
+		t.Fatalf("json user_id session = %q", got)
+	}
+	if got := bodySessionID(RequestContext{Family: ProtocolFamilyOpenAI}, root); got != "" {
+		t.Fatalf("openai body session = %q", got)
+	}
+}
+
+func TestForwardWithFailover_AttributesLedgerEntryAndStripsProjectHeader(t *testing.T) {
+	t.Parallel()
+
+	ledger, err := telemetry.NewLedger("", telemetry.LedgerOptions{Enabled: true})
+	if err != nil {
+		t.Fatalf("NewLedger: %v", err)
+	}
+	cp := newClientProxy(ClientClaude, config.ClientModeAuto, "", []config.Provider{
+		{Name: "p1", BaseURL: "http://p1", APIKey: "k1", Priority: 1},
+	}, time.Hour, 0, testResponseHeaderTimeout, circuitBreakerConfig{}, nil)
+	cp.ledger = ledger
+	var upstreamProject string
+	cp.httpClient.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		upstreamProject = r.Header.Get(headerProject)
+		h := make(http.Header)
+		h.Set("Content-Type", "application/json")
+		return newResponse(http.StatusOK, h, `{"id":"msg_1","usage":{"input_tokens":3,"output_tokens":2}}`), nil
+	})
+
+	body := `{"model":"claude-sonnet-4-5","metadata":{"user_id":"user_abc_account__session_s-7"},"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "http://proxy/clipal/v1/messages", bytes.NewReader([]byte(body)))
+	req.Header.Set(headerProject, "web-app")
+	req = withRequestContext(req, requestContextForClientPath(ClientClaude, "/v1/messages", true))
+	rr := httptest.NewRecorder()
+	cp.forwardWithFailover(rr, req, "/v1/messages")
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	if upstreamProject != "" {
+		t.Fatalf("upstream received %s = %q", headerProject, upstreamProject)
+	}
+	entries, _ := ledger.Query(telemetry.LedgerQuery{Project: "web-app", SessionKey: "s-7"})
+	if len(entries) != 1 {
+		t.Fatalf("attributed entries = %#v", entries)
+	}
+}
@@ -50,54 +50,62 @@ type geminiCostRates struct {
 	LargePrompt     geminiTierRates
 }
 
-func applyUsageCostSnapshot(original *http.Request, requestCtx RequestContext, provider config.Provider, payload *requestPayload, snapshot telemetry.UsageSnapshot) telemetry.UsageSnapshot {
+// applyUsageCostSnapshot fills in the request cost: the upstream-reported
+// cost when present, otherwise the pricing catalog or built-in rates scaled by
+// the provider's multiplier.
+func applyUsageCostSnapshot(pricing config.PricingConfig, original *http.Request, requestCtx RequestContext, provider config.Provider, payload *requestPayload, snapshot telemetry.UsageSnapshot) telemetry.UsageSnapshot {
 	if snapshot.HasCost {
 		return snapshot
 	}
 	if costMicros, ok := usageCostMicrosFromRaw(snapshot.Usage); ok {
 		snapshot.CostMicros = costMicros
 		snapshot.HasCost = true
+		snapshot.CostSource = costSourceUpstream
 		return snapshot
 	}
 
-	costMicros, ok := inferredUsageCostMicros(original, requestCtx, provider, payload, snapshot)
+	model := effectiveUsageCostModel(original, requestCtx, provider, payload)
+	costMicros, source, ok := inferredUsageCostMicros(pricing, requestCtx.Family, requestCtx.Capability, provider.Name, model, snapshot)
 	if !ok {
 		return snapshot
 	}
 	snapshot.CostMicros = costMicros
 	snapshot.HasCost = true
+	snapshot.CostSource = source
 	return snapshot
 }
 
-func inferredUsageCostMicros(original *http.Request, requestCtx RequestContext, provider config.Provider, payload *requestPayload, snapshot telemetry.UsageSnapshot) (int64, bool) {
-	if snapshot.Usage == nil {
-		return 0, false
-	}
-
-	model := effectiveUsageCostModel(original, requestCtx, provider, payload)
-	if model == "" {
-		return 0, false
+func inferredUsageCostMicros(pricing config.PricingConfig, family ProtocolFamily, capability RequestCapability, providerName string, model string, snapshot telemetry.UsageSnapshot) (int64, string, bool) {
+	if snapshot.Usage == nil || model == "" || !isPricedCapability(family, capability) {
+		return 0, "", false
 	}
 
-	switch requestCtx.Family {
-	case ProtocolFamilyOpenAI:
-		if !isOpenAIGenerationCapability(requestCtx.Capability) {
-			return 0, false
+	var costMicros int64
+	source := costSourceCatalog
+	if entry, ok := pricing.Match(model); ok {
+		usage, ok := usageTokenBreakdownFor(family, snapshot)
+		if !ok {
+			return 0, "", false
 		}
-		return calculateOpenAICostMicros(model, snapshot.Usage)
-	case ProtocolFamilyClaude:
-		if requestCtx.Capability != CapabilityClaudeMessages {
-			return 0, false
+		costMicros = catalogCostMicros(pricing, entry, usage)
+	} else {
+		source = costSourceBuiltin
+		switch family {
+		case ProtocolFamilyOpenAI:
+			costMicros, ok = calculateOpenAICostMicros(model, snapshot.Usage)
+		case ProtocolFamilyClaude:
+			costMicros, ok = calculateClaudeCostMicros(model, snapshot.Usage)
+		case ProtocolFamilyGemini:
+			costMicros, ok = calculateGeminiCostMicros(model, snapshot.Usage)
 		}
-		return calculateClaudeCostMicros(model, snapshot.Usage)
-	case ProtocolFamilyGemini:
-		if requestCtx.Capability != CapabilityGeminiGenerateContent && requestCtx.Capability != CapabilityGeminiStreamGenerate {
-			return 0, false
+		if !ok {
+			return 0, "", false
 		}
-		return calculateGeminiCostMicros(model, snapshot.Usage)
-	default:
-		return 0, false
 	}
+	if multiplier := pricing.ProviderMultiplier(providerName); multiplier != 1 {
+		costMicros = int64(math.Round(float64(costMicros) * multiplier))
+	}
+	return costMicros, source, true
 }
 
 func effectiveUsageCostModel(original *http.Request, requestCtx RequestContext, provider config.Provider, payload *requestPayload) string {
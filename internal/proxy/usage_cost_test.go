@@ -13,7 +13,7 @@ import (
 func TestApplyUsageCostSnapshot_UsesDirectCostFromRaw(t *testing.T) {
 	t.Parallel()
 
-	snapshot := applyUsageCostSnapshot(nil, RequestContext{}, config.Provider{}, nil, telemetry.UsageSnapshot{
+	snapshot := applyUsageCostSnapshot(config.PricingConfig{}, nil, RequestContext{}, config.Provider{}, nil, telemetry.UsageSnapshot{
 		Usage: map[string]any{
 			"costUSD": 0.03238,
 		},
@@ -48,7 +48,7 @@ func TestApplyUsageCostSnapshot_CodexUsesProviderOverrideAndCachedTokens(t *test
 		},
 	}
 
-	snapshot := applyUsageCostSnapshot(req, requestCtx, provider, newRequestPayload(body), telemetry.UsageSnapshot{
+	snapshot := applyUsageCostSnapshot(config.PricingConfig{}, req, requestCtx, provider, newRequestPayload(body), telemetry.UsageSnapshot{
 		Usage: map[string]any{
 			"prompt_tokens":     100000.0,
 			"completion_tokens": 20000.0,
@@ -83,7 +83,7 @@ func TestApplyUsageCostSnapshot_OpenAIAPIProviderUsesModelFromRequest(t *testing
 		AuthType: config.ProviderAuthTypeAPIKey,
 	}
 
-	snapshot := applyUsageCostSnapshot(req, requestCtx, provider, newRequestPayload(body), telemetry.UsageSnapshot{
+	snapshot := applyUsageCostSnapshot(config.PricingConfig{}, req, requestCtx, provider, newRequestPayload(body), telemetry.UsageSnapshot{
 		Usage: map[string]any{
 			"prompt_tokens":     100000.0,
 			"completion_tokens": 20000.0,
@@ -119,7 +119,7 @@ func TestApplyUsageCostSnapshot_ClaudeUsesRawCacheAndWebSearchUsage(t *testing.T
 		OAuthProvider: config.OAuthProviderClaude,
 	}
 
-	snapshot := applyUsageCostSnapshot(req, requestCtx, provider, newRequestPayload(body), telemetry.UsageSnapshot{
+	snapshot := applyUsageCostSnapshot(config.PricingConfig{}, req, requestCtx, provider, newRequestPayload(body), telemetry.UsageSnapshot{
 		Usage: map[string]any{
 			"input_tokens":                10000.0,
 			"output_tokens":               2000.0,
@@ -156,7 +156,7 @@ func TestApplyUsageCostSnapshot_GeminiUsesThoughtTokensAndLargePromptTier(t *tes
 		OAuthProvider: config.OAuthProviderGemini,
 	}
 
-	snapshot := applyUsageCostSnapshot(req, requestCtx, provider, newRequestPayload([]byte(`{"contents":[]}`)), telemetry.UsageSnapshot{
+	snapshot := applyUsageCostSnapshot(config.PricingConfig{}, req, requestCtx, provider, newRequestPayload([]byte(`{"contents":[]}`)), telemetry.UsageSnapshot{
 		Usage: map[string]any{
 			"promptTokenCount":        250000.0,
 			"candidatesTokenCount":    10000.0,
@@ -193,7 +193,7 @@ func TestApplyUsageCostSnapshot_GeminiSupportsLiveUsageFields(t *testing.T) {
 		AuthType: config.ProviderAuthTypeAPIKey,
 	}
 
-	snapshot := applyUsageCostSnapshot(req, requestCtx, provider, newRequestPayload([]byte(`{"contents":[],"model":"gemini-2.5-pro"}`)), telemetry.UsageSnapshot{
+	snapshot := applyUsageCostSnapshot(config.PricingConfig{}, req, requestCtx, provider, newRequestPayload([]byte(`{"contents":[],"model":"gemini-2.5-pro"}`)), telemetry.UsageSnapshot{
 		Usage: map[string]any{
 			"promptTokenCount":        1000.0,
 			"responseTokenCount":      200.0,
@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"math"
+	"strings"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/telemetry"
+)
+
+const (
+	costSourceUpstream = "upstream"
+	costSourceCatalog  = "catalog"
+	costSourceBuiltin  = "builtin"
+)
+
+// usageTokenBreakdown splits upstream usage into the token classes a pricing
+// catalog bills separately. input excludes cached prompt tokens and output
+// excludes reasoning tokens.
+type usageTokenBreakdown struct {
+	input      int64
+	cacheRead  int64
+	cacheWrite int64
+	output     int64
+	reasoning  int64
+}
+
+func (b usageTokenBreakdown) promptTokens() int64 {
+	return b.input + b.cacheRead + b.cacheWrite
+}
+
+func usageTokenBreakdownFor(family ProtocolFamily, snapshot telemetry.UsageSnapshot) (usageTokenBreakdown, bool) {
+	if !hasUsageFields(family, snapshot.Usage) {
+		return usageTokenBreakdown{}, false
+	}
+	read, write := usageCacheTokens(family, snapshot.Usage)
+	return usageTokenBreakdown{
+		input:      max(snapshot.InputTokens-read-write, 0),
+		cacheRead:  read,
+		cacheWrite: write,
+		output:     max(snapshot.OutputTokens-snapshot.ReasoningTokens, 0),
+		reasoning:  snapshot.ReasoningTokens + snapshot.ThoughtsTokens,
+	}, true
+}
+
+// usageCacheTokens returns the prompt tokens read from and written to the
+// provider's prompt cache.
+func usageCacheTokens(family ProtocolFamily, raw map[string]any) (int64, int64) {
+	if raw == nil {
+		return 0, 0
+	}
+	var read, write int64
+	switch family {
+	case ProtocolFamilyOpenAI:
+		read, _ = nestedInt64Lookup(raw, "input_tokens_details", "cached_tokens")
+		if read == 0 {
+			read, _ = nestedInt64Lookup(raw, "prompt_tokens_details", "cached_tokens")
+		}
+	case ProtocolFamilyClaude:
+		read, _ = int64Lookup(raw, "cache_read_input_tokens")
+		write, _ = int64Lookup(raw, "cache_creation_input_tokens")
+	case ProtocolFamilyGemini:
+		read, _ = int64Lookup(raw, "cachedContentTokenCount")
+	}
+	return max(read, 0), max(write, 0)
+}
+
+func hasUsageFields(family ProtocolFamily, raw map[string]any) bool {
+	switch family {
+	case ProtocolFamilyOpenAI:
+		return hasOpenAIUsageFields(raw)
+	case ProtocolFamilyClaude:
+		return hasClaudeUsageFields(raw)
+	case ProtocolFamilyGemini:
+		return hasGeminiUsageFields(raw)
+	default:
+		return false
+	}
+}
+
+func isPricedCapability(family ProtocolFamily, capability RequestCapability) bool {
+	switch family {
+	case ProtocolFamilyOpenAI:
+		return isOpenAIGenerationCapability(capability)
+	case ProtocolFamilyClaude:
+		return capability == CapabilityClaudeMessages
+	case ProtocolFamilyGemini:
+		return capability == CapabilityGeminiGenerateContent || capability == CapabilityGeminiStreamGenerate
+	default:
+		return false
+	}
+}
+
+// catalogCostMicros prices usage with a catalog entry, converted to USD.
+func catalogCostMicros(pricing config.PricingConfig, entry config.ModelPricing, usage usageTokenBreakdown) int64 {
+	rates := entry.PriceRates
+	prompt := usage.promptTokens()
+	for _, tier := range entry.Tiers {
+		if prompt > tier.AboveInputTokens {
+			rates = overlayPriceRates(rates, tier.PriceRates)
+		}
+	}
+	input := priceOr(rates.Input, 0)
+	output := priceOr(rates.Output, 0)
+	// Prices are per million tokens, so tokens × price is already in micros.
+	total := float64(usage.input) * input
+	total += float64(usage.cacheRead) * priceOr(rates.CacheRead, input)
+	total += float64(usage.cacheWrite) * priceOr(rates.CacheWrite, input)
+	total += float64(usage.output) * output
+	total += float64(usage.reasoning) * priceOr(rates.Reasoning, output)
+	usdRate, _ := pricing.USDRate(entry.Currency)
+	return int64(math.Round(total * usdRate))
+}
+
+func overlayPriceRates(base, tier config.PriceRates) config.PriceRates {
+	if tier.Input != nil {
+		base.Input = tier.Input
+	}
+	if tier.Output != nil {
+		base.Output = tier.Output
+	}
+	if tier.CacheRead != nil {
+		base.CacheRead = tier.CacheRead
+	}
+	if tier.CacheWrite != nil {
+		base.CacheWrite = tier.CacheWrite
+	}
+	if tier.Reasoning != nil {
+		base.Reasoning = tier.Reasoning
+	}
+	return base
+}
+
+func priceOr(price *float64, fallback float64) float64 {
+	if price == nil {
+		return fallback
+	}
+	return *price
+}
+
+func protocolFamilyForCapability(capability string) ProtocolFamily {
+	for _, family := range []ProtocolFamily{ProtocolFamilyClaude, ProtocolFamilyOpenAI, ProtocolFamilyGemini} {
+		if strings.HasPrefix(capability, string(family)+"_") {
+			return family
+		}
+	}
+	return ""
+}
+
+// RecomputeLedgerCosts re-prices the ledger entries matching q with pricing and
+// the built-in tables. Costs reported by the upstream are kept. It returns the
+// number of entries whose cost changed.
+func RecomputeLedgerCosts(ledger *telemetry.Ledger, pricing config.PricingConfig, q telemetry.LedgerQuery) (int, error) {
+	return ledger.Update(q, func(entry *telemetry.LedgerEntry) bool {
+		if entry.CostSource == costSourceUpstream {
+			return false
+		}
+		family := protocolFamilyForCapability(entry.Capability)
+		model := entry.EffectiveModel
+		if model == "" {
+			model = entry.RequestedModel
+		}
+		costMicros, source, ok := inferredUsageCostMicros(pricing, family, RequestCapability(entry.Capability), entry.Provider, normalizeUsageCostModel(model), ledgerUsageSnapshot(family, *entry))
+		if !ok || (entry.HasCost && entry.CostMicros == costMicros && entry.CostSource == source) {
+			return false
+		}
+		entry.CostMicros = costMicros
+		entry.HasCost = true
+		entry.CostSource = source
+		return true
+	})
+}
+
+// ledgerUsageSnapshot rebuilds a protocol-native usage object from the token
+// counts kept in a ledger entry so the regular pricing code can be reused.
+func ledgerUsageSnapshot(family ProtocolFamily, entry telemetry.LedgerEntry) telemetry.UsageSnapshot {
+	snapshot := telemetry.UsageSnapshot{
+		UsageDelta: telemetry.UsageDelta{
+			InputTokens:  entry.InputTokens,
+			OutputTokens: entry.OutputTokens,
+			TotalTokens:  entry.TotalTokens,
+		},
+		ReasoningTokens: entry.ReasoningTokens,
+		ThoughtsTokens:  entry.ThoughtsTokens,
+	}
+	switch family {
+	case ProtocolFamilyOpenAI:
+		snapshot.Usage = map[string]any{
+			"input_tokens":          entry.InputTokens,
+			"output_tokens":         entry.OutputTokens,
+			"input_tokens_details":  map[string]any{"cached_tokens": entry.CacheReadTokens},
+			"output_tokens_details": map[string]any{"reasoning_tokens": entry.ReasoningTokens},
+		}
+	case ProtocolFamilyClaude:
+		snapshot.Usage = map[string]any{
+			"input_tokens":                max(entry.InputTokens-entry.CacheReadTokens-entry.CacheWriteTokens, 0),
+			"cache_read_input_tokens":     entry.CacheReadTokens,
+			"cache_creation_input_tokens": entry.CacheWriteTokens,
+			"output_tokens":               entry.OutputTokens,
+		}
+	case ProtocolFamilyGemini:
+		snapshot.Usage = map[string]any{
+			"promptTokenCount":        entry.InputTokens,
+			"cachedContentTokenCount": entry.CacheReadTokens,
+			"candidatesTokenCount":    entry.OutputTokens,
+			"thoughtsTokenCount":      entry.ThoughtsTokens,
+		}
+	}
+	return snapshot
+}
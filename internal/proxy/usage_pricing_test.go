@@ -0,0 +1,142 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/telemetry"
+)
+
+func pricePtr(v float64) *float64 { return &v }
+
+func TestInferredUsageCostMicros_CatalogConvertsCurrencyAndAppliesMultiplier(t *testing.T) {
+	t.Parallel()
+
+	pricing := config.PricingConfig{
+		Models: []config.ModelPricing{{
+			Model:    "my-claude-*",
+			Currency: "CNY",
+			PriceRates: config.PriceRates{
+				Input:      pricePtr(10),
+				Output:     pricePtr(40),
+				CacheRead:  pricePtr(1),
+				CacheWrite: pricePtr(12.5),
+			},
+		}},
+		ProviderMultipliers: map[string]float64{"reseller": 1.5},
+		ExchangeRates:       map[string]float64{"CNY": 0.14},
+	}
+	snapshot := telemetry.UsageSnapshot{
+		UsageDelta: telemetry.UsageDelta{InputTokens: 3400, OutputTokens: 500},
+		Usage: map[string]any{
+			"input_tokens":                1000.0,
+			"cache_read_input_tokens":     2000.0,
+			"cache_creation_input_tokens": 400.0,
+			"output_tokens":               500.0,
+		},
+	}
+
+	// 1000×10 + 2000×1 + 400×12.5 + 500×40 = 37000 CNY micros = 5180 USD micros.
+	cost, source, ok := inferredUsageCostMicros(pricing, ProtocolFamilyClaude, CapabilityClaudeMessages, "direct", "My-Claude-1", snapshot)
+	if !ok || source != costSourceCatalog || cost != 5180 {
+		t.Fatalf("cost = %d source=%q ok=%v", cost, source, ok)
+	}
+	cost, _, _ = inferredUsageCostMicros(pricing, ProtocolFamilyClaude, CapabilityClaudeMessages, "reseller", "my-claude-1", snapshot)
+	if cost != 7770 {
+		t.Fatalf("multiplied cost = %d", cost)
+	}
+}
+
+func TestInferredUsageCostMicros_CatalogTiersAndReasoningFallback(t *testing.T) {
+	t.Parallel()
+
+	pricing := config.PricingConfig{
+		Models: []config.ModelPricing{{
+			Model:      "gpt-custom",
+			PriceRates: config.PriceRates{Input: pricePtr(1), Output: pricePtr(2)},
+			Tiers: []config.PriceTier{{
+				AboveInputTokens: 1000,
+				PriceRates:       config.PriceRates{Input: pricePtr(2), Output: pricePtr(4)},
+			}},
+		}},
+	}
+	usage := func(input float64) telemetry.UsageSnapshot {
+		return telemetry.UsageSnapshot{
+			UsageDelta:      telemetry.UsageDelta{InputTokens: int64(input), OutputTokens: 100},
+			ReasoningTokens: 40,
+			Usage: map[string]any{
+				"input_tokens":          input,
+				"output_tokens":         100.0,
+				"output_tokens_details": map[string]any{"reasoning_tokens": 40.0},
+			},
+		}
+	}
+
+	cost, _, ok := inferredUsageCostMicros(pricing, ProtocolFamilyOpenAI, CapabilityOpenAIResponses, "p1", "gpt-custom", usage(1000))
+	if !ok || cost != 1200 {
+		t.Fatalf("base tier cost = %d ok=%v", cost, ok)
+	}
+	// Above the threshold: 2000×2 + 60×4 + 40×4 (reasoning falls back to output).
+	cost, _, _ = inferredUsageCostMicros(pricing, ProtocolFamilyOpenAI, CapabilityOpenAIResponses, "p1", "gpt-custom", usage(2000))
+	if cost != 4400 {
+		t.Fatalf("upper tier cost = %d", cost)
+	}
+}
+
+func TestRecomputeLedgerCosts(t *testing.T) {
+	t.Parallel()
+
+	ledger, err := telemetry.NewLedger("", telemetry.LedgerOptions{Enabled: true})
+	if err != nil {
+		t.Fatalf("NewLedger: %v", err)
+	}
+	now := time.Now()
+	for _, entry := range []telemetry.LedgerEntry{
+		{Time: now, ClientType: "claude", Capability: "claude_messages", Provider: "p1", RequestedModel: "my-claude-1", Status: 200, InputTokens: 3400, OutputTokens: 500, CacheReadTokens: 2000, CacheWriteTokens: 400},
+		{Time: now, ClientType: "claude", Capability: "claude_messages", Provider: "p1", RequestedModel: "my-claude-1", Status: 200, InputTokens: 10, OutputTokens: 10, CostMicros: 99, HasCost: true, CostSource: costSourceUpstream},
+		{Time: now, ClientType: "claude", Capability: "claude_messages", Provider: "p1", RequestedModel: "unknown-model", Status: 200, InputTokens: 10, OutputTokens: 10},
+	} {
+		if err := ledger.Append(entry); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	pricing := config.PricingConfig{
+		Models: []config.ModelPricing{{
+			Model: "my-claude-*",
+			PriceRates: config.PriceRates{
+				Input:      pricePtr(10),
+				Output:     pricePtr(40),
+				CacheRead:  pricePtr(1),
+				CacheWrite: pricePtr(12.5),
+			},
+		}},
+	}
+	updated, err := RecomputeLedgerCosts(ledger, pricing, telemetry.LedgerQuery{})
+	if err != nil {
+		t.Fatalf("RecomputeLedgerCosts: %v", err)
+	}
+	if updated != 1 {
+		t.Fatalf("updated = %d", updated)
+	}
+	entries, _ := ledger.Query(telemetry.LedgerQuery{Model: "my-claude-1"})
+	for _, entry := range entries {
+		switch entry.CostSource {
+		case costSourceCatalog:
+			if entry.CostMicros != 37000 {
+				t.Fatalf("recomputed cost = %d", entry.CostMicros)
+			}
+		case costSourceUpstream:
+			if entry.CostMicros != 99 {
+				t.Fatalf("upstream cost changed to %d", entry.CostMicros)
+			}
+		default:
+			t.Fatalf("unexpected entry %#v", entry)
+		}
+	}
+
+	if updated, _ := RecomputeLedgerCosts(ledger, pricing, telemetry.LedgerQuery{}); updated != 0 {
+		t.Fatalf("second recompute updated = %d", updated)
+	}
+}
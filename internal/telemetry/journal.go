@@ -0,0 +1,207 @@
+package telemetry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	journalFilename = "usage.journal"
+	// journalCompactEvery folds the journal into usage.json after this many
+	// appended records, bounding both its size and the replay work on start.
+	journalCompactEvery = 2000
+)
+
+// journalRecord is one Record call, appended to usage.journal between
+// snapshots. Seq orders records against the snapshot's JournalSeq so a record
+// already folded into usage.json is never applied twice.
+type journalRecord struct {
+	Seq        uint64        `json:"seq"`
+	Client     string        `json:"client"`
+	Provider   string        `json:"provider"`
+	Time       time.Time     `json:"time"`
+	Usage      UsageSnapshot `json:"usage"`
+	Request    bool          `json:"request,omitempty"`
+	Success    bool          `json:"success,omitempty"`
+	Recovered  bool          `json:"recovered,omitempty"`
+	Model      string        `json:"model,omitempty"`
+	Capability string        `json:"capability,omitempty"`
+	Key        string        `json:"key,omitempty"`
+}
+
+// applyRecord adds one record to state.
+func applyRecord(state *storeState, rec journalRecord) {
+	delta := rec.Usage.normalized()
+	snapshot := rec.Usage
+	when := rec.Time
+
+	client := state.Clients[rec.Client]
+	if client.Providers == nil {
+		client.Providers = map[string]ProviderUsage{}
+	}
+	entry := client.Providers[rec.Provider]
+	if rec.Request {
+		entry.RequestCount++
+	}
+	if rec.Success {
+		entry.SuccessCount++
+	}
+	if rec.Recovered {
+		entry.RecoveredCount++
+	}
+	entry.InputTokens += delta.InputTokens
+	entry.OutputTokens += delta.OutputTokens
+	entry.TotalTokens += delta.TotalTokens
+	entry.ReasoningTokens += snapshot.ReasoningTokens
+	entry.ThoughtsTokens += snapshot.ThoughtsTokens
+	if snapshot.HasCost {
+		entry.TotalCostMicros += snapshot.CostMicros
+		entry.HasCost = true
+		entry.DailyCosts = addCostBucket(entry.DailyCosts, usageDayBucket(when), snapshot.CostMicros)
+		entry.HourlyCosts = addCostBucket(entry.HourlyCosts, usageHourBucket(when), snapshot.CostMicros)
+		pruneHourlyCosts(entry.HourlyCosts, when)
+	}
+	counters := UsageCounters{
+		InputTokens:     delta.InputTokens,
+		OutputTokens:    delta.OutputTokens,
+		TotalTokens:     delta.TotalTokens,
+		ReasoningTokens: snapshot.ReasoningTokens,
+		ThoughtsTokens:  snapshot.ThoughtsTokens,
+	}
+	if rec.Request {
+		counters.RequestCount = 1
+	}
+	if rec.Success {
+		counters.SuccessCount = 1
+	}
+	if snapshot.HasCost {
+		counters.CostMicros = snapshot.CostMicros
+		counters.HasCost = true
+	}
+	entry.Models = addUsageCounters(entry.Models, rec.Model, counters)
+	entry.Capabilities = addUsageCounters(entry.Capabilities, rec.Capability, counters)
+	entry.Keys = addUsageCounters(entry.Keys, rec.Key, counters)
+	entry.LastUsedAt = when
+	if snapshot.Usage != nil {
+		entry.Usage = cloneMap(snapshot.Usage)
+	}
+	client.Providers[rec.Provider] = entry
+	state.Clients[rec.Client] = client
+	state.Version = storeVersion
+	state.UpdatedAt = when
+}
+
+func (s *Store) journalPath() string {
+	return filepath.Join(filepath.Dir(s.path), journalFilename)
+}
+
+// appendJournal writes the pending records to the journal and returns how
+// many records the journal holds since the last snapshot. Callers hold
+// persistMu.
+func (s *Store) appendJournal() (int, error) {
+	s.mu.Lock()
+	records := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	if len(records) == 0 {
+		return s.journalCount, nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			s.requeue(records)
+			return s.journalCount, err
+		}
+	}
+	//nolint:gosec // path is derived from the Clipal config directory.
+	f, err := os.OpenFile(s.journalPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		s.requeue(records)
+		return s.journalCount, err
+	}
+	if s.journalTorn {
+		// Terminate the torn line from the failed append so the next
+		// record starts on a line of its own.
+		_, err = f.Write([]byte{'\n'})
+	}
+	if err == nil {
+		_, err = f.Write(buf.Bytes())
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	s.journalTorn = err != nil
+	if err != nil {
+		// Records are retried in order, so replay can drop any copy that a
+		// partial write left behind by its sequence number.
+		s.requeue(records)
+		return s.journalCount, err
+	}
+	s.journalCount += len(records)
+	return s.journalCount, nil
+}
+
+func (s *Store) requeue(records []journalRecord) {
+	s.mu.Lock()
+	s.pending = append(records, s.pending...)
+	s.mu.Unlock()
+}
+
+// truncateJournal empties the journal once a snapshot covers every record in
+// it. Callers hold persistMu.
+func (s *Store) truncateJournal() error {
+	if err := os.Truncate(s.journalPath(), 0); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.journalCount = 0
+	s.journalTorn = false
+	return nil
+}
+
+// replayJournal applies the journal records newer than state.JournalSeq. It
+// returns the highest sequence number seen and the number of records applied.
+func (s *Store) replayJournal(state *storeState) (uint64, int, error) {
+	maxSeq := state.JournalSeq
+	f, err := os.Open(s.journalPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return maxSeq, 0, nil
+		}
+		return maxSeq, 0, err
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), ledgerMaxLineBytes)
+	applied := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var rec journalRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			// A torn line from a crash mid-append; later lines are intact.
+			continue
+		}
+		// Records are appended in sequence order; anything not newer than the
+		// last applied record is already in the snapshot or a retried copy.
+		if rec.Seq <= maxSeq || rec.Client == "" || rec.Provider == "" {
+			continue
+		}
+		applyRecord(state, rec)
+		maxSeq = max(maxSeq, rec.Seq)
+		applied++
+	}
+	s.journalCount = applied
+	return maxSeq, applied, scanner.Err()
+}
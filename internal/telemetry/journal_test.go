@@ -0,0 +1,145 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreJournalRecoversRecordsWithoutSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	store.persistInterval = time.Hour
+
+	when := time.Date(2026, 4, 8, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if err := store.Record("openai", "p1", UsageSnapshot{
+			UsageDelta: UsageDelta{InputTokens: 10, OutputTokens: 5},
+			CostMicros: 100,
+			HasCost:    true,
+		}, when, RecordOptions{CountRequest: true, CountSuccess: true, Model: "gpt-5"}); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	if err := store.persist(); err != nil {
+		t.Fatalf("persist: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, storeFilename)); !os.IsNotExist(err) {
+		t.Fatalf("expected no snapshot before compaction, stat err=%v", err)
+	}
+
+	// Simulate a crash: reopen without closing the first store.
+	reloaded, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore reload: %v", err)
+	}
+	got, ok := reloaded.ProviderSnapshot("openai", "p1")
+	if !ok || got.RequestCount != 3 || got.TotalTokens != 45 || got.TotalCostMicros != 300 || got.Models["gpt-5"].RequestCount != 3 {
+		t.Fatalf("replayed snapshot = %#v ok=%v", got, ok)
+	}
+	if err := reloaded.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Close folded the journal into usage.json; a reopen must not replay it.
+	data, err := os.ReadFile(filepath.Join(dir, journalFilename))
+	if err != nil || len(data) != 0 {
+		t.Fatalf("journal after compaction = %q err=%v", data, err)
+	}
+	again, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore again: %v", err)
+	}
+	defer func() { _ = again.Close() }()
+	if got, _ := again.ProviderSnapshot("openai", "p1"); got.RequestCount != 3 {
+		t.Fatalf("snapshot after compaction = %#v", got)
+	}
+}
+
+func TestStoreJournalReplaySkipsFoldedTornAndDuplicateRecords(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, storeFilename), []byte(`{"version":4,"journal_seq":2,"clients":{"openai":{"providers":{"p1":{"request_count":2,"total_tokens":20}}}}}`), 0o600); err != nil {
+		t.Fatalf("WriteFile snapshot: %v", err)
+	}
+	lines := []string{
+		`{"seq":1,"client":"openai","provider":"p1","time":"2026-04-08T12:00:00Z","usage":{"total_tokens":10},"request":true}`,
+		`{"seq":2,"client":"openai","provider":"p1","time":"2026-04-08T12:00:01Z","usage":{"total_tokens":10},"request":true}`,
+		`{"seq":3,"client":"openai","provider":"p1","time":"2026-04-08T12:00:02Z","usage":{"total_tokens":10},"request":true}`,
+		`{"seq":4,"client":"openai","provider":"p1","ti`,
+		`{"seq":4,"client":"openai","provider":"p1","time":"2026-04-08T12:00:03Z","usage":{"total_tokens":10},"request":true}`,
+		`{"seq":4,"client":"openai","provider":"p1","time":"2026-04-08T12:00:03Z","usage":{"total_tokens":10},"request":true}`,
+	}
+	if err := os.WriteFile(filepath.Join(dir, journalFilename), []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile journal: %v", err)
+	}
+
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	got, _ := store.ProviderSnapshot("openai", "p1")
+	if got.RequestCount != 4 || got.TotalTokens != 40 {
+		t.Fatalf("replayed snapshot = %#v", got)
+	}
+
+	// New records continue the sequence after the replayed ones.
+	if err := store.RecordUsage("openai", "p1", UsageSnapshot{UsageDelta: UsageDelta{TotalTokens: 10}}, time.Now()); err != nil {
+		t.Fatalf("RecordUsage: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, storeFilename))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var persisted storeState
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if persisted.JournalSeq != 5 || persisted.Clients["openai"].Providers["p1"].RequestCount != 5 {
+		t.Fatalf("persisted = %#v", persisted)
+	}
+}
+
+func TestStorePersistCompactsLargeJournal(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	store.persistInterval = time.Hour
+
+	when := time.Date(2026, 4, 8, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < journalCompactEvery; i++ {
+		if err := store.RecordUsage("openai", "p1", UsageSnapshot{UsageDelta: UsageDelta{TotalTokens: 1}}, when); err != nil {
+			t.Fatalf("RecordUsage: %v", err)
+		}
+	}
+	if err := store.persist(); err != nil {
+		t.Fatalf("persist: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, journalFilename))
+	if err != nil || len(data) != 0 {
+		t.Fatalf("expected compacted journal, got %d bytes err=%v", len(data), err)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, storeFilename))
+	if err != nil {
+		t.Fatalf("ReadFile snapshot: %v", err)
+	}
+	var persisted storeState
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if persisted.JournalSeq != journalCompactEvery || persisted.Clients["openai"].Providers["p1"].TotalTokens != journalCompactEvery {
+		t.Fatalf("persisted seq=%d usage=%#v", persisted.JournalSeq, persisted.Clients["openai"].Providers["p1"])
+	}
+}
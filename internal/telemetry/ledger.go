@@ -0,0 +1,724 @@
+package telemetry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/fsutil"
+)
+
+const (
+	ledgerFilename = "usage-ledger.jsonl"
+	// ledgerCompactEvery rewrites the ledger file after this many appends so
+	// expired and overflow entries do not accumulate on disk.
+	ledgerCompactEvery = 1000
+	ledgerMaxLineBytes = 1 << 20
+)
+
+// LedgerEntry records one completed client request.
+type LedgerEntry struct {
+	Time            time.Time `json:"time"`
+	ClientType      string    `json:"client_type"`
+	Capability      string    `json:"capability,omitempty"`
+	Provider        string    `json:"provider,omitempty"`
+	KeyFingerprint  string    `json:"key_fingerprint,omitempty"`
+	RequestedModel  string    `json:"requested_model,omitempty"`
+	EffectiveModel  string    `json:"effective_model,omitempty"`
+	Status          int       `json:"status"`
+	Result          string    `json:"result,omitempty"`
+	Attempts        int       `json:"attempts,omitempty"`
+	TTFBMillis      int64     `json:"ttfb_ms,omitempty"`
+	DurationMillis  int64     `json:"duration_ms,omitempty"`
+	InputTokens     int64     `json:"input_tokens,omitempty"`
+	OutputTokens    int64     `json:"output_tokens,omitempty"`
+	TotalTokens     int64     `json:"total_tokens,omitempty"`
+	ReasoningTokens int64     `json:"reasoning_tokens,omitempty"`
+	ThoughtsTokens  int64     `json:"thoughts_tokens,omitempty"`
+	// CacheReadTokens and CacheWriteTokens are the parts of InputTokens read
+	// from or written to the provider's prompt cache.
+	CacheReadTokens  int64  `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int64  `json:"cache_write_tokens,omitempty"`
+	CostMicros       int64  `json:"cost_micros,omitempty"`
+	HasCost          bool   `json:"has_cost,omitempty"`
+	CostSource       string `json:"cost_source,omitempty"`
+	Estimated        bool   `json:"estimated,omitempty"`
+	SessionKey       string `json:"session_key,omitempty"`
+	// Project and SessionID attribute the request for cost reporting: the
+	// X-Clipal-Project header or the caller's token, and the client's own
+	// conversation session.
+	Project   string `json:"project,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// ApplyUsage copies the token and cost breakdown of a usage snapshot.
+func (e *LedgerEntry) ApplyUsage(snapshot UsageSnapshot) {
+	delta := snapshot.normalized()
+	e.InputTokens = delta.InputTokens
+	e.OutputTokens = delta.OutputTokens
+	e.TotalTokens = delta.TotalTokens
+	e.ReasoningTokens = snapshot.ReasoningTokens
+	e.ThoughtsTokens = snapshot.ThoughtsTokens
+	e.CostMicros = snapshot.CostMicros
+	e.HasCost = snapshot.HasCost
+	e.CostSource = snapshot.CostSource
+	e.Estimated = snapshot.Estimated
+}
+
+// Session returns the client session ID, or the sticky routing key for
+// clients that do not send one.
+func (e LedgerEntry) Session() string {
+	if e.SessionID != "" {
+		return e.SessionID
+	}
+	return e.SessionKey
+}
+
+// Success reports whether the request finished with a complete 2xx response.
+func (e LedgerEntry) Success() bool {
+	if e.Status < 200 || e.Status >= 300 {
+		return false
+	}
+	switch e.Result {
+	case "", "completed", "recovered":
+		return true
+	default:
+		return false
+	}
+}
+
+type LedgerOptions struct {
+	Enabled       bool
+	RetentionDays int
+	MaxEntries    int
+}
+
+// Ledger is an append-only JSON Lines log of completed requests. Entries are
+// kept in memory for queries. Appends are queued and written by a background
+// worker every persistInterval; the same worker rewrites the file after
+// ledgerCompactEvery appends to apply retention and the entry cap.
+type Ledger struct {
+	path            string
+	persistInterval time.Duration
+
+	mu           sync.RWMutex
+	options      LedgerOptions
+	entries      []LedgerEntry
+	pending      []LedgerEntry
+	sinceCompact int
+
+	// persistMu serializes file writes. It is taken before mu.
+	persistMu sync.Mutex
+	file      *os.File
+
+	persistCh chan struct{}
+	closeCh   chan struct{}
+	doneCh    chan struct{}
+	startOnce sync.Once
+	closeOnce sync.Once
+}
+
+func NewLedger(configDir string, options LedgerOptions) (*Ledger, error) {
+	l := &Ledger{options: options, persistInterval: defaultPersistInterval}
+	configDir = strings.TrimSpace(configDir)
+	if configDir == "" {
+		return l, nil
+	}
+	l.path = filepath.Join(configDir, ledgerFilename)
+	l.persistCh = make(chan struct{}, 1)
+	l.closeCh = make(chan struct{})
+	l.doneCh = make(chan struct{})
+	if err := l.load(true); err != nil {
+		return l, err
+	}
+	return l, nil
+}
+
+// OpenLedger loads the ledger file as it is on disk, for reading while the
+// proxy is not running. It applies no retention and ignores appends; only an
+// explicit Update rewrites the file.
+func OpenLedger(configDir string) (*Ledger, error) {
+	l := &Ledger{}
+	configDir = strings.TrimSpace(configDir)
+	if configDir == "" {
+		return l, nil
+	}
+	l.path = filepath.Join(configDir, ledgerFilename)
+	if err := l.load(false); err != nil {
+		return l, err
+	}
+	return l, nil
+}
+
+func (l *Ledger) load(compact bool) error {
+	f, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), ledgerMaxLineBytes)
+	var entries []LedgerEntry
+	dropped := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry LedgerEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			// A torn final line from a crash should not discard the whole ledger.
+			dropped++
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+
+	l.persistMu.Lock()
+	defer l.persistMu.Unlock()
+	l.mu.Lock()
+	l.entries = entries
+	if !compact || (!l.pruneLocked(time.Now()) && dropped == 0) {
+		l.mu.Unlock()
+		return nil
+	}
+	snapshot := l.snapshotForRewriteLocked()
+	l.mu.Unlock()
+	return l.rewrite(snapshot)
+}
+
+// SetOptions applies reloaded retention settings and compacts if needed.
+func (l *Ledger) SetOptions(options LedgerOptions) error {
+	if l == nil {
+		return nil
+	}
+	l.persistMu.Lock()
+	defer l.persistMu.Unlock()
+	l.mu.Lock()
+	l.options = options
+	if !l.pruneLocked(time.Now()) || l.path == "" {
+		l.mu.Unlock()
+		return nil
+	}
+	snapshot := l.snapshotForRewriteLocked()
+	l.mu.Unlock()
+	return l.rewrite(snapshot)
+}
+
+// Append records a completed request. It is a no-op when the ledger is
+// disabled. The entry is visible to queries at once and reaches the file on
+// the next background write.
+func (l *Ledger) Append(entry LedgerEntry) error {
+	if l == nil {
+		return nil
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.ClientType = strings.TrimSpace(entry.ClientType)
+	entry.Provider = strings.TrimSpace(entry.Provider)
+
+	l.mu.Lock()
+	if !l.options.Enabled {
+		l.mu.Unlock()
+		return nil
+	}
+	l.entries = append(l.entries, entry)
+	// Concurrent requests can finish out of order; keep the slice sorted.
+	for i := len(l.entries) - 1; i > 0 && l.entries[i].Time.Before(l.entries[i-1].Time); i-- {
+		l.entries[i], l.entries[i-1] = l.entries[i-1], l.entries[i]
+	}
+	if l.path == "" {
+		l.pruneLocked(time.Now())
+		l.mu.Unlock()
+		return nil
+	}
+	l.pending = append(l.pending, entry)
+	l.sinceCompact++
+	l.mu.Unlock()
+
+	l.notifyPersist()
+	return nil
+}
+
+// Update calls fn for every entry matching q, ignoring paging, and rewrites
+// the ledger file when fn reports a change. It returns the number of changed
+// entries.
+func (l *Ledger) Update(q LedgerQuery, fn func(*LedgerEntry) bool) (int, error) {
+	if l == nil {
+		return 0, nil
+	}
+	l.persistMu.Lock()
+	defer l.persistMu.Unlock()
+	l.mu.Lock()
+	changed := 0
+	for i := range l.entries {
+		if q.matches(l.entries[i]) && fn(&l.entries[i]) {
+			changed++
+		}
+	}
+	if changed == 0 || l.path == "" {
+		l.mu.Unlock()
+		return changed, nil
+	}
+	snapshot := l.snapshotForRewriteLocked()
+	l.mu.Unlock()
+	return changed, l.rewrite(snapshot)
+}
+
+// Compact applies retention and the entry cap, then rewrites the ledger file.
+func (l *Ledger) Compact() error {
+	if l == nil {
+		return nil
+	}
+	l.persistMu.Lock()
+	defer l.persistMu.Unlock()
+	l.mu.Lock()
+	l.pruneLocked(time.Now())
+	if l.path == "" {
+		l.mu.Unlock()
+		return nil
+	}
+	snapshot := l.snapshotForRewriteLocked()
+	l.mu.Unlock()
+	return l.rewrite(snapshot)
+}
+
+// Flush writes queued entries to the ledger file.
+func (l *Ledger) Flush() error {
+	if l == nil || l.path == "" {
+		return nil
+	}
+	l.persistMu.Lock()
+	defer l.persistMu.Unlock()
+	return l.appendPending()
+}
+
+// Close stops the background writer and compacts the ledger so the file on
+// disk matches the retained entries.
+func (l *Ledger) Close() error {
+	if l == nil {
+		return nil
+	}
+	if l.closeCh != nil {
+		l.closeOnce.Do(func() {
+			close(l.closeCh)
+			l.startOnce.Do(func() { close(l.doneCh) })
+			<-l.doneCh
+		})
+	}
+	l.persistMu.Lock()
+	defer l.persistMu.Unlock()
+	defer l.closeFile()
+	l.mu.Lock()
+	if l.path == "" || l.sinceCompact == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	l.pruneLocked(time.Now())
+	snapshot := l.snapshotForRewriteLocked()
+	l.mu.Unlock()
+	return l.rewrite(snapshot)
+}
+
+func (l *Ledger) notifyPersist() {
+	if l.persistCh == nil {
+		return
+	}
+	l.startOnce.Do(func() {
+		go l.persistenceWorker()
+	})
+	select {
+	case l.persistCh <- struct{}{}:
+	default:
+	}
+}
+
+func (l *Ledger) persistenceWorker() {
+	defer close(l.doneCh)
+
+	var timer *time.Timer
+	var timerC <-chan time.Time
+	scheduleFlush := func() {
+		if timer != nil {
+			return
+		}
+		timer = time.NewTimer(l.persistInterval)
+		timerC = timer.C
+	}
+
+	for {
+		select {
+		case <-l.persistCh:
+			scheduleFlush()
+		case <-timerC:
+			timer = nil
+			timerC = nil
+			if err := l.persist(); err != nil {
+				scheduleFlush()
+			}
+		case <-l.closeCh:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+	}
+}
+
+// persist writes queued entries, or rewrites the whole file once
+// ledgerCompactEvery entries were appended since the last rewrite.
+func (l *Ledger) persist() error {
+	l.persistMu.Lock()
+	defer l.persistMu.Unlock()
+	l.mu.Lock()
+	if l.sinceCompact < ledgerCompactEvery {
+		l.mu.Unlock()
+		return l.appendPending()
+	}
+	l.pruneLocked(time.Now())
+	snapshot := l.snapshotForRewriteLocked()
+	l.mu.Unlock()
+	return l.rewrite(snapshot)
+}
+
+// appendPending writes queued entries through the long-lived file handle.
+// Callers hold persistMu.
+func (l *Ledger) appendPending() error {
+	l.mu.Lock()
+	entries := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			l.requeue(entries)
+			return err
+		}
+	}
+	if l.file == nil {
+		if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+			l.requeue(entries)
+			return err
+		}
+		f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			l.requeue(entries)
+			return err
+		}
+		l.file = f
+	}
+	if _, err := l.file.Write(buf.Bytes()); err != nil {
+		// The file may now end in a torn line; load skips it.
+		l.closeFile()
+		l.requeue(entries)
+		return err
+	}
+	return nil
+}
+
+func (l *Ledger) requeue(entries []LedgerEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pending = append(entries, l.pending...)
+}
+
+func (l *Ledger) closeFile() {
+	if l.file != nil {
+		_ = l.file.Close()
+		l.file = nil
+	}
+}
+
+// snapshotForRewriteLocked returns the entries a rewrite should hold. Queued
+// entries are among them, so the queue is dropped.
+func (l *Ledger) snapshotForRewriteLocked() []LedgerEntry {
+	l.pending = nil
+	l.sinceCompact = 0
+	return append([]LedgerEntry(nil), l.entries...)
+}
+
+func (l *Ledger) pruneLocked(now time.Time) bool {
+	start := 0
+	if l.options.RetentionDays > 0 {
+		cutoff := now.AddDate(0, 0, -l.options.RetentionDays)
+		start = sort.Search(len(l.entries), func(i int) bool { return !l.entries[i].Time.Before(cutoff) })
+	}
+	if l.options.MaxEntries > 0 && len(l.entries)-start > l.options.MaxEntries {
+		start = len(l.entries) - l.options.MaxEntries
+	}
+	if start == 0 {
+		return false
+	}
+	kept := make([]LedgerEntry, len(l.entries)-start)
+	copy(kept, l.entries[start:])
+	l.entries = kept
+	return true
+}
+
+// rewrite replaces the ledger file with entries. Callers hold persistMu but
+// not mu, so appends continue while the file is written.
+func (l *Ledger) rewrite(entries []LedgerEntry) error {
+	if l.path == "" {
+		return nil
+	}
+	// The open handle refers to the file being replaced.
+	l.closeFile()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			l.scheduleRewrite()
+			return err
+		}
+	}
+	if err := fsutil.AtomicWriteFile(l.path, buf.Bytes(), 0o600); err != nil {
+		l.scheduleRewrite()
+		return err
+	}
+	return nil
+}
+
+// scheduleRewrite makes the next background write retry a failed rewrite.
+func (l *Ledger) scheduleRewrite() {
+	l.mu.Lock()
+	l.sinceCompact = max(l.sinceCompact, ledgerCompactEvery)
+	l.mu.Unlock()
+	l.notifyPersist()
+}
+
+// LedgerQuery filters ledger entries. Empty fields match everything.
+type LedgerQuery struct {
+	Since      time.Time
+	Until      time.Time
+	ClientType string
+	Provider   string
+	Capability string
+	Project    string
+	// Model matches either the requested or the effective model.
+	Model string
+	// SessionKey matches either the client session ID or the sticky key.
+	SessionKey string
+	Status     int
+	// Failed keeps only unsuccessful entries when true.
+	Failed bool
+	Offset int
+	Limit  int
+}
+
+// ParseLedgerTime accepts an RFC 3339 timestamp, a date, or a look-back window
+// such as "90m", "24h" or "7d".
+func ParseLedgerTime(raw string, now time.Time) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", raw, now.Location()); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("%q is not a timestamp or duration", raw)
+		}
+		return now.AddDate(0, 0, -n), nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("%q is not a timestamp or duration", raw)
+	}
+	return now.Add(-d), nil
+}
+
+func (q LedgerQuery) matches(entry LedgerEntry) bool {
+	if !q.Since.IsZero() && entry.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !entry.Time.Before(q.Until) {
+		return false
+	}
+	if q.ClientType != "" && entry.ClientType != q.ClientType {
+		return false
+	}
+	if q.Provider != "" && entry.Provider != q.Provider {
+		return false
+	}
+	if q.Capability != "" && entry.Capability != q.Capability {
+		return false
+	}
+	if q.Model != "" && entry.RequestedModel != q.Model && entry.EffectiveModel != q.Model {
+		return false
+	}
+	if q.Project != "" && entry.Project != q.Project {
+		return false
+	}
+	if q.SessionKey != "" && entry.SessionID != q.SessionKey && entry.SessionKey != q.SessionKey {
+		return false
+	}
+	if q.Status != 0 && entry.Status != q.Status {
+		return false
+	}
+	if q.Failed && entry.Success() {
+		return false
+	}
+	return true
+}
+
+// Query returns matching entries newest first, paged by Offset and Limit,
+// together with the total number of matches.
+func (l *Ledger) Query(q LedgerQuery) ([]LedgerEntry, int) {
+	if l == nil {
+		return nil, 0
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	out := []LedgerEntry{}
+	total := 0
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		entry := l.entries[i]
+		if !q.matches(entry) {
+			continue
+		}
+		total++
+		if total <= q.Offset || (q.Limit > 0 && len(out) >= q.Limit) {
+			continue
+		}
+		out = append(out, entry)
+	}
+	return out, total
+}
+
+// LedgerGroup aggregates the entries sharing one group-by key.
+type LedgerGroup struct {
+	Key               string `json:"key"`
+	Requests          int64  `json:"requests"`
+	Failures          int64  `json:"failures"`
+	InputTokens       int64  `json:"input_tokens"`
+	OutputTokens      int64  `json:"output_tokens"`
+	TotalTokens       int64  `json:"total_tokens"`
+	ReasoningTokens   int64  `json:"reasoning_tokens"`
+	CostMicros        int64  `json:"cost_micros"`
+	HasCost           bool   `json:"has_cost"`
+	AvgTTFBMillis     int64  `json:"avg_ttfb_ms"`
+	AvgDurationMillis int64  `json:"avg_duration_ms"`
+
+	ttfbSamples int64
+	ttfbSum     int64
+	durationSum int64
+}
+
+var ledgerGroupKeys = map[string]func(LedgerEntry) string{
+	"client_type": func(e LedgerEntry) string { return e.ClientType },
+	"provider":    func(e LedgerEntry) string { return e.Provider },
+	"capability":  func(e LedgerEntry) string { return e.Capability },
+	"model": func(e LedgerEntry) string {
+		if e.EffectiveModel != "" {
+			return e.EffectiveModel
+		}
+		return e.RequestedModel
+	},
+	"key":     func(e LedgerEntry) string { return e.KeyFingerprint },
+	"project": func(e LedgerEntry) string { return e.Project },
+	"session": LedgerEntry.Session,
+	"status":  func(e LedgerEntry) string { return strconv.Itoa(e.Status) },
+	"day":     func(e LedgerEntry) string { return usageDayBucket(e.Time) },
+	"hour":    func(e LedgerEntry) string { return usageHourBucket(e.Time) },
+}
+
+// LedgerGroupByFields lists the supported group-by field names.
+func LedgerGroupByFields() []string {
+	fields := make([]string, 0, len(ledgerGroupKeys))
+	for field := range ledgerGroupKeys {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Aggregate groups matching entries by groupBy, ordered by cost, then request
+// count. Offset and Limit are ignored.
+func (l *Ledger) Aggregate(q LedgerQuery, groupBy string) ([]LedgerGroup, error) {
+	keyFn, ok := ledgerGroupKeys[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported group_by %q (supported: %s)", groupBy, strings.Join(LedgerGroupByFields(), ", "))
+	}
+	if l == nil {
+		return []LedgerGroup{}, nil
+	}
+
+	l.mu.RLock()
+	groups := make(map[string]*LedgerGroup)
+	for _, entry := range l.entries {
+		if !q.matches(entry) {
+			continue
+		}
+		key := keyFn(entry)
+		group := groups[key]
+		if group == nil {
+			group = &LedgerGroup{Key: key}
+			groups[key] = group
+		}
+		group.Requests++
+		if !entry.Success() {
+			group.Failures++
+		}
+		group.InputTokens += entry.InputTokens
+		group.OutputTokens += entry.OutputTokens
+		group.TotalTokens += entry.TotalTokens
+		group.ReasoningTokens += entry.ReasoningTokens
+		if entry.HasCost {
+			group.CostMicros += entry.CostMicros
+			group.HasCost = true
+		}
+		if entry.TTFBMillis > 0 {
+			group.ttfbSamples++
+			group.ttfbSum += entry.TTFBMillis
+		}
+		group.durationSum += entry.DurationMillis
+	}
+	l.mu.RUnlock()
+
+	out := make([]LedgerGroup, 0, len(groups))
+	for _, group := range groups {
+		if group.ttfbSamples > 0 {
+			group.AvgTTFBMillis = group.ttfbSum / group.ttfbSamples
+		}
+		group.AvgDurationMillis = group.durationSum / group.Requests
+		out = append(out, *group)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CostMicros != out[j].CostMicros {
+			return out[i].CostMicros > out[j].CostMicros
+		}
+		if out[i].Requests != out[j].Requests {
+			return out[i].Requests > out[j].Requests
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out, nil
+}
@@ -0,0 +1,304 @@
+package telemetry
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLedgerAppendQueryAndReload(t *testing.T) {
+	dir := t.TempDir()
+	ledger, err := NewLedger(dir, LedgerOptions{Enabled: true})
+	if err != nil {
+		t.Fatalf("NewLedger: %v", err)
+	}
+	t.Cleanup(func() { _ = ledger.Close() })
+
+	now := time.Now().UTC().Truncate(time.Second)
+	entries := []LedgerEntry{
+		{Time: now.Add(-3 * time.Minute), ClientType: "openai", Provider: "p1", RequestedModel: "gpt-5", Status: 200, InputTokens: 10, OutputTokens: 5, TotalTokens: 15},
+		{Time: now.Add(-1 * time.Minute), ClientType: "openai", Provider: "p2", RequestedModel: "gpt-5", EffectiveModel: "gpt-5-mini", Status: 502, Result: "incomplete_response"},
+		// Appended out of order: must still be returned newest first.
+		{Time: now.Add(-2 * time.Minute), ClientType: "claude", Provider: "p1", RequestedModel: "sonnet", Status: 200, Result: "completed"},
+	}
+	for _, entry := range entries {
+		if err := ledger.Append(entry); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	got, total := ledger.Query(LedgerQuery{})
+	if total != 3 || len(got) != 3 {
+		t.Fatalf("Query all: total=%d len=%d", total, len(got))
+	}
+	if got[0].Provider != "p2" || got[1].ClientType != "claude" || got[2].Provider != "p1" {
+		t.Fatalf("expected newest first, got %#v", got)
+	}
+
+	got, total = ledger.Query(LedgerQuery{Model: "gpt-5-mini"})
+	if total != 1 || got[0].Provider != "p2" {
+		t.Fatalf("Query by effective model: total=%d got=%#v", total, got)
+	}
+	got, total = ledger.Query(LedgerQuery{Failed: true})
+	if total != 1 || got[0].Status != 502 {
+		t.Fatalf("Query failed: total=%d got=%#v", total, got)
+	}
+	got, total = ledger.Query(LedgerQuery{ClientType: "openai", Offset: 1, Limit: 1})
+	if total != 2 || len(got) != 1 || got[0].Provider != "p1" {
+		t.Fatalf("Query paged: total=%d got=%#v", total, got)
+	}
+
+	// Appends reach the file through the background writer.
+	path := filepath.Join(dir, ledgerFilename)
+	if err := ledger.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	// A torn trailing line must not discard the rest of the ledger.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	if _, err := f.WriteString(`{"time":"2026-`); err != nil {
+		t.Fatalf("write torn line: %v", err)
+	}
+	_ = f.Close()
+
+	reloaded, err := NewLedger(dir, LedgerOptions{Enabled: true})
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	got, total = reloaded.Query(LedgerQuery{})
+	if total != 3 || got[0].Provider != "p2" || got[2].TotalTokens != 15 {
+		t.Fatalf("reloaded entries: total=%d got=%#v", total, got)
+	}
+}
+
+func TestLedgerDisabledAndPruning(t *testing.T) {
+	dir := t.TempDir()
+	ledger, err := NewLedger(dir, LedgerOptions{})
+	if err != nil {
+		t.Fatalf("NewLedger: %v", err)
+	}
+	if err := ledger.Append(LedgerEntry{ClientType: "openai", Status: 200}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if _, total := ledger.Query(LedgerQuery{}); total != 0 {
+		t.Fatalf("disabled ledger recorded %d entries", total)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ledgerFilename)); !os.IsNotExist(err) {
+		t.Fatalf("disabled ledger should not create a file: %v", err)
+	}
+
+	if err := ledger.SetOptions(LedgerOptions{Enabled: true, RetentionDays: 1, MaxEntries: 2}); err != nil {
+		t.Fatalf("SetOptions: %v", err)
+	}
+	now := time.Now()
+	for i, ts := range []time.Time{now.AddDate(0, 0, -2), now.Add(-3 * time.Hour), now.Add(-2 * time.Hour), now.Add(-time.Hour)} {
+		if err := ledger.Append(LedgerEntry{Time: ts, ClientType: "openai", Status: 200, Attempts: i + 1}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	if err := ledger.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+
+	got, total := ledger.Query(LedgerQuery{})
+	if total != 2 || got[0].Attempts != 4 || got[1].Attempts != 3 {
+		t.Fatalf("expected the two newest entries, got total=%d %#v", total, got)
+	}
+	reloaded, err := NewLedger(dir, LedgerOptions{Enabled: true})
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if _, total := reloaded.Query(LedgerQuery{}); total != 2 {
+		t.Fatalf("compacted file should hold 2 entries, got %d", total)
+	}
+}
+
+func TestLedgerAggregate(t *testing.T) {
+	ledger, err := NewLedger("", LedgerOptions{Enabled: true})
+	if err != nil {
+		t.Fatalf("NewLedger: %v", err)
+	}
+	now := time.Now()
+	for _, entry := range []LedgerEntry{
+		{Time: now, ClientType: "openai", RequestedModel: "gpt-5", Status: 200, TotalTokens: 10, CostMicros: 100, HasCost: true, TTFBMillis: 100, DurationMillis: 400},
+		{Time: now, ClientType: "openai", RequestedModel: "gpt-5", Status: 429, TotalTokens: 0, DurationMillis: 200},
+		{Time: now, ClientType: "openai", RequestedModel: "gpt-5", EffectiveModel: "gpt-5-mini", Status: 200, TotalTokens: 30, CostMicros: 500, HasCost: true, TTFBMillis: 50, DurationMillis: 100},
+	} {
+		if err := ledger.Append(entry); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	groups, err := ledger.Aggregate(LedgerQuery{}, "model")
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("groups = %#v", groups)
+	}
+	if groups[0].Key != "gpt-5-mini" || groups[0].CostMicros != 500 {
+		t.Fatalf("expected highest-cost group first, got %#v", groups[0])
+	}
+	gpt5 := groups[1]
+	if gpt5.Requests != 2 || gpt5.Failures != 1 || gpt5.TotalTokens != 10 || gpt5.AvgTTFBMillis != 100 || gpt5.AvgDurationMillis != 300 {
+		t.Fatalf("gpt-5 group = %#v", gpt5)
+	}
+
+	if _, err := ledger.Aggregate(LedgerQuery{}, "bogus"); err == nil {
+		t.Fatalf("expected unsupported group_by error")
+	}
+}
+
+func TestLedgerAggregateByProjectAndSession(t *testing.T) {
+	ledger, err := NewLedger("", LedgerOptions{Enabled: true})
+	if err != nil {
+		t.Fatalf("NewLedger: %v", err)
+	}
+	now := time.Now()
+	for _, entry := range []LedgerEntry{
+		{Time: now, ClientType: "claude", Status: 200, Project: "web", SessionID: "s1", SessionKey: "sticky-a", CostMicros: 300, HasCost: true},
+		{Time: now, ClientType: "claude", Status: 200, Project: "web", SessionID: "s1", SessionKey: "sticky-b", CostMicros: 200, HasCost: true},
+		{Time: now, ClientType: "openai", Status: 200, Project: "cli", SessionKey: "sticky-c", CostMicros: 100, HasCost: true},
+	} {
+		if err := ledger.Append(entry); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	sessions, err := ledger.Aggregate(LedgerQuery{}, "session")
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].Key != "s1" || sessions[0].CostMicros != 500 || sessions[1].Key != "sticky-c" {
+		t.Fatalf("sessions = %#v", sessions)
+	}
+	projects, err := ledger.Aggregate(LedgerQuery{SessionKey: "s1"}, "project")
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+	if len(projects) != 1 || projects[0].Key != "web" || projects[0].Requests != 2 {
+		t.Fatalf("projects = %#v", projects)
+	}
+	if entries, total := ledger.Query(LedgerQuery{Project: "cli"}); total != 1 || entries[0].SessionKey != "sticky-c" {
+		t.Fatalf("project query = %#v", entries)
+	}
+}
+
+func TestLedgerUpdate(t *testing.T) {
+	dir := t.TempDir()
+	ledger, err := NewLedger(dir, LedgerOptions{Enabled: true})
+	if err != nil {
+		t.Fatalf("NewLedger: %v", err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, entry := range []LedgerEntry{
+		{Time: now.Add(-2 * time.Minute), ClientType: "openai", Provider: "p1", Status: 200},
+		{Time: now.Add(-time.Minute), ClientType: "openai", Provider: "p2", Status: 200},
+	} {
+		if err := ledger.Append(entry); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	changed, err := ledger.Update(LedgerQuery{Provider: "p2", Limit: 1, Offset: 5}, func(entry *LedgerEntry) bool {
+		entry.CostMicros = 42
+		entry.HasCost = true
+		return true
+	})
+	if err != nil || changed != 1 {
+		t.Fatalf("Update: changed=%d err=%v", changed, err)
+	}
+
+	reloaded, err := NewLedger(dir, LedgerOptions{Enabled: true})
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	got, _ := reloaded.Query(LedgerQuery{})
+	if len(got) != 2 || got[0].CostMicros != 42 || !got[0].HasCost || got[1].HasCost {
+		t.Fatalf("reloaded entries = %#v", got)
+	}
+}
+
+func TestLedgerWritesInBackgroundAndCompacts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ledgerFilename)
+	ledger, err := NewLedger(dir, LedgerOptions{Enabled: true, MaxEntries: 10})
+	if err != nil {
+		t.Fatalf("NewLedger: %v", err)
+	}
+	ledger.persistInterval = 10 * time.Millisecond
+	t.Cleanup(func() { _ = ledger.Close() })
+
+	now := time.Now()
+	if err := ledger.Append(LedgerEntry{Time: now, ClientType: "openai", Status: 200}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	waitForLedgerLines(t, path, 1)
+
+	for i := 0; i < ledgerCompactEvery; i++ {
+		if err := ledger.Append(LedgerEntry{Time: now.Add(time.Duration(i+1) * time.Millisecond), ClientType: "openai", Status: 200, Attempts: i}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	// The background rewrite applies the entry cap in memory and on disk.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, total := ledger.Query(LedgerQuery{}); total == 10 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ledger was not compacted")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	waitForLedgerLines(t, path, 10)
+}
+
+func TestOpenLedgerLeavesFileUntouched(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ledgerFilename)
+	old := time.Now().AddDate(-1, 0, 0).UTC().Format(time.RFC3339)
+	data := `{"time":"` + old + `","client_type":"openai","status":200}` + "\n" + `{"time":"2026-`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write ledger: %v", err)
+	}
+
+	ledger, err := OpenLedger(dir)
+	if err != nil {
+		t.Fatalf("OpenLedger: %v", err)
+	}
+	if _, total := ledger.Query(LedgerQuery{}); total != 1 {
+		t.Fatalf("entries = %d", total)
+	}
+	if err := ledger.Append(LedgerEntry{ClientType: "openai", Status: 200}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := ledger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil || string(got) != data {
+		t.Fatalf("ledger file changed: %q err=%v", got, err)
+	}
+}
+
+func waitForLedgerLines(t *testing.T, path string, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(path)
+		got := bytes.Count(data, []byte("\n"))
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ledger file holds %d lines, want %d", got, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -3,18 +3,23 @@ package telemetry
 import (
 	"encoding/json"
 	"fmt"
-	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/lansespirit/Clipal/internal/fsutil"
 )
 
 const (
 	storeFilename          = "usage.json"
-	storeVersion           = 3
+	storeArchiveDir        = "usage-archive"
+	storeVersion           = 4
 	defaultPersistInterval = 3 * time.Second
+	// hourlyCostRetention bounds HourlyCosts; older spend stays in DailyCosts.
+	hourlyCostRetention = 7 * 24 * time.Hour
 )
 
 type UsageDelta struct {
@@ -30,6 +35,12 @@ type UsageSnapshot struct {
 	ThoughtsTokens  int64          `json:"thoughts_tokens,omitempty"`
 	CostMicros      int64          `json:"cost_micros,omitempty"`
 	HasCost         bool           `json:"has_cost,omitempty"`
+	// CostSource records where CostMicros came from: "upstream", "catalog"
+	// or "builtin".
+	CostSource string `json:"cost_source,omitempty"`
+	// Estimated marks token counts approximated locally because the upstream
+	// reported no usage.
+	Estimated bool `json:"estimated,omitempty"`
 }
 
 type ProviderRef struct {
@@ -40,6 +51,13 @@ type ProviderRef struct {
 type RecordOptions struct {
 	CountRequest bool
 	CountSuccess bool
+	// Recovered marks a request completed by resuming an interrupted stream.
+	Recovered bool
+	// Model, Capability and KeyFingerprint select the breakdown buckets the
+	// usage is also counted in. Empty values skip that breakdown.
+	Model          string
+	Capability     string
+	KeyFingerprint string
 }
 
 func (u UsageDelta) normalized() UsageDelta {
@@ -55,6 +73,7 @@ func (u UsageDelta) normalized() UsageDelta {
 type ProviderUsage struct {
 	RequestCount    int64                      `json:"request_count,omitempty"`
 	SuccessCount    int64                      `json:"success_count,omitempty"`
+	RecoveredCount  int64                      `json:"recovered_count,omitempty"`
 	InputTokens     int64                      `json:"input_tokens,omitempty"`
 	OutputTokens    int64                      `json:"output_tokens,omitempty"`
 	TotalTokens     int64                      `json:"total_tokens,omitempty"`
@@ -64,7 +83,38 @@ type ProviderUsage struct {
 	LastUsedAt      time.Time                  `json:"last_used_at,omitempty"`
 	Usage           map[string]any             `json:"usage,omitempty"`
 	DailyCosts      map[string]DailyCostBucket `json:"daily_costs,omitempty"`
+	HourlyCosts     map[string]DailyCostBucket `json:"hourly_costs,omitempty"`
 	HasCost         bool                       `json:"has_cost,omitempty"`
+	Models          map[string]UsageCounters   `json:"models,omitempty"`
+	Capabilities    map[string]UsageCounters   `json:"capabilities,omitempty"`
+	Keys            map[string]UsageCounters   `json:"keys,omitempty"`
+}
+
+// UsageCounters is the per-model, per-capability or per-key share of a
+// provider's usage.
+type UsageCounters struct {
+	RequestCount    int64 `json:"request_count,omitempty"`
+	SuccessCount    int64 `json:"success_count,omitempty"`
+	InputTokens     int64 `json:"input_tokens,omitempty"`
+	OutputTokens    int64 `json:"output_tokens,omitempty"`
+	ReasoningTokens int64 `json:"reasoning_tokens,omitempty"`
+	ThoughtsTokens  int64 `json:"thoughts_tokens,omitempty"`
+	TotalTokens     int64 `json:"total_tokens,omitempty"`
+	CostMicros      int64 `json:"cost_micros,omitempty"`
+	HasCost         bool  `json:"has_cost,omitempty"`
+}
+
+func (c UsageCounters) add(other UsageCounters) UsageCounters {
+	c.RequestCount += other.RequestCount
+	c.SuccessCount += other.SuccessCount
+	c.InputTokens += other.InputTokens
+	c.OutputTokens += other.OutputTokens
+	c.ReasoningTokens += other.ReasoningTokens
+	c.ThoughtsTokens += other.ThoughtsTokens
+	c.TotalTokens += other.TotalTokens
+	c.CostMicros += other.CostMicros
+	c.HasCost = c.HasCost || other.HasCost
+	return c
 }
 
 type DailyCostBucket struct {
@@ -77,11 +127,19 @@ type clientUsage struct {
 }
 
 type storeState struct {
-	Version   int                    `json:"version"`
-	UpdatedAt time.Time              `json:"updated_at,omitempty"`
-	Clients   map[string]clientUsage `json:"clients,omitempty"`
-}
-
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	// PeriodStart is when the counters were last reset; zero means never.
+	PeriodStart time.Time `json:"period_start,omitempty"`
+	// JournalSeq is the last journal record folded into this snapshot.
+	JournalSeq uint64                 `json:"journal_seq,omitempty"`
+	Clients    map[string]clientUsage `json:"clients,omitempty"`
+}
+
+// Store keeps cumulative usage per provider. Record calls are appended to
+// usage.journal every persistInterval; the journal is folded into the
+// usage.json snapshot once it grows past journalCompactEvery records, on
+// Close, and after edits such as renames and deletes.
 type Store struct {
 	path            string
 	persistInterval time.Duration
@@ -91,6 +149,13 @@ type Store struct {
 	dirty       bool
 	lastPersist time.Time
 	revision    uint64
+	seq         uint64
+	pending     []journalRecord
+
+	// persistMu serializes journal appends with snapshot writes.
+	persistMu    sync.Mutex
+	journalCount int
+	journalTorn  bool
 
 	persistCh chan struct{}
 	closeCh   chan struct{}
@@ -125,22 +190,17 @@ func NewStore(configDir string) (*Store, error) {
 }
 
 func (s *Store) load() error {
+	state := storeState{Version: storeVersion}
+	migrated := false
 	data, err := os.ReadFile(s.path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
-	if len(data) == 0 {
-		return nil
-	}
-	var state storeState
-	if err := json.Unmarshal(data, &state); err != nil {
-		return err
-	}
-	if state.Version == 0 {
-		state.Version = storeVersion
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return err
+		}
+		migrated = migrateStoreState(&state)
 	}
 	if state.Clients == nil {
 		state.Clients = map[string]clientUsage{}
@@ -151,10 +211,42 @@ func (s *Store) load() error {
 			state.Clients[client] = usage
 		}
 	}
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+	seq, replayed, err := s.replayJournal(&state)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
 	s.state = state
+	s.seq = seq
+	if migrated || replayed > 0 {
+		// Persist the upgraded version or the replayed records on the next
+		// snapshot.
+		s.dirty = true
+		s.revision++
+	}
+	s.mu.Unlock()
 	return nil
 }
 
+// migrateStoreState upgrades state loaded from an older store version and
+// reports whether anything changed.
+func migrateStoreState(state *storeState) bool {
+	switch {
+	case state.Version == 0:
+		state.Version = storeVersion
+		return false
+	case state.Version >= storeVersion:
+		return false
+	}
+	// Version 3 added nothing that can be split after the fact: provider
+	// totals and daily costs carry over, while the model, capability and key
+	// breakdowns and the hourly costs start empty.
+	state.Version = storeVersion
+	return true
+}
+
 func (s *Store) RecordUsage(clientType string, provider string, snapshot UsageSnapshot, when time.Time) error {
 	return s.Record(clientType, provider, snapshot, when, RecordOptions{
 		CountRequest: true,
@@ -169,50 +261,34 @@ func (s *Store) Record(clientType string, provider string, snapshot UsageSnapsho
 		return nil
 	}
 
-	delta := snapshot.normalized()
 	if when.IsZero() {
 		when = time.Now()
 	}
-
-	s.mu.Lock()
-	client := s.state.Clients[clientType]
-	if client.Providers == nil {
-		client.Providers = map[string]ProviderUsage{}
-	}
-	entry := client.Providers[provider]
-	if options.CountRequest {
-		entry.RequestCount++
-	}
-	if options.CountSuccess {
-		entry.SuccessCount++
-	}
-	entry.InputTokens += delta.InputTokens
-	entry.OutputTokens += delta.OutputTokens
-	entry.TotalTokens += delta.TotalTokens
-	entry.ReasoningTokens += snapshot.ReasoningTokens
-	entry.ThoughtsTokens += snapshot.ThoughtsTokens
-	if snapshot.HasCost {
-		entry.TotalCostMicros += snapshot.CostMicros
-		entry.HasCost = true
-		if entry.DailyCosts == nil {
-			entry.DailyCosts = make(map[string]DailyCostBucket)
-		}
-		day := usageDayBucket(when)
-		bucket := entry.DailyCosts[day]
-		bucket.CostMicros += snapshot.CostMicros
-		bucket.HasCost = true
-		entry.DailyCosts[day] = bucket
+	rec := journalRecord{
+		Client:     clientType,
+		Provider:   provider,
+		Time:       when,
+		Usage:      snapshot,
+		Request:    options.CountRequest,
+		Success:    options.CountSuccess,
+		Recovered:  options.Recovered,
+		Model:      options.Model,
+		Capability: options.Capability,
+		Key:        options.KeyFingerprint,
 	}
-	entry.LastUsedAt = when
-	if snapshot.Usage != nil {
-		entry.Usage = cloneMap(snapshot.Usage)
+	if rec.Usage.Usage != nil {
+		rec.Usage.Usage = cloneMap(rec.Usage.Usage)
 	}
-	client.Providers[provider] = entry
-	s.state.Clients[clientType] = client
-	s.state.Version = storeVersion
-	s.state.UpdatedAt = when
+
+	s.mu.Lock()
+	applyRecord(&s.state, rec)
 	s.dirty = true
 	s.revision++
+	if s.path != "" {
+		s.seq++
+		rec.Seq = s.seq
+		s.pending = append(s.pending, rec)
+	}
 	s.mu.Unlock()
 
 	s.notifyPersist()
@@ -346,10 +422,82 @@ func (s *Store) DeleteProvidersWithRollback(refs []ProviderRef) (func() error, e
 	}, nil
 }
 
+// PeriodStart returns when the counters were last reset, or the zero time.
+func (s *Store) PeriodStart() time.Time {
+	if s == nil {
+		return time.Time{}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state.PeriodStart
+}
+
+// ReadPeriodStart returns when the usage counters in configDir were last
+// reset, reading usage.json without loading or changing the store.
+func ReadPeriodStart(configDir string) (time.Time, error) {
+	data, err := os.ReadFile(filepath.Join(strings.TrimSpace(configDir), storeFilename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	var state struct {
+		PeriodStart time.Time `json:"period_start"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return time.Time{}, err
+	}
+	return state.PeriodStart, nil
+}
+
+// Reset starts a new usage period. The previous counters are written to
+// usage-archive/ next to usage.json before they are cleared; the returned path
+// is empty for an in-memory store.
+func (s *Store) Reset(now time.Time) (string, error) {
+	if s == nil {
+		return "", nil
+	}
+	s.mu.Lock()
+	archivePath := ""
+	if strings.TrimSpace(s.path) != "" {
+		archived := cloneState(s.state)
+		archived.UpdatedAt = now
+		data, err := json.MarshalIndent(archived, "", "  ")
+		if err != nil {
+			s.mu.Unlock()
+			return "", err
+		}
+		archivePath = filepath.Join(filepath.Dir(s.path), storeArchiveDir, "usage-"+now.UTC().Format("20060102T150405Z")+".json")
+		if err := fsutil.AtomicWriteFile(archivePath, append(data, '\n'), 0o600); err != nil {
+			s.mu.Unlock()
+			return "", err
+		}
+	}
+	s.state = storeState{
+		Version:     storeVersion,
+		UpdatedAt:   now,
+		PeriodStart: now,
+		Clients:     map[string]clientUsage{},
+	}
+	s.dirty = true
+	s.revision++
+	s.mu.Unlock()
+
+	if archivePath == "" {
+		return "", nil
+	}
+	return archivePath, s.Flush()
+}
+
+// Flush writes a full usage.json snapshot when anything changed since the
+// last one and empties the journal it supersedes.
 func (s *Store) Flush() error {
 	if s == nil || strings.TrimSpace(s.path) == "" {
 		return nil
 	}
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
 
 	for {
 		s.mu.Lock()
@@ -358,6 +506,7 @@ func (s *Store) Flush() error {
 			return nil
 		}
 		state := cloneState(s.state)
+		state.JournalSeq = s.seq
 		revision := s.revision
 		s.mu.Unlock()
 
@@ -370,12 +519,18 @@ func (s *Store) Flush() error {
 			return err
 		}
 		data = append(data, '\n')
-		if err := atomicWriteFile(s.path, data, 0o600); err != nil {
+		if err := fsutil.AtomicWriteFile(s.path, data, 0o600); err != nil {
+			return err
+		}
+		// Every record on disk predates the snapshot: appends wait for
+		// persistMu, and the snapshot was cloned after the last one.
+		if err := s.truncateJournal(); err != nil {
 			return err
 		}
 
 		now := time.Now()
 		s.mu.Lock()
+		s.pending = dropJournaledRecords(s.pending, state.JournalSeq)
 		s.lastPersist = now
 		if s.revision == revision {
 			s.dirty = false
@@ -441,7 +596,7 @@ func (s *Store) persistenceWorker() {
 	}
 	scheduleFlush := func() {
 		if s.persistInterval <= 0 {
-			_ = s.Flush()
+			_ = s.persist()
 			return
 		}
 		if timer != nil {
@@ -458,7 +613,7 @@ func (s *Store) persistenceWorker() {
 		case <-timerC:
 			timer = nil
 			timerC = nil
-			if err := s.Flush(); err != nil {
+			if err := s.persist(); err != nil {
 				scheduleFlush()
 			}
 		case <-s.closeCh:
@@ -468,11 +623,38 @@ func (s *Store) persistenceWorker() {
 	}
 }
 
+// persist appends pending records to the journal and folds it into a
+// snapshot once it holds journalCompactEvery records.
+func (s *Store) persist() error {
+	s.persistMu.Lock()
+	count, err := s.appendJournal()
+	s.persistMu.Unlock()
+	if err != nil {
+		return err
+	}
+	if count >= journalCompactEvery {
+		return s.Flush()
+	}
+	return nil
+}
+
+func dropJournaledRecords(records []journalRecord, seq uint64) []journalRecord {
+	i := 0
+	for i < len(records) && records[i].Seq <= seq {
+		i++
+	}
+	if i == len(records) {
+		return nil
+	}
+	return append([]journalRecord(nil), records[i:]...)
+}
+
 func cloneState(state storeState) storeState {
 	out := storeState{
-		Version:   state.Version,
-		UpdatedAt: state.UpdatedAt,
-		Clients:   make(map[string]clientUsage, len(state.Clients)),
+		Version:     state.Version,
+		UpdatedAt:   state.UpdatedAt,
+		PeriodStart: state.PeriodStart,
+		Clients:     make(map[string]clientUsage, len(state.Clients)),
 	}
 	for clientName, client := range state.Clients {
 		nextClient := clientUsage{
@@ -588,13 +770,11 @@ func cloneProviderUsage(usage ProviderUsage) ProviderUsage {
 	if usage.Usage != nil {
 		usage.Usage = cloneMap(usage.Usage)
 	}
-	if usage.DailyCosts != nil {
-		cloned := make(map[string]DailyCostBucket, len(usage.DailyCosts))
-		for key, bucket := range usage.DailyCosts {
-			cloned[key] = bucket
-		}
-		usage.DailyCosts = cloned
-	}
+	usage.DailyCosts = maps.Clone(usage.DailyCosts)
+	usage.HourlyCosts = maps.Clone(usage.HourlyCosts)
+	usage.Models = maps.Clone(usage.Models)
+	usage.Capabilities = maps.Clone(usage.Capabilities)
+	usage.Keys = maps.Clone(usage.Keys)
 	return usage
 }
 
@@ -602,6 +782,7 @@ func mergeProviderUsage(left ProviderUsage, right ProviderUsage) ProviderUsage {
 	out := cloneProviderUsage(left)
 	out.RequestCount += right.RequestCount
 	out.SuccessCount += right.SuccessCount
+	out.RecoveredCount += right.RecoveredCount
 	out.InputTokens += right.InputTokens
 	out.OutputTokens += right.OutputTokens
 	out.TotalTokens += right.TotalTokens
@@ -609,16 +790,16 @@ func mergeProviderUsage(left ProviderUsage, right ProviderUsage) ProviderUsage {
 	out.ThoughtsTokens += right.ThoughtsTokens
 	out.TotalCostMicros += right.TotalCostMicros
 	out.HasCost = out.HasCost || right.HasCost
-	if len(right.DailyCosts) > 0 {
-		if out.DailyCosts == nil {
-			out.DailyCosts = make(map[string]DailyCostBucket, len(right.DailyCosts))
-		}
-		for day, bucket := range right.DailyCosts {
-			current := out.DailyCosts[day]
-			current.CostMicros += bucket.CostMicros
-			current.HasCost = current.HasCost || bucket.HasCost
-			out.DailyCosts[day] = current
-		}
+	out.DailyCosts = mergeCostBuckets(out.DailyCosts, right.DailyCosts)
+	out.HourlyCosts = mergeCostBuckets(out.HourlyCosts, right.HourlyCosts)
+	for key, counters := range right.Models {
+		out.Models = addUsageCounters(out.Models, key, counters)
+	}
+	for key, counters := range right.Capabilities {
+		out.Capabilities = addUsageCounters(out.Capabilities, key, counters)
+	}
+	for key, counters := range right.Keys {
+		out.Keys = addUsageCounters(out.Keys, key, counters)
 	}
 	if right.LastUsedAt.After(out.LastUsedAt) {
 		out.LastUsedAt = right.LastUsedAt
@@ -632,10 +813,63 @@ func mergeProviderUsage(left ProviderUsage, right ProviderUsage) ProviderUsage {
 	return out
 }
 
+func mergeCostBuckets(out map[string]DailyCostBucket, in map[string]DailyCostBucket) map[string]DailyCostBucket {
+	if len(in) == 0 {
+		return out
+	}
+	if out == nil {
+		out = make(map[string]DailyCostBucket, len(in))
+	}
+	for key, bucket := range in {
+		current := out[key]
+		current.CostMicros += bucket.CostMicros
+		current.HasCost = current.HasCost || bucket.HasCost
+		out[key] = current
+	}
+	return out
+}
+
+func addCostBucket(buckets map[string]DailyCostBucket, key string, costMicros int64) map[string]DailyCostBucket {
+	if buckets == nil {
+		buckets = make(map[string]DailyCostBucket)
+	}
+	bucket := buckets[key]
+	bucket.CostMicros += costMicros
+	bucket.HasCost = true
+	buckets[key] = bucket
+	return buckets
+}
+
+func pruneHourlyCosts(buckets map[string]DailyCostBucket, now time.Time) {
+	cutoff := usageHourBucket(now.Add(-hourlyCostRetention))
+	for key := range buckets {
+		// Hour keys sort lexically in time order.
+		if key < cutoff {
+			delete(buckets, key)
+		}
+	}
+}
+
+func addUsageCounters(buckets map[string]UsageCounters, key string, counters UsageCounters) map[string]UsageCounters {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return buckets
+	}
+	if buckets == nil {
+		buckets = make(map[string]UsageCounters)
+	}
+	buckets[key] = buckets[key].add(counters)
+	return buckets
+}
+
 func usageDayBucket(when time.Time) string {
 	return when.Format("2006-01-02")
 }
 
+func usageHourBucket(when time.Time) string {
+	return when.Format("2006-01-02T15")
+}
+
 func cloneMap(in map[string]any) map[string]any {
 	if in == nil {
 		return nil
@@ -650,41 +884,3 @@ func cloneMap(in map[string]any) map[string]any {
 	}
 	return out
 }
-
-func atomicWriteFile(path string, data []byte, perm fs.FileMode) error {
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0o700); err != nil {
-		return err
-	}
-
-	f, err := os.CreateTemp(dir, ".clipal-*")
-	if err != nil {
-		return err
-	}
-	tmp := f.Name()
-	success := false
-	defer func() {
-		_ = f.Close()
-		if !success {
-			_ = os.Remove(tmp)
-		}
-	}()
-
-	if err := f.Chmod(perm); err != nil {
-		return err
-	}
-	if _, err := f.Write(data); err != nil {
-		return err
-	}
-	if err := f.Sync(); err != nil {
-		return err
-	}
-	if err := f.Close(); err != nil {
-		return err
-	}
-	if err := os.Rename(tmp, path); err != nil {
-		return err
-	}
-	success = true
-	return nil
-}
@@ -200,6 +200,149 @@ func TestStoreRecordCanSkipSuccessCount(t *testing.T) {
 	}
 }
 
+func TestStoreRecordCountsRecoveredRequests(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	now := time.Date(2026, 4, 8, 12, 0, 0, 0, time.UTC)
+	for _, recovered := range []bool{true, false} {
+		if err := store.Record("claude", "p2", UsageSnapshot{}, now, RecordOptions{
+			CountRequest: true,
+			CountSuccess: true,
+			Recovered:    recovered,
+		}); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	got, ok := store.ProviderSnapshot("claude", "p2")
+	if !ok {
+		t.Fatalf("ProviderSnapshot missing")
+	}
+	if got.RequestCount != 2 || got.RecoveredCount != 1 {
+		t.Fatalf("snapshot = %#v", got)
+	}
+}
+
+func TestStoreRecordTracksBreakdownsAndHourlyCosts(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	now := time.Date(2026, 4, 8, 12, 30, 0, 0, time.UTC)
+	record := func(provider, model, key string, when time.Time, cost int64) {
+		t.Helper()
+		if err := store.Record("openai", provider, UsageSnapshot{
+			UsageDelta:      UsageDelta{InputTokens: 10, OutputTokens: 5},
+			ReasoningTokens: 2,
+			CostMicros:      cost,
+			HasCost:         true,
+		}, when, RecordOptions{
+			CountRequest:   true,
+			CountSuccess:   true,
+			Model:          model,
+			Capability:     "openai_responses",
+			KeyFingerprint: key,
+		}); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	record("p1", "gpt-5", "key-a", now.AddDate(0, 0, -8), 50)
+	record("p1", "gpt-5", "key-a", now, 100)
+	record("p1", "gpt-5-mini", "key-b", now.Add(10*time.Minute), 20)
+	record("p2", "gpt-5", "", now, 1000)
+
+	if err := store.RenameProvider("openai", "p2", "p1"); err != nil {
+		t.Fatalf("RenameProvider: %v", err)
+	}
+	reloaded, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	got, ok := reloaded.ProviderSnapshot("openai", "p1")
+	if !ok {
+		t.Fatalf("ProviderSnapshot missing")
+	}
+
+	if model := got.Models["gpt-5"]; model.RequestCount != 3 || model.InputTokens != 30 || model.ReasoningTokens != 6 || model.CostMicros != 1150 || !model.HasCost {
+		t.Fatalf("gpt-5 breakdown = %#v", model)
+	}
+	if model := got.Models["gpt-5-mini"]; model.RequestCount != 1 || model.TotalTokens != 15 {
+		t.Fatalf("gpt-5-mini breakdown = %#v", model)
+	}
+	if capability := got.Capabilities["openai_responses"]; capability.RequestCount != 4 || capability.SuccessCount != 4 {
+		t.Fatalf("capability breakdown = %#v", capability)
+	}
+	if len(got.Keys) != 2 || got.Keys["key-a"].RequestCount != 2 || got.Keys["key-b"].CostMicros != 20 {
+		t.Fatalf("key breakdown = %#v", got.Keys)
+	}
+	if bucket := got.HourlyCosts["2026-04-08T12"]; bucket.CostMicros != 1120 {
+		t.Fatalf("hourly costs = %#v", got.HourlyCosts)
+	}
+	if _, ok := got.HourlyCosts["2026-03-31T12"]; ok {
+		t.Fatalf("expected hourly buckets past retention to be pruned: %#v", got.HourlyCosts)
+	}
+	if bucket := got.DailyCosts["2026-03-31"]; bucket.CostMicros != 50 {
+		t.Fatalf("daily costs = %#v", got.DailyCosts)
+	}
+}
+
+func TestStoreMigratesVersion3(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, storeFilename), []byte(`{
+  "version": 3,
+  "clients": {
+    "claude": {
+      "providers": {
+        "p1": {
+          "request_count": 4,
+          "success_count": 3,
+          "total_tokens": 120,
+          "total_cost_micros": 900,
+          "daily_costs": {"2026-04-07": {"cost_micros": 900, "has_cost": true}},
+          "has_cost": true
+        }
+      }
+    }
+  }
+}
+`), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	got, ok := store.ProviderSnapshot("claude", "p1")
+	if !ok || got.RequestCount != 4 || got.TotalCostMicros != 900 || got.DailyCosts["2026-04-07"].CostMicros != 900 {
+		t.Fatalf("migrated snapshot = %#v ok=%v", got, ok)
+	}
+	if got.Models != nil || got.Capabilities != nil || got.Keys != nil || got.HourlyCosts != nil {
+		t.Fatalf("expected empty breakdowns after migration: %#v", got)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, storeFilename))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var persisted storeState
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if persisted.Version != storeVersion {
+		t.Fatalf("persisted version = %d want %d", persisted.Version, storeVersion)
+	}
+}
+
 func TestStoreRecordPersistsAsynchronously(t *testing.T) {
 	dir := t.TempDir()
 	store, err := NewStore(dir)
@@ -217,13 +360,13 @@ func TestStoreRecordPersistsAsynchronously(t *testing.T) {
 
 	deadline := time.Now().Add(time.Second)
 	for {
-		data, err := os.ReadFile(filepath.Join(dir, storeFilename))
-		if err == nil && len(data) > 0 {
-			var persisted storeState
-			if err := json.Unmarshal(data, &persisted); err != nil {
-				t.Fatalf("Unmarshal: %v", err)
+		// A store opened after a crash replays the journal.
+		if data, err := os.ReadFile(filepath.Join(dir, journalFilename)); err == nil && len(data) > 0 {
+			reloaded, err := NewStore(dir)
+			if err != nil {
+				t.Fatalf("NewStore reload: %v", err)
 			}
-			got := persisted.Clients["openai"].Providers["p1"]
+			got, _ := reloaded.ProviderSnapshot("openai", "p1")
 			if got.TotalTokens == 3 && got.RequestCount == 1 && got.SuccessCount == 1 {
 				return
 			}
@@ -259,7 +402,7 @@ func TestStoreRecordPersistenceIsThrottledNotDebounced(t *testing.T) {
 
 	deadline := start.Add(300 * time.Millisecond)
 	for {
-		data, err := os.ReadFile(filepath.Join(dir, storeFilename))
+		data, err := os.ReadFile(filepath.Join(dir, journalFilename))
 		if err == nil && len(data) > 0 {
 			return
 		}
@@ -338,3 +481,55 @@ func TestStoreDeleteProvidersWithRollbackRestoresDeletedUsage(t *testing.T) {
 		t.Fatalf("reloaded snapshot = %#v ok=%v", got, ok)
 	}
 }
+
+func TestStoreResetArchivesPreviousPeriod(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	if err := store.RecordUsage("openai", "p1", UsageSnapshot{
+		UsageDelta: UsageDelta{InputTokens: 10, OutputTokens: 20},
+		CostMicros: 500,
+		HasCost:    true,
+	}, time.Date(2026, 4, 8, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("RecordUsage: %v", err)
+	}
+
+	now := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	archive, err := store.Reset(now)
+	if err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if want := filepath.Join(dir, storeArchiveDir, "usage-20260501T000000Z.json"); archive != want {
+		t.Fatalf("archive = %q, want %q", archive, want)
+	}
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatalf("ReadFile archive: %v", err)
+	}
+	var archived storeState
+	if err := json.Unmarshal(data, &archived); err != nil {
+		t.Fatalf("json.Unmarshal archive: %v", err)
+	}
+	if got := archived.Clients["openai"].Providers["p1"]; got.TotalTokens != 30 || got.TotalCostMicros != 500 {
+		t.Fatalf("archived usage = %#v", got)
+	}
+
+	if _, ok := store.ProviderSnapshot("openai", "p1"); ok {
+		t.Fatalf("expected counters to be cleared")
+	}
+	if !store.PeriodStart().Equal(now) {
+		t.Fatalf("PeriodStart = %v", store.PeriodStart())
+	}
+	reloaded, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	defer func() { _ = reloaded.Close() }()
+	if _, ok := reloaded.ProviderSnapshot("openai", "p1"); ok || !reloaded.PeriodStart().Equal(now) {
+		t.Fatalf("reloaded store was not reset: period=%v", reloaded.PeriodStart())
+	}
+}
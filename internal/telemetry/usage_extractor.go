@@ -7,6 +7,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+
+	"github.com/lansespirit/Clipal/internal/tokencount"
 )
 
 const maxJSONCaptureBytes = 512 * 1024
@@ -33,9 +35,15 @@ type UsageExtractor struct {
 	eventName string
 	dataLines []string
 
-	completed bool
-	snapshot  UsageSnapshot
-	found     bool
+	completed  bool
+	sawContent bool
+	snapshot   UsageSnapshot
+	found      bool
+
+	// sawChoices and output track the generated text of OpenAI chat and
+	// completions streams, for an estimate when no usage is reported.
+	sawChoices bool
+	output     tokencount.Counter
 }
 
 func NewUsageExtractor(family string, capability string, contentType string) *UsageExtractor {
@@ -126,6 +134,21 @@ func (e *UsageExtractor) Finalize() (UsageSnapshot, bool) {
 	}
 }
 
+// Partial returns the usage an SSE stream reported before it broke off. Unlike
+// Finalize it does not require the stream to have completed.
+func (e *UsageExtractor) Partial() (UsageSnapshot, bool) {
+	if e == nil {
+		return UsageSnapshot{}, false
+	}
+	switch e.mode {
+	case usageModeOpenAISSE, usageModeClaudeSSE, usageModeGeminiSSE:
+		e.flushPendingEvent()
+		return e.snapshot, e.found
+	default:
+		return UsageSnapshot{}, false
+	}
+}
+
 func (e *UsageExtractor) extractFromJSON() (UsageSnapshot, bool) {
 	reader, ok := e.jsonReader()
 	if !ok {
@@ -167,6 +190,25 @@ func (e *UsageExtractor) extractFromJSON() (UsageSnapshot, bool) {
 	}
 }
 
+// EstimatedOutputTokens approximates the output of an OpenAI chat or
+// completions stream from its generated text. It returns false for other
+// streams and for streams that carried no choices.
+func (e *UsageExtractor) EstimatedOutputTokens() (int64, bool) {
+	if e == nil || e.mode != usageModeOpenAISSE || !e.sawChoices {
+		return 0, false
+	}
+	return int64(e.output.Tokens()), true
+}
+
+// SawContent reports whether an SSE stream has produced its first content,
+// terminal, or error event. Keepalives and metadata-only events do not count.
+func (e *UsageExtractor) SawContent() bool {
+	if e == nil {
+		return false
+	}
+	return e.sawContent
+}
+
 func (e *UsageExtractor) Cleanup() {
 	if e == nil || e.jsonFile == nil {
 		return
@@ -268,6 +310,9 @@ func (e *UsageExtractor) flushPendingEvent() {
 	if !ok {
 		return
 	}
+	if !e.sawContent {
+		e.sawContent = isSSEContentEvent(e.mode, eventName, payload)
+	}
 
 	switch e.mode {
 	case usageModeOpenAISSE:
@@ -294,6 +339,12 @@ func (e *UsageExtractor) handleOpenAISSEEvent(eventName string, payload map[stri
 		}
 		return
 	}
+	if choices, ok := payload["choices"].([]any); ok {
+		e.sawChoices = true
+		for _, raw := range choices {
+			addOpenAIChoiceText(&e.output, raw)
+		}
+	}
 	if snapshot, ok := snapshotFromKnownUsageObject(nestedMap(payload, "usage"), normalizeOpenAIUsage); ok {
 		// Chat/completions streams with include_usage emit a final chunk carrying
 		// top-level usage just before [DONE].
@@ -336,6 +387,98 @@ func (e *UsageExtractor) handleGeminiSSEEvent(payload map[string]any) {
 	}
 }
 
+func isSSEContentEvent(mode usageMode, eventName string, payload map[string]any) bool {
+	if _, ok := payload["error"]; ok {
+		return true
+	}
+	name := eventName
+	if name == "" {
+		name = strings.TrimSpace(stringValue(payload["type"]))
+	}
+	switch mode {
+	case usageModeOpenAISSE:
+		switch {
+		case name == "error", name == "response.completed", name == "response.failed", name == "response.incomplete":
+			return true
+		case strings.HasPrefix(name, "response.") && strings.HasSuffix(name, ".delta"):
+			return true
+		}
+		return hasOpenAIChoiceContent(payload)
+	case usageModeClaudeSSE:
+		switch name {
+		case "content_block_delta", "message_delta", "message_stop", "error":
+			return true
+		}
+		return false
+	case usageModeGeminiSSE:
+		return hasGeminiFinishReason(payload) || hasGeminiContentPart(payload)
+	default:
+		return false
+	}
+}
+
+func hasOpenAIChoiceContent(payload map[string]any) bool {
+	choices, ok := payload["choices"].([]any)
+	if !ok {
+		return false
+	}
+	for _, choice := range choices {
+		choiceMap, ok := choice.(map[string]any)
+		if !ok {
+			continue
+		}
+		if stringValue(choiceMap["text"]) != "" || strings.TrimSpace(stringValue(choiceMap["finish_reason"])) != "" {
+			return true
+		}
+		delta, ok := choiceMap["delta"].(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"content", "reasoning_content", "reasoning", "refusal"} {
+			if stringValue(delta[key]) != "" {
+				return true
+			}
+		}
+		if calls, ok := delta["tool_calls"].([]any); ok && len(calls) > 0 {
+			return true
+		}
+		if _, ok := delta["function_call"].(map[string]any); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func hasGeminiContentPart(payload map[string]any) bool {
+	candidates, ok := payload["candidates"].([]any)
+	if !ok {
+		return false
+	}
+	for _, candidate := range candidates {
+		candidateMap, ok := candidate.(map[string]any)
+		if !ok {
+			continue
+		}
+		parts, ok := nestedMap(candidateMap, "content")["parts"].([]any)
+		if !ok {
+			continue
+		}
+		for _, part := range parts {
+			partMap, ok := part.(map[string]any)
+			if !ok {
+				continue
+			}
+			for key, value := range partMap {
+				if key == "text" && stringValue(value) == "" {
+					continue
+				}
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func hasGeminiFinishReason(payload map[string]any) bool {
 	candidates, ok := payload["candidates"].([]any)
 	if !ok {
@@ -470,3 +613,27 @@ func stringValue(value any) string {
 		return ""
 	}
 }
+
+// addOpenAIChoiceText counts the text of one chat or completions stream
+// choice: content and reasoning deltas, legacy completion text, and tool
+// arguments.
+func addOpenAIChoiceText(c *tokencount.Counter, raw any) {
+	choice, _ := raw.(map[string]any)
+	if choice == nil {
+		return
+	}
+	c.Add(stringValue(choice["text"]))
+	delta, _ := choice["delta"].(map[string]any)
+	if delta == nil {
+		return
+	}
+	c.Add(stringValue(delta["content"]))
+	c.Add(stringValue(delta["reasoning_content"]))
+	toolCalls, _ := delta["tool_calls"].([]any)
+	for _, rawCall := range toolCalls {
+		call, _ := rawCall.(map[string]any)
+		function, _ := call["function"].(map[string]any)
+		c.Add(stringValue(function["name"]))
+		c.Add(stringValue(function["arguments"]))
+	}
+}
@@ -119,6 +119,26 @@ func TestUsageExtractorSSEOpenAIChatCompletionsUsageChunk(t *testing.T) {
 	}
 }
 
+func TestUsageExtractorSSEOpenAIChatEstimatesOutputWithoutUsage(t *testing.T) {
+	extractor := NewUsageExtractor("openai", "openai_chat_completions", "text/event-stream")
+	extractor.Append([]byte("data: {\"choices\":[{\"delta\":{\"role\":\"assistant\",\"content\":\"Hello, world\"}}]}\n\n"))
+	extractor.Append([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"你好\"}}]}\n\n"))
+	extractor.Append([]byte("data: {\"choices\":[{\"delta\":{\"tool_calls\":[{\"function\":{\"arguments\":\"{}\"}}]},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n"))
+	if _, ok := extractor.Finalize(); ok {
+		t.Fatalf("expected no reported usage")
+	}
+	// "Hello", ",", " world", one token per ideograph, and "{}".
+	if tokens, ok := extractor.EstimatedOutputTokens(); !ok || tokens != 6 {
+		t.Fatalf("estimated output = %d ok=%v", tokens, ok)
+	}
+
+	responses := NewUsageExtractor("openai", "openai_responses", "text/event-stream")
+	responses.Append([]byte("data: {\"type\":\"response.output_text.delta\",\"delta\":\"hi\"}\n\n"))
+	if _, ok := responses.EstimatedOutputTokens(); ok {
+		t.Fatalf("responses streams should not be estimated")
+	}
+}
+
 func TestUsageExtractorSSEClaudeRequiresMessageStop(t *testing.T) {
 	extractor := NewUsageExtractor("claude", "claude_messages", "text/event-stream")
 	extractor.Append([]byte("event: message_start\n"))
@@ -159,3 +179,55 @@ func TestUsageExtractorSSEGeminiUsesFinishedChunk(t *testing.T) {
 		t.Fatalf("thoughts_tokens = %d", usage.ThoughtsTokens)
 	}
 }
+
+func TestUsageExtractorSSESawContentIgnoresKeepalives(t *testing.T) {
+	tests := []struct {
+		name       string
+		family     string
+		capability string
+		keepalive  string
+		content    string
+	}{
+		{
+			name:       "claude ping and message_start",
+			family:     "claude",
+			capability: "claude_messages",
+			keepalive:  "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{}}\n\nevent: ping\ndata: {\"type\":\"ping\"}\n\nevent: content_block_start\ndata: {\"type\":\"content_block_start\",\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n",
+			content:    "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"hi\"}}\n\n",
+		},
+		{
+			name:       "openai chat role chunk",
+			family:     "openai",
+			capability: "openai_chat_completions",
+			keepalive:  ": keepalive\n\ndata: {\"choices\":[{\"delta\":{\"role\":\"assistant\",\"content\":\"\"}}]}\n\n",
+			content:    "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n",
+		},
+		{
+			name:       "openai responses created",
+			family:     "openai",
+			capability: "openai_responses",
+			keepalive:  "event: response.created\ndata: {\"type\":\"response.created\"}\n\nevent: response.in_progress\ndata: {\"type\":\"response.in_progress\"}\n\n",
+			content:    "event: response.output_text.delta\ndata: {\"type\":\"response.output_text.delta\",\"delta\":\"hi\"}\n\n",
+		},
+		{
+			name:       "gemini empty text",
+			family:     "gemini",
+			capability: "gemini_stream_generate_content",
+			keepalive:  "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"\"}]}}]}\n\n",
+			content:    "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"hi\"}]}}]}\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extractor := NewUsageExtractor(tt.family, tt.capability, "text/event-stream")
+			extractor.Append([]byte(tt.keepalive))
+			if extractor.SawContent() {
+				t.Fatalf("keepalive events should not count as content")
+			}
+			extractor.Append([]byte(tt.content))
+			if !extractor.SawContent() {
+				t.Fatalf("expected content event to be detected")
+			}
+		})
+	}
+}
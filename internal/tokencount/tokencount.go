@@ -0,0 +1,194 @@
+// Package tokencount is Clipal's local token estimator. It answers
+// count_tokens requests an upstream cannot, and fills in usage an upstream
+// never reported, for prompts and streamed output alike.
+//
+// Vendor tokenizers are not public for every model family, so Text splits
+// input the way BPE pre-tokenizers do and charges each piece by its script
+// and length instead of applying a vocabulary. No BPE merge tables are
+// embedded in the binary. The results are estimates, and usage recorded from
+// them is flagged as estimated.
+package tokencount
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"  // register decoder for ImageSize
+	_ "image/jpeg" // register decoder for ImageSize
+	_ "image/png"  // register decoder for ImageSize
+	"math"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Typical characters per token for each kind of pre-tokenized piece.
+const (
+	asciiWordChars    = 6
+	otherWordChars    = 3
+	digitGroupChars   = 3
+	punctuationChars  = 2
+	whitespaceChars   = 16
+	symbolBytesPerTok = 2
+)
+
+type pieceKind int
+
+const (
+	pieceNone pieceKind = iota
+	pieceASCIIWord
+	pieceOtherWord
+	pieceIdeograph
+	pieceDigits
+	pieceSpace
+	piecePunct
+	pieceSymbol
+)
+
+// Text estimates the number of tokens in s.
+func Text(s string) int {
+	var c Counter
+	c.Add(s)
+	return c.Tokens()
+}
+
+// Counter estimates the tokens of text that arrives in parts, such as the
+// deltas of a stream. Its result for the concatenated parts equals Text of
+// the whole. The zero value is ready to use.
+type Counter struct {
+	total  int
+	kind   pieceKind
+	length int
+	prev   rune
+}
+
+// Add counts s after the text added so far.
+func (c *Counter) Add(s string) {
+	for _, r := range s {
+		next := classify(r)
+		// A single leading space merges into the word that follows it, as
+		// in GPT-style and SentencePiece vocabularies.
+		if c.kind == pieceSpace && c.length == 1 && c.prev == ' ' && (next == pieceASCIIWord || next == pieceOtherWord) {
+			c.kind, c.length = next, 0
+		}
+		if next != c.kind || next == pieceIdeograph {
+			c.total += pieceTokens(c.kind, c.length)
+			c.kind, c.length = next, 0
+		}
+		if next == pieceSymbol {
+			c.length += utf8.RuneLen(r)
+		} else {
+			c.length++
+		}
+		c.prev = r
+	}
+}
+
+// Tokens returns the estimate for the text added so far.
+func (c *Counter) Tokens() int {
+	if c == nil {
+		return 0
+	}
+	return c.total + pieceTokens(c.kind, c.length)
+}
+
+func classify(r rune) pieceKind {
+	switch {
+	case r < utf8.RuneSelf && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'):
+		return pieceASCIIWord
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+		return pieceIdeograph
+	case unicode.IsLetter(r) || unicode.IsMark(r):
+		return pieceOtherWord
+	case unicode.IsDigit(r):
+		return pieceDigits
+	case unicode.IsSpace(r):
+		return pieceSpace
+	case r < utf8.RuneSelf || unicode.IsPunct(r):
+		return piecePunct
+	default:
+		return pieceSymbol
+	}
+}
+
+func pieceTokens(kind pieceKind, length int) int {
+	if length == 0 {
+		return 0
+	}
+	switch kind {
+	case pieceASCIIWord:
+		return ceilDiv(length, asciiWordChars)
+	case pieceOtherWord:
+		return ceilDiv(length, otherWordChars)
+	case pieceIdeograph:
+		return length
+	case pieceDigits:
+		return ceilDiv(length, digitGroupChars)
+	case pieceSpace:
+		return ceilDiv(length, whitespaceChars)
+	case piecePunct:
+		return ceilDiv(length, punctuationChars)
+	default:
+		return ceilDiv(length, symbolBytesPerTok)
+	}
+}
+
+func ceilDiv(n, d int) int {
+	return (n + d - 1) / d
+}
+
+// Claude resizes images so neither edge exceeds claudeMaxImageEdge and the
+// area stays under claudeMaxImagePixels, then charges one token per
+// claudePixelsPerToken.
+const (
+	claudeMaxImageEdge   = 1568
+	claudeMaxImagePixels = 1_150_000
+	claudePixelsPerToken = 750
+	// ClaudeMaxImageTokens is charged for an image whose size is unknown.
+	ClaudeMaxImageTokens = 1600
+)
+
+// ClaudeImage returns the tokens Claude charges for an image of the given
+// size. A non-positive dimension means the size is unknown.
+func ClaudeImage(width, height int) int {
+	if width <= 0 || height <= 0 {
+		return ClaudeMaxImageTokens
+	}
+	w, h := float64(width), float64(height)
+	if edge := max(w, h); edge > claudeMaxImageEdge {
+		w, h = w*claudeMaxImageEdge/edge, h*claudeMaxImageEdge/edge
+	}
+	if area := w * h; area > claudeMaxImagePixels {
+		scale := math.Sqrt(claudeMaxImagePixels / area)
+		w, h = w*scale, h*scale
+	}
+	return min(int(math.Ceil(w*h/claudePixelsPerToken)), ClaudeMaxImageTokens)
+}
+
+// Gemini charges a flat GeminiImageTileTokens for an image whose edges both
+// fit in geminiSmallImageEdge, and otherwise that much per
+// geminiImageTileEdge square tile.
+const (
+	geminiSmallImageEdge = 384
+	geminiImageTileEdge  = 768
+	// GeminiImageTileTokens is also charged for an image whose size is unknown.
+	GeminiImageTileTokens = 258
+)
+
+// GeminiImage returns the tokens Gemini charges for an image of the given
+// size. A non-positive dimension means the size is unknown.
+func GeminiImage(width, height int) int {
+	if width <= geminiSmallImageEdge && height <= geminiSmallImageEdge {
+		return GeminiImageTileTokens
+	}
+	tiles := ceilDiv(width, geminiImageTileEdge) * ceilDiv(height, geminiImageTileEdge)
+	return tiles * GeminiImageTileTokens
+}
+
+// ImageSize reads the dimensions of a PNG, JPEG, or GIF image. ok is false
+// for other formats and for data that cannot be decoded.
+func ImageSize(data []byte) (width, height int, ok bool) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, false
+	}
+	return cfg.Width, cfg.Height, true
+}
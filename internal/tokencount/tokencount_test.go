@@ -0,0 +1,92 @@
+package tokencount
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		want int
+	}{
+		{name: "empty", in: "", want: 0},
+		{name: "short words absorb one leading space", in: "Hello world, how are you?", want: 7},
+		{name: "long words split", in: "internationalization", want: 4},
+		{name: "digits group by three", in: "1234567", want: 3},
+		{name: "indentation is one piece", in: "\n        return", want: 2},
+		{name: "ideographs count per character", in: "你好世界", want: 4},
+		{name: "other scripts", in: "привет", want: 2},
+		{name: "emoji", in: "🙂", want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Text(tt.in); got != tt.want {
+				t.Fatalf("Text(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCounterMatchesTextAcrossParts(t *testing.T) {
+	t.Parallel()
+
+	whole := "Hello world, 你好 1234567\n        return internationalization 🙂"
+	// Split at every rune boundary, including the end of the string.
+	for split := range whole + " " {
+		var c Counter
+		c.Add(whole[:split])
+		c.Add(whole[split:])
+		if got, want := c.Tokens(), Text(whole); got != want {
+			t.Fatalf("split at %d: Tokens = %d, want %d", split, got, want)
+		}
+	}
+}
+
+func TestClaudeImage(t *testing.T) {
+	t.Parallel()
+
+	if got := ClaudeImage(200, 200); got != 54 {
+		t.Fatalf("200x200 = %d, want 54", got)
+	}
+	if got := ClaudeImage(4000, 3000); got > ClaudeMaxImageTokens || got < 1500 {
+		t.Fatalf("4000x3000 = %d, want resized to about 1533", got)
+	}
+	if got := ClaudeImage(0, 0); got != ClaudeMaxImageTokens {
+		t.Fatalf("unknown size = %d", got)
+	}
+}
+
+func TestGeminiImage(t *testing.T) {
+	t.Parallel()
+
+	if got := GeminiImage(384, 200); got != GeminiImageTileTokens {
+		t.Fatalf("small image = %d", got)
+	}
+	if got := GeminiImage(1000, 700); got != 2*GeminiImageTileTokens {
+		t.Fatalf("two tiles = %d", got)
+	}
+	if got := GeminiImage(0, 0); got != GeminiImageTileTokens {
+		t.Fatalf("unknown size = %d", got)
+	}
+}
+
+func TestImageSize(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 30, 20))); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	if w, h, ok := ImageSize(buf.Bytes()); !ok || w != 30 || h != 20 {
+		t.Fatalf("ImageSize = %d, %d, %v", w, h, ok)
+	}
+	if _, _, ok := ImageSize([]byte("not an image")); ok {
+		t.Fatalf("ImageSize accepted garbage")
+	}
+}
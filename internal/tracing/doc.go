@@ -0,0 +1,3 @@
+// Package tracing exports request spans to an OpenTelemetry collector over
+// OTLP/HTTP and handles W3C trace context headers.
+package tracing
@@ -0,0 +1,344 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/logger"
+)
+
+const (
+	exportQueueSize     = 2048
+	exportBatchSize     = 256
+	exportInterval      = 2 * time.Second
+	exportTimeout       = 10 * time.Second
+	defaultServiceName  = "clipal"
+	instrumentationName = "github.com/lansespirit/Clipal"
+)
+
+// SpanKind mirrors the OTLP span kind enum.
+type SpanKind int
+
+const (
+	SpanKindServer SpanKind = 2
+	SpanKindClient SpanKind = 3
+)
+
+// Attribute is a span attribute. Value must be a string, bool, int, int64 or
+// float64.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+func String(key, value string) Attribute      { return Attribute{Key: key, Value: value} }
+func Int64(key string, value int64) Attribute { return Attribute{Key: key, Value: value} }
+func Bool(key string, value bool) Attribute   { return Attribute{Key: key, Value: value} }
+
+// Span is a finished span ready for export.
+type Span struct {
+	TraceID      TraceID
+	SpanID       SpanID
+	ParentSpanID SpanID
+	TraceState   string
+	Name         string
+	Kind         SpanKind
+	Start        time.Time
+	End          time.Time
+	Attributes   []Attribute
+	// Error marks the span status as ERROR with StatusMessage.
+	Error         bool
+	StatusMessage string
+}
+
+// ExporterOptions configures an Exporter.
+type ExporterOptions struct {
+	// Endpoint is the full OTLP/HTTP traces URL.
+	Endpoint    string
+	Headers     map[string]string
+	ServiceName string
+	// HTTPClient overrides the client used for export requests.
+	HTTPClient *http.Client
+}
+
+// Exporter batches spans and posts them to a collector as OTLP/HTTP JSON.
+// Export never blocks request handling; spans are dropped when the queue is
+// full.
+type Exporter struct {
+	options ExporterOptions
+	client  *http.Client
+
+	queue     chan Span
+	flushCh   chan chan struct{}
+	closeCh   chan struct{}
+	doneCh    chan struct{}
+	closeOnce sync.Once
+
+	mu      sync.Mutex
+	failing bool
+}
+
+func NewExporter(options ExporterOptions) *Exporter {
+	options.Endpoint = strings.TrimSpace(options.Endpoint)
+	if strings.TrimSpace(options.ServiceName) == "" {
+		options.ServiceName = defaultServiceName
+	}
+	client := options.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: exportTimeout}
+	}
+	e := &Exporter{
+		options: options,
+		client:  client,
+		queue:   make(chan Span, exportQueueSize),
+		flushCh: make(chan chan struct{}),
+		closeCh: make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// Export queues spans for the next batch.
+func (e *Exporter) Export(spans ...Span) {
+	if e == nil {
+		return
+	}
+	for _, span := range spans {
+		select {
+		case <-e.closeCh:
+			return
+		case e.queue <- span:
+		default:
+			logger.Debug("tracing: export queue full; dropping span %s", span.Name)
+		}
+	}
+}
+
+// Flush sends queued spans and waits for the export request to finish.
+func (e *Exporter) Flush() {
+	if e == nil {
+		return
+	}
+	done := make(chan struct{})
+	select {
+	case e.flushCh <- done:
+		<-done
+	case <-e.doneCh:
+	}
+}
+
+// Close exports queued spans and stops the exporter.
+func (e *Exporter) Close() error {
+	if e == nil {
+		return nil
+	}
+	e.closeOnce.Do(func() {
+		close(e.closeCh)
+		<-e.doneCh
+	})
+	return nil
+}
+
+func (e *Exporter) run() {
+	defer close(e.doneCh)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	batch := make([]Span, 0, exportBatchSize)
+	drain := func() {
+		for {
+			select {
+			case span := <-e.queue:
+				batch = append(batch, span)
+			default:
+				return
+			}
+		}
+	}
+	send := func() {
+		if len(batch) > 0 {
+			e.send(batch)
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= exportBatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case done := <-e.flushCh:
+			drain()
+			send()
+			close(done)
+		case <-e.closeCh:
+			drain()
+			send()
+			return
+		}
+	}
+}
+
+func (e *Exporter) send(spans []Span) {
+	body, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		logger.Warn("tracing: failed to encode %d spans: %v", len(spans), err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.options.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		e.noteResult(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.options.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		e.noteResult(err)
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		e.noteResult(fmt.Errorf("collector returned %s", resp.Status))
+		return
+	}
+	e.noteResult(nil)
+}
+
+// noteResult logs the first failure and the recovery, not every failed batch.
+func (e *Exporter) noteResult(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch {
+	case err != nil && !e.failing:
+		e.failing = true
+		logger.Warn("tracing: failed to export spans to %s: %v", e.options.Endpoint, err)
+	case err != nil:
+		logger.Debug("tracing: failed to export spans to %s: %v", e.options.Endpoint, err)
+	case e.failing:
+		e.failing = false
+		logger.Info("tracing: span export to %s recovered", e.options.Endpoint)
+	}
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	TraceState        string         `json:"traceState,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTracesRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func (e *Exporter) encode(spans []Span) otlpTracesRequest {
+	var resource otlpResourceSpans
+	resource.Resource.Attributes = encodeAttributes([]Attribute{String("service.name", e.options.ServiceName)})
+
+	var scope otlpScopeSpans
+	scope.Scope.Name = instrumentationName
+	scope.Spans = make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		out := otlpSpan{
+			TraceID:           span.TraceID.String(),
+			SpanID:            span.SpanID.String(),
+			TraceState:        span.TraceState,
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        encodeAttributes(span.Attributes),
+		}
+		if span.ParentSpanID.IsValid() {
+			out.ParentSpanID = span.ParentSpanID.String()
+		}
+		if span.Error {
+			out.Status = otlpStatus{Code: 2, Message: span.StatusMessage}
+		}
+		scope.Spans = append(scope.Spans, out)
+	}
+	resource.ScopeSpans = []otlpScopeSpans{scope}
+	return otlpTracesRequest{ResourceSpans: []otlpResourceSpans{resource}}
+}
+
+func encodeAttributes(attrs []Attribute) []otlpKeyValue {
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		var value otlpAnyValue
+		switch v := attr.Value.(type) {
+		case string:
+			value.StringValue = &v
+		case bool:
+			value.BoolValue = &v
+		case int:
+			s := strconv.Itoa(v)
+			value.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			value.IntValue = &s
+		case float64:
+			value.DoubleValue = &v
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		out = append(out, otlpKeyValue{Key: attr.Key, Value: value})
+	}
+	return out
+}
@@ -0,0 +1,124 @@
+package tracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExporterPostsOTLPJSON(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu      sync.Mutex
+		bodies  []otlpTracesRequest
+		headers []http.Header
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body otlpTracesRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode export body: %v", err)
+		}
+		mu.Lock()
+		bodies = append(bodies, body)
+		headers = append(headers, r.Header.Clone())
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	exporter := NewExporter(ExporterOptions{
+		Endpoint: collector.URL + "/v1/traces",
+		Headers:  map[string]string{"Authorization": "Bearer collector-token"},
+	})
+	defer func() { _ = exporter.Close() }()
+
+	traceID := NewTraceID()
+	root := Span{
+		TraceID: traceID,
+		SpanID:  NewSpanID(),
+		Name:    "POST /v1/responses",
+		Kind:    SpanKindServer,
+		Start:   time.Unix(100, 0),
+		End:     time.Unix(101, 500),
+		Attributes: []Attribute{
+			String("clipal.provider", "p1"),
+			Int64("clipal.attempts", 2),
+			Bool("clipal.recovered", false),
+		},
+	}
+	child := Span{
+		TraceID:       traceID,
+		SpanID:        NewSpanID(),
+		ParentSpanID:  root.SpanID,
+		Name:          "attempt p1",
+		Kind:          SpanKindClient,
+		Start:         time.Unix(100, 0),
+		End:           time.Unix(100, 200),
+		Error:         true,
+		StatusMessage: "server_error",
+	}
+	exporter.Export(root, child)
+	exporter.Flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 {
+		t.Fatalf("export requests = %d, want 1", len(bodies))
+	}
+	if got := headers[0].Get("Authorization"); got != "Bearer collector-token" {
+		t.Fatalf("Authorization = %q", got)
+	}
+	if got := headers[0].Get("Content-Type"); got != "application/json" {
+		t.Fatalf("Content-Type = %q", got)
+	}
+	resource := bodies[0].ResourceSpans[0]
+	if attr := resource.Resource.Attributes[0]; attr.Key != "service.name" || attr.Value.StringValue == nil || *attr.Value.StringValue != "clipal" {
+		t.Fatalf("resource attributes = %#v", resource.Resource.Attributes)
+	}
+	spans := resource.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("spans = %d, want 2", len(spans))
+	}
+	if spans[0].TraceID != traceID.String() || spans[0].ParentSpanID != "" || spans[0].Kind != SpanKindServer {
+		t.Fatalf("root span = %#v", spans[0])
+	}
+	if spans[0].StartTimeUnixNano != "100000000000" || spans[0].EndTimeUnixNano != "101000000500" {
+		t.Fatalf("root times = %s..%s", spans[0].StartTimeUnixNano, spans[0].EndTimeUnixNano)
+	}
+	if attr := spans[0].Attributes[1]; attr.Value.IntValue == nil || *attr.Value.IntValue != "2" {
+		t.Fatalf("int attribute = %#v", attr)
+	}
+	if spans[1].ParentSpanID != root.SpanID.String() || spans[1].Status.Code != 2 || spans[1].Status.Message != "server_error" {
+		t.Fatalf("child span = %#v", spans[1])
+	}
+}
+
+func TestExporterCloseDropsLaterSpans(t *testing.T) {
+	t.Parallel()
+
+	var requests int
+	var mu sync.Mutex
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	exporter := NewExporter(ExporterOptions{Endpoint: collector.URL})
+	exporter.Export(Span{TraceID: NewTraceID(), SpanID: NewSpanID(), Name: "queued"})
+	if err := exporter.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	exporter.Export(Span{TraceID: NewTraceID(), SpanID: NewSpanID(), Name: "late"})
+	exporter.Flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 1 {
+		t.Fatalf("export requests = %d, want 1 for the span queued before Close", requests)
+	}
+}
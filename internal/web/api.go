@@ -3,11 +3,10 @@ package web
 import (
 	"encoding/json"
 	"fmt"
-	"io/fs"
 	"net/http"
 	"net/url"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -37,6 +36,12 @@ type API struct {
 	oauthMu      sync.Mutex
 	oauthTargets map[string]oauthTargetClient
 	configMu     sync.Mutex
+	modelsMu     sync.Mutex
+	// modelsCache holds the model lists fetched by HandleListProviderModels,
+	// keyed by client type and provider name.
+	modelsCache map[string]providerModelsCacheEntry
+	// revisionSource tags the config revisions recorded by this API.
+	revisionSource config.RevisionSource
 }
 
 type oauthTargetClient struct {
@@ -61,21 +66,40 @@ func NewAPI(configDir, version string, runtime *proxy.Router) *API {
 		}
 	}
 	return &API{
-		configDir:    configDir,
-		version:      version,
-		runtime:      runtime,
-		telemetry:    telemetryStore,
-		integrations: integration.NewManager(configDir),
-		oauth:        oauthpkg.NewService(configDir),
-		oauthTargets: make(map[string]oauthTargetClient),
+		configDir:      configDir,
+		version:        version,
+		runtime:        runtime,
+		telemetry:      telemetryStore,
+		integrations:   integration.NewManager(configDir),
+		oauth:          oauthpkg.NewService(configDir),
+		oauthTargets:   make(map[string]oauthTargetClient),
+		modelsCache:    make(map[string]providerModelsCacheEntry),
+		revisionSource: config.RevisionSourceWeb,
 	}
 }
 
+// SetRevisionSource sets the source recorded with config revisions written
+// through this API. The CLI uses config.RevisionSourceCLI.
+func (a *API) SetRevisionSource(source config.RevisionSource) {
+	a.revisionSource = source
+}
+
+// reloadRuntimeProviderConfigs applies saved config files to the running proxy
+// and records them in the revision history.
 func (a *API) reloadRuntimeProviderConfigs() error {
-	if a.runtime == nil {
-		return nil
+	return a.applySavedConfig("")
+}
+
+func (a *API) applySavedConfig(restoredFrom string) error {
+	if a.runtime != nil {
+		if err := a.runtime.ReloadProviderConfigs(); err != nil {
+			return err
+		}
 	}
-	return a.runtime.ReloadProviderConfigs()
+	if _, err := config.RecordRevision(a.configDir, a.revisionSource, restoredFrom); err != nil {
+		logger.Warn("failed to record config revision: %v", err)
+	}
+	return nil
 }
 
 // HandleGetGlobalConfig returns the current global configuration
@@ -115,57 +139,101 @@ func (a *API) HandleUpdateGlobalConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update global config
-	cfg.Global.ListenAddr = req.ListenAddr
-	cfg.Global.Port = req.Port
-	cfg.Global.LogLevel = config.LogLevel(req.LogLevel)
-	cfg.Global.ReactivateAfter = req.ReactivateAfter
-	cfg.Global.UpstreamIdleTimeout = req.UpstreamIdleTimeout
+	if err := applyGlobalConfigRequest(&cfg.Global, req); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !a.saveGlobalConfigOrWriteError(w, cfg) {
+		return
+	}
+
+	logger.Info("global configuration updated via web interface")
+	writeJSON(w, SuccessResponse{Message: "configuration updated successfully"})
+}
+
+// applyGlobalConfigRequest copies the settings managed by the Web UI onto g.
+// Optional fields left out of req keep their current values.
+func applyGlobalConfigRequest(g *config.GlobalConfig, req GlobalConfigRequest) error {
+	g.ListenAddr = req.ListenAddr
+	g.Port = req.Port
+	g.LogLevel = config.LogLevel(req.LogLevel)
+	g.ReactivateAfter = req.ReactivateAfter
+	g.UpstreamIdleTimeout = req.UpstreamIdleTimeout
 	// Backwards compatible: if the UI/client doesn't send this (older versions),
 	// keep the current/default value instead of overwriting with empty.
 	if strings.TrimSpace(req.ResponseHeaderTimeout) != "" {
-		cfg.Global.ResponseHeaderTimeout = req.ResponseHeaderTimeout
+		g.ResponseHeaderTimeout = req.ResponseHeaderTimeout
 	}
-	if err := config.ApplyUpstreamProxySettings(&cfg.Global, config.UpstreamProxySettingsPatch{
+	if err := config.ApplyUpstreamProxySettings(g, config.UpstreamProxySettingsPatch{
 		Mode: req.UpstreamProxyMode,
 		URL:  req.UpstreamProxyURL,
 	}); err != nil {
-		writeError(w, err.Error(), http.StatusBadRequest)
-		return
+		return err
 	}
-	cfg.Global.MaxRequestBody = req.MaxRequestBodyBytes
-	cfg.Global.LogDir = req.LogDir
-	cfg.Global.LogRetentionDays = req.LogRetentionDays
-	cfg.Global.LogStdout = req.LogStdout
-	cfg.Global.Notifications.Enabled = req.Notifications.Enabled
-	cfg.Global.Notifications.MinLevel = config.LogLevel(req.Notifications.MinLevel)
-	cfg.Global.Notifications.ProviderSwitch = req.Notifications.ProviderSwitch
-	cfg.Global.CircuitBreaker.FailureThreshold = req.CircuitBreaker.FailureThreshold
-	cfg.Global.CircuitBreaker.SuccessThreshold = req.CircuitBreaker.SuccessThreshold
-	cfg.Global.CircuitBreaker.OpenTimeout = req.CircuitBreaker.OpenTimeout
-	cfg.Global.CircuitBreaker.HalfOpenMaxInFlight = req.CircuitBreaker.HalfOpenMaxInFlight
+	g.MaxRequestBody = req.MaxRequestBodyBytes
+	g.LogDir = req.LogDir
+	g.LogRetentionDays = req.LogRetentionDays
+	g.LogStdout = req.LogStdout
+	g.Notifications.Enabled = req.Notifications.Enabled
+	g.Notifications.MinLevel = config.LogLevel(req.Notifications.MinLevel)
+	g.Notifications.ProviderSwitch = req.Notifications.ProviderSwitch
+	g.CircuitBreaker.FailureThreshold = req.CircuitBreaker.FailureThreshold
+	g.CircuitBreaker.SuccessThreshold = req.CircuitBreaker.SuccessThreshold
+	g.CircuitBreaker.OpenTimeout = req.CircuitBreaker.OpenTimeout
+	g.CircuitBreaker.HalfOpenMaxInFlight = req.CircuitBreaker.HalfOpenMaxInFlight
 	if req.Routing.StickySessions.Enabled != nil {
-		cfg.Global.Routing.StickySessions.Enabled = *req.Routing.StickySessions.Enabled
+		g.Routing.StickySessions.Enabled = *req.Routing.StickySessions.Enabled
 	}
 	if req.Routing.StickySessions.ExplicitTTL != nil {
-		cfg.Global.Routing.StickySessions.ExplicitTTL = *req.Routing.StickySessions.ExplicitTTL
+		g.Routing.StickySessions.ExplicitTTL = *req.Routing.StickySessions.ExplicitTTL
 	}
 	if req.Routing.BusyBackpressure.Enabled != nil {
-		cfg.Global.Routing.BusyBackpressure.Enabled = *req.Routing.BusyBackpressure.Enabled
+		g.Routing.BusyBackpressure.Enabled = *req.Routing.BusyBackpressure.Enabled
 	}
 	if req.Routing.BusyBackpressure.ShortRetryAfterMax != nil {
-		cfg.Global.Routing.BusyBackpressure.ShortRetryAfterMax = *req.Routing.BusyBackpressure.ShortRetryAfterMax
+		g.Routing.BusyBackpressure.ShortRetryAfterMax = *req.Routing.BusyBackpressure.ShortRetryAfterMax
 	}
 	if req.Routing.BusyBackpressure.MaxInlineWait != nil {
-		cfg.Global.Routing.BusyBackpressure.MaxInlineWait = *req.Routing.BusyBackpressure.MaxInlineWait
+		g.Routing.BusyBackpressure.MaxInlineWait = *req.Routing.BusyBackpressure.MaxInlineWait
 	}
-
-	if !a.saveGlobalConfigOrWriteError(w, cfg) {
-		return
+	if req.Routing.FirstTokenTimeout.Default != nil {
+		g.Routing.FirstTokenTimeout.Default = strings.TrimSpace(*req.Routing.FirstTokenTimeout.Default)
 	}
-
-	logger.Info("global configuration updated via web interface")
-	writeJSON(w, SuccessResponse{Message: "configuration updated successfully"})
+	if req.Routing.FirstTokenTimeout.Capabilities != nil {
+		g.Routing.FirstTokenTimeout.Capabilities = *req.Routing.FirstTokenTimeout.Capabilities
+	}
+	if req.Routing.StreamRecovery.Enabled != nil {
+		g.Routing.StreamRecovery.Enabled = *req.Routing.StreamRecovery.Enabled
+	}
+	if req.Routing.StreamRecovery.MaxAttempts != nil {
+		g.Routing.StreamRecovery.MaxAttempts = *req.Routing.StreamRecovery.MaxAttempts
+	}
+	if req.Routing.CountTokens != nil {
+		g.Routing.CountTokens = config.CountTokensMode(strings.TrimSpace(*req.Routing.CountTokens))
+	}
+	if req.UsageLedger.Enabled != nil {
+		g.UsageLedger.Enabled = *req.UsageLedger.Enabled
+	}
+	if req.UsageLedger.RetentionDays != nil {
+		g.UsageLedger.RetentionDays = *req.UsageLedger.RetentionDays
+	}
+	if req.UsageLedger.MaxEntries != nil {
+		g.UsageLedger.MaxEntries = *req.UsageLedger.MaxEntries
+	}
+	if req.Tracing.Enabled != nil {
+		g.Tracing.Enabled = *req.Tracing.Enabled
+	}
+	if req.Tracing.Endpoint != nil {
+		g.Tracing.Endpoint = strings.TrimSpace(*req.Tracing.Endpoint)
+	}
+	if req.Tracing.Headers != nil {
+		g.Tracing.Headers = *req.Tracing.Headers
+	}
+	if req.Tracing.ServiceName != nil {
+		g.Tracing.ServiceName = strings.TrimSpace(*req.Tracing.ServiceName)
+	}
+	return nil
 }
 
 // HandleGetProviders returns providers for a specific client
@@ -637,12 +705,48 @@ func (a *API) HandleExportConfig(w http.ResponseWriter, r *http.Request) {
 		OpenAI: toClientConfigExport(cfg.OpenAI),
 		Gemini: toClientConfigExport(cfg.Gemini),
 	}
+	if includeOAuth, _ := strconv.ParseBool(r.URL.Query().Get("include_oauth")); includeOAuth {
+		creds, err := a.linkedOAuthCredentials(cfg)
+		if err != nil {
+			writeError(w, fmt.Sprintf("failed to export oauth credentials: %v", err), http.StatusInternalServerError)
+			return
+		}
+		exportData.OAuthCredentials = creds
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Content-Disposition", "attachment; filename=clipal-config.json")
 	_ = json.NewEncoder(w).Encode(exportData)
 }
 
+// linkedOAuthCredentials loads the credential behind every OAuth provider in
+// cfg, once each.
+func (a *API) linkedOAuthCredentials(cfg *config.Config) ([]oauthpkg.Credential, error) {
+	var out []oauthpkg.Credential
+	seen := make(map[string]bool)
+	for _, cc := range []config.ClientConfig{cfg.Claude, cfg.OpenAI, cfg.Gemini} {
+		for _, p := range cc.Providers {
+			if !p.UsesOAuth() {
+				continue
+			}
+			key := string(p.NormalizedOAuthProvider()) + "/" + p.NormalizedOAuthRef()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			cred, err := a.oauth.Store().Load(p.NormalizedOAuthProvider(), p.NormalizedOAuthRef())
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+			out = append(out, *cred)
+		}
+	}
+	return out, nil
+}
+
 func (a *API) HandleListIntegrations(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -891,6 +995,8 @@ func buildClientStatus(cc config.ClientConfig, providers []config.Provider, rt p
 			Result:     view.Result,
 			Label:      view.Label,
 			Detail:     view.Detail,
+
+			RecoveredFrom: rt.LastRequest.RecoveredFrom,
 		}
 	}
 
@@ -1628,43 +1734,3 @@ func validateBaseURL(raw string) (string, error) {
 	}
 	return raw, nil
 }
-
-//nolint:gosec // path comes from Clipal-managed config store locations, not arbitrary user input.
-func atomicWriteFile(path string, data []byte, perm fs.FileMode) error {
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0o700); err != nil {
-		return err
-	}
-
-	f, err := os.CreateTemp(dir, ".clipal-*")
-	if err != nil {
-		return err
-	}
-	tmp := f.Name()
-	success := false
-	defer func() {
-		_ = f.Close()
-		if !success {
-			_ = os.Remove(tmp)
-		}
-	}()
-
-	if err := f.Chmod(perm); err != nil {
-		return err
-	}
-	if _, err := f.Write(data); err != nil {
-		return err
-	}
-	if err := f.Sync(); err != nil {
-		return err
-	}
-	if err := f.Close(); err != nil {
-		return err
-	}
-
-	if err := os.Rename(tmp, path); err != nil {
-		return err
-	}
-	success = true
-	return nil
-}
@@ -229,6 +229,15 @@ func TestMapProviderUsageResponse_IncludesSpendRollups(t *testing.T) {
 				HasCost:    true,
 			},
 		},
+		HourlyCosts: map[string]telemetry.DailyCostBucket{
+			now.Format("2006-01-02T15"): {CostMicros: 250_000, HasCost: true},
+		},
+		Models: map[string]telemetry.UsageCounters{
+			"gpt-5": {RequestCount: 2, TotalTokens: 40, CostMicros: 11_250_000, HasCost: true},
+		},
+		Keys: map[string]telemetry.UsageCounters{
+			"0123456789ab": {RequestCount: 2},
+		},
 		LastUsedAt: now,
 	})
 	if resp == nil {
@@ -255,6 +264,15 @@ func TestMapProviderUsageResponse_IncludesSpendRollups(t *testing.T) {
 	if resp.SpendWeekMicros != 4_250_000 {
 		t.Fatalf("spend_week_micros = %d", resp.SpendWeekMicros)
 	}
+	if resp.SpendHourMicros != 250_000 {
+		t.Fatalf("spend_hour_micros = %d", resp.SpendHourMicros)
+	}
+	if got := resp.Models["gpt-5"]; got.RequestCount != 2 || got.TotalTokens != 40 || got.CostMicros != 11_250_000 {
+		t.Fatalf("models = %#v", resp.Models)
+	}
+	if resp.Keys["0123456789ab"].RequestCount != 2 || resp.Capabilities != nil {
+		t.Fatalf("keys = %#v capabilities = %#v", resp.Keys, resp.Capabilities)
+	}
 }
 
 func TestHandleGetProviders_IncludesRequestOnlyUsageWithoutTokenUsage(t *testing.T) {
@@ -472,7 +490,16 @@ func TestHandleUpdateGlobalConfig_AcceptsSnakeCaseNotifications(t *testing.T) {
       "enabled": true,
       "short_retry_after_max": "5s",
       "max_inline_wait": "12s"
-    }
+    },
+    "first_token_timeout": {
+      "default": "90s",
+      "capabilities": {"claude_messages": "2m"}
+    },
+    "stream_recovery": {
+      "enabled": true,
+      "max_attempts": 2
+    },
+    "count_tokens": "local"
   },
   "circuit_breaker": {
     "failure_threshold": 4,
@@ -518,6 +545,15 @@ func TestHandleUpdateGlobalConfig_AcceptsSnakeCaseNotifications(t *testing.T) {
 			if cfg.Global.Routing.BusyBackpressure.MaxInlineWait != "12s" {
 				t.Fatalf("expected routing.busy_backpressure.max_inline_wait=12s, got %q", cfg.Global.Routing.BusyBackpressure.MaxInlineWait)
 			}
+			if got := cfg.Global.Routing.FirstTokenTimeout; got.Default != "90s" || got.Capabilities["claude_messages"] != "2m" {
+				t.Fatalf("expected routing.first_token_timeout to be saved, got %#v", got)
+			}
+			if got := cfg.Global.Routing.StreamRecovery; !got.Enabled || got.MaxAttempts != 2 {
+				t.Fatalf("expected routing.stream_recovery to be saved, got %#v", got)
+			}
+			if cfg.Global.Routing.CountTokens != config.CountTokensModeLocal {
+				t.Fatalf("expected routing.count_tokens=local, got %q", cfg.Global.Routing.CountTokens)
+			}
 			if cfg.Global.NormalizedUpstreamProxyMode() != config.GlobalUpstreamProxyModeCustom {
 				t.Fatalf("expected upstream_proxy_mode=custom, got %q", cfg.Global.NormalizedUpstreamProxyMode())
 			}
@@ -0,0 +1,439 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/logger"
+	oauthpkg "github.com/lansespirit/Clipal/internal/oauth"
+)
+
+// Config import strategies decide what happens to providers that exist on both
+// sides. Replace makes each imported client section authoritative, merge
+// updates same-named providers with the settings the bundle carries and keeps
+// the rest, and add-only never changes anything that already exists.
+const (
+	ConfigImportReplace = "replace"
+	ConfigImportMerge   = "merge"
+	ConfigImportAddOnly = "add_only"
+)
+
+const maxConfigImportBytes = 8 << 20
+
+// HandleImportConfig previews or applies a bundle from /api/config/export.
+//
+//	POST /api/config/import?strategy=merge&dry_run=true
+func (a *API) HandleImportConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	strategy := strings.TrimSpace(r.URL.Query().Get("strategy"))
+	if strategy == "" {
+		strategy = ConfigImportMerge
+	}
+	dryRun := false
+	if raw := strings.TrimSpace(r.URL.Query().Get("dry_run")); raw != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(raw); err != nil {
+			writeError(w, fmt.Sprintf("invalid dry_run: %s", raw), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var bundle ConfigImportBundle
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxConfigImportBytes)).Decode(&bundle); err != nil {
+		writeError(w, fmt.Sprintf("invalid import bundle: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := a.ImportConfig(bundle, strategy, dryRun)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// ImportConfig computes the changes bundle would make under strategy and,
+// unless dryRun is set, writes them. OAuth credentials, the global config, and
+// the client configs are saved together: if any write or the runtime reload
+// fails, everything already written is rolled back.
+func (a *API) ImportConfig(bundle ConfigImportBundle, strategy string, dryRun bool) (ConfigImportResponse, error) {
+	switch strategy {
+	case ConfigImportReplace, ConfigImportMerge, ConfigImportAddOnly:
+	default:
+		return ConfigImportResponse{}, newAPIError(http.StatusBadRequest, fmt.Sprintf("unknown import strategy: %s", strategy), nil)
+	}
+
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+
+	before, err := config.Load(a.configDir)
+	if err != nil {
+		return ConfigImportResponse{}, newAPIError(http.StatusInternalServerError, fmt.Sprintf("failed to load config: %v", err), err)
+	}
+	next, err := config.Load(a.configDir)
+	if err != nil {
+		return ConfigImportResponse{}, newAPIError(http.StatusInternalServerError, fmt.Sprintf("failed to load config: %v", err), err)
+	}
+
+	if bundle.Global != nil && strategy != ConfigImportAddOnly {
+		if err := applyGlobalConfigRequest(&next.Global, *bundle.Global); err != nil {
+			return ConfigImportResponse{}, newAPIError(http.StatusBadRequest, err.Error(), err)
+		}
+	}
+	for _, section := range []struct {
+		clientType string
+		in         *ClientConfigImport
+	}{
+		{"claude", bundle.Claude},
+		{"openai", bundle.OpenAI},
+		{"gemini", bundle.Gemini},
+	} {
+		if section.in == nil {
+			continue
+		}
+		cc, _ := getClientConfigRef(next, section.clientType)
+		importClientConfig(cc, *section.in, strategy)
+	}
+	if err := next.Validate(); err != nil {
+		return ConfigImportResponse{}, newAPIError(http.StatusBadRequest, fmt.Sprintf("invalid configuration: %v", err), err)
+	}
+
+	creds, credChanges, err := a.planOAuthCredentialImport(bundle.OAuthCredentials, strategy)
+	if err != nil {
+		return ConfigImportResponse{}, err
+	}
+
+	resp := ConfigImportResponse{
+		Strategy: strategy,
+		DryRun:   dryRun,
+		Changes:  append(diffConfigForImport(before, next), credChanges...),
+	}
+	if len(resp.Changes) == 0 {
+		resp.Message = "nothing to import"
+		return resp, nil
+	}
+	if dryRun {
+		resp.Message = fmt.Sprintf("%d changes would be applied", len(resp.Changes))
+		return resp, nil
+	}
+
+	if err := a.applyConfigImport(before, next, creds); err != nil {
+		return ConfigImportResponse{}, err
+	}
+	resp.Applied = true
+	resp.Message = fmt.Sprintf("%d changes applied", len(resp.Changes))
+	logger.Info("configuration imported (%s, %d changes)", strategy, len(resp.Changes))
+	return resp, nil
+}
+
+func importClientConfig(cc *config.ClientConfig, in ClientConfigImport, strategy string) {
+	if strategy == ConfigImportReplace {
+		imported := make([]config.Provider, 0, len(in.Providers))
+		for _, p := range in.Providers {
+			imported = append(imported, providerFromImport(p))
+		}
+		cc.Mode = config.ClientMode(strings.TrimSpace(in.Mode))
+		cc.PinnedProvider = strings.TrimSpace(in.PinnedProvider)
+		cc.Providers = imported
+		return
+	}
+	for _, in := range in.Providers {
+		p := providerFromImport(in)
+		if existing := providerByName(cc.Providers, p.Name); existing != nil {
+			if strategy == ConfigImportMerge {
+				mergeImportedProvider(existing, p, in)
+			}
+			continue
+		}
+		cc.Providers = append(cc.Providers, p)
+	}
+	if strategy == ConfigImportMerge {
+		if mode := strings.TrimSpace(in.Mode); mode != "" {
+			cc.Mode = config.ClientMode(mode)
+		}
+		if pinned := strings.TrimSpace(in.PinnedProvider); pinned != "" {
+			cc.PinnedProvider = pinned
+		}
+	}
+}
+
+// mergeImportedProvider copies the settings the bundle carries onto an existing
+// provider. Settings the bundle leaves out keep their local values, and so
+// does the OAuth identity, which exports never include, unless the provider
+// now points at a different credential.
+func mergeImportedProvider(existing *config.Provider, p config.Provider, in ProviderImport) {
+	if p.BaseURL != "" {
+		existing.BaseURL = p.BaseURL
+	}
+	if p.APIKey != "" || len(p.APIKeys) > 0 {
+		existing.APIKey = p.APIKey
+		existing.APIKeys = p.APIKeys
+	}
+	if p.AuthType != "" {
+		existing.AuthType = p.AuthType
+	}
+	if p.OAuthProvider != "" {
+		existing.OAuthProvider = p.OAuthProvider
+	}
+	if p.OAuthRef != "" && p.OAuthRef != existing.NormalizedOAuthRef() {
+		existing.OAuthRef = p.OAuthRef
+		existing.OAuthIdentity = ""
+	}
+	if p.ProxyMode != "" {
+		existing.ProxyMode = p.ProxyMode
+		existing.ProxyURL = p.ProxyURL
+	}
+	if p.Priority > 0 {
+		existing.Priority = p.Priority
+	}
+	if p.Enabled != nil {
+		existing.Enabled = p.Enabled
+	}
+	applyProviderOverrides(existing, ProviderRequest{Overrides: in.Overrides})
+	if len(p.FailureRules) > 0 {
+		existing.FailureRules = p.FailureRules
+	}
+	if len(p.FallbackModels) > 0 {
+		existing.FallbackModels = p.FallbackModels
+	}
+}
+
+func providerFromImport(p ProviderImport) config.Provider {
+	provider := config.Provider{
+		Name:          strings.TrimSpace(p.Name),
+		BaseURL:       strings.TrimSpace(p.BaseURL),
+		APIKey:        strings.TrimSpace(p.APIKey),
+		AuthType:      p.AuthType,
+		OAuthProvider: p.OAuthProvider,
+		OAuthRef:      strings.TrimSpace(p.OAuthRef),
+		ProxyMode:     config.ProviderProxyMode(strings.TrimSpace(p.ProxyMode)),
+		ProxyURL:      strings.TrimSpace(p.ProxyURL),
+		Priority:      p.Priority,
+		Enabled:       p.Enabled,
+	}
+	for _, key := range p.APIKeys {
+		if key = strings.TrimSpace(key); key != "" {
+			provider.APIKeys = append(provider.APIKeys, key)
+		}
+	}
+	applyProviderOverrides(&provider, ProviderRequest{Overrides: p.Overrides})
+	if len(p.FailureRules) > 0 {
+		provider.FailureRules = failureRulesFromRequest(p.FailureRules)
+	}
+	if len(p.FallbackModels) > 0 {
+		provider.FallbackModels = config.FallbackModels(p.FallbackModels)
+	}
+	return provider
+}
+
+// planOAuthCredentialImport picks the credentials to save and describes them.
+// Add-only skips credentials that already exist.
+func (a *API) planOAuthCredentialImport(creds []oauthpkg.Credential, strategy string) ([]oauthpkg.Credential, []ConfigImportChange, error) {
+	var planned []oauthpkg.Credential
+	var changes []ConfigImportChange
+	for _, cred := range creds {
+		cred.Provider = config.OAuthProvider(strings.ToLower(strings.TrimSpace(string(cred.Provider))))
+		cred.Ref = strings.TrimSpace(cred.Ref)
+		switch cred.Provider {
+		case config.OAuthProviderClaude, config.OAuthProviderCodex, config.OAuthProviderGemini:
+		default:
+			return nil, nil, newAPIError(http.StatusBadRequest, fmt.Sprintf("unsupported oauth credential provider: %q", cred.Provider), nil)
+		}
+		if cred.Ref == "" {
+			return nil, nil, newAPIError(http.StatusBadRequest, "oauth credential ref is required", nil)
+		}
+		item := string(cred.Provider) + "/" + cred.Ref
+		existing, err := a.oauth.Store().Load(cred.Provider, cred.Ref)
+		switch {
+		case err != nil:
+			changes = append(changes, ConfigImportChange{Section: "oauth", Item: item, Action: "add"})
+		case strategy == ConfigImportAddOnly:
+			continue
+		default:
+			fields := diffJSONFields(existing, &cred)
+			if len(fields) == 0 {
+				continue
+			}
+			changes = append(changes, ConfigImportChange{Section: "oauth", Item: item, Action: "update", Fields: fields})
+		}
+		planned = append(planned, cred)
+	}
+	return planned, changes, nil
+}
+
+func (a *API) applyConfigImport(before, next *config.Config, creds []oauthpkg.Credential) error {
+	var restores, finalizers []func() error
+	fail := func(status int, baseErr error) error {
+		for i := len(restores) - 1; i >= 0; i-- {
+			if restoreErr := restores[i](); restoreErr != nil {
+				baseErr = fmt.Errorf("%w (rollback failed: %v)", baseErr, restoreErr)
+			}
+		}
+		return newAPIError(status, baseErr.Error(), baseErr)
+	}
+
+	snapshotted := make(map[config.OAuthProvider]bool)
+	for i := range creds {
+		provider := creds[i].Provider
+		if !snapshotted[provider] {
+			snapshotted[provider] = true
+			restore, finalize, err := snapshotOAuthImportProviderDir(filepath.Join(a.configDir, "oauth", string(provider)))
+			if err != nil {
+				return fail(http.StatusInternalServerError, fmt.Errorf("failed to prepare oauth import rollback: %w", err))
+			}
+			restores = append(restores, restore)
+			finalizers = append(finalizers, finalize)
+		}
+		importedRef := creds[i].Ref
+		if err := a.oauth.Store().Save(&creds[i]); err != nil {
+			return fail(http.StatusInternalServerError, fmt.Errorf("failed to save oauth credential %s/%s: %w", provider, importedRef, err))
+		}
+		// The store renames a ref that collides with a different account.
+		if creds[i].Ref != importedRef {
+			relinkImportedOAuthRef(next, provider, importedRef, creds[i].Ref)
+		}
+	}
+
+	if !bytes.Equal(formatGlobalConfigYAML(before.Global), formatGlobalConfigYAML(next.Global)) {
+		restore, err := a.saveGlobalConfigWithRollback(next.Global)
+		if err != nil {
+			return fail(http.StatusInternalServerError, fmt.Errorf("failed to save config: %w", err))
+		}
+		restores = append(restores, restore)
+	}
+	for _, clientType := range []string{"claude", "openai", "gemini"} {
+		was, _ := getClientConfigRef(before, clientType)
+		now, _ := getClientConfigRef(next, clientType)
+		if bytes.Equal(formatClientConfigYAML(clientType, *was), formatClientConfigYAML(clientType, *now)) {
+			continue
+		}
+		restore, err := a.saveClientConfigWithRollback(clientType, *now)
+		if err != nil {
+			return fail(http.StatusInternalServerError, fmt.Errorf("failed to save config: %w", err))
+		}
+		restores = append(restores, restore)
+	}
+
+	if err := a.reloadRuntimeProviderConfigs(); err != nil {
+		return fail(http.StatusInternalServerError, fmt.Errorf("failed to apply saved config: %w", err))
+	}
+	for _, finalize := range finalizers {
+		if err := finalize(); err != nil {
+			logger.Warn("failed to finalize config import snapshot: %v", err)
+		}
+	}
+	return nil
+}
+
+func relinkImportedOAuthRef(cfg *config.Config, provider config.OAuthProvider, from, to string) {
+	for _, cc := range []*config.ClientConfig{&cfg.Claude, &cfg.OpenAI, &cfg.Gemini} {
+		for i := range cc.Providers {
+			p := &cc.Providers[i]
+			if p.UsesOAuth() && p.NormalizedOAuthProvider() == provider && p.NormalizedOAuthRef() == from {
+				p.OAuthRef = to
+			}
+		}
+	}
+}
+
+// diffConfigForImport lists what changes between before and after, in the
+// shapes the export uses.
+func diffConfigForImport(before, after *config.Config) []ConfigImportChange {
+	var changes []ConfigImportChange
+	if fields := diffJSONFields(toGlobalConfigResponse(before.Global), toGlobalConfigResponse(after.Global)); len(fields) > 0 {
+		changes = append(changes, ConfigImportChange{Section: "global", Action: "update", Fields: fields})
+	}
+	for _, clientType := range []string{"claude", "openai", "gemini"} {
+		was, _ := getClientConfigRef(before, clientType)
+		now, _ := getClientConfigRef(after, clientType)
+		oldExport, newExport := toClientConfigExport(*was), toClientConfigExport(*now)
+
+		var fields []string
+		if oldExport.Mode != newExport.Mode {
+			fields = append(fields, "mode")
+		}
+		if oldExport.PinnedProvider != newExport.PinnedProvider {
+			fields = append(fields, "pinned_provider")
+		}
+		if len(fields) > 0 {
+			changes = append(changes, ConfigImportChange{Section: clientType, Action: "update", Fields: fields})
+		}
+
+		oldByName := make(map[string]ProviderExport, len(oldExport.Providers))
+		for _, p := range oldExport.Providers {
+			oldByName[p.Name] = p
+		}
+		for _, p := range newExport.Providers {
+			old, ok := oldByName[p.Name]
+			delete(oldByName, p.Name)
+			if !ok {
+				changes = append(changes, ConfigImportChange{Section: clientType, Item: p.Name, Action: "add"})
+				continue
+			}
+			if fields := diffJSONFields(old, p); len(fields) > 0 {
+				changes = append(changes, ConfigImportChange{Section: clientType, Item: p.Name, Action: "update", Fields: fields})
+			}
+		}
+		removed := make([]string, 0, len(oldByName))
+		for name := range oldByName {
+			removed = append(removed, name)
+		}
+		sort.Strings(removed)
+		for _, name := range removed {
+			changes = append(changes, ConfigImportChange{Section: clientType, Item: name, Action: "remove"})
+		}
+	}
+	return changes
+}
+
+// diffJSONFields returns the dotted JSON paths whose values differ between a
+// and b, sorted.
+func diffJSONFields(a, b any) []string {
+	var left, right any
+	if data, err := json.Marshal(a); err == nil {
+		_ = json.Unmarshal(data, &left)
+	}
+	if data, err := json.Marshal(b); err == nil {
+		_ = json.Unmarshal(data, &right)
+	}
+	var fields []string
+	collectJSONDiff("", left, right, &fields)
+	sort.Strings(fields)
+	return fields
+}
+
+func collectJSONDiff(path string, a, b any, fields *[]string) {
+	left, leftIsObject := a.(map[string]any)
+	right, rightIsObject := b.(map[string]any)
+	if !leftIsObject || !rightIsObject {
+		if !reflect.DeepEqual(a, b) {
+			*fields = append(*fields, path)
+		}
+		return
+	}
+	keys := make(map[string]struct{}, len(left)+len(right))
+	for k := range left {
+		keys[k] = struct{}{}
+	}
+	for k := range right {
+		keys[k] = struct{}{}
+	}
+	for k := range keys {
+		child := k
+		if path != "" {
+			child = path + "." + k
+		}
+		collectJSONDiff(child, left[k], right[k], fields)
+	}
+}
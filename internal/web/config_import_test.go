@@ -0,0 +1,219 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	oauthpkg "github.com/lansespirit/Clipal/internal/oauth"
+)
+
+func exportConfigBundle(t *testing.T, api *API, includeOAuth bool) []byte {
+	t.Helper()
+	target := "/api/config/export"
+	if includeOAuth {
+		target += "?include_oauth=true"
+	}
+	w := httptest.NewRecorder()
+	api.HandleExportConfig(w, httptest.NewRequest(http.MethodGet, target, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("export status=%d body=%s", w.Code, w.Body.String())
+	}
+	return w.Body.Bytes()
+}
+
+func importConfigBundle(t *testing.T, api *API, query string, bundle []byte) (int, ConfigImportResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	api.HandleImportConfig(w, httptest.NewRequest(http.MethodPost, "/api/config/import?"+query, bytes.NewReader(bundle)))
+	var resp ConfigImportResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+	}
+	return w.Code, resp
+}
+
+func writeOpenAIProviders(t *testing.T, dir string, providers ...config.Provider) {
+	t.Helper()
+	cc := config.ClientConfig{Mode: config.ClientModeAuto, Providers: providers}
+	if err := os.WriteFile(filepath.Join(dir, "openai.yaml"), formatClientConfigYAML("openai", cc), 0o600); err != nil {
+		t.Fatalf("write openai.yaml: %v", err)
+	}
+}
+
+func changeKeys(changes []ConfigImportChange) []string {
+	out := make([]string, 0, len(changes))
+	for _, c := range changes {
+		out = append(out, c.Section+":"+c.Item+":"+c.Action)
+	}
+	return out
+}
+
+func TestImportConfig_StrategiesPreviewAndApply(t *testing.T) {
+	srcDir := t.TempDir()
+	writeOpenAIProviders(t, srcDir,
+		config.Provider{Name: "shared", BaseURL: "https://new.example.com", APIKey: "new-key", Priority: 1,
+			FailureRules: []config.FailureRule{{Status: []int{400}, BodyRegex: "quota", Action: config.FailureRuleActionRetryNext}}},
+		config.Provider{Name: "fresh", BaseURL: "https://fresh.example.com", APIKey: "fresh-key", Priority: 2},
+	)
+	bundle := exportConfigBundle(t, NewAPI(srcDir, "test", nil), false)
+
+	for _, tt := range []struct {
+		strategy  string
+		want      []string
+		names     []string
+		sharedURL string
+	}{
+		{ConfigImportAddOnly, []string{"openai:fresh:add"}, []string{"shared", "fresh", "local"}, "https://old.example.com"},
+		{ConfigImportMerge, []string{"openai:shared:update", "openai:fresh:add"}, []string{"shared", "fresh", "local"}, "https://new.example.com"},
+		{ConfigImportReplace, []string{"openai:shared:update", "openai:fresh:add", "openai:local:remove"}, []string{"shared", "fresh"}, "https://new.example.com"},
+	} {
+		t.Run(tt.strategy, func(t *testing.T) {
+			dir := t.TempDir()
+			writeOpenAIProviders(t, dir,
+				config.Provider{Name: "shared", BaseURL: "https://old.example.com", APIKey: "old-key", Priority: 1},
+				config.Provider{Name: "local", BaseURL: "https://local.example.com", APIKey: "local-key", Priority: 3},
+			)
+			api := NewAPI(dir, "test", nil)
+			before, _ := os.ReadFile(filepath.Join(dir, "openai.yaml"))
+
+			status, preview := importConfigBundle(t, api, "strategy="+tt.strategy+"&dry_run=true", bundle)
+			if status != http.StatusOK || preview.Applied || !slices.Contains(changeKeys(preview.Changes), tt.want[0]) {
+				t.Fatalf("preview status=%d resp=%#v", status, preview)
+			}
+			for _, key := range changeKeys(preview.Changes) {
+				if key[:7] == "openai:" && !slices.Contains(tt.want, key) {
+					t.Fatalf("unexpected change %s in %v", key, changeKeys(preview.Changes))
+				}
+			}
+			if after, _ := os.ReadFile(filepath.Join(dir, "openai.yaml")); !bytes.Equal(before, after) {
+				t.Fatalf("dry run wrote openai.yaml")
+			}
+
+			status, applied := importConfigBundle(t, api, "strategy="+tt.strategy, bundle)
+			if status != http.StatusOK || !applied.Applied {
+				t.Fatalf("apply status=%d resp=%#v", status, applied)
+			}
+			cfg, err := config.Load(dir)
+			if err != nil {
+				t.Fatalf("config.Load: %v", err)
+			}
+			var names []string
+			for _, p := range cfg.OpenAI.Providers {
+				names = append(names, p.Name)
+			}
+			if !slices.Equal(names, tt.names) {
+				t.Fatalf("providers = %v, want %v", names, tt.names)
+			}
+			shared := providerByName(cfg.OpenAI.Providers, "shared")
+			if shared.BaseURL != tt.sharedURL {
+				t.Fatalf("shared base_url = %s, want %s", shared.BaseURL, tt.sharedURL)
+			}
+			if tt.strategy != ConfigImportAddOnly && len(shared.FailureRules) != 1 {
+				t.Fatalf("failure rules were not imported: %#v", shared.FailureRules)
+			}
+
+			if _, again := importConfigBundle(t, api, "strategy="+tt.strategy, bundle); again.Applied || len(again.Changes) != 0 {
+				t.Fatalf("re-import was not a no-op: %#v", again)
+			}
+		})
+	}
+}
+
+func TestImportConfig_ImportsOAuthCredentialsAndRejectsInvalidBundles(t *testing.T) {
+	srcDir := t.TempDir()
+	src := NewAPI(srcDir, "test", nil)
+	cred := &oauthpkg.Credential{Ref: "acct-1", Provider: config.OAuthProviderCodex, Email: "me@example.com", AccessToken: "at", RefreshToken: "rt"}
+	if err := src.oauth.Store().Save(cred); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	writeOpenAIProviders(t, srcDir, config.Provider{Name: "codex", AuthType: config.ProviderAuthTypeOAuth, OAuthProvider: config.OAuthProviderCodex, OAuthRef: cred.Ref, Priority: 1})
+	bundle := exportConfigBundle(t, src, true)
+
+	dir := t.TempDir()
+	api := NewAPI(dir, "test", nil)
+	status, resp := importConfigBundle(t, api, "strategy=merge", bundle)
+	if status != http.StatusOK || !slices.Contains(changeKeys(resp.Changes), "oauth:codex/"+cred.Ref+":add") {
+		t.Fatalf("status=%d resp=%#v", status, resp)
+	}
+	loaded, err := api.oauth.Store().Load(config.OAuthProviderCodex, cred.Ref)
+	if err != nil || loaded.RefreshToken != "rt" {
+		t.Fatalf("imported credential = %#v, %v", loaded, err)
+	}
+
+	// A bundle that fails validation writes nothing.
+	invalid := []byte(`{"openai":{"mode":"manual","pinned_provider":"missing","providers":[{"name":"p","base_url":"https://x","api_key":"k","priority":1}]},
+		"oauth_credentials":[{"ref":"acct-2","provider":"codex","access_token":"x"}]}`)
+	if status, _ := importConfigBundle(t, api, "strategy=replace", invalid); status != http.StatusBadRequest {
+		t.Fatalf("invalid bundle status = %d", status)
+	}
+	if _, err := api.oauth.Store().Load(config.OAuthProviderCodex, "acct-2"); err == nil {
+		t.Fatalf("credential from rejected bundle was saved")
+	}
+	if status, _ := importConfigBundle(t, api, "strategy=overwrite", bundle); status != http.StatusBadRequest {
+		t.Fatalf("unknown strategy status = %d", status)
+	}
+}
+
+func TestImportConfig_MergeKeepsSettingsTheBundleLacks(t *testing.T) {
+	srcDir := t.TempDir()
+	codex := config.Provider{Name: "codex", AuthType: config.ProviderAuthTypeOAuth, OAuthProvider: config.OAuthProviderCodex, OAuthRef: "acct-1", Priority: 2}
+	writeOpenAIProviders(t, srcDir, codex)
+	bundle := exportConfigBundle(t, NewAPI(srcDir, "test", nil), false)
+
+	dir := t.TempDir()
+	local := codex
+	local.Priority = 1
+	local.OAuthIdentity = "codex:me@example.com"
+	local.Overrides = &config.ProviderOverrides{Model: ptr("gpt-5-codex")}
+	writeOpenAIProviders(t, dir, local)
+	api := NewAPI(dir, "test", nil)
+
+	if status, resp := importConfigBundle(t, api, "strategy=merge", bundle); status != http.StatusOK || !resp.Applied {
+		t.Fatalf("status=%d resp=%#v", status, resp)
+	}
+	cfg, err := config.Load(dir)
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	got := providerByName(cfg.OpenAI.Providers, "codex")
+	if got.Priority != 2 {
+		t.Fatalf("priority = %d, want the imported 2", got.Priority)
+	}
+	if got.OAuthIdentity != local.OAuthIdentity {
+		t.Fatalf("oauth_identity = %q, want %q", got.OAuthIdentity, local.OAuthIdentity)
+	}
+	if got.Overrides == nil || got.Overrides.Model == nil || *got.Overrides.Model != "gpt-5-codex" {
+		t.Fatalf("local overrides were dropped: %#v", got.Overrides)
+	}
+}
+
+func TestImportConfig_MergeKeepsPriorityWhenBundleOmitsIt(t *testing.T) {
+	dir := t.TempDir()
+	writeOpenAIProviders(t, dir,
+		config.Provider{Name: "p1", BaseURL: "https://p1.example", APIKey: "k1", Priority: 1},
+		config.Provider{Name: "p2", BaseURL: "https://p2.example", APIKey: "k2", Priority: 2},
+	)
+	api := NewAPI(dir, "test", nil)
+
+	bundle := []byte(`{"openai":{"providers":[{"name":"p2","base_url":"https://p2-new.example"}]}}`)
+	if status, resp := importConfigBundle(t, api, "strategy=merge", bundle); status != http.StatusOK || !resp.Applied {
+		t.Fatalf("status=%d resp=%#v", status, resp)
+	}
+	cfg, err := config.Load(dir)
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	got := providerByName(cfg.OpenAI.Providers, "p2")
+	if got.BaseURL != "https://p2-new.example" || got.Priority != 2 {
+		t.Fatalf("merged provider = %#v, want the new base_url and priority 2", got)
+	}
+}
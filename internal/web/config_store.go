@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 
 	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/fsutil"
 )
 
 func (a *API) loadConfigOrWriteError(w http.ResponseWriter) *config.Config {
@@ -103,7 +104,7 @@ func (b configFileBackup) restore() error {
 		}
 		return nil
 	}
-	return atomicWriteFile(b.path, b.data, b.perm)
+	return fsutil.AtomicWriteFile(b.path, b.data, b.perm)
 }
 
 func saveConfigFileWithRollback(path string, data []byte, perm fs.FileMode) (func() error, error) {
@@ -111,7 +112,7 @@ func saveConfigFileWithRollback(path string, data []byte, perm fs.FileMode) (fun
 	if err != nil {
 		return nil, err
 	}
-	if err := atomicWriteFile(path, data, perm); err != nil {
+	if err := fsutil.AtomicWriteFile(path, data, perm); err != nil {
 		return nil, err
 	}
 	return backup.restore, nil
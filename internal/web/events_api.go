@@ -0,0 +1,116 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/proxy"
+)
+
+// eventsHeartbeatInterval keeps idle event streams from being closed by
+// intermediaries and lets the handler notice disconnected clients.
+var eventsHeartbeatInterval = 15 * time.Second
+
+// HandleEvents streams runtime events as server-sent events.
+//
+//	GET /api/events?type=request_completed,attempt_failed&client_type=claude&provider=p1
+//
+// Recent events are replayed first. A reconnecting EventSource resumes after
+// its Last-Event-ID; other clients can pass since=<id> instead.
+func (a *API) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.runtime == nil {
+		writeError(w, "events are only available while the proxy is running", http.StatusServiceUnavailable)
+		return
+	}
+	filter, afterID, err := parseEventsQuery(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	recent, events, cancel := a.runtime.SubscribeEvents(filter, afterID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	for _, event := range recent {
+		if err := writeServerSentEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			if err := writeServerSentEvent(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func parseEventsQuery(r *http.Request) (proxy.EventFilter, uint64, error) {
+	q := r.URL.Query()
+	var filter proxy.EventFilter
+	for _, raw := range strings.Split(q.Get("type"), ",") {
+		eventType := proxy.EventType(strings.TrimSpace(raw))
+		if eventType == "" {
+			continue
+		}
+		if !slices.Contains(proxy.EventTypes, eventType) {
+			return filter, 0, fmt.Errorf("unknown event type: %s", eventType)
+		}
+		filter.Types = append(filter.Types, eventType)
+	}
+	filter.ClientType = strings.TrimSpace(q.Get("client_type"))
+	filter.Provider = strings.TrimSpace(q.Get("provider"))
+
+	since := strings.TrimSpace(r.Header.Get("Last-Event-ID"))
+	if since == "" {
+		since = strings.TrimSpace(q.Get("since"))
+	}
+	if since == "" {
+		return filter, 0, nil
+	}
+	afterID, err := strconv.ParseUint(since, 10, 64)
+	if err != nil {
+		return filter, 0, fmt.Errorf("invalid event id: %s", since)
+	}
+	return filter, afterID, nil
+}
+
+func writeServerSentEvent(w http.ResponseWriter, event proxy.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleEvents_ReplaysFilteredRecentEvents(t *testing.T) {
+	api, router, _, _ := newRuntimeAPI(t)
+	for range 2 {
+		if err := router.ReloadProviderConfigs(); err != nil {
+			t.Fatalf("ReloadProviderConfigs: %v", err)
+		}
+	}
+
+	// A canceled request replays the buffer and returns instead of waiting
+	// for live events.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/api/events?type=config_reloaded", nil).WithContext(ctx)
+	req.Header.Set("Last-Event-ID", "1")
+	w := httptest.NewRecorder()
+	api.HandleEvents(w, req)
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status = %d content-type = %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	body := w.Body.String()
+	if strings.Count(body, "event: config_reloaded\n") != 1 || !strings.Contains(body, "id: 2\n") || !strings.Contains(body, `"result":"reloaded"`) {
+		t.Fatalf("unexpected stream:\n%s", body)
+	}
+}
+
+func TestHandleEvents_RejectsUnknownTypeAndNeedsRuntime(t *testing.T) {
+	api, _, _, _ := newRuntimeAPI(t)
+	w := httptest.NewRecorder()
+	api.HandleEvents(w, httptest.NewRequest(http.MethodGet, "/api/events?type=bogus", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown type: status = %d body=%s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	NewAPI(t.TempDir(), "test", nil).HandleEvents(w, httptest.NewRequest(http.MethodGet, "/api/events", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("without runtime: status = %d body=%s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,105 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/proxy"
+)
+
+// HandleTestFailureRules classifies a sample upstream response against the
+// configured (or draft) failure rules without contacting any provider.
+func (a *API) HandleTestFailureRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req FailureRuleTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Status < 100 || req.Status > 599 {
+		writeError(w, "status must be between 100 and 599", http.StatusBadRequest)
+		return
+	}
+	req.Provider = strings.TrimSpace(req.Provider)
+	if req.Provider != "" && strings.TrimSpace(req.ClientType) == "" {
+		writeError(w, "client_type is required when provider is set", http.StatusBadRequest)
+		return
+	}
+
+	var draft []config.FailureRule
+	if req.Rules != nil {
+		draft = failureRulesFromRequest(*req.Rules)
+		if err := config.ValidateFailureRules("rules", draft); err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	cfg := a.loadConfigOrWriteError(w)
+	if cfg == nil {
+		return
+	}
+
+	globalRules := cfg.Global.Routing.FailureRules
+	var providerRules []config.FailureRule
+	if req.Provider != "" {
+		cc, err := getClientConfigRef(cfg, req.ClientType)
+		if err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		provider := providerByName(cc.Providers, req.Provider)
+		if provider == nil {
+			writeError(w, "provider not found", http.StatusNotFound)
+			return
+		}
+		providerRules = provider.FailureRules
+		if req.Rules != nil {
+			providerRules = draft
+		}
+	} else if req.Rules != nil {
+		globalRules = draft
+	}
+
+	durations, err := cfg.Global.RuntimeDurations()
+	if err != nil {
+		durations = config.DefaultRuntimeDurations()
+	}
+	hdr := make(http.Header, len(req.Headers))
+	for name, value := range req.Headers {
+		hdr.Set(name, value)
+	}
+
+	result, err := proxy.EvaluateFailureRules(providerRules, globalRules, proxy.FailureRuleSample{
+		Status: req.Status,
+		Header: hdr,
+		Body:   []byte(req.Body),
+	}, durations.ReactivateAfter)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := FailureRuleTestResponse{
+		Matched:  result.Matched,
+		Source:   result.Source,
+		RuleName: result.RuleName,
+		Action:   string(result.Action),
+		Reason:   result.Reason,
+	}
+	if result.Matched {
+		ruleIndex := result.RuleIndex
+		resp.RuleIndex = &ruleIndex
+	}
+	if result.Cooldown > 0 {
+		resp.Cooldown = result.Cooldown.String()
+	}
+	writeJSON(w, resp)
+}
@@ -0,0 +1,114 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func postFailureRuleTest(t *testing.T, api *API, body string) (*httptest.ResponseRecorder, FailureRuleTestResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/failure-rules/test", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	api.HandleTestFailureRules(w, req)
+
+	var resp FailureRuleTestResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("json.Unmarshal: %v body=%s", err, w.Body.String())
+		}
+	}
+	return w, resp
+}
+
+func TestHandleTestFailureRules_UsesConfiguredProviderAndGlobalRules(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(`
+routing:
+  failure_rules:
+    - name: region
+      status: [403]
+      body_regex: "unsupported region"
+      action: deactivate_provider
+      cooldown: 6h
+`), 0o600); err != nil {
+		t.Fatalf("WriteFile config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "openai.yaml"), []byte(`
+providers:
+  - name: p1
+    base_url: https://example.com
+    api_key: sk-1
+    priority: 1
+    failure_rules:
+      - name: balance
+        status: [400]
+        json_path: error.code
+        json_value: ^insufficient_balance$
+        action: deactivate_key
+        reason: billing
+`), 0o600); err != nil {
+		t.Fatalf("WriteFile openai: %v", err)
+	}
+	api := NewAPI(dir, "test", nil)
+
+	w, resp := postFailureRuleTest(t, api, `{"client_type":"openai","provider":"p1","status":400,"body":"{\"error\":{\"code\":\"insufficient_balance\"}}"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	if !resp.Matched || resp.Source != "provider" || resp.RuleName != "balance" || resp.Action != "deactivate_key" || resp.Reason != "billing" || resp.Cooldown != "1h0m0s" {
+		t.Fatalf("provider rule response = %+v", resp)
+	}
+	if resp.RuleIndex == nil || *resp.RuleIndex != 0 {
+		t.Fatalf("rule_index = %v, want 0", resp.RuleIndex)
+	}
+
+	w, resp = postFailureRuleTest(t, api, `{"client_type":"openai","provider":"p1","status":403,"body":"unsupported region"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	if !resp.Matched || resp.Source != "global" || resp.RuleName != "region" || resp.Action != "deactivate_provider" || resp.Cooldown != "6h0m0s" {
+		t.Fatalf("global rule response = %+v", resp)
+	}
+
+	w, resp = postFailureRuleTest(t, api, `{"status":503,"headers":{"Retry-After":"7"},"body":"upstream down"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	if resp.Matched || resp.Source != "builtin" || resp.Action != "retry_next" || resp.Reason != "server" || resp.Cooldown != "7s" || resp.RuleIndex != nil {
+		t.Fatalf("builtin response = %+v", resp)
+	}
+}
+
+func TestHandleTestFailureRules_DraftRulesAndValidation(t *testing.T) {
+	api := NewAPI(t.TempDir(), "test", nil)
+
+	w, resp := postFailureRuleTest(t, api, `{"rules":[{"status":[200],"body_regex":"event: error","action":"retry_next"}],"status":200,"body":"event: error\ndata: {}\n\n"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	if !resp.Matched || resp.Source != "global" || resp.RuleName != "#1" || resp.Action != "retry_next" {
+		t.Fatalf("draft response = %+v", resp)
+	}
+
+	for _, body := range []string{
+		`{"rules":[{"status":[400],"action":"explode"}],"status":400}`,
+		`{"status":42}`,
+		`{"provider":"p1","status":400}`,
+	} {
+		w, _ = postFailureRuleTest(t, api, body)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("body %s: status=%d want %d (%s)", body, w.Code, http.StatusBadRequest, w.Body.String())
+		}
+	}
+
+	w, _ = postFailureRuleTest(t, api, `{"client_type":"openai","provider":"missing","status":400}`)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("missing provider status=%d want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -152,6 +152,9 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/config/global", h.localOnly(h.api.HandleGetGlobalConfig))
 	mux.HandleFunc("/api/config/global/update", h.localOnly(h.api.HandleUpdateGlobalConfig))
 	mux.HandleFunc("/api/config/export", h.localOnly(h.api.HandleExportConfig))
+	mux.HandleFunc("/api/config/import", h.localOnly(h.api.HandleImportConfig))
+	mux.HandleFunc("/api/config/revisions", h.localOnly(h.api.HandleListConfigRevisions))
+	mux.HandleFunc("/api/config/revisions/", h.localOnly(h.routeConfigRevisions))
 	mux.HandleFunc("/api/integrations", h.localOnly(h.api.HandleListIntegrations))
 	mux.HandleFunc("/api/integrations/", h.localOnly(h.routeIntegrations))
 
@@ -159,6 +162,18 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/providers/", h.localOnly(h.routeProviders))
 	mux.HandleFunc("/api/oauth/", h.localOnly(h.routeOAuth))
 	mux.HandleFunc("/api/status", h.localOnly(h.api.HandleGetStatus))
+	mux.HandleFunc("/api/events", h.localOnly(h.api.HandleEvents))
+	mux.HandleFunc("/api/logs", h.localOnly(h.api.HandleSearchLogs))
+	mux.HandleFunc("/api/logs/files", h.localOnly(h.api.HandleListLogFiles))
+	mux.HandleFunc("/api/logs/tail", h.localOnly(h.api.HandleTailLogs))
+	mux.HandleFunc("/api/routing/sticky/", h.localOnly(h.api.HandleStickyBindings))
+	mux.HandleFunc("/api/routing/explain", h.localOnly(h.api.HandleExplainRouting))
+	mux.HandleFunc("/api/failure-rules/test", h.localOnly(h.api.HandleTestFailureRules))
+	mux.HandleFunc("/api/usage/requests", h.localOnly(h.api.HandleListUsageRequests))
+	mux.HandleFunc("/api/usage/reset", h.localOnly(h.api.HandleResetUsage))
+	mux.HandleFunc("/api/pricing", h.localOnly(h.api.HandlePricing))
+	mux.HandleFunc("/api/pricing/recompute", h.localOnly(h.api.HandleRecomputePricing))
+	mux.HandleFunc("/metrics", h.localOnly(h.api.HandleMetrics))
 
 	// Service management (OS background service for clipal)
 	mux.HandleFunc("/api/service/status", h.localOnly(h.api.HandleServiceStatus))
@@ -176,6 +191,23 @@ func (h *Handler) routeClientConfig(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// routeConfigRevisions routes /api/config/revisions/{id}/diff and /restore.
+func (h *Handler) routeConfigRevisions(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/config/revisions/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	switch parts[1] {
+	case "diff":
+		h.api.HandleDiffConfigRevision(w, r, parts[0])
+	case "restore":
+		h.api.HandleRestoreConfigRevision(w, r, parts[0])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
 func (h *Handler) routeIntegrations(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
@@ -243,8 +275,28 @@ func (h *Handler) routeProviders(w http.ResponseWriter, r *http.Request) {
 		h.api.HandleReorderProviders(w, r)
 		return
 	}
+	if strings.HasSuffix(path, "/_test") {
+		h.api.HandleTestProvider(w, r)
+		return
+	}
+	if strings.HasSuffix(path, "/_models") {
+		h.api.HandleListProviderModels(w, r)
+		return
+	}
 
 	clientType, providerName, subresource := extractClientProviderSubresource(path)
+	if clientType != "" && providerName != "" && subresource == "test" {
+		h.api.HandleTestProvider(w, r)
+		return
+	}
+	if clientType != "" && providerName != "" && subresource == "models" {
+		h.api.HandleListProviderModels(w, r)
+		return
+	}
+	if clientType != "" && providerName != "" && subresource == "runtime" {
+		h.api.HandleControlProviderRuntime(w, r)
+		return
+	}
 	if clientType != "" && providerName != "" && subresource == "oauth-metadata" {
 		switch r.Method {
 		case http.MethodGet:
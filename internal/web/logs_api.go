@@ -0,0 +1,210 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/logger"
+	"github.com/lansespirit/Clipal/internal/telemetry"
+)
+
+const (
+	logsDefaultLimit = 200
+	logsMaxLimit     = 2000
+	logsTailDefault  = 100
+)
+
+// HandleListLogFiles lists the rotated log files, newest first.
+//
+//	GET /api/logs/files
+func (a *API) HandleListLogFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cfg := a.loadConfigOrWriteError(w)
+	if cfg == nil {
+		return
+	}
+	dir := logger.Dir(a.configDir, cfg.Global.LogDir)
+	files, err := logger.ListFiles(dir)
+	if err != nil {
+		writeError(w, fmt.Sprintf("failed to list log files: %v", err), http.StatusInternalServerError)
+		return
+	}
+	resp := LogFilesResponse{Dir: dir, Files: make([]LogFile, 0, len(files))}
+	for _, f := range files {
+		resp.Files = append(resp.Files, LogFile{Name: f.Name, Day: f.Day, Size: f.Size, Modified: f.ModTime.Format(time.RFC3339)})
+	}
+	writeJSON(w, resp)
+}
+
+// HandleSearchLogs searches the retained log files. The newest limit matches
+// are returned, oldest first.
+//
+//	GET /api/logs?level=warn&client_type=claude&provider=p1&q=timeout&since=24h&limit=200
+func (a *API) HandleSearchLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query, err := parseLogsQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err := parseLogsLimit(r.URL.Query().Get("limit"), logsDefaultLimit)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cfg := a.loadConfigOrWriteError(w)
+	if cfg == nil {
+		return
+	}
+	entries, err := logger.Search(logger.Dir(a.configDir, cfg.Global.LogDir), query, limit)
+	if err != nil {
+		writeError(w, fmt.Sprintf("failed to search logs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	resp := LogEntriesResponse{Entries: make([]LogEntry, 0, len(entries))}
+	for _, e := range entries {
+		resp.Entries = append(resp.Entries, toLogEntry(e))
+	}
+	writeJSON(w, resp)
+}
+
+// HandleTailLogs streams the log as server-sent "log" events: the last lines
+// matching entries first, then new ones as they are written.
+//
+//	GET /api/logs/tail?lines=100&level=info&provider=p1
+func (a *API) HandleTailLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query, err := parseLogsQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lines, err := parseLogsLimit(r.URL.Query().Get("lines"), logsTailDefault)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cfg := a.loadConfigOrWriteError(w)
+	if cfg == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	dir := logger.Dir(a.configDir, cfg.Global.LogDir)
+	recent, err := logger.Search(dir, query, lines)
+	if err != nil {
+		writeError(w, fmt.Sprintf("failed to read logs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	entries := make(chan logger.Entry, 64)
+	go func() {
+		_ = logger.Follow(ctx, dir, query, func(e logger.Entry) {
+			select {
+			case entries <- e:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	for _, e := range recent {
+		if err := writeLogEvent(w, e); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-entries:
+			if err := writeLogEvent(w, e); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func parseLogsQuery(values url.Values, now time.Time) (logger.Query, error) {
+	query := logger.Query{
+		Level:    strings.TrimSpace(values.Get("level")),
+		Client:   strings.TrimSpace(values.Get("client_type")),
+		Provider: strings.TrimSpace(values.Get("provider")),
+		Text:     strings.TrimSpace(values.Get("q")),
+	}
+	if err := query.Validate(); err != nil {
+		return query, err
+	}
+	var err error
+	if query.Since, err = telemetry.ParseLedgerTime(values.Get("since"), now); err != nil {
+		return query, fmt.Errorf("invalid since: %v", err)
+	}
+	if query.Until, err = telemetry.ParseLedgerTime(values.Get("until"), now); err != nil {
+		return query, fmt.Errorf("invalid until: %v", err)
+	}
+	return query, nil
+}
+
+func parseLogsLimit(raw string, fallback int) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return fallback, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("invalid limit: %s", raw)
+	}
+	return min(limit, logsMaxLimit), nil
+}
+
+// toLogEntry redacts the message on its way out of the process.
+func toLogEntry(e logger.Entry) LogEntry {
+	return LogEntry{
+		Time:    e.Time.Format(time.RFC3339Nano),
+		Level:   e.Level,
+		Client:  e.Client,
+		Message: logger.Redact(e.Message),
+	}
+}
+
+func writeLogEvent(w http.ResponseWriter, e logger.Entry) error {
+	data, err := json.Marshal(toLogEntry(e))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: log\ndata: %s\n\n", data)
+	return err
+}
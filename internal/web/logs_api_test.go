@@ -0,0 +1,83 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeLogFixture(t *testing.T, configDir string) {
+	t.Helper()
+	dir := filepath.Join(configDir, "logs")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	lines := []string{
+		"[INFO ] 2026-03-01 10:00:00.000 [claude] forwarding to: p1",
+		"[ERROR] 2026-03-01 10:00:01.000 [claude] p1 rejected Bearer sk-ant-abcdefghijklmnop",
+		"[WARN ] 2026-03-01 10:00:02.000 [openai] p2 returned 429; trying next provider",
+	}
+	if err := os.WriteFile(filepath.Join(dir, "clipal-2026-03-01.log"), []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+func TestHandleSearchLogs_FiltersAndRedacts(t *testing.T) {
+	dir := t.TempDir()
+	writeLogFixture(t, dir)
+	api := NewAPI(dir, "test", nil)
+
+	w := httptest.NewRecorder()
+	api.HandleSearchLogs(w, httptest.NewRequest(http.MethodGet, "/api/logs?level=warn&provider=p1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+	var resp LogEntriesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(resp.Entries) != 1 || resp.Entries[0].Level != "ERROR" || resp.Entries[0].Client != "claude" {
+		t.Fatalf("entries = %#v", resp.Entries)
+	}
+	if strings.Contains(resp.Entries[0].Message, "abcdefghijklmnop") {
+		t.Fatalf("secret not redacted: %q", resp.Entries[0].Message)
+	}
+
+	w = httptest.NewRecorder()
+	api.HandleListLogFiles(w, httptest.NewRequest(http.MethodGet, "/api/logs/files", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"day":"2026-03-01"`) {
+		t.Fatalf("files: status = %d body=%s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	api.HandleSearchLogs(w, httptest.NewRequest(http.MethodGet, "/api/logs?level=loud", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("bad level: status = %d", w.Code)
+	}
+}
+
+func TestHandleTailLogs_ReplaysLastLines(t *testing.T) {
+	dir := t.TempDir()
+	writeLogFixture(t, dir)
+	api := NewAPI(dir, "test", nil)
+
+	// A canceled request replays the tail and returns instead of following.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/api/logs/tail?lines=2", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	api.HandleTailLogs(w, req)
+
+	body := w.Body.String()
+	if w.Code != http.StatusOK || strings.Count(body, "event: log\n") != 2 || strings.Contains(body, "forwarding to") {
+		t.Fatalf("status = %d stream:\n%s", w.Code, body)
+	}
+	if strings.Contains(body, "abcdefghijklmnop") {
+		t.Fatalf("secret not redacted:\n%s", body)
+	}
+}
@@ -0,0 +1,27 @@
+package web
+
+import (
+	"net/http"
+)
+
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// HandleMetrics serves runtime metrics in the Prometheus text format.
+//
+//	GET /metrics
+func (a *API) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.runtime == nil {
+		writeError(w, "metrics are only available while the proxy is running", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", metricsContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	if r.Method == http.MethodHead {
+		return
+	}
+	a.runtime.WriteMetrics(w)
+}
@@ -0,0 +1,48 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleMetrics_WritesPrometheusText(t *testing.T) {
+	api, _, _, _ := newRuntimeAPI(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	api.HandleMetrics(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != metricsContentType {
+		t.Fatalf("content-type = %q", got)
+	}
+	if body := w.Body.String(); !strings.Contains(body, `clipal_provider_keys{client="openai",provider="p1"} 1`) {
+		t.Fatalf("unexpected metrics:\n%s", body)
+	}
+}
+
+func TestHandleMetrics_RequiresRuntimeAndLocalAccess(t *testing.T) {
+	h := NewHandler(t.TempDir(), "test", nil)
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/metrics", nil)
+	req.RemoteAddr = "127.0.0.1:12345"
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("without runtime: status = %d body=%s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/metrics", nil)
+	req.RemoteAddr = "10.0.0.8:12345"
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("remote: status = %d body=%s", w.Code, w.Body.String())
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/fsutil"
 	oauthpkg "github.com/lansespirit/Clipal/internal/oauth"
 	"github.com/lansespirit/Clipal/internal/telemetry"
 	"github.com/lansespirit/Clipal/internal/testutil"
@@ -1866,8 +1867,8 @@ func TestSnapshotOAuthImportProviderDir_RestoresOverwrittenSymlink(t *testing.T)
 		}
 	}()
 
-	if err := atomicWriteFile(linkPath, []byte(`{"ref":"new"}`), 0o600); err != nil {
-		t.Fatalf("atomicWriteFile overwrite: %v", err)
+	if err := fsutil.AtomicWriteFile(linkPath, []byte(`{"ref":"new"}`), 0o600); err != nil {
+		t.Fatalf("AtomicWriteFile overwrite: %v", err)
 	}
 	if err := restore(); err != nil {
 		t.Fatalf("restore: %v", err)
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/fsutil"
 	"github.com/lansespirit/Clipal/internal/logger"
 	oauthpkg "github.com/lansespirit/Clipal/internal/oauth"
 )
@@ -458,7 +459,7 @@ func copyOAuthImportSnapshot(srcRoot string, dstRoot string) (map[string]struct{
 			protectedPaths[rel] = struct{}{}
 			return nil
 		}
-		return atomicWriteFile(dstPath, data, info.Mode().Perm())
+		return fsutil.AtomicWriteFile(dstPath, data, info.Mode().Perm())
 	})
 	return protectedPaths, err
 }
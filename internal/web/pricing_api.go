@@ -0,0 +1,116 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/logger"
+	"github.com/lansespirit/Clipal/internal/proxy"
+	"github.com/lansespirit/Clipal/internal/telemetry"
+)
+
+// HandlePricing reads or replaces the pricing catalog (pricing.yaml).
+//
+//	GET /api/pricing
+//	PUT /api/pricing
+func (a *API) HandlePricing(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		cfg := a.loadConfigOrWriteError(w)
+		if cfg == nil {
+			return
+		}
+		writeJSON(w, toPricingConfig(cfg.Pricing))
+	case http.MethodPut:
+		a.handleUpdatePricing(w, r)
+	default:
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) handleUpdatePricing(w http.ResponseWriter, r *http.Request) {
+	var req PricingConfig
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	pricing := pricingConfigFromRequest(req)
+	if err := config.ValidatePricing(pricing); err != nil {
+		writeAPIError(w, newAPIError(http.StatusBadRequest, fmt.Sprintf("invalid configuration: %v", err), err))
+		return
+	}
+
+	path := filepath.Join(a.configDir, config.PricingFilename)
+	restore, err := saveConfigFileWithRollback(path, formatPricingYAML(pricing), 0o600)
+	if err != nil {
+		writeAPIError(w, newAPIError(http.StatusInternalServerError, fmt.Sprintf("failed to save pricing: %v", err), err))
+		return
+	}
+	if err := a.reloadRuntimeProviderConfigs(); err != nil {
+		if restoreErr := restore(); restoreErr != nil {
+			err = fmt.Errorf("failed to apply saved pricing: %w (rollback failed: %v)", err, restoreErr)
+		} else {
+			err = fmt.Errorf("failed to apply saved pricing: %w", err)
+		}
+		writeAPIError(w, newAPIError(http.StatusInternalServerError, err.Error(), err))
+		return
+	}
+
+	logger.Info("pricing catalog updated via web interface")
+	writeJSON(w, SuccessResponse{Message: "pricing updated successfully"})
+}
+
+// HandleRecomputePricing re-prices historical ledger entries with the current
+// catalog. Costs reported by the upstream are left untouched.
+//
+//	POST /api/pricing/recompute {"since":"30d","provider":"p1"}
+func (a *API) HandleRecomputePricing(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RecomputePricingRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	now := time.Now()
+	query := telemetry.LedgerQuery{
+		ClientType: strings.TrimSpace(req.ClientType),
+		Provider:   strings.TrimSpace(req.Provider),
+		Model:      strings.TrimSpace(req.Model),
+	}
+	var err error
+	if query.Since, err = telemetry.ParseLedgerTime(req.Since, now); err != nil {
+		writeError(w, fmt.Sprintf("invalid since: %v", err), http.StatusBadRequest)
+		return
+	}
+	if query.Until, err = telemetry.ParseLedgerTime(req.Until, now); err != nil {
+		writeError(w, fmt.Sprintf("invalid until: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	cfg := a.loadConfigOrWriteError(w)
+	if cfg == nil {
+		return
+	}
+	ledger, err := a.usageLedger()
+	if err != nil {
+		writeError(w, fmt.Sprintf("failed to load usage ledger: %v", err), http.StatusInternalServerError)
+		return
+	}
+	updated, err := proxy.RecomputeLedgerCosts(ledger, cfg.Pricing, query)
+	if err != nil {
+		writeError(w, fmt.Sprintf("failed to recompute costs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, RecomputePricingResponse{Updated: updated})
+}
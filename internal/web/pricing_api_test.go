@@ -0,0 +1,120 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+)
+
+func doPricingRequest(t *testing.T, api *API, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	if strings.HasSuffix(path, "/recompute") {
+		api.HandleRecomputePricing(w, req)
+	} else {
+		api.HandlePricing(w, req)
+	}
+	return w
+}
+
+func TestHandlePricing_SaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	api := NewAPI(dir, "test", nil)
+
+	w := doPricingRequest(t, api, http.MethodPut, "/api/pricing", `{
+		"models": [
+			{"model": "my-model", "currency": "cny", "input": 2, "output": 8, "cache_read": 0.5,
+			 "tiers": [{"above_input_tokens": 32000, "input": 4}]}
+		],
+		"provider_multipliers": {"reseller": 1.2},
+		"exchange_rates": {"cny": 0.14}
+	}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d body=%s", w.Code, w.Body.String())
+	}
+
+	pricing, err := config.LoadPricing(dir)
+	if err != nil {
+		t.Fatalf("LoadPricing: %v", err)
+	}
+	entry, ok := pricing.Match("my-model")
+	if !ok || entry.Currency != "CNY" || *entry.CacheRead != 0.5 || entry.CacheWrite != nil || len(entry.Tiers) != 1 || *entry.Tiers[0].Input != 4 {
+		t.Fatalf("saved entry = %#v ok=%v", entry, ok)
+	}
+	if pricing.ExchangeRates["CNY"] != 0.14 || pricing.ProviderMultipliers["reseller"] != 1.2 {
+		t.Fatalf("saved pricing = %#v", pricing)
+	}
+
+	w = doPricingRequest(t, api, http.MethodGet, "/api/pricing", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET status = %d body=%s", w.Code, w.Body.String())
+	}
+	var resp PricingConfig
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if len(resp.Models) != 1 || resp.Models[0].Model != "my-model" || *resp.Models[0].Output != 8 {
+		t.Fatalf("GET resp = %#v", resp)
+	}
+
+	w = doPricingRequest(t, api, http.MethodPut, "/api/pricing", `{"models":[{"model":"m","currency":"EUR","input":1,"output":2}]}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "exchange_rates") {
+		t.Fatalf("invalid PUT status = %d body=%s", w.Code, w.Body.String())
+	}
+	if pricing, err := config.LoadPricing(dir); err != nil || len(pricing.Models) != 1 || pricing.Models[0].Model != "my-model" {
+		t.Fatalf("invalid PUT must not overwrite pricing.yaml: %#v err=%v", pricing, err)
+	}
+}
+
+func TestHandleRecomputePricing(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	lines := []string{
+		fmt.Sprintf(`{"time":%q,"client_type":"openai","capability":"openai_responses","provider":"p1","requested_model":"my-model","status":200,"input_tokens":1000,"output_tokens":100,"total_tokens":1100}`, now.Add(-time.Hour).Format(time.RFC3339)),
+		fmt.Sprintf(`{"time":%q,"client_type":"openai","capability":"openai_responses","provider":"p1","requested_model":"my-model","status":200,"input_tokens":1000,"output_tokens":100,"total_tokens":1100}`, now.Add(-72*time.Hour).Format(time.RFC3339)),
+	}
+	if err := os.WriteFile(filepath.Join(dir, "usage-ledger.jsonl"), []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile ledger: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, config.PricingFilename), []byte("models:\n  - model: my-model\n    input: 1\n    output: 10\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile pricing: %v", err)
+	}
+	api := NewAPI(dir, "test", nil)
+
+	w := doPricingRequest(t, api, http.MethodPost, "/api/pricing/recompute", `{"since":"24h"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+	var resp RecomputePricingResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if resp.Updated != 1 {
+		t.Fatalf("updated = %d", resp.Updated)
+	}
+
+	w = getUsageRequests(t, api, "since=24h")
+	var usage UsageRequestsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &usage); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if len(usage.Entries) != 1 || usage.Entries[0].CostMicros != 2000 || usage.Entries[0].CostSource != "catalog" {
+		t.Fatalf("recomputed entries = %#v", usage.Entries)
+	}
+
+	w = doPricingRequest(t, api, http.MethodPost, "/api/pricing/recompute", `{"since":"soon"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid since status = %d", w.Code)
+	}
+}
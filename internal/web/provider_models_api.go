@@ -0,0 +1,145 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/logger"
+	"github.com/lansespirit/Clipal/internal/proxy"
+)
+
+// providerModelsCacheTTL is how long a fetched model list is served from memory.
+const providerModelsCacheTTL = time.Hour
+
+type providerModelsCacheEntry struct {
+	// signature identifies the provider settings the list was fetched with, so
+	// edits to the base URL, keys or credential invalidate it.
+	signature string
+	fetchedAt time.Time
+	models    []ProviderModel
+}
+
+// HandleListProviderModels returns the models a provider offers, fetched from
+// the upstream and cached per provider. refresh=true bypasses the cache.
+// /_models lists the models of an unsaved draft without caching.
+//
+//	GET  /api/providers/{client}/{name}/models?refresh=true
+//	POST /api/providers/{client}/_models
+func (a *API) HandleListProviderModels(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.EscapedPath()
+	draft := strings.HasSuffix(strings.TrimSuffix(path, "/"), "/_models")
+	if (draft && r.Method != http.MethodPost) || (!draft && r.Method != http.MethodGet) {
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	clientType := extractClientType(path)
+	if clientType == "" {
+		writeError(w, "invalid client type", http.StatusBadRequest)
+		return
+	}
+
+	var (
+		edits        *ProviderRequest
+		providerName string
+	)
+	if draft {
+		var req ProviderTestRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if req.Provider == nil {
+			writeError(w, "provider is required", http.StatusBadRequest)
+			return
+		}
+		edits = req.Provider
+	} else if _, providerName, _ = extractClientProviderSubresource(path); providerName == "" {
+		writeError(w, "invalid provider name", http.StatusBadRequest)
+		return
+	}
+
+	cfg := a.loadConfigOrWriteError(w)
+	if cfg == nil {
+		return
+	}
+	provider, err := providerForTest(cfg, clientType, providerName, edits)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	cacheKey := clientType + "\x00" + provider.Name
+	signature := providerModelsSignature(provider)
+	if !draft && r.URL.Query().Get("refresh") != "true" {
+		a.modelsMu.Lock()
+		entry, ok := a.modelsCache[cacheKey]
+		a.modelsMu.Unlock()
+		if ok && entry.signature == signature && time.Since(entry.fetchedAt) < providerModelsCacheTTL {
+			writeJSON(w, ProviderModelsResponse{
+				Provider:  provider.Name,
+				FetchedAt: entry.fetchedAt.Format(time.RFC3339),
+				Cached:    true,
+				Models:    entry.models,
+			})
+			return
+		}
+	}
+
+	var discovered []proxy.DiscoveredModel
+	if a.runtime != nil {
+		discovered, err = a.runtime.DiscoverProviderModels(r.Context(), proxy.ClientType(clientType), provider)
+	} else {
+		discovered, err = proxy.DiscoverProviderModels(r.Context(), cfg, a.oauth, proxy.ClientType(clientType), provider)
+	}
+	if err != nil {
+		if errors.Is(err, proxy.ErrModelDiscoveryUnsupported) {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeAPIError(w, newAPIError(http.StatusBadGateway, fmt.Sprintf("failed to list models: %s", logger.Redact(err.Error())), err))
+		return
+	}
+
+	models := make([]ProviderModel, 0, len(discovered))
+	for _, m := range discovered {
+		models = append(models, ProviderModel{
+			ID:              m.ID,
+			DisplayName:     m.DisplayName,
+			ContextWindow:   m.ContextWindow,
+			MaxOutputTokens: m.MaxOutputTokens,
+			Capabilities:    m.Capabilities,
+		})
+	}
+	fetchedAt := time.Now()
+	if !draft {
+		a.modelsMu.Lock()
+		a.modelsCache[cacheKey] = providerModelsCacheEntry{signature: signature, fetchedAt: fetchedAt, models: models}
+		a.modelsMu.Unlock()
+	}
+	writeJSON(w, ProviderModelsResponse{
+		Provider:  provider.Name,
+		FetchedAt: fetchedAt.Format(time.RFC3339),
+		Models:    models,
+	})
+}
+
+func providerModelsSignature(provider config.Provider) string {
+	parts := []string{
+		provider.BaseURL,
+		string(provider.NormalizedAuthType()),
+		string(provider.NormalizedOAuthProvider()),
+		provider.NormalizedOAuthRef(),
+		string(provider.NormalizedProxyMode()),
+		provider.NormalizedProxyURL(),
+	}
+	parts = append(parts, provider.NormalizedAPIKeys()...)
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
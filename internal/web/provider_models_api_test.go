@@ -0,0 +1,74 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lansespirit/Clipal/internal/config"
+)
+
+func listProviderModels(t *testing.T, mux *http.ServeMux, method string, target string, body string) (int, ProviderModelsResponse) {
+	t.Helper()
+	req := httptest.NewRequest(method, "http://localhost"+target, bytes.NewBufferString(body))
+	req.Host = "localhost:3333"
+	req.RemoteAddr = "127.0.0.1:12345"
+	req.Header.Set("X-Clipal-UI", "1")
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var resp ProviderModelsResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+	}
+	return w.Code, resp
+}
+
+func TestHandleListProviderModels_CachesSavedProviderAndListsDrafts(t *testing.T) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/v1/models" || r.Header.Get("Authorization") != "Bearer sk-good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"message":"bad key"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[{"id":"gpt-5","context_window":400000},{"id":"gpt-4.1"}]}`))
+	}))
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	writeOpenAIProviders(t, dir, config.Provider{Name: "p1", BaseURL: upstream.URL, APIKey: "sk-good", Priority: 1})
+	mux := http.NewServeMux()
+	NewHandler(dir, "test", nil).RegisterRoutes(mux)
+
+	status, resp := listProviderModels(t, mux, http.MethodGet, "/api/providers/openai/p1/models", "")
+	if status != http.StatusOK || resp.Cached || len(resp.Models) != 2 || resp.Models[0].ID != "gpt-4.1" || resp.Models[1].ContextWindow != 400000 {
+		t.Fatalf("status=%d resp=%#v", status, resp)
+	}
+	status, resp = listProviderModels(t, mux, http.MethodGet, "/api/providers/openai/p1/models", "")
+	if status != http.StatusOK || !resp.Cached || len(resp.Models) != 2 || calls != 1 {
+		t.Fatalf("cached status=%d resp=%#v calls=%d", status, resp, calls)
+	}
+	status, resp = listProviderModels(t, mux, http.MethodGet, "/api/providers/openai/p1/models?refresh=true", "")
+	if status != http.StatusOK || resp.Cached || calls != 2 {
+		t.Fatalf("refresh status=%d resp=%#v calls=%d", status, resp, calls)
+	}
+
+	status, resp = listProviderModels(t, mux, http.MethodPost, "/api/providers/openai/_models", `{"provider":{"name":"new","base_url":"`+upstream.URL+`","api_key":"sk-good"}}`)
+	if status != http.StatusOK || resp.Provider != "new" || len(resp.Models) != 2 {
+		t.Fatalf("draft status=%d resp=%#v", status, resp)
+	}
+	if status, _ := listProviderModels(t, mux, http.MethodPost, "/api/providers/openai/_models", `{"provider":{"name":"new","base_url":"`+upstream.URL+`","api_key":"sk-bad"}}`); status != http.StatusBadGateway {
+		t.Fatalf("rejected key status = %d", status)
+	}
+	if status, _ := listProviderModels(t, mux, http.MethodGet, "/api/providers/openai/missing/models", ""); status != http.StatusNotFound {
+		t.Fatalf("missing provider status = %d", status)
+	}
+}
@@ -0,0 +1,142 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/logger"
+	"github.com/lansespirit/Clipal/internal/proxy"
+)
+
+// HandleTestProvider sends a minimal request with every key of a provider and
+// reports the result per key. Saved providers may carry unsaved edits in the
+// body; /_test tests a draft that has not been saved at all. The test never
+// changes the runtime's circuit breaker or deactivation state.
+//
+//	POST /api/providers/{client}/{name}/test
+//	POST /api/providers/{client}/_test
+func (a *API) HandleTestProvider(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := r.URL.EscapedPath()
+	clientType := extractClientType(path)
+	if clientType == "" {
+		writeError(w, "invalid client type", http.StatusBadRequest)
+		return
+	}
+	draft := strings.HasSuffix(strings.TrimSuffix(path, "/"), "/_test")
+	providerName := ""
+	if !draft {
+		_, providerName, _ = extractClientProviderSubresource(path)
+		if providerName == "" {
+			writeError(w, "invalid provider name", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var req ProviderTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if draft && req.Provider == nil {
+		writeError(w, "provider is required", http.StatusBadRequest)
+		return
+	}
+
+	cfg := a.loadConfigOrWriteError(w)
+	if cfg == nil {
+		return
+	}
+	provider, err := providerForTest(cfg, clientType, providerName, req.Provider)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	model := strings.TrimSpace(req.Model)
+	if model == "" {
+		model = proxy.DefaultProbeModel(proxy.ClientType(clientType), provider)
+	}
+	var results []proxy.ProviderProbeResult
+	if a.runtime != nil {
+		results, err = a.runtime.ProbeProvider(r.Context(), proxy.ClientType(clientType), provider, model)
+	} else {
+		results, err = proxy.ProbeProvider(r.Context(), cfg, a.oauth, proxy.ClientType(clientType), provider, model)
+	}
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := ProviderTestResponse{Provider: provider.Name, Model: model, OK: len(results) > 0, Keys: make([]ProviderKeyTestResult, 0, len(results))}
+	for _, result := range results {
+		resp.OK = resp.OK && result.OK
+		resp.Keys = append(resp.Keys, ProviderKeyTestResult{
+			KeyIndex:       result.KeyIndex,
+			KeyFingerprint: result.KeyFingerprint,
+			OK:             result.OK,
+			Status:         result.Status,
+			LatencyMS:      result.Latency.Milliseconds(),
+			EffectiveModel: result.EffectiveModel,
+			Action:         string(result.Action),
+			Reason:         result.Reason,
+			Error:          logger.Redact(result.Error),
+		})
+	}
+	writeJSON(w, resp)
+}
+
+// providerForTest resolves the provider to test: a saved provider with the
+// edits applied, or a draft built like a new provider.
+func providerForTest(cfg *config.Config, clientType string, name string, edits *ProviderRequest) (config.Provider, error) {
+	var req ProviderRequest
+	if edits != nil {
+		req = *edits
+	}
+	normalizeProviderRequest(&req)
+	req.Overrides = normalizeProviderOverrideRequest(req.Overrides)
+	if err := validateProviderOverrideRequest(clientType, req.Overrides); err != nil {
+		return config.Provider{}, newAPIError(http.StatusBadRequest, err.Error(), err)
+	}
+	keys, err := normalizeProviderKeys(req)
+	if err != nil {
+		return config.Provider{}, newAPIError(http.StatusBadRequest, err.Error(), err)
+	}
+
+	if name == "" {
+		if req.Name == "" {
+			req.Name = "draft"
+		}
+		provider, err := providerFromCreateRequest(clientType, req, 1, keys)
+		if err != nil {
+			return config.Provider{}, newAPIError(http.StatusBadRequest, err.Error(), err)
+		}
+		return provider, nil
+	}
+
+	cc, err := getClientConfigRef(cfg, clientType)
+	if err != nil {
+		return config.Provider{}, newAPIError(http.StatusBadRequest, err.Error(), err)
+	}
+	current := providerByName(cc.Providers, name)
+	if current == nil {
+		return config.Provider{}, newAPIError(http.StatusNotFound, "provider not found", nil)
+	}
+	provider, err := mergeProviderUpdate(*current, req, keys)
+	if err == nil {
+		err = validateProviderCredentialSource(clientType, provider)
+	}
+	if err != nil {
+		return config.Provider{}, newAPIError(http.StatusBadRequest, err.Error(), err)
+	}
+	return provider, nil
+}
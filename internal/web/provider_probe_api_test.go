@@ -0,0 +1,89 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lansespirit/Clipal/internal/config"
+)
+
+func testProviderRequest(t *testing.T, mux *http.ServeMux, target string, body string) (int, ProviderTestResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "http://localhost"+target, bytes.NewBufferString(body))
+	req.Host = "localhost:3333"
+	req.RemoteAddr = "127.0.0.1:12345"
+	req.Header.Set("X-Clipal-UI", "1")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var resp ProviderTestResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+	}
+	return w.Code, resp
+}
+
+func TestHandleTestProvider_SavedProviderWithEditsAndDraft(t *testing.T) {
+	var gotModels []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		model, _ := body["model"].(string)
+		gotModels = append(gotModels, model)
+		if r.Header.Get("Authorization") != "Bearer sk-good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"message":"bad key"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	writeOpenAIProviders(t, dir, config.Provider{Name: "p1", BaseURL: upstream.URL, APIKeys: []string{"sk-good", "sk-bad"}, Priority: 1})
+	before, _ := os.ReadFile(filepath.Join(dir, "openai.yaml"))
+	mux := http.NewServeMux()
+	NewHandler(dir, "test", nil).RegisterRoutes(mux)
+
+	status, resp := testProviderRequest(t, mux, "/api/providers/openai/p1/test", `{"model":"gpt-test"}`)
+	if status != http.StatusOK || resp.OK || len(resp.Keys) != 2 || resp.Model != "gpt-test" {
+		t.Fatalf("status=%d resp=%#v", status, resp)
+	}
+	if k := resp.Keys[0]; !k.OK || k.Status != http.StatusOK || k.EffectiveModel != "gpt-test" {
+		t.Fatalf("good key = %#v", k)
+	}
+	if k := resp.Keys[1]; k.OK || k.Status != http.StatusUnauthorized || k.Reason != "auth" || k.Action == "" {
+		t.Fatalf("bad key = %#v", k)
+	}
+
+	// Unsaved edits are tested without being written.
+	status, resp = testProviderRequest(t, mux, "/api/providers/openai/p1/test", `{"provider":{"api_keys":["sk-good"],"overrides":{"model":"edited-model"}}}`)
+	if status != http.StatusOK || !resp.OK || len(resp.Keys) != 1 || resp.Keys[0].EffectiveModel != "edited-model" {
+		t.Fatalf("status=%d resp=%#v", status, resp)
+	}
+	if after, _ := os.ReadFile(filepath.Join(dir, "openai.yaml")); !bytes.Equal(before, after) {
+		t.Fatalf("provider test wrote openai.yaml")
+	}
+	if gotModels[len(gotModels)-1] != "edited-model" {
+		t.Fatalf("upstream models = %v", gotModels)
+	}
+
+	status, resp = testProviderRequest(t, mux, "/api/providers/openai/_test", `{"provider":{"name":"new","base_url":"`+upstream.URL+`","api_key":"sk-good"}}`)
+	if status != http.StatusOK || !resp.OK || resp.Provider != "new" {
+		t.Fatalf("draft status=%d resp=%#v", status, resp)
+	}
+
+	if status, _ := testProviderRequest(t, mux, "/api/providers/openai/_test", `{}`); status != http.StatusBadRequest {
+		t.Fatalf("draft without provider status = %d", status)
+	}
+	if status, _ := testProviderRequest(t, mux, "/api/providers/openai/missing/test", ``); status != http.StatusNotFound {
+		t.Fatalf("missing provider status = %d", status)
+	}
+}
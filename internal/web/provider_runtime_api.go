@@ -0,0 +1,75 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/proxy"
+)
+
+// HandleControlProviderRuntime applies a manual runtime action, such as a
+// deactivation or a circuit reset, to a provider of the running proxy.
+//
+//	POST /api/providers/{client}/{name}/runtime
+func (a *API) HandleControlProviderRuntime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	clientType, providerName, _ := extractClientProviderSubresource(r.URL.EscapedPath())
+	if clientType == "" || providerName == "" {
+		writeError(w, "invalid provider path", http.StatusBadRequest)
+		return
+	}
+	if a.runtime == nil {
+		writeError(w, "runtime controls are only available while the proxy is running", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req ProviderRuntimeControlRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	control := proxy.RuntimeControl{
+		Action: proxy.RuntimeAction(strings.TrimSpace(req.Action)),
+		Key:    req.Key,
+		Reason: strings.TrimSpace(req.Reason),
+	}
+	if raw := strings.TrimSpace(req.Duration); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			writeError(w, fmt.Sprintf("invalid duration %q: %v", raw, err), http.StatusBadRequest)
+			return
+		}
+		control.Duration = d
+	}
+
+	if err := a.runtime.ControlProvider(proxy.ClientType(clientType), providerName, control); err != nil {
+		switch {
+		case errors.Is(err, proxy.ErrProviderNotRunning):
+			writeError(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, proxy.ErrInvalidRuntimeControl):
+			writeError(w, err.Error(), http.StatusBadRequest)
+		default:
+			writeAPIError(w, err)
+		}
+		return
+	}
+
+	resp := ProviderRuntimeControlResponse{Provider: providerName, Action: string(control.Action), Key: control.Key}
+	for _, snap := range a.runtime.RuntimeSnapshot().Clients[proxy.ClientType(clientType)].Providers {
+		if snap.Name != providerName {
+			continue
+		}
+		view := proxy.DescribeProviderAvailability(providerName, true, snap)
+		resp.State = view.State
+		resp.Label = view.Label
+		resp.Detail = view.Detail
+	}
+	writeJSON(w, resp)
+}
@@ -0,0 +1,55 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleControlProviderRuntime_AppliesActionsAndRejectsBadInput(t *testing.T) {
+	api, _, _, _ := newRuntimeAPI(t)
+	mux := http.NewServeMux()
+	(&Handler{api: api}).RegisterRoutes(mux)
+	control := func(target string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost"+target, bytes.NewBufferString(body))
+		req.Host = "localhost:3333"
+		req.RemoteAddr = "127.0.0.1:12345"
+		req.Header.Set("X-Clipal-UI", "1")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := control("/api/providers/openai/p1/runtime", `{"action":"deactivate","duration":"30m","reason":"planned outage"}`)
+	var resp ProviderRuntimeControlResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("deactivate status=%d body=%s", w.Code, w.Body.String())
+	}
+	if resp.State != "unavailable" || !strings.HasPrefix(resp.Detail, "Deactivated manually: planned outage.") {
+		t.Fatalf("deactivate resp = %#v", resp)
+	}
+
+	w = control("/api/providers/openai/p1/runtime", `{"action":"reactivate","reason":"back up"}`)
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK || resp.State != "available" || resp.Detail != "Available. Reactivated manually: back up." {
+		t.Fatalf("reactivate status=%d body=%s", w.Code, w.Body.String())
+	}
+
+	for _, tt := range []struct {
+		provider string
+		body     string
+		status   int
+	}{
+		{"missing", `{"action":"reactivate"}`, http.StatusNotFound},
+		{"p1", `{"action":"deactivate","duration":"soon"}`, http.StatusBadRequest},
+		{"p1", `{"action":"reset_circuit","key":1}`, http.StatusBadRequest},
+		{"p1", `{"action":"pause"}`, http.StatusBadRequest},
+	} {
+		if w := control("/api/providers/openai/"+tt.provider+"/runtime", tt.body); w.Code != tt.status {
+			t.Fatalf("%s %s: status=%d body=%s", tt.provider, tt.body, w.Code, w.Body.String())
+		}
+	}
+}
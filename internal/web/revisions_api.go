@@ -0,0 +1,365 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/fsutil"
+	"github.com/lansespirit/Clipal/internal/logger"
+)
+
+const (
+	revisionDiffContext = 3
+	// revisionDiffMaxCells caps the line-diff table; larger files are shown as
+	// a full replacement.
+	revisionDiffMaxCells = 4 << 20
+)
+
+// HandleListConfigRevisions lists the config revision history, newest first.
+//
+//	GET /api/config/revisions
+func (a *API) HandleListConfigRevisions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	revs, err := config.ListRevisions(a.configDir)
+	if err != nil {
+		writeError(w, fmt.Sprintf("failed to list config revisions: %v", err), http.StatusInternalServerError)
+		return
+	}
+	resp := ConfigRevisionsResponse{Revisions: make([]ConfigRevision, 0, len(revs))}
+	for _, rev := range revs {
+		resp.Revisions = append(resp.Revisions, toConfigRevision(rev))
+	}
+	writeJSON(w, resp)
+}
+
+// HandleDiffConfigRevision shows what a revision changed compared with the
+// revision before it, or with the current files when against=current.
+//
+//	GET /api/config/revisions/{id}/diff?against=current
+func (a *API) HandleDiffConfigRevision(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rev, previous, err := config.LoadRevision(a.configDir, id)
+	if err != nil {
+		writeAPIError(w, revisionLoadError(id, err))
+		return
+	}
+
+	against := strings.TrimSpace(r.URL.Query().Get("against"))
+	var base map[string]string
+	switch against {
+	case "", "previous":
+		against = "previous"
+		if previous != nil {
+			base = previous.Files
+		}
+	case "current":
+		if base, err = config.ReadRevisionFiles(a.configDir); err != nil {
+			writeError(w, fmt.Sprintf("failed to read config files: %v", err), http.StatusInternalServerError)
+			return
+		}
+	default:
+		writeError(w, fmt.Sprintf("invalid against: %s", against), http.StatusBadRequest)
+		return
+	}
+
+	resp := ConfigRevisionDiffResponse{
+		ID:      rev.ID,
+		Against: against,
+		Summary: config.SummarizeRevisionChanges(base, rev.Files),
+		Files:   []ConfigRevisionFileDiff{},
+	}
+	for _, name := range config.RevisionFilenames() {
+		if base[name] == rev.Files[name] {
+			continue
+		}
+		resp.Files = append(resp.Files, ConfigRevisionFileDiff{
+			File: name,
+			Diff: logger.Redact(unifiedLineDiff(redactRevisionKeys(base[name]), redactRevisionKeys(rev.Files[name]))),
+		})
+	}
+	writeJSON(w, resp)
+}
+
+// HandleRestoreConfigRevision writes a revision's files back and reloads the
+// runtime. Nothing is kept if the restored files fail to load or apply.
+//
+//	POST /api/config/revisions/{id}/restore
+func (a *API) HandleRestoreConfigRevision(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rev, _, err := config.LoadRevision(a.configDir, id)
+	if err != nil {
+		writeAPIError(w, revisionLoadError(id, err))
+		return
+	}
+
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+
+	var restores []func() error
+	rollback := func(baseErr error) error {
+		for i := len(restores) - 1; i >= 0; i-- {
+			if restoreErr := restores[i](); restoreErr != nil {
+				baseErr = fmt.Errorf("%w (rollback failed: %v)", baseErr, restoreErr)
+			}
+		}
+		return baseErr
+	}
+	for _, name := range config.RevisionFilenames() {
+		path := filepath.Join(a.configDir, name)
+		backup, err := snapshotConfigFile(path)
+		if err != nil {
+			writeError(w, rollback(fmt.Errorf("failed to restore %s: %w", name, err)).Error(), http.StatusInternalServerError)
+			return
+		}
+		data, ok := rev.Files[name]
+		if ok {
+			err = fsutil.AtomicWriteFile(path, []byte(data), 0o600)
+		} else if err = os.Remove(path); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil {
+			writeError(w, rollback(fmt.Errorf("failed to restore %s: %w", name, err)).Error(), http.StatusInternalServerError)
+			return
+		}
+		restores = append(restores, backup.restore)
+	}
+
+	if _, err := config.Load(a.configDir); err != nil {
+		err = rollback(fmt.Errorf("revision %s is not a valid configuration: %w", id, err))
+		writeAPIError(w, newAPIError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	if err := a.applySavedConfig(rev.ID); err != nil {
+		err = rollback(fmt.Errorf("failed to apply restored config: %w", err))
+		writeAPIError(w, newAPIError(http.StatusInternalServerError, err.Error(), err))
+		return
+	}
+
+	logger.Info("configuration restored to revision %s via web interface", rev.ID)
+	writeJSON(w, ConfigRevisionRestoreResponse{
+		ID:      rev.ID,
+		Message: fmt.Sprintf("restored revision %s", rev.ID),
+	})
+}
+
+func revisionLoadError(id string, err error) error {
+	if errors.Is(err, config.ErrRevisionNotFound) {
+		return newAPIError(http.StatusNotFound, fmt.Sprintf("revision not found: %s", id), err)
+	}
+	return newAPIError(http.StatusInternalServerError, fmt.Sprintf("failed to load revision %s: %v", id, err), err)
+}
+
+func toConfigRevision(rev config.Revision) ConfigRevision {
+	files := make([]string, 0, len(rev.Files))
+	for _, name := range config.RevisionFilenames() {
+		if _, ok := rev.Files[name]; ok {
+			files = append(files, name)
+		}
+	}
+	return ConfigRevision{
+		ID:           rev.ID,
+		Time:         rev.Time.Format(time.RFC3339),
+		Source:       string(rev.Source),
+		Summary:      rev.Summary,
+		RestoredFrom: rev.RestoredFrom,
+		Files:        files,
+	}
+}
+
+// redactRevisionKeys masks the provider API keys in a revision file. Keys are
+// found by parsing the YAML, so bare api_keys list entries are caught along
+// with api_key values. Each key becomes its fingerprint, which keeps a changed
+// key visible in the diff without showing it. Content that does not parse is
+// returned unchanged and left to logger.Redact.
+func redactRevisionKeys(content string) string {
+	var root yaml.Node
+	if content == "" || yaml.Unmarshal([]byte(content), &root) != nil {
+		return content
+	}
+	lines := strings.Split(content, "\n")
+	// maskFrom replaces the rest of a line, starting at a 1-based node column.
+	maskFrom := func(line, column int, mask string) {
+		if line < 1 || line > len(lines) {
+			return
+		}
+		runes := []rune(lines[line-1])
+		if column < 1 || column > len(runes) {
+			return
+		}
+		lines[line-1] = string(runes[:column-1]) + mask
+	}
+	maskKeys := func(n *yaml.Node) {
+		switch n.Kind {
+		case yaml.ScalarNode:
+			if n.Value != "" {
+				maskFrom(n.Line, n.Column, revisionKeyMask(n.Value))
+			}
+		case yaml.SequenceNode:
+			masks := make([]string, 0, len(n.Content))
+			oneLine := true
+			for _, item := range n.Content {
+				masks = append(masks, revisionKeyMask(item.Value))
+				oneLine = oneLine && item.Line == n.Line
+			}
+			if n.Style&yaml.FlowStyle != 0 && oneLine {
+				maskFrom(n.Line, n.Column, "["+strings.Join(masks, ", ")+"]")
+				return
+			}
+			for i, item := range n.Content {
+				if item.Kind == yaml.ScalarNode && item.Value != "" {
+					maskFrom(item.Line, item.Column, masks[i])
+				}
+			}
+		}
+	}
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		switch n.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, child := range n.Content {
+				walk(child)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				switch key, value := n.Content[i], n.Content[i+1]; key.Value {
+				case "api_key", "api_keys":
+					maskKeys(value)
+				default:
+					walk(value)
+				}
+			}
+		}
+	}
+	walk(&root)
+	return strings.Join(lines, "\n")
+}
+
+// revisionKeyMask shows a key as the key_fingerprint used by the usage ledger
+// and the routing explain endpoint.
+func revisionKeyMask(key string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(key)))
+	return "[redacted " + hex.EncodeToString(sum[:])[:12] + "]"
+}
+
+// unifiedLineDiff renders a line diff of two texts as unified-diff hunks
+// without file headers.
+func unifiedLineDiff(before, after string) string {
+	a := splitDiffLines(before)
+	b := splitDiffLines(after)
+
+	type op struct {
+		kind byte // ' ', '-', '+'
+		line string
+	}
+	var ops []op
+	if len(a)*len(b) > revisionDiffMaxCells {
+		for _, line := range a {
+			ops = append(ops, op{'-', line})
+		}
+		for _, line := range b {
+			ops = append(ops, op{'+', line})
+		}
+	} else {
+		// lcs[i][j] is the longest common subsequence of a[i:] and b[j:].
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(a) || j < len(b) {
+			switch {
+			case i < len(a) && j < len(b) && a[i] == b[j]:
+				ops = append(ops, op{' ', a[i]})
+				i++
+				j++
+			case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+				ops = append(ops, op{'-', a[i]})
+				i++
+			default:
+				ops = append(ops, op{'+', b[j]})
+				j++
+			}
+		}
+	}
+
+	var sb strings.Builder
+	for start := 0; start < len(ops); {
+		if ops[start].kind == ' ' {
+			start++
+			continue
+		}
+		// Grow the hunk until the next change is more than two contexts away.
+		from := max(0, start-revisionDiffContext)
+		end := start
+		for k := start; k < len(ops); k++ {
+			if ops[k].kind != ' ' {
+				end = k
+			} else if k-end > 2*revisionDiffContext {
+				break
+			}
+		}
+		to := min(len(ops), end+revisionDiffContext+1)
+
+		oldStart, newStart := 1, 1
+		for _, o := range ops[:from] {
+			if o.kind != '+' {
+				oldStart++
+			}
+			if o.kind != '-' {
+				newStart++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, o := range ops[from:to] {
+			if o.kind != '+' {
+				oldCount++
+			}
+			if o.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, o := range ops[from:to] {
+			sb.WriteByte(o.kind)
+			sb.WriteString(o.line)
+			sb.WriteByte('\n')
+		}
+		start = to
+	}
+	return sb.String()
+}
+
+func splitDiffLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,106 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lansespirit/Clipal/internal/config"
+)
+
+func TestConfigRevisions_ListDiffAndRestore(t *testing.T) {
+	api, _, cfg, dir := newRuntimeAPI(t)
+	baseline, err := config.RecordRevision(dir, config.RevisionSourceFile, "")
+	if err != nil || baseline == nil {
+		t.Fatalf("RecordRevision = %#v, %v", baseline, err)
+	}
+
+	cfg.OpenAI.Providers[0].BaseURL = "https://changed.example.com"
+	if !api.saveClientConfigOrWriteError(httptest.NewRecorder(), "openai", cfg) {
+		t.Fatalf("save failed")
+	}
+
+	w := httptest.NewRecorder()
+	api.HandleListConfigRevisions(w, httptest.NewRequest(http.MethodGet, "/api/config/revisions", nil))
+	var list ConfigRevisionsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(list.Revisions) != 2 || list.Revisions[0].Source != "web" || list.Revisions[1].ID != baseline.ID {
+		t.Fatalf("revisions = %#v", list.Revisions)
+	}
+	if got := strings.Join(list.Revisions[0].Summary, ";"); got != "openai: updated provider p1" {
+		t.Fatalf("summary = %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	api.HandleDiffConfigRevision(w, httptest.NewRequest(http.MethodGet, "/api/config/revisions/x/diff", nil), list.Revisions[0].ID)
+	var diff ConfigRevisionDiffResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &diff); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(diff.Files) != 1 || diff.Files[0].File != "openai.yaml" ||
+		!strings.Contains(diff.Files[0].Diff, "-    base_url: \"https://example.com\"\n+    base_url: \"https://changed.example.com\"\n") {
+		t.Fatalf("diff = %#v", diff)
+	}
+
+	w = httptest.NewRecorder()
+	api.HandleRestoreConfigRevision(w, httptest.NewRequest(http.MethodPost, "/api/config/revisions/x/restore", nil), baseline.ID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("restore status = %d body=%s", w.Code, w.Body.String())
+	}
+	loaded, err := config.Load(dir)
+	if err != nil || loaded.OpenAI.Providers[0].BaseURL != "https://example.com" {
+		t.Fatalf("restored config = %#v, %v", loaded, err)
+	}
+	revs, err := config.ListRevisions(dir)
+	if err != nil || len(revs) != 3 || revs[0].RestoredFrom != baseline.ID {
+		t.Fatalf("revisions after restore = %#v, %v", revs, err)
+	}
+
+	w = httptest.NewRecorder()
+	api.HandleRestoreConfigRevision(w, httptest.NewRequest(http.MethodPost, "/api/config/revisions/x/restore", nil), "missing")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unknown revision status = %d", w.Code)
+	}
+}
+
+func TestUnifiedLineDiff(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	want := "@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n@@ -8,3 +8,4 @@\n h\n i\n j\n+k\n"
+	if got := unifiedLineDiff(before, after); got != want {
+		t.Fatalf("diff:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRedactRevisionKeys(t *testing.T) {
+	content := "providers:\n" +
+		"  - name: p1\n" +
+		"    api_key: \"plain-secret-1\"\n" +
+		"    api_keys:\n" +
+		"      - abc123\n" +
+		"      - \"def456\" # spare\n" +
+		"  - name: p2\n" +
+		"    api_keys: [ghi789, jkl012]\n"
+	got := redactRevisionKeys(content)
+	for _, secret := range []string{"plain-secret-1", "abc123", "def456", "ghi789", "jkl012"} {
+		if strings.Contains(got, secret) {
+			t.Fatalf("%s leaked:\n%s", secret, got)
+		}
+	}
+	if lines := strings.Split(got, "\n"); len(lines) != 9 || lines[4] != "      - "+revisionKeyMask("abc123") ||
+		lines[7] != "    api_keys: ["+revisionKeyMask("ghi789")+", "+revisionKeyMask("jkl012")+"]" {
+		t.Fatalf("redacted:\n%s", got)
+	}
+	if !strings.Contains(got, "  - name: p2\n") {
+		t.Fatalf("non-key lines changed:\n%s", got)
+	}
+
+	diff := unifiedLineDiff(redactRevisionKeys(content), redactRevisionKeys(strings.Replace(content, "abc123", "xyz999", 1)))
+	if !strings.Contains(diff, "-      - "+revisionKeyMask("abc123")) || strings.Contains(diff, "xyz999") {
+		t.Fatalf("diff:\n%s", diff)
+	}
+}
@@ -0,0 +1,108 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lansespirit/Clipal/internal/proxy"
+)
+
+// HandleExplainRouting runs a sample request through request detection,
+// sticky resolution and provider selection without sending it upstream.
+//
+//	POST /api/routing/explain
+func (a *API) HandleExplainRouting(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req RoutingExplainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Path) == "" {
+		writeError(w, "path is required", http.StatusBadRequest)
+		return
+	}
+	if a.runtime == nil {
+		writeError(w, "routing explain is only available while the proxy is running", http.StatusServiceUnavailable)
+		return
+	}
+
+	header := make(http.Header, len(req.Headers))
+	for key, value := range req.Headers {
+		header.Set(key, value)
+	}
+	body := []byte(req.Body)
+	var text string
+	if err := json.Unmarshal(req.Body, &text); err == nil {
+		body = []byte(text)
+	}
+
+	out, err := a.runtime.ExplainRequest(r.Context(), strings.ToUpper(strings.TrimSpace(req.Method)), req.Path, header, body)
+	if err != nil {
+		if errors.Is(err, proxy.ErrUnknownRequestPath) {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, routingExplainResponse(out))
+}
+
+func routingExplainResponse(out proxy.RoutingExplanation) RoutingExplainResponse {
+	resp := RoutingExplainResponse{
+		ClientType: string(out.ClientType),
+		Method:     out.Method,
+		Path:       out.Path,
+		Capability: string(out.Capability),
+		Scope:      out.Scope,
+		Handling:   string(out.Handling),
+		Notes:      out.Notes,
+		Candidates: make([]RoutingExplainCandidate, 0, len(out.Candidates)),
+	}
+	if s := out.Sticky; s != nil {
+		resp.Sticky = &RoutingExplainSticky{
+			Level:    s.Level,
+			Source:   s.Source,
+			Preview:  s.Preview,
+			Provider: s.Provider,
+			Key:      s.Key,
+			Applied:  s.Applied,
+			Detail:   s.Detail,
+		}
+	}
+	for _, c := range out.Candidates {
+		cand := RoutingExplainCandidate{
+			Provider:       c.Provider,
+			Attempt:        c.Attempt,
+			Skipped:        c.Attempt == 0,
+			Reason:         c.Reason,
+			Key:            c.Key,
+			KeyFingerprint: c.KeyFingerprint,
+			AvailableKeys:  c.AvailableKeys,
+			Model:          c.Model,
+			FallbackModels: c.FallbackModels,
+			Error:          c.Error,
+		}
+		if p := c.Request; p != nil {
+			upstream := &RoutingExplainUpstream{Method: p.Method, URL: p.URL, Headers: p.Header}
+			if body := bytes.TrimSpace(p.Body); len(body) > 0 {
+				if json.Valid(body) {
+					upstream.Body = json.RawMessage(body)
+				} else {
+					upstream.BodyText = string(p.Body)
+				}
+			}
+			cand.Request = upstream
+		}
+		resp.Candidates = append(resp.Candidates, cand)
+	}
+	return resp
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleExplainRouting_ReturnsRedactedUpstreamRequest(t *testing.T) {
+	api, _, _, _ := newRuntimeAPI(t)
+	mux := http.NewServeMux()
+	(&Handler{api: api}).RegisterRoutes(mux)
+	call := func(method string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "http://localhost/api/routing/explain", bytes.NewBufferString(body))
+		req.Host = "localhost:3333"
+		req.RemoteAddr = "127.0.0.1:12345"
+		req.Header.Set("X-Clipal-UI", "1")
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := call(http.MethodPost, `{"path":"/clipal/v1/chat/completions","headers":{"Authorization":"Bearer client-secret"},"body":{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}}`)
+	var resp RoutingExplainResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	if resp.ClientType != "openai" || resp.Method != http.MethodPost || resp.Handling != "failover" || len(resp.Candidates) != 1 {
+		t.Fatalf("resp = %#v", resp)
+	}
+	cand := resp.Candidates[0]
+	if cand.Provider != "p1" || cand.Attempt != 1 || cand.Skipped || cand.Request == nil {
+		t.Fatalf("candidate = %#v", cand)
+	}
+	if cand.Request.URL != "https://example.com/v1/chat/completions" || cand.Request.Headers["Authorization"][0] != "Bearer [redacted]" {
+		t.Fatalf("upstream = %#v", cand.Request)
+	}
+	if !strings.Contains(string(cand.Request.Body), `"model":"gpt-4o"`) || strings.Contains(w.Body.String(), "k1") {
+		t.Fatalf("upstream body = %s; response = %s", cand.Request.Body, w.Body.String())
+	}
+
+	for _, tt := range []struct {
+		method string
+		body   string
+		status int
+	}{
+		{http.MethodPost, `{"path":"/unknown/v1/messages"}`, http.StatusBadRequest},
+		{http.MethodPost, `{}`, http.StatusBadRequest},
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+	} {
+		if w := call(tt.method, tt.body); w.Code != tt.status {
+			t.Fatalf("%s %s: status=%d body=%s", tt.method, tt.body, w.Code, w.Body.String())
+		}
+	}
+}
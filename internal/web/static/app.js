@@ -1,5 +1,8 @@
 const pendingOAuthSessionStorageKey = 'clipal.pendingOAuthSession'
 const oauthAuthorizationCancelledError = 'clipal.oauth.cancelled'
+const LIVE_EVENT_TYPES = ['request_completed', 'attempt_failed', 'provider_switched', 'provider_deactivated', 'provider_reactivated', 'circuit_changed', 'oauth_refreshed', 'config_reloaded']
+const LIVE_EVENT_LIMIT = 50
+const LOG_ENTRY_LIMIT = 200
 
 function defaultOAuthAuthorizationState() {
     return {
@@ -64,6 +67,7 @@ function app() {
                     save: 'Save',
                     reset: 'Reset',
                     export: 'Export',
+                    import: 'Import',
                     cancel: 'Cancel',
                     show: 'Show',
                     hide: 'Hide',
@@ -247,7 +251,17 @@ function app() {
                         priority: 'Priority',
                         priorityHint: 'Smaller numbers are tried first.',
                         saveProvider: 'Save Provider',
-                        authorizeProvider: 'Continue to Authorization'
+                        authorizeProvider: 'Continue to Authorization',
+                        testConnection: 'Test Connection',
+                        testing: 'Testing...',
+                        testResult: 'Test with {model}: {passed}/{total} keys OK',
+                        testKey: 'Key {index}',
+                        testNoResponse: 'no response',
+                        loadModels: 'Load Models',
+                        loadingModels: 'Loading...',
+                        modelsLoaded: '{count} models available upstream',
+                        modelsLoadedCached: '{count} models available upstream (cached {time})',
+                        contextWindow: '{tokens} context'
                     }
                 },
                 settings: {
@@ -305,7 +319,27 @@ function app() {
                     saveSettings: 'Save Settings',
                     saveSuccess: 'Configuration saved. Some changes may require restart.',
                     exportSuccess: 'Configuration exported successfully',
-                    exportFailure: 'Failed to export configuration'
+                    exportFailure: 'Failed to export configuration',
+                    importStrategyMerge: 'Import: merge',
+                    importStrategyReplace: 'Import: replace',
+                    importStrategyAddOnly: 'Import: add only',
+                    importNothing: 'The bundle matches the current configuration; nothing to import.',
+                    importConfirm: 'Apply {count} changes?\n\n{changes}',
+                    importSuccess: 'Imported {count} changes',
+                    historyTitle: 'Revision History',
+                    historyCopy: 'Every save and every external edit of config.yaml and the client configs is kept here. The newest 50 revisions are retained.',
+                    historyEmpty: 'No revisions recorded yet.',
+                    historyBaseline: 'Initial snapshot',
+                    historyRestoredFrom: 'Restored from {id}',
+                    historySource_web: 'Web UI',
+                    historySource_cli: 'CLI',
+                    historySource_file: 'File edit',
+                    historyChanges: 'Changes',
+                    historyCompare: 'Compare with current',
+                    historyRestore: 'Restore',
+                    historyNoDiff: 'No differences.',
+                    historyRestoreConfirm: 'Restore the configuration from {time}? The current files are kept as a new revision.',
+                    historyRestoreSuccess: 'Configuration restored'
                 },
                 integrations: {
                     title: 'CLI Takeover',
@@ -407,7 +441,31 @@ function app() {
                     groupCoolingDown: 'Cooling down',
                     groupUnavailable: 'Unavailable',
                     groupRecoveryProbe: 'Recovery probe',
-                    keysAvailable: 'Keys available: {available}/{total}'
+                    keysAvailable: 'Keys available: {available}/{total}',
+                    topSessions: 'Top sessions by cost (7 days)',
+                    noSessions: 'No session usage recorded yet',
+                    sessionSummary: '{requests} requests · {tokens} tokens',
+                    liveEvents: 'Live activity',
+                    noLiveEvents: 'Waiting for requests…',
+                    eventRequestCompleted: '{provider} · {status} · {duration}',
+                    eventAttemptFailed: '{provider} failed: {reason}',
+                    eventProviderSwitched: 'Switched {from} → {to}',
+                    eventProviderDeactivated: '{provider} deactivated: {reason}',
+                    eventProviderReactivated: '{provider} reactivated',
+                    eventCircuitChanged: '{provider} circuit {from} → {to}',
+                    eventOAuthRefreshed: '{detail} {result}',
+                    eventConfigReloaded: 'Configuration {result}',
+                    logs: 'Logs',
+                    logsLevel: 'Minimum level',
+                    logsClient: 'Client',
+                    logsAllClients: 'All clients',
+                    logsProvider: 'Provider',
+                    logsText: 'Contains',
+                    logsSince: 'Since',
+                    logsSearch: 'Search',
+                    logsFollow: 'Follow',
+                    logsStopFollow: 'Stop following',
+                    noLogs: 'No matching log entries'
                 },
                 toast: {
                     success: 'Success',
@@ -446,6 +504,7 @@ function app() {
                     save: '保存',
                     reset: '重置',
                     export: '导出',
+                    import: '导入',
                     cancel: '取消',
                     show: '展开',
                     hide: '收起',
@@ -628,7 +687,17 @@ function app() {
                         priority: '优先级',
                         priorityHint: '数字越小越先尝试。',
                         saveProvider: '保存 Provider',
-                        authorizeProvider: '继续授权'
+                        authorizeProvider: '继续授权',
+                        testConnection: '测试连接',
+                        testing: '测试中...',
+                        testResult: '使用 {model} 测试：{passed}/{total} 个 Key 可用',
+                        testKey: 'Key {index}',
+                        testNoResponse: '无响应',
+                        loadModels: '加载模型',
+                        loadingModels: '加载中...',
+                        modelsLoaded: '上游可用 {count} 个模型',
+                        modelsLoadedCached: '上游可用 {count} 个模型（{time}缓存）',
+                        contextWindow: '{tokens} 上下文'
                     }
                 },
                 settings: {
@@ -686,7 +755,27 @@ function app() {
                     saveSettings: '保存设置',
                     saveSuccess: '配置已保存。部分改动可能需要重启。',
                     exportSuccess: '配置导出成功',
-                    exportFailure: '配置导出失败'
+                    exportFailure: '配置导出失败',
+                    importStrategyMerge: '导入：合并',
+                    importStrategyReplace: '导入：替换',
+                    importStrategyAddOnly: '导入：仅新增',
+                    importNothing: '导入文件与当前配置一致，没有需要导入的内容。',
+                    importConfirm: '确认应用 {count} 项改动？\n\n{changes}',
+                    importSuccess: '已导入 {count} 项改动',
+                    historyTitle: '修订历史',
+                    historyCopy: '每次保存以及对 config.yaml 和客户端配置的外部修改都会记录在这里，保留最近 50 个修订。',
+                    historyEmpty: '还没有修订记录。',
+                    historyBaseline: '初始快照',
+                    historyRestoredFrom: '恢复自 {id}',
+                    historySource_web: 'Web UI',
+                    historySource_cli: 'CLI',
+                    historySource_file: '文件修改',
+                    historyChanges: '改动',
+                    historyCompare: '与当前对比',
+                    historyRestore: '恢复',
+                    historyNoDiff: '没有差异。',
+                    historyRestoreConfirm: '确认恢复 {time} 的配置？当前文件会作为新的修订保留。',
+                    historyRestoreSuccess: '配置已恢复'
                 },
                 integrations: {
                     title: 'CLI 接管',
@@ -788,7 +877,31 @@ function app() {
                     groupCoolingDown: '冷却中',
                     groupUnavailable: '不可用',
                     groupRecoveryProbe: '恢复探测',
-                    keysAvailable: '可用密钥：{available}/{total}'
+                    keysAvailable: '可用密钥：{available}/{total}',
+                    topSessions: '费用最高的会话（近 7 天）',
+                    noSessions: '暂无会话用量记录',
+                    sessionSummary: '{requests} 次请求 · {tokens} token',
+                    liveEvents: '实时活动',
+                    noLiveEvents: '等待请求…',
+                    eventRequestCompleted: '{provider} · {status} · {duration}',
+                    eventAttemptFailed: '{provider} 失败：{reason}',
+                    eventProviderSwitched: '已切换 {from} → {to}',
+                    eventProviderDeactivated: '{provider} 已停用：{reason}',
+                    eventProviderReactivated: '{provider} 已恢复',
+                    eventCircuitChanged: '{provider} 熔断 {from} → {to}',
+                    eventOAuthRefreshed: '{detail} {result}',
+                    eventConfigReloaded: '配置 {result}',
+                    logs: '日志',
+                    logsLevel: '最低级别',
+                    logsClient: '客户端',
+                    logsAllClients: '全部客户端',
+                    logsProvider: 'Provider',
+                    logsText: '包含',
+                    logsSince: '起始时间',
+                    logsSearch: '搜索',
+                    logsFollow: '实时跟踪',
+                    logsStopFollow: '停止跟踪',
+                    noLogs: '没有匹配的日志'
                 },
                 toast: {
                     success: '成功',
@@ -861,6 +974,21 @@ function app() {
             config_dir: '',
             clients: {}
         },
+        topSessions: [],
+        liveEvents: [],
+        eventSource: null,
+        logsQuery: {
+            level: 'info',
+            client_type: '',
+            provider: '',
+            q: '',
+            since: '24h'
+        },
+        logEntries: [],
+        logsSource: null,
+        configImportStrategy: 'merge',
+        configRevisions: [],
+        revisionDiff: null,
         serviceStatus: {
             os: '',
             install_command: '',
@@ -903,6 +1031,8 @@ function app() {
         },
         editingProviderName: '',
         editingProviderKeyCount: 0,
+        providerTest: { running: false, result: null },
+        providerModels: { loading: false, result: null },
 
         // Helpers
         withDefaultGlobalConfig(cfg) {
@@ -1718,6 +1848,7 @@ function app() {
             try {
                 await Promise.all([
                     this.refreshStatus(),
+                    this.refreshTopSessions(),
                     this.loadServiceStatus(),
                     this.loadProviders(),
                     this.loadOAuthProviders(true),
@@ -1744,6 +1875,11 @@ function app() {
                 }
                 if (this.activeTab === 'status') {
                     this.refreshStatus();
+                    this.refreshTopSessions();
+                }
+                this.syncLiveEvents();
+                if (this.activeTab !== 'status') {
+                    this.stopFollowingLogs();
                 }
             }, 3000);
         },
@@ -1933,6 +2069,139 @@ function app() {
             }
         },
 
+        async refreshTopSessions() {
+            try {
+                const result = await this.apiCall('/api/usage/requests?since=7d&group_by=session', {}, true, true);
+                const groups = Array.isArray(result && result.groups) ? result.groups : [];
+                this.topSessions = groups.filter(group => String(group.key || '').trim() !== '').slice(0, 10);
+            } catch (error) {
+                console.error('Failed to refresh top sessions:', error);
+            }
+        },
+
+        // syncLiveEvents keeps the event stream open only while the Status tab
+        // is shown. EventSource reconnects on its own and resumes after the
+        // last event it received.
+        syncLiveEvents() {
+            const wanted = this.activeTab === 'status' && typeof EventSource !== 'undefined';
+            if (!wanted) {
+                if (this.eventSource) {
+                    this.eventSource.close();
+                    this.eventSource = null;
+                }
+                return;
+            }
+            if (this.eventSource) {
+                return;
+            }
+            const source = new EventSource(`/api/events?type=${LIVE_EVENT_TYPES.join(',')}`);
+            const onEvent = message => {
+                try {
+                    this.pushLiveEvent(JSON.parse(message.data));
+                } catch (error) {
+                    console.error('Failed to parse runtime event:', error);
+                }
+            };
+            LIVE_EVENT_TYPES.forEach(type => source.addEventListener(type, onEvent));
+            this.eventSource = source;
+        },
+
+        pushLiveEvent(event) {
+            if (!event || !event.id || this.liveEvents.some(existing => existing.id === event.id)) {
+                return;
+            }
+            this.liveEvents = [event, ...this.liveEvents].slice(0, LIVE_EVENT_LIMIT);
+        },
+
+        liveEventSummary(event) {
+            const e = event || {};
+            const key = {
+                request_completed: 'eventRequestCompleted',
+                attempt_failed: 'eventAttemptFailed',
+                provider_switched: 'eventProviderSwitched',
+                provider_deactivated: 'eventProviderDeactivated',
+                provider_reactivated: 'eventProviderReactivated',
+                circuit_changed: 'eventCircuitChanged',
+                oauth_refreshed: 'eventOAuthRefreshed',
+                config_reloaded: 'eventConfigReloaded'
+            }[e.type];
+            if (!key) {
+                return String(e.type || '');
+            }
+            return this.tf(`statusPage.${key}`, {
+                provider: e.provider || '-',
+                status: e.status || e.result || '-',
+                duration: `${Number(e.duration_ms || 0)}ms`,
+                reason: e.reason || e.status || '-',
+                from: e.from || '-',
+                to: e.to || '-',
+                detail: e.detail || '',
+                result: e.result || ''
+            });
+        },
+
+        liveEventLabel(event) {
+            const e = event || {};
+            const at = e.at ? new Date(e.at) : null;
+            const time = at && !Number.isNaN(at.getTime()) ? at.toLocaleTimeString() : '';
+            return [time, e.client_type ? this.clientLabel(e.client_type) : '', e.model || ''].filter(Boolean).join(' · ');
+        },
+
+        logsQueryString() {
+            return Object.entries(this.logsQuery)
+                .map(([key, value]) => [key, String(value || '').trim()])
+                .filter(([, value]) => value !== '')
+                .map(([key, value]) => `${key}=${encodeURIComponent(value)}`)
+                .join('&');
+        },
+
+        async searchLogs() {
+            this.stopFollowingLogs();
+            try {
+                const result = await this.apiCall(`/api/logs?${this.logsQueryString()}&limit=${LOG_ENTRY_LIMIT}`);
+                this.logEntries = Array.isArray(result && result.entries) ? result.entries : [];
+            } catch (error) {
+                console.error('Failed to search logs:', error);
+            }
+        },
+
+        // toggleFollowLogs streams the tail of the current log with the same
+        // filters as a search. The server redacts secrets before sending.
+        toggleFollowLogs() {
+            if (this.logsSource) {
+                this.stopFollowingLogs();
+                return;
+            }
+            if (typeof EventSource === 'undefined') {
+                return;
+            }
+            this.logEntries = [];
+            const source = new EventSource(`/api/logs/tail?${this.logsQueryString()}&lines=${LOG_ENTRY_LIMIT}`);
+            source.addEventListener('log', message => {
+                try {
+                    this.logEntries = [...this.logEntries, JSON.parse(message.data)].slice(-LOG_ENTRY_LIMIT);
+                } catch (error) {
+                    console.error('Failed to parse log entry:', error);
+                }
+            });
+            this.logsSource = source;
+        },
+
+        stopFollowingLogs() {
+            if (this.logsSource) {
+                this.logsSource.close();
+                this.logsSource = null;
+            }
+        },
+
+        logsText() {
+            return this.logEntries.map(entry => {
+                const at = new Date(entry.time);
+                const time = Number.isNaN(at.getTime()) ? String(entry.time || '') : at.toLocaleString();
+                return `[${String(entry.level || '').padEnd(5)}] ${time} ${entry.message || ''}`;
+            }).join('\n');
+        },
+
         // Services
         async loadServiceStatus(background = false) {
             try {
@@ -3356,6 +3625,18 @@ function app() {
             return this.tf('statusPage.enabledCount', { count: Number(count || 0) });
         },
 
+        topSessionLabel(session) {
+            const key = String((session && session.key) || '');
+            return key.length > 40 ? `${key.slice(0, 40)}…` : key;
+        },
+
+        topSessionSummary(session) {
+            return this.tf('statusPage.sessionSummary', {
+                requests: this.formatTokenCount((session && session.requests) || 0),
+                tokens: this.formatCompactTokenCount((session && session.total_tokens) || 0)
+            });
+        },
+
         statusCircuitBreakerSummary() {
             return this.tf('statusPage.circuitBreakerSummary', {
                 failure: this.globalConfig.circuit_breaker.failure_threshold,
@@ -3655,6 +3936,60 @@ function app() {
             }
         },
 
+        providerFormPayload() {
+            const payload = {
+                proxy_mode: this.normalizeProviderProxyMode(this.providerForm.proxy_mode),
+                priority: this.providerForm.priority,
+                enabled: this.providerForm.enabled
+            };
+            if (this.providerFormUsesOAuth()) {
+                payload.auth_type = 'oauth';
+                payload.oauth_provider = this.providerForm.oauth_provider;
+                payload.oauth_ref = this.providerForm.oauth_ref;
+                if (this.providerForm.name) {
+                    payload.name = this.providerForm.name;
+                }
+            } else {
+                payload.name = this.providerForm.name;
+                payload.base_url = this.providerForm.base_url;
+            }
+            if (payload.proxy_mode === 'custom') {
+                const proxyURL = String(this.providerForm.proxy_url || '').trim();
+                if (proxyURL) {
+                    payload.proxy_url = proxyURL;
+                }
+            }
+            const overrides = {};
+            if (this.providerSupportsModelOverride()) {
+                overrides.model = String(this.providerForm.model || '');
+            }
+            if (this.providerSupportsReasoningEffort()) {
+                overrides.openai = {
+                    reasoning_effort: String(this.providerForm.reasoning_effort || '')
+                };
+            }
+            if (this.providerSupportsThinkingBudget()) {
+                overrides.claude = {
+                    thinking_budget_tokens: this.normalizeThinkingBudgetTokens(this.providerForm.thinking_budget_tokens)
+                };
+            }
+            if (Object.keys(overrides).length > 0) {
+                payload.overrides = overrides;
+            }
+            if (!this.providerFormUsesOAuth()) {
+                const keys = String(this.providerForm.api_keys_text || '')
+                    .split('\n')
+                    .map(v => v.trim())
+                    .filter(Boolean);
+                if (keys.length === 1) {
+                    payload.api_key = keys[0];
+                } else if (keys.length > 1) {
+                    payload.api_keys = keys;
+                }
+            }
+            return payload;
+        },
+
         async saveProvider() {
             try {
                 if (!this.showEditProviderModal && this.providerFormUsesOAuth()) {
@@ -3662,56 +3997,7 @@ function app() {
                     return;
                 }
 
-                const payload = {
-                    proxy_mode: this.normalizeProviderProxyMode(this.providerForm.proxy_mode),
-                    priority: this.providerForm.priority,
-                    enabled: this.providerForm.enabled
-                };
-                if (this.providerFormUsesOAuth()) {
-                    payload.auth_type = 'oauth';
-                    payload.oauth_provider = this.providerForm.oauth_provider;
-                    payload.oauth_ref = this.providerForm.oauth_ref;
-                    if (this.providerForm.name) {
-                        payload.name = this.providerForm.name;
-                    }
-                } else {
-                    payload.name = this.providerForm.name;
-                    payload.base_url = this.providerForm.base_url;
-                }
-                if (payload.proxy_mode === 'custom') {
-                    const proxyURL = String(this.providerForm.proxy_url || '').trim();
-                    if (proxyURL) {
-                        payload.proxy_url = proxyURL;
-                    }
-                }
-                const overrides = {};
-                if (this.providerSupportsModelOverride()) {
-                    overrides.model = String(this.providerForm.model || '');
-                }
-                if (this.providerSupportsReasoningEffort()) {
-                    overrides.openai = {
-                        reasoning_effort: String(this.providerForm.reasoning_effort || '')
-                    };
-                }
-                if (this.providerSupportsThinkingBudget()) {
-                    overrides.claude = {
-                        thinking_budget_tokens: this.normalizeThinkingBudgetTokens(this.providerForm.thinking_budget_tokens)
-                    };
-                }
-                if (Object.keys(overrides).length > 0) {
-                    payload.overrides = overrides;
-                }
-                if (!this.providerFormUsesOAuth()) {
-                    const keys = String(this.providerForm.api_keys_text || '')
-                        .split('\n')
-                        .map(v => v.trim())
-                        .filter(Boolean);
-                    if (keys.length === 1) {
-                        payload.api_key = keys[0];
-                    } else if (keys.length > 1) {
-                        payload.api_keys = keys;
-                    }
-                }
+                const payload = this.providerFormPayload();
                 if (this.showEditProviderModal) {
                     // Update existing provider
                     await this.apiCall(
@@ -3750,6 +4036,84 @@ function app() {
             }
         },
 
+        canTestProvider() {
+            return this.showEditProviderModal || !this.providerFormUsesOAuth();
+        },
+
+        async testProviderConnection() {
+            const url = this.showEditProviderModal
+                ? `/api/providers/${this.selectedClient}/${encodeURIComponent(this.editingProviderName)}/test`
+                : `/api/providers/${this.selectedClient}/_test`;
+            this.providerTest = { running: true, result: null };
+            try {
+                const result = await this.apiCall(url, {
+                    method: 'POST',
+                    body: JSON.stringify({ provider: this.providerFormPayload() })
+                }, true);
+                this.providerTest = { running: false, result };
+            } catch (error) {
+                this.providerTest = { running: false, result: null };
+                console.error('Failed to test provider:', error);
+            }
+        },
+
+        providerTestTitle() {
+            const result = this.providerTest.result;
+            if (!result) return '';
+            const passed = (result.keys || []).filter(key => key.ok).length;
+            return this.tf('modal.provider.testResult', {
+                model: result.model,
+                passed,
+                total: (result.keys || []).length
+            });
+        },
+
+        providerTestKeySummary(key) {
+            const parts = [this.tf('modal.provider.testKey', { index: Number(key.key_index || 0) + 1 })];
+            parts.push(key.status ? `HTTP ${key.status}` : this.t('modal.provider.testNoResponse'));
+            parts.push(`${Number(key.latency_ms || 0)} ms`);
+            if (key.effective_model) parts.push(key.effective_model);
+            if (!key.ok && key.reason) parts.push(key.reason);
+            if (!key.ok && key.action) parts.push(key.action);
+            return parts.join(' · ');
+        },
+
+        async loadProviderModels(refresh = false) {
+            const url = this.showEditProviderModal
+                ? `/api/providers/${this.selectedClient}/${encodeURIComponent(this.editingProviderName)}/models${refresh ? '?refresh=true' : ''}`
+                : `/api/providers/${this.selectedClient}/_models`;
+            const options = this.showEditProviderModal
+                ? {}
+                : { method: 'POST', body: JSON.stringify({ provider: this.providerFormPayload() }) };
+            this.providerModels = { loading: true, result: this.providerModels.result };
+            try {
+                const result = await this.apiCall(url, options, true);
+                this.providerModels = { loading: false, result };
+            } catch (error) {
+                this.providerModels = { loading: false, result: null };
+                console.error('Failed to load provider models:', error);
+            }
+        },
+
+        providerModelOptionLabel(model) {
+            const parts = [];
+            if (model.display_name && model.display_name !== model.id) parts.push(model.display_name);
+            if (model.context_window) {
+                parts.push(this.tf('modal.provider.contextWindow', { tokens: this.formatCompactTokenCount(model.context_window) }));
+            }
+            return parts.join(' · ');
+        },
+
+        providerModelsSummary() {
+            const result = this.providerModels.result;
+            if (!result) return '';
+            const count = (result.models || []).length;
+            if (result.cached) {
+                return this.tf('modal.provider.modelsLoadedCached', { count, time: this.formatRelativeTime(result.fetched_at) });
+            }
+            return this.tf('modal.provider.modelsLoaded', { count });
+        },
+
         async startOAuthProviderAuthorization() {
             const provider = String(this.providerForm.oauth_provider || '').trim().toLowerCase();
             if (!provider) {
@@ -4082,6 +4446,105 @@ function app() {
             }
         },
 
+        triggerConfigImportPicker() {
+            const input = this.$refs && this.$refs.configImportInput;
+            if (!input || typeof input.click !== 'function') return;
+            input.value = '';
+            input.click();
+        },
+
+        async handleConfigImportSelection(event) {
+            const input = event && event.target ? event.target : null;
+            const file = input && input.files ? input.files[0] : null;
+            try {
+                if (file) await this.importConfigBundle(await file.text());
+            } catch (error) {
+                console.error('Failed to import config:', error);
+            } finally {
+                if (input) input.value = '';
+            }
+        },
+
+        // importConfigBundle previews the bundle and applies it after confirmation.
+        async importConfigBundle(text) {
+            const target = '/api/config/import?strategy=' + encodeURIComponent(this.configImportStrategy);
+            const preview = await this.apiCall(target + '&dry_run=true', { method: 'POST', body: text });
+            const changes = Array.isArray(preview.changes) ? preview.changes : [];
+            if (changes.length === 0) {
+                this.showAlert('info', this.t('settings.importNothing'));
+                return null;
+            }
+            if (!confirm(this.tf('settings.importConfirm', { count: changes.length, changes: this.configImportSummary(changes) }))) {
+                return null;
+            }
+            const result = await this.apiCall(target, { method: 'POST', body: text });
+            this.showAlert('success', this.tf('settings.importSuccess', { count: changes.length }));
+            await this.loadGlobalConfig();
+            await this.refreshStatus();
+            return result;
+        },
+
+        async loadConfigRevisions() {
+            try {
+                const data = await this.apiCall('/api/config/revisions', {}, true, true);
+                this.configRevisions = Array.isArray(data.revisions) ? data.revisions : [];
+            } catch (error) {
+                console.error('Failed to load config revisions:', error);
+            }
+        },
+
+        revisionLabel(rev) {
+            const r = rev || {};
+            const at = new Date(r.time);
+            const time = Number.isNaN(at.getTime()) ? String(r.time || '') : at.toLocaleString();
+            const sourceKey = 'settings.historySource_' + r.source;
+            const source = this.lookupMessage('en', sourceKey) ? this.t(sourceKey) : r.source;
+            return [time, source].filter(Boolean).join(' · ');
+        },
+
+        revisionSummary(rev) {
+            const r = rev || {};
+            const summary = Array.isArray(r.summary) && r.summary.length > 0
+                ? r.summary.join('; ')
+                : this.t('settings.historyBaseline');
+            return r.restored_from ? this.tf('settings.historyRestoredFrom', { id: r.restored_from }) + ' · ' + summary : summary;
+        },
+
+        async showRevisionDiff(rev, against = 'previous') {
+            if (this.revisionDiff && this.revisionDiff.id === rev.id && this.revisionDiff.against === against) {
+                this.revisionDiff = null;
+                return;
+            }
+            const data = await this.apiCall(`/api/config/revisions/${encodeURIComponent(rev.id)}/diff?against=${against}`, {}, true);
+            const files = Array.isArray(data.files) ? data.files : [];
+            this.revisionDiff = {
+                id: rev.id,
+                against,
+                text: files.length > 0
+                    ? files.map(f => `--- ${f.file}\n${f.diff}`).join('\n')
+                    : this.t('settings.historyNoDiff')
+            };
+        },
+
+        async restoreConfigRevision(rev) {
+            if (!confirm(this.tf('settings.historyRestoreConfirm', { time: this.revisionLabel(rev) }))) return null;
+            const result = await this.apiCall(`/api/config/revisions/${encodeURIComponent(rev.id)}/restore`, { method: 'POST' });
+            this.showAlert('success', this.t('settings.historyRestoreSuccess'));
+            this.revisionDiff = null;
+            await Promise.all([this.loadGlobalConfig(), this.loadProviders(), this.loadConfigRevisions(), this.refreshStatus()]);
+            return result;
+        },
+
+        configImportSummary(changes) {
+            const signs = { add: '+', update: '~', remove: '-' };
+            return changes.map(change => {
+                let line = (signs[change.action] || '~') + ' ' + change.section;
+                if (change.item) line += ' ' + change.item;
+                if (Array.isArray(change.fields) && change.fields.length > 0) line += ' (' + change.fields.join(', ') + ')';
+                return line;
+            }).join('\n');
+        },
+
         defaultToastTitle(type) {
             switch (String(type || '').trim()) {
                 case 'success':
@@ -4199,6 +4662,8 @@ function app() {
             };
             this.editingProviderName = '';
             this.editingProviderKeyCount = 0;
+            this.providerTest = { running: false, result: null };
+            this.providerModels = { loading: false, result: null };
         }
     };
 }
@@ -1920,3 +1920,205 @@ test('saveGlobalConfig normalizes and clears non-custom upstream proxy settings'
     assert.equal(calls[0].options.upstream_proxy_mode, 'direct');
     assert.equal(calls[0].options.upstream_proxy_url, '');
 });
+
+test('refreshTopSessions keeps the ten costliest attributed sessions', async () => {
+    const groups = [{ key: '', cost_micros: 900 }];
+    for (let i = 0; i < 12; i += 1) {
+        groups.push({ key: `session-${i}`, cost_micros: 100 - i, requests: 1200, total_tokens: 45000 });
+    }
+    let requestedURL = '';
+    const state = loadApp({
+        context: {
+            fetch: async url => {
+                requestedURL = url;
+                return { ok: true, json: async () => ({ group_by: 'session', groups }) };
+            }
+        }
+    });
+
+    await state.refreshTopSessions();
+
+    assert.equal(requestedURL, '/api/usage/requests?since=7d&group_by=session');
+    assert.equal(state.topSessions.length, 10);
+    assert.equal(state.topSessions[0].key, 'session-0');
+    assert.equal(state.topSessionSummary(state.topSessions[0]), '1,200 requests · 45K tokens');
+    assert.equal(state.topSessionLabel({ key: 'x'.repeat(50) }), `${'x'.repeat(40)}…`);
+});
+
+test('pushLiveEvent keeps the newest runtime events once each', () => {
+    const state = loadApp();
+
+    for (let id = 1; id <= 55; id += 1) {
+        state.pushLiveEvent({ id, type: 'request_completed', provider: 'p1', status: 200, duration_ms: id });
+    }
+    state.pushLiveEvent({ id: 55, type: 'request_completed' });
+
+    assert.equal(state.liveEvents.length, 50);
+    assert.equal(state.liveEvents[0].id, 55);
+    assert.equal(state.liveEventSummary(state.liveEvents[0]), 'p1 · 200 · 55ms');
+    assert.equal(state.liveEventSummary({ type: 'provider_switched', from: 'p1', to: 'p2' }), 'Switched p1 → p2');
+});
+
+test('searchLogs sends the log filters and formats entries', async () => {
+    let requestedURL = '';
+    const state = loadApp({
+        context: {
+            fetch: async url => {
+                requestedURL = url;
+                return {
+                    ok: true,
+                    json: async () => ({ entries: [{ time: 'bad', level: 'WARN', client: 'claude', message: '[claude] p1 returned 429' }] })
+                };
+            }
+        }
+    });
+    state.logsQuery.provider = ' p1 ';
+    state.logsQuery.level = 'warn';
+
+    await state.searchLogs();
+
+    assert.equal(requestedURL, '/api/logs?level=warn&provider=p1&since=24h&limit=200');
+    assert.equal(state.logsText(), '[WARN ] bad [claude] p1 returned 429');
+});
+
+test('importConfigBundle previews with dry_run before applying', async () => {
+    const requests = [];
+    let confirmed = '';
+    const state = loadApp({
+        context: {
+            confirm: message => {
+                confirmed = message;
+                return true;
+            },
+            fetch: async (url, options = {}) => {
+                requests.push(`${options.method || 'GET'} ${url}`);
+                return {
+                    ok: true,
+                    json: async () => ({
+                        changes: [{ section: 'openai', item: 'p1', action: 'update', fields: ['base_url'] }],
+                        applied: !String(url).includes('dry_run')
+                    })
+                };
+            }
+        }
+    });
+    state.configImportStrategy = 'add_only';
+
+    const result = await state.importConfigBundle('{}');
+
+    assert.deepEqual(requests.slice(0, 2), [
+        'POST /api/config/import?strategy=add_only&dry_run=true',
+        'POST /api/config/import?strategy=add_only'
+    ]);
+    assert.match(confirmed, /~ openai p1 \(base_url\)/);
+    assert.equal(result.applied, true);
+});
+
+test('config revision helpers label sources and fetch diffs', async () => {
+    const requests = [];
+    const state = loadApp({
+        context: {
+            fetch: async url => {
+                requests.push(url);
+                return {
+                    ok: true,
+                    json: async () => ({ files: [{ file: 'openai.yaml', diff: '@@ -1,1 +1,1 @@\n-a\n+b\n' }] })
+                };
+            }
+        }
+    });
+    const rev = { id: '20260301T100000.000000000Z', time: 'bad', source: 'file', summary: [], restored_from: 'r1' };
+
+    assert.equal(state.revisionLabel(rev), 'bad · File edit');
+    assert.equal(state.revisionSummary(rev), 'Restored from r1 · Initial snapshot');
+
+    await state.showRevisionDiff(rev, 'current');
+    assert.equal(requests[0], '/api/config/revisions/20260301T100000.000000000Z/diff?against=current');
+    assert.equal(state.revisionDiff.text, '--- openai.yaml\n@@ -1,1 +1,1 @@\n-a\n+b\n');
+
+    await state.showRevisionDiff(rev, 'current');
+    assert.equal(state.revisionDiff, null);
+});
+
+test('testProviderConnection posts the form as a draft or as edits and summarizes keys', async () => {
+    const state = loadApp();
+    const calls = [];
+    state.selectedClient = 'openai';
+    state.providerForm = {
+        name: 'draft',
+        base_url: 'https://example.com',
+        api_keys_text: 'key-1\nkey-2',
+        priority: 1,
+        enabled: true
+    };
+    const result = {
+        provider: 'draft',
+        model: 'gpt-4.1-mini',
+        ok: false,
+        keys: [
+            { key_index: 0, ok: true, status: 200, latency_ms: 120, effective_model: 'gpt-4.1-mini' },
+            { key_index: 1, ok: false, status: 401, latency_ms: 80, effective_model: 'gpt-4.1-mini', reason: 'auth', action: 'deactivate_key' }
+        ]
+    };
+    state.apiCall = async (url, options) => {
+        calls.push({ url, body: JSON.parse(options.body) });
+        return result;
+    };
+
+    await state.testProviderConnection();
+    assert.equal(calls[0].url, '/api/providers/openai/_test');
+    assert.deepEqual(calls[0].body.provider.api_keys, ['key-1', 'key-2']);
+    assert.equal(state.providerTestTitle(), 'Test with gpt-4.1-mini: 1/2 keys OK');
+    assert.equal(state.providerTestKeySummary(result.keys[1]), 'Key 2 · HTTP 401 · 80 ms · gpt-4.1-mini · auth · deactivate_key');
+
+    state.showEditProviderModal = true;
+    state.editingProviderName = 'p 1';
+    await state.testProviderConnection();
+    assert.equal(calls[1].url, '/api/providers/openai/p%201/test');
+
+    state.closeModals();
+    assert.equal(state.providerTest.result, null);
+});
+
+test('loadProviderModels lists draft and saved provider models for the model picker', async () => {
+    const state = loadApp();
+    const calls = [];
+    state.selectedClient = 'claude';
+    state.providerForm = {
+        name: 'draft',
+        base_url: 'https://example.com',
+        api_keys_text: 'key-1',
+        priority: 1,
+        enabled: true
+    };
+    const result = {
+        provider: 'draft',
+        fetched_at: new Date().toISOString(),
+        cached: false,
+        models: [
+            { id: 'claude-haiku-4-5', display_name: 'Claude Haiku 4.5', context_window: 200000 },
+            { id: 'claude-sonnet-4-5' }
+        ]
+    };
+    state.apiCall = async (url, options) => {
+        calls.push({ url, options });
+        return result;
+    };
+
+    await state.loadProviderModels();
+    assert.equal(calls[0].url, '/api/providers/claude/_models');
+    assert.equal(calls[0].options.method, 'POST');
+    assert.equal(JSON.parse(calls[0].options.body).provider.name, 'draft');
+    assert.equal(state.providerModelsSummary(), '2 models available upstream');
+    assert.equal(state.providerModelOptionLabel(result.models[0]), 'Claude Haiku 4.5 · 200K context');
+    assert.equal(state.providerModelOptionLabel(result.models[1]), '');
+
+    state.showEditProviderModal = true;
+    state.editingProviderName = 'p 1';
+    await state.loadProviderModels(true);
+    assert.equal(calls[1].url, '/api/providers/claude/p%201/models?refresh=true');
+    assert.equal(calls[1].options.method, undefined);
+
+    state.closeModals();
+    assert.equal(state.providerModels.result, null);
+});
@@ -0,0 +1,119 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/config"
+	"github.com/lansespirit/Clipal/internal/proxy"
+)
+
+// HandleStickyBindings lists, purges and re-pins the sticky bindings of the
+// running proxy.
+//
+//	GET    /api/routing/sticky/{client}?scope=
+//	DELETE /api/routing/sticky/{client}?scope=
+//	DELETE /api/routing/sticky/{client}/{id}
+//	PUT    /api/routing/sticky/{client}/{id}
+func (a *API) HandleStickyBindings(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), "/api/routing/sticky/"), "/"), "/")
+	if len(parts) > 2 || parts[0] == "" {
+		writeError(w, "invalid request path", http.StatusBadRequest)
+		return
+	}
+	clientType, ok := config.CanonicalClientType(parts[0])
+	if !ok {
+		writeError(w, "invalid client type", http.StatusBadRequest)
+		return
+	}
+	id := ""
+	if len(parts) == 2 {
+		id, _ = url.PathUnescape(parts[1])
+	}
+	if a.runtime == nil {
+		writeError(w, "sticky bindings are only available while the proxy is running", http.StatusServiceUnavailable)
+		return
+	}
+	ct := proxy.ClientType(clientType)
+	scope := strings.TrimSpace(r.URL.Query().Get("scope"))
+
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		bindings, err := a.runtime.StickyBindings(ct, scope)
+		if err != nil {
+			writeStickyError(w, err)
+			return
+		}
+		now := time.Now()
+		resp := StickyBindingsResponse{ClientType: clientType, Bindings: make([]StickyBindingStatus, 0, len(bindings))}
+		for _, b := range bindings {
+			resp.Bindings = append(resp.Bindings, stickyBindingStatus(b, now))
+		}
+		writeJSON(w, resp)
+	case r.Method == http.MethodDelete:
+		removed, err := a.runtime.PurgeStickyBindings(ct, scope, id)
+		if err != nil {
+			writeStickyError(w, err)
+			return
+		}
+		writeJSON(w, StickyPurgeResponse{Removed: removed})
+	case r.Method == http.MethodPut && id != "":
+		var req StickyRepinRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Provider) == "" {
+			writeError(w, "provider is required", http.StatusBadRequest)
+			return
+		}
+		b, err := a.runtime.RepinStickyBinding(ct, id, strings.TrimSpace(req.Provider), req.Key)
+		if err != nil {
+			writeStickyError(w, err)
+			return
+		}
+		writeJSON(w, stickyBindingStatus(b, time.Now()))
+	default:
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeStickyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, proxy.ErrStickyBindingNotFound), errors.Is(err, proxy.ErrProviderNotRunning):
+		writeError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, proxy.ErrInvalidStickyScope), errors.Is(err, proxy.ErrInvalidRuntimeControl):
+		writeError(w, err.Error(), http.StatusBadRequest)
+	default:
+		writeAPIError(w, err)
+	}
+}
+
+func stickyBindingStatus(b proxy.StickyBindingSnapshot, now time.Time) StickyBindingStatus {
+	out := StickyBindingStatus{
+		ID:         b.ID,
+		Kind:       string(b.Kind),
+		Scope:      b.Scope,
+		Level:      b.Level,
+		Source:     b.Source,
+		Provider:   b.Provider,
+		Key:        b.Key,
+		LastSeenAt: b.LastSeenAt.Format(time.RFC3339),
+		Preview:    b.Preview,
+	}
+	if !b.BoundAt.IsZero() {
+		out.BoundAt = b.BoundAt.Format(time.RFC3339)
+	}
+	if !b.ExpiresAt.IsZero() {
+		out.ExpiresAt = b.ExpiresAt.Format(time.RFC3339)
+		if remaining := b.ExpiresAt.Sub(now).Truncate(time.Second); remaining > 0 {
+			out.TTLRemaining = remaining.String()
+		}
+	}
+	return out
+}
@@ -0,0 +1,55 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleStickyBindings_ListsPurgesAndRejectsUnknownBindings(t *testing.T) {
+	api, _, _, _ := newRuntimeAPI(t)
+	mux := http.NewServeMux()
+	(&Handler{api: api}).RegisterRoutes(mux)
+	call := func(method string, target string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "http://localhost"+target, bytes.NewBufferString(body))
+		req.Host = "localhost:3333"
+		req.RemoteAddr = "127.0.0.1:12345"
+		req.Header.Set("X-Clipal-UI", "1")
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := call(http.MethodGet, "/api/routing/sticky/codex?scope=openai_responses", "")
+	var list StickyBindingsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || w.Code != http.StatusOK || list.ClientType != "openai" || list.Bindings == nil {
+		t.Fatalf("list status=%d body=%s", w.Code, w.Body.String())
+	}
+	w = call(http.MethodDelete, "/api/routing/sticky/openai", "")
+	if w.Code != http.StatusOK || w.Body.String() != "{\"removed\":0}\n" {
+		t.Fatalf("purge status=%d body=%s", w.Code, w.Body.String())
+	}
+
+	for _, tt := range []struct {
+		method string
+		target string
+		body   string
+		status int
+	}{
+		{http.MethodGet, "/api/routing/sticky/openai?scope=bogus", "", http.StatusBadRequest},
+		{http.MethodGet, "/api/routing/sticky/unknown", "", http.StatusBadRequest},
+		{http.MethodDelete, "/api/routing/sticky/openai/0123456789abcdef", "", http.StatusNotFound},
+		{http.MethodPut, "/api/routing/sticky/openai/0123456789abcdef", `{"provider":"p1"}`, http.StatusNotFound},
+		{http.MethodPut, "/api/routing/sticky/openai/0123456789abcdef", `{}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/routing/sticky/openai", `{}`, http.StatusMethodNotAllowed},
+	} {
+		if w := call(tt.method, tt.target, tt.body); w.Code != tt.status {
+			t.Fatalf("%s %s: status=%d body=%s", tt.method, tt.target, w.Code, w.Body.String())
+		}
+	}
+}
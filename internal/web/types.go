@@ -5,12 +5,14 @@ package web
 // and lets us redact sensitive fields like API keys.
 
 import (
+	"encoding/json"
 	"net/url"
 	"strings"
 	"time"
 
 	"github.com/lansespirit/Clipal/internal/config"
 	integrationpkg "github.com/lansespirit/Clipal/internal/integration"
+	oauthpkg "github.com/lansespirit/Clipal/internal/oauth"
 	"github.com/lansespirit/Clipal/internal/telemetry"
 )
 
@@ -31,6 +33,8 @@ type GlobalConfigRequest struct {
 	Notifications         NotificationsConfigRequest  `json:"notifications"`
 	CircuitBreaker        CircuitBreakerConfigRequest `json:"circuit_breaker"`
 	Routing               RoutingConfigRequest        `json:"routing"`
+	UsageLedger           UsageLedgerConfigRequest    `json:"usage_ledger"`
+	Tracing               TracingConfigRequest        `json:"tracing"`
 }
 
 type NotificationsConfigRequest struct {
@@ -47,8 +51,11 @@ type CircuitBreakerConfigRequest struct {
 }
 
 type RoutingConfigRequest struct {
-	StickySessions   StickySessionsConfigRequest   `json:"sticky_sessions"`
-	BusyBackpressure BusyBackpressureConfigRequest `json:"busy_backpressure"`
+	StickySessions    StickySessionsConfigRequest    `json:"sticky_sessions"`
+	BusyBackpressure  BusyBackpressureConfigRequest  `json:"busy_backpressure"`
+	FirstTokenTimeout FirstTokenTimeoutConfigRequest `json:"first_token_timeout"`
+	StreamRecovery    StreamRecoveryConfigRequest    `json:"stream_recovery"`
+	CountTokens       *string                        `json:"count_tokens,omitempty"`
 }
 
 type StickySessionsConfigRequest struct {
@@ -62,6 +69,29 @@ type BusyBackpressureConfigRequest struct {
 	MaxInlineWait      *string `json:"max_inline_wait,omitempty"`
 }
 
+type FirstTokenTimeoutConfigRequest struct {
+	Default      *string            `json:"default,omitempty"`
+	Capabilities *map[string]string `json:"capabilities,omitempty"`
+}
+
+type StreamRecoveryConfigRequest struct {
+	Enabled     *bool `json:"enabled,omitempty"`
+	MaxAttempts *int  `json:"max_attempts,omitempty"`
+}
+
+type UsageLedgerConfigRequest struct {
+	Enabled       *bool `json:"enabled,omitempty"`
+	RetentionDays *int  `json:"retention_days,omitempty"`
+	MaxEntries    *int  `json:"max_entries,omitempty"`
+}
+
+type TracingConfigRequest struct {
+	Enabled     *bool              `json:"enabled,omitempty"`
+	Endpoint    *string            `json:"endpoint,omitempty"`
+	Headers     *map[string]string `json:"headers,omitempty"`
+	ServiceName *string            `json:"service_name,omitempty"`
+}
+
 // GlobalConfigResponse represents the global configuration returned to the UI.
 type GlobalConfigResponse struct {
 	ListenAddr            string                       `json:"listen_addr"`
@@ -79,6 +109,8 @@ type GlobalConfigResponse struct {
 	Notifications         NotificationsConfigResponse  `json:"notifications"`
 	CircuitBreaker        CircuitBreakerConfigResponse `json:"circuit_breaker"`
 	Routing               RoutingConfigResponse        `json:"routing"`
+	UsageLedger           UsageLedgerConfigResponse    `json:"usage_ledger"`
+	Tracing               TracingConfigResponse        `json:"tracing"`
 }
 
 type NotificationsConfigResponse struct {
@@ -95,8 +127,11 @@ type CircuitBreakerConfigResponse struct {
 }
 
 type RoutingConfigResponse struct {
-	StickySessions   StickySessionsConfigResponse   `json:"sticky_sessions"`
-	BusyBackpressure BusyBackpressureConfigResponse `json:"busy_backpressure"`
+	StickySessions    StickySessionsConfigResponse    `json:"sticky_sessions"`
+	BusyBackpressure  BusyBackpressureConfigResponse  `json:"busy_backpressure"`
+	FirstTokenTimeout FirstTokenTimeoutConfigResponse `json:"first_token_timeout"`
+	StreamRecovery    StreamRecoveryConfigResponse    `json:"stream_recovery"`
+	CountTokens       string                          `json:"count_tokens"`
 }
 
 type StickySessionsConfigResponse struct {
@@ -110,6 +145,31 @@ type BusyBackpressureConfigResponse struct {
 	MaxInlineWait      string `json:"max_inline_wait"`
 }
 
+type FirstTokenTimeoutConfigResponse struct {
+	Default      string            `json:"default"`
+	Capabilities map[string]string `json:"capabilities"`
+}
+
+type StreamRecoveryConfigResponse struct {
+	Enabled     bool `json:"enabled"`
+	MaxAttempts int  `json:"max_attempts"`
+}
+
+type UsageLedgerConfigResponse struct {
+	Enabled       bool `json:"enabled"`
+	RetentionDays int  `json:"retention_days"`
+	MaxEntries    int  `json:"max_entries"`
+}
+
+// TracingConfigResponse lists only header names; values often hold collector
+// credentials.
+type TracingConfigResponse struct {
+	Enabled     bool     `json:"enabled"`
+	Endpoint    string   `json:"endpoint"`
+	HeaderNames []string `json:"header_names"`
+	ServiceName string   `json:"service_name"`
+}
+
 type ClientConfigRequest struct {
 	Mode           string `json:"mode"`
 	PinnedProvider string `json:"pinned_provider"`
@@ -209,6 +269,7 @@ type ProviderResponse struct {
 type ProviderUsageResponse struct {
 	RequestCount     int64                         `json:"request_count,omitempty"`
 	SuccessCount     int64                         `json:"success_count,omitempty"`
+	RecoveredCount   int64                         `json:"recovered_count,omitempty"`
 	InputTokens      int64                         `json:"input_tokens,omitempty"`
 	OutputTokens     int64                         `json:"output_tokens,omitempty"`
 	TotalTokens      int64                         `json:"total_tokens,omitempty"`
@@ -217,9 +278,27 @@ type ProviderUsageResponse struct {
 	UsageBreakdowns  []ProviderUsageBreakdownEntry `json:"usage_breakdowns,omitempty"`
 	SpendTodayMicros int64                         `json:"spend_today_micros,omitempty"`
 	SpendWeekMicros  int64                         `json:"spend_week_micros,omitempty"`
+	SpendHourMicros  int64                         `json:"spend_hour_micros,omitempty"`
 	LastUsedAt       string                        `json:"last_used_at,omitempty"`
 	HasUsage         bool                          `json:"has_usage,omitempty"`
 	HasCost          bool                          `json:"has_cost,omitempty"`
+	Models           map[string]UsageCounters      `json:"models,omitempty"`
+	Capabilities     map[string]UsageCounters      `json:"capabilities,omitempty"`
+	Keys             map[string]UsageCounters      `json:"keys,omitempty"`
+}
+
+// UsageCounters is a provider's usage within one model, capability or API
+// key fingerprint.
+type UsageCounters struct {
+	RequestCount    int64 `json:"request_count,omitempty"`
+	SuccessCount    int64 `json:"success_count,omitempty"`
+	InputTokens     int64 `json:"input_tokens,omitempty"`
+	OutputTokens    int64 `json:"output_tokens,omitempty"`
+	TotalTokens     int64 `json:"total_tokens,omitempty"`
+	ReasoningTokens int64 `json:"reasoning_tokens,omitempty"`
+	ThoughtsTokens  int64 `json:"thoughts_tokens,omitempty"`
+	CostMicros      int64 `json:"cost_micros,omitempty"`
+	HasCost         bool  `json:"has_cost,omitempty"`
 }
 
 type ProviderUsageBreakdownEntry struct {
@@ -228,6 +307,134 @@ type ProviderUsageBreakdownEntry struct {
 	Tokens int64  `json:"tokens,omitempty"`
 }
 
+// UsageRequestEntry is one completed request from the usage ledger.
+type UsageRequestEntry struct {
+	Time             string `json:"time"`
+	ClientType       string `json:"client_type"`
+	Capability       string `json:"capability,omitempty"`
+	Provider         string `json:"provider,omitempty"`
+	KeyFingerprint   string `json:"key_fingerprint,omitempty"`
+	RequestedModel   string `json:"requested_model,omitempty"`
+	EffectiveModel   string `json:"effective_model,omitempty"`
+	Status           int    `json:"status"`
+	Result           string `json:"result,omitempty"`
+	Attempts         int    `json:"attempts,omitempty"`
+	TTFBMillis       int64  `json:"ttfb_ms,omitempty"`
+	DurationMillis   int64  `json:"duration_ms,omitempty"`
+	InputTokens      int64  `json:"input_tokens,omitempty"`
+	OutputTokens     int64  `json:"output_tokens,omitempty"`
+	TotalTokens      int64  `json:"total_tokens,omitempty"`
+	ReasoningTokens  int64  `json:"reasoning_tokens,omitempty"`
+	ThoughtsTokens   int64  `json:"thoughts_tokens,omitempty"`
+	CacheReadTokens  int64  `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int64  `json:"cache_write_tokens,omitempty"`
+	CostMicros       int64  `json:"cost_micros,omitempty"`
+	HasCost          bool   `json:"has_cost,omitempty"`
+	CostSource       string `json:"cost_source,omitempty"`
+	Estimated        bool   `json:"estimated,omitempty"`
+	SessionKey       string `json:"session_key,omitempty"`
+	Project          string `json:"project,omitempty"`
+	SessionID        string `json:"session_id,omitempty"`
+}
+
+// UsageResetResponse reports where the previous period's counters were archived.
+type UsageResetResponse struct {
+	Archive     string `json:"archive"`
+	PeriodStart string `json:"period_start"`
+}
+
+// PricingConfig mirrors config.PricingConfig (pricing.yaml).
+type PricingConfig struct {
+	Models              []ModelPricing     `json:"models"`
+	ProviderMultipliers map[string]float64 `json:"provider_multipliers,omitempty"`
+	ExchangeRates       map[string]float64 `json:"exchange_rates,omitempty"`
+}
+
+type ModelPricing struct {
+	Model    string `json:"model"`
+	Currency string `json:"currency,omitempty"`
+	PriceRates
+	Tiers []PriceTier `json:"tiers,omitempty"`
+}
+
+// PriceRates are prices per million tokens; nil means unset.
+type PriceRates struct {
+	Input      *float64 `json:"input,omitempty"`
+	Output     *float64 `json:"output,omitempty"`
+	CacheRead  *float64 `json:"cache_read,omitempty"`
+	CacheWrite *float64 `json:"cache_write,omitempty"`
+	Reasoning  *float64 `json:"reasoning,omitempty"`
+}
+
+type PriceTier struct {
+	AboveInputTokens int64 `json:"above_input_tokens"`
+	PriceRates
+}
+
+// RecomputePricingRequest selects the ledger entries to re-price. Times accept
+// the same formats as the usage query API.
+type RecomputePricingRequest struct {
+	Since      string `json:"since,omitempty"`
+	Until      string `json:"until,omitempty"`
+	ClientType string `json:"client_type,omitempty"`
+	Provider   string `json:"provider,omitempty"`
+	Model      string `json:"model,omitempty"`
+}
+
+type RecomputePricingResponse struct {
+	Updated int `json:"updated"`
+}
+
+type UsageRequestsResponse struct {
+	Total   int                 `json:"total"`
+	Offset  int                 `json:"offset"`
+	Limit   int                 `json:"limit"`
+	Entries []UsageRequestEntry `json:"entries"`
+}
+
+type UsageRequestGroup struct {
+	Key               string `json:"key"`
+	Requests          int64  `json:"requests"`
+	Failures          int64  `json:"failures"`
+	InputTokens       int64  `json:"input_tokens"`
+	OutputTokens      int64  `json:"output_tokens"`
+	TotalTokens       int64  `json:"total_tokens"`
+	ReasoningTokens   int64  `json:"reasoning_tokens"`
+	CostMicros        int64  `json:"cost_micros"`
+	HasCost           bool   `json:"has_cost"`
+	AvgTTFBMillis     int64  `json:"avg_ttfb_ms"`
+	AvgDurationMillis int64  `json:"avg_duration_ms"`
+}
+
+type UsageRequestGroupsResponse struct {
+	GroupBy string              `json:"group_by"`
+	Groups  []UsageRequestGroup `json:"groups"`
+}
+
+type LogFile struct {
+	Name     string `json:"name"`
+	Day      string `json:"day"`
+	Size     int64  `json:"size"`
+	Modified string `json:"modified"`
+}
+
+type LogFilesResponse struct {
+	Dir   string    `json:"dir"`
+	Files []LogFile `json:"files"`
+}
+
+// LogEntry is one log record with secrets already redacted.
+type LogEntry struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Client  string `json:"client,omitempty"`
+	Message string `json:"message"`
+}
+
+type LogEntriesResponse struct {
+	Entries []LogEntry `json:"entries"`
+}
+
 type ProviderOAuthLimits struct {
 	Primary    *ProviderOAuthLimitWindow      `json:"primary,omitempty"`
 	Secondary  *ProviderOAuthLimitWindow      `json:"secondary,omitempty"`
@@ -264,6 +471,9 @@ type ExportConfigResponse struct {
 	Claude ClientConfigExport   `json:"claude"`
 	OpenAI ClientConfigExport   `json:"openai"`
 	Gemini ClientConfigExport   `json:"gemini"`
+	// OAuthCredentials holds the credentials linked providers use. It is only
+	// filled when the export asks for them.
+	OAuthCredentials []oauthpkg.Credential `json:"oauth_credentials,omitempty"`
 }
 
 type ClientConfigExport struct {
@@ -285,6 +495,92 @@ type ProviderExport struct {
 	Priority      int                        `json:"priority"`
 	Enabled       *bool                      `json:"enabled,omitempty"`
 	Overrides     *ProviderOverridesResponse `json:"overrides,omitempty"`
+	FailureRules  []FailureRuleRequest       `json:"failure_rules,omitempty"`
+	// FallbackModels maps a model to the models tried after it fails.
+	FallbackModels map[string][]string `json:"fallback_models,omitempty"`
+}
+
+// ConfigImportBundle reads the document written by GET /api/config/export.
+// Sections missing from the bundle are left untouched.
+type ConfigImportBundle struct {
+	Global           *GlobalConfigRequest  `json:"global,omitempty"`
+	Claude           *ClientConfigImport   `json:"claude,omitempty"`
+	OpenAI           *ClientConfigImport   `json:"openai,omitempty"`
+	Gemini           *ClientConfigImport   `json:"gemini,omitempty"`
+	OAuthCredentials []oauthpkg.Credential `json:"oauth_credentials,omitempty"`
+}
+
+type ClientConfigImport struct {
+	Mode           string           `json:"mode"`
+	PinnedProvider string           `json:"pinned_provider"`
+	Providers      []ProviderImport `json:"providers"`
+}
+
+// ProviderImport is the import-side shape of ProviderExport.
+type ProviderImport struct {
+	Name           string                    `json:"name"`
+	BaseURL        string                    `json:"base_url,omitempty"`
+	APIKey         string                    `json:"api_key,omitempty"`
+	APIKeys        []string                  `json:"api_keys,omitempty"`
+	AuthType       config.ProviderAuthType   `json:"auth_type"`
+	OAuthProvider  config.OAuthProvider      `json:"oauth_provider,omitempty"`
+	OAuthRef       string                    `json:"oauth_ref,omitempty"`
+	ProxyMode      string                    `json:"proxy_mode,omitempty"`
+	ProxyURL       string                    `json:"proxy_url,omitempty"`
+	Priority       int                       `json:"priority"`
+	Enabled        *bool                     `json:"enabled,omitempty"`
+	Overrides      *ProviderOverridesRequest `json:"overrides,omitempty"`
+	FailureRules   []FailureRuleRequest      `json:"failure_rules,omitempty"`
+	FallbackModels map[string][]string       `json:"fallback_models,omitempty"`
+}
+
+// ConfigImportChange is one entry of an import diff. Fields names the changed
+// settings without their values, so secrets never appear in a preview.
+type ConfigImportChange struct {
+	Section string   `json:"section"`
+	Item    string   `json:"item,omitempty"`
+	Action  string   `json:"action"`
+	Fields  []string `json:"fields,omitempty"`
+}
+
+type ConfigImportResponse struct {
+	Strategy string               `json:"strategy"`
+	DryRun   bool                 `json:"dry_run"`
+	Applied  bool                 `json:"applied"`
+	Changes  []ConfigImportChange `json:"changes"`
+	Message  string               `json:"message"`
+}
+
+// ConfigRevision describes one entry of the config revision history. File
+// contents are only exposed through the diff endpoint.
+type ConfigRevision struct {
+	ID           string   `json:"id"`
+	Time         string   `json:"time"`
+	Source       string   `json:"source"`
+	Summary      []string `json:"summary,omitempty"`
+	RestoredFrom string   `json:"restored_from,omitempty"`
+	Files        []string `json:"files"`
+}
+
+type ConfigRevisionsResponse struct {
+	Revisions []ConfigRevision `json:"revisions"`
+}
+
+type ConfigRevisionFileDiff struct {
+	File string `json:"file"`
+	Diff string `json:"diff"`
+}
+
+type ConfigRevisionDiffResponse struct {
+	ID      string                   `json:"id"`
+	Against string                   `json:"against"`
+	Summary []string                 `json:"summary"`
+	Files   []ConfigRevisionFileDiff `json:"files"`
+}
+
+type ConfigRevisionRestoreResponse struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
 }
 
 type OAuthStartRequest struct {
@@ -424,6 +720,8 @@ type RequestOutcomeStatus struct {
 	Result     string `json:"result,omitempty"`
 	Label      string `json:"label,omitempty"`
 	Detail     string `json:"detail,omitempty"`
+	// RecoveredFrom names the provider whose interrupted stream Provider resumed.
+	RecoveredFrom string `json:"recovered_from,omitempty"`
 }
 
 // ErrorResponse represents an error response
@@ -525,6 +823,26 @@ func toGlobalConfigResponse(gc config.GlobalConfig) GlobalConfigResponse {
 				ShortRetryAfterMax: gc.Routing.BusyBackpressure.ShortRetryAfterMax,
 				MaxInlineWait:      gc.Routing.BusyBackpressure.MaxInlineWait,
 			},
+			FirstTokenTimeout: FirstTokenTimeoutConfigResponse{
+				Default:      gc.Routing.FirstTokenTimeout.Default,
+				Capabilities: gc.Routing.FirstTokenTimeout.Capabilities,
+			},
+			StreamRecovery: StreamRecoveryConfigResponse{
+				Enabled:     gc.Routing.StreamRecovery.Enabled,
+				MaxAttempts: gc.Routing.StreamRecovery.MaxAttempts,
+			},
+			CountTokens: string(gc.Routing.CountTokens),
+		},
+		UsageLedger: UsageLedgerConfigResponse{
+			Enabled:       gc.UsageLedger.Enabled,
+			RetentionDays: gc.UsageLedger.RetentionDays,
+			MaxEntries:    gc.UsageLedger.MaxEntries,
+		},
+		Tracing: TracingConfigResponse{
+			Enabled:     gc.Tracing.Enabled,
+			Endpoint:    gc.Tracing.TracesEndpoint(),
+			HeaderNames: sortedStringMapKeys(gc.Tracing.Headers),
+			ServiceName: gc.Tracing.ServiceName,
 		},
 	}
 }
@@ -599,6 +917,7 @@ func mapProviderUsageResponse(usage telemetry.ProviderUsage) *ProviderUsageRespo
 	resp := &ProviderUsageResponse{
 		RequestCount:     usage.RequestCount,
 		SuccessCount:     usage.SuccessCount,
+		RecoveredCount:   usage.RecoveredCount,
 		InputTokens:      usage.InputTokens,
 		OutputTokens:     usage.OutputTokens,
 		TotalTokens:      usage.TotalTokens,
@@ -606,8 +925,12 @@ func mapProviderUsageResponse(usage telemetry.ProviderUsage) *ProviderUsageRespo
 		ThoughtsTokens:   usage.ThoughtsTokens,
 		SpendTodayMicros: spendTodayMicros,
 		SpendWeekMicros:  spendWeekMicros,
+		SpendHourMicros:  usage.HourlyCosts[now.Format("2006-01-02T15")].CostMicros,
 		HasUsage:         usage.Usage != nil,
 		HasCost:          usage.HasCost,
+		Models:           toUsageCounters(usage.Models),
+		Capabilities:     toUsageCounters(usage.Capabilities),
+		Keys:             toUsageCounters(usage.Keys),
 	}
 	if usage.ReasoningTokens > 0 {
 		resp.UsageBreakdowns = append(resp.UsageBreakdowns, ProviderUsageBreakdownEntry{
@@ -629,6 +952,27 @@ func mapProviderUsageResponse(usage telemetry.ProviderUsage) *ProviderUsageRespo
 	return resp
 }
 
+func toUsageCounters(in map[string]telemetry.UsageCounters) map[string]UsageCounters {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[string]UsageCounters, len(in))
+	for key, c := range in {
+		out[key] = UsageCounters{
+			RequestCount:    c.RequestCount,
+			SuccessCount:    c.SuccessCount,
+			InputTokens:     c.InputTokens,
+			OutputTokens:    c.OutputTokens,
+			TotalTokens:     c.TotalTokens,
+			ReasoningTokens: c.ReasoningTokens,
+			ThoughtsTokens:  c.ThoughtsTokens,
+			CostMicros:      c.CostMicros,
+			HasCost:         c.HasCost,
+		}
+	}
+	return out
+}
+
 func providerSpendForRecentDays(usage telemetry.ProviderUsage, now time.Time, days int) int64 {
 	if days <= 0 || len(usage.DailyCosts) == 0 {
 		return 0
@@ -645,6 +989,53 @@ func providerSpendForRecentDays(usage telemetry.ProviderUsage, now time.Time, da
 	return total
 }
 
+func toUsageRequestEntry(entry telemetry.LedgerEntry) UsageRequestEntry {
+	return UsageRequestEntry{
+		Time:             entry.Time.Format(time.RFC3339Nano),
+		ClientType:       entry.ClientType,
+		Capability:       entry.Capability,
+		Provider:         entry.Provider,
+		KeyFingerprint:   entry.KeyFingerprint,
+		RequestedModel:   entry.RequestedModel,
+		EffectiveModel:   entry.EffectiveModel,
+		Status:           entry.Status,
+		Result:           entry.Result,
+		Attempts:         entry.Attempts,
+		TTFBMillis:       entry.TTFBMillis,
+		DurationMillis:   entry.DurationMillis,
+		InputTokens:      entry.InputTokens,
+		OutputTokens:     entry.OutputTokens,
+		TotalTokens:      entry.TotalTokens,
+		ReasoningTokens:  entry.ReasoningTokens,
+		ThoughtsTokens:   entry.ThoughtsTokens,
+		CacheReadTokens:  entry.CacheReadTokens,
+		CacheWriteTokens: entry.CacheWriteTokens,
+		CostMicros:       entry.CostMicros,
+		HasCost:          entry.HasCost,
+		CostSource:       entry.CostSource,
+		Estimated:        entry.Estimated,
+		SessionKey:       entry.SessionKey,
+		Project:          entry.Project,
+		SessionID:        entry.SessionID,
+	}
+}
+
+func toUsageRequestGroup(group telemetry.LedgerGroup) UsageRequestGroup {
+	return UsageRequestGroup{
+		Key:               group.Key,
+		Requests:          group.Requests,
+		Failures:          group.Failures,
+		InputTokens:       group.InputTokens,
+		OutputTokens:      group.OutputTokens,
+		TotalTokens:       group.TotalTokens,
+		ReasoningTokens:   group.ReasoningTokens,
+		CostMicros:        group.CostMicros,
+		HasCost:           group.HasCost,
+		AvgTTFBMillis:     group.AvgTTFBMillis,
+		AvgDurationMillis: group.AvgDurationMillis,
+	}
+}
+
 func toClientConfigExport(cc config.ClientConfig) ClientConfigExport {
 	out := make([]ProviderExport, 0, len(cc.Providers))
 	for _, p := range cc.Providers {
@@ -659,6 +1050,10 @@ func toClientConfigExport(cc config.ClientConfig) ClientConfigExport {
 			Priority:      p.Priority,
 			Enabled:       p.Enabled,
 			Overrides:     mapProviderOverridesResponse(p),
+			FailureRules:  toFailureRuleRequests(p.FailureRules),
+		}
+		if len(p.FallbackModels) > 0 {
+			export.FallbackModels = map[string][]string(p.FallbackModels)
 		}
 		if !p.UsesOAuth() {
 			export.APIKey = p.APIKey
@@ -712,3 +1107,287 @@ func toIntegrationResponse(product integrationpkg.ProductID, status integrationp
 		BackupTargetExisted: preview.BackupTargetExisted,
 	}
 }
+
+// FailureRuleRequest mirrors config.FailureRule for draft rules sent by the UI.
+type FailureRuleRequest struct {
+	Name      string            `json:"name,omitempty"`
+	Status    []int             `json:"status,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	BodyRegex string            `json:"body_regex,omitempty"`
+	JSONPath  string            `json:"json_path,omitempty"`
+	JSONValue string            `json:"json_value,omitempty"`
+	Action    string            `json:"action"`
+	Reason    string            `json:"reason,omitempty"`
+	Cooldown  string            `json:"cooldown,omitempty"`
+}
+
+// FailureRuleTestRequest describes a sample upstream response to classify.
+// When Rules is set it replaces the provider's rules (or the global rules when
+// Provider is empty) so drafts can be tested before they are saved.
+type FailureRuleTestRequest struct {
+	ClientType string                `json:"client_type,omitempty"`
+	Provider   string                `json:"provider,omitempty"`
+	Rules      *[]FailureRuleRequest `json:"rules,omitempty"`
+	Status     int                   `json:"status"`
+	Headers    map[string]string     `json:"headers,omitempty"`
+	Body       string                `json:"body"`
+}
+
+// FailureRuleTestResponse reports how the proxy would handle the sample response.
+type FailureRuleTestResponse struct {
+	Matched   bool   `json:"matched"`
+	Source    string `json:"source"`
+	RuleIndex *int   `json:"rule_index,omitempty"`
+	RuleName  string `json:"rule_name,omitempty"`
+	Action    string `json:"action"`
+	Reason    string `json:"reason,omitempty"`
+	Cooldown  string `json:"cooldown,omitempty"`
+}
+
+// ProviderTestRequest configures a provider connection test. Provider carries
+// unsaved edits for a saved provider, or the whole draft for /_test.
+type ProviderTestRequest struct {
+	Model    string           `json:"model,omitempty"`
+	Provider *ProviderRequest `json:"provider,omitempty"`
+}
+
+// ProviderTestResponse reports the connection test result for every key.
+type ProviderTestResponse struct {
+	Provider string                  `json:"provider"`
+	Model    string                  `json:"model"`
+	OK       bool                    `json:"ok"`
+	Keys     []ProviderKeyTestResult `json:"keys"`
+}
+
+type ProviderKeyTestResult struct {
+	KeyIndex       int    `json:"key_index"`
+	KeyFingerprint string `json:"key_fingerprint,omitempty"`
+	OK             bool   `json:"ok"`
+	Status         int    `json:"status,omitempty"`
+	LatencyMS      int64  `json:"latency_ms"`
+	EffectiveModel string `json:"effective_model,omitempty"`
+	Action         string `json:"action,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// ProviderModelsResponse lists the models a provider offers upstream.
+type ProviderModelsResponse struct {
+	Provider  string          `json:"provider"`
+	FetchedAt string          `json:"fetched_at"`
+	Cached    bool            `json:"cached"`
+	Models    []ProviderModel `json:"models"`
+}
+
+type ProviderModel struct {
+	ID              string   `json:"id"`
+	DisplayName     string   `json:"display_name,omitempty"`
+	ContextWindow   int      `json:"context_window,omitempty"`
+	MaxOutputTokens int      `json:"max_output_tokens,omitempty"`
+	Capabilities    []string `json:"capabilities,omitempty"`
+}
+
+func toFailureRuleRequests(rules []config.FailureRule) []FailureRuleRequest {
+	if len(rules) == 0 {
+		return nil
+	}
+	out := make([]FailureRuleRequest, 0, len(rules))
+	for _, rule := range rules {
+		out = append(out, FailureRuleRequest{
+			Name:      rule.Name,
+			Status:    append([]int(nil), rule.Status...),
+			Headers:   rule.Headers,
+			BodyRegex: rule.BodyRegex,
+			JSONPath:  rule.JSONPath,
+			JSONValue: rule.JSONValue,
+			Action:    string(rule.Action),
+			Reason:    rule.Reason,
+			Cooldown:  rule.Cooldown,
+		})
+	}
+	return out
+}
+
+func failureRulesFromRequest(rules []FailureRuleRequest) []config.FailureRule {
+	out := make([]config.FailureRule, 0, len(rules))
+	for _, rule := range rules {
+		out = append(out, config.FailureRule{
+			Name:      strings.TrimSpace(rule.Name),
+			Status:    append([]int(nil), rule.Status...),
+			Headers:   rule.Headers,
+			BodyRegex: rule.BodyRegex,
+			JSONPath:  strings.TrimSpace(rule.JSONPath),
+			JSONValue: rule.JSONValue,
+			Action:    config.FailureRuleAction(strings.TrimSpace(rule.Action)),
+			Reason:    strings.TrimSpace(rule.Reason),
+			Cooldown:  strings.TrimSpace(rule.Cooldown),
+		})
+	}
+	return out
+}
+
+func toPricingConfig(p config.PricingConfig) PricingConfig {
+	out := PricingConfig{
+		Models:              make([]ModelPricing, 0, len(p.Models)),
+		ProviderMultipliers: p.ProviderMultipliers,
+		ExchangeRates:       p.ExchangeRates,
+	}
+	for _, entry := range p.Models {
+		model := ModelPricing{
+			Model:      entry.Model,
+			Currency:   entry.Currency,
+			PriceRates: PriceRates(entry.PriceRates),
+		}
+		for _, tier := range entry.Tiers {
+			model.Tiers = append(model.Tiers, PriceTier{
+				AboveInputTokens: tier.AboveInputTokens,
+				PriceRates:       PriceRates(tier.PriceRates),
+			})
+		}
+		out.Models = append(out.Models, model)
+	}
+	return out
+}
+
+func pricingConfigFromRequest(p PricingConfig) config.PricingConfig {
+	out := config.PricingConfig{
+		ProviderMultipliers: make(map[string]float64, len(p.ProviderMultipliers)),
+		ExchangeRates:       make(map[string]float64, len(p.ExchangeRates)),
+	}
+	for provider, multiplier := range p.ProviderMultipliers {
+		out.ProviderMultipliers[strings.TrimSpace(provider)] = multiplier
+	}
+	for currency, rate := range p.ExchangeRates {
+		out.ExchangeRates[strings.ToUpper(strings.TrimSpace(currency))] = rate
+	}
+	for _, entry := range p.Models {
+		model := config.ModelPricing{
+			Model:      strings.TrimSpace(entry.Model),
+			Currency:   strings.ToUpper(strings.TrimSpace(entry.Currency)),
+			PriceRates: config.PriceRates(entry.PriceRates),
+		}
+		for _, tier := range entry.Tiers {
+			model.Tiers = append(model.Tiers, config.PriceTier{
+				AboveInputTokens: tier.AboveInputTokens,
+				PriceRates:       config.PriceRates(tier.PriceRates),
+			})
+		}
+		out.Models = append(out.Models, model)
+	}
+	return out
+}
+
+// ProviderRuntimeControlRequest is a manual runtime action on a running provider.
+type ProviderRuntimeControlRequest struct {
+	// Action is deactivate, reactivate, reset_circuit, open_circuit or clear_busy.
+	Action string `json:"action"`
+	// Key is the 1-based key index for key-level deactivate and reactivate.
+	Key int `json:"key,omitempty"`
+	// Duration is a Go duration such as "30m" for deactivate and open_circuit.
+	Duration string `json:"duration,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// ProviderRuntimeControlResponse reports the provider availability after a
+// runtime action.
+type ProviderRuntimeControlResponse struct {
+	Provider string `json:"provider"`
+	Action   string `json:"action"`
+	Key      int    `json:"key,omitempty"`
+	State    string `json:"state"`
+	Label    string `json:"label"`
+	Detail   string `json:"detail"`
+}
+
+// StickyBindingStatus is one sticky binding of the running proxy.
+type StickyBindingStatus struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"` // binding | response_lookup | dynamic_feature
+	Scope    string `json:"scope"`
+	Level    string `json:"level"` // L1 | L2 | L3
+	Source   string `json:"source"`
+	Provider string `json:"provider"`
+	// Key is the 1-based key index of the provider.
+	Key          int    `json:"key"`
+	BoundAt      string `json:"bound_at,omitempty"`
+	LastSeenAt   string `json:"last_seen_at"`
+	ExpiresAt    string `json:"expires_at,omitempty"`
+	TTLRemaining string `json:"ttl_remaining,omitempty"`
+	Preview      string `json:"preview,omitempty"`
+}
+
+type StickyBindingsResponse struct {
+	ClientType string                `json:"client_type"`
+	Bindings   []StickyBindingStatus `json:"bindings"`
+}
+
+type StickyPurgeResponse struct {
+	Removed int `json:"removed"`
+}
+
+// StickyRepinRequest moves a sticky binding to another provider. Key is the
+// 1-based key index; 0 keeps the current key on the same provider and uses
+// the first key of another one.
+type StickyRepinRequest struct {
+	Provider string `json:"provider"`
+	Key      int    `json:"key,omitempty"`
+}
+
+// RoutingExplainRequest is a sample client request to explain. Path is the
+// client path, such as /clipal/v1/messages, with an optional query. Body is
+// the JSON request body; a JSON string is sent as raw text instead.
+type RoutingExplainRequest struct {
+	Method  string            `json:"method,omitempty"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// RoutingExplainResponse is how the running proxy would route a request.
+type RoutingExplainResponse struct {
+	ClientType string                    `json:"client_type"`
+	Method     string                    `json:"method"`
+	Path       string                    `json:"path"`
+	Capability string                    `json:"capability"`
+	Scope      string                    `json:"scope"`
+	Handling   string                    `json:"handling"` // failover | manual | single_shot | local | rejected
+	Notes      []string                  `json:"notes,omitempty"`
+	Sticky     *RoutingExplainSticky     `json:"sticky,omitempty"`
+	Candidates []RoutingExplainCandidate `json:"candidates"`
+}
+
+type RoutingExplainSticky struct {
+	Level    string `json:"level"`
+	Source   string `json:"source"`
+	Preview  string `json:"preview,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	Key      int    `json:"key,omitempty"`
+	Applied  bool   `json:"applied"`
+	Detail   string `json:"detail"`
+}
+
+// RoutingExplainCandidate is one provider in routing order. Attempt is the
+// 1-based attempt position, or 0 when the provider would be skipped.
+type RoutingExplainCandidate struct {
+	Provider       string                  `json:"provider"`
+	Attempt        int                     `json:"attempt"`
+	Skipped        bool                    `json:"skipped"`
+	Reason         string                  `json:"reason"`
+	Key            int                     `json:"key,omitempty"`
+	KeyFingerprint string                  `json:"key_fingerprint,omitempty"`
+	AvailableKeys  int                     `json:"available_keys"`
+	Model          string                  `json:"model,omitempty"`
+	FallbackModels []string                `json:"fallback_models,omitempty"`
+	Request        *RoutingExplainUpstream `json:"request,omitempty"`
+	Error          string                  `json:"error,omitempty"`
+}
+
+// RoutingExplainUpstream is the upstream request an attempt would send, with
+// credentials redacted. Body holds JSON bodies and BodyText anything else.
+type RoutingExplainUpstream struct {
+	Method   string              `json:"method"`
+	URL      string              `json:"url"`
+	Headers  map[string][]string `json:"headers"`
+	Body     json.RawMessage     `json:"body,omitempty"`
+	BodyText string              `json:"body_text,omitempty"`
+}
@@ -0,0 +1,149 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lansespirit/Clipal/internal/logger"
+	"github.com/lansespirit/Clipal/internal/telemetry"
+)
+
+const (
+	usageRequestsDefaultLimit = 50
+	usageRequestsMaxLimit     = 500
+)
+
+// HandleListUsageRequests lists usage ledger entries, or aggregates them when
+// group_by is set.
+//
+//	GET /api/usage/requests?since=24h&provider=p1&model=...&limit=50&offset=0
+//	GET /api/usage/requests?since=7d&group_by=model
+func (a *API) HandleListUsageRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, err := parseUsageRequestsQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ledger, err := a.usageLedger()
+	if err != nil {
+		writeError(w, fmt.Sprintf("failed to load usage ledger: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if groupBy := strings.TrimSpace(r.URL.Query().Get("group_by")); groupBy != "" {
+		groups, err := ledger.Aggregate(query, groupBy)
+		if err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := UsageRequestGroupsResponse{GroupBy: groupBy, Groups: make([]UsageRequestGroup, 0, len(groups))}
+		for _, group := range groups {
+			resp.Groups = append(resp.Groups, toUsageRequestGroup(group))
+		}
+		writeJSON(w, resp)
+		return
+	}
+
+	entries, total := ledger.Query(query)
+	resp := UsageRequestsResponse{
+		Total:   total,
+		Offset:  query.Offset,
+		Limit:   query.Limit,
+		Entries: make([]UsageRequestEntry, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		resp.Entries = append(resp.Entries, toUsageRequestEntry(entry))
+	}
+	writeJSON(w, resp)
+}
+
+// usageLedger returns the running proxy's ledger, or a fresh read of the
+// ledger file when the API runs without a proxy runtime.
+func (a *API) usageLedger() (*telemetry.Ledger, error) {
+	if a.runtime != nil {
+		if ledger := a.runtime.UsageLedger(); ledger != nil {
+			return ledger, nil
+		}
+	}
+	return telemetry.OpenLedger(a.configDir)
+}
+
+func parseUsageRequestsQuery(values url.Values, now time.Time) (telemetry.LedgerQuery, error) {
+	query := telemetry.LedgerQuery{
+		ClientType: strings.TrimSpace(values.Get("client_type")),
+		Provider:   strings.TrimSpace(values.Get("provider")),
+		Capability: strings.TrimSpace(values.Get("capability")),
+		Model:      strings.TrimSpace(values.Get("model")),
+		Project:    strings.TrimSpace(values.Get("project")),
+		SessionKey: strings.TrimSpace(values.Get("session")),
+		Limit:      usageRequestsDefaultLimit,
+	}
+
+	var err error
+	if query.Since, err = telemetry.ParseLedgerTime(values.Get("since"), now); err != nil {
+		return query, fmt.Errorf("invalid since: %v", err)
+	}
+	if query.Until, err = telemetry.ParseLedgerTime(values.Get("until"), now); err != nil {
+		return query, fmt.Errorf("invalid until: %v", err)
+	}
+	if raw := strings.TrimSpace(values.Get("status")); raw != "" {
+		if query.Status, err = strconv.Atoi(raw); err != nil || query.Status < 100 || query.Status > 599 {
+			return query, fmt.Errorf("invalid status: %s", raw)
+		}
+	}
+	if raw := strings.TrimSpace(values.Get("failed")); raw != "" {
+		if query.Failed, err = strconv.ParseBool(raw); err != nil {
+			return query, fmt.Errorf("invalid failed: %s", raw)
+		}
+	}
+	if raw := strings.TrimSpace(values.Get("limit")); raw != "" {
+		if query.Limit, err = strconv.Atoi(raw); err != nil || query.Limit < 1 {
+			return query, fmt.Errorf("invalid limit: %s", raw)
+		}
+		query.Limit = min(query.Limit, usageRequestsMaxLimit)
+	}
+	if raw := strings.TrimSpace(values.Get("offset")); raw != "" {
+		if query.Offset, err = strconv.Atoi(raw); err != nil || query.Offset < 0 {
+			return query, fmt.Errorf("invalid offset: %s", raw)
+		}
+	}
+	return query, nil
+}
+
+// HandleResetUsage archives the cumulative usage counters shown on provider
+// cards and starts a new period. The request ledger is left untouched;
+// `clipal usage` reports start at the recorded period start instead.
+//
+//	POST /api/usage/reset
+func (a *API) HandleResetUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.telemetry == nil {
+		writeError(w, "usage telemetry is unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	now := time.Now()
+	archive, err := a.telemetry.Reset(now)
+	if err != nil {
+		writeError(w, fmt.Sprintf("failed to reset usage: %v", err), http.StatusInternalServerError)
+		return
+	}
+	logger.Info("usage counters reset via web interface (archive: %s)", archive)
+	writeJSON(w, UsageResetResponse{
+		Archive:     archive,
+		PeriodStart: now.Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,126 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeUsageLedgerFixture(t *testing.T, dir string, now time.Time) {
+	t.Helper()
+	lines := []string{
+		fmt.Sprintf(`{"time":%q,"client_type":"openai","provider":"p1","requested_model":"gpt-5","status":200,"total_tokens":30,"cost_micros":300,"has_cost":true}`, now.Add(-48*time.Hour).Format(time.RFC3339)),
+		fmt.Sprintf(`{"time":%q,"client_type":"openai","provider":"p1","requested_model":"gpt-5","status":200,"total_tokens":10,"cost_micros":100,"has_cost":true}`, now.Add(-2*time.Hour).Format(time.RFC3339)),
+		fmt.Sprintf(`{"time":%q,"client_type":"openai","provider":"p2","requested_model":"gpt-5","status":429}`, now.Add(-time.Hour).Format(time.RFC3339)),
+		fmt.Sprintf(`{"time":%q,"client_type":"claude","provider":"c1","requested_model":"sonnet","status":200,"total_tokens":5,"project":"web","session_id":"s1"}`, now.Add(-30*time.Minute).Format(time.RFC3339)),
+	}
+	if err := os.WriteFile(filepath.Join(dir, "usage-ledger.jsonl"), []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile ledger: %v", err)
+	}
+}
+
+func getUsageRequests(t *testing.T, api *API, rawQuery string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/usage/requests?"+rawQuery, nil)
+	w := httptest.NewRecorder()
+	api.HandleListUsageRequests(w, req)
+	return w
+}
+
+func TestHandleListUsageRequests_FiltersAndPages(t *testing.T) {
+	dir := t.TempDir()
+	writeUsageLedgerFixture(t, dir, time.Now())
+	api := NewAPI(dir, "test", nil)
+
+	w := getUsageRequests(t, api, "since=24h&client_type=openai&limit=1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+	var resp UsageRequestsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if resp.Total != 2 || resp.Limit != 1 || len(resp.Entries) != 1 || resp.Entries[0].Provider != "p2" {
+		t.Fatalf("resp = %#v", resp)
+	}
+
+	w = getUsageRequests(t, api, "failed=true")
+	resp = UsageRequestsResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if resp.Total != 1 || resp.Entries[0].Status != http.StatusTooManyRequests {
+		t.Fatalf("failed resp = %#v", resp)
+	}
+	w = getUsageRequests(t, api, "project=web&session=s1")
+	resp = UsageRequestsResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if resp.Total != 1 || resp.Entries[0].Provider != "c1" || resp.Entries[0].SessionID != "s1" {
+		t.Fatalf("project resp = %#v", resp)
+	}
+}
+
+func TestHandleListUsageRequests_GroupBy(t *testing.T) {
+	dir := t.TempDir()
+	writeUsageLedgerFixture(t, dir, time.Now())
+	api := NewAPI(dir, "test", nil)
+
+	w := getUsageRequests(t, api, "since=7d&group_by=provider")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+	var resp UsageRequestGroupsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if resp.GroupBy != "provider" || len(resp.Groups) != 3 {
+		t.Fatalf("resp = %#v", resp)
+	}
+	if got := resp.Groups[0]; got.Key != "p1" || got.Requests != 2 || got.CostMicros != 400 || got.TotalTokens != 40 {
+		t.Fatalf("p1 group = %#v", got)
+	}
+}
+
+func TestHandleListUsageRequests_RejectsInvalidQuery(t *testing.T) {
+	api := NewAPI(t.TempDir(), "test", nil)
+
+	for _, rawQuery := range []string{"since=yesterday", "limit=0", "status=42", "group_by=bogus"} {
+		if w := getUsageRequests(t, api, rawQuery); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d body=%s", rawQuery, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestHandleResetUsage_ArchivesCounters(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "usage.json"), []byte(`{"version":4,"clients":{"openai":{"providers":{"p1":{"request_count":2}}}}}`), 0o600); err != nil {
+		t.Fatalf("WriteFile usage: %v", err)
+	}
+	api := NewAPI(dir, "test", nil)
+	defer func() { _ = api.telemetry.Close() }()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/usage/reset", nil)
+	w := httptest.NewRecorder()
+	api.HandleResetUsage(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", w.Code, w.Body.String())
+	}
+	var resp UsageResetResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if filepath.Dir(resp.Archive) != filepath.Join(dir, "usage-archive") || resp.PeriodStart == "" {
+		t.Fatalf("resp = %#v", resp)
+	}
+	if _, ok := api.telemetry.ProviderSnapshot("openai", "p1"); ok {
+		t.Fatalf("expected counters to be cleared")
+	}
+}
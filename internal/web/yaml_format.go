@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/lansespirit/Clipal/internal/config"
@@ -111,6 +112,14 @@ func formatClientConfigYAML(clientType string, cc config.ClientConfig) []byte {
 				}
 			}
 		}
+		if len(p.FailureRules) > 0 {
+			writeBufferString(&b, "    failure_rules:\n")
+			writeFailureRulesYAML(&b, "      ", p.FailureRules)
+		}
+		if len(p.FallbackModels) > 0 {
+			writeBufferString(&b, "    fallback_models:\n")
+			writeFallbackModelsYAML(&b, "      ", p.FallbackModels)
+		}
 	}
 
 	writeBufferString(&b, "\n")
@@ -160,6 +169,30 @@ func formatGlobalConfigYAML(gc config.GlobalConfig) []byte {
 	writeBufferString(&b, fmt.Sprintf("  min_level: %s # debug | info | warn | error\n", yamlDoubleQuote(strings.TrimSpace(string(gc.Notifications.MinLevel)))))
 	writeBufferString(&b, fmt.Sprintf("  provider_switch: %v\n", boolPtrOrTrue(gc.Notifications.ProviderSwitch)))
 
+	writeBufferString(&b, "\n# Per-request usage ledger (<config-dir>/usage-ledger.jsonl)\n")
+	writeBufferString(&b, "usage_ledger:\n")
+	writeBufferString(&b, fmt.Sprintf("  enabled: %v\n", gc.UsageLedger.Enabled))
+	writeBufferString(&b, fmt.Sprintf("  retention_days: %d # 0 keeps entries forever\n", gc.UsageLedger.RetentionDays))
+	writeBufferString(&b, fmt.Sprintf("  max_entries: %d # 0 means unlimited\n", gc.UsageLedger.MaxEntries))
+
+	if tc := gc.Tracing; tc.Enabled || strings.TrimSpace(tc.Endpoint) != "" || len(tc.Headers) > 0 || strings.TrimSpace(tc.ServiceName) != "" {
+		writeBufferString(&b, "\n# OpenTelemetry trace export over OTLP/HTTP\n")
+		writeBufferString(&b, "tracing:\n")
+		writeBufferString(&b, fmt.Sprintf("  enabled: %v\n", tc.Enabled))
+		if strings.TrimSpace(tc.Endpoint) != "" {
+			writeBufferString(&b, fmt.Sprintf("  endpoint: %s\n", yamlDoubleQuote(strings.TrimSpace(tc.Endpoint))))
+		}
+		if len(tc.Headers) > 0 {
+			writeBufferString(&b, "  headers:\n")
+			for _, name := range sortedStringMapKeys(tc.Headers) {
+				writeBufferString(&b, fmt.Sprintf("    %s: %s\n", yamlDoubleQuote(name), yamlDoubleQuote(tc.Headers[name])))
+			}
+		}
+		if strings.TrimSpace(tc.ServiceName) != "" {
+			writeBufferString(&b, fmt.Sprintf("  service_name: %s\n", yamlDoubleQuote(strings.TrimSpace(tc.ServiceName))))
+		}
+	}
+
 	writeBufferString(&b, "\n# Routing strategy\n")
 	writeBufferString(&b, "routing:\n")
 	writeBufferString(&b, "  sticky_sessions:\n")
@@ -175,11 +208,221 @@ func formatGlobalConfigYAML(gc config.GlobalConfig) []byte {
 	writeBufferString(&b, fmt.Sprintf("    probe_max_inflight: %d\n", gc.Routing.BusyBackpressure.ProbeMaxInFlight))
 	writeBufferString(&b, fmt.Sprintf("    short_retry_after_max: %s\n", yamlDoubleQuote(strings.TrimSpace(gc.Routing.BusyBackpressure.ShortRetryAfterMax))))
 	writeBufferString(&b, fmt.Sprintf("    max_inline_wait: %s\n", yamlDoubleQuote(strings.TrimSpace(gc.Routing.BusyBackpressure.MaxInlineWait))))
+	if ft := gc.Routing.FirstTokenTimeout; strings.TrimSpace(ft.Default) != "" || len(ft.Capabilities) > 0 {
+		writeBufferString(&b, "  # Fail over a stream that sends only keepalives for too long after headers. 0 disables.\n")
+		writeBufferString(&b, "  first_token_timeout:\n")
+		if strings.TrimSpace(ft.Default) != "" {
+			writeBufferString(&b, fmt.Sprintf("    default: %s\n", yamlDoubleQuote(strings.TrimSpace(ft.Default))))
+		}
+		if len(ft.Capabilities) > 0 {
+			capabilities := make([]string, 0, len(ft.Capabilities))
+			for capability := range ft.Capabilities {
+				capabilities = append(capabilities, capability)
+			}
+			sort.Strings(capabilities)
+			writeBufferString(&b, "    capabilities:\n")
+			for _, capability := range capabilities {
+				writeBufferString(&b, fmt.Sprintf("      %s: %s\n", yamlDoubleQuote(capability), yamlDoubleQuote(strings.TrimSpace(ft.Capabilities[capability]))))
+			}
+		}
+	}
+	if sr := gc.Routing.StreamRecovery; sr.Enabled || sr.MaxAttempts > 0 {
+		writeBufferString(&b, "  # Continue an interrupted text stream on another provider from the relayed output.\n")
+		writeBufferString(&b, "  stream_recovery:\n")
+		writeBufferString(&b, fmt.Sprintf("    enabled: %v\n", sr.Enabled))
+		if sr.MaxAttempts > 0 {
+			writeBufferString(&b, fmt.Sprintf("    max_attempts: %d\n", sr.MaxAttempts))
+		}
+	}
+	if mode := gc.Routing.CountTokens; mode != "" {
+		writeBufferString(&b, "  # fallback, local, or upstream: where count_tokens requests are answered.\n")
+		writeBufferString(&b, fmt.Sprintf("  count_tokens: %s\n", mode))
+	}
+	if len(gc.Routing.FailureRules) > 0 {
+		writeBufferString(&b, "  # Evaluated in order after each provider's own failure_rules; first match wins.\n")
+		writeBufferString(&b, "  failure_rules:\n")
+		writeFailureRulesYAML(&b, "    ", gc.Routing.FailureRules)
+	}
+	if len(gc.Routing.FallbackModels) > 0 {
+		writeBufferString(&b, "  # Models tried in order on the same provider when the requested model is unavailable.\n")
+		writeBufferString(&b, "  fallback_models:\n")
+		writeFallbackModelsYAML(&b, "    ", gc.Routing.FallbackModels)
+	}
+	if len(gc.Routing.ModelList) > 0 {
+		writeBufferString(&b, "  # Per client type: how /v1/models requests are answered (current, merged, or static).\n")
+		writeBufferString(&b, "  model_list:\n")
+		writeModelListsYAML(&b, "    ", gc.Routing.ModelList)
+	}
 
 	writeBufferString(&b, "\n")
 	return b.Bytes()
 }
 
+func formatPricingYAML(p config.PricingConfig) []byte {
+	var b bytes.Buffer
+
+	writeBufferString(&b, "# Model pricing for clipal (overrides and extends the built-in tables)\n")
+	writeBufferString(&b, "# Prices are per million tokens. Managed by the web UI.\n\n")
+
+	if len(p.Models) == 0 {
+		writeBufferString(&b, "models: []\n")
+	} else {
+		writeBufferString(&b, "# First match wins; a trailing * matches a model prefix.\n")
+		writeBufferString(&b, "models:\n")
+	}
+	for _, entry := range p.Models {
+		writeBufferString(&b, fmt.Sprintf("  - model: %s\n", yamlDoubleQuote(strings.TrimSpace(entry.Model))))
+		if currency := strings.TrimSpace(entry.Currency); currency != "" {
+			writeBufferString(&b, fmt.Sprintf("    currency: %s\n", yamlDoubleQuote(currency)))
+		}
+		writePriceRatesYAML(&b, "    ", entry.PriceRates)
+		if len(entry.Tiers) > 0 {
+			writeBufferString(&b, "    tiers:\n")
+		}
+		for _, tier := range entry.Tiers {
+			writeBufferString(&b, fmt.Sprintf("      - above_input_tokens: %d\n", tier.AboveInputTokens))
+			writePriceRatesYAML(&b, "        ", tier.PriceRates)
+		}
+	}
+
+	if len(p.ProviderMultipliers) > 0 {
+		writeBufferString(&b, "\n# Scale inferred costs per provider (e.g. 1.1 for a 10% markup).\n")
+		writeBufferString(&b, "provider_multipliers:\n")
+		writeFloatMapYAML(&b, "  ", p.ProviderMultipliers)
+	}
+	if len(p.ExchangeRates) > 0 {
+		writeBufferString(&b, "\n# USD value of one unit of each currency used above.\n")
+		writeBufferString(&b, "exchange_rates:\n")
+		writeFloatMapYAML(&b, "  ", p.ExchangeRates)
+	}
+	return b.Bytes()
+}
+
+func writePriceRatesYAML(b *bytes.Buffer, indent string, rates config.PriceRates) {
+	for _, field := range []struct {
+		name  string
+		value *float64
+	}{
+		{"input", rates.Input},
+		{"output", rates.Output},
+		{"cache_read", rates.CacheRead},
+		{"cache_write", rates.CacheWrite},
+		{"reasoning", rates.Reasoning},
+	} {
+		if field.value != nil {
+			writeBufferString(b, fmt.Sprintf("%s%s: %s\n", indent, field.name, yamlFloat(*field.value)))
+		}
+	}
+}
+
+func writeFloatMapYAML(b *bytes.Buffer, indent string, m map[string]float64) {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		writeBufferString(b, fmt.Sprintf("%s%s: %s\n", indent, yamlDoubleQuote(strings.TrimSpace(key)), yamlFloat(m[key])))
+	}
+}
+
+func yamlFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func sortedStringMapKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeFallbackModelsYAML(b *bytes.Buffer, indent string, models config.FallbackModels) {
+	names := make([]string, 0, len(models))
+	for name := range models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeBufferString(b, fmt.Sprintf("%s%s: [%s]\n", indent, yamlDoubleQuote(strings.TrimSpace(name)), yamlInlineQuotedList(models[name])))
+	}
+}
+
+func writeModelListsYAML(b *bytes.Buffer, indent string, lists config.ModelLists) {
+	clients := make([]string, 0, len(lists))
+	for client := range lists {
+		clients = append(clients, client)
+	}
+	sort.Strings(clients)
+	for _, client := range clients {
+		list := lists[client]
+		writeBufferString(b, fmt.Sprintf("%s%s:", indent, client))
+		if list.Source == "" && len(list.Models) == 0 && len(list.Aliases) == 0 {
+			writeBufferString(b, " {}\n")
+			continue
+		}
+		writeBufferString(b, "\n")
+		if list.Source != "" {
+			writeBufferString(b, fmt.Sprintf("%s  source: %s\n", indent, list.Source))
+		}
+		if len(list.Models) > 0 {
+			writeBufferString(b, fmt.Sprintf("%s  models: [%s]\n", indent, yamlInlineQuotedList(list.Models)))
+		}
+		if len(list.Aliases) > 0 {
+			writeBufferString(b, fmt.Sprintf("%s  aliases: [%s]\n", indent, yamlInlineQuotedList(list.Aliases)))
+		}
+	}
+}
+
+func writeFailureRulesYAML(b *bytes.Buffer, indent string, rules []config.FailureRule) {
+	for _, rule := range rules {
+		prefix := indent + "- "
+		field := func(line string) {
+			writeBufferString(b, prefix+line+"\n")
+			prefix = indent + "  "
+		}
+		if name := strings.TrimSpace(rule.Name); name != "" {
+			field(fmt.Sprintf("name: %s", yamlDoubleQuote(name)))
+		}
+		if len(rule.Status) > 0 {
+			statuses := make([]string, 0, len(rule.Status))
+			for _, status := range rule.Status {
+				statuses = append(statuses, fmt.Sprintf("%d", status))
+			}
+			field(fmt.Sprintf("status: [%s]", strings.Join(statuses, ", ")))
+		}
+		if len(rule.Headers) > 0 {
+			names := make([]string, 0, len(rule.Headers))
+			for name := range rule.Headers {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			field("headers:")
+			for _, name := range names {
+				writeBufferString(b, fmt.Sprintf("%s  %s: %s\n", prefix, yamlDoubleQuote(name), yamlDoubleQuote(rule.Headers[name])))
+			}
+		}
+		if rule.BodyRegex != "" {
+			field(fmt.Sprintf("body_regex: %s", yamlDoubleQuote(rule.BodyRegex)))
+		}
+		if strings.TrimSpace(rule.JSONPath) != "" {
+			field(fmt.Sprintf("json_path: %s", yamlDoubleQuote(strings.TrimSpace(rule.JSONPath))))
+		}
+		if rule.JSONValue != "" {
+			field(fmt.Sprintf("json_value: %s", yamlDoubleQuote(rule.JSONValue)))
+		}
+		field(fmt.Sprintf("action: %s # return_to_client | retry_next | busy_retry | deactivate_provider | deactivate_key", yamlDoubleQuote(string(rule.NormalizedAction()))))
+		if reason := strings.TrimSpace(rule.Reason); reason != "" {
+			field(fmt.Sprintf("reason: %s", yamlDoubleQuote(reason)))
+		}
+		if cooldown := strings.TrimSpace(rule.Cooldown); cooldown != "" {
+			field(fmt.Sprintf("cooldown: %s", yamlDoubleQuote(cooldown)))
+		}
+	}
+}
+
 func yamlInlineQuotedList(values []string) string {
 	if len(values) == 0 {
 		return ""
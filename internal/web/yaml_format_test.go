@@ -3,6 +3,7 @@ package web
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -532,3 +533,171 @@ func TestFormatGlobalConfigYAML_RoundTripAndEscapesSpecialCharacters(t *testing.
 		t.Fatalf("provider_switch = %v, want false", loaded.Global.Notifications.ProviderSwitch)
 	}
 }
+
+func TestFormatConfigYAML_FailureRulesRoundTrip(t *testing.T) {
+	rules := []config.FailureRule{
+		{
+			Name:      "region",
+			Status:    []int{403, 451},
+			Headers:   map[string]string{"X-Region": `^(cn|ru)$`},
+			BodyRegex: `(?i)"unsupported region"`,
+			Action:    config.FailureRuleActionDeactivateProvider,
+			Cooldown:  "6h",
+		},
+		{
+			JSONPath:  "error.code",
+			JSONValue: "^insufficient_balance$",
+			Action:    config.FailureRuleActionDeactivateKey,
+			Reason:    "billing",
+		},
+	}
+
+	gc := config.DefaultGlobalConfig()
+	gc.Routing.FailureRules = rules
+	var parsedGlobal config.GlobalConfig
+	if err := yaml.Unmarshal(formatGlobalConfigYAML(gc), &parsedGlobal); err != nil {
+		t.Fatalf("yaml.Unmarshal global: %v\n%s", err, formatGlobalConfigYAML(gc))
+	}
+	if !reflect.DeepEqual(parsedGlobal.Routing.FailureRules, rules) {
+		t.Fatalf("global failure_rules = %#v, want %#v", parsedGlobal.Routing.FailureRules, rules)
+	}
+
+	cc := config.ClientConfig{
+		Providers: []config.Provider{
+			{Name: "p1", BaseURL: "https://a.example", APIKey: "k1", Priority: 1, Enabled: boolPtr(true), FailureRules: rules},
+		},
+	}
+	var parsedClient config.ClientConfig
+	if err := yaml.Unmarshal(formatClientConfigYAML("openai", cc), &parsedClient); err != nil {
+		t.Fatalf("yaml.Unmarshal client: %v\n%s", err, formatClientConfigYAML("openai", cc))
+	}
+	if len(parsedClient.Providers) != 1 || !reflect.DeepEqual(parsedClient.Providers[0].FailureRules, rules) {
+		t.Fatalf("provider failure_rules = %#v, want %#v", parsedClient.Providers, rules)
+	}
+}
+
+func TestFormatConfigYAML_FallbackModelsRoundTrip(t *testing.T) {
+	models := config.FallbackModels{
+		"claude-opus-4-1": {"claude-sonnet-4-5", "claude-haiku-4-5"},
+		"gpt-5":           {"gpt-5-mini"},
+	}
+
+	gc := config.DefaultGlobalConfig()
+	gc.Routing.FallbackModels = models
+	var parsedGlobal config.GlobalConfig
+	if err := yaml.Unmarshal(formatGlobalConfigYAML(gc), &parsedGlobal); err != nil {
+		t.Fatalf("yaml.Unmarshal global: %v\n%s", err, formatGlobalConfigYAML(gc))
+	}
+	if !reflect.DeepEqual(parsedGlobal.Routing.FallbackModels, models) {
+		t.Fatalf("global fallback_models = %#v, want %#v", parsedGlobal.Routing.FallbackModels, models)
+	}
+
+	cc := config.ClientConfig{
+		Providers: []config.Provider{
+			{Name: "p1", BaseURL: "https://a.example", APIKey: "k1", Priority: 1, Enabled: boolPtr(true), FallbackModels: models},
+		},
+	}
+	var parsedClient config.ClientConfig
+	if err := yaml.Unmarshal(formatClientConfigYAML("claude", cc), &parsedClient); err != nil {
+		t.Fatalf("yaml.Unmarshal client: %v\n%s", err, formatClientConfigYAML("claude", cc))
+	}
+	if len(parsedClient.Providers) != 1 || !reflect.DeepEqual(parsedClient.Providers[0].FallbackModels, models) {
+		t.Fatalf("provider fallback_models = %#v, want %#v", parsedClient.Providers, models)
+	}
+}
+
+func TestFormatGlobalConfigYAML_ModelListRoundTrip(t *testing.T) {
+	lists := config.ModelLists{
+		"claude": {},
+		"gemini": {Source: config.ModelListSourceStatic, Models: []string{"gemini-2.5-pro", "gemini-2.5-flash"}},
+		"openai": {Source: config.ModelListSourceMerged, Aliases: []string{"fast"}},
+	}
+
+	gc := config.DefaultGlobalConfig()
+	gc.Routing.ModelList = lists
+	var parsed config.GlobalConfig
+	if err := yaml.Unmarshal(formatGlobalConfigYAML(gc), &parsed); err != nil {
+		t.Fatalf("yaml.Unmarshal global: %v\n%s", err, formatGlobalConfigYAML(gc))
+	}
+	if !reflect.DeepEqual(parsed.Routing.ModelList, lists) {
+		t.Fatalf("model_list = %#v, want %#v", parsed.Routing.ModelList, lists)
+	}
+}
+
+func TestFormatGlobalConfigYAML_FirstTokenTimeoutRoundTrip(t *testing.T) {
+	gc := config.DefaultGlobalConfig()
+	want := config.FirstTokenTimeoutConfig{
+		Default:      "90s",
+		Capabilities: map[string]string{"openai_responses": "3m", "claude_messages": "0"},
+	}
+	gc.Routing.FirstTokenTimeout = want
+
+	var parsed config.GlobalConfig
+	if err := yaml.Unmarshal(formatGlobalConfigYAML(gc), &parsed); err != nil {
+		t.Fatalf("yaml.Unmarshal global: %v\n%s", err, formatGlobalConfigYAML(gc))
+	}
+	if !reflect.DeepEqual(parsed.Routing.FirstTokenTimeout, want) {
+		t.Fatalf("first_token_timeout = %#v, want %#v", parsed.Routing.FirstTokenTimeout, want)
+	}
+
+	if out := string(formatGlobalConfigYAML(config.DefaultGlobalConfig())); strings.Contains(out, "first_token_timeout") {
+		t.Fatalf("expected first_token_timeout to be omitted when unset:\n%s", out)
+	}
+}
+
+func TestFormatGlobalConfigYAML_StreamRecoveryRoundTrip(t *testing.T) {
+	gc := config.DefaultGlobalConfig()
+	want := config.StreamRecoveryConfig{Enabled: true, MaxAttempts: 2}
+	gc.Routing.StreamRecovery = want
+	gc.Routing.CountTokens = config.CountTokensModeLocal
+
+	var parsed config.GlobalConfig
+	if err := yaml.Unmarshal(formatGlobalConfigYAML(gc), &parsed); err != nil {
+		t.Fatalf("yaml.Unmarshal global: %v\n%s", err, formatGlobalConfigYAML(gc))
+	}
+	if parsed.Routing.StreamRecovery != want {
+		t.Fatalf("stream_recovery = %#v, want %#v", parsed.Routing.StreamRecovery, want)
+	}
+	if parsed.Routing.CountTokens != config.CountTokensModeLocal {
+		t.Fatalf("count_tokens = %q, want local", parsed.Routing.CountTokens)
+	}
+
+	if out := string(formatGlobalConfigYAML(config.DefaultGlobalConfig())); strings.Contains(out, "stream_recovery") {
+		t.Fatalf("expected stream_recovery to be omitted when disabled:\n%s", out)
+	}
+}
+
+func TestFormatGlobalConfigYAML_UsageLedgerRoundTrip(t *testing.T) {
+	gc := config.DefaultGlobalConfig()
+	want := config.UsageLedgerConfig{Enabled: false, RetentionDays: 7, MaxEntries: 1000}
+	gc.UsageLedger = want
+
+	var parsed config.GlobalConfig
+	if err := yaml.Unmarshal(formatGlobalConfigYAML(gc), &parsed); err != nil {
+		t.Fatalf("yaml.Unmarshal global: %v\n%s", err, formatGlobalConfigYAML(gc))
+	}
+	if parsed.UsageLedger != want {
+		t.Fatalf("usage_ledger = %#v, want %#v", parsed.UsageLedger, want)
+	}
+}
+
+func TestFormatGlobalConfigYAML_TracingRoundTrip(t *testing.T) {
+	gc := config.DefaultGlobalConfig()
+	if strings.Contains(string(formatGlobalConfigYAML(gc)), "tracing:") {
+		t.Fatalf("default config should omit the tracing section")
+	}
+	gc.Tracing = config.TracingConfig{
+		Enabled:     true,
+		Endpoint:    "https://collector.example.com:4318",
+		Headers:     map[string]string{"Authorization": "Bearer token", "X-Tenant": "team #1"},
+		ServiceName: "clipal-dev",
+	}
+
+	var parsed config.GlobalConfig
+	if err := yaml.Unmarshal(formatGlobalConfigYAML(gc), &parsed); err != nil {
+		t.Fatalf("yaml.Unmarshal global: %v\n%s", err, formatGlobalConfigYAML(gc))
+	}
+	if !reflect.DeepEqual(parsed.Tracing, gc.Tracing) {
+		t.Fatalf("tracing = %#v, want %#v", parsed.Tracing, gc.Tracing)
+	}
+}